@@ -1,63 +1,231 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/jordanpartridge/agentctl/pkg/board"
+	"github.com/jordanpartridge/agentctl/pkg/config"
 	"github.com/jordanpartridge/agentctl/pkg/container"
 	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/daemon"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
 	"github.com/jordanpartridge/agentctl/pkg/pipeline"
 	"github.com/jordanpartridge/agentctl/pkg/review"
+	"github.com/jordanpartridge/agentctl/pkg/tutorial"
 )
 
 func main() {
+	args, ns, err := extractNamespace(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if err := namespace.Set(ns); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
 	}
 
+	switch os.Args[1] {
+	case "spawn", "run", "kill":
+		if bulkRequested(os.Args[2:]) {
+			bulkCommand(os.Args[1], os.Args[2:])
+			return
+		}
+	}
+
 	switch os.Args[1] {
 	case "spawn":
 		if len(os.Args) < 4 {
-			fmt.Println("Usage: agentctl spawn <name> <repo> [branch] [--image <image>] [--intent <text>]")
+			fmt.Println("Usage: agentctl spawn <name> <repo> [branch] [--image <image>] [--profile <name>] [--workspace <path>] [--intent <text>] [--no-setup] [--setup <cmd>]... [--no-coord-mount] [--tmpfs-size <size>] [--disk-quota <size>] [--after <agent>]... [--needs <agent>,...] [--egress] [--allow-host <host>]... [--wait] [--yolo] [--no-toolchain-check] [--no-references]")
 			os.Exit(1)
 		}
-		branch := "main"
-		intent := ""
-		image := ""
+		opts := container.SpawnOptions{Name: os.Args[2], Repo: os.Args[3]}
 		positional := 0
 		for i := 4; i < len(os.Args); i++ {
 			if os.Args[i] == "--intent" && i+1 < len(os.Args) {
-				intent = os.Args[i+1]
+				opts.Intent = os.Args[i+1]
 				i++
 			} else if os.Args[i] == "--image" && i+1 < len(os.Args) {
-				image = os.Args[i+1]
+				opts.Image = os.Args[i+1]
+				i++
+			} else if os.Args[i] == "--profile" && i+1 < len(os.Args) {
+				opts.Profile = os.Args[i+1]
+				i++
+			} else if os.Args[i] == "--workspace" && i+1 < len(os.Args) {
+				opts.Workspace = os.Args[i+1]
+				i++
+			} else if os.Args[i] == "--setup" && i+1 < len(os.Args) {
+				opts.SetupCommands = append(opts.SetupCommands, os.Args[i+1])
+				i++
+			} else if os.Args[i] == "--no-setup" {
+				opts.SkipSetup = true
+			} else if os.Args[i] == "--no-coord-mount" {
+				opts.NoCoordMount = true
+			} else if os.Args[i] == "--tmpfs-size" && i+1 < len(os.Args) {
+				opts.TmpfsSize = os.Args[i+1]
+				i++
+			} else if os.Args[i] == "--disk-quota" && i+1 < len(os.Args) {
+				opts.DiskQuota = os.Args[i+1]
+				i++
+			} else if os.Args[i] == "--after" && i+1 < len(os.Args) {
+				opts.After = append(opts.After, os.Args[i+1])
+				i++
+			} else if os.Args[i] == "--needs" && i+1 < len(os.Args) {
+				opts.Needs = append(opts.Needs, strings.Split(os.Args[i+1], ",")...)
+				i++
+			} else if os.Args[i] == "--egress" {
+				opts.RestrictEgress = true
+			} else if os.Args[i] == "--wait" {
+				opts.WaitForCapacity = true
+			} else if os.Args[i] == "--yolo" {
+				opts.Yolo = true
+			} else if os.Args[i] == "--no-toolchain-check" {
+				opts.SkipToolchainCheck = true
+			} else if os.Args[i] == "--no-references" {
+				opts.NoReferences = true
+			} else if os.Args[i] == "--allow-host" && i+1 < len(os.Args) {
+				opts.AllowHosts = append(opts.AllowHosts, strings.Split(os.Args[i+1], ",")...)
 				i++
 			} else if !strings.HasPrefix(os.Args[i], "--") {
 				if positional == 0 {
-					branch = os.Args[i]
+					opts.Branch = os.Args[i]
 				}
 				positional++
 			}
 		}
-		agent, err := container.SpawnWithIntent(os.Args[2], os.Args[3], branch, intent, image)
+		spawnAgent(opts)
+
+	case "export":
+		// Write a shareable agent definition: agentctl export <name> > agent.yaml
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl export <name> > agent.yaml")
+			os.Exit(1)
+		}
+		def, err := container.Export(os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		data, err := container.MarshalDefinition(def)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		os.Stdout.Write(data)
+
+	case "import":
+		// Recreate an agent from a definition: agentctl import agent.yaml [--name <name>] [--run [attempts]]
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl import <agent.yaml> [--name <name>] [--run [attempts]]")
+			fmt.Println("  Spawns the agent described by the file; --run also starts its task")
 			os.Exit(1)
 		}
-		img := agent.Image
-		fmt.Printf("🤖 Agent: %s\n📦 Container: %s\n🖼️  Image: %s\n🌐 Port: %d\n", agent.Name, agent.ContainerID[:12], img, agent.Port)
+		def, err := container.LoadDefinition(os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		run := false
+		maxAttempts := 10
+		for i := 3; i < len(os.Args); i++ {
+			if os.Args[i] == "--name" && i+1 < len(os.Args) {
+				def.Name = os.Args[i+1]
+				i++
+			} else if os.Args[i] == "--run" {
+				run = true
+				if i+1 < len(os.Args) {
+					if n, err := strconv.Atoi(os.Args[i+1]); err == nil {
+						maxAttempts = n
+						i++
+					}
+				}
+			}
+		}
+		fmt.Printf("📥 Importing agent %s from %s\n", def.Name, os.Args[2])
+		spawnAgent(def.SpawnOptions())
+		if def.Task == "" {
+			break
+		}
+		if !run {
+			fmt.Printf("📋 Task: %s\n", def.Task)
+			fmt.Printf("   Start it with: agentctl run %s '%s'\n", def.Name, strings.ReplaceAll(def.Task, "'", "'\\''"))
+			break
+		}
+		fmt.Printf("🚀 Running agent %s until done (max %d attempts)\n", def.Name, maxAttempts)
+		fmt.Printf("📋 Task: %s\n", def.Task)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		result, err := container.RunUntilDone(def.Name, def.Task, maxAttempts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("✅ Completed in %d attempts\n", result.Attempts)
 
 	case "run":
-		// Run until done: agentctl run <name> <task> [max-attempts]
+		// Run until done: agentctl run <name> <task> [max-attempts] [--force]
+		var force, outputPatch, continueSession bool
+		var applyTo, doneWhen string
+		os.Args, force = forceArg(os.Args)
+		os.Args, continueSession = flagArg(os.Args, "--continue-session")
+		os.Args, outputPatch, applyTo = outputPatchArgs(os.Args)
+		os.Args, doneWhen = doneWhenArg(os.Args)
+		if len(os.Args) >= 4 && os.Args[2] == "--resume" {
+			name := os.Args[3]
+			if force {
+				breakRunLock(name)
+			}
+			maxAttempts := 0
+			if len(os.Args) > 4 {
+				if n, err := strconv.Atoi(os.Args[4]); err == nil {
+					maxAttempts = n
+				}
+			}
+			fmt.Printf("⏯️  Resuming agent %s\n", name)
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			result, err := container.ResumeRun(name, maxAttempts)
+			printInstructions(result)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			fmt.Printf("✅ Completed in %d attempts\n", result.Attempts)
+			applyPatchFor(result, applyTo)
+			return
+		}
 		if len(os.Args) < 4 {
-			fmt.Println("Usage: agentctl run <name> <task> [max-attempts]")
+			fmt.Println("Usage: agentctl run <name> <task> [max-attempts] [--force] [--output-patch] [--apply-to <dir>] [--done-when <cmd>] [--continue-session]")
+			fmt.Println("       agentctl run --resume <name> [max-attempts] [--force]")
 			fmt.Println("  Runs Claude repeatedly until task is complete (tests pass, changes committed)")
+			fmt.Println("  --force takes over the agent from another run that is gone but left its lock")
+			fmt.Println("  --done-when <cmd> judges completion by the command's exit code in the container instead of the tests")
+			fmt.Println("  --output-patch has the agent leave its change uncommitted and saves it as a patch")
+			fmt.Println("  --apply-to <dir> also applies that patch to a local checkout for review")
+			fmt.Println("  --continue-session resumes the last attempt's Claude session in each retry")
 			os.Exit(1)
 		}
+		var yes bool
+		os.Args, yes = yesArg(os.Args)
 		name := os.Args[2]
 		task := os.Args[3]
 		maxAttempts := 10
@@ -66,49 +234,497 @@ func main() {
 				maxAttempts = n
 			}
 		}
+		// A glob runs the task on every agent it matches, in parallel.
+		if container.IsPattern(name) {
+			names := matchAgentsArg([]string{name})
+			if !confirmAgents("Run the task on", names, yes) {
+				os.Exit(1)
+			}
+			if force {
+				for _, n := range names {
+					breakRunLock(n)
+				}
+			}
+			bulkAgents(names, func(n string) container.BulkSpec {
+				return container.BulkSpec{Name: n, Task: task, MaxAttempts: maxAttempts, OutputPatch: outputPatch, DoneWhen: doneWhen, ContinueSession: continueSession}
+			}, mustBulkOp("run"), "completed")
+			return
+		}
+		if force {
+			breakRunLock(name)
+		}
 
 		fmt.Printf("🚀 Running agent %s until done (max %d attempts)\n", name, maxAttempts)
 		fmt.Printf("📋 Task: %s\n", task)
+		if doneWhen != "" {
+			fmt.Printf("🏁 Done when: %s\n", doneWhen)
+		}
+		if continueSession {
+			fmt.Println("🧵 Retries continue the last attempt's session")
+		}
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
-		result, err := container.RunUntilDone(name, task, maxAttempts)
+		result, err := container.RunWithOptions(name, task, maxAttempts, container.RunOptions{OutputPatch: outputPatch, DoneWhen: doneWhen, ContinueSession: continueSession})
+		printInstructions(result)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		fmt.Printf("✅ Completed in %d attempts\n", result.Attempts)
+		applyPatchFor(result, applyTo)
+
+	case "tell":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: agentctl tell <name> \"<message>\"")
+			fmt.Println("  Queues an instruction that is added to the agent's task at its next run attempt")
 			os.Exit(1)
 		}
+		name := os.Args[2]
+		if _, err := container.Tell(name, strings.Join(os.Args[3:], " ")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("📨 Queued for %s's next attempt (%d waiting)\n", name, len(container.PendingInstructions(name)))
+		if _, err := container.LoadCheckpoint(name); err != nil {
+			fmt.Println("   No run is in progress; it will be given to the next `agentctl run`.")
+		}
 
+	case "apply-patch":
+		// Seed the workspace with a partial patch, then run until done.
+		var force bool
+		os.Args, force = forceArg(os.Args)
+		if len(os.Args) < 5 {
+			fmt.Println("Usage: agentctl apply-patch <name> <patch-file> <task> [max-attempts] [--force]")
+			fmt.Println("  Applies the patch to the agent's workspace (uncommitted) and runs the agent to finish it")
+			os.Exit(1)
+		}
+		name, patchPath, task := os.Args[2], os.Args[3], os.Args[4]
+		maxAttempts := 10
+		if len(os.Args) > 5 {
+			if n, err := strconv.Atoi(os.Args[5]); err == nil {
+				maxAttempts = n
+			}
+		}
+		if force {
+			breakRunLock(name)
+		}
+		// Don't touch the workspace of an agent another run is working in.
+		if err := container.CheckRunLock(name); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		files, err := container.ApplyPatch(name, patchPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("🩹 Applied %s to %s: %s\n", patchPath, name, strings.Join(files, ", "))
+		fmt.Printf("🚀 Running agent %s until done (max %d attempts)\n", name, maxAttempts)
+		fmt.Printf("📋 Task: %s\n", task)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		container.RecordIntent(name, task)
+		result, err := container.RunUntilDone(name, container.PatchTask(task, patchPath, files), maxAttempts)
+		printInstructions(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 		fmt.Printf("✅ Completed in %d attempts\n", result.Attempts)
 
 	case "check":
 		// Check completion status
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: agentctl check <name>")
+		var args []string
+		asJSON := false
+		for _, a := range os.Args[2:] {
+			if a == "--json" {
+				asJSON = true
+			} else {
+				args = append(args, a)
+			}
+		}
+		if len(args) < 1 {
+			fmt.Println("Usage: agentctl check <name> [--json]")
 			os.Exit(1)
 		}
-		status := container.CheckCompletion(os.Args[2])
+		status := container.CheckCompletion(args[0])
+		if asJSON {
+			out, _ := json.MarshalIndent(struct {
+				container.AgentStatus
+				Done bool `json:"done"`
+			}{status, status.Done()}, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
 		fmt.Printf("Tests: %s\n", status.TestStatus)
+		if status.TestReport != nil {
+			fmt.Printf("Results: %s\n", status.TestReport)
+		}
 		fmt.Printf("Uncommitted changes: %v\n", status.HasUncommitted)
 		fmt.Printf("Claude running: %v\n", status.ClaudeRunning)
+		if len(status.FailingTests) > 0 {
+			fmt.Printf("Failing tests: %s\n", strings.Join(status.FailingTests, ", "))
+		}
+		if len(status.FlakyTests) > 0 {
+			fmt.Printf("Flaky tests: %s\n", strings.Join(status.FlakyTests, ", "))
+		}
+		if status.Coverage != nil {
+			fmt.Printf("Coverage: %s\n", status.Coverage)
+			if status.Coverage.Low {
+				fmt.Printf("⚠️  %s\n", status.Coverage.Reason)
+			}
+		}
 
-		if status.TestStatus == "pass" && !status.HasUncommitted {
+		if status.Done() {
 			fmt.Println("✅ Agent appears complete")
 		} else {
 			fmt.Println("⏳ Agent has pending work")
 		}
 
+	case "summarize":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl summarize <name> [--pr]")
+			fmt.Println("  Has the model summarize the agent's branch: files, commits, tests and open questions")
+			fmt.Println("  --pr  also write the summary into the body of the branch's open PR")
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		summary, err := container.Summarize(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Println(summary)
+		if len(os.Args) > 3 && os.Args[3] == "--pr" {
+			url, err := container.UpdatePRSummary(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			fmt.Printf("📝 Summary added to %s\n", url)
+		}
+
+	case "pr":
+		// agentctl pr <name> [--title T] [--base B] [--draft]
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl pr <name> [--title <title>] [--base <branch>] [--draft]")
+			fmt.Println("  Opens a PR (an MR on GitLab) for the agent's pushed branch, with its summary as the body")
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		var opts container.PROptions
+		for i := 3; i < len(os.Args); i++ {
+			switch os.Args[i] {
+			case "--draft":
+				opts.Draft = true
+			case "--title", "--base":
+				if i+1 >= len(os.Args) {
+					fmt.Fprintf(os.Stderr, "Error: %s needs a value\n", os.Args[i])
+					os.Exit(1)
+				}
+				if os.Args[i] == "--title" {
+					opts.Title = os.Args[i+1]
+				} else {
+					opts.Base = os.Args[i+1]
+				}
+				i++
+			}
+		}
+		pr, err := container.OpenPR(name, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("🔀 Opened #%d: %s\n", pr.Number, pr.URL)
+
+	case "ci":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl ci <name>")
+			fmt.Println("  Shows the CI result for the agent's branch; exits 1 if it failed")
+			os.Exit(1)
+		}
+		status, err := container.CIStatus(os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		switch status {
+		case forge.CIPass:
+			fmt.Println("✅ CI passed")
+		case forge.CIFail:
+			fmt.Println("❌ CI failed")
+			os.Exit(1)
+		case forge.CIPending:
+			fmt.Println("⏳ CI running")
+		default:
+			fmt.Println("➖ No CI for this branch")
+		}
+
+	case "integrity":
+		// Pre-push sanity checks: agentctl integrity <name>
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl integrity <name>")
+			os.Exit(1)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		problems, err := container.CheckIntegrity(os.Args[2], cfg.Integrity)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(problems) == 0 {
+			fmt.Println("✅ Workspace looks sane")
+			return
+		}
+		for _, p := range problems {
+			fmt.Printf("❌ %s\n", p)
+		}
+		os.Exit(1)
+
+	case "squash":
+		squashCommand(os.Args[2:])
+
+	case "bench":
+		benchCommand(os.Args[2:])
+
+	case "attempts":
+		// Attempt tags: agentctl attempts <name> [<from> <to>] [--stat]
+		if len(os.Args) != 3 && len(os.Args) != 5 && len(os.Args) != 6 {
+			fmt.Println("Usage: agentctl attempts <name> [<from> <to>] [--stat]")
+			fmt.Println("  Lists the agentctl/attempt-N tags, or diffs two attempts' end states")
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		if len(os.Args) > 3 {
+			from, err1 := strconv.Atoi(os.Args[3])
+			to, err2 := strconv.Atoi(os.Args[4])
+			if err1 != nil || err2 != nil {
+				fmt.Fprintf(os.Stderr, "Error: attempts must be numbers\n")
+				os.Exit(1)
+			}
+			diff, err := container.DiffAttempts(name, from, to, len(os.Args) == 6 && os.Args[5] == "--stat")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			fmt.Print(diff)
+			return
+		}
+		refs, err := container.AttemptTags(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(refs) == 0 {
+			fmt.Printf("No attempt tags for %s yet\n", name)
+			return
+		}
+		reports := map[int]*container.TestReport{}
+		if agent, err := container.LoadAgent(name); err == nil {
+			for _, t := range agent.TestReports {
+				reports[t.Attempt] = t.Report
+			}
+		}
+		for _, r := range refs {
+			note := r.Subject
+			if r.Snapshot {
+				note = "(uncommitted work snapshot)"
+			}
+			fmt.Printf("%-20s %s  %s  %s\n", r.Tag, r.Commit, r.Date, note)
+			if report := reports[r.Attempt]; report != nil {
+				fmt.Printf("%-20s 🧪 %s\n", "", report)
+			}
+		}
+
+	case "cp":
+		// Copy files out of (or into) an agent: agentctl cp <name>:<path> <local-path>
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: agentctl cp <name>:<path> <local-path>")
+			fmt.Println("       agentctl cp <local-path> <name>:<path>")
+			fmt.Println("  Relative agent paths are taken from the workspace")
+			os.Exit(1)
+		}
+		if err := container.Copy(os.Args[2], os.Args[3]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "artifacts":
+		// Export artifacts now: agentctl artifacts <name> [path...]
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl artifacts <name> [path...]")
+			fmt.Println("  Copies the given paths (default: artifacts.paths from the config) into " + container.ArtifactsDir("<name>"))
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		paths := os.Args[3:]
+		if len(paths) == 0 {
+			cfg, err := config.Load()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			paths = cfg.Artifacts.Paths
+		}
+		if len(paths) == 0 {
+			fmt.Printf("No artifact paths (pass some, or set \"artifacts\": {\"paths\": [...]} in %s)\n", config.Path())
+			os.Exit(1)
+		}
+		files, err := container.ExportArtifacts(name, paths)
+		for _, f := range files {
+			fmt.Printf("📦 %s\n", f)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "push":
+		// Push the agent's branch after the integrity checks: agentctl push <name> [--no-verify]
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl push <name> [--no-verify]")
+			os.Exit(1)
+		}
+		verify := !(len(os.Args) > 3 && os.Args[3] == "--no-verify")
+		if err := container.Push(os.Args[2], verify); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("⬆️  Pushed %s\n", os.Args[2])
+
+	case "wait":
+		// Block until done: agentctl wait <name> [--timeout 2h] [--interval 10s]
+		// Exit status: 0 completed, 1 failed, 2 timed out.
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl wait <name> [--timeout <duration>] [--interval <duration>]")
+			fmt.Println("  Blocks until the agent completes (exit 0), fails (exit 1) or times out (exit 2)")
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		opts := container.WaitOptions{}
+		for i := 3; i < len(os.Args); i++ {
+			if (os.Args[i] == "--timeout" || os.Args[i] == "--interval") && i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: invalid %s %q: %v\n", os.Args[i], os.Args[i+1], err)
+					os.Exit(1)
+				}
+				if os.Args[i] == "--timeout" {
+					opts.Timeout = d
+				} else {
+					opts.Interval = d
+				}
+				i++
+			}
+		}
+		var lastBeat time.Time
+		opts.Heartbeat = func(elapsed time.Duration, state container.AgentLifecycleState) {
+			if time.Since(lastBeat) < time.Minute {
+				return
+			}
+			lastBeat = time.Now()
+			fmt.Printf("⏳ %s: %s (%s elapsed)\n", name, state, formatDuration(elapsed))
+		}
+
+		fmt.Printf("👀 Waiting for %s", name)
+		if opts.Timeout > 0 {
+			fmt.Printf(" (timeout %s)", opts.Timeout)
+		}
+		fmt.Println()
+		res, err := container.Wait(name, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		switch res.Result {
+		case container.WaitSucceeded:
+			fmt.Printf("✅ %s completed: %s (%s)\n", name, res.Reason, formatDuration(res.Elapsed))
+		case container.WaitFailed:
+			fmt.Printf("❌ %s failed: %s (%s)\n", name, res.Reason, formatDuration(res.Elapsed))
+			os.Exit(1)
+		default:
+			fmt.Printf("⏰ %s timed out: %s\n", name, res.Reason)
+			os.Exit(2)
+		}
+
 	case "kill":
+		args, repo := repoArg(os.Args[2:])
+		args, yes := yesArg(args)
+		if repo != "" {
+			killed := container.KillRepo(repo)
+			sweepOrphans(repo)
+			fmt.Printf("Killed %d agent(s) on %s\n", len(killed), repo)
+			return
+		}
+		if len(args) < 1 {
+			fmt.Println("Usage: agentctl kill <name|glob>... [--yes] | --repo <url>")
+			fmt.Println("  e.g. agentctl kill 'issue-12*' asks before killing every agent it matches")
+			os.Exit(1)
+		}
+		if len(args) == 1 && !container.IsPattern(args[0]) {
+			container.Kill(args[0])
+			return
+		}
+		names := matchAgentsArg(args)
+		if !confirmAgents("Kill", names, yes) {
+			os.Exit(1)
+		}
+		bulkAgents(names, nameSpec, func(s container.BulkSpec) container.BulkResult {
+			if err := container.Kill(s.Name); err != nil {
+				return container.BulkResult{Error: err.Error()}
+			}
+			return container.BulkResult{OK: true}
+		}, "killed")
+
+	case "pause", "resume":
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: agentctl kill <name>")
+			fmt.Printf("Usage: agentctl %s <name>\n", os.Args[1])
 			os.Exit(1)
 		}
-		container.Kill(os.Args[2])
+		pause, icon := container.Pause, "⏸️ "
+		if os.Args[1] == "resume" {
+			pause, icon = container.Resume, "▶️ "
+		}
+		if err := pause(os.Args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("%s %sd: %s\n", icon, os.Args[1], os.Args[2])
+
+	case "namespaces":
+		names, err := namespace.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		for _, ns := range append([]string{namespace.Default}, names...) {
+			label := ns
+			if ns == namespace.Default {
+				label = "(default)"
+			}
+			marker := " "
+			if ns == namespace.Current() {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, label)
+		}
 
 	case "list":
-		agents, err := container.ListWithState()
+		_, repo := repoArg(os.Args[2:])
+		agents, err := container.ListWithStateCached()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
+		}
+		agents = container.AgentsOnRepo(agents, repo)
+		if ns := namespace.Current(); ns != namespace.Default {
+			fmt.Printf("🏷️  Namespace: %s\n", ns)
 		}
 		if len(agents) == 0 {
 			fmt.Println("No agents")
@@ -129,13 +745,23 @@ func main() {
 			case container.StateStopped:
 				indicator = "🔌"
 				label = "stopped"
+			case container.StatePaused:
+				indicator = "⏸️ "
+			}
+			if a.Idle {
+				indicator = "💤"
+				label = "idle " + formatDuration(a.IdleFor)
 			}
 			age := formatDuration(a.Age)
 			cid := a.ContainerID
 			if len(cid) > 12 {
 				cid = cid[:12]
 			}
-			fmt.Printf("%s %-15s %-12s %-12s port:%-5d %s\n", indicator, a.Name, label, cid, a.Port, age)
+			line := fmt.Sprintf("%s %-15s %-12s %-12s port:%-5d %s", indicator, a.Name, label, cid, a.Port, age)
+			if a.Intent != "" {
+				line = fmt.Sprintf("%-64s %s", line, truncateIntent(a.Intent))
+			}
+			fmt.Println(line)
 		}
 
 	case "status":
@@ -143,32 +769,103 @@ func main() {
 			fmt.Println("Usage: agentctl status <name>")
 			os.Exit(1)
 		}
-		container.Status(os.Args[2])
+		if err := container.Status(os.Args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
 
-	case "logs":
+	case "note":
+		// agentctl note <name> ["text"] — add a note, or list notes without text
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: agentctl logs [-f] <name>")
+			fmt.Println("Usage: agentctl note <name> [\"text\"]")
 			os.Exit(1)
 		}
-		// Check for -f flag
-		if os.Args[2] == "-f" {
-			if len(os.Args) < 4 {
-				fmt.Println("Usage: agentctl logs -f <name>")
-				os.Exit(1)
+		name := os.Args[2]
+		if len(os.Args) > 3 {
+			n, err := container.AddNote(name, strings.Join(os.Args[3:], " "))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
 			}
-			container.LogsFollow(os.Args[3])
-		} else {
-			container.Logs(os.Args[2])
+			fmt.Printf("📝 %s: %s\n", name, n)
+			return
 		}
-
-	case "spy":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: agentctl spy <name> [--raw] [--tools] [--thinking] [--verbose] [--json]")
-			os.Exit(1)
+		notes, err := container.Notes(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(notes) == 0 {
+			fmt.Printf("No notes for %s\n", name)
+		}
+		for _, n := range notes {
+			fmt.Printf("📝 %s\n", n)
+		}
+
+	case "logs":
+		usage := "Usage: agentctl logs [-f] <name> [--source " + strings.Join(container.LogSourceNames(), "|") + "] [--tail N] [--since <age>]"
+		name := ""
+		var opts container.LogOptions
+		for i := 2; i < len(os.Args); i++ {
+			var err error
+			switch {
+			case os.Args[i] == "-f" || os.Args[i] == "--follow":
+				opts.Follow = true
+			case os.Args[i] == "--source" && i+1 < len(os.Args):
+				opts.Source = os.Args[i+1]
+				i++
+			case os.Args[i] == "--tail" && i+1 < len(os.Args):
+				if opts.Tail, err = strconv.Atoi(os.Args[i+1]); err != nil || opts.Tail < 0 {
+					err = fmt.Errorf("invalid --tail %q", os.Args[i+1])
+				}
+				i++
+			case os.Args[i] == "--since" && i+1 < len(os.Args):
+				opts.Since, err = container.ParseAge(os.Args[i+1])
+				i++
+			case name == "":
+				name = os.Args[i]
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		}
+		if name == "" {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		if opts.Follow && opts.Since > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --since cannot be combined with -f (use --tail)")
+			os.Exit(1)
+		}
+		if err := container.Logs(name, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "spy":
+		usage := "Usage: agentctl spy <name> [--raw] [--tools] [--tool Bash,Edit] [--path GLOB]... [--grep REGEX] [--thinking] [--verbose] [--diffs] [--json] [--no-color] [--compact] [--wide] [--width N] [--format TEMPLATE] [--out FILE] [--jsonl-out FILE]"
+		if len(os.Args) < 3 {
+			fmt.Println(usage)
+			os.Exit(1)
 		}
 		name := ""
-		opts := container.SpyOptions{}
-		for _, arg := range os.Args[2:] {
+		opts := container.SpyOptions{NoColor: os.Getenv("NO_COLOR") != ""}
+		if cfg, err := config.Load(); err == nil {
+			switch cfg.Spy.Theme {
+			case "plain":
+				opts.NoColor = true
+			case "compact":
+				opts.Compact = true
+			}
+			opts.Width = cfg.Spy.Width
+			opts.Wide = cfg.Spy.Width < 0
+			opts.Format = cfg.Spy.Format
+			opts.Diffs = cfg.Spy.Diffs
+		}
+		args := os.Args[2:]
+		for i := 0; i < len(args); i++ {
+			arg := args[i]
 			switch arg {
 			case "--raw":
 				opts.Raw = true
@@ -178,8 +875,66 @@ func main() {
 				opts.Thinking = true
 			case "--verbose":
 				opts.Verbose = true
+			case "--diffs":
+				opts.Diffs = true
 			case "--json":
 				opts.JSON = true
+			case "--no-color":
+				opts.NoColor = true
+			case "--compact":
+				opts.Compact = true
+			case "--wide":
+				opts.Wide = true
+			case "--width":
+				if i+1 >= len(args) {
+					fmt.Println(usage)
+					os.Exit(1)
+				}
+				i++
+				w, err := strconv.Atoi(args[i])
+				if err != nil || w <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid --width %q\n", args[i])
+					os.Exit(1)
+				}
+				opts.Width, opts.Wide = w, false
+			case "--format":
+				if i+1 >= len(args) {
+					fmt.Println(usage)
+					os.Exit(1)
+				}
+				i++
+				opts.Format = args[i]
+			case "--out", "--jsonl-out", "--record":
+				if i+1 >= len(args) {
+					fmt.Println(usage)
+					os.Exit(1)
+				}
+				i++
+				f, err := container.OpenSpyFile(args[i])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				defer f.Close()
+				if arg == "--out" {
+					opts.Out = f
+				} else {
+					opts.Record = f
+				}
+			case "--tool", "--path", "--grep":
+				if i+1 >= len(args) {
+					fmt.Println(usage)
+					os.Exit(1)
+				}
+				i++
+				switch arg {
+				case "--tool":
+					opts.Tools = append(opts.Tools, strings.Split(args[i], ",")...)
+				case "--path":
+					opts.Paths = append(opts.Paths, args[i])
+				default:
+					opts.Grep = args[i]
+				}
 			default:
 				if !strings.HasPrefix(arg, "--") {
 					name = arg
@@ -187,14 +942,87 @@ func main() {
 			}
 		}
 		if name == "" {
-			fmt.Println("Usage: agentctl spy <name> [--raw] [--tools] [--thinking] [--verbose] [--json]")
+			fmt.Println(usage)
 			os.Exit(1)
 		}
 		if err := container.Spy(name, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "guard":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl guard <name>")
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		policy, err := container.NewPolicy(cfg.Policy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if !cfg.Policy.Enabled {
+			fmt.Println("⚠️  policy.enabled is off in the config; guarding with the built-in rules only")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("🛡️  Guarding %s (%s on violation, Ctrl+C to stop)...\n", name, policy.Action)
+		v, err := container.Guard(name, policy, ctx.Done())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if v != nil {
 			os.Exit(1)
 		}
 
+	case "violations":
+		name := ""
+		if len(os.Args) > 2 {
+			name = os.Args[2]
+		}
+		violations, err := container.ListViolations(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(violations) == 0 {
+			fmt.Println("No policy violations")
+			return
+		}
+		for _, v := range violations {
+			fmt.Printf("🚨 %s %-15s %-6s %s\n", v.Time.Format("2006-01-02 15:04"), v.Agent, v.Action, v.String())
+		}
+
+	case "analytics":
+		analyticsCommand(os.Args[2:])
+
+	case "stats":
+		statsCommand(os.Args[2:])
+
+	case "triage":
+		triageCommand(os.Args[2:])
+
+	case "release-notes":
+		releaseNotesCommand(os.Args[2:])
+
+	case "conflicts":
+		conflictsCommand(os.Args[2:])
+
+	case "workflow":
+		workflowCommand(os.Args[2:])
+
+	case "dag":
+		dagCommand(os.Args[2:])
+
+	case "board":
+		boardCommand(os.Args[2:])
+
 	case "watch":
 		if len(os.Args) < 3 {
 			fmt.Println("Usage: agentctl watch <name>")
@@ -217,7 +1045,7 @@ func main() {
 		info, err := container.Diagnose(os.Args[2])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 		fmt.Println("🔍 Agent Diagnostics")
@@ -266,6 +1094,67 @@ func main() {
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 		fmt.Println(info.ErrorLogs)
 
+	case "verify-auth":
+		// Probe each agent's model credentials with a no-op prompt.
+		var names []string
+		asJSON, refresh := false, true
+		for _, a := range os.Args[2:] {
+			switch a {
+			case "--json":
+				asJSON = true
+			case "--no-refresh":
+				refresh = false
+			default:
+				names = append(names, a)
+			}
+		}
+		if len(names) == 0 {
+			agents, err := container.List()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			for _, a := range agents {
+				names = append(names, a.Name)
+			}
+		}
+		var checks []*container.AuthCheck
+		var failed error
+		for _, name := range names {
+			check, err := container.VerifyAuth(name, refresh)
+			if err != nil {
+				check = &container.AuthCheck{Agent: name, Problem: err.Error()}
+				failed = err
+			} else if !check.OK && failed == nil {
+				failed = fmt.Errorf("%w: %s: %s", container.ErrAuthFailed, name, check.Problem)
+			}
+			checks = append(checks, check)
+		}
+		if asJSON {
+			out, _ := json.MarshalIndent(checks, "", "  ")
+			fmt.Println(string(out))
+		} else {
+			if len(checks) == 0 {
+				fmt.Println("No agents")
+			}
+			for _, c := range checks {
+				indicator := "✅"
+				if !c.OK {
+					indicator = "❌"
+				}
+				fmt.Printf("%s %s: %s\n", indicator, c.Agent, c)
+				for _, what := range c.Refreshed {
+					fmt.Printf("   🔑 Refreshed its %s credentials from the host\n", what)
+				}
+				if c.StaleKey {
+					fmt.Println("   🔑 Spawned with an older AGENT_LLM_KEY; runs pass the host's current one")
+				}
+			}
+		}
+		if failed != nil {
+			os.Exit(exitCode(failed))
+		}
+
 	case "claim":
 		// Claim a file: agentctl claim <agent> <repo-url> <file>
 		if len(os.Args) < 5 {
@@ -279,12 +1168,12 @@ func main() {
 		// Initialize coordination dir
 		if _, err := coordination.Init(repoURL); err != nil {
 			fmt.Fprintf(os.Stderr, "Error initializing coordination: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 		if err := coordination.ClaimFile(repoURL, agentName, filePath); err != nil {
 			fmt.Fprintf(os.Stderr, "Claim failed: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 		fmt.Printf("Claimed %s for agent %s\n", filePath, agentName)
 
@@ -300,15 +1189,23 @@ func main() {
 
 		if err := coordination.ReleaseFile(repoURL, agentName, filePath); err != nil {
 			fmt.Fprintf(os.Stderr, "Release failed: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 		fmt.Printf("Released %s from agent %s\n", filePath, agentName)
 
 	case "notify":
-		// Send a notification: agentctl notify <agent> <repo-url> <type> [key=value...]
+		// Send a notification: agentctl notify <agent> <repo-url> <type> [key=value...] [--force]
+		if len(os.Args) == 3 && os.Args[2] == "--types" {
+			printMessageTypes()
+			return
+		}
+		var force bool
+		os.Args, force = forceArg(os.Args)
 		if len(os.Args) < 5 {
-			fmt.Println("Usage: agentctl notify <agent> <repo-url> <type> [key=value...]")
-			fmt.Println("  Types: committed, pushed, pr_created, merged, rebase_needed")
+			fmt.Println("Usage: agentctl notify <agent> <repo-url> <type> [key=value...] [--force]")
+			fmt.Println("       agentctl notify --types")
+			fmt.Println("  Types: committed, pushed, pr_created, merged, rebase_needed, and those in coordination.message_types")
+			fmt.Println("  --force publishes a message of an unknown type or that doesn't match its type's fields")
 			os.Exit(1)
 		}
 		agentName := os.Args[2]
@@ -327,7 +1224,7 @@ func main() {
 		// Initialize coordination dir
 		if _, err := coordination.Init(repoURL); err != nil {
 			fmt.Fprintf(os.Stderr, "Error initializing coordination: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 		msg := coordination.Message{
@@ -335,24 +1232,46 @@ func main() {
 			Agent: agentName,
 			Data:  data,
 		}
-		if err := coordination.Publish(repoURL, msg); err != nil {
+		publish := coordination.Publish
+		if force {
+			publish = coordination.PublishUnchecked
+		}
+		if err := publish(repoURL, msg); err != nil {
 			fmt.Fprintf(os.Stderr, "Notify failed: %v\n", err)
-			os.Exit(1)
+			if errors.Is(err, coordination.ErrInvalidMessage) {
+				fmt.Fprintln(os.Stderr, "  (--force publishes it anyway; `agentctl notify --types` lists the types)")
+			}
+			os.Exit(exitCode(err))
 		}
 		fmt.Printf("Published %s from agent %s\n", msgType, agentName)
 
+	case "plan":
+		planCommand(os.Args[2:])
+
+	case "claims":
+		claimsCommand(os.Args[2:])
+
+	case "capacity":
+		capacityCommand(os.Args[2:])
+
 	case "bus":
-		// Show bus state: agentctl bus <repo-url> [--claims] [--messages] [--state]
+		// Show bus state: agentctl bus <repo-url> [--claims] [--messages] [--state] [--touched]
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: agentctl bus <repo-url> [--claims] [--messages] [--state]")
+			fmt.Println("Usage: agentctl bus <repo-url> [--claims] [--messages] [--state] [--touched]")
+			fmt.Println("       agentctl bus serve [--addr :8088]")
 			os.Exit(1)
 		}
+		if os.Args[2] == "serve" {
+			busServeCommand(os.Args[3:])
+			return
+		}
 		repoURL := os.Args[2]
 
 		// Parse flags
 		showClaims := false
 		showMessages := false
 		showState := false
+		showTouched := false
 		for _, arg := range os.Args[3:] {
 			switch arg {
 			case "--claims":
@@ -361,19 +1280,22 @@ func main() {
 				showMessages = true
 			case "--state":
 				showState = true
+			case "--touched":
+				showTouched = true
 			}
 		}
 		// If no specific flags, show everything
-		if !showClaims && !showMessages && !showState {
+		if !showClaims && !showMessages && !showState && !showTouched {
 			showClaims = true
 			showMessages = true
 			showState = true
+			showTouched = true
 		}
 
 		// Initialize coordination dir
 		if _, err := coordination.Init(repoURL); err != nil {
 			fmt.Fprintf(os.Stderr, "Error initializing coordination: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 		if showClaims {
@@ -431,19 +1353,61 @@ func main() {
 			} else if len(state.Agents) == 0 {
 				fmt.Println("  (no agents registered)")
 			} else {
+				timeout := coordination.HeartbeatTimeout()
 				for _, agent := range state.Agents {
-					fmt.Printf("  %-15s status=%-10s branch=%-20s updated=%s\n",
-						agent.Name, agent.Status, agent.Branch, agent.LastUpdate.Format(time.RFC3339))
+					beat := "-"
+					if !agent.Heartbeat.IsZero() {
+						beat = formatDuration(time.Since(agent.Heartbeat)) + " ago"
+					}
+					stale := ""
+					if agent.Stale(time.Now(), timeout) {
+						stale = "  💀 stale: treated as dead"
+					}
+					fmt.Printf("  %-15s status=%-10s branch=%-20s updated=%s heartbeat=%s%s\n",
+						agent.Name, agent.Status, agent.Branch, agent.LastUpdate.Format(time.RFC3339), beat, stale)
+				}
+			}
+		}
+
+		if showTouched {
+			fmt.Println()
+			fmt.Println("Files Touched:")
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+			touched, err := coordination.TouchedFiles(repoURL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+			} else if len(touched) == 0 {
+				fmt.Println("  (nothing reported yet)")
+			} else {
+				names := make([]string, 0, len(touched))
+				for n := range touched {
+					names = append(names, n)
+				}
+				sort.Strings(names)
+				for _, n := range names {
+					ft := touched[n]
+					fmt.Printf("  %-15s wrote %d, read %d (through attempt %d)\n", n, len(ft.Written), len(ft.Read), ft.Attempt)
+					for _, f := range ft.Written {
+						fmt.Printf("    ✏️  %s\n", f)
+					}
+				}
+				for _, o := range coordination.TouchOverlaps(touched) {
+					line := fmt.Sprintf("  ⚠️  %s written by %s", o.File, strings.Join(o.Writers, ", "))
+					if len(o.Readers) > 0 {
+						line += ", read by " + strings.Join(o.Readers, ", ")
+					}
+					fmt.Println(line)
 				}
 			}
 		}
 
 	case "prune":
 		// Remove all exited/stopped containers, preserving history
-		pruned, err := container.Prune()
+		_, repo := repoArg(os.Args[2:])
+		pruned, err := container.Prune(repo)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 		if len(pruned) == 0 {
 			fmt.Println("Nothing to prune")
@@ -453,33 +1417,205 @@ func main() {
 			}
 			fmt.Printf("Removed %d agent(s)\n", len(pruned))
 		}
+		if repo != "" {
+			sweepOrphans(repo)
+		}
+
+	case "cache":
+		// agentctl cache stats | prune [--older-than 30d] [--max-size 20G] [--dry-run]
+		if len(os.Args) < 3 || (os.Args[2] != "stats" && os.Args[2] != "prune") {
+			fmt.Println("Usage: agentctl cache stats")
+			fmt.Println("       agentctl cache prune [--older-than <age>] [--max-size <size>] [--dry-run]")
+			os.Exit(1)
+		}
+		if os.Args[2] == "stats" {
+			stats, err := container.CacheStats()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			var total int64
+			for _, st := range stats {
+				total += st.Size
+				oldest := "-"
+				if !st.Oldest.IsZero() {
+					oldest = formatDuration(time.Since(st.Oldest))
+				}
+				fmt.Printf("📦 %-10s %8s  %7d files  oldest %-5s %s\n", st.Kind, container.FormatSize(st.Size), st.Files, oldest, st.Path)
+			}
+			fmt.Printf("   %-10s %8s\n", "total", container.FormatSize(total))
+			return
+		}
+		opts := container.CachePruneOptions{}
+		for i := 3; i < len(os.Args); i++ {
+			var err error
+			switch {
+			case os.Args[i] == "--older-than" && i+1 < len(os.Args):
+				opts.OlderThan, err = container.ParseAge(os.Args[i+1])
+				i++
+			case os.Args[i] == "--max-size" && i+1 < len(os.Args):
+				opts.MaxSize, err = container.ParseSize(os.Args[i+1])
+				i++
+			case os.Args[i] == "--dry-run":
+				opts.DryRun = true
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		}
+		if opts.OlderThan == 0 && opts.MaxSize == 0 {
+			fmt.Fprintln(os.Stderr, "Error: cache prune needs --older-than and/or --max-size")
+			os.Exit(1)
+		}
+		res, err := container.PruneCache(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		verb := "Removed"
+		if opts.DryRun {
+			verb = "Would remove"
+		}
+		fmt.Printf("🧹 %s %d files (%s); cache now %s\n", verb, res.Files, container.FormatSize(res.Bytes), container.FormatSize(res.Remaining))
 
 	case "cleanup":
-		// Remove completed agents past grace period
-		gracePeriod := container.DefaultGracePeriod
-		if len(os.Args) > 2 {
-			if d, err := time.ParseDuration(os.Args[2]); err == nil {
-				gracePeriod = d
+		// Remove finished agents past their grace period, per the retention policy
+		policy, err := container.LoadRetention()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		args, repo := repoArg(os.Args[2:])
+		args, yes := yesArg(args)
+		all, result := false, ""
+		var patterns []string
+		for i := 0; i < len(args); i++ {
+			switch {
+			case args[i] == "--all":
+				all = true
+			case args[i] == "--result" && i+1 < len(args):
+				result = args[i+1]
+				i++
+			default:
+				if d, err := time.ParseDuration(args[i]); err == nil && i == 0 {
+					policy = policy.WithGrace(d)
+				} else {
+					patterns = append(patterns, args[i])
+				}
+			}
+		}
+		// Named agents, globs or --all are removed whatever their state.
+		if all || len(patterns) > 0 {
+			names := container.AgentNames()
+			if !all {
+				names = matchAgentsArg(patterns)
 			}
+			if len(names) == 0 {
+				fmt.Println("No agents")
+				return
+			}
+			if !confirmAgents("Clean up", names, yes) {
+				os.Exit(1)
+			}
+			bulkAgents(names, nameSpec, container.BulkCleanup(result), "cleaned up")
+			return
 		}
-		cleaned, err := container.CleanupCompleted(gracePeriod)
+		retired, err := container.ApplyRetention(policy, repo)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		agents, records := 0, 0
+		for _, r := range retired {
+			if r.Reason == container.HistoryPruned {
+				records++
+				continue
+			}
+			agents++
+			fmt.Printf("Cleaned: %s (%s)\n", r.Name, r.Reason)
+		}
+		if agents == 0 {
+			fmt.Println("No agents past their grace period to clean up")
+		} else {
+			fmt.Printf("Removed %d agent(s)\n", agents)
+		}
+		if records > 0 {
+			fmt.Printf("Pruned %d history record(s) older than %s\n", records, formatDuration(policy.History))
+		}
+		if repo != "" {
+			sweepOrphans(repo)
+		}
+
+	case "tutorial":
+		repo, name, restart := os.Getenv("AGENTCTL_TUTORIAL_REPO"), "tutorial", false
+		for i := 2; i < len(os.Args); i++ {
+			switch {
+			case os.Args[i] == "--repo" && i+1 < len(os.Args):
+				repo = os.Args[i+1]
+				i++
+			case os.Args[i] == "--name" && i+1 < len(os.Args):
+				name = os.Args[i+1]
+				i++
+			case os.Args[i] == "--restart":
+				restart = true
+			}
+		}
+		progress := tutorial.LoadProgress()
+		if restart {
+			tutorial.Reset()
+			progress = nil
+		}
+		if progress == nil {
+			if repo == "" {
+				fmt.Println("Usage: agentctl tutorial --repo <sandbox-url> [--name tutorial] [--restart]")
+				fmt.Println()
+				fmt.Println("The tutorial spawns a real agent and opens a real PR, so point it at a throwaway repo:")
+				fmt.Println("  gh repo create agentctl-sandbox --private --add-readme")
+				fmt.Println("  agentctl tutorial --repo https://github.com/<you>/agentctl-sandbox")
+				fmt.Println("(or set AGENTCTL_TUTORIAL_REPO)")
+				os.Exit(1)
+			}
+			progress = &tutorial.Progress{Repo: repo, Name: name}
+			fmt.Printf("🎓 agentctl tutorial: an agent named %s on %s\n", name, repo)
+			fmt.Println("   Each step is explained first; Enter runs it, s skips it, q stops (run `agentctl tutorial` again to resume).")
+		} else {
+			fmt.Printf("🎓 Resuming the tutorial on %s at step %d (--restart to start over)\n", progress.Repo, progress.Done+1)
+		}
+		r := &tutorial.Runner{In: os.Stdin, Out: os.Stdout}
+		if _, err := r.Run(tutorial.Steps(progress.Repo, progress.Name), progress); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "find":
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl find <keyword>")
+			fmt.Println("  Searches agents and history: names, intents, tasks, repos, branches, notes and metadata")
 			os.Exit(1)
 		}
-		stale, err := container.CleanupStale(gracePeriod)
+		keyword := strings.Join(os.Args[2:], " ")
+		results, err := container.Find(keyword)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(results) == 0 {
+			fmt.Printf("Nothing matches %q\n", keyword)
 			os.Exit(1)
 		}
-		total := append(cleaned, stale...)
-		if len(total) == 0 {
-			fmt.Printf("No agents older than %s to clean up\n", gracePeriod)
-		} else {
-			for _, name := range total {
-				fmt.Printf("Cleaned: %s\n", name)
+		for _, r := range results {
+			indicator, state := "🔄", "live"
+			if !r.Live {
+				indicator, state = "📜", r.Result
+			}
+			fmt.Printf("%s %-15s %-10s %-6s %s\n", indicator, r.Name, state, formatDuration(time.Since(r.When)), r.Repo)
+			if r.Intent != "" {
+				fmt.Printf("   🎯 %s\n", r.Intent)
+			}
+			if r.Match != "intent" {
+				fmt.Printf("   matched %s\n", r.Match)
 			}
-			fmt.Printf("Removed %d agent(s)\n", len(total))
 		}
 
 	case "history":
@@ -487,7 +1623,7 @@ func main() {
 		records, err := container.ListHistory()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 		if len(records) == 0 {
 			fmt.Println("No agent history")
@@ -502,17 +1638,52 @@ func main() {
 			}
 			age := formatDuration(time.Since(h.CompletedAt))
 			fmt.Printf("%s %-15s %-10s %-10s %s\n", indicator, h.Name, h.Result, age, h.Repo)
+			if h.Intent != "" {
+				fmt.Printf("   🎯 %s\n", h.Intent)
+			}
 			if h.Metadata != nil {
 				for k, v := range h.Metadata {
-					fmt.Printf("   %s: %s\n", k, v)
+					if k != "summary" {
+						fmt.Printf("   %s: %s\n", k, v)
+					}
 				}
+				if s := h.Metadata["summary"]; s != "" {
+					fmt.Printf("   📄 %s\n", strings.ReplaceAll(s, "\n", "\n      "))
+				}
+			}
+			for _, n := range h.Notes {
+				fmt.Printf("   📝 %s\n", n)
 			}
+			for _, ins := range h.Instructions {
+				fmt.Printf("   📨 %s\n", ins)
+			}
+		}
+
+	case "encrypt":
+		// Seal history and recorded sessions written in plaintext
+		records, events, err := container.EncryptStored()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("🔐 Encrypted %d history record(s) and %d session event(s)\n", records, events)
+		if cfg, err := config.Load(); err == nil && !cfg.Encryption.Enabled {
+			fmt.Println("⚠️  encryption.enabled is off in the config: new records will be written in plaintext")
 		}
 
 	case "pipeline":
+		if len(os.Args) >= 3 {
+			switch os.Args[2] {
+			case "run", "list", "history", "status", "resume":
+				pipelineCommand(os.Args[2], os.Args[3:])
+				return
+			}
+		}
 		// agentctl pipeline <repo> <issue> [--dry-run] [--from=<step>]
 		if len(os.Args) < 4 {
 			fmt.Println("Usage: agentctl pipeline <repo> <issue> [--dry-run] [--from=<step>]")
+			fmt.Println("       agentctl pipeline run <name> --repo <repo> [--<param> <value>]... [--dry-run] [--from=<step>]")
+			fmt.Println("       agentctl pipeline list | history | status [run-id] | resume <run-id> [--force]")
 			os.Exit(1)
 		}
 		repo := os.Args[2]
@@ -527,7 +1698,7 @@ func main() {
 		}
 		if err := pipeline.Run(repo, issue, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "❌ Pipeline failed: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 	case "dispatch":
@@ -566,8 +1737,58 @@ func main() {
 		}
 		if err := container.Dispatch(name, repo, issue, intent, intentFile, model, branch, image); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
+		}
+
+	case "serve":
+		// Run the daemon: agentctl serve [--addr :8090]
+		addr := ""
+		for i := 2; i < len(os.Args); i++ {
+			if os.Args[i] == "--addr" && i+1 < len(os.Args) {
+				addr = os.Args[i+1]
+				i++
+			}
 		}
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		srv := daemon.New(cfg)
+		for _, problem := range srv.Check() {
+			fmt.Fprintf(os.Stderr, "⚠️  %s\n", problem)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := srv.Run(ctx, addr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "shim":
+		// Liveness endpoint, run inside agent containers by spawn:
+		// agentctl shim [--addr :8080] [--home /home/agent]
+		addr, home := "", ""
+		for i := 2; i < len(os.Args); i++ {
+			switch {
+			case os.Args[i] == "--addr" && i+1 < len(os.Args):
+				addr = os.Args[i+1]
+				i++
+			case os.Args[i] == "--home" && i+1 < len(os.Args):
+				home = os.Args[i+1]
+				i++
+			}
+		}
+		if home == "" {
+			home, _ = os.UserHomeDir()
+		}
+		if err := container.ServeShim(addr, home); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "schedule":
+		scheduleCommand(os.Args[2:])
 
 	case "review":
 		// agentctl review <name>
@@ -578,7 +1799,7 @@ func main() {
 		result, err := review.Review(os.Args[2])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 		if result.Approved {
 			fmt.Println("✅ APPROVED — merging is safe")
@@ -593,6 +1814,43 @@ func main() {
 		}
 		os.Exit(1)
 
+	case "feedback":
+		// agentctl feedback <name> [--pr N] [--attempts N] [--image I]
+		if len(os.Args) < 3 {
+			fmt.Println("Usage: agentctl feedback <name> [--pr <number>] [--attempts N] [--image <image>]")
+			os.Exit(1)
+		}
+		name := os.Args[2]
+		opts := review.FeedbackOptions{MaxAttempts: 10}
+		for i := 3; i < len(os.Args); i++ {
+			if i+1 >= len(os.Args) {
+				break
+			}
+			switch os.Args[i] {
+			case "--pr":
+				opts.PR, _ = strconv.Atoi(strings.TrimPrefix(os.Args[i+1], "#"))
+				i++
+			case "--attempts":
+				if n, err := strconv.Atoi(os.Args[i+1]); err == nil {
+					opts.MaxAttempts = n
+				}
+				i++
+			case "--image":
+				opts.Image = os.Args[i+1]
+				i++
+			}
+		}
+		result, err := review.Feedback(name, opts)
+		if errors.Is(err, review.ErrNoFeedback) {
+			fmt.Println("✅ No unresolved review comments")
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("✅ Review feedback addressed and pushed in %d attempts\n", result.Attempts)
+
 	default:
 		printUsage()
 	}
@@ -612,6 +1870,8 @@ func watchAgent(name string) {
 			testIcon = "✅"
 		case "fail":
 			testIcon = "❌"
+		case "flaky":
+			testIcon = "🎲"
 		}
 
 		uncommittedIcon := "✅"
@@ -626,9 +1886,16 @@ func watchAgent(name string) {
 
 		fmt.Printf("\n  Tests:        %s %s\n", testIcon, status.TestStatus)
 		fmt.Printf("  Uncommitted:  %s %v\n", uncommittedIcon, status.HasUncommitted)
+		if status.Coverage != nil {
+			coverageIcon := "✅"
+			if status.Coverage.Low {
+				coverageIcon = "⚠️ "
+			}
+			fmt.Printf("  Coverage:     %s %s\n", coverageIcon, status.Coverage)
+		}
 		fmt.Printf("  Agent:        %s running=%v\n\n", agentIcon, status.ClaudeRunning)
 
-		if status.TestStatus == "pass" && !status.HasUncommitted {
+		if status.Done() {
 			fmt.Println("  ✅ Task complete!")
 		} else {
 			fmt.Println("  ⏳ Working...")
@@ -638,12 +1905,1512 @@ func watchAgent(name string) {
 	}
 }
 
-func formatDuration(d time.Duration) string {
-	if d < time.Minute {
-		return fmt.Sprintf("%ds", int(d.Seconds()))
+// planCommand partitions sub-tasks into parallel batches around the repo's
+// file claims. Tasks are a JSON array of {"name", "targets"} from a file or
+// stdin ("-").
+// analyticsCommand summarizes recorded spy events: which tools agents call
+// and how often, and what they spend in tokens.
+func analyticsCommand(args []string) {
+	usage := "Usage: agentctl analytics [--agent <glob>] [--since <dur>] [--tool <name>] [--file <recording>]... [--json]"
+	var filter container.AnalyticsFilter
+	var files []string
+	asJSON := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--agent", "--since", "--tool", "--file":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				os.Exit(1)
+			}
+			i++
+			switch args[i-1] {
+			case "--agent":
+				filter.Agent = args[i]
+			case "--tool":
+				filter.Tool = args[i]
+			case "--file":
+				files = append(files, args[i])
+			default:
+				d, err := container.ParseAge(args[i])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				filter.Since = time.Now().Add(-d)
+			}
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
 	}
-	if d < time.Hour {
-		return fmt.Sprintf("%dm", int(d.Minutes()))
+
+	records, err := container.LoadSpyRecords(files...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	a := container.Analyze(records, filter)
+	if asJSON {
+		out, _ := json.MarshalIndent(a, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if a.Events == 0 {
+		fmt.Println("No recorded events (record with `agentctl spy <name> --jsonl-out <file>` or spy.record in the config)")
+		return
+	}
+	fmt.Printf("📊 %d events from %d agent(s), %s → %s\n", a.Events, len(a.Agents),
+		a.From.Local().Format("2006-01-02 15:04"), a.To.Local().Format("2006-01-02 15:04"))
+	fmt.Printf("\n🔧 %d tool calls\n", a.ToolCalls)
+	for _, t := range a.Tools {
+		fmt.Printf("   %-14s %6d  %5.1f%%  %d agent(s)\n", t.Tool, t.Calls, 100*t.Share, t.Agents)
+	}
+	fmt.Printf("\n🪙 Tokens: %d in, %d out, %d cache read, %d cache write\n",
+		a.InputTokens, a.OutputTokens, a.CacheReadTokens, a.CacheWriteTokens)
+	fmt.Println("\n🤖 By agent")
+	for _, s := range a.Agents {
+		fmt.Printf("   %-20s %6d tool calls  %5d messages  %d tokens\n", s.Agent, s.ToolCalls, s.Messages, s.Tokens)
+	}
+}
+
+// statsCommand aggregates history into success rates, attempts, durations
+// and costs per repo, template or backend.
+func statsCommand(args []string) {
+	usage := "Usage: agentctl stats [--repo <url>] [--group-by repo|template|backend] [--since <dur>] [--json]"
+	var filter container.StatsFilter
+	asJSON := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--json":
+			asJSON = true
+		case args[i] == "--repo" && i+1 < len(args):
+			filter.Repo = args[i+1]
+			i++
+		case args[i] == "--group-by" && i+1 < len(args):
+			filter.GroupBy = args[i+1]
+			i++
+		case args[i] == "--since" && i+1 < len(args):
+			d, err := container.ParseAge(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			filter.Since = time.Now().Add(-d)
+			i++
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+
+	records, err := container.FindHistory(container.HistoryQuery{Repo: filter.Repo, Since: filter.Since})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	groups, err := container.Stats(records, filter, cfg.Stats.Prices)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if asJSON {
+		out, _ := json.MarshalIndent(groups, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if len(groups) == 0 {
+		fmt.Println("No runs in history")
+		return
+	}
+	if filter.GroupBy == "" {
+		filter.GroupBy = container.GroupByRepo
+	}
+	fmt.Printf("%-40s %5s %8s %8s %9s %10s %9s\n", strings.ToUpper(filter.GroupBy),
+		"RUNS", "SUCCESS", "ATTEMPTS", "DURATION", "TOKENS", "COST")
+	for _, g := range groups {
+		success, attempts, duration, cost := "-", "-", "-", "-"
+		if g.Succeeded+g.Failed > 0 {
+			success = fmt.Sprintf("%.0f%%", 100*g.SuccessRate)
+		}
+		if g.MeanAttempts > 0 {
+			attempts = fmt.Sprintf("%.1f", g.MeanAttempts)
+		}
+		if g.MeanDuration > 0 {
+			duration = formatDuration(time.Duration(g.MeanDuration * float64(time.Second)))
+		}
+		if g.PricedRuns > 0 {
+			cost = fmt.Sprintf("$%.2f", g.Cost)
+		}
+		key := g.Key
+		if len(key) > 40 {
+			key = "…" + key[len(key)-39:]
+		}
+		fmt.Printf("%-40s %5d %8s %8s %9s %10d %9s\n", key, g.Runs, success, attempts, duration, g.Tokens, cost)
+	}
+}
+
+func planCommand(args []string) {
+	usage := "Usage: agentctl plan <repo-url> <tasks.json|-> [--agent <name>] [--json]"
+	var positional []string
+	owner, asJSON := "", false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--agent" && i+1 < len(args):
+			owner = args[i+1]
+			i++
+		case args[i] == "--json":
+			asJSON = true
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) != 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	repoURL, source := positional[0], positional[1]
+
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	var tasks []coordination.SubTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid tasks: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if _, err := coordination.Init(repoURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing coordination: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	plan, err := coordination.PlanPartition(repoURL, owner, tasks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if asJSON {
+		out, _ := json.MarshalIndent(plan, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	for i, batch := range plan.Batches {
+		fmt.Printf("📦 Batch %d (parallel)\n", i+1)
+		for _, t := range batch {
+			fmt.Printf("   %s  %s\n", t.Name, strings.Join(t.Targets, ", "))
+		}
+	}
+	for _, t := range tasks {
+		if held, ok := plan.Blocked[t.Name]; ok {
+			fmt.Printf("🔒 %s blocked by %s\n", t.Name, strings.Join(held, ", "))
+		}
+	}
+}
+
+// capacityCommand shows the host's load against the capacity limits:
+// agentctl capacity [--json]. It exits 10 when a spawn would be refused.
+func capacityCommand(args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	u := container.ReadHostUsage()
+	check := u.Check(cfg.Capacity)
+	if len(args) > 0 && args[0] == "--json" {
+		full := ""
+		if check != nil {
+			full = check.Error()
+		}
+		out, _ := json.MarshalIndent(struct {
+			container.HostUsage
+			Limits config.Capacity `json:"limits"`
+			Full   string          `json:"full,omitempty"`
+		}{u, cfg.Capacity, full}, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		measure := func(v float64, limit float64, unit string) string {
+			s := "unknown"
+			if v >= 0 {
+				s = fmt.Sprintf("%.0f%s", v, unit)
+			}
+			if limit > 0 {
+				s += fmt.Sprintf(" (max %.0f%s)", limit, unit)
+			}
+			return s
+		}
+		fmt.Printf("CPU:     %s\n", measure(u.CPU, cfg.Capacity.MaxCPU, "%"))
+		fmt.Printf("Memory:  %s\n", measure(u.Memory, cfg.Capacity.MaxMemory, "%"))
+		disk := "unknown"
+		if u.DiskFree >= 0 {
+			disk = container.FormatSize(u.DiskFree) + " free"
+		}
+		if cfg.Capacity.MinFreeDisk != "" {
+			disk += " (min " + cfg.Capacity.MinFreeDisk + ")"
+		}
+		fmt.Printf("Disk:    %s\n", disk)
+		fmt.Printf("Agents:  %s\n", measure(float64(u.Agents), float64(cfg.Capacity.MaxAgents), ""))
+		if check != nil {
+			fmt.Printf("🛑 %v\n", check)
+		} else {
+			fmt.Println("✅ Room to spawn")
+		}
+	}
+	if check != nil {
+		os.Exit(exitCode(check))
+	}
+}
+
+// printMessageTypes lists the bus's message types and their fields.
+func printMessageTypes() {
+	schemas := coordination.MessageSchemas()
+	var types []string
+	for t := range schemas {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		s := schemas[coordination.MessageType(t)]
+		var fields []string
+		for _, f := range s.Required {
+			fields = append(fields, f+"*")
+		}
+		fields = append(fields, s.Optional...)
+		if s.Open {
+			fields = append(fields, "...")
+		}
+		fmt.Printf("%-18s %-40s %s\n", t, strings.Join(fields, " "), s.Description)
+	}
+	fmt.Println("(* required, ... any other field)")
+}
+
+// claimsCommand lets scripts and hooks outside agentctl honour claims:
+// agentctl claims check <repo-url> <file>... [--json] [--acquire [--as <name>]
+// [--wait] [--timeout <dur>]]. It exits 5 when a file is claimed.
+func claimsCommand(args []string) {
+	usage := "Usage: agentctl claims check <repo-url> <file>... [--json] [--acquire [--as <name>] [--wait] [--timeout <dur>]]"
+	var positional []string
+	asJSON, acquire, wait := false, false, false
+	as, timeout := os.Getenv("USER"), 10*time.Minute
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--json":
+			asJSON = true
+		case args[i] == "--acquire":
+			acquire = true
+		case args[i] == "--wait":
+			wait = true
+		case args[i] == "--as" && i+1 < len(args):
+			as = args[i+1]
+			i++
+		case args[i] == "--timeout" && i+1 < len(args):
+			d, err := time.ParseDuration(args[i+1])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --timeout: %v\n", err)
+				os.Exit(1)
+			}
+			timeout = d
+			i++
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) < 3 || positional[0] != "check" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	repoURL, files := positional[1], positional[2:]
+	if as == "" {
+		as = "external"
+	}
+	if _, err := coordination.Init(repoURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing coordination: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	var acquireErr error
+	if acquire {
+		if !wait {
+			timeout = 0
+		}
+		acquireErr = coordination.AcquireClaims(repoURL, as, files, timeout)
+		if acquireErr != nil && !errors.Is(acquireErr, coordination.ErrClaimConflict) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", acquireErr)
+			os.Exit(exitCode(acquireErr))
+		}
+	}
+	statuses, err := coordination.CheckClaims(repoURL, files)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if asJSON {
+		out, _ := json.MarshalIndent(statuses, "", "  ")
+		fmt.Println(string(out))
+	}
+	blocked := false
+	for _, st := range statuses {
+		held := st.Claimed && !(acquire && st.Agent == as)
+		blocked = blocked || held
+		if asJSON {
+			continue
+		}
+		switch {
+		case held:
+			fmt.Printf("🔒 %s claimed by %s since %s\n", st.File, st.Agent, st.ClaimedAt.Format(time.RFC3339))
+		case st.Claimed:
+			fmt.Printf("✅ %s claimed for %s\n", st.File, st.Agent)
+		default:
+			fmt.Printf("✅ %s is free\n", st.File)
+		}
+	}
+	if blocked {
+		if acquireErr != nil && !asJSON {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", acquireErr)
+		}
+		os.Exit(exitClaimConflict)
+	}
+}
+
+// bulkRequested reports whether a spawn, run or kill reads its agents from
+// stdin.
+func bulkRequested(args []string) bool {
+	for _, a := range args {
+		if a == "--stdin" {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkCommand applies spawn, run or kill to a JSON array of agent specs on
+// stdin, printing one JSON result per line as items finish. Progress goes
+// to stderr so stdout stays machine-readable.
+func bulkCommand(op string, args []string) {
+	usage := fmt.Sprintf("Usage: agentctl %s --stdin [--concurrency N] < agents.json", op)
+	concurrency := container.DefaultBulkConcurrency
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--stdin":
+		case args[i] == "--concurrency" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: --concurrency needs a positive number\n")
+				os.Exit(1)
+			}
+			concurrency = n
+			i++
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+	specs, err := container.ReadBulkSpecs(os.Stdin, op)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	bulkOp, err := container.BulkOpFor(op)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	results := json.NewEncoder(os.Stdout)
+	os.Stdout = os.Stderr
+	failed := 0
+	container.Bulk(specs, concurrency, bulkOp, func(r container.BulkResult) {
+		if !r.OK {
+			failed++
+		}
+		results.Encode(r)
+	})
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// benchCommand runs one task across variants and compares the outcomes.
+func benchCommand(args []string) {
+	usage := "Usage: agentctl bench <bench.json> [--keep] [--json]"
+	var positional []string
+	keep, asJSON := false, false
+	for _, a := range args {
+		switch a {
+		case "--keep":
+			keep = true
+		case "--json":
+			asJSON = true
+		default:
+			positional = append(positional, a)
+		}
+	}
+	if len(positional) != 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	spec, err := container.LoadBenchSpec(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("🏁 Benchmarking %d variant(s) x %d run(s), %d at a time\n", len(spec.Variants), spec.Runs, spec.Parallel)
+	results := container.Bench(spec, keep)
+
+	if asJSON {
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Println()
+	fmt.Print(container.FormatBench(results))
+	for _, r := range results {
+		if r.Error != "" && !r.Completed {
+			fmt.Printf("⚠️  %s run %d: %s\n", r.Variant, r.Run, r.Error)
+		}
+	}
+}
+
+// scheduleCommand lists configured schedules or runs one now.
+func scheduleCommand(args []string) {
+	usage := "Usage: agentctl schedule list | run <id>"
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		if len(cfg.Schedules) == 0 {
+			fmt.Println("No schedules (add \"schedules\" to ~/.agentctl/config.json)")
+			return
+		}
+		now := time.Now()
+		for _, sc := range cfg.Schedules {
+			what := "task"
+			if sc.Pipeline != "" {
+				what = "pipeline " + sc.Pipeline
+			}
+			fmt.Printf("⏰ %s  [%s]  %s, %d repo(s)\n", sc.ID, sc.Cron, what, len(sc.Repos))
+			if c, err := daemon.ParseCron(sc.Cron); err != nil {
+				fmt.Printf("   ⚠️  %v\n", err)
+			} else {
+				fmt.Printf("   Next: %s\n", c.Next(now).Format("Mon Jan 2 15:04"))
+			}
+			if last, ok := daemon.LastScheduleRun(sc.ID); ok {
+				icon := "✅"
+				switch last.Result {
+				case "running":
+					icon = "🔄"
+				case "failed":
+					icon = "❌"
+				}
+				fmt.Printf("   Last: %s %s %s (%s)\n", icon, last.Result, last.Repo, last.Started.Format("Jan 2 15:04"))
+			}
+		}
+	case "run":
+		if len(args) < 2 {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		sc, ok := daemon.FindSchedule(cfg, args[1])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no schedule named %q\n", args[1])
+			os.Exit(1)
+		}
+		if err := daemon.RunSchedule(sc); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+	default:
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+}
+
+// squashCommand rewrites an agent's (or a checkout's) commits per the commit
+// policy: agentctl squash <name> | --dir <path> [--repo <url>] [--task <t>]
+// [--policy keep|squash-fixups|squash-all] [--dry-run] [--push]
+func squashCommand(args []string) {
+	usage := "Usage: agentctl squash <name> | --dir <path> [--repo <url>] [--task <text>] [--policy keep|squash-fixups|squash-all] [--dry-run] [--push]"
+	var name, dir, repo string
+	opts := container.SquashOptions{}
+	push := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--dry-run":
+			opts.DryRun = true
+		case arg == "--push":
+			push = true
+		case strings.HasPrefix(arg, "--") && i+1 < len(args):
+			switch arg {
+			case "--dir":
+				dir = args[i+1]
+			case "--repo":
+				repo = args[i+1]
+			case "--task":
+				opts.Task = args[i+1]
+			case "--policy":
+				opts.Policy = args[i+1]
+			default:
+				fmt.Println(usage)
+				os.Exit(1)
+			}
+			i++
+		case !strings.HasPrefix(arg, "--") && name == "":
+			name = arg
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+	if (name == "") == (dir == "") || (push && name == "") {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	if name != "" && repo == "" {
+		if agent, err := container.LoadAgent(name); err == nil {
+			repo = agent.Repo
+		}
+	}
+	if opts.Policy == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		opts.Policy = cfg.Commits.PolicyFor(repo)
+	}
+
+	var res *container.SquashResult
+	var err error
+	if name != "" {
+		res, err = container.Squash(name, opts)
+	} else {
+		res, err = container.SquashDir(dir, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if opts.Policy == container.CommitsKeep {
+		fmt.Println("Commit policy is keep; nothing to do")
+		return
+	}
+	if !res.Changed() {
+		fmt.Printf("✅ %d commit(s) already tidy (%s)\n", len(res.Before), opts.Policy)
+	} else {
+		verb := "Squashed"
+		if opts.DryRun {
+			verb = "Would squash"
+		}
+		fmt.Printf("🧹 %s %d commit(s) into %d (%s):\n", verb, len(res.Before), len(res.After), opts.Policy)
+		for _, s := range res.After {
+			fmt.Printf("   %s\n", s)
+		}
+		if !opts.DryRun {
+			fmt.Println("   (previous head saved as refs/agentctl/pre-squash)")
+		}
+	}
+	if push && !opts.DryRun {
+		if err := container.ForcePush(name); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("⬆️  Pushed %s\n", name)
+	}
+}
+
+// boardCommand renders the fleet progress board and optionally publishes it,
+// once or every --every interval.
+func boardCommand(args []string) {
+	usage := "Usage: agentctl board [--format md|html|slack] [--out <file>] [--repo <url>] [--publish] [--gist <id>] [--wiki <owner/repo[:Page]>] [--slack <webhook-url>] [--every <duration>]"
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	format, out, repo := board.FormatMarkdown, "", ""
+	var gist, wiki, slack string
+	var every time.Duration
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if flag == "--publish" {
+			gist, wiki, slack = cfg.Board.Gist, cfg.Board.Wiki, cfg.Board.SlackWebhook
+			continue
+		}
+		if i+1 >= len(args) {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		val := args[i+1]
+		i++
+		switch flag {
+		case "--format":
+			format = val
+		case "--out":
+			out = val
+		case "--repo":
+			repo = val
+		case "--gist":
+			gist = val
+		case "--wiki":
+			wiki = val
+		case "--slack":
+			slack = val
+		case "--every":
+			if every, err = time.ParseDuration(val); err != nil || every <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid --every %q\n", val)
+				os.Exit(1)
+			}
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+
+	publish := func() error {
+		b, err := board.Collect(repo)
+		if err != nil {
+			return err
+		}
+		rendered, err := board.Render(b, format)
+		if err != nil {
+			return err
+		}
+		switch {
+		case out != "":
+			if err := os.WriteFile(out, []byte(rendered), 0644); err != nil {
+				return err
+			}
+			fmt.Printf("📋 Board written to %s\n", out)
+		case gist == "" && wiki == "" && slack == "":
+			fmt.Print(rendered)
+		}
+		if gist != "" {
+			if err := board.PublishGist(gist, board.Markdown(b)); err != nil {
+				return err
+			}
+			fmt.Printf("📤 Gist %s updated\n", gist)
+		}
+		if wiki != "" {
+			if err := board.PublishWiki(wiki, board.Markdown(b)); err != nil {
+				return err
+			}
+			fmt.Printf("📤 Wiki %s updated\n", wiki)
+		}
+		if slack != "" {
+			if err := board.PublishSlack(slack, board.Slack(b)); err != nil {
+				return err
+			}
+			fmt.Println("📤 Posted to Slack")
+		}
+		return nil
+	}
+
+	if every == 0 {
+		if err := publish(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+	for {
+		if err := publish(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+		time.Sleep(every)
+	}
+}
+
+// pipelineCommand handles the named-pipeline subcommands: run, list, history,
+// status and resume.
+func pipelineCommand(sub string, args []string) {
+	switch sub {
+	case "run":
+		if len(args) < 1 || strings.HasPrefix(args[0], "--") {
+			fmt.Println("Usage: agentctl pipeline run <name> --repo <repo> [--<param> <value>]... [--dry-run] [--from=<step>]")
+			os.Exit(1)
+		}
+		name := args[0]
+		opts := pipeline.Options{}
+		params := map[string]string{}
+		for i := 1; i < len(args); i++ {
+			arg := args[i]
+			switch {
+			case arg == "--dry-run":
+				opts.DryRun = true
+			case strings.HasPrefix(arg, "--from="):
+				opts.FromStep = strings.TrimPrefix(arg, "--from=")
+			case strings.HasPrefix(arg, "--") && strings.Contains(arg, "="):
+				kv := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+				params[kv[0]] = kv[1]
+			case strings.HasPrefix(arg, "--") && i+1 < len(args):
+				params[strings.TrimPrefix(arg, "--")] = args[i+1]
+				i++
+			}
+		}
+		if err := pipeline.RunNamed(name, params, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Pipeline failed: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "list":
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		names := pipeline.Names(cfg)
+		if len(names) == 0 {
+			fmt.Printf("No named pipelines (define them under \"pipelines\" in %s)\n", config.Path())
+			return
+		}
+		for _, name := range names {
+			def := cfg.Pipelines[name]
+			fmt.Printf("%-15s %s\n", name, strings.Join(def.Steps, " → "))
+			for k, v := range def.Params {
+				if v == "" {
+					v = "(required)"
+				}
+				fmt.Printf("   --%s %s\n", k, v)
+			}
+		}
+
+	case "history":
+		runs, err := pipeline.ListRuns()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(runs) == 0 {
+			fmt.Println("No pipeline history")
+			return
+		}
+		for _, r := range runs {
+			indicator := "✅"
+			if r.Status == "failed" {
+				indicator = "❌"
+			} else if r.Status == "running" {
+				indicator = "🔄"
+			}
+			age := formatDuration(time.Since(r.StartedAt))
+			fmt.Printf("%s %-30s %-8s %-6s %s\n", indicator, r.ID, r.Status, age, r.Repo)
+			for _, step := range r.Steps {
+				line := fmt.Sprintf("   %s %s", pipelineStepIcons[step.Status], step.Name)
+				if !step.FinishedAt.IsZero() && !step.StartedAt.IsZero() {
+					line += fmt.Sprintf(" (%s)", formatDuration(step.FinishedAt.Sub(step.StartedAt)))
+				}
+				if step.Attempts > 1 {
+					line += fmt.Sprintf(" [%d attempts]", step.Attempts)
+				}
+				if step.Error != "" {
+					line += ": " + step.Error
+				}
+				fmt.Println(line)
+			}
+		}
+
+	case "resume":
+		if len(args) < 1 {
+			fmt.Println("Usage: agentctl pipeline resume <run-id> [--force]")
+			fmt.Println("  Re-runs the failed and unfinished steps of a recorded run (see pipeline history)")
+			os.Exit(1)
+		}
+		force := len(args) > 1 && args[1] == "--force"
+		if err := pipeline.Resume(args[0], force); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Pipeline failed: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+
+	case "status":
+		// Show the execution graph of a run (default: the most recent).
+		var r *pipeline.RunRecord
+		if len(args) > 0 {
+			rec, err := pipeline.LoadRun(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			r = rec
+		} else {
+			runs, err := pipeline.ListRuns()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			if len(runs) == 0 {
+				fmt.Println("No pipeline history")
+				return
+			}
+			r = runs[len(runs)-1]
+		}
+		fmt.Printf("📋 %s  %s  %s\n", r.ID, r.Status, r.Repo)
+		for i, stage := range r.Stages() {
+			if i > 0 {
+				fmt.Println("   │")
+			}
+			var cells []string
+			for _, step := range stage {
+				cell := pipelineStepIcons[step.Status] + " " + step.Name
+				if len(step.Needs) > 0 {
+					cell += " ← " + strings.Join(step.Needs, ", ")
+				}
+				cells = append(cells, cell)
+			}
+			fmt.Printf("   %s\n", strings.Join(cells, "   "))
+		}
+	}
+}
+
+var pipelineStepIcons = map[string]string{
+	pipeline.StepOK:      "✅",
+	pipeline.StepFailed:  "❌",
+	pipeline.StepRunning: "🔄",
+	pipeline.StepSkipped: "⏭️",
+	pipeline.StepPending: "⏸️",
+}
+
+// spawnAgent creates an agent and prints its details, exiting on failure.
+func spawnAgent(opts container.SpawnOptions) {
+	agent, err := container.SpawnWithOptions(opts)
+	var setupErr *container.SetupError
+	if errors.As(err, &setupErr) {
+		fmt.Fprintf(os.Stderr, "❌ Agent %s spawned but repo setup failed: %v\n", agent.Name, err)
+		fmt.Fprintf(os.Stderr, "   Inspect with: agentctl shell %s  (full output in %s)\n", agent.Name, setupErr.Log)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	img := agent.Image
+	fmt.Printf("🤖 Agent: %s\n📦 Container: %s\n🖼️  Image: %s\n🌐 Port: %d\n", agent.Name, agent.ContainerID[:12], img, agent.Port)
+}
+
+// extractNamespace removes the global --namespace flag (accepted anywhere on
+// the command line) from args and returns its value, falling back to
+// $AGENTCTL_NAMESPACE.
+func extractNamespace(args []string) ([]string, string, error) {
+	var rest []string
+	ns := os.Getenv(namespace.EnvVar)
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--namespace":
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("--namespace needs a value")
+			}
+			ns = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--namespace="):
+			ns = strings.TrimPrefix(arg, "--namespace=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, ns, nil
+}
+
+// truncateIntent shortens an intent for one-line listings.
+func truncateIntent(intent string) string {
+	if r := []rune(intent); len(r) > 60 {
+		return string(r[:57]) + "..."
+	}
+	return intent
+}
+
+// repoArg takes a "--repo <url>" option out of args.
+func repoArg(args []string) ([]string, string) {
+	var rest []string
+	repo := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--repo" && i+1 < len(args) {
+			repo = args[i+1]
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	return rest, repo
+}
+
+// forceArg removes --force from args, reporting whether it was there.
+func forceArg(args []string) ([]string, bool) {
+	return flagArg(args, "--force")
+}
+
+// flagArg removes a boolean flag from args, reporting whether it was there.
+func flagArg(args []string, flag string) ([]string, bool) {
+	var rest []string
+	found := false
+	for _, a := range args {
+		if a == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest, found
+}
+
+// outputPatchArgs strips run's patch-only flags from args: --output-patch,
+// and --apply-to <dir>, which implies it.
+func outputPatchArgs(args []string) ([]string, bool, string) {
+	var rest []string
+	outputPatch, applyTo := false, ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--output-patch":
+			outputPatch = true
+		case args[i] == "--apply-to" && i+1 < len(args):
+			outputPatch, applyTo = true, args[i+1]
+			i++
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, outputPatch, applyTo
+}
+
+// doneWhenArg strips --done-when <cmd> (or --done-when=<cmd>) from args and
+// returns the command.
+func doneWhenArg(args []string) ([]string, string) {
+	var rest []string
+	var cmd string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--done-when" && i+1 < len(args):
+			cmd = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--done-when="):
+			cmd = strings.TrimPrefix(args[i], "--done-when=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, cmd
+}
+
+// applyPatchFor applies a patch-only run's patch to dir, if both are set.
+func applyPatchFor(result *container.TaskResult, dir string) {
+	if dir == "" || result == nil || result.Patch == "" {
+		return
+	}
+	if err := container.ApplyPatchToCheckout(dir, result.Patch); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🩹 Applied %s to %s for review (uncommitted)\n", result.Patch, dir)
+}
+
+// yesArg strips --yes (or -y) from args and reports whether it was given.
+func yesArg(args []string) ([]string, bool) {
+	var rest []string
+	yes := false
+	for _, a := range args {
+		if a == "--yes" || a == "-y" {
+			yes = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest, yes
+}
+
+// confirmAgents lists the agents a bulk command is about to act on and asks
+// before going ahead, unless yes is set.
+func confirmAgents(verb string, names []string, yes bool) bool {
+	if yes {
+		return true
+	}
+	fmt.Printf("%s %d agent(s): %s\n", verb, len(names), strings.Join(names, ", "))
+	fmt.Print("Continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	fmt.Println("Aborted")
+	return false
+}
+
+// matchAgentsArg resolves names and globs to agents, exiting on a bad or
+// unmatched one.
+func matchAgentsArg(patterns []string) []string {
+	names, err := container.MatchAgents(patterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	return names
+}
+
+// triageCommand estimates a repo's agent-ready issues and puts an agent on
+// each easy one.
+func triageCommand(args []string) {
+	usage := "Usage: agentctl triage <repo> [--label <label>] [--max-difficulty 1-5] [--limit N] [--attempts N] [--dry-run] [--json]"
+	var repo string
+	var opts container.TriageOptions
+	attempts, dryRun, asJSON := 10, false, false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--dry-run":
+			dryRun = true
+		case args[i] == "--json":
+			asJSON = true
+		case args[i] == "--label" && i+1 < len(args):
+			opts.Label = args[i+1]
+			i++
+		case (args[i] == "--max-difficulty" || args[i] == "--limit" || args[i] == "--attempts") && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: %s needs a positive number\n", args[i])
+				os.Exit(1)
+			}
+			switch args[i] {
+			case "--max-difficulty":
+				opts.MaxDifficulty = n
+			case "--limit":
+				opts.Limit = n
+			default:
+				attempts = n
+			}
+			i++
+		case !strings.HasPrefix(args[i], "--") && repo == "":
+			repo = args[i]
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+	if repo == "" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	candidates, err := container.Triage(repo, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if asJSON {
+		out, _ := json.MarshalIndent(candidates, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		if len(candidates) == 0 {
+			fmt.Println("No open issues to triage")
+		}
+		for _, c := range candidates {
+			if c.Agent != "" {
+				fmt.Printf("🟢 #%-5d %s\n   difficulty %d: %s → %s\n", c.Number, c.Title, c.Estimate.Difficulty, c.Estimate.Reason, c.Agent)
+				if len(c.Estimate.Files) > 0 {
+					fmt.Printf("   files: %s\n", strings.Join(c.Estimate.Files, ", "))
+				}
+			} else {
+				fmt.Printf("⏭️  #%-5d %s (%s)\n", c.Number, c.Title, c.Skip)
+			}
+		}
+	}
+
+	specs := container.TriageSpecs(repo, candidates, attempts)
+	if dryRun || len(specs) == 0 {
+		return
+	}
+	byName := map[string]container.BulkSpec{}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		byName[s.Name], names[i] = s, s.Name
+	}
+	fmt.Printf("🚀 Spawning %d agent(s)...\n", len(specs))
+	bulkAgents(names, func(n string) container.BulkSpec { return byName[n] }, container.SpawnAndRun, "done")
+}
+
+func releaseNotesCommand(args []string) {
+	usage := "Usage: agentctl release-notes <repo> [--since <tag>] [--file <changelog>] [--name <agent>] [--image <img>] [--attempts N] [--draft]"
+	var repo string
+	var opts container.ReleaseNotesOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--draft":
+			opts.Draft = true
+		case args[i] == "--attempts" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: %s needs a positive number\n", args[i])
+				os.Exit(1)
+			}
+			opts.MaxAttempts = n
+			i++
+		case (args[i] == "--since" || args[i] == "--file" || args[i] == "--name" || args[i] == "--image") && i+1 < len(args):
+			switch args[i] {
+			case "--since":
+				opts.Since = args[i+1]
+			case "--file":
+				opts.File = args[i+1]
+			case "--name":
+				opts.Name = args[i+1]
+			default:
+				opts.Image = args[i+1]
+			}
+			i++
+		case !strings.HasPrefix(args[i], "--") && repo == "":
+			repo = args[i]
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+	if repo == "" {
+		fmt.Println(usage)
+		fmt.Println("  Spawns an agent that writes a changelog entry for the PRs merged since the last tag, and opens a PR for it")
+		os.Exit(1)
+	}
+	pr, err := container.ReleaseNotes(repo, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("🔀 Opened #%d: %s\n", pr.Number, pr.URL)
+}
+
+// conflictsCommand predicts the files a task will touch and warns about
+// agents already holding or working on them:
+// agentctl conflicts <repo> "<task>" [--json].
+func conflictsCommand(args []string) {
+	usage := "Usage: agentctl conflicts <repo> \"<task>\" [--json]"
+	var positional []string
+	asJSON := false
+	for _, a := range args {
+		switch {
+		case a == "--json":
+			asJSON = true
+		case !strings.HasPrefix(a, "--"):
+			positional = append(positional, a)
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+	if len(positional) != 2 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+
+	p, err := container.PredictConflicts(positional[0], positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if asJSON {
+		out, _ := json.MarshalIndent(p, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if len(p.Files) == 0 {
+		fmt.Println("🔮 No files predicted")
+		return
+	}
+	fmt.Printf("🔮 Predicted files: %s\n", strings.Join(p.Files, ", "))
+	if len(p.Risks) == 0 {
+		fmt.Println("✅ No predicted conflicts")
+		return
+	}
+	for _, r := range p.Risks {
+		if r.Claimed != "" {
+			fmt.Printf("⚠️  %s: %s is claimed by %s\n", r.File, r.Claimed, r.Agent)
+		} else if r.Intent != "" {
+			fmt.Printf("⚠️  %s: %s is predicted to touch it (%s)\n", r.File, r.Agent, r.Intent)
+		} else {
+			fmt.Printf("⚠️  %s: %s is predicted to touch it\n", r.File, r.Agent)
+		}
+	}
+	fmt.Printf("💡 Serialize: spawn with --after %s\n", strings.Join(p.After, " --after "))
+}
+
+// workflowCommand runs a built-in workflow. The only one is deps:
+// agentctl workflow deps [<repo>...] [flags].
+func workflowCommand(args []string) {
+	usage := "Usage: agentctl workflow deps [<repo>...] [--ecosystem go|npm|composer]... [--concurrency N] [--attempts N] [--image <img>] [--draft] [--dry-run] [--json]"
+	if len(args) == 0 || args[0] != "deps" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	var repos []string
+	var opts container.DepsOptions
+	dryRun, asJSON := false, false
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "--draft":
+			opts.Draft = true
+		case args[i] == "--dry-run":
+			dryRun = true
+		case args[i] == "--json":
+			asJSON = true
+		case args[i] == "--ecosystem" && i+1 < len(args):
+			opts.Ecosystems = append(opts.Ecosystems, args[i+1])
+			i++
+		case args[i] == "--image" && i+1 < len(args):
+			opts.Image = args[i+1]
+			i++
+		case (args[i] == "--concurrency" || args[i] == "--attempts") && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: %s needs a positive number\n", args[i])
+				os.Exit(1)
+			}
+			if args[i] == "--concurrency" {
+				opts.Concurrency = n
+			} else {
+				opts.MaxAttempts = n
+			}
+			i++
+		case !strings.HasPrefix(args[i], "--"):
+			repos = append(repos, args[i])
+		default:
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+	}
+	repos = container.DepsRepos(repos)
+	if len(repos) == 0 {
+		fmt.Println(usage)
+		fmt.Println("  Name repos, or list them under workflows.deps.repos in the config")
+		os.Exit(1)
+	}
+
+	jobs, err := container.PlanDeps(repos, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if dryRun {
+		if asJSON {
+			out, _ := json.MarshalIndent(jobs, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
+		for _, j := range jobs {
+			fmt.Printf("📦 %s: %s → %s\n", j.Repo, j.Ecosystem, j.Agent)
+		}
+		return
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No go.mod, package.json or composer.json found")
+		return
+	}
+
+	results := json.NewEncoder(os.Stdout)
+	if asJSON {
+		os.Stdout = os.Stderr
+	} else {
+		fmt.Printf("📦 Upgrading %d ecosystem(s) across %d repo(s)...\n", len(jobs), len(repos))
+	}
+	failed := 0
+	container.RunDeps(jobs, opts, func(r container.BulkResult) {
+		if !r.OK {
+			failed++
+		}
+		switch {
+		case asJSON:
+			results.Encode(r)
+		case !r.OK:
+			fmt.Printf("❌ %s: %s\n", r.Name, r.Error)
+		case r.PR != "":
+			fmt.Printf("✅ %s: %s\n", r.Name, r.PR)
+		default:
+			fmt.Printf("✅ %s: %s\n", r.Name, r.Note)
+		}
+	})
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// busServeCommand serves the host's coordination buses to agents and tools
+// elsewhere: agentctl bus serve [--addr :8088]
+func busServeCommand(args []string) {
+	gw := &coordination.Gateway{}
+	addr := coordination.DefaultGatewayAddr
+	// The token comes from the config or the environment, never the
+	// command line, where ps would show it.
+	if cfg, err := config.Load(); err == nil {
+		gw.Token = cfg.Coordination.Token
+	}
+	if token := os.Getenv(coordination.BusTokenEnv); token != "" {
+		gw.Token = token
+	}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--addr" && i+1 < len(args):
+			addr = args[i+1]
+			i++
+		case args[i] == "--token":
+			fmt.Fprintf(os.Stderr, "Error: --token is not accepted; set coordination.token or %s\n", coordination.BusTokenEnv)
+			os.Exit(1)
+		default:
+			fmt.Println("Usage: agentctl bus serve [--addr :8088]")
+			os.Exit(1)
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if gw.Token == "" {
+		fmt.Println("🔓 No token set: serving this host only")
+	}
+	fmt.Printf("🚌 Coordination bus gateway listening on %s\n", addr)
+	if err := gw.ListenAndServe(ctx, addr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// dagCommand implements `agentctl dag show [--json]`.
+func dagCommand(args []string) {
+	usage := "Usage: agentctl dag show [--json]"
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	asJSON := false
+	for _, arg := range args[1:] {
+		if arg != "--json" {
+			fmt.Println(usage)
+			os.Exit(1)
+		}
+		asJSON = true
+	}
+	stages, err := container.AgentDAG()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if asJSON {
+		if stages == nil {
+			stages = [][]container.DAGNode{}
+		}
+		out, _ := json.MarshalIndent(stages, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	if len(stages) == 0 {
+		fmt.Println("No agent dependencies (spawn with --needs <agent>)")
+		return
+	}
+	fmt.Println("🕸️  Agent dependencies")
+	for i, stage := range stages {
+		if i > 0 {
+			fmt.Println("   │")
+		}
+		var cells []string
+		for _, n := range stage {
+			cell := dagStateIcons[n.State] + " " + n.Name
+			if len(n.Needs) > 0 {
+				cell += " ← " + strings.Join(n.Needs, ", ")
+			}
+			cells = append(cells, cell)
+		}
+		fmt.Printf("   %s\n", strings.Join(cells, "   "))
+	}
+}
+
+var dagStateIcons = map[string]string{
+	container.NeedCompleted: "✅",
+	container.NeedFailed:    "❌",
+	container.NeedWaiting:   "⏳",
+	container.NeedPending:   "⏸️",
+}
+
+// bulkAgents applies op to the named agents, printing a line per agent as
+// it finishes and exiting 1 if any failed.
+func bulkAgents(names []string, specs func(string) container.BulkSpec, op container.BulkOp, done string) {
+	list := make([]container.BulkSpec, len(names))
+	for i, n := range names {
+		list[i] = specs(n)
+	}
+	failed := 0
+	container.Bulk(list, container.DefaultBulkConcurrency, op, func(r container.BulkResult) {
+		if !r.OK {
+			failed++
+			fmt.Printf("❌ %s: %s\n", r.Name, r.Error)
+			return
+		}
+		if r.Attempts > 0 {
+			fmt.Printf("✅ %s: %s in %d attempts\n", r.Name, done, r.Attempts)
+		} else {
+			fmt.Printf("✅ %s: %s\n", r.Name, done)
+		}
+	})
+	fmt.Printf("%d of %d agent(s) %s\n", len(names)-failed, len(names), done)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func mustBulkOp(op string) container.BulkOp {
+	bulkOp, err := container.BulkOpFor(op)
+	if err != nil {
+		panic(err)
+	}
+	return bulkOp
+}
+
+func nameSpec(name string) container.BulkSpec {
+	return container.BulkSpec{Name: name}
+}
+
+// breakRunLock clears the agent's run lock for --force.
+func breakRunLock(name string) {
+	if l := container.BreakRunLock(name); l != nil {
+		fmt.Printf("🔓 Removed the run lock held by %s\n", l)
+	}
+}
+
+// sweepOrphans cleans a repo's bus of entries left by agents that are gone.
+func sweepOrphans(repo string) {
+	swept, err := container.SweepOrphans(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not sweep the coordination bus: %v\n", err)
+	}
+	for _, name := range swept {
+		fmt.Printf("🧹 Removed orphaned bus entry: %s\n", name)
+	}
+}
+
+// printInstructions lists the `tell` messages a run was given.
+func printInstructions(result *container.TaskResult) {
+	if result == nil || len(result.Instructions) == 0 {
+		return
+	}
+	fmt.Printf("📨 Instructions given during the run:\n")
+	for _, ins := range result.Instructions {
+		fmt.Printf("   %s\n", ins)
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
 	}
 	if d < 24*time.Hour {
 		return fmt.Sprintf("%dh", int(d.Hours()))
@@ -651,39 +3418,183 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dd", int(d.Hours()/24))
 }
 
+// Exit codes, documented under "Exit codes" in the usage text and README.
+// 0 is success and 1 any other failure; wait also exits 2 on a timeout.
+const (
+	exitAgentNotFound       = 3
+	exitContainerNotRunning = 4
+	exitClaimConflict       = 5
+	exitMaxAttempts         = 6
+	exitBudgetExceeded      = 7
+	exitRuntimeUnresponsive = 8
+	exitRunInProgress       = 9
+	exitNoCapacity          = 10
+	exitImageUnavailable    = 11
+	exitDependencyFailed    = 12
+	exitToolchainMissing    = 13
+	exitUnrecoverable       = 14
+	exitAuthFailed          = 15
+)
+
+// exitCode maps an error to the exit code scripts can branch on.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, container.ErrAgentNotFound):
+		return exitAgentNotFound
+	case errors.Is(err, container.ErrContainerNotRunning):
+		return exitContainerNotRunning
+	case errors.Is(err, coordination.ErrClaimConflict):
+		return exitClaimConflict
+	case errors.Is(err, container.ErrMaxAttempts):
+		return exitMaxAttempts
+	case errors.Is(err, container.ErrBudgetExceeded):
+		return exitBudgetExceeded
+	case errors.Is(err, container.ErrRuntimeUnresponsive):
+		return exitRuntimeUnresponsive
+	case errors.Is(err, container.ErrRunInProgress):
+		return exitRunInProgress
+	case errors.Is(err, container.ErrNoCapacity):
+		return exitNoCapacity
+	case errors.Is(err, container.ErrImageUnavailable):
+		return exitImageUnavailable
+	case errors.Is(err, container.ErrDependencyFailed):
+		return exitDependencyFailed
+	case errors.Is(err, container.ErrToolchainMissing):
+		return exitToolchainMissing
+	case errors.Is(err, container.ErrUnrecoverable):
+		return exitUnrecoverable
+	case errors.Is(err, container.ErrAuthFailed):
+		return exitAuthFailed
+	}
+	return 1
+}
+
 func printUsage() {
 	fmt.Println("agentctl - Claude Code Agent Container Orchestrator")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  spawn <name> <repo> [branch] [--image <img>]  Create new agent container")
-	fmt.Println("  run <name> <task> [attempts]    Run until task complete (Ralph Wiggum mode)")
-	fmt.Println("  check <name>                    Check if agent's task is complete")
-	fmt.Println("  list                            List all agents with lifecycle status")
+	fmt.Println("  spawn <name> <repo> [branch] [--image <img>]  Create new agent container (default: the repo's default branch)")
+	fmt.Println("        [--profile <name>]                        Use a config profile (image, home, workspace, user)")
+	fmt.Println("        [--workspace <path>]                      Clone the repo to this path in the container")
+	fmt.Println("        [--no-setup] [--setup <cmd>]              Control the post-clone dependency install")
+	fmt.Println("        [--tmpfs-size <size>] [--disk-quota <size>] Limit /tmp and the container's disk")
+	fmt.Println("        [--after <agent>]...                      Build on another agent's work; rebase once it's merged")
+	fmt.Println("        [--needs <agent>,...]                     Start runs only once these agents' runs have completed")
+	fmt.Println("        [--egress] [--allow-host <host>]...       Only reach GitHub, registries, the API and these hosts")
+	fmt.Println("        [--wait]                                  Wait for room under the capacity limits instead of failing")
+	fmt.Println("        [--yolo]                                  Skip Claude's permission checks despite the profile's rules")
+	fmt.Println("        [--no-toolchain-check]                    Don't check the image has the runtimes the repo needs")
+	fmt.Println("        [--no-references]                         Don't mount the configured reference repos and docs")
+	fmt.Println("  run <name> <task> [attempts]    Run until task complete (Ralph Wiggum mode); a glob runs many")
+	fmt.Println("      [--force]                   Take over from a run that died holding the agent's lock")
+	fmt.Println("      [--output-patch]            Leave the change uncommitted and save it as a patch in history")
+	fmt.Println("      [--apply-to <dir>]          ... and apply that patch to a local checkout for review")
+	fmt.Println("      [--done-when <cmd>]         Done once this command exits 0 in the container, not the tests")
+	fmt.Println("      [--continue-session]        Resume the last attempt's Claude session instead of starting afresh")
+	fmt.Println("  run --resume <name> [attempts]  Continue an interrupted run from its last attempt")
+	fmt.Println("  apply-patch <name> <patch> <task> [attempts]  Seed the workspace with a partial diff and run to finish it")
+	fmt.Println("  tell <name> \"<message>\"        Add an instruction to a running agent's next attempt")
+	fmt.Println("  check <name> [--json]           Check if agent's task is complete, with test counts")
+	fmt.Println("  summarize <name> [--pr]         Summarize what the agent changed (and put it in its PR)")
+	fmt.Println("  pr <name> [--draft]             Open a PR/MR for the agent's branch on GitHub, GitLab or Gitea")
+	fmt.Println("  ci <name>                       Show the CI result for the agent's branch")
+	fmt.Println("  integrity <name>                Check for conflict markers, .orig/.rej files, debug prints, broken build")
+	fmt.Println("  push <name> [--no-verify]       Push the agent's branch if the integrity checks pass")
+	fmt.Println("  squash <name> [--policy p] [--dry-run] [--push]  Tidy the agent's commits before a PR")
+	fmt.Println("  attempts <name> [<from> <to>]   List attempt tags or diff two attempts")
+	fmt.Println("  bench <bench.json> [--keep] [--json]  Run one task across prompt/model/image variants and compare")
+	fmt.Println("  cp <name>:<path> <local-path>   Copy a file or directory out of an agent (or the reverse)")
+	fmt.Println("  artifacts <name> [path...]      Export build outputs to ~/.agentctl/artifacts/<name>/")
+	fmt.Println("  list [--repo <url>]             List all agents (or one repo's) with lifecycle status")
+	fmt.Println("  capacity [--json]               Show host load against the spawn capacity limits")
 	fmt.Println("  status <name>                   Show agent details")
+	fmt.Println("  tutorial --repo <sandbox-url>   Guided walk-through: spawn, spy, run, check, PR, cleanup")
+	fmt.Println("  find <keyword>                  Search agents and history by intent, task, repo, notes, metadata")
+	fmt.Println("  note <name> [\"text\"]            Add a timestamped note to an agent (or list its notes)")
 	fmt.Println("  logs [-f] <name>                Show Claude logs (-f to follow in real-time)")
+	fmt.Println("       [--source claude|setup|tests|hooks] [--tail N] [--since 1h]")
 	fmt.Println("  watch <name>                    Poll agent status every 5s (tests/uncommitted/running)")
+	fmt.Println("  board [--format md|html|slack]  Fleet progress board: task, state, PR and blockers per agent")
+	fmt.Println("        [--out f] [--publish] [--gist id] [--wiki owner/repo] [--slack url] [--every 10m]")
+	fmt.Println("  guard <name>                    Enforce the security policy on an agent's tool calls")
+	fmt.Println("  violations [name]               List recorded policy violations")
+	fmt.Println("  wait <name> [--timeout <dur>]   Block until done (exit 0=completed, 1=failed, 2=timeout)")
 	fmt.Println("  spy <name> [flags]              Stream Claude's real-time session activity")
+	fmt.Println("  triage <repo> [--label agent-ok] [--max-difficulty 2] [--limit N] [--dry-run]")
+	fmt.Println("                                  Estimate labelled issues and spawn agents for the easy ones")
+	fmt.Println("  release-notes <repo> [--since <tag>] [--file CHANGELOG.md] [--draft]")
+	fmt.Println("                                  Have an agent write the changelog entry since a tag and open a PR")
+	fmt.Println("  conflicts <repo> \"<task>\" [--json]")
+	fmt.Println("                                  Predict the files a task touches and the agents it would collide with")
+	fmt.Println("  workflow deps [<repo>...] [--ecosystem go|npm|composer] [--concurrency N] [--dry-run]")
+	fmt.Println("                                  Upgrade dependencies per ecosystem, test, and open a PR for each")
+	fmt.Println("  dag show [--json]               Agents' dependencies (spawn --needs) in stages, with their states")
+	fmt.Println("  analytics [--agent glob] [--since 7d] [--tool T] [--file f]... [--json]")
+	fmt.Println("                                  Tool use and tokens across recorded spy events")
+	fmt.Println("  stats [--repo url] [--group-by repo|template|backend] [--since 30d] [--json]")
+	fmt.Println("                                  Success rate, attempts, duration and cost of past runs")
 	fmt.Println("  shell <name>                    Open shell in agent container")
 	fmt.Println("  diagnose <name>                 Debug stuck agents (processes, logs, auth)")
-	fmt.Println("  kill <name>                     Stop and remove agent")
+	fmt.Println("  verify-auth [<name>...] [--no-refresh] [--json]  Check agents can reach their model, refreshing stale logins")
+	fmt.Println("  kill <name|glob>... | --repo <url>  Stop and remove agents, or every agent on a repo")
+	fmt.Println("  spawn|run|kill --stdin [--concurrency N]  Apply to a JSON array of agent specs; one JSON result per line")
+	fmt.Println("  pause <name>                    Freeze an agent's container (run loops wait at the next attempt)")
+	fmt.Println("  resume <name>                   Unfreeze a paused agent")
+	fmt.Println("  export <name>                   Print a shareable agent definition (YAML)")
+	fmt.Println("  import <file> [--name <n>] [--run [attempts]]")
+	fmt.Println("                                  Spawn an agent from an exported definition")
 	fmt.Println()
 	fmt.Println("Lifecycle:")
-	fmt.Println("  prune                           Remove all exited/stopped containers")
-	fmt.Println("  cleanup [grace-period]           Remove completed/stale agents past grace period")
+	fmt.Println("  prune [--repo <url>]            Remove all exited/stopped containers")
+	fmt.Println("  cleanup [grace-period] [--repo <url>]  Remove finished agents past their grace period (config: retention)")
+	fmt.Println("  cleanup <name|glob>... | --all [--result <r>]  Remove the agents given, keeping history")
 	fmt.Println("  history                          Show history of removed agents")
+	fmt.Println("  encrypt                          Encrypt history and recorded sessions written in plaintext")
+	fmt.Println("  cache stats                      Show shared dependency cache usage")
+	fmt.Println("  cache prune [--older-than 30d] [--max-size 20G] [--dry-run]")
+	fmt.Println("                                   Trim the shared dependency cache")
 	fmt.Println()
 	fmt.Println("Pipeline:")
 	fmt.Println("  pipeline <repo> <issue> [--dry-run] [--from=<step>]")
 	fmt.Println("                                  Run a pipeline.yml against a repo+issue")
+	fmt.Println("  pipeline run <name> --repo <repo> [--<param> <value>]...")
+	fmt.Println("                                  Run a named pipeline from config")
+	fmt.Println("  pipeline list | history         Show named pipelines / per-step run history")
+	fmt.Println("  pipeline status [run-id]        Show a run's execution graph (default: latest)")
+	fmt.Println("  pipeline resume <run-id>        Resume a failed or interrupted run from the failed step")
+	fmt.Println()
+	fmt.Println("Daemon:")
+	fmt.Println("  serve [--addr :8090]            Route bus messages and GitHub webhooks to pipeline triggers,")
+	fmt.Println("                                  and serve the fleet API (admin and read-only spectator roles)")
+	fmt.Println("  shim [--addr :8080] [--home DIR] Liveness endpoint for the host (spawn starts it in each agent)")
+	fmt.Println("  schedule list                   Show schedules with next run and last outcome")
+	fmt.Println("  schedule run <id>               Run a schedule now (the daemon does this on its cron)")
 	fmt.Println()
 	fmt.Println("QA / Review:")
 	fmt.Println("  review <name>                   Ask Lexi to review the open PR (exit 0=approved, 1=changes)")
+	fmt.Println("  feedback <name> [--pr N]        Run the agent again on its PR's unresolved review comments, then push")
 	fmt.Println()
 	fmt.Println("Coordination:")
 	fmt.Println("  claim <agent> <repo-url> <file>             Claim a file for editing")
 	fmt.Println("  release <agent> <repo-url> <file>           Release a file claim")
-	fmt.Println("  notify <agent> <repo-url> <type> [k=v...]   Publish a coordination message")
-	fmt.Println("  bus <repo-url> [--claims|--messages|--state] Show coordination bus state")
+	fmt.Println("  notify <agent> <repo-url> <type> [k=v...]   Publish a coordination message (checked against its type; --force)")
+	fmt.Println("  notify --types                              List message types and their fields")
+	fmt.Println("  bus <repo-url> [--claims|--messages|--state|--touched] Show coordination bus state")
+	fmt.Println("  bus serve [--addr :8088]                    Serve this host's buses over HTTP and SSE")
+	fmt.Println("  plan <repo-url> <tasks.json|->  [--json]    Group sub-tasks into parallel batches around claims")
+	fmt.Println("  claims check <repo-url> <file>... [--json]  Report claims on files; exit 5 if any is claimed")
+	fmt.Println("         [--acquire [--as <name>] [--wait] [--timeout <dur>]]  Claim them all, waiting until they're free")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0 success, 1 other failure, 2 wait timed out, 3 agent not found, 4 container not running,")
+	fmt.Println("  5 file claimed by another agent, 6 max attempts reached, 7 budget exceeded, 8 runtime unresponsive,")
+	fmt.Println("  9 another run holds the agent, 10 host or repo at capacity, 11 image unavailable,")
+	fmt.Println("  12 a needed agent failed, 13 image lacks a toolchain the repo needs,")
+	fmt.Println("  14 run stopped on a failure not worth retrying, 15 agent can't authenticate with its model")
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("  --namespace <ns>                Isolate agents, buses and ports from other fleets on this host")
+	fmt.Println("                                  (default: $AGENTCTL_NAMESPACE; `agentctl namespaces` lists them)")
 	fmt.Println()
 	fmt.Println("Example:")
 	fmt.Println("  agentctl spawn fix-bug https://github.com/user/repo feature-branch --image agent-lexi:latest")
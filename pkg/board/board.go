@@ -0,0 +1,172 @@
+// Package board builds a fleet progress board — each agent's task, state,
+// PR and blockers — rendered as Markdown, HTML or Slack text and published
+// to a gist, a repo wiki page or a Slack webhook.
+package board
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/container"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// Entry is one agent's row on the board.
+type Entry struct {
+	Name     string
+	Repo     string
+	Branch   string
+	Task     string
+	State    string // working, blocked, done, idle, active, completed, exited, stopped
+	PR       string // URL or number from the agent's pr_created message
+	Blockers []string
+	Note     string // the latest `agentctl note`
+	Age      time.Duration
+}
+
+// Board is a snapshot of the fleet.
+type Board struct {
+	Title     string
+	Namespace string
+	Generated time.Time
+	Entries   []Entry
+}
+
+// Counts tallies entries per state.
+func (b *Board) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, e := range b.Entries {
+		counts[e.State]++
+	}
+	return counts
+}
+
+// Collect snapshots the agents in the current namespace, optionally only
+// those working on repo.
+func Collect(repo string) (*Board, error) {
+	agents, err := container.ListWithStateCached()
+	if err != nil {
+		return nil, err
+	}
+	return Build(agents, repo, time.Now()), nil
+}
+
+// Build assembles a board from agents, enriching each with its coordination
+// state, bus messages and policy violations.
+func Build(agents []*container.AgentWithState, repo string, now time.Time) *Board {
+	b := &Board{Title: "Agent fleet", Namespace: namespace.Current(), Generated: now}
+	if repo != "" {
+		b.Title += " — " + repo
+	}
+
+	states := map[string]*coordination.State{}
+	messages := map[string][]coordination.Message{}
+	for _, a := range agents {
+		if repo != "" && a.Repo != repo {
+			continue
+		}
+		if a.Repo != "" {
+			if _, seen := states[a.Repo]; !seen {
+				states[a.Repo], _ = coordination.GetState(a.Repo)
+				messages[a.Repo], _ = coordination.ReadMessages(a.Repo)
+			}
+		}
+		b.Entries = append(b.Entries, entry(a, states[a.Repo], messages[a.Repo], now))
+	}
+	sort.Slice(b.Entries, func(i, j int) bool {
+		if blockedI, blockedJ := len(b.Entries[i].Blockers) > 0, len(b.Entries[j].Blockers) > 0; blockedI != blockedJ {
+			return blockedI
+		}
+		return b.Entries[i].Name < b.Entries[j].Name
+	})
+	return b
+}
+
+func entry(a *container.AgentWithState, state *coordination.State, msgs []coordination.Message, now time.Time) Entry {
+	e := Entry{
+		Name:   a.Name,
+		Repo:   a.Repo,
+		Branch: a.Branch,
+		Task:   a.Task,
+		State:  string(a.Lifecycle),
+		Age:    now.Sub(a.Created),
+	}
+	if e.Task == "" {
+		e.Task = a.Intent
+	}
+	if state != nil {
+		if s, ok := state.Agents[a.Name]; ok && s.Status != "" {
+			e.State = s.Status
+			if s.Branch != "" {
+				e.Branch = s.Branch
+			}
+		}
+	}
+
+	for _, m := range msgs {
+		if m.Agent != a.Name {
+			continue
+		}
+		switch m.Type {
+		case coordination.MsgPRCreated:
+			if url := m.Data["url"]; url != "" {
+				e.PR = url
+			} else if pr := m.Data["pr"]; pr != "" {
+				e.PR = "#" + pr
+			}
+		case coordination.MsgEscalation:
+			reason := strings.TrimSpace(m.Data["step"] + " " + m.Data["error"])
+			if reason == "" {
+				reason = m.Data["reason"]
+			}
+			e.Blockers = append(e.Blockers, "escalated: "+reason)
+		}
+	}
+	if len(a.Notes) > 0 {
+		e.Note = a.Notes[len(a.Notes)-1].Text
+	}
+	for _, v := range a.Violations {
+		e.Blockers = append(e.Blockers, "policy violation: "+v.Reason)
+	}
+	switch {
+	case e.State == "blocked" && len(e.Blockers) == 0:
+		e.Blockers = append(e.Blockers, "blocked (gave up after max attempts)")
+	case a.Lifecycle == container.StateExited:
+		e.Blockers = append(e.Blockers, "container exited")
+	}
+	return e
+}
+
+// stateIcon mirrors the icons of `agentctl list`.
+func stateIcon(state string) string {
+	switch state {
+	case "working", "active":
+		return "🔄"
+	case "done", "completed":
+		return "✅"
+	case "blocked":
+		return "🚧"
+	case "paused":
+		return "⏸️"
+	case "exited":
+		return "💀"
+	case "stopped":
+		return "🔌"
+	default:
+		return "⏳"
+	}
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
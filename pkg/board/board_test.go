@@ -0,0 +1,92 @@
+package board
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/container"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func testBoard(t *testing.T) *Board {
+	t.Helper()
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	t.Cleanup(func() { os.Setenv("HOME", origHome) })
+
+	repo := "https://github.com/org/api"
+	coordination.Init(repo)
+	coordination.UpdateAgentState(repo, "api-auth", "working", "feat/auth")
+	coordination.UpdateAgentState(repo, "api-db", "blocked", "")
+	coordination.Publish(repo, coordination.Message{Type: coordination.MsgPRCreated, Agent: "api-auth",
+		Data: map[string]string{"url": "https://github.com/org/api/pull/7"}})
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	agents := []*container.AgentWithState{
+		{Agent: &container.Agent{Name: "api-auth", Repo: repo, Task: "Add | token auth", Created: now.Add(-90 * time.Minute)}, Lifecycle: container.StateActive},
+		{Agent: &container.Agent{Name: "api-db", Repo: repo, Intent: "Migrate to pgx", Created: now.Add(-3 * time.Hour),
+			Notes: []container.Note{{Text: "old"}, {Text: "waiting on upstream pgx fix"}}}, Lifecycle: container.StateActive},
+		{Agent: &container.Agent{Name: "web", Repo: "https://github.com/org/web", Created: now}, Lifecycle: container.StateExited},
+	}
+	return Build(agents, repo, now)
+}
+
+func TestBuild(t *testing.T) {
+	b := testBoard(t)
+	if len(b.Entries) != 2 {
+		t.Fatalf("repo filter: got %d entries, want 2", len(b.Entries))
+	}
+	// Blocked agents sort first.
+	db, auth := b.Entries[0], b.Entries[1]
+	if db.Name != "api-db" || db.State != "blocked" || len(db.Blockers) != 1 || db.Task != "Migrate to pgx" || db.Note != "waiting on upstream pgx fix" {
+		t.Errorf("api-db entry = %+v", db)
+	}
+	if auth.State != "working" || auth.Branch != "feat/auth" || auth.PR != "https://github.com/org/api/pull/7" || len(auth.Blockers) != 0 {
+		t.Errorf("api-auth entry = %+v", auth)
+	}
+}
+
+func TestRender(t *testing.T) {
+	b := testBoard(t)
+
+	md, _ := Render(b, FormatMarkdown)
+	if !strings.Contains(md, `Add \| token auth`) || !strings.Contains(md, "1h30m") || !strings.Contains(md, "2 agents: 1 blocked · 1 working") {
+		t.Errorf("markdown board:\n%s", md)
+	}
+
+	page, err := Render(b, FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page, `<a href="https://github.com/org/api/pull/7">`) || !strings.Contains(page, `class="blocked"`) {
+		t.Errorf("html board:\n%s", page)
+	}
+
+	if text, _ := Render(b, FormatSlack); !strings.Contains(text, "*api-db* (blocked)") || !strings.Contains(text, "📝 waiting on upstream") {
+		t.Errorf("slack board:\n%s", text)
+	}
+	if _, err := Render(b, "pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestPublishSlack(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := PublishSlack(srv.URL, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != "hello" {
+		t.Errorf("posted %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package board
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GistFile is the file name the board is written to in a gist.
+const GistFile = "agent-fleet.md"
+
+// PublishGist replaces the board file in an existing gist via `gh api`.
+func PublishGist(gistID, markdown string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"files": map[string]interface{}{GistFile: map[string]string{"content": markdown}},
+	})
+	cmd := exec.Command("gh", "api", "-X", "PATCH", "gists/"+gistID, "--input", "-")
+	cmd.Stdin = bytes.NewReader(body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("gist update failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// PublishWiki writes the board to a page of a GitHub repo wiki. target is
+// "owner/repo" or "owner/repo:Page-Name" (default page "Agent-Fleet").
+func PublishWiki(target, markdown string) error {
+	repo, page, _ := strings.Cut(target, ":")
+	if page == "" {
+		page = "Agent-Fleet"
+	}
+	repo = strings.TrimSuffix(strings.TrimPrefix(repo, "https://github.com/"), ".git")
+
+	dir, err := os.MkdirTemp("", "agentctl-wiki-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if err := git("clone", "--depth", "1", "https://github.com/"+repo+".wiki.git", "."); err != nil {
+		return fmt.Errorf("wiki clone failed (has the wiki got a first page?): %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, page+".md"), []byte(markdown), 0644); err != nil {
+		return err
+	}
+	if err := git("add", page+".md"); err != nil {
+		return err
+	}
+	if exec.Command("git", "-C", dir, "diff", "--cached", "--quiet").Run() == nil {
+		return nil // unchanged
+	}
+	if err := git("commit", "-m", "Update agent fleet board"); err != nil {
+		return err
+	}
+	return git("push")
+}
+
+// PublishSlack posts the board to a Slack incoming webhook.
+func PublishSlack(webhookURL, text string) error {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slack post failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack post failed: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package board
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// Formats accepted by Render.
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatSlack    = "slack"
+)
+
+// Render renders the board in the given format.
+func Render(b *Board, format string) (string, error) {
+	switch format {
+	case FormatMarkdown, "markdown", "":
+		return Markdown(b), nil
+	case FormatHTML:
+		return HTML(b)
+	case FormatSlack:
+		return Slack(b), nil
+	default:
+		return "", fmt.Errorf("unknown board format %q (want md, html or slack)", format)
+	}
+}
+
+// summary is the one-line state tally, e.g. "3 working · 1 blocked".
+func summary(b *Board) string {
+	counts := b.Counts()
+	states := make([]string, 0, len(counts))
+	for s := range counts {
+		states = append(states, s)
+	}
+	sort.Strings(states)
+	parts := make([]string, 0, len(states))
+	for _, s := range states {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+	}
+	if len(parts) == 0 {
+		return "no agents"
+	}
+	return fmt.Sprintf("%d agents: %s", len(b.Entries), strings.Join(parts, " · "))
+}
+
+func heading(b *Board) string {
+	h := b.Title
+	if b.Namespace != "" {
+		h += " [" + b.Namespace + "]"
+	}
+	return h
+}
+
+// mdCell escapes a value for a Markdown table cell.
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	s = strings.ReplaceAll(s, "|", `\|`)
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+func shorten(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > max {
+		return s[:max] + "…"
+	}
+	return s
+}
+
+// Markdown renders the board as a Markdown table.
+func Markdown(b *Board) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", heading(b))
+	fmt.Fprintf(&sb, "_Updated %s — %s_\n\n", b.Generated.Format("2006-01-02 15:04 MST"), summary(b))
+	if len(b.Entries) == 0 {
+		return sb.String()
+	}
+	sb.WriteString("| | Agent | Task | State | PR | Blockers | Note | Age |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, e := range b.Entries {
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			stateIcon(e.State), mdCell(e.Name), mdCell(shorten(e.Task, 80)), e.State,
+			mdCell(e.PR), mdCell(strings.Join(e.Blockers, "; ")), mdCell(shorten(e.Note, 80)), formatAge(e.Age))
+	}
+	return sb.String()
+}
+
+// Slack renders the board as Slack mrkdwn text.
+func Slack(b *Board) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%s* — %s\n", heading(b), summary(b))
+	for _, e := range b.Entries {
+		fmt.Fprintf(&sb, "%s *%s* (%s) %s", stateIcon(e.State), e.Name, e.State, shorten(e.Task, 80))
+		if e.PR != "" {
+			fmt.Fprintf(&sb, " · PR %s", e.PR)
+		}
+		if len(e.Blockers) > 0 {
+			fmt.Fprintf(&sb, " · ⚠️ %s", strings.Join(e.Blockers, "; "))
+		}
+		if e.Note != "" {
+			fmt.Fprintf(&sb, " · 📝 %s", shorten(e.Note, 80))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+var htmlTemplate = template.Must(template.New("board").Funcs(template.FuncMap{
+	"icon":    stateIcon,
+	"age":     formatAge,
+	"shorten": shorten,
+	"join":    strings.Join,
+	"isURL":   func(s string) bool { return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>{{.Heading}}</title>
+<style>
+body { font-family: -apple-system, sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .4em .6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+.blocked { background: #fff4e5; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Heading}}</h1>
+<p class="meta">Updated {{.Board.Generated.Format "2006-01-02 15:04 MST"}} — {{.Summary}}</p>
+{{if .Board.Entries}}<table>
+<tr><th></th><th>Agent</th><th>Task</th><th>State</th><th>PR</th><th>Blockers</th><th>Note</th><th>Age</th></tr>
+{{range .Board.Entries}}<tr{{if .Blockers}} class="blocked"{{end}}>
+<td>{{icon .State}}</td><td>{{.Name}}</td><td>{{shorten .Task 120}}</td><td>{{.State}}</td>
+<td>{{if isURL .PR}}<a href="{{.PR}}">{{.PR}}</a>{{else}}{{.PR}}{{end}}</td>
+<td>{{join .Blockers "; "}}</td><td>{{.Note}}</td><td>{{age .Age}}</td>
+</tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// HTML renders the board as a self-refreshing HTML page.
+func HTML(b *Board) (string, error) {
+	var sb strings.Builder
+	err := htmlTemplate.Execute(&sb, struct {
+		Board   *Board
+		Heading string
+		Summary string
+	}{b, heading(b), summary(b)})
+	return sb.String(), err
+}
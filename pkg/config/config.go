@@ -0,0 +1,743 @@
+// Package config loads the user's agentctl configuration from
+// ~/.agentctl/config.json. A missing file is not an error — every field has a
+// usable zero value so agentctl works out of the box.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is the parsed ~/.agentctl/config.json.
+type Config struct {
+	LLMKey    string `json:"llm_key,omitempty"`
+	LexiURL   string `json:"lexi_url,omitempty"`
+	LexiToken string `json:"lexi_token,omitempty"`
+	Setup     Setup  `json:"setup,omitempty"`
+
+	Coordination Coordination `json:"coordination,omitempty"`
+	Runtime      Runtime      `json:"runtime,omitempty"`
+	Tests        Tests        `json:"tests,omitempty"`
+	Integrity    Integrity    `json:"integrity,omitempty"`
+	Artifacts    Artifacts    `json:"artifacts,omitempty"`
+	Commits      Commits      `json:"commits,omitempty"`
+	Quota        Quota        `json:"quota,omitempty"`
+	Capacity     Capacity     `json:"capacity,omitempty"`
+	Retention    Retention    `json:"retention,omitempty"`
+	Spy          Spy          `json:"spy,omitempty"`
+	Policy       Policy       `json:"policy,omitempty"`
+	Board        Board        `json:"board,omitempty"`
+	Telemetry    Telemetry    `json:"telemetry,omitempty"`
+	Logs         Logs         `json:"logs,omitempty"`
+	GitCache     GitCache     `json:"git_cache,omitempty"`
+	Git          Git          `json:"git,omitempty"`
+	Summary      Summary      `json:"summary,omitempty"`
+	Idle         Idle         `json:"idle,omitempty"`
+	Egress       Egress       `json:"egress,omitempty"`
+	Triage       Triage       `json:"triage,omitempty"`
+	Encryption   Encryption   `json:"encryption,omitempty"`
+	Workflows    Workflows    `json:"workflows,omitempty"`
+	RunLoop      RunLoop      `json:"run_loop,omitempty"`
+	Stats        Stats        `json:"stats,omitempty"`
+	Toolchains   Toolchains   `json:"toolchains,omitempty"`
+	Auth         Auth         `json:"auth,omitempty"`
+	// Forges names the code host behind hosts agentctl can't recognise by
+	// name, such as a self-hosted GitLab.
+	Forges []Forge `json:"forges,omitempty"`
+
+	// Pipelines are named, reusable pipelines run with `agentctl pipeline run`.
+	Pipelines map[string]NamedPipeline `json:"pipelines,omitempty"`
+	// PipelineSteps defines reusable steps (name → shell command) that named
+	// pipelines reference; they override the built-in steps of the same name.
+	PipelineSteps map[string]string `json:"pipeline_steps,omitempty"`
+
+	// Triggers start named pipelines when the daemon sees matching events.
+	Triggers []Trigger `json:"triggers,omitempty"`
+	Daemon   Daemon    `json:"daemon,omitempty"`
+	// Notifications are chat channels the daemon posts bus events to.
+	Notifications []Notification `json:"notifications,omitempty"`
+	// Profiles name agent images and, for images not laid out like
+	// agent-devbox, where their home and workspace are.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// Registries hold the credentials for private registries agent images
+	// are pulled from.
+	Registries []Registry `json:"registries,omitempty"`
+	// Workspaces sets where a repo is cloned inside the container, keyed by
+	// "owner/repo"; it wins over the profile's and the image's workspace.
+	Workspaces map[string]string `json:"workspaces,omitempty"`
+	// Schedules are recurring runs started by the daemon.
+	Schedules []Schedule `json:"schedules,omitempty"`
+	// References are repos and directories mounted read-only into agents
+	// for them to read, such as a style guide or a sibling service's source.
+	References []Reference `json:"references,omitempty"`
+}
+
+// Reference is read-only material mounted into agents at
+// /references/<name> and listed in their prompts. Give either Repo or Path.
+type Reference struct {
+	// Name is its directory under /references (default: the last element
+	// of Repo or Path).
+	Name string `json:"name,omitempty"`
+	// Repo is a git URL kept checked out on the host, refreshed at each
+	// spawn; Ref picks the branch or tag (default: the remote's HEAD).
+	Repo string `json:"repo,omitempty"`
+	Ref  string `json:"ref,omitempty"`
+	// Path is a directory on the host, mounted as it is.
+	Path string `json:"path,omitempty"`
+	// Description tells agents what it is and when to read it.
+	Description string `json:"description,omitempty"`
+	// Repos limits it to agents working on these repos ("owner/repo");
+	// empty means every agent.
+	Repos []string `json:"repos,omitempty"`
+}
+
+// Profile describes an agent image. Home and Workspace default to the image's
+// agentctl.home / agentctl.workspace labels, then its HOME, then
+// /home/agent and <home>/workspace/repo; User and UserNS to the image's USER
+// and agentctl.userns label.
+type Profile struct {
+	Image     string `json:"image,omitempty"`
+	Home      string `json:"home,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	// User runs the container (podman --user), e.g. "1001" or "app:app".
+	User string `json:"user,omitempty"`
+	// UserNS is passed to podman --userns, e.g. "keep-id:uid=1000,gid=1000"
+	// so the host user owns the bind-mounted caches inside the container.
+	UserNS string `json:"userns,omitempty"`
+	// RestrictEgress holds the profile's agents to the egress allow-list,
+	// with AllowHosts added to it.
+	RestrictEgress bool     `json:"restrict_egress,omitempty"`
+	AllowHosts     []string `json:"allow_hosts,omitempty"`
+	// Permissions, when set, replace --dangerously-skip-permissions for the
+	// profile's agents with these Claude Code permission rules.
+	Permissions *Permissions `json:"permissions,omitempty"`
+	// Toolchains declares the runtimes the profile's image provides, e.g.
+	// {"php": "8.3"}, so spawn can suggest the profile to a repo that needs
+	// them.
+	Toolchains map[string]string `json:"toolchains,omitempty"`
+}
+
+// Registry is a private image registry. Spawn pulls an image that isn't
+// present locally, logging in with Username and Password or, with AuthFile,
+// using a containers auth.json (e.g. one mounted from a secret) instead.
+type Registry struct {
+	// Host is the registry as image names spell it: "registry.internal:5000".
+	Host     string `json:"host"`
+	Username string `json:"username,omitempty"`
+	// Password is best given as "${REGISTRY_TOKEN}", kept out of the file.
+	Password string `json:"password,omitempty"`
+	AuthFile string `json:"auth_file,omitempty"`
+}
+
+// Permissions are Claude Code permission rules such as "Edit",
+// "Bash(go test:*)" or "WebFetch(domain:pkg.go.dev)". Tools not allowed are
+// refused, since an unattended agent has no one to ask; Deny wins over Allow.
+type Permissions struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Schedule runs a task against each of its repos on a cron schedule — in a
+// fresh agent per repo, or through a named pipeline.
+type Schedule struct {
+	// ID names the schedule in history and `agentctl schedule` commands.
+	ID string `json:"id"`
+	// Cron is a five-field cron expression or @hourly, @daily, @nightly
+	// (02:00), @weekly, @monthly; evaluated in the daemon's local time.
+	Cron  string   `json:"cron"`
+	Repos []string `json:"repos"`
+	// Task is the prompt for the agent (or the pipeline's task param).
+	Task string `json:"task,omitempty"`
+	// Pipeline runs this named pipeline per repo instead of a bare agent.
+	Pipeline string            `json:"pipeline,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Branch   string            `json:"branch,omitempty"`
+	Image    string            `json:"image,omitempty"`
+	// Attempts bounds the agent's run loop (default 10).
+	Attempts int `json:"attempts,omitempty"`
+	// KeepFailed leaves the container of a failed run for inspection.
+	KeepFailed bool `json:"keep_failed,omitempty"`
+}
+
+// Trigger starts a named pipeline in response to an event.
+type Trigger struct {
+	// On names the event: "bus:<message type>" (e.g. "bus:pr_created") or
+	// "github:<event>[.<action>]" (e.g. "github:issues.labeled").
+	On string `json:"on"`
+	// Repo is the repo whose bus is watched (required for bus events) or
+	// that a webhook must come from (optional for github events).
+	Repo string `json:"repo,omitempty"`
+	// Label restricts github label events to one label name.
+	Label string `json:"label,omitempty"`
+	// Pipeline is the named pipeline to run.
+	Pipeline string `json:"pipeline"`
+	// Params are passed to the pipeline; {{field}} placeholders are filled
+	// from the event (repo, issue, pr, label, agent, and bus message data).
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Notification is a channel the daemon posts fleet events to, one message
+// per event or batched into a digest.
+type Notification struct {
+	// Name identifies the channel in logs.
+	Name string `json:"name,omitempty"`
+	// Webhook is a Slack (or Slack-compatible) incoming-webhook URL.
+	Webhook string `json:"webhook"`
+	// Repos whose buses are watched; every watched bus when empty.
+	Repos []string `json:"repos,omitempty"`
+	// Events are the bus message types posted (default pr_created, merged,
+	// checks_completed, review_submitted, escalation, policy_violation,
+	// claim_conflict and agent_removed).
+	Events []string `json:"events,omitempty"`
+	// Digest batches events over this window ("15m") into one summary
+	// message; without it every event is posted as it happens.
+	Digest string `json:"digest,omitempty"`
+	// Immediate are event types posted at once even in digest mode
+	// (default escalation and policy_violation).
+	Immediate []string `json:"immediate,omitempty"`
+}
+
+// Daemon configures `agentctl serve`.
+type Daemon struct {
+	// Addr is the HTTP listen address (default ":8090").
+	Addr string `json:"addr,omitempty"`
+	// WebhookSecret verifies GitHub webhook signatures; without it every
+	// delivery is refused.
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	// PollInterval is how often watched buses are read (default "5s").
+	PollInterval string `json:"poll_interval,omitempty"`
+	// Users may call the HTTP API with their token as a bearer token. With no
+	// users configured the API is read-only and open.
+	Users []DaemonUser `json:"users,omitempty"`
+}
+
+// DaemonUser is an API identity. Role is "admin" (everything) or "spectator"
+// (list, spy and history only).
+type DaemonUser struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+// NamedPipeline is a sequence of step names plus parameter defaults. A
+// parameter whose default is empty must be supplied on the command line.
+// Needs maps a step to the steps it waits for; declaring any turns the
+// pipeline into a DAG whose independent steps run in parallel.
+// The bare list form `"standard": ["implement", "review"]` is accepted too.
+type NamedPipeline struct {
+	Steps  []string            `json:"steps"`
+	Params map[string]string   `json:"params,omitempty"`
+	Needs  map[string][]string `json:"needs,omitempty"`
+}
+
+// UnmarshalJSON accepts either the object form or a bare list of steps.
+func (p *NamedPipeline) UnmarshalJSON(data []byte) error {
+	var steps []string
+	if err := json.Unmarshal(data, &steps); err == nil {
+		p.Steps = steps
+		return nil
+	}
+	type plain NamedPipeline
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// Policy restricts what agents may do. When enabled, `agentctl run` watches
+// the agent's tool calls and acts on the first one that breaks a rule.
+// Built-in rules forbid piping downloads into a shell, pushing to protected
+// branches and writing files outside the workspace.
+type Policy struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Action on a violation: "kill" (default), "pause" or "warn".
+	Action string `json:"action,omitempty"`
+	// ForbiddenCommands are regular expressions no shell command may match.
+	ForbiddenCommands []string `json:"forbidden_commands,omitempty"`
+	// ForbiddenPaths are globs no file tool may touch ("*.pem", "/etc/*",
+	// "/home/agent/.ssh/"); a pattern without a slash matches the base name.
+	ForbiddenPaths []string `json:"forbidden_paths,omitempty"`
+	// WritablePaths are directories outside the workspace agents may write to.
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	// ForbiddenHosts may not be fetched, by web tools or in shell commands
+	// (subdomains included).
+	ForbiddenHosts []string `json:"forbidden_hosts,omitempty"`
+	// ProtectedBranches may not be pushed to (default main and master).
+	ProtectedBranches []string `json:"protected_branches,omitempty"`
+}
+
+// Board lists where `agentctl board --publish` sends the fleet board.
+type Board struct {
+	// Gist is the ID of an existing gist to overwrite.
+	Gist string `json:"gist,omitempty"`
+	// Wiki is "owner/repo" or "owner/repo:Page-Name".
+	Wiki string `json:"wiki,omitempty"`
+	// SlackWebhook is a Slack incoming-webhook URL.
+	SlackWebhook string `json:"slack_webhook,omitempty"`
+}
+
+// Toolchains controls the checks spawn makes that the image can build the
+// repo: that it has the runtimes go.mod, package.json and composer.json ask
+// for, and that it isn't too old.
+type Toolchains struct {
+	// Check is "fail" (the default: a missing runtime fails the spawn),
+	// "warn" or "off".
+	Check string `json:"check,omitempty"`
+	// MaxImageAge warns at spawn when the image was built longer ago than
+	// this, e.g. "30d"; unset, images are never called stale.
+	MaxImageAge string `json:"max_image_age,omitempty"`
+}
+
+// Spy sets display defaults for `agentctl spy`; command-line flags override them.
+type Spy struct {
+	// Theme is "default", "plain" (no color) or "compact" (one emoji per line).
+	Theme string `json:"theme,omitempty"`
+	// Width truncates every field to this many characters; -1 never truncates.
+	Width int `json:"width,omitempty"`
+	// Format is a Go template for each line (fields: .Time .Kind .Icon .Tool .Text).
+	Format string `json:"format,omitempty"`
+	// Diffs shows what each Edit, MultiEdit and Write call changes.
+	Diffs bool `json:"diffs,omitempty"`
+	// Record has the daemon record every running agent's session events to
+	// the store `agentctl analytics` reads.
+	Record bool `json:"record,omitempty"`
+	// MaxSize rotates the files `spy --out` and `--jsonl-out` append to
+	// once they grow past this size, and Keep is how many rotated files are
+	// kept; unset, they follow Logs.
+	MaxSize string `json:"max_size,omitempty"`
+	Keep    int    `json:"keep,omitempty"`
+}
+
+// Capacity keeps spawns from overloading the host: a spawn is refused, or
+// waits, while the host is over any limit set. Zero values are no limit.
+type Capacity struct {
+	// MaxCPU is the busiest the CPUs may be, in percent: the 1-minute load
+	// average over the number of cores.
+	MaxCPU float64 `json:"max_cpu,omitempty"`
+	// MaxMemory is the most memory in use, in percent.
+	MaxMemory float64 `json:"max_memory,omitempty"`
+	// MinFreeDisk is the least free space on podman's storage, e.g. "20G".
+	MinFreeDisk string `json:"min_free_disk,omitempty"`
+	// MaxAgents caps the agent containers running at once.
+	MaxAgents int `json:"max_agents,omitempty"`
+	// MaxAgentsPerRepo caps the agents at work on one repo, as its
+	// coordination bus counts them; agents with a dead heartbeat don't count.
+	MaxAgentsPerRepo int `json:"max_agents_per_repo,omitempty"`
+	// Poll is how often a waiting spawn checks again (default 15s).
+	Poll string `json:"poll,omitempty"`
+}
+
+// Retention sets how long `agentctl cleanup` keeps finished agents and their
+// history. Durations may use days ("3d"); unset grace periods default to an
+// hour.
+type Retention struct {
+	// Completed is the grace period of agents whose task is done.
+	Completed string `json:"completed,omitempty"`
+	// Failed is the grace period of agents whose run gave up.
+	Failed string `json:"failed,omitempty"`
+	// Killed is the grace period of containers that exited or were removed
+	// outside agentctl: killed, crashed or stopped by hand.
+	Killed string `json:"killed,omitempty"`
+	// MaxContainers caps the finished agents kept; the oldest go first.
+	MaxContainers int `json:"max_containers,omitempty"`
+	// History is how long history records are kept (default forever).
+	History string `json:"history,omitempty"`
+}
+
+// Quota sets default per-container storage limits for spawned agents. Sizes
+// use K/M/G/T suffixes ("2G").
+type Quota struct {
+	// Tmpfs mounts /tmp as a tmpfs of this size.
+	Tmpfs string `json:"tmpfs,omitempty"`
+	// Disk caps the container's writable layer (podman --storage-opt size=,
+	// which needs a storage driver that supports it, e.g. overlay on xfs).
+	Disk string `json:"disk,omitempty"`
+}
+
+// Tests controls the completion check's test run.
+type Tests struct {
+	// Runs is how many times a failing suite is executed to separate flaky
+	// tests from real failures (default 1: no re-runs).
+	Runs int `json:"runs,omitempty"`
+	// OutputLines is how much of a failing run's output goes into the retry
+	// prompt (default 40; -1 leaves it out).
+	OutputLines int `json:"output_lines,omitempty"`
+	// Coverage holds completion back when coverage is too low.
+	Coverage Coverage `json:"coverage,omitempty"`
+}
+
+// Coverage is the completion check's coverage gate. It is off unless Min or
+// CompareBase is set.
+type Coverage struct {
+	// Min is the lowest total coverage, in percent, an agent may finish with.
+	Min float64 `json:"min,omitempty"`
+	// CompareBase fails agents whose coverage is below their base branch's.
+	CompareBase bool `json:"compare_base,omitempty"`
+	// Tolerance is how many points below the base still pass.
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// Logs controls rotation of the logs agentctl writes inside the container
+// (claude.log, setup.log, tests.log).
+type Logs struct {
+	// MaxSize rotates a log to <log>.1 once it grows past this size
+	// (default 10M).
+	MaxSize string `json:"max_size,omitempty"`
+	// Keep is how many rotated files are kept (default 3).
+	Keep int `json:"keep,omitempty"`
+}
+
+// GitCache keeps a bare mirror of each repo on the host, under
+// ~/.agentctl/cache/git, so spawns clone locally instead of over the network.
+type GitCache struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Repos limits the cache to these repos ("owner/repo"); empty means all.
+	Repos []string `json:"repos,omitempty"`
+}
+
+// Triage sets the defaults of `agentctl triage`.
+type Triage struct {
+	// Label picks the issues agents may take (default "agent-ok").
+	Label string `json:"label,omitempty"`
+	// MaxDifficulty is the highest estimate, from 1 (trivial) to 5, that
+	// gets an agent (default 2).
+	MaxDifficulty int `json:"max_difficulty,omitempty"`
+	// Command estimates an issue, or predicts the files a task touches for
+	// `agentctl conflicts`, on the host, reading the prompt on stdin
+	// (default "claude -p").
+	Command string `json:"command,omitempty"`
+}
+
+// Egress limits where agents can connect: a restricted agent sits on an
+// internal podman network whose only way out is a proxy admitting the
+// allow-list.
+type Egress struct {
+	// Restrict applies the allow-list to every agent, not only those spawned
+	// with --egress or a restricting profile.
+	Restrict bool `json:"restrict,omitempty"`
+	// Allow adds hosts to the built-in list (GitHub, the LLM router,
+	// package registries); ".example.com" also covers subdomains.
+	Allow []string `json:"allow,omitempty"`
+	// ProxyImage is the squid image run as the proxy.
+	ProxyImage string `json:"proxy_image,omitempty"`
+}
+
+// Idle decides what becomes of an agent that went quiet mid-attempt: its
+// session hasn't been written to for After and no Claude (or opencode)
+// process runs, though the task wrapper still does.
+type Idle struct {
+	// After is the quiet period (default 15m, "off" to never call an agent
+	// idle).
+	After string `json:"after,omitempty"`
+	// Action is "complete" (default: the agent counts as completed, so
+	// cleanup treats it as finished) or "pause" (the daemon pauses it).
+	Action string `json:"action,omitempty"`
+}
+
+// RunLoop paces the run loop's attempts.
+type RunLoop struct {
+	// Settle is the pause between an attempt and its check (default 2s).
+	Settle string `json:"settle,omitempty"`
+	// Backoff is the pause after an attempt that left the task unfinished,
+	// doubled after each one in a row up to MaxBackoff (defaults 3s and 2m).
+	Backoff    string `json:"backoff,omitempty"`
+	MaxBackoff string `json:"max_backoff,omitempty"`
+	// RateLimit caps agent invocations per minute across every run in the
+	// namespace on this host, so a failing swarm can't hammer the API
+	// (default 0: no limit).
+	RateLimit int `json:"rate_limit,omitempty"`
+	// ContinueSession has every run resume the previous attempt's Claude
+	// session in each retry, like `run --continue-session`.
+	ContinueSession bool `json:"continue_session,omitempty"`
+	// Retry picks the strategy for a class of failed attempt ("tests",
+	// "lint", "merge_conflict", "dependency", "rate_limit", "agent_error",
+	// "unfinished"): "prompt", "setup", "backoff", "abort" or "retry".
+	// Classes not listed keep their defaults.
+	Retry map[string]string `json:"retry,omitempty"`
+}
+
+// Auth checks agents can reach their model before work depends on it; see
+// `agentctl verify-auth`.
+type Auth struct {
+	// Check is what a failed check at the start of a run does: "fail" (the
+	// default) stops the run, "warn" carries on, "off" turns every check
+	// off. Spawn only ever warns.
+	Check string `json:"check,omitempty"`
+	// Timeout bounds the probe invocation (default "2m").
+	Timeout string `json:"timeout,omitempty"`
+	// MaxAge is how long a passed probe is trusted before spawn and run
+	// probe again (default "1h"; "0" probes every time). verify-auth and
+	// a run's auth errors always probe.
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+// Stats configures `agentctl stats`.
+type Stats struct {
+	// Prices cost runs by backend (the model an agent ran with), with
+	// "default" for the rest; a backend with no price has no cost.
+	Prices map[string]Price `json:"prices,omitempty"`
+}
+
+// Price is what a backend charges, in dollars per million tokens. Cache
+// prices default to Input.
+type Price struct {
+	Input      float64  `json:"input"`
+	Output     float64  `json:"output"`
+	CacheRead  *float64 `json:"cache_read,omitempty"`
+	CacheWrite *float64 `json:"cache_write,omitempty"`
+}
+
+// Encryption keeps the files under ~/.agentctl that may hold sensitive data
+// — history records and recorded session events — encrypted at rest.
+// Encrypted files are read transparently whether or not it is enabled.
+type Encryption struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Keyring is the OS keyring entry holding the key, created on first use
+	// (default "agentctl"). AGENTCTL_ENCRYPTION_KEY, a base64 32-byte key,
+	// takes its place where there is no keyring.
+	Keyring string `json:"keyring,omitempty"`
+}
+
+// Workflows configures the built-in workflows (`agentctl workflow`).
+type Workflows struct {
+	Deps DepsWorkflow `json:"deps,omitempty"`
+}
+
+// DepsWorkflow configures `agentctl workflow deps`.
+type DepsWorkflow struct {
+	// Repos are upgraded when the command names none.
+	Repos []string `json:"repos,omitempty"`
+	// Ecosystems limits the upgrades to go, npm or composer (default all).
+	Ecosystems []string `json:"ecosystems,omitempty"`
+}
+
+// Forge describes a code host. github.com, and hosts with gitlab, gitea or
+// codeberg in their name, need no entry.
+type Forge struct {
+	Host string `json:"host"`
+	// Type is "github" (also for GitHub Enterprise), "gitlab" or "gitea".
+	Type string `json:"type"`
+	// APIURL overrides the API's address (default https://<host>/api/v4 for
+	// GitLab, /api/v1 for Gitea).
+	APIURL string `json:"api_url,omitempty"`
+	// TokenEnv names a variable holding the token, tried before the
+	// standard ones (GH_TOKEN, GITLAB_TOKEN, GITEA_TOKEN).
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+// Summary has the model write a short account of what an agent changed when
+// its run completes, for history and the PR.
+type Summary struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Command is run in the agent's workspace with the prompt on stdin and
+	// prints the summary (default "claude -p").
+	Command string `json:"command,omitempty"`
+	// UpdatePR also writes the summary into the body of the open PR for the
+	// agent's branch.
+	UpdatePR bool `json:"update_pr,omitempty"`
+}
+
+// Git sets the identity agents commit as, and how their commits are signed,
+// instead of whatever the image defaults to. Name, Email and Trailer may use
+// {agent} for the agent's name.
+type Git struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	// Trailer is added to every commit message, e.g.
+	// "Co-Authored-By: agentctl/{agent} <agents@example.com>".
+	Trailer string     `json:"trailer,omitempty"`
+	Signing GitSigning `json:"signing,omitempty"`
+}
+
+// GitSigning signs agent commits with a key mounted read-only from the host.
+type GitSigning struct {
+	// Format is "ssh" or "gpg"; empty leaves commits unsigned.
+	Format string `json:"format,omitempty"`
+	// Key is the private key file for ssh, or the key ID for gpg.
+	Key string `json:"key,omitempty"`
+	// GPGHome is the keyring holding a gpg key (default ~/.gnupg).
+	GPGHome string `json:"gpg_home,omitempty"`
+}
+
+// Integrity configures the sanity checks run on a workspace before agentctl
+// pushes it: conflict markers, .orig/.rej leftovers, debug prints and a build.
+type Integrity struct {
+	// Disabled skips the checks.
+	Disabled bool `json:"disabled,omitempty"`
+	// DebugPatterns are regular expressions no line added on the branch may
+	// match, e.g. `console\.log\(` or `\bdd\(`.
+	DebugPatterns []string `json:"debug_patterns,omitempty"`
+	// Build is the compile check, run in the workspace. By default it is
+	// detected: go build, cargo check or tsc --noEmit.
+	Build string `json:"build,omitempty"`
+	// SkipBuild leaves out the compile check.
+	SkipBuild bool `json:"skip_build,omitempty"`
+}
+
+// Commits controls how an agent's commits are tidied before a PR by
+// `agentctl squash` and the pipelines' squash step.
+type Commits struct {
+	// Policy is "keep" (default), "squash-fixups" (fold "fix", "wip",
+	// "fixup!" … commits into the commit before them) or "squash-all" (one
+	// commit whose message is written from the task and change summary).
+	Policy string `json:"policy,omitempty"`
+	// Repos overrides Policy per repo, keyed by "owner/repo".
+	Repos map[string]string `json:"repos,omitempty"`
+}
+
+// PolicyFor returns the commit policy for a repo ("owner/repo" or a URL).
+func (c Commits) PolicyFor(repo string) string {
+	if p, ok := c.Repos[RepoKey(repo)]; ok {
+		return p
+	}
+	if c.Policy == "" {
+		return "keep"
+	}
+	return c.Policy
+}
+
+// RepoKey reduces a GitHub URL or "owner/repo" to "owner/repo", the form
+// per-repo settings are keyed by.
+func RepoKey(repo string) string {
+	repo = strings.TrimSuffix(repo, ".git")
+	for _, prefix := range []string{"https://github.com/", "git@github.com:"} {
+		repo = strings.TrimPrefix(repo, prefix)
+	}
+	return repo
+}
+
+// WorkspaceFor returns the configured in-container workspace for a repo, or
+// "" to use the profile's or image's.
+func (c *Config) WorkspaceFor(repo string) string {
+	return c.Workspaces[RepoKey(repo)]
+}
+
+// Artifacts lists workspace paths copied out of an agent when its run
+// completes, into ~/.agentctl/artifacts/<agent>/.
+type Artifacts struct {
+	// Paths are files or directories, relative to the workspace unless
+	// absolute ("dist/", "coverage.html", "/tmp/build.log").
+	Paths []string `json:"paths,omitempty"`
+}
+
+// Runtime bounds podman invocations so a wedged runtime can't hang agentctl.
+// Durations use Go syntax ("45s", "1h").
+type Runtime struct {
+	// Timeout bounds quick calls: inspect, exec probes, cp, stop (default 30s).
+	Timeout string `json:"timeout,omitempty"`
+	// LongTimeout bounds container creation, clones, setup and test runs (default 30m).
+	LongTimeout string `json:"long_timeout,omitempty"`
+	// StatusCache is how long `list`, the board and the daemon API reuse
+	// container states (default 3s, "0" to always ask podman).
+	StatusCache string `json:"status_cache,omitempty"`
+}
+
+// Coordination controls how spawned agents reach the coordination bus.
+type Coordination struct {
+	// DisableMount stops Spawn from bind-mounting the agentctl binary and the
+	// repo's coordination directory into the container.
+	DisableMount bool `json:"disable_mount,omitempty"`
+	// Backend is where the bus lives: "file" (default, this host only),
+	// "git", which replicates it through a branch of the repo itself so
+	// agents on other machines share it, "redis", or "http": the bus of
+	// another host, reached through its `agentctl bus serve` gateway.
+	Backend string `json:"backend,omitempty"`
+	// Branch is the git backend's branch (default agentctl/coordination).
+	Branch string `json:"branch,omitempty"`
+	// URL is the redis backend's server, redis://[:password@]host:6379/0
+	// (rediss:// for TLS), or the http backend's gateway, http://host:8088.
+	// It must be reachable from inside the containers.
+	URL string `json:"url,omitempty"`
+	// Token is the bearer token `agentctl bus serve` requires and the http
+	// backend sends; best given as "${AGENTCTL_BUS_TOKEN}".
+	Token string `json:"token,omitempty"`
+	// ClaimEnforcement is what `agentctl run` does about the files an agent
+	// edits: it claims unclaimed ones on the agent's behalf and, on a file
+	// another agent claimed, "interrupt"s the attempt and tells the agent
+	// to leave the file alone (default), only "warn"s, or does nothing at
+	// all ("off").
+	ClaimEnforcement string `json:"claim_enforcement,omitempty"`
+	// Heartbeat is how often a run loop records that it is alive (default
+	// 1m, "off" to stop); on the git backend each beat is a commit.
+	Heartbeat string `json:"heartbeat,omitempty"`
+	// HeartbeatTimeout is how long a working agent may go unheard before it
+	// is treated as dead: its claims can be taken and the daemon removes it
+	// from the bus (default 5m).
+	HeartbeatTimeout string `json:"heartbeat_timeout,omitempty"`
+	// MessageTypes registers custom bus message types, or tightens the
+	// built-in ones, with the data fields they carry. Publishing a type that
+	// is neither built in nor listed here fails unless forced.
+	MessageTypes map[string]MessageSchema `json:"message_types,omitempty"`
+}
+
+// MessageSchema declares the data fields of a bus message type.
+type MessageSchema struct {
+	Description string   `json:"description,omitempty"`
+	Required    []string `json:"required,omitempty"`
+	Optional    []string `json:"optional,omitempty"`
+	// Open allows fields beyond Required and Optional; without it they are
+	// rejected, catching misspelt keys.
+	Open bool `json:"open,omitempty"`
+}
+
+// Telemetry exports traces of spawns and runs over OTLP/HTTP. The standard
+// OTEL_EXPORTER_OTLP_* and OTEL_SERVICE_NAME variables override it.
+type Telemetry struct {
+	// Endpoint is the collector's base URL, e.g. http://localhost:4318;
+	// spans go to its /v1/traces. Tracing is off without one.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent with every export, e.g. an API key.
+	Headers map[string]string `json:"headers,omitempty"`
+	// ServiceName is the service.name resource attribute (default agentctl).
+	ServiceName string `json:"service_name,omitempty"`
+}
+
+// Setup controls the post-clone bootstrap step run by Spawn.
+type Setup struct {
+	// Disabled skips setup entirely.
+	Disabled bool `json:"disabled,omitempty"`
+	// Commands overrides auto-detection with an explicit command list, run in
+	// order inside the workspace. A repo-provided script still takes precedence.
+	Commands []string `json:"commands,omitempty"`
+	// Script is the repo-relative setup script path (default .agentctl/setup.sh).
+	Script string `json:"script,omitempty"`
+}
+
+// Dir returns the agentctl home directory (~/.agentctl).
+func Dir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".agentctl")
+}
+
+// Path returns the path to ~/.agentctl/config.json.
+func Path() string {
+	return filepath.Join(Dir(), "config.json")
+}
+
+// Load reads the config file. A missing file yields an empty Config.
+func Load() (*Config, error) {
+	return LoadFile(Path())
+}
+
+// LoadFile reads a config from an explicit path. String values may reference
+// the environment (${GH_TOKEN}, ${WORKDIR:-/srv/work}) and start with ~, and
+// an "include" key pulls in shared base configs (e.g. from a dotfiles repo)
+// that this file then overrides.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	tree, err := loadTree(path, map[string]bool{})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFileMissing(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), "nope.json"))
+	if err != nil {
+		t.Fatalf("missing config should not error: %v", err)
+	}
+	if cfg.LLMKey != "" || len(cfg.Setup.Commands) != 0 {
+		t.Errorf("missing config should be empty, got %+v", cfg)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"llm_key":"k","setup":{"commands":["make deps"],"script":"bin/setup"}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error: %v", err)
+	}
+	if cfg.LLMKey != "k" {
+		t.Errorf("LLMKey = %q, want %q", cfg.LLMKey, "k")
+	}
+	if len(cfg.Setup.Commands) != 1 || cfg.Setup.Commands[0] != "make deps" {
+		t.Errorf("Setup.Commands = %v", cfg.Setup.Commands)
+	}
+	if cfg.Setup.Script != "bin/setup" {
+		t.Errorf("Setup.Script = %q", cfg.Setup.Script)
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte("{not json"), 0644)
+	if _, err := LoadFile(path); err == nil {
+		t.Error("expected parse error for invalid config")
+	}
+}
+
+func TestExpandString(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	t.Setenv("AGENTCTL_TEST_VAR", "value")
+	tests := []struct{ in, want string }{
+		{"${AGENTCTL_TEST_VAR}", "value"},
+		{"pre-${AGENTCTL_TEST_VAR}-post", "pre-value-post"},
+		{"${AGENTCTL_TEST_UNSET:-fallback}", "fallback"},
+		{"${AGENTCTL_TEST_UNSET}", ""},
+		{"$AGENTCTL_TEST_VAR", "$AGENTCTL_TEST_VAR"},
+		{"~/work", filepath.Join(home, "work")},
+		{"~" + string(filepath.Separator) + "work", filepath.Join(home, "work")},
+		{"~", home},
+		{"a~b", "a~b"},
+	}
+	for _, tt := range tests {
+		if got := ExpandString(tt.in); got != tt.want {
+			t.Errorf("ExpandString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFileInterpolatesEnv(t *testing.T) {
+	t.Setenv("AGENTCTL_TEST_KEY", "secret")
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"llm_key":"${AGENTCTL_TEST_KEY}","setup":{"script":"~/setup.sh"}}`), 0644)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error: %v", err)
+	}
+	if cfg.LLMKey != "secret" {
+		t.Errorf("LLMKey = %q, want %q", cfg.LLMKey, "secret")
+	}
+	if cfg.Setup.Script == "~/setup.sh" {
+		t.Error("~ should be expanded")
+	}
+}
+
+func TestLoadFileInclude(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "base.json"), []byte(`{"llm_key":"base","lexi_url":"http://base","setup":{"commands":["make deps"]}}`), 0644)
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"include":"base.json","llm_key":"local","setup":{"script":"bin/setup"}}`), 0644)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error: %v", err)
+	}
+	if cfg.LLMKey != "local" {
+		t.Errorf("including file should win, LLMKey = %q", cfg.LLMKey)
+	}
+	if cfg.LexiURL != "http://base" {
+		t.Errorf("included values should apply, LexiURL = %q", cfg.LexiURL)
+	}
+	if len(cfg.Setup.Commands) != 1 || cfg.Setup.Script != "bin/setup" {
+		t.Errorf("nested objects should deep-merge, Setup = %+v", cfg.Setup)
+	}
+}
+
+func TestLoadFileIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"include":"b.json"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"include":"a.json"}`), 0644)
+	if _, err := LoadFile(filepath.Join(dir, "a.json")); err == nil {
+		t.Error("expected include cycle error")
+	}
+}
+
+func TestLoadFilePipelines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"pipelines": {
+			"standard": ["implement", "review", "merge"],
+			"hotfix": {"steps": ["implement", "pr"], "params": {"task": "", "base": "main"}}
+		},
+		"pipeline_steps": {"lint": "make lint"}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error: %v", err)
+	}
+	if got := cfg.Pipelines["standard"].Steps; len(got) != 3 || got[2] != "merge" {
+		t.Errorf("standard steps = %v", got)
+	}
+	hotfix := cfg.Pipelines["hotfix"]
+	if len(hotfix.Steps) != 2 || hotfix.Params["base"] != "main" {
+		t.Errorf("hotfix = %+v", hotfix)
+	}
+	if _, ok := hotfix.Params["task"]; !ok {
+		t.Error("required param with empty default should be kept")
+	}
+	if cfg.PipelineSteps["lint"] != "make lint" {
+		t.Errorf("PipelineSteps = %v", cfg.PipelineSteps)
+	}
+}
+
+func TestCommitsPolicyFor(t *testing.T) {
+	c := Commits{Policy: "squash-fixups", Repos: map[string]string{"org/api": "squash-all"}}
+	for repo, want := range map[string]string{
+		"https://github.com/org/api.git": "squash-all",
+		"org/api":                        "squash-all",
+		"org/web":                        "squash-fixups",
+	} {
+		if got := c.PolicyFor(repo); got != want {
+			t.Errorf("PolicyFor(%q) = %q, want %q", repo, got, want)
+		}
+	}
+	if got := (Commits{}).PolicyFor("org/api"); got != "keep" {
+		t.Errorf("default policy = %q, want keep", got)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// envRef matches ${VAR} and ${VAR:-default}.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// ExpandString interpolates ${VAR} / ${VAR:-default} references from the
+// environment and expands a leading ~ to the user's home directory. Bare $VAR
+// is left alone so values that legitimately contain $ survive.
+func ExpandString(s string) string {
+	s = envRef.ReplaceAllStringFunc(s, func(m string) string {
+		parts := envRef.FindStringSubmatch(m)
+		if v, ok := os.LookupEnv(parts[1]); ok && v != "" {
+			return v
+		}
+		return parts[2]
+	})
+	return ExpandHome(s)
+}
+
+// ExpandHome replaces a leading "~" or "~/" (or "~\" on Windows) with the
+// user's home directory.
+func ExpandHome(s string) string {
+	if s != "~" && !strings.HasPrefix(s, "~/") && !strings.HasPrefix(s, "~"+string(filepath.Separator)) {
+		return s
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return s
+	}
+	return filepath.Join(home, strings.TrimPrefix(s, "~"))
+}
+
+// loadTree reads a config file into a generic tree, resolving its include
+// directive (a path or list of paths, relative to the including file) and
+// interpolating every string value. Included files are applied first so the
+// including file's values win.
+func loadTree(path string, seen map[string]bool) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if seen[abs] {
+		return nil, fmt.Errorf("config include cycle at %s", path)
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	expandTree(tree)
+
+	var includes []string
+	switch inc := tree["include"].(type) {
+	case string:
+		includes = []string{inc}
+	case []interface{}:
+		for _, v := range inc {
+			if s, ok := v.(string); ok {
+				includes = append(includes, s)
+			}
+		}
+	}
+	delete(tree, "include")
+
+	merged := map[string]interface{}{}
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(abs), inc)
+		}
+		sub, err := loadTree(inc, seen)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", inc, err)
+		}
+		mergeTree(merged, sub)
+	}
+	mergeTree(merged, tree)
+	return merged, nil
+}
+
+// expandTree interpolates every string value in place.
+func expandTree(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return ExpandString(t)
+	case map[string]interface{}:
+		for k, val := range t {
+			t[k] = expandTree(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = expandTree(val)
+		}
+	}
+	return v
+}
+
+// mergeTree deep-merges src into dst. Objects merge key by key; anything else
+// (including lists) is replaced wholesale.
+func mergeTree(dst, src map[string]interface{}) {
+	for k, sv := range src {
+		sm, sIsMap := sv.(map[string]interface{})
+		dm, dIsMap := dst[k].(map[string]interface{})
+		if sIsMap && dIsMap {
+			mergeTree(dm, sm)
+			continue
+		}
+		dst[k] = sv
+	}
+}
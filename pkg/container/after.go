@@ -0,0 +1,105 @@
+package container
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// dependencyPoll is how often a finished agent waiting on its dependencies
+// checks the bus for their merges.
+var dependencyPoll = 5 * time.Second
+
+// settledDependencies returns the bus messages that settle dependencies not
+// yet in done, one per dependency: its merge, or its removal without one.
+func settledDependencies(repoURL string, after, done []string) ([]coordination.Message, error) {
+	pending := map[string]bool{}
+	for _, dep := range after {
+		pending[dep] = true
+	}
+	for _, dep := range done {
+		delete(pending, dep)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	msgs, err := coordination.ReadMessages(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	var settled []coordination.Message
+	for _, msg := range msgs {
+		if !pending[msg.Agent] || (msg.Type != coordination.MsgMerged && msg.Type != coordination.MsgAgentRemoved) {
+			continue
+		}
+		settled = append(settled, msg)
+		delete(pending, msg.Agent)
+	}
+	return settled, nil
+}
+
+// pendingDependencies returns the dependencies in after not yet in done.
+func pendingDependencies(after, done []string) []string {
+	var pending []string
+	for _, dep := range after {
+		if !slices.Contains(done, dep) {
+			pending = append(pending, dep)
+		}
+	}
+	return pending
+}
+
+// mergeBase is the branch a dependency was merged into: the message's "base",
+// else the branch the dependency was spawned from, else main.
+func mergeBase(msg coordination.Message) string {
+	if base := msg.Data["base"]; base != "" {
+		return base
+	}
+	if dep, err := loadAgent(msg.Agent); err == nil && dep.Branch != "" {
+		return dep.Branch
+	}
+	return "main"
+}
+
+// dependencyNote tells the agent that work it builds on has been merged, or
+// dropped, and what to do about it. It is empty when nothing was merged.
+func dependencyNote(settled []coordination.Message) string {
+	var b strings.Builder
+	for _, msg := range settled {
+		if msg.Type != coordination.MsgMerged {
+			continue
+		}
+		base := mergeBase(msg)
+		fmt.Fprintf(&b, "\n\nIMPORTANT: %s's work, which yours builds on, has been merged into %s. "+
+			"Run 'git fetch origin && git rebase origin/%s', resolve any conflicts, make sure the tests still pass, and continue.",
+			msg.Agent, base, base)
+	}
+	return b.String()
+}
+
+// takeSettled records settled dependencies in the checkpoint and returns
+// the note for the agent.
+func takeSettled(cp *Checkpoint, settled []coordination.Message) string {
+	for _, msg := range settled {
+		cp.Settled = append(cp.Settled, msg.Agent)
+		if msg.Type == coordination.MsgMerged {
+			fmt.Printf("🔀 Dependency %s merged into %s\n", msg.Agent, mergeBase(msg))
+		} else {
+			fmt.Printf("⚠️  Dependency %s was removed without merging\n", msg.Agent)
+		}
+	}
+	return dependencyNote(settled)
+}
+
+// awaitDependencies blocks until at least one pending dependency settles.
+func awaitDependencies(repoURL string, after, done []string) []coordination.Message {
+	for {
+		if settled, err := settledDependencies(repoURL, after, done); err == nil && len(settled) > 0 {
+			return settled
+		}
+		time.Sleep(dependencyPoll)
+	}
+}
@@ -0,0 +1,75 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestSettledDependencies(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/test/after"
+	if _, err := coordination.Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	saveAgent(&Agent{Name: "api", Repo: repo, Branch: "develop"})
+	for _, m := range []coordination.Message{
+		{Type: coordination.MsgPushed, Agent: "api"},
+		{Type: coordination.MsgMerged, Agent: "other"},
+		{Type: coordination.MsgMerged, Agent: "api"},
+		{Type: coordination.MsgAgentRemoved, Agent: "db", Data: map[string]string{"reason": "killed"}},
+	} {
+		coordination.Publish(repo, m)
+	}
+
+	after := []string{"api", "db", "auth"}
+	settled, err := settledDependencies(repo, after, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range settled {
+		got = append(got, m.Agent+":"+string(m.Type))
+	}
+	if want := []string{"api:merged", "db:agent_removed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("settled = %v, want %v", got, want)
+	}
+
+	cp := &Checkpoint{}
+	note := takeSettled(cp, settled)
+	if !reflect.DeepEqual(cp.Settled, []string{"api", "db"}) {
+		t.Errorf("checkpoint settled = %v", cp.Settled)
+	}
+	// The merge base falls back to the branch the dependency was spawned from.
+	if !strings.Contains(note, "api's work") || !strings.Contains(note, "git rebase origin/develop") {
+		t.Errorf("note should ask for a rebase onto develop: %q", note)
+	}
+	if strings.Contains(note, "db") {
+		t.Errorf("a removed dependency has nothing to rebase onto: %q", note)
+	}
+
+	if pending := pendingDependencies(after, cp.Settled); !reflect.DeepEqual(pending, []string{"auth"}) {
+		t.Errorf("pending = %v, want [auth]", pending)
+	}
+	if again, _ := settledDependencies(repo, after, cp.Settled); len(again) != 0 {
+		t.Errorf("settled dependencies should not be reported twice: %v", again)
+	}
+}
+
+func TestMergeBase(t *testing.T) {
+	msg := coordination.Message{Type: coordination.MsgMerged, Agent: "gone", Data: map[string]string{"base": "release"}}
+	if got := mergeBase(msg); got != "release" {
+		t.Errorf("mergeBase = %q, want release", got)
+	}
+	msg.Data = nil
+	if got := mergeBase(msg); got != "main" {
+		t.Errorf("mergeBase without base or agent = %q, want main", got)
+	}
+}
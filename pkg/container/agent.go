@@ -1,14 +1,21 @@
 package container
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+	"github.com/jordanpartridge/agentctl/pkg/telemetry"
 )
 
 type Agent struct {
@@ -17,29 +24,89 @@ type Agent struct {
 	Port        int       `json:"port"`
 	Repo        string    `json:"repo"`
 	Branch      string    `json:"branch"`
+	BaseBranch  string    `json:"base_branch,omitempty"` // the repo's default branch, resolved at spawn
 	Image       string    `json:"image,omitempty"`
 	Status      string    `json:"status"`
 	Created     time.Time `json:"created"`
 	Intent      string    `json:"intent,omitempty"`
+
+	// Spawn settings recorded so the agent can be exported and reproduced.
+	Task          string   `json:"task,omitempty"`
+	SetupCommands []string `json:"setup_commands,omitempty"`
+	SkipSetup     bool     `json:"skip_setup,omitempty"`
+	NoCoordMount  bool     `json:"no_coordination_mount,omitempty"`
+	TmpfsSize     string   `json:"tmpfs_size,omitempty"`
+	DiskQuota     string   `json:"disk_quota,omitempty"`
+	Profile       string   `json:"profile,omitempty"`
+	Yolo          bool     `json:"yolo,omitempty"`
+	// Permissions are the Claude permission rules the agent runs under;
+	// nil means it skips permission checks.
+	Permissions *config.Permissions `json:"permissions,omitempty"`
+	// After lists the agents whose merges this agent waits for: once its
+	// task is done, run waits until they are merged, then has it rebase onto
+	// the new base and finish.
+	After []string `json:"after,omitempty"`
+	// Needs lists the agents whose runs must complete before this agent's
+	// run starts.
+	Needs []string `json:"needs,omitempty"`
+	// Layout records the in-container home, workspace and user.
+	Layout Layout `json:"layout,omitempty"`
+
+	// Paused is set by Pause; ResumeStatus is the coordination status to
+	// restore on Resume.
+	Paused       bool   `json:"paused,omitempty"`
+	ResumeStatus string `json:"resume_status,omitempty"`
+
+	Violations []PolicyViolation `json:"violations,omitempty"`
+	Notes      []Note            `json:"notes,omitempty"`
+	// Instructions are the `tell` messages delivered to the agent's runs.
+	Instructions []Instruction `json:"instructions,omitempty"`
+	// Summary is the latest account of its change written by Summarize.
+	Summary string `json:"summary,omitempty"`
+	// Egress is the allow-list the agent's network is held to; empty means
+	// unrestricted.
+	Egress []string `json:"egress,omitempty"`
+	// Issue is the number of the forge issue the agent was spawned for.
+	Issue int `json:"issue,omitempty"`
+	// CoverageBase caches the coverage of the branch's base for the
+	// coverage gate.
+	CoverageBase *BaseCoverage `json:"coverage_base,omitempty"`
+	// TestReports are the test results of the current run's attempts.
+	TestReports []AttemptTests `json:"test_reports,omitempty"`
+	// Scope is the files the agent's task is predicted to touch, recorded
+	// by PredictConflicts.
+	Scope []string `json:"scope,omitempty"`
+	// Template names where the task came from — a built-in workflow
+	// ("deps", "release-notes", "triage") or "schedule:<id>" — and Model
+	// the backend it ran with; `agentctl stats` groups runs by them.
+	Template string `json:"template,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// References are the read-only references mounted into the container,
+	// listed in every attempt's prompt.
+	References []Reference `json:"references,omitempty"`
+	// AuthVerified is when the auth probe last got a reply, so checks
+	// soon after can trust it rather than pay for another.
+	AuthVerified time.Time `json:"auth_verified,omitempty"`
 }
 
 const DefaultImage = "agent-devbox:latest"
 
 // cacheDir returns the path to the shared cache directory on the host
 func cacheDir() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".agentctl", "cache")
+	return filepath.Join(config.Dir(), "cache")
+}
+
+// cacheKinds are the per-toolchain subdirectories of the shared cache.
+var cacheKinds = []string{
+	"composer",
+	"npm",
+	"go-mod",
+	"pip",
 }
 
 // ensureCacheDirs creates the shared cache directories on the host if they don't exist
 func ensureCacheDirs() error {
-	dirs := []string{
-		"composer",
-		"npm",
-		"go-mod",
-		"pip",
-	}
-	for _, d := range dirs {
+	for _, d := range cacheKinds {
 		if err := os.MkdirAll(filepath.Join(cacheDir(), d), 0755); err != nil {
 			return fmt.Errorf("failed to create cache dir %s: %w", d, err)
 		}
@@ -47,17 +114,88 @@ func ensureCacheDirs() error {
 	return nil
 }
 
-// SpawnWithIntent creates a new agent container with the given repo cloned and an intent description.
-func SpawnWithIntent(name, repo, branch, intent, image string) (*Agent, error) {
-	agent, err := Spawn(name, repo, branch, image)
-	if err != nil {
-		return nil, err
+// SpawnOptions describes a new agent container.
+type SpawnOptions struct {
+	Name   string
+	Repo   string
+	Branch string // must exist; empty means the repo's default branch
+	Image  string
+	Intent string
+
+	// SkipSetup disables the post-clone bootstrap step.
+	SkipSetup bool
+	// SetupCommands replaces the configured/auto-detected setup commands.
+	SetupCommands []string
+	// NoCoordMount skips mounting agentctl and the coordination bus, like
+	// coordination.disable_mount in config but for this agent only.
+	NoCoordMount bool
+	// TmpfsSize and DiskQuota override quota.tmpfs / quota.disk from config.
+	TmpfsSize string
+	DiskQuota string
+	// Profile selects a config profile: image, home, workspace and user.
+	Profile string
+	// Workspace overrides where the repo is cloned inside the container.
+	Workspace string
+	// Model overrides AGENT_LLM_MODEL from the host environment.
+	Model string
+	// After names agents whose work this one builds on; see Agent.After.
+	After []string
+	// Needs names agents whose runs must complete first; see Agent.Needs.
+	Needs []string
+	// RestrictEgress limits the agent's network to the egress allow-list;
+	// AllowHosts adds to the list (and implies RestrictEgress).
+	RestrictEgress bool
+	AllowHosts     []string
+	// Issue links the agent to the issue it works on; see Agent.Issue.
+	Issue int
+	// WaitForCapacity waits while the host is over a config.Capacity limit
+	// instead of failing with ErrNoCapacity.
+	WaitForCapacity bool
+	// Yolo runs Claude with --dangerously-skip-permissions even when the
+	// profile sets permission rules.
+	Yolo bool
+	// Template names the workflow or schedule the agent's task comes from;
+	// see Agent.Template.
+	Template string
+	// SkipToolchainCheck spawns without checking the image has the
+	// runtimes the repo needs, whatever toolchains.check says.
+	SkipToolchainCheck bool
+	// NoReferences spawns without the configured references.
+	NoReferences bool
+}
+
+// quotaArgs returns the podman run flags for the agent's storage limits,
+// filling unset options from config so the saved agent records what was used.
+func quotaArgs(opts *SpawnOptions, q config.Quota) ([]string, error) {
+	if opts.TmpfsSize == "" {
+		opts.TmpfsSize = q.Tmpfs
 	}
-	if intent != "" {
-		agent.Intent = intent
-		saveAgent(agent)
+	if opts.DiskQuota == "" {
+		opts.DiskQuota = q.Disk
 	}
-	return agent, nil
+	// Podman gets the parsed byte count: ParseSize takes "1.5G" or "512MiB",
+	// which tmpfs and storage-opt don't.
+	var args []string
+	if opts.TmpfsSize != "" {
+		n, err := ParseSize(opts.TmpfsSize)
+		if err != nil {
+			return nil, fmt.Errorf("tmpfs quota: %w", err)
+		}
+		args = append(args, "--tmpfs", "/tmp:rw,size="+strconv.FormatInt(n, 10))
+	}
+	if opts.DiskQuota != "" {
+		n, err := ParseSize(opts.DiskQuota)
+		if err != nil {
+			return nil, fmt.Errorf("disk quota: %w", err)
+		}
+		args = append(args, "--storage-opt", "size="+strconv.FormatInt(n, 10))
+	}
+	return args, nil
+}
+
+// SpawnWithIntent creates a new agent container with the given repo cloned and an intent description.
+func SpawnWithIntent(name, repo, branch, intent, image string) (*Agent, error) {
+	return SpawnWithOptions(SpawnOptions{Name: name, Repo: repo, Branch: branch, Image: image, Intent: intent})
 }
 
 // resolveLLMKey returns the mesh LLM router key for containers: AGENT_LLM_KEY
@@ -66,96 +204,230 @@ func resolveLLMKey() string {
 	if key := os.Getenv("AGENT_LLM_KEY"); key != "" {
 		return key
 	}
-	home, err := os.UserHomeDir()
+	cfg, err := config.Load()
 	if err != nil {
 		return ""
 	}
-	data, err := os.ReadFile(filepath.Join(home, ".agentctl", "config.json"))
-	if err != nil {
-		return ""
-	}
-	var cfg struct {
-		LLMKey string `json:"llm_key"`
-	}
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return ""
-	}
 	return cfg.LLMKey
 }
 
 // Spawn creates a new agent container with the given repo cloned
 func Spawn(name, repo, branch, image string) (*Agent, error) {
+	return SpawnWithOptions(SpawnOptions{Name: name, Repo: repo, Branch: branch, Image: image})
+}
+
+// SpawnWithOptions creates a new agent container, clones the repo, and runs
+// the post-clone setup step. If setup fails the agent is still saved (so it
+// can be inspected with shell/logs) and a *SetupError is returned.
+func SpawnWithOptions(opts SpawnOptions) (*Agent, error) {
+	span := telemetry.Start(nil, "agentctl.spawn", "agent.name", opts.Name, "agent.repo", opts.Repo,
+		"agent.branch", opts.Branch, "agent.image", opts.Image, "agent.profile", opts.Profile)
+	restore := traceAgent(opts.Name, span)
+	agent, err := spawn(opts)
+	restore()
+	result := "ok"
+	var setupErr *SetupError
+	if errors.As(err, &setupErr) {
+		result = "setup_failed"
+	} else if err != nil {
+		result = "failed"
+	}
+	span.Set("agent.result", result)
+	span.End(err)
+	return agent, err
+}
+
+func spawn(opts SpawnOptions) (*Agent, error) {
+	name, repo, branch, image := opts.Name, opts.Repo, opts.Branch, opts.Image
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNeeds(name, opts.Needs, savedNeeds); err != nil {
+		return nil, err
+	}
+
+	// Hold the admission until the container runs, so the next spawn's
+	// check counts it.
+	admitted, err := admit(cfg.Capacity, repo, opts.WaitForCapacity)
+	if err != nil {
+		return nil, err
+	}
+	defer admitted()
+
 	rand.Seed(time.Now().UnixNano())
-	port := 8000 + rand.Intn(1000)
+	port := namespace.PortBase() + rand.Intn(namespace.PortRange)
 
 	// Ensure shared cache directories exist on host
 	if err := ensureCacheDirs(); err != nil {
 		return nil, fmt.Errorf("cache setup failed: %w", err)
 	}
 
-	// Get GitHub token — check both common names then fall back to gh CLI
-	ghToken := os.Getenv("GH_TOKEN")
-	if ghToken == "" {
-		ghToken = os.Getenv("GITHUB_TOKEN")
+	// Get the forge's token — GH_TOKEN, GITLAB_TOKEN or GITEA_TOKEN, as the
+	// repo's host expects, or the gh CLI's
+	host := forge.For(repo)
+	token := host.Token()
+
+	// Pulled first, so the layout comes from the image's own labels.
+	if image, err = resolveImage(cfg, opts.Profile, image); err != nil {
+		return nil, err
 	}
-	if ghToken == "" {
-		out, err := exec.Command("gh", "auth", "token").Output()
-		if err == nil {
-			ghToken = strings.TrimSpace(string(out))
-		}
+	if err := ensureImage(cfg, image); err != nil {
+		return nil, err
+	}
+	layout, image, err := resolveLayout(cfg, opts.Profile, image)
+	if err != nil {
+		return nil, err
+	}
+	warnStaleImage(cfg.Toolchains, image)
+	if layout, err = layout.withWorkspace(cfg, repo, opts.Workspace); err != nil {
+		return nil, err
 	}
 
-	if image == "" {
-		image = DefaultImage
+	// A restricted agent publishes no shim port — an internal network has no
+	// route from the host — and is probed through podman exec instead.
+	var egress, network []string
+	if egressRestricted(cfg, opts) {
+		egress = egressHosts(cfg, opts)
+		if network, err = startEgress(name, cfg.Egress, egress); err != nil {
+			return nil, fmt.Errorf("spawn failed: %w", err)
+		}
+		port = 0
+		fmt.Printf("🧱 %s can reach %d allowed host(s) only\n", name, len(egress))
 	}
 
 	cache := cacheDir()
 	args := []string{
 		"run", "-d",
-		"--name", name,
-		"-p", fmt.Sprintf("%d:8080", port),
-		"-e", fmt.Sprintf("GH_TOKEN=%s", ghToken),
+		"--name", containerName(name),
+		"-e", fmt.Sprintf("%s=%s", host.TokenEnv(), token),
+	}
+	if port != 0 {
+		args = append(args, "-p", fmt.Sprintf("%d:%d", port, ShimPort))
+	}
+	args = append(args, network...)
+	if ns := namespace.Current(); ns != namespace.Default {
+		args = append(args, "--label", "agentctl.namespace="+ns)
+	}
+	if layout.User != "" {
+		args = append(args, "--user", layout.User)
+	}
+	if layout.UserNS != "" {
+		args = append(args, "--userns", layout.UserNS)
 	}
 	// LLM router credentials + overrides for the image's run-task.
 	// The key never lives in the image: host env wins, then ~/.agentctl/config.json llm_key.
-	if llmKey := resolveLLMKey(); llmKey != "" {
-		args = append(args, "-e", fmt.Sprintf("AGENT_LLM_KEY=%s", llmKey))
-	}
+	keyArgs, keyEnv := llmKeyEnv()
+	args = append(args, keyArgs...)
 	for _, key := range []string{"AGENT_LLM_BASE_URL", "AGENT_LLM_MODEL", "AGENT_LLM_FAST_MODEL"} {
-		if v := os.Getenv(key); v != "" {
+		v := os.Getenv(key)
+		if key == "AGENT_LLM_MODEL" && opts.Model != "" {
+			v = opts.Model
+		}
+		if v != "" {
 			args = append(args, "-e", fmt.Sprintf("%s=%s", key, v))
 		}
 	}
-	args = append(args,
-		"-v", fmt.Sprintf("%s/composer:/home/agent/.cache/composer:z", cache),
-		"-v", fmt.Sprintf("%s/npm:/home/agent/.cache/npm:z", cache),
-		"-v", fmt.Sprintf("%s/go-mod:/home/agent/.cache/go-mod:z", cache),
-		"-v", fmt.Sprintf("%s/pip:/home/agent/.cache/pip:z", cache),
-		image,
-	)
+	rules := agentPermissions(cfg, opts)
+	args = append(args, "-e", PermissionsEnv+"="+permissionsMode(rules))
+	for _, kind := range cacheKinds {
+		args = append(args, "-v", fmt.Sprintf("%s:%s:z", filepath.Join(cache, kind), layout.Path(".cache/"+kind)))
+	}
+	quota, err := quotaArgs(&opts, cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, quota...)
+	if !cfg.Coordination.DisableMount && !opts.NoCoordMount {
+		args = append(args, coordinationMounts(name, repo, layout)...)
+	}
+	signing, err := gitSigningMounts(cfg.Git.Signing)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, signing...)
+	var references []Reference
+	if !opts.NoReferences {
+		var mounts []string
+		mounts, references = referenceMounts(cfg.References, repo)
+		args = append(args, mounts...)
+	}
+	mirror := false
+	if useGitCache(cfg.GitCache, repo) {
+		if path, err := ensureMirror(repo, forge.AuthEnv(host, repo, token)); err != nil {
+			fmt.Printf("⚠️  Git cache unavailable, cloning directly: %v\n", err)
+		} else {
+			args = append(args, "-v", fmt.Sprintf("%s:%s:ro,z", path, containerMirrorPath))
+			mirror = true
+		}
+	}
+	args = append(args, image)
 
-	cmd := exec.Command("podman", args...)
+	cmd := podmanLong(args...)
+	cmd.Env = keyEnv
 	out, err := cmd.Output()
+	if err == nil && repo != "" {
+		// On the bus from the start, the agent takes its repo slot before
+		// its run loop begins.
+		if _, initErr := coordination.Init(repo); initErr == nil {
+			coordination.UpdateAgentState(repo, name, coordination.StatusSpawned, branch)
+		}
+	}
+	admitted()
 	if err != nil {
+		if egress != nil {
+			removeEgress(name)
+		}
 		return nil, fmt.Errorf("spawn failed: %w", err)
 	}
 
 	containerID := strings.TrimSpace(string(out))
 	time.Sleep(2 * time.Second)
+	startShim(name)
 
 	// No Claude config is copied in: the CLI authenticates to the mesh router
 	// via AGENT_LLM_KEY, and copying host ~/.claude would leak session
 	// transcripts and fire host hooks inside the container.
+	// An agent that can't be held to its rules must not run without them.
+	if rules != nil {
+		if err := writeClaudeSettings(name, rules); err != nil {
+			podman("rm", "-f", containerName(name)).Run()
+			removeEgress(name)
+			return nil, fmt.Errorf("spawn failed: cannot write Claude permissions: %w", err)
+		}
+		fmt.Printf("🔒 %s runs under %d allow and %d deny rule(s)\n", name, len(rules.Allow), len(rules.Deny))
+	}
 
 	// Clone the repository if provided
+	var base string
 	if repo != "" {
-		cloneURL := repo
-		if ghToken != "" && strings.HasPrefix(repo, "https://") {
-			cloneURL = strings.Replace(repo, "https://", fmt.Sprintf("https://%s@", ghToken), 1)
+		cloneRepo(name, forge.CloneURL(host, repo, token), layout, mirror)
+		base, branch, err = checkoutBranch(name, repo, branch, layout)
+		if err != nil {
+			podman("rm", "-f", containerName(name)).Run()
+			removeEgress(name)
+			return nil, fmt.Errorf("spawn failed: %w", err)
+		}
+		fmt.Printf("🌿 %s on %s (base %s)\n", name, branch, base)
+		// Better to fail here than to spend attempts finding out.
+		if err := verifyToolchains(cfg, opts, image, layout); err != nil {
+			podman("rm", "-f", containerName(name)).Run()
+			removeEgress(name)
+			return nil, fmt.Errorf("spawn failed: %w", err)
+		}
+		// Agent commits must be attributable (and, when configured,
+		// signed): a failure here is a failed spawn.
+		if err := configureGit(name, cfg.Git, layout); err != nil {
+			podman("rm", "-f", containerName(name)).Run()
+			removeEgress(name)
+			return nil, fmt.Errorf("spawn failed: %w", err)
+		}
+		// With the bus mounted, the agent's pushes announce themselves.
+		if !cfg.Coordination.DisableMount && !opts.NoCoordMount {
+			if err := installPushHook(name, repo, layout); err != nil {
+				fmt.Printf("⚠️  Push hook not installed, pushes won't reach the bus on their own: %v\n", err)
+			}
 		}
-		exec.Command("podman", "exec", name, "git", "clone", cloneURL, "/home/agent/workspace/repo").Run()
-		exec.Command("podman", "exec", name, "sh", "-c",
-			fmt.Sprintf("cd /home/agent/workspace/repo && git checkout %s 2>/dev/null || true", branch)).Run()
 	}
 
 	agent := &Agent{
@@ -164,40 +436,126 @@ func Spawn(name, repo, branch, image string) (*Agent, error) {
 		Port:        port,
 		Repo:        repo,
 		Branch:      branch,
+		BaseBranch:  base,
 		Image:       image,
 		Status:      "running",
 		Created:     time.Now(),
+		Intent:      opts.Intent,
+
+		SetupCommands: opts.SetupCommands,
+		SkipSetup:     opts.SkipSetup,
+		NoCoordMount:  opts.NoCoordMount,
+		TmpfsSize:     opts.TmpfsSize,
+		DiskQuota:     opts.DiskQuota,
+		Profile:       opts.Profile,
+		Yolo:          opts.Yolo,
+		Permissions:   rules,
+		After:         opts.After,
+		Needs:         opts.Needs,
+		Layout:        layout,
+		Egress:        egress,
+		Issue:         opts.Issue,
+		Template:      opts.Template,
+		Model:         agentBackend(opts),
+		References:    references,
 	}
 	saveAgent(agent)
+
+	// A spawn is worth keeping even when auth fails: verify-auth can
+	// refresh it later, and run checks again before relying on it.
+	checkAuth(name, false)
+
+	if repo != "" && !opts.SkipSetup {
+		if err := runSetup(name, cfg.Setup, opts.SetupCommands); err != nil {
+			return agent, err
+		}
+	}
 	return agent, nil
 }
 
+// MergePR squash-merges (or queues auto-merge for) the PR of the agent's
+// current branch.
+func MergePR(name string) (string, error) {
+	if _, err := loadAgent(name); err != nil {
+		return "", err
+	}
+	out, err := podmanLong("exec", containerName(name), "sh", "-c",
+		layoutOf(name).cd()+"gh pr merge --squash --auto").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("merge failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Push pushes the agent's current branch to origin. With verify set (and
+// integrity checks not disabled in the config) a workspace that fails
+// CheckIntegrity is not pushed and an *IntegrityError is returned.
+func Push(name string, verify bool) error {
+	return push(name, verify, "git push origin HEAD")
+}
+
+// ForcePush pushes a rewritten branch (e.g. after Squash) with
+// --force-with-lease, after the same integrity checks as Push.
+func ForcePush(name string) error {
+	return push(name, true, "git push --force-with-lease origin HEAD")
+}
+
+func push(name string, verify bool, command string) error {
+	if _, err := loadAgent(name); err != nil {
+		return err
+	}
+	if cfg, err := config.Load(); err == nil && verify && !cfg.Integrity.Disabled {
+		problems, err := CheckIntegrity(name, cfg.Integrity)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return &IntegrityError{Problems: problems}
+		}
+	}
+	out, err := podmanLong("exec", containerName(name), "sh", "-c",
+		layoutOf(name).cd()+command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("push failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // Kill stops and removes an agent container
 func Kill(name string) error {
-	exec.Command("podman", "stop", name).Run()
-	exec.Command("podman", "rm", name).Run()
-	os.Remove(agentMetaPath(name))
+	agent, err := loadAgent(name)
+	if err == nil && agent.Paused {
+		podman("unpause", containerName(name)).Run()
+	}
+	podman("stop", containerName(name)).Run()
+	podman("rm", containerName(name)).Run()
+	if err == nil && len(agent.Egress) > 0 {
+		removeEgress(name)
+	}
+	if err == nil {
+		if released := leaveBus(agent, "killed"); len(released) > 0 {
+			fmt.Printf("🔓 Released %d claim(s): %s\n", len(released), strings.Join(released, ", "))
+		}
+	}
+	deleteAgent(name)
+	removeCheckpoint(name)
+	removeInstructions(name)
 	fmt.Printf("Killed: %s\n", name)
 	return nil
 }
 
-// List returns all managed agents
+// List returns all managed agents with their container status.
 func List() ([]*Agent, error) {
-	entries, _ := os.ReadDir(agentDir())
-	var agents []*Agent
-	for _, e := range entries {
-		if !strings.HasSuffix(e.Name(), ".json") {
-			continue
-		}
-		data, _ := os.ReadFile(filepath.Join(agentDir(), e.Name()))
-		var agent Agent
-		json.Unmarshal(data, &agent)
-		out, _ := exec.Command("podman", "inspect", "-f", "{{.State.Status}}", agent.Name).Output()
-		agent.Status = strings.TrimSpace(string(out))
-		if agent.Status == "" {
-			agent.Status = "stopped"
+	agents := loadAgents()
+	statuses, err := containerStatuses()
+	if errors.Is(err, ErrRuntimeUnresponsive) {
+		return nil, err
+	}
+	for _, a := range agents {
+		a.Status = statuses[containerName(a.Name)]
+		if a.Status == "" {
+			a.Status = "stopped"
 		}
-		agents = append(agents, &agent)
 	}
 	return agents, nil
 }
@@ -208,51 +566,94 @@ func Status(name string) error {
 	if err != nil {
 		return err
 	}
-	out, _ := exec.Command("podman", "inspect", "-f", "{{.State.Status}}", name).Output()
+	out, err := podmanRetry("inspect", "-f", "{{.State.Status}}", containerName(name))
+	if errors.Is(err, ErrRuntimeUnresponsive) {
+		return err
+	}
 	fmt.Printf("Agent: %s\n", agent.Name)
 	fmt.Printf("Status: %s\n", strings.TrimSpace(string(out)))
 	fmt.Printf("Port: %d\n", agent.Port)
 	fmt.Printf("Repo: %s\n", agent.Repo)
 	fmt.Printf("Branch: %s\n", agent.Branch)
+	if agent.Intent != "" {
+		fmt.Printf("Intent: %s\n", agent.Intent)
+	}
+	if agent.BaseBranch != "" {
+		fmt.Printf("Base: %s\n", agent.BaseBranch)
+	}
 	fmt.Printf("Created: %s\n", agent.Created.Format(time.RFC3339))
-	taskRun, _ := exec.Command("podman", "exec", name, "sh", "-c", "pgrep -f run-task || pgrep -f opencode || true").Output()
-	if strings.TrimSpace(string(taskRun)) != "" {
-		fmt.Println("task: running")
+	if len(agent.Egress) > 0 {
+		fmt.Printf("Egress: restricted to %s\n", strings.Join(agent.Egress, ", "))
+	}
+	for _, v := range agent.Violations {
+		fmt.Printf("Policy violation: %s %s\n", v.Time.Format(time.RFC3339), v.String())
+	}
+	for _, n := range agent.Notes {
+		fmt.Printf("Note: %s\n", n)
+	}
+	cp, cpErr := LoadCheckpoint(name)
+	running := LoadRunLock(name)
+	if running != nil && running.stale() {
+		running = nil
+	}
+	if cpErr == nil && running == nil {
+		fmt.Printf("Run checkpoint: attempt %d/%d finished (%s); if the run was interrupted: agentctl run --resume %s\n", cp.Attempt, cp.MaxAttempts, cp.LastStatus, name)
+	}
+	attempt := 0
+	if cpErr == nil {
+		attempt = cp.Attempt + 1
+	}
+	if st, err := probeShim(name); err == nil {
+		if st.Running() {
+			fmt.Printf("task: running (pid %d)\n", st.PID)
+		} else {
+			fmt.Println("task: exited")
+		}
+		if st.Attempt > 0 {
+			attempt = st.Attempt
+		}
+		if !st.LastActivity.IsZero() {
+			fmt.Printf("Last activity: %s ago\n", time.Since(st.LastActivity).Round(time.Second))
+		}
 	} else {
-		fmt.Println("task: exited")
+		taskRun, _ := podman("exec", containerName(name), "sh", "-c", "pgrep -f run-task || pgrep -f opencode || true").Output()
+		if strings.TrimSpace(string(taskRun)) != "" {
+			fmt.Println("task: running")
+		} else {
+			fmt.Println("task: exited")
+		}
+	}
+	if running != nil {
+		progress := "running"
+		if attempt > 0 {
+			progress = fmt.Sprintf("attempt %d", attempt)
+			if cpErr == nil {
+				progress += fmt.Sprintf("/%d", cp.MaxAttempts)
+			}
+		}
+		fmt.Printf("Run: %s, %s elapsed (pid %d on %s)\n", progress, time.Since(running.Started).Round(time.Second), running.PID, running.Host)
+	} else if attempt > 0 {
+		fmt.Printf("Attempt: %d\n", attempt)
 	}
-	if _, err := exec.Command("podman", "exec", name, "test", "-f", "/home/agent/task.log").CombinedOutput(); err == nil {
-		last, _ := exec.Command("podman", "exec", name, "tail", "-3", "/home/agent/task.log").Output()
+	if a, err := LatestActivity(name); err == nil {
+		if a.Message != "" {
+			fmt.Printf("Last message: %s%s\n", a.Message, agoSuffix(a.Said))
+		}
+		if a.Action != "" {
+			fmt.Printf("Last action: %s%s\n", a.Action, agoSuffix(a.ActionAt))
+		}
+	}
+	taskLog := layoutOf(name).Path("task.log")
+	if _, err := podman("exec", containerName(name), "test", "-f", taskLog).CombinedOutput(); err == nil {
+		last, _ := podman("exec", containerName(name), "tail", "-3", taskLog).Output()
 		fmt.Printf("task.log tail:\n%s", last)
 	}
 	return nil
 }
 
-// Logs shows Claude logs from the agent
-func Logs(name string) error {
-	if _, err := exec.Command("podman", "exec", name, "test", "-f", "/home/agent/task.log").CombinedOutput(); err == nil {
-		cmd := exec.Command("podman", "exec", name, "tail", "-50", "/home/agent/task.log")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
-	}
-	cmd := exec.Command("podman", "exec", name, "cat", "/home/agent/claude.log")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-// LogsFollow streams Claude logs from the agent in real-time using tail -f
-func LogsFollow(name string) error {
-	cmd := exec.Command("podman", "exec", name, "tail", "-f", "/home/agent/claude.log")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 // Shell opens an interactive shell in the agent container
 func Shell(name string) error {
-	cmd := exec.Command("podman", "exec", "-it", name, "/bin/bash")
+	cmd := exec.Command("podman", "exec", "-it", containerName(name), "/bin/bash")
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -274,39 +675,40 @@ func Diagnose(name string) (*DiagnoseInfo, error) {
 	info := &DiagnoseInfo{
 		AuthFiles: make(map[string]bool),
 	}
+	layout := layoutOf(name)
 
 	// Get running processes
-	out, _ := exec.Command("podman", "exec", name, "ps", "aux").Output()
+	out, _ := podman("exec", containerName(name), "ps", "aux").Output()
 	info.Processes = strings.TrimSpace(string(out))
 
 	// Check if Claude is running
-	out, _ = exec.Command("podman", "exec", name, "sh", "-c",
+	out, _ = podman("exec", containerName(name), "sh", "-c",
 		"ps aux 2>/dev/null | grep -v grep | grep claude || true").Output()
 	info.ClaudeRunning = len(strings.TrimSpace(string(out))) > 0
 
 	// Get last 20 lines of error logs
-	out, _ = exec.Command("podman", "exec", name, "sh", "-c",
-		"tail -20 /home/agent/claude.log 2>/dev/null || echo 'No log file found'").Output()
+	out, _ = podman("exec", containerName(name), "sh", "-c",
+		"tail -20 "+layout.Path("claude.log")+" 2>/dev/null || echo 'No log file found'").Output()
 	info.ErrorLogs = strings.TrimSpace(string(out))
 
 	// Check if auth files exist
 	authChecks := map[string]string{
-		".claude.json": "/home/agent/.claude.json",
-		".claude/":     "/home/agent/.claude",
+		".claude.json": layout.Path(".claude.json"),
+		".claude/":     layout.Path(".claude"),
 	}
 	for label, path := range authChecks {
-		err := exec.Command("podman", "exec", name, "test", "-e", path).Run()
+		err := podman("exec", containerName(name), "test", "-e", path).Run()
 		info.AuthFiles[label] = err == nil
 	}
 
 	// Get disk space
-	out, _ = exec.Command("podman", "exec", name, "df", "-h", "/home/agent").Output()
+	out, _ = podman("exec", containerName(name), "df", "-h", layout.Home).Output()
 	info.DiskSpace = strings.TrimSpace(string(out))
 
 	// Check available tools
 	tools := []string{"claude", "git", "gh", "node", "npm", "go", "python3", "cargo"}
 	for _, tool := range tools {
-		err := exec.Command("podman", "exec", name, "which", tool).Run()
+		err := podman("exec", containerName(name), "which", tool).Run()
 		if err == nil {
 			info.AvailableTools = append(info.AvailableTools, tool)
 		}
@@ -315,32 +717,14 @@ func Diagnose(name string) (*DiagnoseInfo, error) {
 	return info, nil
 }
 
+// agentDir held each agent's metadata as JSON before the store; see
+// importJSON.
 func agentDir() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".agentctl", "agents")
-}
-
-func agentMetaPath(name string) string {
-	return filepath.Join(agentDir(), name+".json")
-}
-
-func saveAgent(agent *Agent) error {
-	os.MkdirAll(agentDir(), 0755)
-	data, _ := json.MarshalIndent(agent, "", "  ")
-	return os.WriteFile(agentMetaPath(agent.Name), data, 0644)
-}
-
-// LoadAgent reads the saved metadata for the named agent.
-func LoadAgent(name string) (*Agent, error) {
-	data, err := os.ReadFile(agentMetaPath(name))
-	if err != nil {
-		return nil, fmt.Errorf("agent not found: %s", name)
-	}
-	var agent Agent
-	json.Unmarshal(data, &agent)
-	return &agent, nil
+	return filepath.Join(namespace.Root(), "agents")
 }
 
-func loadAgent(name string) (*Agent, error) {
-	return LoadAgent(name)
+// containerName maps an agent name to its podman container name, which
+// carries the namespace prefix.
+func containerName(name string) string {
+	return namespace.Container(name)
 }
@@ -1,9 +1,13 @@
 package container
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
 )
 
 func TestCacheDir(t *testing.T) {
@@ -124,4 +128,40 @@ func TestLoadAgentNotFound(t *testing.T) {
 	if err == nil {
 		t.Error("expected error for nonexistent agent, got nil")
 	}
+	if !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("error should be ErrAgentNotFound: %v", err)
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	defer namespace.Set(namespace.Default)
+
+	calls := filepath.Join(tmpHome, "calls")
+	fakePodman(t, `echo "$@" >> `+calls)
+
+	saveAgent(&Agent{Name: "prod-agent"})
+	if err := namespace.Set("exp"); err != nil {
+		t.Fatal(err)
+	}
+	saveAgent(&Agent{Name: "exp-agent"})
+
+	agents, _ := List()
+	if len(agents) != 1 || agents[0].Name != "exp-agent" {
+		t.Fatalf("List() in namespace = %v, want only exp-agent", agents)
+	}
+
+	Kill("exp-agent")
+	out, _ := os.ReadFile(calls)
+	if !strings.Contains(string(out), "stop exp_exp-agent") {
+		t.Errorf("podman should address the prefixed container, got %q", out)
+	}
+
+	namespace.Set(namespace.Default)
+	if _, err := LoadAgent("prod-agent"); err != nil {
+		t.Errorf("default namespace agent should be untouched: %v", err)
+	}
 }
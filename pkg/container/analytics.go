@@ -0,0 +1,393 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// SpyRecord is one normalized session event, as `spy --record` and the
+// daemon store it for `agentctl analytics`.
+type SpyRecord struct {
+	Agent   string    `json:"agent"`
+	Session string    `json:"session,omitempty"` // session ID
+	Time    time.Time `json:"time"`              // the transcript's timestamp, else when recorded
+	Kind    string    `json:"kind"`              // tool, text, thinking, result or usage
+	Tool    string    `json:"tool,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+
+	// Token counts, on usage records only (one per model message).
+	InputTokens      int `json:"input_tokens,omitempty"`
+	OutputTokens     int `json:"output_tokens,omitempty"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// SpyRecorder turns session JSONL lines into SpyRecords, regardless of any
+// display filters.
+type SpyRecorder struct {
+	w       io.Writer
+	agent   string
+	session string
+	now     func() time.Time
+	// lastMessage is the ID of the last message whose usage was recorded; a
+	// message split over consecutive lines repeats its usage.
+	lastMessage string
+}
+
+// NewSpyRecorder returns a recorder writing agent's events to w as JSONL.
+// session is the transcript path or ID.
+func NewSpyRecorder(w io.Writer, agent, session string) *SpyRecorder {
+	return &SpyRecorder{w: w, agent: agent, session: sessionID(session), now: time.Now}
+}
+
+func sessionID(session string) string {
+	return strings.TrimSuffix(path.Base(session), ".jsonl")
+}
+
+// Record writes the events in one transcript line, each encrypted when
+// config.Encryption is on.
+func (r *SpyRecorder) Record(line string) error {
+	for _, rec := range r.records(line) {
+		data, _ := json.Marshal(rec)
+		data, err := seal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := r.w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SpyRecorder) records(line string) []SpyRecord {
+	var msg jsonlMessage
+	if json.Unmarshal([]byte(line), &msg) != nil || msg.Message == nil {
+		return nil
+	}
+	at, err := time.Parse(time.RFC3339, msg.Timestamp)
+	if err != nil {
+		at = r.now()
+	}
+	base := SpyRecord{Agent: r.agent, Session: r.session, Time: at.UTC()}
+
+	var out []SpyRecord
+	for _, block := range msg.Message.Content {
+		rec := base
+		rec.Kind = block.Type
+		switch block.Type {
+		case "tool_use":
+			var ti toolInput
+			json.Unmarshal(block.Input, &ti)
+			rec.Kind, rec.Tool, rec.Summary = "tool", block.Name, toolSummary(block.Name, ti, SpyOptions{})
+		case "text":
+			if msg.Message.Role != "assistant" {
+				continue
+			}
+			rec.Summary = truncate(block.Text, defaultTextWidth)
+		case "thinking":
+			rec.Summary = truncate(block.Thinking, defaultThinkingWidth)
+		case "tool_result":
+			rec.Kind, rec.Summary = "result", truncate(block.Text, defaultDetailWidth)
+		default:
+			continue
+		}
+		out = append(out, rec)
+	}
+
+	if u := msg.Message.Usage; u != nil && (msg.Message.ID == "" || msg.Message.ID != r.lastMessage) {
+		r.lastMessage = msg.Message.ID
+		rec := base
+		rec.Kind = "usage"
+		rec.InputTokens, rec.OutputTokens = u.InputTokens, u.OutputTokens
+		rec.CacheReadTokens, rec.CacheWriteTokens = u.CacheReadInputTokens, u.CacheCreationInputTokens
+		out = append(out, rec)
+	}
+	return out
+}
+
+// SpyStorePath is the namespace's event store, which the daemon appends to
+// and `agentctl analytics` reads by default. It outlives the agents.
+func SpyStorePath() string {
+	return filepath.Join(namespace.Root(), "spy-events.jsonl")
+}
+
+// lockSpyStore takes the lock held while the store is written, so a rewrite
+// of it (EncryptStored) can't drop events appended meanwhile.
+func lockSpyStore() (func(), error) {
+	return lockFile(SpyStorePath()+".lock", longLockStale)
+}
+
+func spyCursorsPath() string {
+	return filepath.Join(namespace.Root(), "spy-cursors.json")
+}
+
+// spyCursor is how far into an agent's session RecordSessions has got.
+type spyCursor struct {
+	Session     string `json:"session"`
+	Offset      int64  `json:"offset"`
+	LastMessage string `json:"last_message,omitempty"`
+}
+
+func loadSpyCursors() map[string]spyCursor {
+	cursors := map[string]spyCursor{}
+	if data, err := os.ReadFile(spyCursorsPath()); err == nil {
+		json.Unmarshal(data, &cursors)
+	}
+	return cursors
+}
+
+// RecordSessions appends what every running agent's session gained since the
+// last call to the store, and returns how many records it wrote. Only whole
+// lines are taken, so a line being written is picked up next time.
+func RecordSessions() (int, error) {
+	agents, err := ListWithState()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(namespace.Root(), 0755); err != nil {
+		return 0, err
+	}
+	// Records are gathered first, so the store is locked only to append.
+	var records bytes.Buffer
+	counter := &countingWriter{w: &records}
+
+	cursors := loadSpyCursors()
+	var recordErr error
+	for _, a := range agents {
+		if !a.ContainerUp || a.Paused {
+			continue
+		}
+		session, err := discoverSessionFile(a.Name)
+		if err != nil {
+			continue // no session yet
+		}
+		cur := cursors[a.Name]
+		if cur.Session != session {
+			cur = spyCursor{Session: session}
+		}
+		out, err := podmanRetry("exec", containerName(a.Name), "tail", "-c", fmt.Sprintf("+%d", cur.Offset+1), session)
+		if err != nil {
+			continue
+		}
+		end := strings.LastIndexByte(string(out), '\n') + 1
+		rec := NewSpyRecorder(counter, a.Name, session)
+		rec.lastMessage = cur.LastMessage
+		before, counted := records.Len(), counter.n
+		for _, line := range strings.Split(string(out[:end]), "\n") {
+			if recordErr = rec.Record(line); recordErr != nil {
+				break
+			}
+		}
+		if recordErr != nil {
+			// Keep what the agents before this one gained, with their
+			// cursors; this agent's lines are all taken again next time.
+			records.Truncate(before)
+			counter.n = counted
+			break
+		}
+		cur.Offset += int64(end)
+		cur.LastMessage = rec.lastMessage
+		cursors[a.Name] = cur
+	}
+	if err := appendSpyStore(records.Bytes()); err != nil {
+		return 0, err
+	}
+	data, _ := json.MarshalIndent(cursors, "", "  ")
+	if err := writeFileAtomic(spyCursorsPath(), data, 0644); err != nil {
+		return counter.n, err
+	}
+	return counter.n, recordErr
+}
+
+// appendSpyStore appends records to the store under its lock.
+func appendSpyStore(records []byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+	unlock, err := lockSpyStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(SpyStorePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(records)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// countingWriter counts the lines written through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += strings.Count(string(p), "\n")
+	return c.w.Write(p)
+}
+
+// LoadSpyRecords reads recorded events from files, or from the store when
+// none are given. A missing store is empty.
+func LoadSpyRecords(files ...string) ([]SpyRecord, error) {
+	if len(files) == 0 {
+		if _, err := os.Stat(SpyStorePath()); os.IsNotExist(err) {
+			return nil, nil
+		}
+		files = []string{SpyStorePath()}
+	}
+	var out []SpyRecord
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line, err := unseal(scanner.Bytes())
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			var rec SpyRecord
+			if json.Unmarshal(line, &rec) == nil && rec.Kind != "" {
+				out = append(out, rec)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// AnalyticsFilter narrows the records Analyze looks at.
+type AnalyticsFilter struct {
+	Agent string    // glob over agent names
+	Since time.Time // drop older records
+	Tool  string    // only this tool's calls (other kinds still count)
+}
+
+// ToolStat is how much one tool was used.
+type ToolStat struct {
+	Tool   string  `json:"tool"`
+	Calls  int     `json:"calls"`
+	Share  float64 `json:"share"` // of all tool calls, 0-1
+	Agents int     `json:"agents"`
+}
+
+// AgentStat is one agent's activity.
+type AgentStat struct {
+	Agent     string `json:"agent"`
+	ToolCalls int    `json:"tool_calls"`
+	Messages  int    `json:"messages"` // model messages (usage records)
+	Tokens    int    `json:"tokens"`
+}
+
+// Analytics summarizes recorded spy events.
+type Analytics struct {
+	Events    int         `json:"events"`
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	ToolCalls int         `json:"tool_calls"`
+	Tools     []ToolStat  `json:"tools"`  // most used first
+	Agents    []AgentStat `json:"agents"` // most tool calls first
+
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens"`
+	CacheWriteTokens int `json:"cache_write_tokens"`
+}
+
+// Analyze counts tool use and tokens over the records that pass filter.
+func Analyze(records []SpyRecord, filter AnalyticsFilter) *Analytics {
+	a := &Analytics{}
+	tools := map[string]*ToolStat{}
+	toolAgents := map[string]map[string]bool{}
+	agents := map[string]*AgentStat{}
+	for _, rec := range records {
+		if filter.Agent != "" {
+			if ok, _ := path.Match(filter.Agent, rec.Agent); !ok {
+				continue
+			}
+		}
+		if rec.Time.Before(filter.Since) {
+			continue
+		}
+		if filter.Tool != "" && rec.Kind == "tool" && !strings.EqualFold(rec.Tool, filter.Tool) {
+			continue
+		}
+		a.Events++
+		if a.From.IsZero() || rec.Time.Before(a.From) {
+			a.From = rec.Time
+		}
+		if rec.Time.After(a.To) {
+			a.To = rec.Time
+		}
+		as := agents[rec.Agent]
+		if as == nil {
+			as = &AgentStat{Agent: rec.Agent}
+			agents[rec.Agent] = as
+		}
+		switch rec.Kind {
+		case "tool":
+			a.ToolCalls++
+			as.ToolCalls++
+			ts := tools[rec.Tool]
+			if ts == nil {
+				ts = &ToolStat{Tool: rec.Tool}
+				tools[rec.Tool] = ts
+				toolAgents[rec.Tool] = map[string]bool{}
+			}
+			ts.Calls++
+			toolAgents[rec.Tool][rec.Agent] = true
+		case "usage":
+			as.Messages++
+			as.Tokens += rec.InputTokens + rec.OutputTokens + rec.CacheReadTokens + rec.CacheWriteTokens
+			a.InputTokens += rec.InputTokens
+			a.OutputTokens += rec.OutputTokens
+			a.CacheReadTokens += rec.CacheReadTokens
+			a.CacheWriteTokens += rec.CacheWriteTokens
+		}
+	}
+
+	for name, ts := range tools {
+		ts.Agents = len(toolAgents[name])
+		ts.Share = float64(ts.Calls) / float64(a.ToolCalls)
+		a.Tools = append(a.Tools, *ts)
+	}
+	sort.Slice(a.Tools, func(i, j int) bool {
+		if a.Tools[i].Calls != a.Tools[j].Calls {
+			return a.Tools[i].Calls > a.Tools[j].Calls
+		}
+		return a.Tools[i].Tool < a.Tools[j].Tool
+	})
+	for _, as := range agents {
+		a.Agents = append(a.Agents, *as)
+	}
+	sort.Slice(a.Agents, func(i, j int) bool {
+		if a.Agents[i].ToolCalls != a.Agents[j].ToolCalls {
+			return a.Agents[i].ToolCalls > a.Agents[j].ToolCalls
+		}
+		return a.Agents[i].Agent < a.Agents[j].Agent
+	})
+	return a
+}
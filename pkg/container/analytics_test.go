@@ -0,0 +1,113 @@
+package container
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const recordedTranscript = `{"type":"assistant","timestamp":"2026-03-01T10:00:00Z","message":{"id":"m1","role":"assistant","content":[{"type":"thinking","thinking":"look it up"}],"usage":{"input_tokens":100,"output_tokens":10,"cache_read_input_tokens":1000}}}
+{"type":"assistant","timestamp":"2026-03-01T10:00:01Z","message":{"id":"m1","role":"assistant","content":[{"type":"tool_use","name":"WebSearch","input":{"query":"podman exec tty"}}],"usage":{"input_tokens":100,"output_tokens":10,"cache_read_input_tokens":1000}}}
+{"type":"user","timestamp":"2026-03-01T10:00:05Z","message":{"role":"user","content":[{"type":"tool_result","text":"3 results"}]}}
+{"type":"progress","data":{"type":"bash_progress"}}
+{"type":"assistant","timestamp":"2026-03-01T10:00:09Z","message":{"id":"m2","role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}],"usage":{"input_tokens":5,"output_tokens":20,"cache_creation_input_tokens":50}}}
+`
+
+func TestSpyRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewSpyRecorder(&buf, "w1", "/home/agent/.claude/projects/-w/s1.jsonl")
+	for _, line := range strings.Split(recordedTranscript, "\n") {
+		if err := rec.Record(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "rec.jsonl")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	records, err := LoadSpyRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, r := range records {
+		kinds = append(kinds, r.Kind+":"+r.Tool)
+		if r.Agent != "w1" || r.Session != "s1" {
+			t.Errorf("record %+v", r)
+		}
+	}
+	// The split message m1 counts its usage once.
+	if got := strings.Join(kinds, ","); got != "thinking:,usage:,tool:WebSearch,result:,tool:Bash,usage:" {
+		t.Errorf("kinds = %s", got)
+	}
+	if records[2].Summary != "podman exec tty" || !records[2].Time.Equal(time.Date(2026, 3, 1, 10, 0, 1, 0, time.UTC)) {
+		t.Errorf("tool record %+v", records[2])
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []SpyRecord{
+		{Agent: "w1", Time: at, Kind: "tool", Tool: "Bash"},
+		{Agent: "w1", Time: at, Kind: "tool", Tool: "WebSearch"},
+		{Agent: "w1", Time: at, Kind: "usage", InputTokens: 10, OutputTokens: 5},
+		{Agent: "w2", Time: at.Add(time.Hour), Kind: "tool", Tool: "Bash"},
+		{Agent: "w2", Time: at.Add(time.Hour), Kind: "text"},
+		{Agent: "docs", Time: at.Add(-48 * time.Hour), Kind: "tool", Tool: "WebSearch"},
+	}
+
+	a := Analyze(records, AnalyticsFilter{})
+	if a.Events != 6 || a.ToolCalls != 4 || len(a.Agents) != 3 || a.InputTokens != 10 {
+		t.Fatalf("analytics %+v", a)
+	}
+	if a.Tools[0].Tool != "Bash" || a.Tools[0].Calls != 2 || a.Tools[0].Agents != 2 || a.Tools[0].Share != 0.5 {
+		t.Errorf("tools %+v", a.Tools)
+	}
+	if !a.From.Equal(at.Add(-48*time.Hour)) || !a.To.Equal(at.Add(time.Hour)) {
+		t.Errorf("span %v → %v", a.From, a.To)
+	}
+
+	a = Analyze(records, AnalyticsFilter{Agent: "w*", Since: at.Add(-time.Hour), Tool: "websearch"})
+	if a.ToolCalls != 1 || len(a.Tools) != 1 || a.Tools[0].Tool != "WebSearch" || len(a.Agents) != 2 {
+		t.Errorf("filtered %+v", a)
+	}
+	if a.Agents[0].Agent != "w1" || a.Agents[0].Tokens != 15 || a.Agents[0].Messages != 1 {
+		t.Errorf("agents %+v", a.Agents)
+	}
+}
+
+func TestRecordSessions(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	transcript := filepath.Join(t.TempDir(), "s1.jsonl")
+	lines := strings.SplitAfter(recordedTranscript, "\n")
+	// The last line is still being written.
+	os.WriteFile(transcript, []byte(strings.Join(lines[:4], "")+lines[4][:20]), 0644)
+	fakePodman(t, `case "$*" in
+ps*) echo '[{"Names": ["w1"], "State": "running"}]' ;;
+*" cat "*.claude.json) echo '{"projects": {"/workspace": {"lastSessionId": "s1"}}}' ;;
+*"stat -c"*) echo "1700000000 /home/dev/.claude/projects/-workspace/s1.jsonl" ;;
+*" tail -c "*) tail -c "$5" `+transcript+` ;;
+esac`)
+	saveAgent(&Agent{Name: "w1", Port: 1})
+
+	n, err := RecordSessions()
+	if err != nil || n != 4 {
+		t.Fatalf("RecordSessions = %d, %v", n, err)
+	}
+	os.WriteFile(transcript, []byte(recordedTranscript), 0644)
+	if n, err := RecordSessions(); err != nil || n != 2 {
+		t.Fatalf("second RecordSessions = %d, %v", n, err)
+	}
+	if n, _ := RecordSessions(); n != 0 {
+		t.Errorf("nothing new, recorded %d", n)
+	}
+	records, err := LoadSpyRecords()
+	if err != nil || len(records) != 6 || records[5].Kind != "usage" || records[5].OutputTokens != 20 {
+		t.Errorf("store = %+v, %v", records, err)
+	}
+}
@@ -0,0 +1,98 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// ArtifactsDir returns where an agent's exported artifacts are kept.
+func ArtifactsDir(name string) string {
+	return filepath.Join(namespace.Root(), "artifacts", name)
+}
+
+// containerPath resolves a path inside an agent: relative paths are taken
+// from the workspace.
+func containerPath(name, p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(layoutOf(name).Workspace, p)
+}
+
+// SplitCopyArg splits an `agentctl cp` argument into agent and path.
+// Local paths (no colon before the first slash) return an empty agent.
+func SplitCopyArg(arg string) (agent, p string) {
+	name, rest, ok := strings.Cut(arg, ":")
+	if !ok || name == "" || strings.ContainsAny(name, `/\`) || filepath.VolumeName(arg) != "" {
+		return "", arg
+	}
+	return name, rest
+}
+
+// Copy copies between an agent and the host, like podman cp but with the
+// agent's name and workspace-relative paths: exactly one of src and dst is
+// "<agent>:<path>".
+func Copy(src, dst string) error {
+	srcAgent, srcPath := SplitCopyArg(src)
+	dstAgent, dstPath := SplitCopyArg(dst)
+	switch {
+	case srcAgent != "" && dstAgent != "":
+		return fmt.Errorf("cannot copy between two agents")
+	case srcAgent != "":
+		return copyOut(srcAgent, srcPath, dstPath)
+	case dstAgent != "":
+		if _, err := loadAgent(dstAgent); err != nil {
+			return err
+		}
+		out, err := podmanLong("cp", srcPath, containerName(dstAgent)+":"+containerPath(dstAgent, dstPath)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("copy to %s failed: %w: %s", dstAgent, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	default:
+		return fmt.Errorf("one of source and destination must be <agent>:<path>")
+	}
+}
+
+func copyOut(name, p, dst string) error {
+	if _, err := loadAgent(name); err != nil {
+		return err
+	}
+	out, err := podmanLong("cp", containerName(name)+":"+containerPath(name, p), dst).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("copy from %s failed: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ExportArtifacts copies the given paths out of an agent into
+// ArtifactsDir(name), keeping their workspace-relative layout, and returns
+// the host paths written. Paths that don't exist in the container are
+// skipped; a previous export of the same path is replaced.
+func ExportArtifacts(name string, paths []string) ([]string, error) {
+	dir := ArtifactsDir(name)
+	var exported []string
+	for _, p := range paths {
+		src := containerPath(name, p)
+		if podman("exec", containerName(name), "test", "-e", src).Run() != nil {
+			fmt.Printf("   (no %s in %s, skipped)\n", p, name)
+			continue
+		}
+		rel := strings.TrimPrefix(path.Clean("/"+strings.TrimSuffix(p, "/")), "/")
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return exported, err
+		}
+		os.RemoveAll(dst)
+		if err := copyOut(name, p, dst); err != nil {
+			return exported, err
+		}
+		exported = append(exported, dst)
+	}
+	return exported, nil
+}
@@ -0,0 +1,58 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitCopyArg(t *testing.T) {
+	tests := []struct{ arg, agent, path string }{
+		{"worker:dist/app", "worker", "dist/app"},
+		{"worker:/tmp/build.log", "worker", "/tmp/build.log"},
+		{"./out", "", "./out"},
+		{"out/a:b", "", "out/a:b"},
+		{":x", "", ":x"},
+	}
+	for _, tt := range tests {
+		if agent, p := SplitCopyArg(tt.arg); agent != tt.agent || p != tt.path {
+			t.Errorf("SplitCopyArg(%q) = %q, %q", tt.arg, agent, p)
+		}
+	}
+}
+
+func TestExportArtifacts(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$@" >> `+calls+`
+case "$1" in
+exec) [ "$5" = "/srv/repo/dist" ] || [ "$5" = "/tmp/build.log" ] ;;
+cp) mkdir -p "$3" ;;
+esac`)
+	saveAgent(&Agent{Name: "worker", Layout: Layout{Home: "/home/dev", Workspace: "/srv/repo"}})
+
+	files, err := ExportArtifacts("worker", []string{"dist/", "coverage.html", "/tmp/build.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := ArtifactsDir("worker")
+	if len(files) != 2 || files[0] != filepath.Join(dir, "dist") || files[1] != filepath.Join(dir, "tmp", "build.log") {
+		t.Errorf("ExportArtifacts() = %v", files)
+	}
+	out, _ := os.ReadFile(calls)
+	if !strings.Contains(string(out), "cp worker:/srv/repo/dist "+filepath.Join(dir, "dist")) {
+		t.Errorf("podman calls:\n%s", out)
+	}
+
+	if err := Copy("worker:a", "other:b"); err == nil {
+		t.Error("expected an error copying between agents")
+	}
+	if err := Copy("a", "b"); err == nil {
+		t.Error("expected an error with no agent side")
+	}
+}
@@ -0,0 +1,111 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// attemptTagPrefix names the tags that mark the workspace at the end of each
+// attempt: agentctl/attempt-1, agentctl/attempt-2, ...
+const attemptTagPrefix = "agentctl/attempt-"
+
+// AttemptTag returns the tag marking the end of the given attempt.
+func AttemptTag(attempt int) string {
+	return attemptTagPrefix + strconv.Itoa(attempt)
+}
+
+// snapshotSubject starts the message of the commit recording an attempt's
+// uncommitted work.
+const snapshotSubject = "agentctl: attempt snapshot"
+
+// tagAttempt tags the workspace as the attempt left it. Uncommitted and
+// untracked (but not ignored) files are captured in a snapshot commit on top
+// of HEAD, built in a scratch index so the agent's index and branch are left
+// alone; a clean workspace is tagged at HEAD. Tags are local and never
+// pushed.
+func tagAttempt(name string, attempt int) error {
+	script := layoutOf(name).cd() + fmt.Sprintf(`idx=$(mktemp) && cp "$(git rev-parse --git-path index)" "$idx" 2>/dev/null
+tree=$(GIT_INDEX_FILE="$idx" git add -A 2>/dev/null && GIT_INDEX_FILE="$idx" git write-tree)
+rm -f "$idx"
+[ -n "$tree" ] || exit 1
+commit=$(git rev-parse HEAD) || exit 1
+if [ "$tree" != "$(git rev-parse HEAD^{tree})" ]; then
+  commit=$(git -c user.name=agentctl -c user.email=agentctl@localhost commit-tree "$tree" -p HEAD -m '%s %d (uncommitted changes)') || exit 1
+fi
+git tag -f %s "$commit" >/dev/null`, snapshotSubject, attempt, shellQuote(AttemptTag(attempt)))
+	out, err := podmanLong("exec", containerName(name), "sh", "-c", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tagging attempt %d: %w: %s", attempt, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// clearAttemptTags deletes the attempt tags of a previous run, so a fresh
+// run's tags don't mix with stale ones.
+func clearAttemptTags(name string) {
+	podman("exec", containerName(name), "sh", "-c",
+		layoutOf(name).cd()+"git tag -l '"+attemptTagPrefix+"*' | xargs -r git tag -d >/dev/null 2>&1; true").Run()
+}
+
+// AttemptRef is the tagged end state of one attempt.
+type AttemptRef struct {
+	Attempt  int
+	Tag      string
+	Commit   string // short SHA
+	Date     string
+	Subject  string
+	Snapshot bool // the attempt ended with uncommitted work
+}
+
+// AttemptTags lists an agent's attempt tags in attempt order.
+func AttemptTags(name string) ([]AttemptRef, error) {
+	if _, err := loadAgent(name); err != nil {
+		return nil, err
+	}
+	out, err := podman("exec", containerName(name), "git", "-C", layoutOf(name).Workspace, "for-each-ref",
+		"--format=%(refname:short)%09%(objectname:short)%09%(creatordate:iso8601)%09%(subject)",
+		"refs/tags/agentctl/").Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing attempt tags: %w", err)
+	}
+	return parseAttemptRefs(string(out)), nil
+}
+
+func parseAttemptRefs(out string) []AttemptRef {
+	var refs []AttemptRef
+	for _, line := range nonEmptyLines(out) {
+		f := strings.SplitN(line, "\t", 4)
+		if len(f) < 4 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(f[0], attemptTagPrefix))
+		if err != nil || !strings.HasPrefix(f[0], attemptTagPrefix) {
+			continue
+		}
+		refs = append(refs, AttemptRef{
+			Attempt: n, Tag: f[0], Commit: f[1], Date: f[2], Subject: f[3],
+			Snapshot: strings.HasPrefix(f[3], snapshotSubject),
+		})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Attempt < refs[j].Attempt })
+	return refs
+}
+
+// DiffAttempts returns the diff between the end states of two attempts, or
+// only its stat.
+func DiffAttempts(name string, from, to int, stat bool) (string, error) {
+	if _, err := loadAgent(name); err != nil {
+		return "", err
+	}
+	args := []string{"exec", containerName(name), "git", "-C", layoutOf(name).Workspace, "diff", "--no-color"}
+	if stat {
+		args = append(args, "--stat")
+	}
+	out, err := podmanLong(append(args, AttemptTag(from), AttemptTag(to), "--")...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("diffing attempts %d and %d: %w: %s", from, to, err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttemptTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	// "podman exec <container> cmd..." runs cmd on the host, in a real repo.
+	fakePodman(t, `shift 2; exec "$@"`)
+	dir, git := gitRepo(t, "Add login")
+	saveAgent(&Agent{Name: "worker", Layout: Layout{Workspace: dir}})
+
+	if err := tagAttempt("worker", 1); err != nil {
+		t.Fatal(err)
+	}
+	head, _ := git(nil, "rev-parse", "HEAD")
+	if tag, _ := git(nil, "rev-parse", AttemptTag(1)); tag != head {
+		t.Errorf("clean attempt tagged %s, want HEAD %s", tag, head)
+	}
+
+	// Uncommitted and untracked work goes into a snapshot; the agent's
+	// index and branch are untouched.
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("edited\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644)
+	if err := tagAttempt("worker", 2); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := git(nil, "rev-parse", "HEAD"); after != head {
+		t.Error("tagging moved HEAD")
+	}
+	if st, _ := git(nil, "status", "--porcelain"); !strings.Contains(st, " M file.txt") || !strings.Contains(st, "?? new.txt") {
+		t.Errorf("workspace status changed:\n%s", st)
+	}
+
+	refs, err := AttemptTags("worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Snapshot || !refs[1].Snapshot || refs[1].Attempt != 2 {
+		t.Fatalf("AttemptTags() = %+v", refs)
+	}
+	stat, err := DiffAttempts("worker", 1, 2, true)
+	if err != nil || !strings.Contains(stat, "file.txt") || !strings.Contains(stat, "new.txt") {
+		t.Errorf("DiffAttempts() = %q, %v", stat, err)
+	}
+
+	clearAttemptTags("worker")
+	if refs, _ := AttemptTags("worker"); len(refs) != 0 {
+		t.Errorf("tags left after clear: %+v", refs)
+	}
+}
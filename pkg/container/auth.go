@@ -0,0 +1,272 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// AuthProbeEnv is set for the probe's run-task invocation, so an image can
+// answer it more cheaply than with a full agent turn.
+const AuthProbeEnv = "AGENTCTL_AUTH_PROBE"
+
+// authProbePrompt is the no-op the probe asks of the agent.
+const authProbePrompt = "Reply with the single word OK and do nothing else."
+
+const (
+	defaultAuthTimeout = 2 * time.Minute
+	defaultAuthMaxAge  = time.Hour
+)
+
+// oauthCredentialsPath is where Claude Code keeps its OAuth login, relative
+// to the home directory.
+const oauthCredentialsPath = ".claude/.credentials.json"
+
+// AuthCheck is what VerifyAuth found for an agent.
+type AuthCheck struct {
+	Agent string `json:"agent"`
+	OK    bool   `json:"ok"`
+	// Problem says what is wrong when the probe failed.
+	Problem string `json:"problem,omitempty"`
+	// Reply is the first line of the agent's answer to the probe.
+	Reply string `json:"reply,omitempty"`
+	// RateLimited marks a probe the API turned away for now: the
+	// credentials were accepted.
+	RateLimited bool `json:"rate_limited,omitempty"`
+	// OAuthExpires is when the container's Claude OAuth token expires, for
+	// images logged in with one.
+	OAuthExpires *time.Time `json:"oauth_expires,omitempty"`
+	// StaleKey is set when the container was spawned with another
+	// AGENT_LLM_KEY than the host has now; runs pass the host's.
+	StaleKey bool `json:"stale_key,omitempty"`
+	// Refreshed lists the credentials copied in from the host.
+	Refreshed []string `json:"refreshed,omitempty"`
+}
+
+// VerifyAuth checks the agent can reach its model by having its run-task
+// answer a no-op prompt. With refresh, a failed probe has the container's
+// Claude OAuth login replaced by the host's, when the host's is newer, and
+// is tried again.
+func VerifyAuth(name string, refresh bool) (*AuthCheck, error) {
+	if _, err := loadAgent(name); err != nil {
+		return nil, err
+	}
+	out, err := podmanRetry("inspect", "-f", "{{.State.Status}}", containerName(name))
+	if errors.Is(err, ErrRuntimeUnresponsive) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: no container %q — is the agent spawned?", ErrAgentNotFound, name)
+	}
+	if status := strings.TrimSpace(string(out)); status != "running" {
+		return nil, fmt.Errorf("%w: %q is %s", ErrContainerNotRunning, name, status)
+	}
+	return verifyAuth(name, refresh, authTimeout()), nil
+}
+
+func verifyAuth(name string, refresh bool, timeout time.Duration) *AuthCheck {
+	check := &AuthCheck{Agent: name}
+	if key := resolveLLMKey(); key != "" {
+		out, _ := podman("exec", containerName(name), "printenv", "AGENT_LLM_KEY").Output()
+		check.StaleKey = strings.TrimSpace(string(out)) != key
+	}
+	layout := layoutOf(name)
+	creds, _ := podman("exec", containerName(name), "cat", layout.Path(oauthCredentialsPath)).Output()
+	if expires, ok := oauthExpiry(creds); ok {
+		check.OAuthExpires = &expires
+	}
+
+	check.probe(name, timeout)
+	if !check.OK && refresh && check.OAuthExpires != nil {
+		if refreshed := refreshOAuth(name, layout, *check.OAuthExpires); refreshed != nil {
+			check.Refreshed = append(check.Refreshed, "oauth")
+			check.OAuthExpires = refreshed
+			check.probe(name, timeout)
+		}
+	}
+
+	// Remember the outcome: a pass spares the next checks a probe, a
+	// failure must not be covered by an older pass.
+	updateAgent(name, func(a *Agent) error {
+		a.AuthVerified = time.Time{}
+		if check.OK {
+			a.AuthVerified = time.Now()
+		}
+		return nil
+	})
+	return check
+}
+
+// probe runs the no-op prompt through the image's run-task, the way an
+// attempt would, and judges the reply.
+func (c *AuthCheck) probe(name string, timeout time.Duration) {
+	keyArgs, keyEnv := llmKeyEnv()
+	args := append([]string{"exec", "-i", "-e", AuthProbeEnv + "=1"}, keyArgs...)
+	args = append(args, containerName(name), "sh", "-c", layoutOf(name).cd()+`run-task "$(cat)" < /dev/null 2>&1`)
+	cmd := podmanTimeout(timeout, args...)
+	cmd.Env = keyEnv
+	cmd.Stdin = strings.NewReader(authProbePrompt)
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+
+	c.OK, c.Problem, c.Reply, c.RateLimited = false, "", "", false
+	switch {
+	case errors.Is(err, ErrRuntimeUnresponsive):
+		c.Problem = fmt.Sprintf("no reply within %s", timeout)
+	case agentErrorOutput.MatchString(output):
+		c.Problem = strings.TrimSpace(agentErrorOutput.FindString(output))
+	case rateLimitOutput.MatchString(output):
+		c.OK, c.RateLimited = true, true
+	case err != nil:
+		c.Problem = fmt.Sprintf("run-task failed (%v): %s", err, tailLines(output, 3))
+	case output == "":
+		c.Problem = "run-task exited without a reply"
+	default:
+		c.OK = true
+	}
+	if c.OK {
+		c.Reply, _, _ = strings.Cut(output, "\n")
+		if len(c.Reply) > 80 {
+			c.Reply = c.Reply[:80] + "..."
+		}
+	}
+}
+
+// llmKeyEnv passes the host's current AGENT_LLM_KEY to a podman command, so
+// a key rotated since spawn reaches the agent. The flag names the variable
+// without a value and podman reads it from env, which callers set as the
+// command's environment: a value on argv would show in ps for the whole run.
+func llmKeyEnv() (args, env []string) {
+	key := resolveLLMKey()
+	if key == "" {
+		return nil, nil
+	}
+	return []string{"-e", "AGENT_LLM_KEY"}, append(os.Environ(), "AGENT_LLM_KEY="+key)
+}
+
+// oauthExpiry reads when a Claude Code credentials file's token expires.
+func oauthExpiry(data []byte) (time.Time, bool) {
+	var creds struct {
+		ClaudeAiOauth *struct {
+			ExpiresAt int64 `json:"expiresAt"`
+		} `json:"claudeAiOauth"`
+	}
+	if json.Unmarshal(data, &creds) != nil || creds.ClaudeAiOauth == nil || creds.ClaudeAiOauth.ExpiresAt == 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(creds.ClaudeAiOauth.ExpiresAt), true
+}
+
+// hostOAuthPath is the host's Claude Code credentials file.
+func hostOAuthPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, filepath.FromSlash(oauthCredentialsPath))
+}
+
+// refreshOAuth copies the host's Claude OAuth login into the container
+// when it outlasts the container's, returning its expiry; nil when there
+// is nothing better to copy. Only the credentials file goes in: the rest
+// of the host's ~/.claude (transcripts, hooks) stays out.
+func refreshOAuth(name string, layout Layout, current time.Time) *time.Time {
+	data, err := os.ReadFile(hostOAuthPath())
+	if err != nil {
+		return nil
+	}
+	expires, ok := oauthExpiry(data)
+	if !ok || !expires.After(current) || !expires.After(time.Now()) {
+		return nil
+	}
+	dest := layout.Path(oauthCredentialsPath)
+	cmd := podman("exec", "-i", containerName(name), "sh", "-c",
+		fmt.Sprintf("mkdir -p %s && umask 077 && cat > %s", shellQuote(path.Dir(dest)), shellQuote(dest)))
+	cmd.Stdin = strings.NewReader(string(data))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		fmt.Printf("⚠️  Could not copy the host's Claude login into %s: %v %s\n", name, err, strings.TrimSpace(string(out)))
+		return nil
+	}
+	return &expires
+}
+
+// authMode is auth.check: "fail", "warn" or "off".
+func authMode() string {
+	if cfg, err := config.Load(); err == nil && cfg.Auth.Check != "" {
+		return cfg.Auth.Check
+	}
+	return "fail"
+}
+
+func authTimeout() time.Duration {
+	if cfg, err := config.Load(); err == nil {
+		if d, err := time.ParseDuration(cfg.Auth.Timeout); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultAuthTimeout
+}
+
+// authMaxAge is auth.max_age: how long a passed probe is trusted.
+func authMaxAge() time.Duration {
+	if cfg, err := config.Load(); err == nil && cfg.Auth.MaxAge != "" {
+		if d, err := time.ParseDuration(cfg.Auth.MaxAge); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultAuthMaxAge
+}
+
+// checkAuth runs the auth check before an agent is relied on, refreshing
+// what it can. The probe is a billed model call, so one that passed within
+// auth.max_age stands in for it. It fails with ErrAuthFailed only when
+// strict (and auth.check is "fail"); otherwise a failure is a warning.
+func checkAuth(name string, strict bool) error {
+	mode := authMode()
+	if mode == "off" {
+		return nil
+	}
+	if a, err := loadAgent(name); err == nil && !a.AuthVerified.IsZero() && time.Since(a.AuthVerified) < authMaxAge() {
+		return nil
+	}
+	check := verifyAuth(name, true, authTimeout())
+	for _, what := range check.Refreshed {
+		fmt.Printf("🔑 Refreshed %s's %s credentials from the host\n", name, what)
+	}
+	if check.StaleKey {
+		fmt.Printf("🔑 %s was spawned with an older AGENT_LLM_KEY; runs use the host's current one\n", name)
+	}
+	if check.OK {
+		return nil
+	}
+	if strict && mode == "fail" {
+		return fmt.Errorf("%w: %s: %s (see `agentctl verify-auth %s`)", ErrAuthFailed, name, check.Problem, name)
+	}
+	fmt.Printf("⚠️  %s can't reach its model: %s (see `agentctl verify-auth %s`)\n", name, check.Problem, name)
+	return nil
+}
+
+// String sums the check up for `verify-auth`.
+func (c *AuthCheck) String() string {
+	var s string
+	switch {
+	case c.RateLimited:
+		s = "signed in, but rate limited right now"
+	case c.OK:
+		s = fmt.Sprintf("ok (replied %q)", c.Reply)
+	default:
+		s = c.Problem
+	}
+	if c.OAuthExpires != nil {
+		if until := time.Until(*c.OAuthExpires); until > 0 {
+			s += fmt.Sprintf("; OAuth token expires in %s", until.Round(time.Minute))
+		} else {
+			s += "; OAuth token expired " + c.OAuthExpires.Format(time.RFC3339)
+		}
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuthExpiry(t *testing.T) {
+	if got, ok := oauthExpiry([]byte(`{"claudeAiOauth": {"accessToken": "x", "expiresAt": 1700000000000}}`)); !ok || got.Unix() != 1700000000 {
+		t.Errorf("oauthExpiry() = %v, %v", got, ok)
+	}
+	for _, data := range []string{"", "{}", `{"claudeAiOauth": {}}`, "not json"} {
+		if _, ok := oauthExpiry([]byte(data)); ok {
+			t.Errorf("oauthExpiry(%q) found an expiry", data)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AGENT_LLM_KEY", "")
+
+	for _, tc := range []struct {
+		script, problem string
+		ok, rateLimited bool
+	}{
+		{script: "echo OK", ok: true},
+		{script: `echo 'API Error: 429 {"type":"error","error":{"type":"rate_limit_error"}}'; exit 1`, ok: true, rateLimited: true},
+		{script: `echo "Invalid API key · Please run /login"; exit 1`, problem: "Invalid API key"},
+		{script: "exit 0", problem: "run-task exited without a reply"},
+	} {
+		fakePodman(t, `case "$*" in
+*AUTH_PROBE*) `+tc.script+` ;;
+esac`)
+		check := &AuthCheck{Agent: "worker"}
+		check.probe("worker", 10*time.Second)
+		if check.OK != tc.ok || check.RateLimited != tc.rateLimited || (tc.problem != "") != (check.Problem != "") {
+			t.Errorf("probe with %q = %+v", tc.script, check)
+		}
+		if !strings.HasPrefix(check.Problem, tc.problem) {
+			t.Errorf("probe with %q: problem %q, want %q", tc.script, check.Problem, tc.problem)
+		}
+	}
+}
+
+func TestProbeKeepsLLMKeyOffArgv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AGENT_LLM_KEY", "sk-secret")
+
+	fakePodman(t, `case "$*" in
+*sk-secret*) echo "key on argv"; exit 1 ;;
+*AUTH_PROBE*) echo "$AGENT_LLM_KEY" ;;
+esac`)
+	check := &AuthCheck{Agent: "worker"}
+	check.probe("worker", 10*time.Second)
+	if !check.OK || check.Reply != "sk-secret" {
+		t.Errorf("probe = %+v, want the key from podman's environment only", check)
+	}
+}
+
+func TestVerifyAuthRefreshesOAuth(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("AGENT_LLM_KEY", "")
+
+	// The container's login expired an hour ago; the host's is good for a day.
+	expired := time.Now().Add(-time.Hour).UnixMilli()
+	fresh := time.Now().Add(24 * time.Hour).UnixMilli()
+	os.MkdirAll(filepath.Join(tmpHome, ".claude"), 0700)
+	os.WriteFile(filepath.Join(tmpHome, ".claude", ".credentials.json"),
+		[]byte(fmt.Sprintf(`{"claudeAiOauth": {"expiresAt": %d}}`, fresh)), 0600)
+
+	copied := filepath.Join(t.TempDir(), "copied")
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) if [ -f `+copied+` ]; then echo OK; else echo "OAuth token has expired"; exit 1; fi ;;
+*"umask 077"*) cat > `+copied+` ;;
+*credentials.json*) echo '{"claudeAiOauth": {"expiresAt": `+fmt.Sprint(expired)+`}}' ;;
+esac`)
+
+	check := verifyAuth("worker", true, 10*time.Second)
+	if !check.OK || len(check.Refreshed) != 1 || check.OAuthExpires.UnixMilli() != fresh {
+		t.Fatalf("verifyAuth() = %+v", check)
+	}
+	if data, _ := os.ReadFile(copied); len(data) == 0 {
+		t.Error("the host's credentials weren't copied in")
+	}
+
+	// Without refresh, the failure is only reported.
+	os.Remove(copied)
+	if check := verifyAuth("worker", false, 10*time.Second); check.OK || len(check.Refreshed) != 0 {
+		t.Errorf("verifyAuth() without refresh = %+v", check)
+	}
+}
+
+func TestRunFailsOnAuth(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	t.Setenv("AGENT_LLM_KEY", "")
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) echo "Invalid API key · Please run /login"; exit 1 ;;
+*prompt.txt*) echo run >> `+calls+` ;;
+esac`)
+	saveAgent(&Agent{Name: "worker"})
+
+	if _, err := RunUntilDone("worker", "fix login", 3); !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("RunUntilDone() error = %v, want ErrAuthFailed", err)
+	}
+	if _, err := os.Stat(calls); err == nil {
+		t.Error("an attempt ran despite the failed auth check")
+	}
+
+	// With auth.check "warn" the run goes ahead.
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"auth": {"check": "warn"}}`), 0644)
+	if err := checkAuth("worker", true); err != nil {
+		t.Errorf("checkAuth() with check=warn = %v", err)
+	}
+}
+
+func TestCheckAuthTrustsRecentProbe(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AGENT_LLM_KEY", "")
+
+	probes := filepath.Join(t.TempDir(), "probes")
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) echo probe >> `+probes+`; echo OK ;;
+esac`)
+	count := func() int {
+		data, _ := os.ReadFile(probes)
+		return strings.Count(string(data), "probe")
+	}
+	saveAgent(&Agent{Name: "worker"})
+
+	// Spawn's probe spares the run that follows another.
+	for i := 0; i < 2; i++ {
+		if err := checkAuth("worker", true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count() != 1 {
+		t.Errorf("%d probes, want 1", count())
+	}
+
+	// Once it's older than auth.max_age, the next check probes again.
+	updateAgent("worker", func(a *Agent) error {
+		a.AuthVerified = time.Now().Add(-2 * defaultAuthMaxAge)
+		return nil
+	})
+	checkAuth("worker", true)
+	if count() != 2 {
+		t.Errorf("%d probes, want 2 once the pass is old", count())
+	}
+
+	// A failed probe isn't covered by the pass before it.
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) echo "Invalid API key"; exit 1 ;;
+esac`)
+	verifyAuth("worker", false, 10*time.Second)
+	if err := checkAuth("worker", true); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("checkAuth() after a failed probe = %v, want ErrAuthFailed", err)
+	}
+}
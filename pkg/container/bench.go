@@ -0,0 +1,275 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BenchSpec describes a benchmark: one task run by freshly spawned agents
+// for each variant, so prompts, models and images can be compared.
+type BenchSpec struct {
+	Repo        string         `json:"repo"`
+	Branch      string         `json:"branch,omitempty"`
+	Task        string         `json:"task"`
+	MaxAttempts int            `json:"max_attempts,omitempty"`
+	Runs        int            `json:"runs,omitempty"`     // per variant (default 1)
+	Parallel    int            `json:"parallel,omitempty"` // agents at once (default 1)
+	Variants    []BenchVariant `json:"variants"`
+}
+
+// BenchVariant is one way of running the task. Empty fields use the
+// defaults spawn and run would.
+type BenchVariant struct {
+	Name string `json:"name"`
+	// Prompt replaces the task; {{task}} in it expands to the task.
+	Prompt  string `json:"prompt,omitempty"`
+	Model   string `json:"model,omitempty"` // AGENT_LLM_MODEL
+	Image   string `json:"image,omitempty"`
+	Profile string `json:"profile,omitempty"`
+}
+
+func (v BenchVariant) prompt(task string) string {
+	if v.Prompt == "" {
+		return task
+	}
+	return strings.ReplaceAll(v.Prompt, "{{task}}", task)
+}
+
+// BenchResult is the outcome of one run of one variant.
+type BenchResult struct {
+	Variant    string        `json:"variant"`
+	Run        int           `json:"run"`
+	Agent      string        `json:"agent"`
+	Completed  bool          `json:"completed"`
+	Attempts   int           `json:"attempts"`
+	Duration   time.Duration `json:"duration_ns"`
+	Tokens     int           `json:"tokens"` // from Claude transcripts; 0 for other harnesses
+	Files      int           `json:"files_changed"`
+	Insertions int           `json:"insertions"`
+	Deletions  int           `json:"deletions"`
+	Tests      string        `json:"tests"` // pass, fail, flaky or unknown
+	Error      string        `json:"error,omitempty"`
+}
+
+var benchVariantName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// LoadBenchSpec reads and checks a benchmark file.
+func LoadBenchSpec(path string) (*BenchSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec BenchSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid bench file %s: %w", path, err)
+	}
+	if spec.Repo == "" || spec.Task == "" {
+		return nil, fmt.Errorf("bench file %s needs a repo and a task", path)
+	}
+	if len(spec.Variants) == 0 {
+		return nil, fmt.Errorf("bench file %s has no variants", path)
+	}
+	seen := map[string]bool{}
+	for _, v := range spec.Variants {
+		if !benchVariantName.MatchString(v.Name) {
+			return nil, fmt.Errorf("variant name %q must be lowercase letters, digits and dashes", v.Name)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	if spec.Runs <= 0 {
+		spec.Runs = 1
+	}
+	if spec.Parallel <= 0 {
+		spec.Parallel = 1
+	}
+	return &spec, nil
+}
+
+// Bench spawns an agent per variant and run, runs the task on each until
+// done, measures the outcome and kills the agent again (unless keep). Results
+// are in variant order, runs in order within a variant.
+func Bench(spec *BenchSpec, keep bool) []BenchResult {
+	stamp := time.Now().Format("0102-1504")
+	type job struct {
+		i       int
+		variant BenchVariant
+		run     int
+	}
+	var jobs []job
+	for r := 1; r <= spec.Runs; r++ {
+		for _, v := range spec.Variants {
+			jobs = append(jobs, job{len(jobs), v, r})
+		}
+	}
+
+	results := make([]BenchResult, len(jobs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, spec.Parallel)
+	for _, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(j job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			name := fmt.Sprintf("bench-%s-%s-%d", stamp, j.variant.Name, j.run)
+			results[j.i] = benchRun(spec, j.variant, j.run, name)
+			if !keep {
+				Kill(name)
+			}
+		}(j)
+	}
+	wg.Wait()
+
+	order := map[string]int{}
+	for i, v := range spec.Variants {
+		order[v.Name] = i
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Variant != results[b].Variant {
+			return order[results[a].Variant] < order[results[b].Variant]
+		}
+		return results[a].Run < results[b].Run
+	})
+	return results
+}
+
+func benchRun(spec *BenchSpec, v BenchVariant, run int, name string) BenchResult {
+	res := BenchResult{Variant: v.Name, Run: run, Agent: name, Tests: "unknown"}
+	fmt.Printf("🏁 %s: spawning %s\n", v.Name, name)
+	_, err := SpawnWithOptions(SpawnOptions{
+		Name: name, Repo: spec.Repo, Branch: spec.Branch, Image: v.Image,
+		Profile: v.Profile, Model: v.Model, Intent: "bench: " + v.Name, Template: "bench:" + v.Name,
+		WaitForCapacity: true,
+	})
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	start := time.Now()
+	tr, _ := RunUntilDone(name, v.prompt(spec.Task), spec.MaxAttempts)
+	res.Duration = time.Since(start)
+	if tr != nil {
+		res.Completed, res.Attempts, res.Error = tr.Completed, tr.Attempts, tr.Error
+		if tr.TestStatus != "" {
+			res.Tests = tr.TestStatus
+		}
+	}
+	res.Tokens = tokensUsed(name)
+	if base, err := branchBase(agentGit(name)); err == nil {
+		stat, _ := agentGit(name)(nil, "diff", "--shortstat", base)
+		res.Files, res.Insertions, res.Deletions = parseShortstat(stat)
+	}
+	return res
+}
+
+// tokensUsed totals the tokens in an agent's Claude transcripts.
+func tokensUsed(name string) int {
+	return sessionUsage(name).Total()
+}
+
+// sessionUsage counts the tokens in an agent's Claude transcripts by kind.
+func sessionUsage(name string) TokenUsage {
+	out, _ := podmanLong("exec", containerName(name), "sh", "-c",
+		"cat "+layoutOf(name).sessionGlob()+" 2>/dev/null | grep '\"usage\"'; true").Output()
+	return transcriptUsage(strings.Split(string(out), "\n"))
+}
+
+// transcriptTokens sums input, cache and output tokens over transcript lines.
+func transcriptTokens(lines []string) int {
+	return transcriptUsage(lines).Total()
+}
+
+// transcriptUsage counts tokens over transcript lines. A message split over
+// several lines repeats its usage, so each message counts once.
+func transcriptUsage(lines []string) TokenUsage {
+	seen := map[string]bool{}
+	var total TokenUsage
+	for _, line := range lines {
+		var msg jsonlMessage
+		if json.Unmarshal([]byte(line), &msg) != nil || msg.Message == nil || msg.Message.Usage == nil {
+			continue
+		}
+		if id := msg.Message.ID; id != "" {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		u := msg.Message.Usage
+		total.Input += u.InputTokens
+		total.Output += u.OutputTokens
+		total.CacheWrite += u.CacheCreationInputTokens
+		total.CacheRead += u.CacheReadInputTokens
+	}
+	return total
+}
+
+var shortstatField = regexp.MustCompile(`(\d+) (file|insertion|deletion)`)
+
+// parseShortstat reads `git diff --shortstat` output.
+func parseShortstat(stat string) (files, insertions, deletions int) {
+	for _, m := range shortstatField.FindAllStringSubmatch(stat, -1) {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "file":
+			files = n
+		case "insertion":
+			insertions = n
+		case "deletion":
+			deletions = n
+		}
+	}
+	return files, insertions, deletions
+}
+
+// FormatBench renders a comparison table with one row per variant, averaging
+// over its runs.
+func FormatBench(results []BenchResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s %6s %8s %9s %9s %14s %s\n", "VARIANT", "DONE", "ATTEMPTS", "DURATION", "TOKENS", "DIFF", "TESTS")
+	for i := 0; i < len(results); {
+		j := i
+		for j < len(results) && results[j].Variant == results[i].Variant {
+			j++
+		}
+		runs := results[i:j]
+		var done, passed, attempts, tokens, files, ins, del int
+		var dur time.Duration
+		for _, r := range runs {
+			if r.Completed {
+				done++
+			}
+			if r.Tests == "pass" {
+				passed++
+			}
+			attempts += r.Attempts
+			tokens += r.Tokens
+			files += r.Files
+			ins += r.Insertions
+			del += r.Deletions
+			dur += r.Duration
+		}
+		n := len(runs)
+		tokenCol := "-"
+		if tokens > 0 {
+			tokenCol = strconv.Itoa(tokens / n)
+		}
+		diff := fmt.Sprintf("+%d -%d (%d)", ins/n, del/n, files/n)
+		fmt.Fprintf(&b, "%-16s %6s %8.1f %9s %9s %14s %d/%d pass\n", runs[0].Variant,
+			fmt.Sprintf("%d/%d", done, n), float64(attempts)/float64(n),
+			(dur / time.Duration(n)).Round(time.Second), tokenCol, diff, passed, n)
+		i = j
+	}
+	return b.String()
+}
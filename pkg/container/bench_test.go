@@ -0,0 +1,88 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadBenchSpec(t *testing.T) {
+	dir := t.TempDir()
+	write := func(body string) string {
+		path := filepath.Join(dir, "bench.json")
+		os.WriteFile(path, []byte(body), 0644)
+		return path
+	}
+
+	spec, err := LoadBenchSpec(write(`{"repo": "https://github.com/org/api", "task": "fix login",
+		"variants": [{"name": "baseline"}, {"name": "terse", "prompt": "{{task}}. Be brief."}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Runs != 1 || spec.Parallel != 1 {
+		t.Errorf("defaults: runs=%d parallel=%d", spec.Runs, spec.Parallel)
+	}
+	if got := spec.Variants[1].prompt(spec.Task); got != "fix login. Be brief." {
+		t.Errorf("prompt = %q", got)
+	}
+	if got := spec.Variants[0].prompt(spec.Task); got != "fix login" {
+		t.Errorf("baseline prompt = %q", got)
+	}
+
+	for body, want := range map[string]string{
+		`{"task": "x", "variants": [{"name": "a"}]}`:                             "needs a repo and a task",
+		`{"repo": "r", "task": "x"}`:                                             "no variants",
+		`{"repo": "r", "task": "x", "variants": [{"name": "A b"}]}`:              "must be lowercase",
+		`{"repo": "r", "task": "x", "variants": [{"name": "a"}, {"name": "a"}]}`: "duplicate variant",
+	} {
+		if _, err := LoadBenchSpec(write(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadBenchSpec(%s) = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestTranscriptTokens(t *testing.T) {
+	lines := []string{
+		`{"type":"assistant","message":{"id":"m1","role":"assistant","usage":{"input_tokens":100,"output_tokens":20,"cache_read_input_tokens":1000}}}`,
+		`{"type":"assistant","message":{"id":"m1","role":"assistant","usage":{"input_tokens":100,"output_tokens":20,"cache_read_input_tokens":1000}}}`,
+		`{"type":"assistant","message":{"id":"m2","role":"assistant","usage":{"input_tokens":5,"output_tokens":7,"cache_creation_input_tokens":50}}}`,
+		`{"type":"user","message":{"role":"user","content":[]}}`,
+		`not json`,
+	}
+	if got := transcriptTokens(lines); got != 1182 {
+		t.Errorf("transcriptTokens() = %d, want 1182", got)
+	}
+}
+
+func TestParseShortstat(t *testing.T) {
+	f, i, d := parseShortstat(" 3 files changed, 42 insertions(+), 7 deletions(-)\n")
+	if f != 3 || i != 42 || d != 7 {
+		t.Errorf("parseShortstat() = %d, %d, %d", f, i, d)
+	}
+	if f, i, d := parseShortstat(" 1 file changed, 1 deletion(-)"); f != 1 || i != 0 || d != 1 {
+		t.Errorf("parseShortstat(deletion only) = %d, %d, %d", f, i, d)
+	}
+}
+
+func TestFormatBench(t *testing.T) {
+	table := FormatBench([]BenchResult{
+		{Variant: "baseline", Run: 1, Completed: true, Attempts: 2, Duration: 4 * time.Minute, Tokens: 1000, Files: 2, Insertions: 30, Deletions: 4, Tests: "pass"},
+		{Variant: "baseline", Run: 2, Completed: false, Attempts: 4, Duration: 8 * time.Minute, Tokens: 3000, Files: 4, Insertions: 50, Deletions: 6, Tests: "fail"},
+		{Variant: "opencode", Run: 1, Completed: true, Attempts: 1, Duration: time.Minute, Tests: "pass"},
+	})
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("table:\n%s", table)
+	}
+	for _, want := range []string{"baseline", "1/2", "3.0", "6m0s", "2000", "+40 -5 (3)", "1/2 pass"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("baseline row %q missing %q", lines[1], want)
+		}
+	}
+	// Harnesses without Claude transcripts report no tokens.
+	if f := strings.Fields(lines[2]); f[0] != "opencode" || f[4] != "-" {
+		t.Errorf("opencode row = %q", lines[2])
+	}
+}
@@ -0,0 +1,75 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultBranch asks origin which branch its HEAD points at, so agents start
+// from the repo's own default (main, master, develop, ...) rather than a guess.
+func defaultBranch(name string, layout Layout) (string, error) {
+	out, err := podman("exec", containerName(name), "sh", "-c",
+		layout.cd()+"git ls-remote --symref origin HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot read the repo's default branch (did the clone fail?): %w", err)
+	}
+	branch := parseSymref(string(out))
+	if branch == "" {
+		return "", fmt.Errorf("origin did not report a default branch")
+	}
+	return branch, nil
+}
+
+// parseSymref picks the branch out of `git ls-remote --symref origin HEAD`:
+//
+//	ref: refs/heads/develop	HEAD
+//	3f2a...	HEAD
+func parseSymref(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		ref, target, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok || strings.TrimSpace(target) != "HEAD" {
+			continue
+		}
+		if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok {
+			return branch
+		}
+	}
+	return ""
+}
+
+// checkoutBranch resolves the repo's default branch and checks out the
+// requested branch, which must exist; an empty branch means the default. It
+// returns the default branch and the branch checked out.
+func checkoutBranch(name, repo, branch string, layout Layout) (base, checkedOut string, err error) {
+	base, err = defaultBranch(name, layout)
+	if err != nil {
+		return "", "", err
+	}
+	if branch == "" || branch == base {
+		return base, base, nil
+	}
+	out, err := podman("exec", containerName(name), "sh", "-c",
+		layout.cd()+"git checkout "+shellQuote(branch)).CombinedOutput()
+	if err != nil {
+		return base, "", fmt.Errorf("branch %q does not exist in %s (its default branch is %s): %s",
+			branch, repo, base, strings.TrimSpace(string(out)))
+	}
+	return base, branch, nil
+}
+
+// startBranch creates branch from where the agent is and checks it out, so
+// a workflow's commits go up as a PR rather than onto the base.
+func startBranch(name, branch string) error {
+	if _, err := loadAgent(name); err != nil {
+		return fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	out, err := podman("exec", containerName(name), "sh", "-c",
+		layoutOf(name).cd()+"git checkout -b "+shellQuote(branch)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot create branch %s: %w: %s", branch, err, strings.TrimSpace(string(out)))
+	}
+	return updateAgent(name, func(agent *Agent) error {
+		agent.Branch = branch
+		return nil
+	})
+}
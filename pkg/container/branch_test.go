@@ -0,0 +1,42 @@
+package container
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseSymref(t *testing.T) {
+	out := "ref: refs/heads/develop\tHEAD\n3f2a9c1e\tHEAD\n"
+	if got := parseSymref(out); got != "develop" {
+		t.Errorf("parseSymref = %q, want develop", got)
+	}
+	if got := parseSymref("3f2a9c1e\tHEAD\n"); got != "" {
+		t.Errorf("a detached HEAD has no branch, got %q", got)
+	}
+}
+
+func TestCheckoutBranch(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fakePodman(t, `case "$*" in
+*ls-remote*) printf 'ref: refs/heads/trunk\tHEAD\nabc123\tHEAD\n' ;;
+*"checkout 'feature'"*) exit 0 ;;
+*checkout*) echo "error: pathspec 'nope' did not match any file(s) known to git" >&2; exit 1 ;;
+esac`)
+
+	base, branch, err := checkoutBranch("a1", "https://github.com/org/api", "", Layout{})
+	if err != nil || base != "trunk" || branch != "trunk" {
+		t.Errorf("default = %q, %q, %v; want trunk, trunk", base, branch, err)
+	}
+	if base, branch, err = checkoutBranch("a1", "https://github.com/org/api", "feature", Layout{}); err != nil || base != "trunk" || branch != "feature" {
+		t.Errorf("feature = %q, %q, %v", base, branch, err)
+	}
+	_, _, err = checkoutBranch("a1", "https://github.com/org/api", "nope", Layout{})
+	if err == nil || !strings.Contains(err.Error(), `branch "nope" does not exist`) || !strings.Contains(err.Error(), "default branch is trunk") {
+		t.Errorf("missing branch error = %v", err)
+	}
+}
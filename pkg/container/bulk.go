@@ -0,0 +1,192 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultBulkConcurrency bounds how many items of a bulk operation run at
+// once when the caller doesn't say.
+const DefaultBulkConcurrency = 4
+
+// BulkSpec is one item of a bulk spawn, run or kill. Kill needs only the
+// name; run also needs the task.
+type BulkSpec struct {
+	Name      string   `json:"name"`
+	Repo      string   `json:"repo,omitempty"`
+	Branch    string   `json:"branch,omitempty"`
+	Image     string   `json:"image,omitempty"`
+	Profile   string   `json:"profile,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	Intent    string   `json:"intent,omitempty"`
+	Setup     []string `json:"setup,omitempty"`
+	NoSetup   bool     `json:"no_setup,omitempty"`
+	After     []string `json:"after,omitempty"`
+	Needs     []string `json:"needs,omitempty"`
+	Issue     int      `json:"issue,omitempty"`
+	Yolo      bool     `json:"yolo,omitempty"`
+	Template  string   `json:"template,omitempty"`
+
+	Task        string `json:"task,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+	// OutputPatch runs the task in patch-only mode (RunForPatch).
+	OutputPatch bool `json:"output_patch,omitempty"`
+	// DoneWhen replaces the test suite as the completion check.
+	DoneWhen string `json:"done_when,omitempty"`
+	// ContinueSession resumes each attempt's Claude session in the next.
+	ContinueSession bool `json:"continue_session,omitempty"`
+}
+
+// SpawnOptions converts the spec into options for SpawnWithOptions.
+func (s BulkSpec) SpawnOptions() SpawnOptions {
+	return SpawnOptions{
+		Name: s.Name, Repo: s.Repo, Branch: s.Branch, Image: s.Image, Profile: s.Profile,
+		Workspace: s.Workspace, Model: s.Model, Intent: s.Intent,
+		SetupCommands: s.Setup, SkipSetup: s.NoSetup, After: s.After, Needs: s.Needs, Issue: s.Issue, Yolo: s.Yolo, Template: s.Template,
+	}
+}
+
+// BulkResult reports one item of a bulk operation.
+type BulkResult struct {
+	Index int    `json:"index"` // position in the input array
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+
+	ContainerID string `json:"container_id,omitempty"` // spawn
+	Port        int    `json:"port,omitempty"`         // spawn
+	Attempts    int    `json:"attempts,omitempty"`     // run
+	PR          string `json:"pr,omitempty"`           // workflow deps
+	Note        string `json:"note,omitempty"`
+}
+
+// BulkOp performs one item of a bulk operation.
+type BulkOp func(BulkSpec) BulkResult
+
+// ReadBulkSpecs parses a JSON array of specs, checking the fields op needs
+// ("spawn", "run" or "kill").
+func ReadBulkSpecs(r io.Reader, op string) ([]BulkSpec, error) {
+	var specs []BulkSpec
+	if err := json.NewDecoder(r).Decode(&specs); err != nil {
+		return nil, fmt.Errorf("invalid JSON array of agent specs: %w", err)
+	}
+	seen := map[string]bool{}
+	for i, s := range specs {
+		switch {
+		case s.Name == "":
+			return nil, fmt.Errorf("item %d: no name", i)
+		case seen[s.Name]:
+			return nil, fmt.Errorf("item %d: agent %s listed twice", i, s.Name)
+		case op == "spawn" && s.Repo == "":
+			return nil, fmt.Errorf("item %d (%s): spawn needs a repo", i, s.Name)
+		case op == "run" && s.Task == "":
+			return nil, fmt.Errorf("item %d (%s): run needs a task", i, s.Name)
+		}
+		seen[s.Name] = true
+	}
+	lookup := specNeeds(specs)
+	for i, s := range specs {
+		if err := checkNeeds(s.Name, s.Needs, lookup); err != nil {
+			return nil, fmt.Errorf("item %d (%s): %w", i, s.Name, err)
+		}
+	}
+	return specs, nil
+}
+
+// BulkOpFor returns the operation for a command name.
+func BulkOpFor(op string) (BulkOp, error) {
+	switch op {
+	case "spawn":
+		return bulkSpawn, nil
+	case "run":
+		return bulkRun, nil
+	case "kill":
+		return bulkKill, nil
+	}
+	return nil, fmt.Errorf("no bulk %s (want spawn, run or kill)", op)
+}
+
+// Bulk applies op to every spec, at most concurrency at a time, and calls
+// emit with each result as it finishes. emit calls are serialized. Specs
+// start after those they need, so an agent waiting on another never holds a
+// slot the other is queued for.
+func Bulk(specs []BulkSpec, concurrency int, op BulkOp, emit func(BulkResult)) {
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, i := range startOrder(specs) {
+		s := specs[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, s BulkSpec) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := op(s)
+			res.Index, res.Name = i, s.Name
+			mu.Lock()
+			emit(res)
+			mu.Unlock()
+		}(i, s)
+	}
+	wg.Wait()
+}
+
+func bulkSpawn(s BulkSpec) BulkResult {
+	opts := s.SpawnOptions()
+	opts.WaitForCapacity = true // queue behind the host's capacity limits
+	agent, err := SpawnWithOptions(opts)
+	var res BulkResult
+	if agent != nil {
+		res.ContainerID, res.Port = agent.ContainerID, agent.Port
+	}
+	var setupErr *SetupError
+	if errors.As(err, &setupErr) {
+		res.Error = fmt.Sprintf("spawned but repo setup failed: %v (log: %s)", err, setupErr.Log)
+		return res
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.OK = true
+	return res
+}
+
+// SpawnAndRun spawns the agent, then runs its task to completion.
+func SpawnAndRun(s BulkSpec) BulkResult {
+	if res := bulkSpawn(s); !res.OK {
+		return res
+	}
+	return bulkRun(s)
+}
+
+func bulkRun(s BulkSpec) BulkResult {
+	tr, err := RunWithOptions(s.Name, s.Task, s.MaxAttempts, RunOptions{OutputPatch: s.OutputPatch, DoneWhen: s.DoneWhen, ContinueSession: s.ContinueSession})
+	var res BulkResult
+	if tr != nil {
+		res.Attempts = tr.Attempts
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.OK = true
+	return res
+}
+
+func bulkKill(s BulkSpec) BulkResult {
+	if _, err := loadAgent(s.Name); err != nil {
+		return BulkResult{Error: err.Error()}
+	}
+	if err := Kill(s.Name); err != nil {
+		return BulkResult{Error: err.Error()}
+	}
+	return BulkResult{OK: true}
+}
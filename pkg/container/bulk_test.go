@@ -0,0 +1,84 @@
+package container
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadBulkSpecs(t *testing.T) {
+	specs, err := ReadBulkSpecs(strings.NewReader(`[
+		{"name": "a1", "repo": "https://github.com/org/api", "model": "cloud-fast", "setup": ["make deps"]},
+		{"name": "a2", "repo": "https://github.com/org/api", "branch": "dev", "task": "fix it"}
+	]`), "spawn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := specs[0].SpawnOptions()
+	if opts.Branch != "" || opts.Model != "cloud-fast" || opts.SetupCommands[0] != "make deps" {
+		t.Errorf("SpawnOptions() = %+v", opts)
+	}
+	if specs[1].SpawnOptions().Branch != "dev" {
+		t.Error("branch not kept")
+	}
+
+	for _, tc := range []struct{ op, input, want string }{
+		{"spawn", `{"name": "a1"}`, "invalid JSON array"},
+		{"kill", `[{"repo": "r"}]`, "item 0: no name"},
+		{"kill", `[{"name": "a1"}, {"name": "a1"}]`, "listed twice"},
+		{"spawn", `[{"name": "a1"}]`, "spawn needs a repo"},
+		{"run", `[{"name": "a1"}]`, "run needs a task"},
+	} {
+		if _, err := ReadBulkSpecs(strings.NewReader(tc.input), tc.op); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s %s: err = %v, want %q", tc.op, tc.input, err, tc.want)
+		}
+	}
+	if _, err := ReadBulkSpecs(strings.NewReader(`[{"name": "a1"}]`), "kill"); err != nil {
+		t.Errorf("kill by name: %v", err)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	var specs []BulkSpec
+	for _, n := range []string{"a", "b", "c", "d", "e", "f"} {
+		specs = append(specs, BulkSpec{Name: n})
+	}
+	var running, peak int32
+	op := func(s BulkSpec) BulkResult {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if s.Name == "c" {
+			return BulkResult{Error: "boom"}
+		}
+		return BulkResult{OK: true}
+	}
+
+	var mu sync.Mutex
+	got := map[string]BulkResult{}
+	Bulk(specs, 2, op, func(r BulkResult) {
+		mu.Lock()
+		got[r.Name] = r
+		mu.Unlock()
+	})
+	if peak > 2 {
+		t.Errorf("%d items ran at once, want at most 2", peak)
+	}
+	if len(got) != len(specs) {
+		t.Fatalf("%d results for %d items", len(got), len(specs))
+	}
+	if r := got["c"]; r.OK || r.Error != "boom" || r.Index != 2 {
+		t.Errorf("failed item = %+v", r)
+	}
+	if r := got["f"]; !r.OK || r.Index != 5 {
+		t.Errorf("last item = %+v", r)
+	}
+}
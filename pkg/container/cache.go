@@ -0,0 +1,209 @@
+package container
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheDirStats summarizes one shared cache directory.
+type CacheDirStats struct {
+	Kind   string
+	Path   string
+	Size   int64
+	Files  int
+	Oldest time.Time
+	Newest time.Time
+}
+
+// CacheStats reports the size and age of each shared cache directory.
+func CacheStats() ([]CacheDirStats, error) {
+	var stats []CacheDirStats
+	for _, kind := range cacheKinds {
+		st := CacheDirStats{Kind: kind, Path: filepath.Join(cacheDir(), kind)}
+		err := walkCacheFiles(st.Path, func(f cacheFile) {
+			st.Size += f.size
+			st.Files++
+			if st.Oldest.IsZero() || f.modTime.Before(st.Oldest) {
+				st.Oldest = f.modTime
+			}
+			if f.modTime.After(st.Newest) {
+				st.Newest = f.modTime
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+// CachePruneOptions selects what PruneCache removes.
+type CachePruneOptions struct {
+	// OlderThan removes files not modified within this window (0: no age limit).
+	OlderThan time.Duration
+	// MaxSize then removes the oldest files until the cache fits (0: no size limit).
+	MaxSize int64
+	// DryRun reports what would be removed without deleting anything.
+	DryRun bool
+}
+
+// CachePruneResult reports what PruneCache removed (or would remove).
+type CachePruneResult struct {
+	Files     int
+	Bytes     int64
+	Remaining int64
+}
+
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// PruneCache trims the shared cache by age and then by total size, oldest
+// files first, and removes directories left empty.
+func PruneCache(opts CachePruneOptions) (*CachePruneResult, error) {
+	var files []cacheFile
+	var total int64
+	for _, kind := range cacheKinds {
+		err := walkCacheFiles(filepath.Join(cacheDir(), kind), func(f cacheFile) {
+			files = append(files, f)
+			total += f.size
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	res := &CachePruneResult{}
+	cutoff := time.Now().Add(-opts.OlderThan)
+	for _, f := range files {
+		expired := opts.OlderThan > 0 && f.modTime.Before(cutoff)
+		oversize := opts.MaxSize > 0 && total-res.Bytes > opts.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+		if !opts.DryRun {
+			if err := os.Remove(f.path); err != nil {
+				continue
+			}
+		}
+		res.Files++
+		res.Bytes += f.size
+	}
+	res.Remaining = total - res.Bytes
+
+	if !opts.DryRun {
+		for _, kind := range cacheKinds {
+			removeEmptyDirs(filepath.Join(cacheDir(), kind))
+		}
+	}
+	return res, nil
+}
+
+// walkCacheFiles calls fn for every regular file under root. A missing root
+// is treated as empty.
+func walkCacheFiles(root string, fn func(cacheFile)) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fn(cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// removeEmptyDirs deletes empty directories below root, deepest first,
+// keeping root itself.
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i]) // fails harmlessly when not empty
+	}
+}
+
+// ParseSize parses a human size such as "512M", "20G" or "1.5T" into bytes.
+// Units are binary (K=1024); a bare number is bytes.
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSpace(strings.ToUpper(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 512M, 20G)", size)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// FormatSize renders bytes with a binary unit, e.g. "1.5G".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseAge parses a duration that may also use days, e.g. "30d" or "36h".
+func ParseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 12h)", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 12h)", s)
+	}
+	return d, nil
+}
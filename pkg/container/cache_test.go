@@ -0,0 +1,189 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// writeCacheFile creates a cache file of the given size and age.
+func writeCacheFile(t *testing.T, rel string, size int, age time.Duration) string {
+	t.Helper()
+	path := filepath.Join(cacheDir(), rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCacheStats(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	writeCacheFile(t, "npm/a", 100, time.Hour)
+	writeCacheFile(t, "npm/sub/b", 50, 48*time.Hour)
+
+	stats, err := CacheStats()
+	if err != nil {
+		t.Fatalf("CacheStats() error: %v", err)
+	}
+	if len(stats) != len(cacheKinds) {
+		t.Fatalf("got %d entries, want %d", len(stats), len(cacheKinds))
+	}
+	for _, st := range stats {
+		if st.Kind == "npm" {
+			if st.Size != 150 || st.Files != 2 {
+				t.Errorf("npm stats = %+v", st)
+			}
+		} else if st.Size != 0 {
+			t.Errorf("%s should be empty, got %+v", st.Kind, st)
+		}
+	}
+}
+
+func TestPruneCacheByAge(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fresh := writeCacheFile(t, "composer/fresh", 10, time.Hour)
+	stale := writeCacheFile(t, "composer/old/stale", 20, 40*24*time.Hour)
+
+	res, err := PruneCache(CachePruneOptions{OlderThan: 30 * 24 * time.Hour, DryRun: true})
+	if err != nil {
+		t.Fatalf("PruneCache() error: %v", err)
+	}
+	if res.Files != 1 || res.Bytes != 20 {
+		t.Errorf("dry run result = %+v", res)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Error("dry run must not delete files")
+	}
+
+	if _, err := PruneCache(CachePruneOptions{OlderThan: 30 * 24 * time.Hour}); err != nil {
+		t.Fatalf("PruneCache() error: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale file should be removed")
+	}
+	if _, err := os.Stat(filepath.Dir(stale)); !os.IsNotExist(err) {
+		t.Error("emptied directory should be removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("fresh file should be kept")
+	}
+}
+
+func TestPruneCacheBySize(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	oldest := writeCacheFile(t, "pip/oldest", 100, 3*time.Hour)
+	middle := writeCacheFile(t, "go-mod/middle", 100, 2*time.Hour)
+	newest := writeCacheFile(t, "npm/newest", 100, time.Hour)
+
+	res, err := PruneCache(CachePruneOptions{MaxSize: 150})
+	if err != nil {
+		t.Fatalf("PruneCache() error: %v", err)
+	}
+	if res.Files != 2 || res.Remaining != 100 {
+		t.Errorf("result = %+v", res)
+	}
+	for _, p := range []string{oldest, middle} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", p)
+		}
+	}
+	if _, err := os.Stat(newest); err != nil {
+		t.Error("newest file should be kept")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"512", 512},
+		{"1K", 1024},
+		{"512M", 512 << 20},
+		{"20G", 20 << 30},
+		{"20gb", 20 << 30},
+		{"1.5GiB", 3 << 29},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "G", "lots", "-1G"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{512: "512B", 1536: "1.5K", 20 << 30: "20.0G"} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	if d, err := ParseAge("30d"); err != nil || d != 30*24*time.Hour {
+		t.Errorf("ParseAge(30d) = %v, %v", d, err)
+	}
+	if d, err := ParseAge("12h"); err != nil || d != 12*time.Hour {
+		t.Errorf("ParseAge(12h) = %v, %v", d, err)
+	}
+	if _, err := ParseAge("soon"); err == nil {
+		t.Error("ParseAge(soon) should fail")
+	}
+}
+
+func TestQuotaArgs(t *testing.T) {
+	opts := SpawnOptions{DiskQuota: "10G"}
+	args, err := quotaArgs(&opts, config.Quota{Tmpfs: "2G", Disk: "50G"})
+	if err != nil {
+		t.Fatalf("quotaArgs() error: %v", err)
+	}
+	want := []string{"--tmpfs", "/tmp:rw,size=2147483648", "--storage-opt", "size=10737418240"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("quotaArgs() = %v, want %v", args, want)
+	}
+	if opts.TmpfsSize != "2G" {
+		t.Errorf("config default should be recorded on opts, got %q", opts.TmpfsSize)
+	}
+
+	args, err = quotaArgs(&SpawnOptions{TmpfsSize: "1.5GiB", DiskQuota: "20gb"}, config.Quota{})
+	if err != nil {
+		t.Fatalf("quotaArgs() error: %v", err)
+	}
+	want = []string{"--tmpfs", "/tmp:rw,size=1610612736", "--storage-opt", "size=21474836480"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("quotaArgs(1.5GiB, 20gb) = %v, want %v", args, want)
+	}
+
+	if _, err := quotaArgs(&SpawnOptions{TmpfsSize: "huge"}, config.Quota{}); err == nil {
+		t.Error("invalid size should fail")
+	}
+}
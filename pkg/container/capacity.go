@@ -0,0 +1,202 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// defaultCapacityPoll is how often a spawn waiting for capacity checks again.
+const defaultCapacityPoll = 15 * time.Second
+
+// HostUsage is how loaded the host is. Measurements that can't be taken on
+// this host are -1 and never count against a limit.
+type HostUsage struct {
+	CPU      float64 `json:"cpu"`       // percent of cores busy (1-minute load)
+	Memory   float64 `json:"memory"`    // percent of memory in use
+	DiskFree int64   `json:"disk_free"` // bytes free on podman's storage
+	Agents   int     `json:"agents"`    // agent containers running
+}
+
+// procRoot is swapped out in tests.
+var procRoot = "/proc"
+
+// ReadHostUsage measures the host and podman's share of it.
+func ReadHostUsage() HostUsage {
+	u := HostUsage{CPU: -1, Memory: -1, DiskFree: -1}
+	if data, err := os.ReadFile(filepath.Join(procRoot, "loadavg")); err == nil {
+		if f := strings.Fields(string(data)); len(f) > 0 {
+			if load, err := strconv.ParseFloat(f[0], 64); err == nil {
+				u.CPU = load / float64(runtime.NumCPU()) * 100
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(procRoot, "meminfo")); err == nil {
+		u.Memory = memoryUsed(string(data))
+	}
+	u.DiskFree = storageFree()
+	if statuses, err := containerStatuses(); err == nil {
+		for _, a := range loadAgents() {
+			if statuses[containerName(a.Name)] == "running" {
+				u.Agents++
+			}
+		}
+	}
+	return u
+}
+
+// memoryUsed reads /proc/meminfo: the share of MemTotal not MemAvailable.
+func memoryUsed(meminfo string) float64 {
+	fields := map[string]float64{}
+	for _, line := range strings.Split(meminfo, "\n") {
+		key, rest, ok := strings.Cut(line, ":")
+		if f := strings.Fields(rest); ok && len(f) > 0 {
+			if v, err := strconv.ParseFloat(f[0], 64); err == nil {
+				fields[key] = v
+			}
+		}
+	}
+	total, avail := fields["MemTotal"], fields["MemAvailable"]
+	if total <= 0 {
+		return -1
+	}
+	return (total - avail) / total * 100
+}
+
+// storageFree returns the free bytes on the filesystem holding podman's
+// containers, or -1.
+func storageFree() int64 {
+	dir, _ := os.UserHomeDir()
+	if out, err := podman("info", "--format", "{{.Store.GraphRoot}}").Output(); err == nil && strings.TrimSpace(string(out)) != "" {
+		dir = strings.TrimSpace(string(out))
+	}
+	out, err := exec.Command("df", "-Pk", dir).Output()
+	if err != nil {
+		return -1
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	f := strings.Fields(lines[len(lines)-1])
+	if len(f) < 4 {
+		return -1
+	}
+	kb, err := strconv.ParseInt(f[3], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return kb * 1024
+}
+
+// capacityConfigured reports whether any host capacity limit is set.
+func capacityConfigured(c config.Capacity) bool {
+	return c.MaxCPU > 0 || c.MaxMemory > 0 || c.MinFreeDisk != "" || c.MaxAgents > 0
+}
+
+// overCapacity says which limit u is over, or "" when there is room.
+func overCapacity(u HostUsage, c config.Capacity) string {
+	var over []string
+	if c.MaxCPU > 0 && u.CPU >= c.MaxCPU {
+		over = append(over, fmt.Sprintf("CPU %.0f%% ≥ %.0f%%", u.CPU, c.MaxCPU))
+	}
+	if c.MaxMemory > 0 && u.Memory >= c.MaxMemory {
+		over = append(over, fmt.Sprintf("memory %.0f%% ≥ %.0f%%", u.Memory, c.MaxMemory))
+	}
+	if min, err := ParseSize(c.MinFreeDisk); err == nil && min > 0 && u.DiskFree >= 0 && u.DiskFree < min {
+		over = append(over, fmt.Sprintf("disk %s free < %s", FormatSize(u.DiskFree), c.MinFreeDisk))
+	}
+	if c.MaxAgents > 0 && u.Agents >= c.MaxAgents {
+		over = append(over, fmt.Sprintf("%d agents running ≥ %d", u.Agents, c.MaxAgents))
+	}
+	return strings.Join(over, ", ")
+}
+
+// readHostUsage is swapped out in tests.
+var readHostUsage = ReadHostUsage
+
+// CheckCapacity fails with ErrNoCapacity while the host is over a limit or,
+// given a repo, the repo has no free agent slot.
+func CheckCapacity(c config.Capacity, repo string) error {
+	if capacityConfigured(c) {
+		if err := readHostUsage().Check(c); err != nil {
+			return err
+		}
+	}
+	return checkRepoSlots(c, repo)
+}
+
+// checkRepoSlots fails with ErrNoCapacity while repo's bus counts
+// MaxAgentsPerRepo agents at work. A bus that can't be read doesn't block.
+func checkRepoSlots(c config.Capacity, repo string) error {
+	if c.MaxAgentsPerRepo <= 0 || repo == "" {
+		return nil
+	}
+	st, err := coordination.GetState(repo)
+	if err != nil {
+		return nil
+	}
+	if active := st.ActiveAgents(time.Now(), coordination.HeartbeatTimeout()); len(active) >= c.MaxAgentsPerRepo {
+		return fmt.Errorf("%w: %d agents on %s ≥ %d (%s)", ErrNoCapacity,
+			len(active), repo, c.MaxAgentsPerRepo, strings.Join(active, ", "))
+	}
+	return nil
+}
+
+// Check fails with ErrNoCapacity if u is over a limit of c.
+func (u HostUsage) Check(c config.Capacity) error {
+	if over := overCapacity(u, c); over != "" {
+		return fmt.Errorf("%w: %s", ErrNoCapacity, over)
+	}
+	return nil
+}
+
+// WaitForCapacity blocks until the host is under every limit and, given a
+// repo, the repo has a free agent slot.
+func WaitForCapacity(c config.Capacity, repo string) {
+	poll := defaultCapacityPoll
+	if d, err := time.ParseDuration(c.Poll); err == nil && d > 0 {
+		poll = d
+	}
+	last := ""
+	for {
+		err := CheckCapacity(c, repo)
+		if err == nil {
+			return
+		}
+		if err.Error() != last {
+			fmt.Printf("⏳ Waiting for capacity (%v)\n", err)
+			last = err.Error()
+		}
+		time.Sleep(poll)
+	}
+}
+
+// admitMu queues this process's spawns through the capacity check one at a
+// time, so a bulk spawn can't start them all on one reading.
+var admitMu sync.Mutex
+
+// admit lets a spawn on repo go ahead once the host and repo have room —
+// straight away or failing with ErrNoCapacity unless wait is set. The
+// returned function ends the admission; it may be called more than once.
+func admit(c config.Capacity, repo string, wait bool) (func(), error) {
+	if !capacityConfigured(c) && (c.MaxAgentsPerRepo <= 0 || repo == "") {
+		return func() {}, nil
+	}
+	admitMu.Lock()
+	var once sync.Once
+	release := func() { once.Do(admitMu.Unlock) }
+	if wait {
+		WaitForCapacity(c, repo)
+	} else if err := CheckCapacity(c, repo); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
@@ -0,0 +1,153 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestReadHostUsage(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	proc := t.TempDir()
+	load := float64(runtime.NumCPU()) / 2
+	os.WriteFile(filepath.Join(proc, "loadavg"), []byte(fmt.Sprintf("%.2f 0.50 0.50 1/100 1234\n", load)), 0644)
+	os.WriteFile(filepath.Join(proc, "meminfo"), []byte("MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    4000000 kB\n"), 0644)
+	orig := procRoot
+	procRoot = proc
+	defer func() { procRoot = orig }()
+
+	fakePodman(t, `case "$*" in
+ps*) echo '[{"Names": ["a1"], "State": "running"}, {"Names": ["a2"], "State": "exited"}, {"Names": ["other"], "State": "running"}]' ;;
+esac`)
+	saveAgent(&Agent{Name: "a1"})
+	saveAgent(&Agent{Name: "a2"})
+
+	u := ReadHostUsage()
+	if u.CPU != 50 || u.Memory != 75 || u.Agents != 1 {
+		t.Errorf("usage = %+v", u)
+	}
+	if u.DiskFree <= 0 {
+		t.Errorf("free disk of $HOME = %d", u.DiskFree)
+	}
+
+	procRoot = t.TempDir() // no /proc: not measured, never over a limit
+	u = ReadHostUsage()
+	if u.CPU != -1 || u.Memory != -1 || u.Check(config.Capacity{MaxCPU: 1, MaxMemory: 1}) != nil {
+		t.Errorf("usage without /proc = %+v", u)
+	}
+}
+
+func TestHostUsageCheck(t *testing.T) {
+	u := HostUsage{CPU: 90, Memory: 40, DiskFree: 5 << 30, Agents: 3}
+	if err := u.Check(config.Capacity{MaxCPU: 95, MaxMemory: 80, MinFreeDisk: "4G", MaxAgents: 4}); err != nil {
+		t.Errorf("under every limit: %v", err)
+	}
+	err := u.Check(config.Capacity{MaxCPU: 85, MinFreeDisk: "10G", MaxAgents: 3})
+	if !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("Check = %v", err)
+	}
+	for _, want := range []string{"CPU 90% ≥ 85%", "disk 5.0G free < 10G", "3 agents running ≥ 3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q missing from %v", want, err)
+		}
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	orig := readHostUsage
+	defer func() { readHostUsage = orig }()
+	var mu sync.Mutex
+	running := 2
+	readHostUsage = func() HostUsage {
+		mu.Lock()
+		defer mu.Unlock()
+		return HostUsage{CPU: -1, Memory: -1, DiskFree: -1, Agents: running}
+	}
+	limits := config.Capacity{MaxAgents: 2, Poll: "10ms"}
+
+	if _, err := admit(limits, "", false); !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("admit without waiting = %v", err)
+	}
+
+	admitted := make(chan struct{})
+	go func() {
+		release, _ := admit(limits, "", true)
+		release()
+		close(admitted)
+	}()
+	select {
+	case <-admitted:
+		t.Fatal("admitted while at capacity")
+	case <-time.After(50 * time.Millisecond):
+	}
+	mu.Lock()
+	running = 1
+	mu.Unlock()
+	select {
+	case <-admitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting with room to spawn")
+	}
+
+	release, err := admit(config.Capacity{}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestAdmitRepoSlots(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/test/" + t.Name()
+	if _, err := coordination.Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	coordination.UpdateAgentState(repo, "a", "working", "a")
+	coordination.UpdateAgentState(repo, "b", coordination.StatusSpawned, "b")
+	limits := config.Capacity{MaxAgentsPerRepo: 2, Poll: "10ms"}
+
+	_, err := admit(limits, repo, false)
+	if !errors.Is(err, ErrNoCapacity) || !strings.Contains(err.Error(), "2 agents on "+repo+" ≥ 2 (a, b)") {
+		t.Fatalf("admit on a full repo = %v", err)
+	}
+	release, err := admit(limits, "https://github.com/test/other", false)
+	if err != nil {
+		t.Fatalf("admit on another repo = %v", err)
+	}
+	release()
+
+	admitted := make(chan struct{})
+	go func() {
+		release, _ := admit(limits, repo, true)
+		release()
+		close(admitted)
+	}()
+	select {
+	case <-admitted:
+		t.Fatal("admitted while the repo is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	coordination.UpdateAgentState(repo, "a", "done", "a")
+	select {
+	case <-admitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting with a free slot")
+	}
+}
@@ -0,0 +1,120 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// Checkpoint is RunUntilDone's loop state, written after every attempt so an
+// interrupted run (host process killed, laptop asleep) can be resumed.
+type Checkpoint struct {
+	Agent string `json:"agent"`
+	// Task is the task as accumulated so far, including notes the loop has
+	// added to it (e.g. rebase requests from other agents).
+	Task        string    `json:"task"`
+	Attempt     int       `json:"attempt"` // attempts finished
+	MaxAttempts int       `json:"max_attempts"`
+	LoopStart   time.Time `json:"loop_start"`
+	Updated     time.Time `json:"updated"`
+	LastStatus  string    `json:"last_status,omitempty"` // tests=… uncommitted=…
+	// Settled lists the agent's dependencies (Agent.After) already merged
+	// or removed, and so handled.
+	Settled []string `json:"settled,omitempty"`
+	// OutputPatch marks a patch-only run (RunForPatch), whose change is
+	// diffed against PatchBase, HEAD when the run started.
+	OutputPatch bool   `json:"output_patch,omitempty"`
+	PatchBase   string `json:"patch_base,omitempty"`
+	// DoneWhen replaces the test suite in deciding when the run is done.
+	DoneWhen string `json:"done_when,omitempty"`
+	// ContinueSession has each retry resume Session, the Claude session
+	// the last attempt left, rather than start a new conversation.
+	ContinueSession bool   `json:"continue_session,omitempty"`
+	Session         string `json:"session,omitempty"`
+	// Failure is the class of the last attempt's failure, which picks the
+	// next attempt's prompt; SetupRerun says setup ran again after it.
+	Failure    string `json:"failure,omitempty"`
+	SetupRerun bool   `json:"setup_rerun,omitempty"`
+}
+
+func checkpointDir() string {
+	return filepath.Join(namespace.Root(), "checkpoints")
+}
+
+func checkpointPath(name string) string {
+	return filepath.Join(checkpointDir(), name+".json")
+}
+
+func saveCheckpoint(cp *Checkpoint) error {
+	if err := os.MkdirAll(checkpointDir(), 0755); err != nil {
+		return err
+	}
+	cp.Updated = time.Now()
+	data, _ := json.MarshalIndent(cp, "", "  ")
+	// The task is sealed with history when encryption is on.
+	data, err := seal(data)
+	if err != nil {
+		return err
+	}
+	// A crash mid-write can't leave a torn checkpoint.
+	return writeFileAtomic(checkpointPath(cp.Agent), data, 0600)
+}
+
+// LoadCheckpoint returns the saved run state of an interrupted run.
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	data, err := os.ReadFile(checkpointPath(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no interrupted run to resume for %s", name)
+	}
+	if err == nil {
+		data, err = unseal(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// hasCheckpoint reports whether the agent has a checkpoint, readable or not.
+func hasCheckpoint(name string) bool {
+	_, err := os.Stat(checkpointPath(name))
+	return err == nil
+}
+
+func removeCheckpoint(name string) {
+	os.Remove(checkpointPath(name))
+}
+
+// ResumeRun continues an interrupted RunUntilDone from its checkpoint, with
+// the same attempt count, task context and loop start. maxAttempts, if
+// non-zero, replaces the saved limit. Like RunUntilDone it holds the
+// agent's run lock.
+func ResumeRun(name string, maxAttempts int) (*TaskResult, error) {
+	cp, err := LoadCheckpoint(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := loadAgent(name); err != nil {
+		return nil, err
+	}
+	release, err := acquireRunLock(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if maxAttempts > 0 {
+		cp.MaxAttempts = maxAttempts
+	}
+	if cp.Attempt >= cp.MaxAttempts {
+		return &TaskResult{Attempts: cp.Attempt}, fmt.Errorf("%w: %s already used %d of %d attempts (pass a higher attempt count)", ErrMaxAttempts, name, cp.Attempt, cp.MaxAttempts)
+	}
+	return runLoop(name, cp)
+}
@@ -0,0 +1,60 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumeRun(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) echo OK ;;
+*run-task*) { echo "$*"; cat; } >> `+calls+` ;;
+*"test -f go.mod"*) exit 0 ;;
+*"go test"*) echo EXIT_CODE:0 ;;
+*"test -f"*) exit 1 ;;
+esac`)
+
+	if _, err := ResumeRun("worker", 0); err == nil {
+		t.Fatal("expected an error with no checkpoint")
+	}
+
+	saveAgent(&Agent{Name: "worker"})
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	saveCheckpoint(&Checkpoint{Agent: "worker", Task: "fix login\n\nrebase first", Attempt: 3, MaxAttempts: 3, LoopStart: start})
+	if _, err := ResumeRun("worker", 0); err == nil || !strings.Contains(err.Error(), "3 of 3") || !errors.Is(err, ErrMaxAttempts) {
+		t.Fatalf("ResumeRun() with no attempts left = %v", err)
+	}
+
+	cp, err := LoadCheckpoint("worker")
+	if err != nil || cp.Attempt != 3 || !cp.LoopStart.Equal(start) {
+		t.Fatalf("LoadCheckpoint() = %+v, %v", cp, err)
+	}
+
+	result, err := ResumeRun("worker", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Completed || result.Attempts != 4 {
+		t.Errorf("result = %+v, want completed on attempt 4", result)
+	}
+	out, _ := os.ReadFile(calls)
+	if !strings.Contains(string(out), "Continue working") || !strings.Contains(string(out), "rebase first") {
+		t.Errorf("resumed prompt lost the run's context: %s", out)
+	}
+	if _, err := LoadCheckpoint("worker"); err == nil {
+		t.Error("checkpoint should be removed once the task completes")
+	}
+	if h, err := LoadHistory("worker"); err != nil || h.Attempts != 4 || !h.Created.Equal(start) {
+		t.Errorf("history = %+v, %v", h, err)
+	}
+}
@@ -0,0 +1,119 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// Claim enforcement modes (coordination.claim_enforcement).
+const (
+	ClaimsInterrupt = "interrupt"
+	ClaimsWarn      = "warn"
+	ClaimsOff       = "off"
+)
+
+// ClaimConflict is an agent editing a file another agent has claimed.
+type ClaimConflict struct {
+	File   string
+	Holder string
+}
+
+// claimEnforcement returns the configured mode, "interrupt" by default.
+func claimEnforcement() (string, error) {
+	cfg, err := config.Load()
+	if err != nil || cfg.Coordination.ClaimEnforcement == "" {
+		return ClaimsInterrupt, nil
+	}
+	switch mode := cfg.Coordination.ClaimEnforcement; mode {
+	case ClaimsInterrupt, ClaimsWarn, ClaimsOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("coordination: unknown claim_enforcement %q (want interrupt, warn or off)", mode)
+	}
+}
+
+// enforceClaims checks the files written in transcript lines against the
+// bus: unclaimed ones are claimed for the agent, and writes to files another
+// agent holds are published as conflicts and returned.
+func enforceClaims(repoURL, name, mode string, lines []string) []ClaimConflict {
+	_, written := touchedFiles(lines, layoutOf(name).Workspace)
+	if len(written) == 0 {
+		return nil
+	}
+	claims, err := coordination.ListClaims(repoURL)
+	if err != nil {
+		return nil
+	}
+	var conflicts []ClaimConflict
+	for _, file := range written {
+		holder := ""
+		if c, ok := claims[file]; ok {
+			holder = c.Agent
+		} else if err := coordination.ClaimFile(repoURL, name, file); err != nil {
+			// Claimed by someone else since we listed.
+			holder, _, _ = coordination.IsFileClaimed(repoURL, file)
+		}
+		if holder == "" || holder == name {
+			continue
+		}
+		conflicts = append(conflicts, ClaimConflict{File: file, Holder: holder})
+		fmt.Printf("🔒 %s edited %s, claimed by %s\n", name, file, holder)
+		coordination.Publish(repoURL, coordination.Message{
+			Type:  coordination.MsgClaimConflict,
+			Agent: name,
+			Data:  map[string]string{"file": file, "holder": holder, "action": mode},
+		})
+	}
+	return conflicts
+}
+
+// watchClaims enforces claims on an attempt's edits until stop is closed. In
+// interrupt mode it delivers the first conflicts and stops watching.
+func watchClaims(repoURL, name, mode string, stop <-chan struct{}) <-chan []ClaimConflict {
+	conflicts := make(chan []ClaimConflict, 1)
+	scanner := newSessionScanner(name)
+	go func() {
+		ticker := time.NewTicker(GuardInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			lines, err := scanner.poll()
+			if err != nil {
+				continue
+			}
+			if found := enforceClaims(repoURL, name, mode, lines); len(found) > 0 && mode == ClaimsInterrupt {
+				conflicts <- found
+				return
+			}
+		}
+	}()
+	return conflicts
+}
+
+// interruptTask stops the agent process of a running attempt; the container
+// and its workspace stay as they are.
+func interruptTask(name string) error {
+	return podman("exec", containerName(name), "pkill", "-f", agentProcess.String()).Run()
+}
+
+// claimConflictNote tells the agent which files to keep out of after it was
+// interrupted for editing them.
+func claimConflictNote(conflicts []ClaimConflict) string {
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].File < conflicts[j].File })
+	var files []string
+	for _, c := range conflicts {
+		files = append(files, fmt.Sprintf("%s (claimed by %s)", c.File, c.Holder))
+	}
+	return "\n\nIMPORTANT: You were stopped for editing files other agents have claimed: " +
+		strings.Join(files, ", ") + ". Do not modify them. Revert your changes to them " +
+		"(git checkout -- <file>) and work around them."
+}
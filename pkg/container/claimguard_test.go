@@ -0,0 +1,92 @@
+package container
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestEnforceClaims(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/test/claims-enforce"
+	if _, err := coordination.Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	coordination.ClaimFile(repo, "other", "pkg/auth/token.go")
+	coordination.ClaimFile(repo, "me", "README.md")
+
+	edit := func(file string) string {
+		input, _ := json.Marshal(map[string]string{"file_path": file})
+		data, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+			Role:    "assistant",
+			Content: []contentBlock{{Type: "tool_use", Name: "Edit", Input: input}},
+		}})
+		return string(data)
+	}
+	conflicts := enforceClaims(repo, "me", ClaimsInterrupt, []string{
+		edit(DefaultWorkspace + "/pkg/auth/token.go"),
+		edit(DefaultWorkspace + "/main.go"),
+		edit(DefaultWorkspace + "/README.md"),
+	})
+
+	if len(conflicts) != 1 || conflicts[0] != (ClaimConflict{File: "pkg/auth/token.go", Holder: "other"}) {
+		t.Fatalf("conflicts = %v", conflicts)
+	}
+	if holder, _, _ := coordination.IsFileClaimed(repo, "main.go"); holder != "me" {
+		t.Errorf("main.go should be claimed for me, holder %q", holder)
+	}
+	if holder, _, _ := coordination.IsFileClaimed(repo, "pkg/auth/token.go"); holder != "other" {
+		t.Errorf("token.go should stay with other, holder %q", holder)
+	}
+
+	msgs, _ := coordination.ReadMessages(repo)
+	var found bool
+	for _, m := range msgs {
+		if m.Type == coordination.MsgClaimConflict {
+			found = m.Agent == "me" && m.Data["file"] == "pkg/auth/token.go" &&
+				m.Data["holder"] == "other" && m.Data["action"] == ClaimsInterrupt
+		}
+	}
+	if !found {
+		t.Errorf("no claim_conflict message in %+v", msgs)
+	}
+
+	note := claimConflictNote(conflicts)
+	if !strings.Contains(note, "pkg/auth/token.go (claimed by other)") {
+		t.Errorf("note should name the file and its holder: %q", note)
+	}
+}
+
+func TestAwaitTaskInterrupt(t *testing.T) {
+	stopped := filepath.Join(t.TempDir(), "stopped")
+	fakePodman(t, `case "$*" in
+*pkill*) touch `+stopped+` ;;
+*run-task*) while [ ! -f `+stopped+` ]; do sleep 0.05; done; exit 143 ;;
+esac`)
+
+	conflicts := make(chan []ClaimConflict, 1)
+	conflicts <- []ClaimConflict{{File: "a.go", Holder: "other"}}
+	done := make(chan struct{})
+	var got []ClaimConflict
+	go func() {
+		defer close(done)
+		_, _, got, _ = awaitTask("claims-await", "do it", "", nil, conflicts)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("awaitTask did not interrupt the task")
+	}
+	if len(got) != 1 || got[0].File != "a.go" {
+		t.Errorf("conflicts = %v", got)
+	}
+}
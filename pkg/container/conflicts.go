@@ -0,0 +1,225 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+	"github.com/jordanpartridge/agentctl/pkg/process"
+)
+
+// scopeFileListBytes bounds how much of the repo's file list goes into a
+// scope prediction.
+const scopeFileListBytes = 60000
+
+// ConflictRisk is a predicted file that work already under way holds or is
+// expected to touch.
+type ConflictRisk struct {
+	File  string `json:"file"`  // the predicted file
+	Agent string `json:"agent"` // the agent in the way
+	// Claimed is the agent's claim the file falls under; empty when the
+	// overlap is with the agent's predicted scope, whose intent is given.
+	Claimed string `json:"claimed,omitempty"`
+	Intent  string `json:"intent,omitempty"`
+}
+
+// ConflictPrediction is what a task is expected to touch and whom it would
+// collide with.
+type ConflictPrediction struct {
+	Files []string       `json:"files"`
+	Risks []ConflictRisk `json:"risks,omitempty"`
+	// After lists the agents to serialize behind (spawn --after), sorted.
+	After []string `json:"after,omitempty"`
+}
+
+// Swapped out in tests.
+var (
+	listRepoFiles = repoFiles
+	predictScope  = runScopePrediction
+)
+
+// PredictConflicts has the model predict, from repo's file list, which files
+// task will touch, and checks them against the repo's claims and the
+// predicted scopes of its live agents. Agents without a scope yet get one
+// predicted from their task, recorded for next time.
+func PredictConflicts(repo, task string) (*ConflictPrediction, error) {
+	files, err := listRepoFiles(repo)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list %s's files: %w", repo, err)
+	}
+	command := scopeCommand()
+	predicted, err := predictScope(command, scopePrompt(repo, task, files))
+	if err != nil {
+		return nil, err
+	}
+	p := &ConflictPrediction{Files: knownTargets(predicted, files)}
+
+	after := map[string]bool{}
+	seen := map[string]bool{}
+	add := func(r ConflictRisk) {
+		if key := r.File + "\x00" + r.Agent; !seen[key] {
+			seen[key] = true
+			p.Risks = append(p.Risks, r)
+			after[r.Agent] = true
+		}
+	}
+
+	claims, _ := coordination.ListClaims(repo)
+	claimed := make([]string, 0, len(claims))
+	for f := range claims {
+		claimed = append(claimed, f)
+	}
+	sort.Strings(claimed)
+	for _, f := range p.Files {
+		for _, c := range claimed {
+			if coordination.Overlaps(f, c) {
+				add(ConflictRisk{File: f, Agent: claims[c].Agent, Claimed: c})
+			}
+		}
+	}
+	for _, a := range loadAgents() {
+		if !a.OnRepo(repo) {
+			continue
+		}
+		if a.Scope == nil {
+			a.Scope = agentScope(a, command, files)
+		}
+		for _, f := range p.Files {
+			for _, s := range a.Scope {
+				if coordination.Overlaps(f, s) {
+					add(ConflictRisk{File: f, Agent: a.Name, Intent: a.Intent})
+					break
+				}
+			}
+		}
+	}
+	for name := range after {
+		p.After = append(p.After, name)
+	}
+	sort.Strings(p.After)
+	return p, nil
+}
+
+// agentScope predicts and records the files a live agent's task touches.
+// An agent with no task to go on, or whose prediction fails, has an empty
+// scope until the next try.
+func agentScope(a *Agent, command string, files []string) []string {
+	task := a.Task
+	if task == "" {
+		task = a.Intent
+	}
+	if task == "" {
+		return nil
+	}
+	predicted, err := predictScope(command, scopePrompt(a.Repo, task, files))
+	if err != nil {
+		return nil
+	}
+	scope := knownTargets(predicted, files)
+	updateAgent(a.Name, func(agent *Agent) error {
+		agent.Scope = scope
+		return nil
+	})
+	return scope
+}
+
+// knownTargets keeps the predicted targets that match a file of the repo,
+// dropping invented paths and the repo root.
+func knownTargets(predicted, files []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range predicted {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "./")
+		if t == "" || t == "." || t == "/" || seen[t] {
+			continue
+		}
+		for _, f := range files {
+			if coordination.Overlaps(t, f) {
+				out = append(out, t)
+				seen[t] = true
+				break
+			}
+		}
+	}
+	return out
+}
+
+func scopePrompt(repo, task string, files []string) string {
+	list := strings.Join(files, "\n")
+	if len(list) > scopeFileListBytes {
+		list = list[:scopeFileListBytes] + "\n[truncated]"
+	}
+	return "You are planning work on " + repo + " for an autonomous coding agent.\n" +
+		"Predict which of the repository's files the task below will change or add to. Prefer a directory " +
+		"(\"pkg/auth/\") over guessing at many files in it, and leave out files it will only read.\n" +
+		`Reply with JSON only: {"files": ["path", ...]}` + "\n" +
+		"\n## Task\n" + strings.TrimSpace(task) + "\n" +
+		"\n## Files (git ls-files)\n" + list + "\n"
+}
+
+// scopeCommand is the host command predictions run with: triage's.
+func scopeCommand() string {
+	if cfg, err := config.Load(); err == nil && cfg.Triage.Command != "" {
+		return cfg.Triage.Command
+	}
+	return DefaultSummaryCommand
+}
+
+// runScopePrediction runs command on the host with prompt on stdin.
+func runScopePrediction(command, prompt string) ([]string, error) {
+	cmd := process.Shell(command)
+	cmd.Stdin = strings.NewReader(prompt)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	return parseScope(string(out))
+}
+
+// parseScope reads the file list from the model's reply, which may come
+// wrapped in prose or a code fence.
+func parseScope(reply string) ([]string, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON in reply")
+	}
+	var scope struct {
+		Files []string `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &scope); err != nil {
+		return nil, fmt.Errorf("invalid prediction: %w", err)
+	}
+	return scope.Files, nil
+}
+
+// repoFiles lists the files on repo's default branch: from the git cache's
+// mirror when there is one, else from a shallow clone without file contents.
+func repoFiles(repo string) ([]string, error) {
+	gitDir := mirrorPath(repo)
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		tmp, err := os.MkdirTemp("", "agentctl-files-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+		host := forge.For(repo)
+		cmd := exec.Command("git", "clone", "--bare", "--quiet", "--depth", "1", "--filter=blob:none", repo, tmp)
+		cmd.Env = append(os.Environ(), forge.AuthEnv(host, repo, host.Token())...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		gitDir = tmp
+	}
+	out, err := exec.Command("git", "--git-dir", gitDir, "ls-tree", "-r", "--name-only", "HEAD").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestParseScope(t *testing.T) {
+	files, err := parseScope("Likely:\n```json\n{\"files\": [\"pkg/auth/\", \"README.md\"]}\n```")
+	if err != nil || !reflect.DeepEqual(files, []string{"pkg/auth/", "README.md"}) {
+		t.Errorf("parseScope = %v, %v", files, err)
+	}
+	if _, err := parseScope("no idea"); err == nil {
+		t.Error("parseScope should fail without JSON")
+	}
+}
+
+func TestPredictConflicts(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/acme/api"
+	if _, err := coordination.Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	coordination.ClaimFile(repo, "remote", "pkg/auth/token.go")
+	saveAgent(&Agent{Name: "billing", Repo: repo, Intent: "Add invoices", Scope: []string{"pkg/billing/"}})
+	saveAgent(&Agent{Name: "docs", Repo: repo, Task: "Document the API"})
+	saveAgent(&Agent{Name: "elsewhere", Repo: "https://github.com/acme/web", Scope: []string{"pkg/billing/"}})
+
+	origList, origPredict := listRepoFiles, predictScope
+	defer func() { listRepoFiles, predictScope = origList, origPredict }()
+	listRepoFiles = func(string) ([]string, error) {
+		return []string{"README.md", "pkg/auth/token.go", "pkg/billing/invoice.go", "pkg/api/routes.go"}, nil
+	}
+	predictScope = func(command, prompt string) ([]string, error) {
+		if !strings.Contains(prompt, "pkg/api/routes.go") {
+			t.Errorf("prompt lacks the file list:\n%s", prompt)
+		}
+		if strings.Contains(prompt, "Document the API") {
+			return []string{"README.md"}, nil
+		}
+		return []string{"pkg/auth/", "pkg/billing/invoice.go", "pkg/made/up.go", "."}, nil
+	}
+
+	p, err := PredictConflicts(repo, "Bill for token refreshes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"pkg/auth/", "pkg/billing/invoice.go"}; !reflect.DeepEqual(p.Files, want) {
+		t.Errorf("Files = %v, want %v", p.Files, want)
+	}
+	want := []ConflictRisk{
+		{File: "pkg/auth/", Agent: "remote", Claimed: "pkg/auth/token.go"},
+		{File: "pkg/billing/invoice.go", Agent: "billing", Intent: "Add invoices"},
+	}
+	if !reflect.DeepEqual(p.Risks, want) {
+		t.Errorf("Risks = %+v, want %+v", p.Risks, want)
+	}
+	if !reflect.DeepEqual(p.After, []string{"billing", "remote"}) {
+		t.Errorf("After = %v", p.After)
+	}
+	if docs, _ := loadAgent("docs"); !reflect.DeepEqual(docs.Scope, []string{"README.md"}) {
+		t.Errorf("docs scope = %v, want it predicted and recorded", docs.Scope)
+	}
+}
@@ -0,0 +1,59 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// containerAgentctlPath is where the host agentctl binary is mounted inside agents.
+const containerAgentctlPath = "/usr/local/bin/agentctl"
+
+// containerCoordRoot mirrors ~/.agentctl/coordination for the container's agent
+// user, so agentctl running inside resolves CoordDir(repo) to the same
+// bind-mounted directory the host uses.
+func containerCoordRoot(l Layout) string {
+	return l.Path(".agentctl/coordination")
+}
+
+// coordinationMounts returns podman run arguments that expose the coordination
+// bus to the agent: the repo's coordination directory, the host agentctl
+// binary (read-only, Linux hosts only — elsewhere the binary can't run in the
+// container), and AGENTCTL_AGENT/AGENTCTL_REPO so the agent knows who it is
+// (plus AGENTCTL_REDIS_URL with the redis backend, and AGENTCTL_BUS_URL and
+// AGENTCTL_BUS_TOKEN with the http one).
+// Failures degrade to no mounts; coordination is advisory.
+func coordinationMounts(name, repo string, layout Layout) []string {
+	if repo == "" {
+		return nil
+	}
+	dir, err := coordination.Init(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: coordination dir unavailable, agent won't see the bus: %v\n", err)
+		return nil
+	}
+
+	args := []string{
+		"-v", fmt.Sprintf("%s:%s/%s:z", dir, containerCoordRoot(layout), filepath.Base(dir)),
+		"-e", "AGENTCTL_AGENT=" + name,
+		"-e", "AGENTCTL_REPO=" + repo,
+	}
+	if cfg, err := config.Load(); err == nil {
+		switch c := cfg.Coordination; c.Backend {
+		case "redis":
+			args = append(args, "-e", coordination.RedisURLEnv+"="+c.URL)
+		case "http":
+			args = append(args, "-e", coordination.BusURLEnv+"="+c.URL, "-e", coordination.BusTokenEnv+"="+c.Token)
+		}
+	}
+	if runtime.GOOS == "linux" {
+		if self, err := os.Executable(); err == nil {
+			args = append(args, "-v", fmt.Sprintf("%s:%s:ro", self, containerAgentctlPath))
+		}
+	}
+	return args
+}
@@ -0,0 +1,38 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestCoordinationMounts(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/test/repo"
+	args := coordinationMounts("agent-1", repo, Layout{})
+
+	hostDir, _ := coordination.CoordDir(repo)
+	wantMount := hostDir + ":" + containerCoordRoot(Layout{}) + "/" + filepath.Base(hostDir) + ":z"
+	joined := strings.Join(args, " ")
+	for _, want := range []string{wantMount, "AGENTCTL_AGENT=agent-1", "AGENTCTL_REPO=" + repo} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("mount args missing %q: %v", want, args)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(hostDir, "claims.json")); err != nil {
+		t.Errorf("coordination dir should be initialized before mounting: %v", err)
+	}
+}
+
+func TestCoordinationMountsNoRepo(t *testing.T) {
+	if args := coordinationMounts("agent-1", "", Layout{}); args != nil {
+		t.Errorf("expected no mounts without a repo, got %v", args)
+	}
+}
@@ -0,0 +1,179 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// CoverageResult is what the coverage gate made of an agent's workspace.
+type CoverageResult struct {
+	Percent float64 // total coverage
+	Base    float64 // the base branch's coverage; -1 when not compared
+	// Low blocks completion; Reason says why.
+	Low    bool
+	Reason string
+}
+
+// BaseCoverage caches the coverage of the commit an agent's branch starts
+// from, measured once.
+type BaseCoverage struct {
+	Commit  string  `json:"commit"`
+	Percent float64 `json:"percent"`
+}
+
+// coverageRunner measures total coverage for one kind of project.
+type coverageRunner struct {
+	check string // test expression marking the project
+	// command runs the suite with coverage in the current directory and
+	// prints a report containing the total.
+	command string
+	total   *regexp.Regexp // its first group is the percentage
+}
+
+// coverageProfile is where Go writes its profile inside the container.
+const coverageProfile = "/tmp/agentctl-cover.out"
+
+// coverageWorktree is where the base branch is checked out to be measured.
+const coverageWorktree = "/tmp/agentctl-coverage-base"
+
+var coverageRunners = []coverageRunner{
+	{
+		check:   "test -f vendor/bin/pest",
+		command: "XDEBUG_MODE=coverage vendor/bin/pest --coverage 2>&1",
+		total:   regexp.MustCompile(`(?m)^\s*Total:?\s+([\d.]+)\s*%`),
+	},
+	{
+		check:   "test -f go.mod",
+		command: "go test -coverprofile=" + coverageProfile + " ./... >/dev/null 2>&1; go tool cover -func=" + coverageProfile + " 2>&1",
+		total:   regexp.MustCompile(`(?m)^total:\s+\(statements\)\s+([\d.]+)%`),
+	},
+	{
+		check:   "test -f pytest.ini -o -f pyproject.toml",
+		command: "pytest --cov=. --cov-report=term 2>&1",
+		total:   regexp.MustCompile(`(?m)^TOTAL\s.*?([\d.]+)%\s*$`),
+	},
+}
+
+// parse finds the total in a runner's report.
+func (r coverageRunner) parse(output string) (float64, error) {
+	m := r.total.FindStringSubmatch(output)
+	if m == nil {
+		return 0, fmt.Errorf("no coverage total in the output of %s", strings.Fields(r.command)[0])
+	}
+	return strconv.ParseFloat(m[1], 64)
+}
+
+// measureCoverage runs the first matching runner in dir inside the container.
+func measureCoverage(name, dir string) (float64, error) {
+	cd := "cd " + shellQuote(dir) + " && "
+	for _, r := range coverageRunners {
+		if _, err := podmanRetry("exec", containerName(name), "sh", "-c", cd+r.check); err != nil {
+			continue
+		}
+		out, _ := podmanLong("exec", containerName(name), "sh", "-c", cd+r.command).Output()
+		return r.parse(string(out))
+	}
+	return 0, fmt.Errorf("no coverage tool for this project (Go, Pest or pytest-cov)")
+}
+
+// baseCoverage measures the branch's base in a throwaway worktree, sharing
+// the workspace's installed dependencies, and caches it on the agent.
+func baseCoverage(name string) (float64, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return 0, err
+	}
+	git := agentGit(name)
+	base, err := branchBase(git)
+	if err != nil {
+		return 0, err
+	}
+	if c := agent.CoverageBase; c != nil && c.Commit == base {
+		return c.Percent, nil
+	}
+	ws := layoutOf(name).Workspace
+	git(nil, "worktree", "remove", "--force", coverageWorktree)
+	if _, err := git(nil, "worktree", "add", "--detach", "--force", coverageWorktree, base); err != nil {
+		return 0, err
+	}
+	defer git(nil, "worktree", "remove", "--force", coverageWorktree)
+	for _, deps := range []string{"vendor", "node_modules"} {
+		podman("exec", containerName(name), "sh", "-c", fmt.Sprintf("test -d %[1]s/%[2]s && ! test -e %[3]s/%[2]s && ln -s %[1]s/%[2]s %[3]s/%[2]s",
+			shellQuote(ws), deps, coverageWorktree)).Run()
+	}
+	percent, err := measureCoverage(name, coverageWorktree)
+	if err != nil {
+		return 0, err
+	}
+	updateAgent(name, func(agent *Agent) error {
+		agent.CoverageBase = &BaseCoverage{Commit: base, Percent: percent}
+		return nil
+	})
+	return percent, nil
+}
+
+// coverageGate reports whether the coverage gate is configured.
+func coverageGate() (config.Coverage, bool) {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.Coverage{}, false
+	}
+	c := cfg.Tests.Coverage
+	return c, c.Min > 0 || c.CompareBase
+}
+
+// checkCoverage measures the workspace and applies the gate, or returns nil
+// when no gate is configured or coverage can't be measured — an unmeasurable
+// project is not held back.
+func checkCoverage(name string) *CoverageResult {
+	gate, on := coverageGate()
+	if !on {
+		return nil
+	}
+	percent, err := measureCoverage(name, layoutOf(name).Workspace)
+	if err != nil {
+		fmt.Printf("⚠️  Coverage not checked: %v\n", err)
+		return nil
+	}
+	base := -1.0
+	if gate.CompareBase {
+		if b, err := baseCoverage(name); err == nil {
+			base = b
+		} else {
+			fmt.Printf("⚠️  Base coverage unknown: %v\n", err)
+		}
+	}
+	return judgeCoverage(percent, base, gate)
+}
+
+// judgeCoverage applies the gate to a measurement.
+func judgeCoverage(percent, base float64, gate config.Coverage) *CoverageResult {
+	r := &CoverageResult{Percent: percent, Base: base}
+	switch {
+	case gate.Min > 0 && percent < gate.Min:
+		r.Low, r.Reason = true, fmt.Sprintf("coverage %.1f%% is below the required %.1f%%", percent, gate.Min)
+	case base >= 0 && percent < base-gate.Tolerance:
+		r.Low, r.Reason = true, fmt.Sprintf("coverage fell from %.1f%% on the base branch to %.1f%%", base, percent)
+	}
+	return r
+}
+
+// String is the coverage as the run loop reports it.
+func (r *CoverageResult) String() string {
+	if r.Base >= 0 {
+		return fmt.Sprintf("%.1f%% (base %.1f%%)", r.Percent, r.Base)
+	}
+	return fmt.Sprintf("%.1f%%", r.Percent)
+}
+
+// coverageNote asks the agent for tests when coverage held it back.
+func coverageNote(r *CoverageResult) string {
+	if r == nil || !r.Low {
+		return ""
+	}
+	return "- Coverage: " + r.Reason + ". Add tests for the code you changed; do not delete, skip or weaken existing tests.\n"
+}
@@ -0,0 +1,90 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestCoverageParse(t *testing.T) {
+	reports := []string{
+		"  Http/Controllers/UserController ..... 80.0 %\n  ─────\n  Total: 76.4 %\n",
+		"github.com/acme/api/auth.go:12:\tLogin\t\t100.0%\ntotal:\t\t\t\t(statements)\t76.4%\n",
+		"Name    Stmts   Miss  Cover\n-------\napp.py     10      2    80%\nTOTAL     123     29    76.4%\n",
+	}
+	for i, r := range coverageRunners {
+		got, err := r.parse(reports[i])
+		if err != nil || got != 76.4 {
+			t.Errorf("%s: parse = %v, %v", r.check, got, err)
+		}
+		if _, err := r.parse("FAIL\n"); err == nil {
+			t.Errorf("%s: a report without a total should fail", r.check)
+		}
+	}
+}
+
+func TestJudgeCoverage(t *testing.T) {
+	for _, tc := range []struct {
+		percent, base float64
+		gate          config.Coverage
+		low           string
+	}{
+		{70, -1, config.Coverage{Min: 80}, "below the required 80.0%"},
+		{85, -1, config.Coverage{Min: 80}, ""},
+		{70, 72, config.Coverage{CompareBase: true}, "fell from 72.0%"},
+		{71.5, 72, config.Coverage{CompareBase: true, Tolerance: 0.5}, ""},
+		{72, 72, config.Coverage{CompareBase: true}, ""},
+	} {
+		r := judgeCoverage(tc.percent, tc.base, tc.gate)
+		if r.Low != (tc.low != "") || !strings.Contains(r.Reason, tc.low) {
+			t.Errorf("judgeCoverage(%v, %v, %+v) = %+v", tc.percent, tc.base, tc.gate, r)
+		}
+	}
+}
+
+func TestCoverageBlocksCompletion(t *testing.T) {
+	low := &CoverageResult{Percent: 70, Base: 72, Low: true, Reason: "coverage fell from 72.0% on the base branch to 70.0%"}
+	status := AgentStatus{TestStatus: "pass", Coverage: low}
+	if status.Done() {
+		t.Error("low coverage should block completion")
+	}
+	prompt := retryPrompt("fix login", status, false)
+	if !strings.Contains(prompt, "- Coverage: coverage fell from 72.0%") || !strings.Contains(prompt, "Add tests") {
+		t.Errorf("prompt doesn't ask for tests:\n%s", prompt)
+	}
+
+	status.Coverage = &CoverageResult{Percent: 75, Base: 72}
+	if !status.Done() || strings.Contains(retryPrompt("fix login", status, false), "Coverage") {
+		t.Error("coverage at or above the base should not hold the agent back")
+	}
+	if status.Coverage.String() != "75.0% (base 72.0%)" {
+		t.Errorf("String() = %q", status.Coverage)
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fakePodman(t, `case "$*" in
+*"test -f go.mod"*) exit 0 ;;
+*"test -f"*) exit 1 ;;
+*"go tool cover"*) printf 'total:\t(statements)\t64.2%%\n' ;;
+esac`)
+
+	if r := checkCoverage("api"); r != nil {
+		t.Errorf("no gate configured, got %+v", r)
+	}
+
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"tests": {"coverage": {"min": 80}}}`), 0644)
+	r := checkCoverage("api")
+	if r == nil || r.Percent != 64.2 || !r.Low || r.Base != -1 {
+		t.Errorf("checkCoverage = %+v", r)
+	}
+}
@@ -0,0 +1,133 @@
+package container
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefinitionVersion is the agent.yaml format version written by Export.
+const DefinitionVersion = 1
+
+// Definition is a portable description of an agent: everything needed to
+// spawn the same container and give it the same task on another machine.
+// Host-specific state (container ID, port, timestamps) and credentials are
+// deliberately left out.
+type Definition struct {
+	Version int    `yaml:"version"`
+	Name    string `yaml:"name"`
+	Repo    string `yaml:"repo,omitempty"`
+	Branch  string `yaml:"branch,omitempty"`
+	Image   string `yaml:"image,omitempty"`
+	Profile string `yaml:"profile,omitempty"`
+	// Yolo skips Claude's permission checks whatever the profile says.
+	Yolo   bool   `yaml:"yolo,omitempty"`
+	Intent string `yaml:"intent,omitempty"`
+	Task   string `yaml:"task,omitempty"`
+	// After names the agents this one waits on to merge.
+	After []string `yaml:"after,omitempty"`
+	// Needs names the agents whose runs must complete before this one's.
+	Needs []string `yaml:"needs,omitempty"`
+	// Egress is the agent's network allow-list; empty means unrestricted.
+	Egress []string `yaml:"egress,omitempty"`
+
+	Setup        DefinitionSetup        `yaml:"setup,omitempty"`
+	Coordination DefinitionCoordination `yaml:"coordination,omitempty"`
+	Quota        DefinitionQuota        `yaml:"quota,omitempty"`
+}
+
+// DefinitionQuota mirrors the spawn-time storage limits.
+type DefinitionQuota struct {
+	Tmpfs string `yaml:"tmpfs,omitempty"`
+	Disk  string `yaml:"disk,omitempty"`
+}
+
+// DefinitionSetup mirrors the spawn-time setup flags.
+type DefinitionSetup struct {
+	Skip     bool     `yaml:"skip,omitempty"`
+	Commands []string `yaml:"commands,omitempty"`
+}
+
+// DefinitionCoordination mirrors the spawn-time coordination settings.
+type DefinitionCoordination struct {
+	DisableMount bool `yaml:"disable_mount,omitempty"`
+}
+
+// Export builds a Definition from a saved agent's metadata.
+func Export(name string) (*Definition, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Definition{
+		Version: DefinitionVersion,
+		Name:    agent.Name,
+		Repo:    agent.Repo,
+		Branch:  agent.Branch,
+		Image:   agent.Image,
+		Profile: agent.Profile,
+		Yolo:    agent.Yolo,
+		Intent:  agent.Intent,
+		Task:    agent.Task,
+		After:   agent.After,
+		Needs:   agent.Needs,
+		Egress:  agent.Egress,
+		Setup: DefinitionSetup{
+			Skip:     agent.SkipSetup,
+			Commands: agent.SetupCommands,
+		},
+		Coordination: DefinitionCoordination{DisableMount: agent.NoCoordMount},
+		Quota:        DefinitionQuota{Tmpfs: agent.TmpfsSize, Disk: agent.DiskQuota},
+	}, nil
+}
+
+// MarshalDefinition encodes a Definition as YAML.
+func MarshalDefinition(def *Definition) ([]byte, error) {
+	return yaml.Marshal(def)
+}
+
+// ParseDefinition decodes and validates an agent.yaml document.
+func ParseDefinition(data []byte) (*Definition, error) {
+	var def Definition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("invalid agent definition: %w", err)
+	}
+	if def.Version > DefinitionVersion {
+		return nil, fmt.Errorf("agent definition version %d is newer than this agentctl supports (%d)", def.Version, DefinitionVersion)
+	}
+	if def.Name == "" {
+		return nil, fmt.Errorf("agent definition has no name")
+	}
+	return &def, nil
+}
+
+// LoadDefinition reads an agent.yaml file.
+func LoadDefinition(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return ParseDefinition(data)
+}
+
+// SpawnOptions converts the definition into options for SpawnWithOptions.
+func (d *Definition) SpawnOptions() SpawnOptions {
+	return SpawnOptions{
+		Name:          d.Name,
+		Repo:          d.Repo,
+		Branch:        d.Branch,
+		Image:         d.Image,
+		Profile:       d.Profile,
+		Yolo:          d.Yolo,
+		Intent:        d.Intent,
+		SkipSetup:     d.Setup.Skip,
+		SetupCommands: d.Setup.Commands,
+		NoCoordMount:  d.Coordination.DisableMount,
+		TmpfsSize:     d.Quota.Tmpfs,
+		DiskQuota:     d.Quota.Disk,
+		After:         d.After,
+		Needs:         d.Needs,
+		AllowHosts:    d.Egress,
+	}
+}
@@ -0,0 +1,106 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	saveAgent(&Agent{
+		Name:          "shared",
+		ContainerID:   "abc123",
+		Port:          8123,
+		Repo:          "https://github.com/org/repo",
+		Branch:        "feature",
+		Image:         "custom:latest",
+		Intent:        "fix auth",
+		Task:          "Make the login tests pass",
+		SetupCommands: []string{"make deps"},
+		NoCoordMount:  true,
+	})
+
+	def, err := Export("shared")
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	data, err := MarshalDefinition(def)
+	if err != nil {
+		t.Fatalf("MarshalDefinition() error: %v", err)
+	}
+	if strings.Contains(string(data), "abc123") || strings.Contains(string(data), "8123") {
+		t.Errorf("export leaked host-specific state:\n%s", data)
+	}
+
+	parsed, err := ParseDefinition(data)
+	if err != nil {
+		t.Fatalf("ParseDefinition() error: %v", err)
+	}
+	want := SpawnOptions{
+		Name:          "shared",
+		Repo:          "https://github.com/org/repo",
+		Branch:        "feature",
+		Image:         "custom:latest",
+		Intent:        "fix auth",
+		SetupCommands: []string{"make deps"},
+		NoCoordMount:  true,
+	}
+	if got := parsed.SpawnOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SpawnOptions() = %+v, want %+v", got, want)
+	}
+	if parsed.Task != "Make the login tests pass" {
+		t.Errorf("Task = %q", parsed.Task)
+	}
+}
+
+func TestExportNotFound(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	if _, err := Export("missing"); err == nil {
+		t.Error("Export() of unknown agent should fail")
+	}
+}
+
+func TestParseDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"minimal", "name: a\nrepo: https://github.com/org/repo\n", ""},
+		{"missing name", "repo: https://github.com/org/repo\n", "no name"},
+		{"future version", "version: 99\nname: a\n", "newer"},
+		{"malformed", "name: [\n", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDefinition([]byte(tt.yaml))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefinitionDefaultBranch(t *testing.T) {
+	// No branch means the repo's default, resolved at spawn.
+	def := &Definition{Name: "a"}
+	if got := def.SpawnOptions().Branch; got != "" {
+		t.Errorf("Branch = %q, want empty", got)
+	}
+}
@@ -0,0 +1,201 @@
+package container
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Ecosystem is a package manager the deps workflow upgrades, found by its
+// manifest at the root of a repo.
+type Ecosystem struct {
+	Name     string // go, npm or composer
+	Manifest string
+	Label    string // for commit messages and PR titles
+	Steps    string // how to upgrade, for the prompt
+}
+
+// Ecosystems are those the deps workflow knows, in the order it runs them.
+var Ecosystems = []Ecosystem{
+	{
+		Name: "go", Manifest: "go.mod", Label: "Go modules",
+		Steps: "Run `go list -m -u all` to see what is out of date. Upgrade with `go get -u ./...` " +
+			"(or `go get <module>@<version>` one at a time where a batch breaks), then `go mod tidy`. " +
+			"Stay within major versions: a new major means a new import path, so leave those alone.",
+	},
+	{
+		Name: "npm", Manifest: "package.json", Label: "npm packages",
+		Steps: "Use the package manager the lockfile belongs to (package-lock.json: npm, yarn.lock: yarn, " +
+			"pnpm-lock.yaml: pnpm). List outdated packages (`npm outdated`), raise the ranges in package.json " +
+			"to the latest minor and patch versions and update the lockfile. Leave major versions alone " +
+			"unless the upgrade needs no code changes beyond trivial ones.",
+	},
+	{
+		Name: "composer", Manifest: "composer.json", Label: "Composer packages",
+		Steps: "Run `composer outdated --direct` to see what is out of date. Raise the constraints in " +
+			"composer.json to the latest minor and patch versions and run `composer update -W`. Leave " +
+			"major versions alone unless the upgrade needs no code changes beyond trivial ones.",
+	},
+}
+
+// depsPrompt is a deps agent's task. Its arguments are the ecosystem's
+// label, the repo, the manifest, the upgrade steps and the label again.
+const depsPrompt = `Upgrade the %s of %s (%s) to their latest compatible versions.
+
+%s
+
+Then build the project and run its full test suite. Where an upgrade breaks the build or the
+tests, fix the code if the fix is small and clearly right; otherwise hold that one package back
+to the newest version that works and say why in the commit message body.
+
+Touch only this ecosystem's manifest and lockfile and the code the upgrades need. If everything
+is already up to date, change nothing. Otherwise commit with the message
+"chore(deps): upgrade %s", listing each package's old and new version in the body.`
+
+// depsPRBody is the description of a deps PR.
+const depsPRBody = `Dependency upgrades for %s, made and tested by agentctl's deps workflow.
+
+Before merging, read the versions listed in the commit message and the changelogs of anything
+with breaking changes in its history.`
+
+// DepsOptions controls the deps workflow. Zero values take the defaults.
+type DepsOptions struct {
+	// Ecosystems limits the workflow to these (default: all it knows).
+	Ecosystems  []string
+	Image       string
+	MaxAttempts int
+	Concurrency int
+	Draft       bool
+}
+
+// DepsJob is one ecosystem of one repo to upgrade, by one agent.
+type DepsJob struct {
+	Repo      string `json:"repo"`
+	Ecosystem string `json:"ecosystem"`
+	Agent     string `json:"agent"`
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// DepsRepos is the repos given, or the config's workflows.deps.repos.
+func DepsRepos(repos []string) []string {
+	if len(repos) > 0 {
+		return repos
+	}
+	if cfg, err := config.Load(); err == nil {
+		return cfg.Workflows.Deps.Repos
+	}
+	return nil
+}
+
+// PlanDeps finds the ecosystems of each repo, from the manifests at its root,
+// and names an agent for each: deps-<repo>-<ecosystem>.
+func PlanDeps(repos []string, opts DepsOptions) ([]DepsJob, error) {
+	if len(opts.Ecosystems) == 0 {
+		if cfg, err := config.Load(); err == nil {
+			opts.Ecosystems = cfg.Workflows.Deps.Ecosystems
+		}
+	}
+	for _, name := range opts.Ecosystems {
+		if ecosystem(name) == nil {
+			return nil, fmt.Errorf("unknown ecosystem %q (want go, npm or composer)", name)
+		}
+	}
+	var jobs []DepsJob
+	for _, repo := range repos {
+		files, err := listRepoFiles(repo)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list %s's files: %w", repo, err)
+		}
+		root := map[string]bool{}
+		for _, f := range files {
+			root[f] = true
+		}
+		slug := unsafeNameChars.ReplaceAllString(strings.ToLower(path.Base(strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git"))), "-")
+		for _, e := range Ecosystems {
+			if root[e.Manifest] && (len(opts.Ecosystems) == 0 || slices.Contains(opts.Ecosystems, e.Name)) {
+				jobs = append(jobs, DepsJob{Repo: repo, Ecosystem: e.Name, Agent: "deps-" + strings.Trim(slug, "-") + "-" + e.Name})
+			}
+		}
+	}
+	return jobs, nil
+}
+
+func ecosystem(name string) *Ecosystem {
+	for i := range Ecosystems {
+		if Ecosystems[i].Name == name {
+			return &Ecosystems[i]
+		}
+	}
+	return nil
+}
+
+// DepsTask is the task a deps agent runs.
+func DepsTask(repo string, e Ecosystem) string {
+	return fmt.Sprintf(depsPrompt, e.Label, repo, e.Manifest, e.Steps, strings.ToLower(e.Label))
+}
+
+// RunDeps runs the jobs, at most opts.Concurrency at a time and each
+// spawn waiting for capacity, calling emit with each result. A job spawns
+// its agent on a fresh branch, runs the upgrade until the tests pass and
+// opens a PR when there were upgrades to make; an agent with nothing to
+// upgrade is removed.
+func RunDeps(jobs []DepsJob, opts DepsOptions, emit func(BulkResult)) {
+	byName := map[string]DepsJob{}
+	specs := make([]BulkSpec, len(jobs))
+	for i, j := range jobs {
+		byName[j.Agent] = j
+		specs[i] = BulkSpec{Name: j.Agent, Repo: j.Repo, Image: opts.Image, MaxAttempts: opts.MaxAttempts, Template: "deps"}
+	}
+	Bulk(specs, opts.Concurrency, func(s BulkSpec) BulkResult {
+		return runDepsJob(byName[s.Name], s, opts)
+	}, emit)
+}
+
+func runDepsJob(j DepsJob, s BulkSpec, opts DepsOptions) BulkResult {
+	e := ecosystem(j.Ecosystem)
+	s.Intent = "Upgrade " + e.Label
+	if res := bulkSpawn(s); !res.OK {
+		return res
+	}
+	if err := startBranch(s.Name, "deps/"+e.Name+"-"+time.Now().Format("20060102")); err != nil {
+		return BulkResult{Error: err.Error()}
+	}
+	git := agentGit(s.Name)
+	start, err := git(nil, "rev-parse", "HEAD")
+	if err != nil {
+		return BulkResult{Error: err.Error()}
+	}
+
+	s.Task = DepsTask(j.Repo, *e)
+	res := bulkRun(s)
+	if !res.OK {
+		return res
+	}
+	commits, _ := git(nil, "rev-list", "--count", strings.TrimSpace(start)+"..HEAD")
+	if strings.TrimSpace(commits) == "0" {
+		Kill(s.Name)
+		res.Note = "already up to date"
+		return res
+	}
+	if err := Push(s.Name, true); err != nil {
+		res.OK, res.Error = false, err.Error()
+		return res
+	}
+	pr, err := OpenPR(s.Name, PROptions{
+		Title: "chore(deps): upgrade " + strings.ToLower(e.Label),
+		Body:  fmt.Sprintf(depsPRBody, e.Label),
+		Draft: opts.Draft,
+	})
+	if err != nil {
+		res.OK, res.Error = false, err.Error()
+		return res
+	}
+	res.PR = pr.URL
+	return res
+}
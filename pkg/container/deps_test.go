@@ -0,0 +1,55 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanDeps(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	origList := listRepoFiles
+	defer func() { listRepoFiles = origList }()
+	listRepoFiles = func(repo string) ([]string, error) {
+		if strings.HasSuffix(repo, "api") {
+			return []string{"go.mod", "go.sum", "web/package.json", "README.md"}, nil
+		}
+		return []string{"composer.json", "package.json"}, nil
+	}
+
+	repos := []string{"https://github.com/acme/api", "git@github.com:acme/My.Site.git"}
+	jobs, err := PlanDeps(repos, DepsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DepsJob{
+		{Repo: repos[0], Ecosystem: "go", Agent: "deps-api-go"},
+		{Repo: repos[1], Ecosystem: "npm", Agent: "deps-my-site-npm"},
+		{Repo: repos[1], Ecosystem: "composer", Agent: "deps-my-site-composer"},
+	}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Errorf("PlanDeps = %+v", jobs)
+	}
+
+	jobs, _ = PlanDeps(repos, DepsOptions{Ecosystems: []string{"composer"}})
+	if len(jobs) != 1 || jobs[0].Agent != "deps-my-site-composer" {
+		t.Errorf("PlanDeps(composer) = %+v", jobs)
+	}
+	if _, err := PlanDeps(repos, DepsOptions{Ecosystems: []string{"cargo"}}); err == nil {
+		t.Error("PlanDeps should reject an unknown ecosystem")
+	}
+}
+
+func TestDepsTask(t *testing.T) {
+	task := DepsTask("https://github.com/acme/api", *ecosystem("go"))
+	for _, want := range []string{"Go modules of https://github.com/acme/api (go.mod)", "go mod tidy", `"chore(deps): upgrade go modules"`} {
+		if !strings.Contains(task, want) {
+			t.Errorf("task lacks %q:\n%s", want, task)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package container
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/forge"
 )
 
 const DispatchPreamble = `Do ALL work yourself, directly in this session. Do NOT delegate to subagents or task agents — they do not receive this spec.
@@ -95,17 +98,44 @@ func ownerRepoOf(repo string) string {
 // container env, which podman exec inherits (a .bashrc echo would be both
 // redundant and a shell-injection vector).
 func run(step string, args ...string) error {
+	if args[0] == "podman" {
+		if out, err := podmanLong(args[1:]...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v: %s", step, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
 	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
 		return fmt.Errorf("%s: %v: %s", step, err, strings.TrimSpace(string(out)))
 	}
 	return nil
 }
 
+// dispatchIntent is what a dispatched agent is recorded as working on: the
+// intent text, the issue and its title, or the intent file's first line.
+func dispatchIntent(issue, intent, issueJSON, fileContent string) string {
+	switch {
+	case issue != "":
+		var is struct {
+			Title string `json:"title"`
+		}
+		json.Unmarshal([]byte(issueJSON), &is)
+		return strings.TrimSpace("issue #" + issue + " " + is.Title)
+	case intent != "":
+		return intent
+	}
+	return fileContent
+}
+
 func Dispatch(name, repo string, issue, intent, intentFile, model, branch, image string) error {
 	if code, msg := ValidateDispatchArgs(issue, intent, intentFile); code != 0 {
 		return fmt.Errorf("%s", msg)
 	}
 	model = DefaultModel(model)
+	// Issues are read with gh; elsewhere, pass the task with --intent.
+	onGitHub := forge.For(repo).Type() == forge.GitHub
+	if issue != "" && !onGitHub {
+		return fmt.Errorf("--issue needs a GitHub repo; pass the task with --intent or --intent-file")
+	}
 	if image == "" {
 		image = DefaultImage
 	}
@@ -127,22 +157,27 @@ func Dispatch(name, repo string, issue, intent, intentFile, model, branch, image
 		return e
 	}
 
+	layout := layoutOf(name)
+	ws := layout.Workspace
 	ownerRepo := ownerRepoOf(repo)
-	if err := run("gh clone", "podman", "exec", name, "gh", "repo", "clone", ownerRepo, "/home/agent/workspace/repo"); err != nil {
-		return fail(err)
-	}
-	if err := run("gh auth setup-git", "podman", "exec", name, "gh", "auth", "setup-git"); err != nil {
-		return fail(err)
+	// Other forges are cloned by Spawn with the token in the remote URL.
+	if onGitHub {
+		if err := run("gh clone", "podman", "exec", containerName(name), "gh", "repo", "clone", ownerRepo, ws); err != nil {
+			return fail(err)
+		}
+		if err := run("gh auth setup-git", "podman", "exec", containerName(name), "gh", "auth", "setup-git"); err != nil {
+			return fail(err)
+		}
 	}
 	if branch != "" {
-		if err := run("checkout", "podman", "exec", name, "git", "-C", "/home/agent/workspace/repo", "checkout", branch); err != nil {
+		if err := run("checkout", "podman", "exec", containerName(name), "git", "-C", ws, "checkout", branch); err != nil {
 			return fail(err)
 		}
 	}
-	if err := run("git user.name", "podman", "exec", name, "git", "-C", "/home/agent/workspace/repo", "config", "user.name", gitName); err != nil {
+	if err := run("git user.name", "podman", "exec", containerName(name), "git", "-C", ws, "config", "user.name", gitName); err != nil {
 		return fail(err)
 	}
-	if err := run("git user.email", "podman", "exec", name, "git", "-C", "/home/agent/workspace/repo", "config", "user.email", gitEmail); err != nil {
+	if err := run("git user.email", "podman", "exec", containerName(name), "git", "-C", ws, "config", "user.email", gitEmail); err != nil {
 		return fail(err)
 	}
 
@@ -161,6 +196,7 @@ func Dispatch(name, repo string, issue, intent, intentFile, model, branch, image
 		fileContent = string(data)
 	}
 	fullIntent := ComposeIntent(issue, intent, intentFile, ownerRepo, issueJSON, fileContent)
+	RecordIntent(name, dispatchIntent(issue, intent, issueJSON, fileContent))
 
 	// Private temp file (0600 via CreateTemp) instead of a predictable
 	// world-readable /tmp path — intent can contain sensitive task detail.
@@ -174,17 +210,18 @@ func Dispatch(name, repo string, issue, intent, intentFile, model, branch, image
 		return fail(fmt.Errorf("write intent temp: %v", err))
 	}
 	tmp.Close()
-	if err := run("cp intent", "podman", "cp", tmp.Name(), name+":/home/agent/intent.txt"); err != nil {
+	if err := run("cp intent", "podman", "cp", tmp.Name(), containerName(name)+":"+layout.Path("intent.txt")); err != nil {
 		return fail(err)
 	}
 
-	if err := run("launch", "podman", "exec", "-d", "-w", "/home/agent/workspace/repo",
-		"-e", "AGENT_LLM_MODEL="+model, name,
-		"sh", "-c", "run-task \"$(cat /home/agent/intent.txt)\" > /home/agent/task.log 2>&1"); err != nil {
+	taskLog := layout.Path("task.log")
+	if err := run("launch", "podman", "exec", "-d", "-w", ws,
+		"-e", "AGENT_LLM_MODEL="+model, containerName(name),
+		"sh", "-c", fmt.Sprintf("run-task \"$(cat %s)\" > %s 2>&1", shellQuote(layout.Path("intent.txt")), shellQuote(taskLog))); err != nil {
 		return fail(err)
 	}
 
-	fmt.Printf("dispatched: %s\nmodel: %s   repo: %s   intent: %s\nfollow:  agentctl logs %s   (tails /home/agent/task.log)\nstatus:  agentctl status %s\n",
-		name, model, repo, IntentSource(issue, intent, intentFile), name, name)
+	fmt.Printf("dispatched: %s\nmodel: %s   repo: %s   intent: %s\nfollow:  agentctl logs %s   (tails %s)\nstatus:  agentctl status %s\n",
+		name, model, repo, IntentSource(issue, intent, intentFile), name, taskLog, name)
 	return nil
 }
@@ -0,0 +1,29 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+)
+
+// doneWhenNote tells the agent how a --done-when run is judged.
+func doneWhenNote(cmd string) string {
+	return fmt.Sprintf("\n\nThe task is done when `%s` exits 0 in the workspace; that command, not the test suite, decides.", cmd)
+}
+
+// checkDoneWhen runs a run's completion command in the workspace, logged
+// with the tests. It returns "pass" or "fail" and, on failure, the tail of
+// its output for the retry prompt.
+func checkDoneWhen(name, cmd string) (string, string) {
+	layout := layoutOf(name)
+	testLog := layout.Path("tests.log")
+	script := logAppend(testLog, "done-when") + "{ " + layout.cd() + "{ " + cmd + "\n} 2>&1; echo EXIT_CODE:$?; } 2>&1 | tee -a " + shellQuote(testLog)
+	out, _ := podmanLong("exec", containerName(name), "sh", "-c", script).Output()
+	output := string(out)
+	if strings.Contains(output, "EXIT_CODE:0") {
+		return "pass", ""
+	}
+	if n := testOutputLines(); n > 0 {
+		return "fail", testOutputTail(output, n)
+	}
+	return "fail", ""
+}
@@ -0,0 +1,36 @@
+package container
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStatusOfDoneWhen(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fakePodman(t, `case "$*" in
+*"make docs"*) echo "docs/api.md is stale"; echo EXIT_CODE:2 ;;
+*"make e2e"*) echo EXIT_CODE:0 ;;
+*"go test"*) echo "should not run"; echo EXIT_CODE:1 ;;
+*"test -f go.mod"*) exit 0 ;;
+esac`)
+
+	status := statusOf("d", "make docs")
+	if status.TestStatus != "fail" || status.DoneWhen != "make docs" || !strings.Contains(status.TestOutput, "docs/api.md is stale") {
+		t.Errorf("failing done-when: %+v", status)
+	}
+	if status := statusOf("d", "make e2e"); !status.Done() {
+		t.Errorf("passing done-when: %+v", status)
+	}
+
+	prompt := retryPrompt("write the docs"+doneWhenNote("make docs"), status, false)
+	for _, want := range []string{"- `make docs`: fail", "Last 1 lines of its output", "until `make docs` succeeds and all changes are committed"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("%q missing from prompt:\n%s", want, prompt)
+		}
+	}
+}
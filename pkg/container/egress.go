@@ -0,0 +1,166 @@
+package container
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// DefaultProxyImage is the squid image that enforces an egress allow-list.
+const DefaultProxyImage = "docker.io/ubuntu/squid:latest"
+
+// egressProxyPort is where the proxy listens on the agent's network.
+const egressProxyPort = 3128
+
+// defaultLLMRouter is where the bundled image's run-task and opencode.json
+// reach their model: the mesh LLM router on the host.
+const defaultLLMRouter = "http://host.containers.internal:8101"
+
+// defaultEgress is what an egress-restricted agent can always reach: the
+// code host and the package registries setup needs. A leading dot covers
+// the domain and its subdomains.
+var defaultEgress = []string{
+	".github.com",
+	".githubusercontent.com",
+	"registry.npmjs.org",
+	"registry.yarnpkg.com",
+	"pypi.org",
+	"files.pythonhosted.org",
+	"proxy.golang.org",
+	"sum.golang.org",
+	"index.crates.io",
+	"static.crates.io",
+	"repo.packagist.org",
+	"rubygems.org",
+}
+
+// egressRestricted reports whether an agent spawned with opts is held to an
+// allow-list: asked for on the command line, by its profile or for everyone.
+func egressRestricted(cfg *config.Config, opts SpawnOptions) bool {
+	return opts.RestrictEgress || len(opts.AllowHosts) > 0 || cfg.Egress.Restrict ||
+		cfg.Profiles[opts.Profile].RestrictEgress
+}
+
+// egressHosts builds an agent's allow-list from the defaults, the config,
+// its profile, the spawn options, and the hosts of its repo and LLM router:
+// the image's default router always, and AGENT_LLM_BASE_URL's when set.
+func egressHosts(cfg *config.Config, opts SpawnOptions) []string {
+	hosts := append([]string(nil), defaultEgress...)
+	hosts = append(hosts, cfg.Egress.Allow...)
+	hosts = append(hosts, cfg.Profiles[opts.Profile].AllowHosts...)
+	hosts = append(hosts, opts.AllowHosts...)
+	if opts.Repo != "" {
+		hosts = append(hosts, forge.Host(opts.Repo))
+	}
+	for _, router := range []string{defaultLLMRouter, os.Getenv("AGENT_LLM_BASE_URL")} {
+		if u, err := url.Parse(router); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return normalizeHosts(hosts)
+}
+
+// normalizeHosts lower-cases hosts, reads "*.example.com" as ".example.com"
+// and drops hosts a wildcard already covers, which squid would warn about.
+func normalizeHosts(hosts []string) []string {
+	set := map[string]bool{}
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		h = strings.TrimPrefix(h, "*")
+		if h != "" && h != "." {
+			set[h] = true
+		}
+	}
+	var out []string
+	for h := range set {
+		covered := false
+		for parent := "." + strings.TrimPrefix(h, "."); ; {
+			if parent != h && set[parent] {
+				covered = true
+				break
+			}
+			i := strings.Index(parent[1:], ".")
+			if i < 0 {
+				break
+			}
+			parent = parent[i+1:]
+		}
+		if !covered {
+			out = append(out, h)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// squidConfig allows CONNECT and plain HTTP to hosts and nothing else.
+func squidConfig(hosts []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "http_port %d\n", egressProxyPort)
+	for _, h := range hosts {
+		fmt.Fprintf(&b, "acl allowed dstdomain %s\n", h)
+	}
+	b.WriteString("http_access allow allowed\n")
+	b.WriteString("http_access deny all\n")
+	b.WriteString("cache deny all\n")
+	b.WriteString("access_log stdio:/dev/stdout\n")
+	return b.String()
+}
+
+func egressNetwork(name string) string { return containerName(name) + "-egress" }
+func egressProxy(name string) string   { return containerName(name) + "-proxy" }
+
+func egressConfigPath(name string) string {
+	return filepath.Join(namespace.Root(), "egress", name+".conf")
+}
+
+// startEgress gives an agent an internal podman network, with no route out,
+// whose only way out is a squid proxy admitting hosts. It returns the podman
+// run flags that put the agent on that network behind the proxy.
+func startEgress(name string, cfg config.Egress, hosts []string) ([]string, error) {
+	removeEgress(name) // leftovers of an earlier agent of this name
+	conf := egressConfigPath(name)
+	if err := os.MkdirAll(filepath.Dir(conf), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(conf, []byte(squidConfig(hosts)), 0644); err != nil {
+		return nil, err
+	}
+	network := egressNetwork(name)
+	if out, err := podman("network", "create", "--internal", network).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("egress network: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	image := cfg.ProxyImage
+	if image == "" {
+		image = DefaultProxyImage
+	}
+	if out, err := podmanLong("run", "-d", "--name", egressProxy(name),
+		"--label", "agentctl.egress-for="+name,
+		"--network", network, "--network", "podman",
+		"-v", conf+":/etc/squid/squid.conf:ro,z",
+		image).CombinedOutput(); err != nil {
+		removeEgress(name)
+		return nil, fmt.Errorf("egress proxy: %v %s", err, strings.TrimSpace(string(out)))
+	}
+
+	proxy := fmt.Sprintf("http://%s:%d", egressProxy(name), egressProxyPort)
+	args := []string{"--network", network}
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "https_proxy", "http_proxy"} {
+		args = append(args, "-e", key+"="+proxy)
+	}
+	return append(args, "-e", "NO_PROXY=localhost,127.0.0.1", "-e", "no_proxy=localhost,127.0.0.1"), nil
+}
+
+// removeEgress removes an agent's proxy and network, if it has them.
+func removeEgress(name string) {
+	podman("rm", "-f", egressProxy(name)).Run()
+	podman("network", "rm", "-f", egressNetwork(name)).Run()
+	os.Remove(egressConfigPath(name))
+}
@@ -0,0 +1,113 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestNormalizeHosts(t *testing.T) {
+	got := normalizeHosts([]string{"API.github.com", ".github.com", "*.corp.example.com", "git.corp.example.com", "pypi.org", "pypi.org", " ", "*"})
+	want := []string{".corp.example.com", ".github.com", "pypi.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeHosts = %v, want %v", got, want)
+	}
+}
+
+func TestEgressHosts(t *testing.T) {
+	t.Setenv("AGENT_LLM_BASE_URL", "https://llm.mesh.internal:8443/v1")
+	cfg := &config.Config{
+		Egress:   config.Egress{Allow: []string{"artifacts.acme.dev"}},
+		Profiles: map[string]config.Profile{"locked": {RestrictEgress: true, AllowHosts: []string{"pkg.internal"}}},
+	}
+	opts := SpawnOptions{Repo: "https://gitlab.acme.dev/team/api.git", Profile: "locked", AllowHosts: []string{"docs.rs"}}
+	hosts := strings.Join(egressHosts(cfg, opts), " ")
+	for _, h := range []string{".github.com", "host.containers.internal", "registry.npmjs.org", "artifacts.acme.dev", "pkg.internal", "docs.rs", "gitlab.acme.dev", "llm.mesh.internal"} {
+		if !strings.Contains(" "+hosts+" ", " "+h+" ") {
+			t.Errorf("%s missing from %s", h, hosts)
+		}
+	}
+
+	if egressRestricted(&config.Config{}, SpawnOptions{}) {
+		t.Error("agents are unrestricted by default")
+	}
+	for _, restricted := range []struct {
+		cfg  *config.Config
+		opts SpawnOptions
+	}{
+		{&config.Config{}, SpawnOptions{RestrictEgress: true}},
+		{&config.Config{}, SpawnOptions{AllowHosts: []string{"x.dev"}}},
+		{&config.Config{Egress: config.Egress{Restrict: true}}, SpawnOptions{}},
+		{cfg, SpawnOptions{Profile: "locked"}},
+	} {
+		if !egressRestricted(restricted.cfg, restricted.opts) {
+			t.Errorf("%+v should be restricted", restricted.opts)
+		}
+	}
+}
+
+func TestEgressHostsReachTheDefaultRouter(t *testing.T) {
+	t.Setenv("AGENT_LLM_BASE_URL", "")
+	hosts := egressHosts(&config.Config{}, SpawnOptions{})
+	found := false
+	for _, h := range hosts {
+		if h == "host.containers.internal" {
+			found = true
+		}
+		if h == ".anthropic.com" {
+			t.Errorf("nothing in the image calls the Anthropic API: %v", hosts)
+		}
+	}
+	if !found {
+		t.Errorf("the image's router host is missing from %v", hosts)
+	}
+}
+
+func TestStartEgress(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$*" >> `+calls)
+
+	args, err := startEgress("locked", config.Egress{}, []string{".github.com", "pypi.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "--network locked-egress") || !strings.Contains(joined, "HTTPS_PROXY=http://locked-proxy:3128") {
+		t.Errorf("run args = %s", joined)
+	}
+	conf, _ := os.ReadFile(egressConfigPath("locked"))
+	if !strings.Contains(string(conf), "acl allowed dstdomain .github.com\n") || !strings.Contains(string(conf), "http_access deny all") {
+		t.Errorf("squid.conf:\n%s", conf)
+	}
+	log, _ := os.ReadFile(calls)
+	for _, want := range []string{
+		"network create --internal locked-egress",
+		"run -d --name locked-proxy --label agentctl.egress-for=locked --network locked-egress --network podman",
+		DefaultProxyImage,
+	} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("missing %q in podman calls:\n%s", want, log)
+		}
+	}
+
+	// Killing the agent takes its proxy and network with it.
+	os.Remove(calls)
+	saveAgent(&Agent{Name: "locked", Egress: []string{".github.com"}})
+	Kill("locked")
+	log, _ = os.ReadFile(calls)
+	if !strings.Contains(string(log), "rm -f locked-proxy") || !strings.Contains(string(log), "network rm -f locked-egress") {
+		t.Errorf("podman calls:\n%s", log)
+	}
+	if _, err := os.Stat(egressConfigPath("locked")); !os.IsNotExist(err) {
+		t.Error("proxy config should be removed")
+	}
+}
@@ -0,0 +1,285 @@
+package container
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// EncryptionKeyEnv holds a base64 32-byte key, used instead of the keyring.
+const EncryptionKeyEnv = "AGENTCTL_ENCRYPTION_KEY"
+
+// Sealed data is one line: sealedPrefix then base64 of nonce and AES-256-GCM
+// ciphertext, so whole files and JSONL records share a format and plaintext
+// written before encryption was turned on still reads. AES-GCM rather than
+// NaCl secretbox because it is in the standard library: an authenticated
+// cipher either way, without a golang.org/x/crypto dependency. A random
+// 96-bit nonce per seal is safe for the few million records a host writes
+// under one key.
+const (
+	sealedPrefix       = "agentctl:v1:"
+	defaultKeyringName = "agentctl"
+	keyringAccount     = "encryption-key"
+)
+
+// ErrNoEncryptionKey is returned when sealed data is met, or encryption is
+// on, and no key can be found or stored.
+var ErrNoEncryptionKey = errors.New("no encryption key")
+
+// encryptionEnabled is swapped out in tests.
+var encryptionEnabled = func() bool {
+	cfg, err := config.Load()
+	return err == nil && cfg.Encryption.Enabled
+}
+
+var (
+	keyMu     sync.Mutex
+	cachedKey []byte
+	// loadKey is swapped out in tests.
+	loadKey = keyringKey
+)
+
+// encryptionKey returns the key, reading or creating it once per process.
+func encryptionKey() ([]byte, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if cachedKey != nil {
+		return cachedKey, nil
+	}
+	var key []byte
+	var err error
+	if env := os.Getenv(EncryptionKeyEnv); env != "" {
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(env))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s must be a base64 32-byte key", EncryptionKeyEnv)
+		}
+	} else if key, err = loadKey(); err != nil {
+		return nil, err
+	}
+	cachedKey = key
+	return key, nil
+}
+
+// keyringKey reads the key from the OS keyring — secret-tool (libsecret) on
+// Linux, the login keychain on macOS — storing a new one the first time.
+// The key goes to the keyring tool on stdin, never in its arguments.
+func keyringKey() ([]byte, error) {
+	name := defaultKeyringName
+	if cfg, err := config.Load(); err == nil && cfg.Encryption.Keyring != "" {
+		name = cfg.Encryption.Keyring
+	}
+	var lookup func() *exec.Cmd
+	var store func(encoded string) *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		lookup = func() *exec.Cmd {
+			return exec.Command("secret-tool", "lookup", "service", name, "account", keyringAccount)
+		}
+		store = func(encoded string) *exec.Cmd {
+			cmd := exec.Command("secret-tool", "store", "--label", "agentctl encryption key", "service", name, "account", keyringAccount)
+			cmd.Stdin = strings.NewReader(encoded)
+			return cmd
+		}
+	case "darwin":
+		lookup = func() *exec.Cmd {
+			return exec.Command("security", "find-generic-password", "-s", name, "-a", keyringAccount, "-w")
+		}
+		store = func(encoded string) *exec.Cmd {
+			// security -i reads the command from stdin.
+			cmd := exec.Command("security", "-i")
+			cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -s %q -a %q -w %q\n", name, keyringAccount, encoded))
+			return cmd
+		}
+	default:
+		return nil, fmt.Errorf("%w: no OS keyring support on %s; set %s", ErrNoEncryptionKey, runtime.GOOS, EncryptionKeyEnv)
+	}
+	read := func() ([]byte, bool, error) {
+		out, err := lookup().Output()
+		if err != nil || len(bytes.TrimSpace(out)) == 0 {
+			return nil, false, nil
+		}
+		stored, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil || len(stored) != 32 {
+			return nil, true, fmt.Errorf("keyring entry %q is not an agentctl key", name)
+		}
+		return stored, true, nil
+	}
+	if key, found, err := read(); found {
+		return key, err
+	}
+
+	// Creating the key: processes starting at once each make one, so they
+	// take turns, and each uses whatever the keyring holds once its turn
+	// is over.
+	if err := os.MkdirAll(config.Dir(), 0755); err != nil {
+		return nil, err
+	}
+	unlock, err := lockFile(filepath.Join(config.Dir(), "keyring.lock"), rateLockStale)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if key, found, err := read(); found {
+		return key, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	out, storeErr := store(base64.StdEncoding.EncodeToString(key)).CombinedOutput()
+	if stored, found, err := read(); found {
+		return stored, err
+	}
+	if storeErr == nil {
+		storeErr = errors.New("the new key could not be read back")
+	}
+	return nil, fmt.Errorf("%w: could not store one in the keyring (%v: %s); set %s",
+		ErrNoEncryptionKey, storeErr, strings.TrimSpace(string(out)), EncryptionKeyEnv)
+}
+
+// seal encrypts data when encryption is enabled, and returns it as it is
+// otherwise.
+func seal(data []byte) ([]byte, error) {
+	if !encryptionEnabled() {
+		return data, nil
+	}
+	return sealWith(data)
+}
+
+func sealWith(data []byte) ([]byte, error) {
+	gcm, err := encryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, data, nil)
+	return []byte(sealedPrefix + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// unseal decrypts sealed data and passes plaintext through.
+func unseal(data []byte) ([]byte, error) {
+	text := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(text, []byte(sealedPrefix)) {
+		return data, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(text[len(sealedPrefix):]))
+	if err != nil {
+		return nil, fmt.Errorf("corrupt encrypted data: %w", err)
+	}
+	gcm, err := encryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, fmt.Errorf("corrupt encrypted data")
+	}
+	plain, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt (wrong key?): %w", err)
+	}
+	return plain, nil
+}
+
+func encryptionCipher() (cipher.AEAD, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptStored encrypts the history records and recorded session events
+// written before encryption was turned on, returning how many of each it
+// sealed. The event store is rewritten under the lock the daemon appends
+// under, so no event recorded meanwhile is lost.
+func EncryptStored() (records, events int, err error) {
+	err = inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT name, data FROM history`)
+		if err != nil {
+			return err
+		}
+		plain := map[string][]byte{}
+		for rows.Next() {
+			var name string
+			var data []byte
+			if err := rows.Scan(&name, &data); err != nil {
+				rows.Close()
+				return err
+			}
+			if !bytes.HasPrefix(data, []byte(sealedPrefix)) {
+				plain[name] = data
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		for name, data := range plain {
+			sealed, err := sealWith(data)
+			if err != nil {
+				return err
+			}
+			// A sealed record keeps no repo column.
+			if _, err := tx.Exec(`UPDATE history SET data = ?, repo_key = '' WHERE name = ?`, sealed, name); err != nil {
+				return err
+			}
+		}
+		records = len(plain)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	unlock, err := lockSpyStore()
+	if err != nil {
+		return records, events, err
+	}
+	defer unlock()
+	data, err := os.ReadFile(SpyStorePath())
+	if os.IsNotExist(err) {
+		return records, events, nil
+	}
+	if err != nil {
+		return records, events, err
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !bytes.HasPrefix(line, []byte(sealedPrefix)) {
+			if line, err = sealWith(line); err != nil {
+				return records, events, err
+			}
+			events++
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if events == 0 {
+		return records, events, nil
+	}
+	return records, events, writeFileAtomic(SpyStorePath(), out.Bytes(), 0600)
+}
@@ -0,0 +1,192 @@
+package container
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useEncryption turns encryption on or off with a fixed key for the test.
+func useEncryption(t *testing.T, enabled bool, key []byte) {
+	origEnabled, origLoad := encryptionEnabled, loadKey
+	t.Cleanup(func() {
+		encryptionEnabled, loadKey = origEnabled, origLoad
+		cachedKey = nil
+	})
+	encryptionEnabled = func() bool { return enabled }
+	loadKey = func() ([]byte, error) { return key, nil }
+	cachedKey = nil
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	useEncryption(t, true, bytes.Repeat([]byte{7}, 32))
+
+	sealed, err := seal([]byte(`{"pr":"https://github.com/acme/api/pull/1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(sealed), sealedPrefix) || strings.Contains(string(sealed), "acme") {
+		t.Fatalf("sealed = %s", sealed)
+	}
+	plain, err := unseal(sealed)
+	if err != nil || string(plain) != `{"pr":"https://github.com/acme/api/pull/1"}` {
+		t.Errorf("unseal = %s, %v", plain, err)
+	}
+	if plain, _ := unseal([]byte(`{"plain":true}`)); string(plain) != `{"plain":true}` {
+		t.Errorf("plaintext should pass through, got %s", plain)
+	}
+
+	cachedKey = bytes.Repeat([]byte{8}, 32)
+	if _, err := unseal(sealed); err == nil {
+		t.Error("unseal with the wrong key should fail")
+	}
+}
+
+func TestEncryptedHistoryAndEvents(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	// Written before encryption was turned on.
+	useEncryption(t, false, bytes.Repeat([]byte{7}, 32))
+	SaveHistory(&AgentHistory{Name: "old", Repo: "https://github.com/acme/api", Result: "success"})
+	os.WriteFile(SpyStorePath(), []byte(`{"agent":"old","kind":"tool","tool":"Bash"}`+"\n"), 0644)
+
+	useEncryption(t, true, bytes.Repeat([]byte{7}, 32))
+	SaveHistory(&AgentHistory{Name: "new", Repo: "https://github.com/acme/api", Result: "success"})
+	if data := storedHistory(t, "new"); strings.Contains(string(data), "acme") {
+		t.Errorf("history written in plaintext: %s", data)
+	}
+	if info, _ := os.Stat(storePath()); info.Mode().Perm() != 0600 {
+		t.Errorf("store mode = %v", info.Mode().Perm())
+	}
+	records, err := ListHistory()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("ListHistory = %d records, %v", len(records), err)
+	}
+	if h, err := LoadHistory("new"); err != nil || h.Repo != "https://github.com/acme/api" {
+		t.Errorf("LoadHistory = %+v, %v", h, err)
+	}
+
+	f, _ := os.OpenFile(SpyStorePath(), os.O_APPEND|os.O_WRONLY, 0600)
+	rec := NewSpyRecorder(f, "new", "s1.jsonl")
+	rec.Record(`{"timestamp":"2026-01-02T15:04:05Z","message":{"role":"assistant","content":[{"type":"tool_use","name":"Read","input":{"file_path":"a.go"}}]}}`)
+	f.Close()
+
+	records2, events, err := EncryptStored()
+	if err != nil || records2 != 1 || events != 1 {
+		t.Fatalf("EncryptStored = %d, %d, %v", records2, events, err)
+	}
+	if data := storedHistory(t, "old"); strings.Contains(string(data), "acme") {
+		t.Errorf("history still has plaintext: %s", data)
+	}
+	if data, _ := os.ReadFile(SpyStorePath()); strings.Contains(string(data), "Bash") {
+		t.Errorf("events still have plaintext: %s", data)
+	}
+	got, err := LoadSpyRecords()
+	if err != nil || len(got) != 2 || got[0].Tool != "Bash" || got[1].Tool != "Read" {
+		t.Errorf("LoadSpyRecords = %+v, %v", got, err)
+	}
+}
+
+func TestEncryptedCheckpoint(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useEncryption(t, true, bytes.Repeat([]byte{7}, 32))
+
+	if err := saveCheckpoint(&Checkpoint{Agent: "w1", Task: "rotate the acme API keys", Attempt: 1, MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(checkpointPath("w1"))
+	if strings.Contains(string(data), "acme") {
+		t.Errorf("checkpoint written in plaintext: %s", data)
+	}
+	if info, _ := os.Stat(checkpointPath("w1")); info.Mode().Perm() != 0600 {
+		t.Errorf("checkpoint mode = %v", info.Mode().Perm())
+	}
+	if cp, err := LoadCheckpoint("w1"); err != nil || cp.Task != "rotate the acme API keys" {
+		t.Errorf("LoadCheckpoint = %+v, %v", cp, err)
+	}
+
+	// Without the key the checkpoint can't be read, but still exists.
+	cachedKey = bytes.Repeat([]byte{8}, 32)
+	if _, err := LoadCheckpoint("w1"); err == nil || !hasCheckpoint("w1") {
+		t.Errorf("LoadCheckpoint with the wrong key = %v, hasCheckpoint %v", err, hasCheckpoint("w1"))
+	}
+}
+
+func TestKeyringKeyCreatedOnce(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fakes secret-tool")
+	}
+	t.Setenv("HOME", t.TempDir())
+	bin, entry := t.TempDir(), filepath.Join(t.TempDir(), "entry")
+	// A keyring whose store replaces the entry, as libsecret's does.
+	os.WriteFile(filepath.Join(bin, "secret-tool"), []byte(`#!/bin/sh
+case "$1" in
+lookup) cat `+entry+` 2>/dev/null ;;
+store) sleep 0.05; cat > `+entry+` ;;
+esac
+`), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	keys := make([][]byte, 4)
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, err := keyringKey()
+			if err != nil {
+				t.Error(err)
+			}
+			keys[i] = key
+		}(i)
+	}
+	wg.Wait()
+	stored, _ := os.ReadFile(entry)
+	for i, key := range keys {
+		if base64.StdEncoding.EncodeToString(key) != strings.TrimSpace(string(stored)) {
+			t.Errorf("process %d uses a key the keyring doesn't hold", i)
+		}
+	}
+}
+
+func TestEncryptStoredKeepsAppends(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useEncryption(t, false, bytes.Repeat([]byte{7}, 32))
+	os.MkdirAll(filepath.Dir(SpyStorePath()), 0755)
+	var plain bytes.Buffer
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&plain, `{"agent":"old","kind":"tool","tool":"Bash %d"}`+"\n", i)
+	}
+	os.WriteFile(SpyStorePath(), plain.Bytes(), 0600)
+
+	// The daemon keeps recording while the store is rewritten.
+	useEncryption(t, true, bytes.Repeat([]byte{7}, 32))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			var buf bytes.Buffer
+			rec := NewSpyRecorder(&buf, "new", "s1.jsonl")
+			rec.Record(`{"timestamp":"2026-01-02T15:04:05Z","message":{"role":"assistant","content":[{"type":"tool_use","name":"Read","input":{"file_path":"a.go"}}]}}`)
+			if err := appendSpyStore(buf.Bytes()); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	if _, events, err := EncryptStored(); err != nil || events != 500 {
+		t.Errorf("EncryptStored = %d events, %v", events, err)
+	}
+	<-done
+	if got, err := LoadSpyRecords(); err != nil || len(got) != 550 {
+		t.Errorf("LoadSpyRecords = %d records, %v; want 550", len(got), err)
+	}
+}
@@ -0,0 +1,43 @@
+package container
+
+import "errors"
+
+// Errors callers can test for with errors.Is. They are returned wrapped, with
+// the agent or container named in the message; the CLI maps each to its own
+// exit code.
+var (
+	// ErrAgentNotFound: no agent (metadata or container) by that name.
+	ErrAgentNotFound = errors.New("agent not found")
+	// ErrContainerNotRunning: the agent's container exists but is stopped,
+	// paused or exited.
+	ErrContainerNotRunning = errors.New("container not running")
+	// ErrMaxAttempts: the run loop used every attempt without the task
+	// being done.
+	ErrMaxAttempts = errors.New("max attempts reached")
+	// ErrBudgetExceeded: a run went over its spending limit. Nothing sets a
+	// limit yet; the error and its exit code are fixed now so scripts can
+	// rely on them.
+	ErrBudgetExceeded = errors.New("budget exceeded")
+	// ErrRunInProgress: another agentctl process is running the agent's
+	// loop.
+	ErrRunInProgress = errors.New("run already in progress")
+	// ErrNoCapacity: the host is over a capacity limit, so the agent was
+	// not spawned.
+	ErrNoCapacity = errors.New("host at capacity")
+	// ErrImageUnavailable: the agent image is not present locally and could
+	// not be pulled.
+	ErrImageUnavailable = errors.New("image unavailable")
+	// ErrDependencyFailed: an agent the run needs failed, or was removed
+	// before it completed, so the run did not start.
+	ErrDependencyFailed = errors.New("dependency failed")
+	// ErrToolchainMissing: the image lacks a runtime the repo's manifests
+	// need, or has too old a version of it, so the agent was not spawned.
+	ErrToolchainMissing = errors.New("toolchain missing")
+	// ErrUnrecoverable: an attempt failed in a way run_loop.retry says not
+	// to retry (by default, an agent that can't run at all), so the run
+	// stopped before using its attempts.
+	ErrUnrecoverable = errors.New("unrecoverable failure")
+	// ErrAuthFailed: the agent could not reach its model with the
+	// credentials it has, even after refreshing them from the host.
+	ErrAuthFailed = errors.New("auth failed")
+)
@@ -0,0 +1,166 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// FailureClass is why an attempt left the task unfinished, as far as the
+// agent's output and the check can tell.
+type FailureClass string
+
+const (
+	FailureTests         FailureClass = "tests"          // the suite (or --done-when) fails
+	FailureLint          FailureClass = "lint"           // it fails on linting or static analysis only
+	FailureMergeConflict FailureClass = "merge_conflict" // the workspace has unmerged files
+	FailureDependency    FailureClass = "dependency"     // the tests can't run: a package or tool is missing
+	FailureRateLimit     FailureClass = "rate_limit"     // the API turned the agent away for now
+	FailureAgentError    FailureClass = "agent_error"    // the agent can't run at all (auth, billing, no run-task)
+	FailureUnfinished    FailureClass = "unfinished"     // none of those: e.g. work left uncommitted
+)
+
+// RetryStrategy is what the run loop does about a failed attempt.
+type RetryStrategy string
+
+const (
+	// RetryPrompt retries with the prompt written for the failure's class.
+	RetryPrompt RetryStrategy = "prompt"
+	// RetrySetup re-runs the agent's setup commands, then retries with the
+	// class's prompt.
+	RetrySetup RetryStrategy = "setup"
+	// RetryBackoff waits the longest backoff (run_loop.max_backoff) before
+	// retrying with the same prompt as last time.
+	RetryBackoff RetryStrategy = "backoff"
+	// RetryAbort ends the run with ErrUnrecoverable, keeping the attempts
+	// left for after a person has looked.
+	RetryAbort RetryStrategy = "abort"
+	// RetryPlain retries with the generic retry prompt.
+	RetryPlain RetryStrategy = "retry"
+)
+
+// defaultRetryStrategies are the strategies run_loop.retry starts from.
+var defaultRetryStrategies = map[FailureClass]RetryStrategy{
+	FailureTests:         RetryPrompt,
+	FailureLint:          RetryPrompt,
+	FailureMergeConflict: RetryPrompt,
+	FailureDependency:    RetrySetup,
+	FailureRateLimit:     RetryBackoff,
+	FailureAgentError:    RetryAbort,
+	FailureUnfinished:    RetryPlain,
+}
+
+// retryStrategies are the defaults with run_loop.retry applied. Unknown
+// classes and strategies are reported and ignored.
+func retryStrategies() map[FailureClass]RetryStrategy {
+	strategies := map[FailureClass]RetryStrategy{}
+	for class, s := range defaultRetryStrategies {
+		strategies[class] = s
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return strategies
+	}
+	for class, s := range cfg.RunLoop.Retry {
+		if _, ok := defaultRetryStrategies[FailureClass(class)]; !ok {
+			fmt.Printf("⚠️  run_loop.retry: unknown failure class %q (want %s)\n", class, strings.Join(failureClasses(), ", "))
+			continue
+		}
+		switch strategy := RetryStrategy(s); strategy {
+		case RetryPrompt, RetrySetup, RetryBackoff, RetryAbort, RetryPlain:
+			strategies[FailureClass(class)] = strategy
+		default:
+			fmt.Printf("⚠️  run_loop.retry: unknown strategy %q for %s (want prompt, setup, backoff, abort or retry)\n", s, class)
+		}
+	}
+	return strategies
+}
+
+func failureClasses() []string {
+	var classes []string
+	for class := range defaultRetryStrategies {
+		classes = append(classes, string(class))
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+var (
+	// rateLimitOutput is the API refusing the agent for now, as Claude
+	// Code reports it.
+	rateLimitOutput = regexp.MustCompile(`(?im)^.*(API Error:?\s*(429|529)\b|rate_limit_error|overloaded_error|usage limit reached)`)
+	// agentErrorOutput is the agent unable to run at all: retrying
+	// without someone fixing the image or the credentials won't help.
+	agentErrorOutput = regexp.MustCompile(`(?im)^.*(Invalid API key|API Error:?\s*(401|403)\b|authentication_error|permission_error|credit balance is too low|Please run /login|run-task: (command )?not found)`)
+	// dependencyOutput is a test run that couldn't load a package or
+	// find a tool, rather than tests that ran and failed.
+	dependencyOutput = regexp.MustCompile(`(?m)(Cannot find module '|ERR_MODULE_NOT_FOUND|ModuleNotFoundError|No module named |cannot find package|no required module provides package|missing go\.sum entry|vendor/autoload\.php|failed to select a version for|: (command )?not found$)`)
+	// lintOutput is a linter or static analyser failing the run.
+	lintOutput = regexp.MustCompile(`(?i)\b(eslint|phpstan|psalm|golangci-lint|go vet|staticcheck|php-cs-fixer|pint|prettier|flake8|ruff|pylint|mypy|rubocop|clippy|stylelint)\b`)
+)
+
+// classifyFailure says why an attempt failed, from the agent's output and
+// the check after it. What stops the agent outright comes first, then what
+// stops the tests from meaning anything, then the tests themselves.
+func classifyFailure(status AgentStatus, output string) FailureClass {
+	switch {
+	case rateLimitOutput.MatchString(output):
+		return FailureRateLimit
+	case agentErrorOutput.MatchString(output):
+		return FailureAgentError
+	case len(status.Unmerged) > 0:
+		return FailureMergeConflict
+	case status.TestStatus != "fail":
+		return FailureUnfinished
+	case dependencyOutput.MatchString(status.TestOutput):
+		return FailureDependency
+	case len(status.FailingTests) == 0 && lintOutput.MatchString(status.TestOutput):
+		return FailureLint
+	}
+	return FailureTests
+}
+
+// failurePrompt is the lead-in the class's prompt adds to the retry
+// prompt; "" for classes the retry prompt already covers. rerunSetup says
+// whether the setup commands ran again since.
+func failurePrompt(class FailureClass, status AgentStatus, rerunSetup bool) string {
+	switch class {
+	case FailureLint:
+		return "The last attempt failed on linting or static analysis, not on the tests. Fix what the linter reports without changing what the code does, and run the linter before finishing.\n\n"
+	case FailureMergeConflict:
+		files := "some files"
+		if len(status.Unmerged) > 0 {
+			files = strings.Join(status.Unmerged, ", ")
+		}
+		return "The last attempt left unresolved merge conflicts in " + files + ". Resolve them first, keeping the intent of both sides, then `git add` them and finish the merge or rebase (`git rebase --continue`) before anything else.\n\n"
+	case FailureDependency:
+		note := "The last attempt's tests could not run because a package or tool is missing"
+		if rerunSetup {
+			note += "; the setup commands have been run again"
+		}
+		return note + ". If it is still missing, add it to the project's manifest and install it rather than changing the code under test.\n\n"
+	}
+	return ""
+}
+
+// rerunSetup runs the agent's setup commands again, as spawn did, for an
+// attempt that failed on a missing dependency. Agents spawned with
+// --no-setup are left alone; it reports whether setup ran.
+func rerunSetup(name string) bool {
+	agent, err := loadAgent(name)
+	if err != nil || agent.SkipSetup {
+		return false
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return false
+	}
+	fmt.Printf("🔧 Re-running setup for the missing dependency\n")
+	if err := runSetup(name, cfg.Setup, agent.SetupCommands); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	failing := AgentStatus{TestStatus: "fail"}
+	tests := []struct {
+		name   string
+		status AgentStatus
+		output string
+		want   FailureClass
+	}{
+		{"rate limited", failing, "API Error: 429 {\"type\":\"error\",\"error\":{\"type\":\"rate_limit_error\"}}", FailureRateLimit},
+		{"usage limit", AgentStatus{TestStatus: "pass"}, "Claude AI usage limit reached|1760000000", FailureRateLimit},
+		{"bad key", failing, "Invalid API key · Please run /login", FailureAgentError},
+		{"no run-task", failing, "sh: 1: run-task: not found", FailureAgentError},
+		{"conflicted", AgentStatus{TestStatus: "fail", Unmerged: []string{"src/auth.go"}}, "", FailureMergeConflict},
+		{"missing module", AgentStatus{TestStatus: "fail", TestOutput: "Error: Cannot find module 'zod'\nRequire stack:"}, "", FailureDependency},
+		{"missing tool", AgentStatus{TestStatus: "fail", TestOutput: "sh: 1: jest: not found"}, "", FailureDependency},
+		{"lint only", AgentStatus{TestStatus: "fail", TestOutput: "> eslint src\n  3:7  error  'x' is assigned a value but never used"}, "", FailureLint},
+		{"lint and tests", AgentStatus{TestStatus: "fail", FailingTests: []string{"TestLogin"}, TestOutput: "eslint passed\n--- FAIL: TestLogin"}, "", FailureTests},
+		{"tests", AgentStatus{TestStatus: "fail", FailingTests: []string{"TestLogin"}}, "I fixed the rate limit handling", FailureTests},
+		{"uncommitted", AgentStatus{TestStatus: "pass", HasUncommitted: true}, "", FailureUnfinished},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure(tt.status, tt.output); got != tt.want {
+			t.Errorf("%s: classifyFailure() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnmergedFiles(t *testing.T) {
+	porcelain := "UU src/auth.go\n M README.md\nAA src/db.go\n?? notes.txt\n"
+	if got := unmergedFiles(porcelain); strings.Join(got, ",") != "src/auth.go,src/db.go" {
+		t.Errorf("unmergedFiles() = %v", got)
+	}
+}
+
+func TestFailurePrompt(t *testing.T) {
+	got := failurePrompt(FailureMergeConflict, AgentStatus{Unmerged: []string{"a.go", "b.go"}}, false)
+	if !strings.Contains(got, "merge conflicts in a.go, b.go") {
+		t.Errorf("merge conflict prompt = %q", got)
+	}
+	if got := failurePrompt(FailureDependency, AgentStatus{}, true); !strings.Contains(got, "setup commands have been run again") {
+		t.Errorf("dependency prompt after setup = %q", got)
+	}
+	if got := failurePrompt(FailureDependency, AgentStatus{}, false); strings.Contains(got, "setup") {
+		t.Errorf("dependency prompt without setup = %q", got)
+	}
+	if got := failurePrompt(FailureTests, AgentStatus{}, false); got != "" {
+		t.Errorf("the retry prompt covers failing tests already: %q", got)
+	}
+}
+
+func TestRetryStrategies(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"),
+		[]byte(`{"run_loop": {"retry": {"lint": "abort", "tests": "sideways", "flaky": "retry"}}}`), 0644)
+
+	got := retryStrategies()
+	if got[FailureLint] != RetryAbort {
+		t.Errorf("lint = %s, want the configured abort", got[FailureLint])
+	}
+	if got[FailureTests] != RetryPrompt || got[FailureDependency] != RetrySetup {
+		t.Errorf("unknown strategies and unlisted classes should keep their defaults: %v", got)
+	}
+	if _, ok := got["flaky"]; ok {
+		t.Errorf("unknown classes should be ignored: %v", got)
+	}
+}
+
+func TestRunAbortsOnAgentError(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"run_loop": {"settle": "0s", "backoff": "1ms"}, "auth": {"check": "off"}}`), 0644)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `case "$*" in
+*prompt.txt*) echo run >> `+calls+`; cat > /dev/null; echo "Invalid API key · Please run /login"; exit 1 ;;
+*"test -f go.mod"*) exit 0 ;;
+*"go test"*) echo FAIL; echo EXIT_CODE:1 ;;
+*"test -f"*) exit 1 ;;
+esac`)
+	saveAgent(&Agent{Name: "worker"})
+
+	result, err := RunUntilDone("worker", "fix login", 5)
+	if !errors.Is(err, ErrUnrecoverable) || result.Attempts != 1 || result.Failure != string(FailureAgentError) {
+		t.Fatalf("RunUntilDone() = %+v, %v; want it to stop after one attempt", result, err)
+	}
+	if data, _ := os.ReadFile(calls); strings.Count(string(data), "run") != 1 {
+		t.Errorf("the agent ran %d times", strings.Count(string(data), "run"))
+	}
+	cp, err := LoadCheckpoint("worker")
+	if err != nil || cp.Attempt != 1 || cp.Failure != string(FailureAgentError) {
+		t.Errorf("checkpoint = %+v, %v; want it kept for --resume", cp, err)
+	}
+}
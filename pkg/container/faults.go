@@ -0,0 +1,117 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FaultsEnv enables fault injection, for tests and CI only. It holds a
+// comma-separated list of rules, kind[:match][@count]: a podman call whose
+// arguments contain match fails the way kind says instead of running, for
+// the first count matching calls (every call without @count). For example
+//
+//	AGENTCTL_FAULTS=transient@2,rate-limit@1,clone-partial
+//
+// makes the first two podman calls hit lock contention, the first agent
+// run get rate limited and every clone break off. Counts are per process.
+const FaultsEnv = "AGENTCTL_FAULTS"
+
+// faultKinds are the injectable failures: what the call prints to stderr,
+// its exit code, and which calls it hits when the rule names none.
+var faultKinds = map[string]struct {
+	stderr string
+	code   int
+	match  string
+}{
+	// podman itself failing, retryable (see isTransient) or not.
+	"transient": {"Error: database is locked", 125, ""},
+	"fail":      {"Error: injected podman failure", 125, ""},
+	// podman not answering; the call runs into its timeout.
+	"hang": {"", 0, ""},
+	// The model API failing under the agent harness.
+	"api-error":  {`API Error: 500 {"type":"error","error":{"type":"api_error","message":"Internal server error"}}`, 1, "run-task"},
+	"rate-limit": {`API Error: 429 {"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your per-minute rate limit"}}`, 1, "run-task"},
+	// A clone that breaks off part way.
+	"clone-partial": {"error: RPC failed; curl 18 transfer closed with outstanding read data remaining\nfatal: early EOF\nfatal: index-pack failed", 128, "clone"},
+}
+
+type faultRule struct {
+	kind      string
+	match     string
+	remaining int // calls left to fail; -1 is unlimited
+}
+
+var faults struct {
+	sync.Mutex
+	spec  string
+	rules []*faultRule
+}
+
+// parseFaults parses a FaultsEnv value.
+func parseFaults(spec string) ([]*faultRule, error) {
+	var rules []*faultRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		r := &faultRule{remaining: -1}
+		if rest, count, ok := strings.Cut(item, "@"); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("fault %q: count must be a positive number", item)
+			}
+			item, r.remaining = rest, n
+		}
+		r.kind, r.match, _ = strings.Cut(item, ":")
+		kind, ok := faultKinds[r.kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown fault %q (want transient, fail, hang, api-error, rate-limit or clone-partial)", r.kind)
+		}
+		if r.match == "" {
+			r.match = kind.match
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// faultFor returns the fault to inject into a podman call, if any, counting
+// it against its rule.
+func faultFor(args []string) *faultRule {
+	spec := os.Getenv(FaultsEnv)
+	if spec == "" {
+		return nil
+	}
+	faults.Lock()
+	defer faults.Unlock()
+	if spec != faults.spec {
+		rules, err := parseFaults(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s ignored: %v\n", FaultsEnv, err)
+		}
+		faults.spec, faults.rules = spec, rules
+	}
+	call := strings.Join(args, " ")
+	for _, r := range faults.rules {
+		if r.remaining != 0 && strings.Contains(call, r.match) {
+			if r.remaining > 0 {
+				r.remaining--
+			}
+			return r
+		}
+	}
+	return nil
+}
+
+// command returns the shell command standing in for the failed call.
+func (r *faultRule) command() []string {
+	kind := faultKinds[r.kind]
+	if r.kind == "hang" {
+		return []string{"sh", "-c", "exec sleep 60"}
+	}
+	return []string{"sh", "-c", fmt.Sprintf("printf '%%s\\n' %s >&2; exit %d", shellQuote(kind.stderr), kind.code)}
+}
@@ -0,0 +1,75 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFaults(t *testing.T) {
+	rules, err := parseFaults("transient@2, rate-limit ,fail:inspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[0].remaining != 2 || rules[1].match != "run-task" || rules[1].remaining != -1 || rules[2].match != "inspect" {
+		t.Errorf("rules = %+v %+v %+v", rules[0], rules[1], rules[2])
+	}
+	for _, bad := range []string{"explode", "transient@0", "fail@x"} {
+		if _, err := parseFaults(bad); err == nil {
+			t.Errorf("parseFaults(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	shrinkRetryDelay(t)
+	fakePodman(t, "echo ok")
+
+	// Retries ride out transient failures, and give up when they persist.
+	t.Setenv(FaultsEnv, "transient@2")
+	if out, err := podmanRetry("inspect", "agent-1"); err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Errorf("podmanRetry() = %q, %v; want recovery after two transient failures", out, err)
+	}
+	t.Setenv(FaultsEnv, "transient:inspect@3")
+	if _, err := podmanRetry("inspect", "agent-1"); !isTransient(err) {
+		t.Errorf("podmanRetry() = %v, want the transient error after exhausting retries", err)
+	}
+	if out, err := podmanRetry("inspect", "agent-1"); err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Errorf("calls after the rule is used up should run: %q, %v", out, err)
+	}
+
+	t.Setenv(FaultsEnv, "hang:ps")
+	start := time.Now()
+	if _, err := podmanRetry("ps", "-a"); !errors.Is(err, ErrRuntimeUnresponsive) {
+		t.Errorf("hang: err = %v, want ErrRuntimeUnresponsive", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("hang took %s", time.Since(start))
+	}
+
+	// Model API failures surface from the agent run; other calls are untouched.
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	t.Setenv(FaultsEnv, "rate-limit@1")
+	if _, err := runTask("worker", "do it", ""); err == nil {
+		t.Error("rate-limited run succeeded")
+	}
+	if out, err := podman("exec", "agent-worker", "true").Output(); err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Errorf("unrelated call = %q, %v", out, err)
+	}
+	if _, err := runTask("worker", "do it", ""); err != nil {
+		t.Errorf("second run = %v, want success once the fault is used up", err)
+	}
+
+	t.Setenv(FaultsEnv, "clone-partial")
+	out, err := podmanLong("exec", "agent-worker", "git", "clone", "https://github.com/org/api", "/w").CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 128 || !strings.Contains(string(out), "early EOF") {
+		t.Errorf("clone = %q, %v", out, err)
+	}
+}
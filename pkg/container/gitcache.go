@@ -0,0 +1,170 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// containerMirrorPath is where a repo's mirror is mounted (read-only) in the
+// agent's container.
+const containerMirrorPath = "/var/cache/agentctl/mirror.git"
+
+// mirrorLockAge is how long a refresh lock is honoured; an older one is left
+// over from a refresh that died and is taken over.
+const mirrorLockAge = time.Hour
+
+// gitCacheDir holds the repo mirrors on the host.
+func gitCacheDir() string {
+	return filepath.Join(cacheDir(), "git")
+}
+
+var unsafeMirrorChars = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+
+// mirrorPath returns where repo's mirror lives: <owner>/<repo>.git for
+// GitHub repos, the URL's host and path for others.
+func mirrorPath(repo string) string {
+	key := config.RepoKey(strings.TrimSuffix(strings.TrimSpace(repo), "/"))
+	if _, rest, ok := strings.Cut(key, "://"); ok {
+		key = rest
+	}
+	key = strings.TrimPrefix(key, "git@")
+	key = strings.ReplaceAll(key, ":", "/")
+	key = unsafeMirrorChars.ReplaceAllString(key, "_")
+	key = strings.Trim(strings.ReplaceAll(key, "..", "_"), "/")
+	return filepath.Join(gitCacheDir(), filepath.FromSlash(key)+".git")
+}
+
+// useGitCache reports whether spawns on repo clone from a mirror.
+func useGitCache(c config.GitCache, repo string) bool {
+	if !c.Enabled || repo == "" {
+		return false
+	}
+	if len(c.Repos) == 0 {
+		return true
+	}
+	for _, r := range c.Repos {
+		if repoKey(r) == repoKey(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureMirror returns the path of repo's mirror. The first spawn creates it
+// (a full clone, once); later ones use it as it is and refresh it in the
+// background for the next spawn. auth is the environment that
+// authenticates git to the repo's host (see forge.AuthEnv), so the token is
+// neither written into the mirror's config nor shown in ps.
+func ensureMirror(repo string, auth []string) (string, error) {
+	path := mirrorPath(repo)
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err == nil {
+		refreshMirror(path, auth)
+		return path, nil
+	}
+	fmt.Printf("🪞 Creating a local mirror of %s (first spawn only)\n", repo)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(path), ".mirror-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	clone := exec.Command("git", "clone", "--bare", "--quiet", repo, tmp)
+	clone.Env = append(os.Environ(), auth...)
+	if out, err := clone.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("mirror clone failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// Fetch branches and tags only: a full --mirror would also pull every
+	// pull request ref.
+	exec.Command("git", "--git-dir", tmp, "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*").Run()
+	exec.Command("git", "--git-dir", tmp, "config", "--add", "remote.origin.fetch", "+refs/tags/*:refs/tags/*").Run()
+	// Another spawn may have created the mirror meanwhile; either will do.
+	if err := os.Rename(tmp, path); err != nil {
+		if _, statErr := os.Stat(filepath.Join(path, "HEAD")); statErr != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// refreshMirror starts a fetch into the mirror that outlives agentctl,
+// unless one is already running.
+func refreshMirror(path string, auth []string) {
+	lock := path + ".lock"
+	if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > mirrorLockAge {
+		os.Remove(lock)
+	}
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return // a refresh is already running
+	}
+	f.Close()
+	args := []string{"git", "--git-dir", path, "fetch", "--prune", "--quiet", "origin"}
+	env := append(os.Environ(), auth...)
+	if _, err := exec.LookPath("sh"); err != nil {
+		// No sh to outlive agentctl (Windows without Git's): fetch from
+		// here, leaving the lock to go stale if agentctl exits first.
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = env
+		if err := cmd.Start(); err != nil {
+			os.Remove(lock)
+			return
+		}
+		go func() {
+			cmd.Wait()
+			os.Remove(lock)
+		}()
+		return
+	}
+	cmd := exec.Command("sh", "-c", shellJoin(args)+" >/dev/null 2>&1; rm -f "+shellQuote(lock))
+	cmd.Env = env
+	if err := cmd.Start(); err != nil {
+		os.Remove(lock)
+		return
+	}
+	go cmd.Wait()
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// mirrorCloneScript clones the workspace from the mounted mirror, points
+// origin at the real URL and catches up with it, since the mirror may be a
+// refresh behind.
+func mirrorCloneScript(cloneURL, workspace string) string {
+	ws := shellQuote(workspace)
+	return "git clone --quiet --no-hardlinks " + containerMirrorPath + " " + ws +
+		" && cd " + ws +
+		" && git remote set-url origin " + shellQuote(cloneURL) +
+		" && git fetch --prune --quiet origin" +
+		` && git reset --quiet --hard "@{upstream}"`
+}
+
+// cloneRepo clones repo into the agent's workspace, from the mounted mirror
+// when there is one. A failed mirror clone falls back to a direct one.
+func cloneRepo(name, cloneURL string, layout Layout, mirror bool) {
+	if mirror {
+		// The output is not shown: it can contain the tokenised clone URL.
+		err := podmanLong("exec", containerName(name), "sh", "-c", mirrorCloneScript(cloneURL, layout.Workspace)).Run()
+		if err == nil {
+			fmt.Printf("🪞 Cloned from the local mirror\n")
+			return
+		}
+		fmt.Printf("⚠️  Mirror clone failed (%v), cloning directly\n", err)
+		podman("exec", containerName(name), "rm", "-rf", layout.Workspace).Run()
+	}
+	podmanLong("exec", containerName(name), "git", "clone", cloneURL, layout.Workspace).Run()
+}
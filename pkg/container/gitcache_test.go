@@ -0,0 +1,139 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+)
+
+func TestMirrorPath(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	for repo, want := range map[string]string{
+		"https://github.com/acme/mono.git":   "/home/u/.agentctl/cache/git/acme/mono.git",
+		"git@github.com:acme/mono.git":       "/home/u/.agentctl/cache/git/acme/mono.git",
+		"https://gitlab.example.com/a/b":     "/home/u/.agentctl/cache/git/gitlab.example.com/a/b.git",
+		"https://evil.example.com/../../etc": "/home/u/.agentctl/cache/git/evil.example.com/_/_/etc.git",
+	} {
+		if got := mirrorPath(repo); got != want {
+			t.Errorf("mirrorPath(%q) = %s, want %s", repo, got, want)
+		}
+	}
+}
+
+func TestUseGitCache(t *testing.T) {
+	repo := "https://github.com/acme/mono"
+	if useGitCache(config.GitCache{}, repo) {
+		t.Error("the cache is opt-in")
+	}
+	if !useGitCache(config.GitCache{Enabled: true}, repo) {
+		t.Error("enabled with no repo list should cover every repo")
+	}
+	only := config.GitCache{Enabled: true, Repos: []string{"acme/mono"}}
+	if !useGitCache(only, repo+".git") || useGitCache(only, "https://github.com/acme/small") {
+		t.Error("a repo list should limit the cache to those repos")
+	}
+	if useGitCache(config.GitCache{Enabled: true}, "") {
+		t.Error("no repo, nothing to cache")
+	}
+}
+
+func hostGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestEnsureMirror(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	src := t.TempDir()
+	hostGit(t, src, "init", "-q", "-b", "main")
+	hostGit(t, src, "commit", "-q", "--allow-empty", "-m", "one")
+	hostGit(t, src, "update-ref", "refs/pull/1/head", "HEAD")
+
+	path, err := ensureMirror(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != mirrorPath(src) {
+		t.Errorf("mirror at %s, want %s", path, mirrorPath(src))
+	}
+	if got := hostGit(t, path, "rev-parse", "main"); got != hostGit(t, src, "rev-parse", "HEAD") {
+		t.Errorf("mirror main = %s", got)
+	}
+
+	// Later spawns refresh it in the background, branches and tags only.
+	hostGit(t, src, "commit", "-q", "--allow-empty", "-m", "two")
+	head := hostGit(t, src, "rev-parse", "HEAD")
+	if _, err := ensureMirror(src, nil); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for hostGit(t, path, "rev-parse", "main") != head && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if hostGit(t, path, "rev-parse", "main") != head {
+		t.Error("the background refresh did not fetch the new commit")
+	}
+	for _, err := os.Stat(path + ".lock"); err == nil && time.Now().Before(deadline); _, err = os.Stat(path + ".lock") {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := os.Stat(path + ".lock"); err == nil {
+		t.Error("the refresh lock should be removed when the fetch ends")
+	}
+	if refs := hostGit(t, path, "for-each-ref", "refs/pull"); refs != "" {
+		t.Errorf("pull request refs should not be mirrored: %s", refs)
+	}
+}
+
+func TestMirrorCloneScript(t *testing.T) {
+	script := mirrorCloneScript("https://tok@github.com/acme/mono", "/home/agent/workspace/repo")
+	for _, want := range []string{
+		"git clone --quiet --no-hardlinks " + containerMirrorPath + " '/home/agent/workspace/repo'",
+		"git remote set-url origin 'https://tok@github.com/acme/mono'",
+		"git fetch --prune --quiet origin",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script %q lacks %q", script, want)
+		}
+	}
+}
+
+func TestRefreshMirrorKeepsTokenOutOfArgs(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	bin, seen := t.TempDir(), filepath.Join(t.TempDir(), "seen")
+	os.WriteFile(filepath.Join(bin, "git"), []byte("#!/bin/sh\necho \"args: $*\" > "+seen+".tmp\necho \"header: $GIT_CONFIG_VALUE_0\" >> "+seen+".tmp\nmv "+seen+".tmp "+seen+"\n"), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	path := filepath.Join(t.TempDir(), "mono.git")
+	refreshMirror(path, forge.AuthEnv(forge.For("https://github.com/acme/mono"), "https://github.com/acme/mono", "s3cret"))
+	deadline := time.Now().Add(10 * time.Second)
+	var data []byte
+	for ; len(data) == 0 && time.Now().Before(deadline); data, _ = os.ReadFile(seen) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	args, header, _ := strings.Cut(string(data), "\n")
+	if !strings.HasPrefix(header, "header: AUTHORIZATION: basic ") {
+		t.Errorf("git's environment lacks the auth header: %q", data)
+	}
+	if strings.Contains(args, "AUTHORIZATION") || strings.Contains(args, "extraheader") {
+		t.Errorf("the auth header is in git's arguments: %q", args)
+	}
+}
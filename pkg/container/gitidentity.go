@@ -0,0 +1,129 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Where signing material is mounted, read-only, in the container. Spawn
+// copies it into the agent's home so ssh-keygen and gpg get the private,
+// writable copies they insist on.
+const (
+	containerSigningKey = "/var/run/agentctl/signing-key"
+	containerGPGHome    = "/var/run/agentctl/gnupg"
+)
+
+// expandAgent fills in {agent} in a git setting.
+func expandAgent(s, name string) string {
+	return strings.ReplaceAll(s, "{agent}", name)
+}
+
+// gitSigningMounts returns the podman run flags mounting the configured
+// signing key (ssh) or keyring (gpg), checking they exist.
+func gitSigningMounts(s config.GitSigning) ([]string, error) {
+	switch s.Format {
+	case "":
+		return nil, nil
+	case "ssh":
+		if s.Key == "" {
+			return nil, fmt.Errorf("git.signing: ssh signing needs key (the private key file)")
+		}
+		if _, err := os.Stat(s.Key); err != nil {
+			return nil, fmt.Errorf("git.signing: %w", err)
+		}
+		return []string{"-v", s.Key + ":" + containerSigningKey + ":ro,z"}, nil
+	case "gpg":
+		if s.Key == "" {
+			return nil, fmt.Errorf("git.signing: gpg signing needs key (the key ID)")
+		}
+		home := s.GPGHome
+		if home == "" {
+			home = config.ExpandHome("~/.gnupg")
+		}
+		if _, err := os.Stat(home); err != nil {
+			return nil, fmt.Errorf("git.signing: %w", err)
+		}
+		return []string{"-v", home + ":" + containerGPGHome + ":ro,z"}, nil
+	}
+	return nil, fmt.Errorf("git.signing: unknown format %q (use ssh or gpg)", s.Format)
+}
+
+// gitIdentityScript configures git in the container: identity, signing
+// (checking the key can be used) and the trailer hook in the workspace.
+// It returns "" when nothing is configured.
+func gitIdentityScript(g config.Git, name string, layout Layout) string {
+	var lines []string
+	set := func(key, value string) {
+		lines = append(lines, "git config --global "+key+" "+shellQuote(value))
+	}
+	if g.Name != "" {
+		set("user.name", expandAgent(g.Name, name))
+	}
+	if g.Email != "" {
+		set("user.email", expandAgent(g.Email, name))
+	}
+	switch g.Signing.Format {
+	case "ssh":
+		key := layout.Path(".ssh/agentctl-signing-key")
+		lines = append(lines,
+			"mkdir -p "+shellQuote(filepath.Dir(key)),
+			"cp "+containerSigningKey+" "+shellQuote(key),
+			"chmod 600 "+shellQuote(key),
+			// Fails on a missing or passphrase-protected key rather than
+			// leaving every later commit to fail.
+			"ssh-keygen -y -P '' -f "+shellQuote(key)+" >/dev/null")
+		set("gpg.format", "ssh")
+		set("user.signingkey", key)
+	case "gpg":
+		home := layout.Path(".gnupg")
+		lines = append(lines,
+			"rm -rf "+shellQuote(home),
+			"cp -r "+containerGPGHome+" "+shellQuote(home),
+			"chmod 700 "+shellQuote(home),
+			"gpg --batch --list-secret-keys "+shellQuote(g.Signing.Key)+" >/dev/null")
+		set("gpg.format", "openpgp")
+		set("user.signingkey", g.Signing.Key)
+	}
+	if g.Signing.Format != "" {
+		set("commit.gpgsign", "true")
+		set("tag.gpgsign", "true")
+	}
+	if g.Trailer != "" {
+		lines = append(lines, layout.cd()+trailerHookScript(expandAgent(g.Trailer, name)))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "set -e\n" + strings.Join(lines, "\n")
+}
+
+// trailerHookScript installs a prepare-commit-msg hook in the repo's own
+// hooks directory (never a tracked one) that adds trailer to each commit,
+// keeping any hook already there.
+func trailerHookScript(trailer string) string {
+	hook := `#!/bin/sh
+if [ -x "$0.orig" ]; then "$0.orig" "$@" || exit $?; fi
+git interpret-trailers --in-place --if-exists addIfDifferent --trailer ` + shellQuote(trailer) + ` "$1"
+`
+	return `hooks="$(git rev-parse --git-dir)/hooks" && mkdir -p "$hooks"` +
+		` && if [ -e "$hooks/prepare-commit-msg" ] && ! grep -q agentctl-trailer "$hooks/prepare-commit-msg"; then mv "$hooks/prepare-commit-msg" "$hooks/prepare-commit-msg.orig"; fi` +
+		` && printf '%s# agentctl-trailer\n' ` + shellQuote(hook) + ` > "$hooks/prepare-commit-msg"` +
+		` && chmod +x "$hooks/prepare-commit-msg"`
+}
+
+// configureGit applies the git settings to a freshly cloned agent.
+func configureGit(name string, g config.Git, layout Layout) error {
+	script := gitIdentityScript(g, name, layout)
+	if script == "" {
+		return nil
+	}
+	out, err := podman("exec", containerName(name), "sh", "-c", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git identity setup failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
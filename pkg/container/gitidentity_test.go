@@ -0,0 +1,95 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestGitIdentityScript(t *testing.T) {
+	layout := Layout{Home: "/home/agent", Workspace: "/home/agent/workspace/repo"}
+	if s := gitIdentityScript(config.Git{}, "a1", layout); s != "" {
+		t.Errorf("nothing configured, got %q", s)
+	}
+
+	g := config.Git{
+		Name:    "agentctl {agent}",
+		Email:   "{agent}@agents.example.com",
+		Trailer: "Co-Authored-By: agentctl/{agent} <agents@example.com>",
+		Signing: config.GitSigning{Format: "ssh", Key: "/keys/agent"},
+	}
+	s := gitIdentityScript(g, "fix-12", layout)
+	for _, want := range []string{
+		"git config --global user.name 'agentctl fix-12'",
+		"git config --global user.email 'fix-12@agents.example.com'",
+		"cp " + containerSigningKey + " '/home/agent/.ssh/agentctl-signing-key'",
+		"ssh-keygen -y -P '' -f '/home/agent/.ssh/agentctl-signing-key'",
+		"git config --global gpg.format 'ssh'",
+		"git config --global commit.gpgsign 'true'",
+		"cd '/home/agent/workspace/repo' && hooks=",
+		"agentctl/fix-12",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("script lacks %q:\n%s", want, s)
+		}
+	}
+
+	g.Signing = config.GitSigning{Format: "gpg", Key: "ABCD1234"}
+	s = gitIdentityScript(g, "fix-12", layout)
+	if !strings.Contains(s, "gpg --batch --list-secret-keys 'ABCD1234'") || !strings.Contains(s, "user.signingkey 'ABCD1234'") {
+		t.Errorf("gpg script:\n%s", s)
+	}
+}
+
+func TestGitSigningMounts(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(key, []byte("key"), 0600)
+	args, err := gitSigningMounts(config.GitSigning{Format: "ssh", Key: key})
+	if err != nil || len(args) != 2 || args[1] != key+":"+containerSigningKey+":ro,z" {
+		t.Errorf("ssh mounts = %v, %v", args, err)
+	}
+	for _, bad := range []config.GitSigning{
+		{Format: "ssh"},
+		{Format: "ssh", Key: "/no/such/key"},
+		{Format: "gpg"},
+		{Format: "gpg", Key: "ABCD", GPGHome: "/no/such/gnupg"},
+		{Format: "x509", Key: "k"},
+	} {
+		if _, err := gitSigningMounts(bad); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+	if args, err := gitSigningMounts(config.GitSigning{}); args != nil || err != nil {
+		t.Errorf("unsigned = %v, %v", args, err)
+	}
+}
+
+func TestTrailerHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	hostGit(t, repo, "init", "-q")
+	// An existing hook keeps running.
+	os.WriteFile(filepath.Join(repo, ".git", "hooks", "prepare-commit-msg"),
+		[]byte("#!/bin/sh\necho 'Refs: TICKET-1' >> \"$1\"\n"), 0755)
+
+	install := func() {
+		cmd := exec.Command("sh", "-c", trailerHookScript("Co-Authored-By: agentctl/fix-12 <agents@example.com>"))
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("install: %v: %s", err, out)
+		}
+	}
+	install()
+	install() // idempotent: must not chain the hook to itself
+	hostGit(t, repo, "commit", "-q", "--allow-empty", "-m", "fix login")
+	msg := hostGit(t, repo, "log", "-1", "--format=%B")
+	if !strings.Contains(msg, "Refs: TICKET-1") || strings.Count(msg, "Co-Authored-By: agentctl/fix-12 <agents@example.com>") != 1 {
+		t.Errorf("commit message:\n%s", msg)
+	}
+}
@@ -0,0 +1,240 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// GuardInterval is how often the guard reads new transcript lines.
+const GuardInterval = 2 * time.Second
+
+// guardMaxFailures is how many reads of the transcript in a row may fail
+// (a busy runtime, say) before the guard gives up. Swapped out in tests.
+var guardMaxFailures = 15
+
+// sessionScanner returns lines appended to any session transcript since the
+// previous poll. Each run-task attempt starts a new session file, so it
+// tracks every file rather than tailing one.
+type sessionScanner struct {
+	name    string
+	glob    string
+	offsets map[string]int64
+}
+
+// newSessionScanner starts reading at the current end of existing transcripts.
+func newSessionScanner(name string) *sessionScanner {
+	s := &sessionScanner{name: name, glob: layoutOf(name).sessionGlob(), offsets: map[string]int64{}}
+	sizes, _ := s.sizes()
+	for f, size := range sizes {
+		s.offsets[f] = size
+	}
+	return s
+}
+
+func (s *sessionScanner) sizes() (map[string]int64, error) {
+	out, err := podmanRetry("exec", containerName(s.name), "sh", "-c",
+		`for f in `+s.glob+`; do [ -f "$f" ] && echo "$(wc -c < "$f") $f"; done; true`)
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for _, line := range strings.Split(string(out), "\n") {
+		size, file, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+			sizes[file] = n
+		}
+	}
+	return sizes, nil
+}
+
+// poll returns complete lines written since the last call.
+func (s *sessionScanner) poll() ([]string, error) {
+	sizes, err := s.sizes()
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for file, size := range sizes {
+		offset := s.offsets[file]
+		if size <= offset {
+			continue
+		}
+		out, err := podman("exec", containerName(s.name), "tail", "-c", fmt.Sprintf("+%d", offset+1), file).Output()
+		if err != nil {
+			continue
+		}
+		// Leave a partially written last line for the next poll.
+		end := strings.LastIndexByte(string(out), '\n')
+		if end < 0 {
+			continue
+		}
+		s.offsets[file] = offset + int64(end) + 1
+		for _, line := range strings.Split(string(out[:end]), "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// checkLine returns the first violation among a transcript line's tool calls.
+func (p *Policy) checkLine(line string) *PolicyViolation {
+	var msg jsonlMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Message == nil {
+		return nil
+	}
+	for _, block := range msg.Message.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		var ti toolInput
+		json.Unmarshal(block.Input, &ti)
+		if v := p.Check(block.Name, ti); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// Guard watches an agent's tool calls and enforces the policy until stop is
+// closed or a kill/pause violation ends the watch, which it returns. Warnings
+// are enforced (recorded) and watching continues. A transcript it can't
+// read is tried again; after guardMaxFailures failures in a row Guard
+// returns an error, and the agent must not carry on unwatched.
+//
+// Enforcement is after the fact: a tool call is in the transcript once the
+// model has issued it, so a fast command may already have run. Guard narrows
+// the window to one poll interval; it is not a sandbox.
+func Guard(name string, p *Policy, stop <-chan struct{}) (*PolicyViolation, error) {
+	scanner := newSessionScanner(name)
+	p = p.forAgent(name)
+	ticker := time.NewTicker(GuardInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-stop:
+			return nil, nil
+		case <-ticker.C:
+		}
+		lines, err := scanner.poll()
+		if err != nil {
+			if failures++; failures > guardMaxFailures {
+				return nil, fmt.Errorf("policy guard could not read %s's session %d times in a row: %w", name, failures, err)
+			}
+			fmt.Printf("⚠️  Policy guard could not read %s's session (%d/%d): %v\n", name, failures, guardMaxFailures, err)
+			continue
+		}
+		failures = 0
+		for _, line := range lines {
+			v := p.checkLine(line)
+			if v == nil {
+				continue
+			}
+			v.Agent, v.Time = name, time.Now()
+			Enforce(v)
+			if v.Action != PolicyWarn {
+				return v, nil
+			}
+		}
+	}
+}
+
+// Enforce records a violation and applies its action: the agent is killed
+// (keeping a history record), paused, or only warned about.
+func Enforce(v *PolicyViolation) {
+	fmt.Printf("🚨 Policy violation by %s — %s\n", v.Agent, v)
+	recordViolation(v)
+
+	var agent *Agent
+	err := updateAgent(v.Agent, func(a *Agent) error {
+		a.Violations = append(a.Violations, *v)
+		if v.Action == PolicyPause {
+			a.Status = "paused"
+		}
+		agent = a
+		return nil
+	})
+	if err == nil {
+		if agent.Repo != "" {
+			coordination.Publish(agent.Repo, coordination.Message{
+				Type:  coordination.MsgViolation,
+				Agent: v.Agent,
+				Data:  map[string]string{"rule": v.Rule, "reason": v.Reason, "action": v.Action},
+			})
+			if v.Action != PolicyWarn {
+				coordination.UpdateAgentState(agent.Repo, v.Agent, "blocked", "")
+			}
+		}
+	}
+
+	switch v.Action {
+	case PolicyKill:
+		if err := Cleanup(v.Agent, "policy_violation", 0, map[string]string{
+			"rule": v.Rule, "reason": v.Reason, "detail": v.Detail,
+		}); err != nil {
+			podman("stop", containerName(v.Agent)).Run()
+		}
+		fmt.Printf("💀 Killed %s\n", v.Agent)
+	case PolicyPause:
+		if out, err := podman("pause", containerName(v.Agent)).CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  could not pause %s: %v %s\n", v.Agent, err, strings.TrimSpace(string(out)))
+			return
+		}
+		fmt.Printf("⏸️  Paused %s (inspect with `agentctl shell`, continue with `podman unpause %s`)\n", v.Agent, containerName(v.Agent))
+	}
+}
+
+func violationsPath() string {
+	return filepath.Join(namespace.Root(), "violations.jsonl")
+}
+
+// recordViolation appends to the namespace's violation log, which outlives
+// the agent's metadata.
+func recordViolation(v *PolicyViolation) error {
+	if err := os.MkdirAll(filepath.Dir(violationsPath()), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(violationsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, _ := json.Marshal(v)
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// ListViolations returns recorded violations, optionally for one agent.
+func ListViolations(name string) ([]PolicyViolation, error) {
+	f, err := os.Open(violationsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []PolicyViolation
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var v PolicyViolation
+		if json.Unmarshal(scanner.Bytes(), &v) == nil && (name == "" || v.Agent == name) {
+			out = append(out, v)
+		}
+	}
+	return out, scanner.Err()
+}
@@ -0,0 +1,121 @@
+package container
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Idle policy actions (config idle.action).
+const (
+	IdleComplete = "complete"
+	IdlePause    = "pause"
+)
+
+// DefaultIdleAfter is how long an agent may be quiet before it is idle.
+const DefaultIdleAfter = 15 * time.Minute
+
+// idlePolicy returns the configured quiet period (0 when idle detection is
+// off) and action.
+func idlePolicy() (time.Duration, string) {
+	after, action := DefaultIdleAfter, IdleComplete
+	cfg, err := config.Load()
+	if err != nil {
+		return after, action
+	}
+	switch cfg.Idle.After {
+	case "":
+	case "off", "0":
+		after = 0
+	default:
+		if d, err := time.ParseDuration(cfg.Idle.After); err == nil && d > 0 {
+			after = d
+		}
+	}
+	if cfg.Idle.Action == IdlePause {
+		action = IdlePause
+	}
+	return after, action
+}
+
+// isHarness reports whether a command line is the model harness itself —
+// claude or opencode, run directly or by node or bun — rather than a wrapper
+// such as run-task that may outlive it.
+func isHarness(cmdline string) bool {
+	fields := strings.Fields(cmdline)
+	if len(fields) == 0 {
+		return false
+	}
+	prog := path.Base(fields[0])
+	if (prog == "node" || prog == "bun") && len(fields) > 1 {
+		prog = path.Base(fields[1])
+	}
+	return prog == "claude" || prog == "opencode"
+}
+
+// idle reports whether a running container's agent has gone quiet: its
+// wrapper still runs, but the harness has exited and nothing has been
+// written for after.
+func (st containerState) idle(after time.Duration, now time.Time) bool {
+	return after > 0 && st.Status == "running" && st.Claude && !st.Harness &&
+		!st.Activity.IsZero() && now.Sub(st.Activity) >= after
+}
+
+// activityScript prints the container's processes, a separator, then the
+// modification time (epoch seconds) of the newest session transcript and
+// log, for shimless agents.
+func activityScript(l Layout) string {
+	files := l.sessionGlob() + " " + shellQuote(l.Path("claude.log")) + " " + shellQuote(l.Path("task.log"))
+	return "ps -eo args 2>/dev/null; echo '--agentctl--'; " +
+		"f=$(ls -t " + files + " 2>/dev/null | head -1); [ -n \"$f\" ] && stat -c %Y \"$f\" 2>/dev/null; true"
+}
+
+// parseActivity reads activityScript's output.
+func parseActivity(out string) containerState {
+	procs, mtime := out, ""
+	if i := strings.LastIndex(out, "\n--agentctl--"); i >= 0 {
+		procs, mtime = out[:i], out[i+len("\n--agentctl--"):]
+	}
+	var st containerState
+	for _, line := range strings.Split(procs, "\n") {
+		// The script's own shell mentions claude.log; skip it.
+		if agentProcess.MatchString(line) && !strings.Contains(line, "--agentctl--") {
+			st.Claude = true
+			st.Harness = st.Harness || isHarness(line)
+		}
+	}
+	if secs, err := strconv.ParseInt(strings.TrimSpace(mtime), 10, 64); err == nil {
+		st.Activity = time.Unix(secs, 0)
+	}
+	return st
+}
+
+// SweepIdle pauses agents the idle policy calls idle, when its action is
+// pause, and returns their names. With the complete action idle agents are
+// already reported completed, so there is nothing to do.
+func SweepIdle() ([]string, error) {
+	after, action := idlePolicy()
+	if after == 0 || action != IdlePause {
+		return nil, nil
+	}
+	agents, err := ListWithState()
+	if err != nil {
+		return nil, err
+	}
+	var paused []string
+	for _, a := range agents {
+		if !a.Idle || a.Paused {
+			continue
+		}
+		if err := Pause(a.Name); err != nil {
+			return paused, err
+		}
+		AddNote(a.Name, fmt.Sprintf("paused: idle for %s", a.IdleFor.Round(time.Minute)))
+		paused = append(paused, a.Name)
+	}
+	return paused, nil
+}
@@ -0,0 +1,101 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsHarness(t *testing.T) {
+	for cmdline, want := range map[string]bool{
+		"claude -p fix the tests":                      true,
+		"/usr/local/bin/claude --resume":               true,
+		"node /usr/lib/node_modules/.bin/opencode run": true,
+		"bash /usr/local/bin/run-task 'ask claude'":    false,
+		"tee -a /home/agent/claude.log":                false,
+		"":                                             false,
+	} {
+		if got := isHarness(cmdline); got != want {
+			t.Errorf("isHarness(%q) = %v", cmdline, got)
+		}
+	}
+}
+
+func TestParseActivity(t *testing.T) {
+	out := "ARGS\nsleep infinity\nbash /usr/local/bin/run-task fix\nsh -c ps -eo args; echo '--agentctl--'; ls -t claude.log\n--agentctl--\n1767225600\n"
+	st := parseActivity(out)
+	if !st.Claude || st.Harness || !st.Activity.Equal(time.Unix(1767225600, 0)) {
+		t.Errorf("state = %+v", st)
+	}
+	if st := parseActivity("sleep infinity\n--agentctl--\n"); st.Claude || !st.Activity.IsZero() {
+		t.Errorf("idle container = %+v", st)
+	}
+}
+
+func TestIdleLifecycle(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	quiet := containerState{Status: "running", Claude: true, Activity: time.Now().Add(-time.Hour)}
+	aws := withState(&Agent{Name: "w"}, quiet)
+	if !aws.Idle || aws.Lifecycle != StateCompleted || aws.IdleFor < 59*time.Minute {
+		t.Errorf("quiet wrapper: %+v", aws)
+	}
+	// The harness still running, or recent activity, keeps it active.
+	busy := quiet
+	busy.Harness = true
+	if aws := withState(&Agent{Name: "w"}, busy); aws.Idle || aws.Lifecycle != StateActive {
+		t.Errorf("harness running: %+v", aws)
+	}
+	recent := quiet
+	recent.Activity = time.Now().Add(-time.Minute)
+	if aws := withState(&Agent{Name: "w"}, recent); aws.Idle || aws.Lifecycle != StateActive {
+		t.Errorf("recent activity: %+v", aws)
+	}
+
+	// With the pause action the agent stays active until it is paused.
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"idle": {"after": "30m", "action": "pause"}}`), 0644)
+	if aws := withState(&Agent{Name: "w"}, quiet); !aws.Idle || aws.Lifecycle != StateActive {
+		t.Errorf("pause policy: %+v", aws)
+	}
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"idle": {"after": "off"}}`), 0644)
+	if aws := withState(&Agent{Name: "w"}, quiet); aws.Idle {
+		t.Errorf("idle detection off: %+v", aws)
+	}
+}
+
+func TestSweepIdle(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"idle": {"after": "10m", "action": "pause"}}`), 0644)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$*" >> `+calls+`
+case "$1" in
+ps) echo '[{"Names": ["sleepy"], "State": "running"}]' ;;
+exec) echo "bash /usr/local/bin/run-task fix"; echo "--agentctl--"; echo `+strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)+` ;;
+esac`)
+	saveAgent(&Agent{Name: "sleepy", Port: 1})
+
+	paused, err := SweepIdle()
+	if err != nil || len(paused) != 1 || paused[0] != "sleepy" {
+		t.Fatalf("SweepIdle = %v, %v", paused, err)
+	}
+	log, _ := os.ReadFile(calls)
+	if !strings.Contains(string(log), "pause sleepy") {
+		t.Errorf("podman calls:\n%s", log)
+	}
+	agent, _ := loadAgent("sleepy")
+	if !agent.Paused || len(agent.Notes) != 1 || !strings.HasPrefix(agent.Notes[0].Text, "paused: idle for 1h") {
+		t.Errorf("agent = %+v", agent)
+	}
+}
@@ -0,0 +1,119 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// IntegrityProblem is one thing wrong with a workspace about to be pushed.
+type IntegrityProblem struct {
+	Check  string // markers, leftovers, debug, build
+	Detail string
+}
+
+func (p IntegrityProblem) String() string {
+	return p.Check + ": " + p.Detail
+}
+
+// IntegrityError is returned by Push when the workspace fails its checks.
+type IntegrityError struct {
+	Problems []IntegrityProblem
+}
+
+func (e *IntegrityError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  - " + p.String()
+	}
+	return fmt.Sprintf("workspace failed %d integrity check(s):\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// buildChecks maps a detected toolchain to its compile-only command.
+var buildChecks = map[string]string{
+	"go":    "go build ./...",
+	"cargo": "cargo check --quiet",
+	"tsc":   "npx --no-install tsc --noEmit",
+}
+
+const detectBuild = `if [ -f go.mod ]; then echo go; elif [ -f Cargo.toml ]; then echo cargo; elif [ -f tsconfig.json ]; then echo tsc; fi`
+
+// CheckIntegrity runs the pre-push sanity checks in the agent's workspace:
+// no conflict markers in tracked files, no .orig/.rej files, no added lines
+// matching the configured debug patterns, and a build that still compiles.
+func CheckIntegrity(name string, cfg config.Integrity) ([]IntegrityProblem, error) {
+	var debug []*regexp.Regexp
+	for _, expr := range cfg.DebugPatterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("integrity: invalid debug pattern %q: %w", expr, err)
+		}
+		debug = append(debug, re)
+	}
+	cd := layoutOf(name).cd()
+	sh := func(script string) string {
+		out, _ := podmanLong("exec", containerName(name), "sh", "-c", cd+script).Output()
+		return string(out)
+	}
+
+	var problems []IntegrityProblem
+	for _, line := range nonEmptyLines(sh(`git grep -nI -E '^(<<<<<<<|>>>>>>>)( |$)' -- . ; true`)) {
+		problems = append(problems, IntegrityProblem{"markers", "conflict marker at " + line})
+	}
+	for _, f := range nonEmptyLines(sh(`git ls-files -co --exclude-standard -- '*.orig' '*.rej'`)) {
+		problems = append(problems, IntegrityProblem{"leftovers", f})
+	}
+	if len(debug) > 0 {
+		diff := sh(`base=$(git merge-base HEAD origin/HEAD 2>/dev/null || git rev-parse HEAD) && git diff -U0 --no-color "$base"`)
+		for _, hit := range debugLines(diff, debug) {
+			problems = append(problems, IntegrityProblem{"debug", hit})
+		}
+	}
+	if !cfg.SkipBuild {
+		build := cfg.Build
+		if build == "" {
+			build = buildChecks[strings.TrimSpace(sh(detectBuild))]
+		}
+		if build != "" {
+			out := sh("{ " + build + "; } 2>&1; echo EXIT_CODE:$?")
+			if !strings.Contains(out, "EXIT_CODE:0") {
+				problems = append(problems, IntegrityProblem{"build", build + " failed:\n" + tailLines(strings.TrimSpace(out), 15)})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// debugLines returns the added lines of a unified diff that match a debug
+// pattern, as "file: line".
+func debugLines(diff string, patterns []*regexp.Regexp) []string {
+	var hits []string
+	file := ""
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+		case strings.HasPrefix(line, "+"):
+			added := strings.TrimPrefix(line, "+")
+			for _, re := range patterns {
+				if re.MatchString(added) {
+					hits = append(hits, fmt.Sprintf("%s: %s", file, truncate(strings.TrimSpace(added), 100)))
+					break
+				}
+			}
+		}
+	}
+	return hits
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fakePodman(t, `case "$*" in
+*"git grep"*) echo 'src/auth.go:12:<<<<<<< HEAD' ;;
+*"git ls-files"*) echo 'src/auth.go.orig' ;;
+*"git diff"*) printf '+++ b/src/auth.go\n+\tlog.Println("DEBUG token", tok)\n+\treturn tok\n' ;;
+*"go.mod"*) echo go ;;
+*"go build"*) echo 'src/auth.go:12: syntax error'; echo EXIT_CODE:1 ;;
+*"git push"*) echo pushed >&2 ;;
+esac`)
+	saveAgent(&Agent{Name: "worker"})
+
+	problems, err := CheckIntegrity("worker", config.Integrity{DebugPatterns: []string{`"DEBUG`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checks []string
+	for _, p := range problems {
+		checks = append(checks, p.Check)
+	}
+	if strings.Join(checks, ",") != "markers,leftovers,debug,build" {
+		t.Fatalf("problems = %v", problems)
+	}
+	if !strings.Contains(problems[2].Detail, "src/auth.go: log.Println") || !strings.Contains(problems[3].Detail, "syntax error") {
+		t.Errorf("problems = %v", problems)
+	}
+
+	if _, err := CheckIntegrity("worker", config.Integrity{DebugPatterns: []string{"("}}); err == nil {
+		t.Error("expected an error for an invalid debug pattern")
+	}
+
+	var ie *IntegrityError
+	if err := Push("worker", true); !errors.As(err, &ie) || len(ie.Problems) != 3 {
+		t.Errorf("Push() = %v, want an IntegrityError with markers, leftovers and build", err)
+	}
+	if err := Push("worker", false); err != nil {
+		t.Errorf("Push() without verify = %v", err)
+	}
+}
+
+func TestDebugLines(t *testing.T) {
+	diff := "diff --git a/app.js b/app.js\n--- a/app.js\n+++ b/app.js\n@@ -1 +1,2 @@\n-console.log('old')\n+console.log('new')\n+render()\n"
+	hits := debugLines(diff, []*regexp.Regexp{regexp.MustCompile(`console\.log\(`)})
+	if len(hits) != 1 || hits[0] != "app.js: console.log('new')" {
+		t.Errorf("debugLines() = %v", hits)
+	}
+}
@@ -0,0 +1,103 @@
+package container
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxIntentLen caps an intent taken from a task: enough to recognise the
+// work a day later, short enough for one line of `list`.
+const maxIntentLen = 160
+
+// IntentFrom summarises a task as an intent: its first non-empty line,
+// truncated.
+func IntentFrom(task string) string {
+	for _, line := range strings.Split(task, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			return truncate(line, maxIntentLen)
+		}
+	}
+	return ""
+}
+
+// RecordIntent sets the agent's intent from text unless it already has one
+// (from spawn --intent, or an earlier run).
+func RecordIntent(name, text string) error {
+	return updateAgent(name, func(agent *Agent) error {
+		if agent.Intent == "" {
+			agent.Intent = IntentFrom(text)
+		}
+		return nil
+	})
+}
+
+// FindResult is one agent, live or in history, matching a Find query.
+type FindResult struct {
+	Name   string
+	Live   bool // a current agent rather than a history record
+	Repo   string
+	Intent string
+	Result string    // history only
+	When   time.Time // created (live) or completed (history)
+	Match  string    // the field that matched, e.g. "intent" or "metadata.pr"
+}
+
+// Find searches current agents and history for keyword, case-insensitively,
+// in names, intents, tasks, repos, branches, notes and history metadata.
+// Live agents come first, then history, each newest first.
+func Find(keyword string) ([]FindResult, error) {
+	kw := strings.ToLower(strings.TrimSpace(keyword))
+	var live, past []FindResult
+	for _, a := range loadAgents() {
+		fields := map[string]string{"name": a.Name, "intent": a.Intent, "task": a.Task, "repo": a.Repo, "branch": a.Branch}
+		addNotes(fields, a.Notes)
+		if match := matchField(fields, kw); match != "" {
+			live = append(live, FindResult{Name: a.Name, Live: true, Repo: a.Repo, Intent: a.Intent, When: a.Created, Match: match})
+		}
+	}
+	records, err := ListHistory()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range records {
+		fields := map[string]string{"name": h.Name, "intent": h.Intent, "repo": h.Repo, "branch": h.Branch, "result": h.Result}
+		for k, v := range h.Metadata {
+			fields["metadata."+k] = v
+		}
+		addNotes(fields, h.Notes)
+		if match := matchField(fields, kw); match != "" {
+			past = append(past, FindResult{Name: h.Name, Repo: h.Repo, Intent: h.Intent, Result: h.Result, When: h.CompletedAt, Match: match})
+		}
+	}
+	for _, rs := range [][]FindResult{live, past} {
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].When.After(rs[j].When) })
+	}
+	return append(live, past...), nil
+}
+
+func addNotes(fields map[string]string, notes []Note) {
+	var texts []string
+	for _, n := range notes {
+		texts = append(texts, n.Text)
+	}
+	fields["notes"] = strings.Join(texts, "\n")
+}
+
+// matchField returns the first field (intent first, then alphabetically)
+// containing kw, or "".
+func matchField(fields map[string]string, kw string) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "intent" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range append([]string{"intent"}, keys...) {
+		if strings.Contains(strings.ToLower(fields[k]), kw) {
+			return k
+		}
+	}
+	return ""
+}
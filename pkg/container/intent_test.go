@@ -0,0 +1,64 @@
+package container
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntentFrom(t *testing.T) {
+	if got := IntentFrom("\n  Fix the   login redirect\n\nDetails follow"); got != "Fix the login redirect" {
+		t.Errorf("IntentFrom = %q", got)
+	}
+	if got := IntentFrom(strings.Repeat("x", 300)); len(got) != maxIntentLen+3 {
+		t.Errorf("long tasks should be truncated, got %d chars", len(got))
+	}
+	if got := dispatchIntent("42", "", `{"title": "Crash on empty cart", "body": "..."}`, ""); got != "issue #42 Crash on empty cart" {
+		t.Errorf("dispatchIntent = %q", got)
+	}
+}
+
+func TestRecordIntentAndFind(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	saveAgent(&Agent{Name: "fix-3", Repo: "https://github.com/org/shop", Created: time.Now()})
+	saveAgent(&Agent{Name: "fix-4", Intent: "Upgrade the payment SDK", Created: time.Now()})
+	RecordIntent("fix-3", "Fix checkout rounding\nUse decimal math")
+	RecordIntent("fix-4", "something else")
+
+	if a, _ := loadAgent("fix-3"); a.Intent != "Fix checkout rounding" {
+		t.Errorf("intent = %q", a.Intent)
+	}
+	if a, _ := loadAgent("fix-4"); a.Intent != "Upgrade the payment SDK" {
+		t.Errorf("an existing intent should be kept, got %q", a.Intent)
+	}
+
+	SaveHistory(&AgentHistory{Name: "fix-1", Intent: "Checkout button misaligned", Result: "success",
+		CompletedAt: time.Now().Add(-time.Hour), Metadata: map[string]string{"pr": "https://github.com/org/shop/pull/7"}})
+	SaveHistory(&AgentHistory{Name: "fix-2", Result: "failed", CompletedAt: time.Now(),
+		Metadata: map[string]string{"pr": "https://github.com/org/shop/pull/9"}})
+
+	results, err := Find("CHECKOUT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Name+":"+r.Match)
+	}
+	if strings.Join(got, " ") != "fix-3:intent fix-1:intent" {
+		t.Errorf("Find(checkout) = %v", got)
+	}
+	if results[0].Live != true || results[1].Result != "success" {
+		t.Errorf("results = %+v", results)
+	}
+
+	results, _ = Find("pull/9")
+	if len(results) != 1 || results[0].Name != "fix-2" || results[0].Match != "metadata.pr" {
+		t.Errorf("Find(pull/9) = %+v", results)
+	}
+}
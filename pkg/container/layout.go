@@ -0,0 +1,182 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Default in-container paths of the agent-devbox image.
+const (
+	DefaultHome      = "/home/agent"
+	DefaultWorkspace = DefaultHome + "/workspace/repo"
+)
+
+// Image labels that describe a non-default layout.
+const (
+	labelHome      = "agentctl.home"
+	labelWorkspace = "agentctl.workspace"
+	labelUserNS    = "agentctl.userns"
+)
+
+// Layout is where an agent's files live inside its container and which user
+// runs it. Images laid out differently from agent-devbox describe theirs in a
+// config profile or with agentctl.home / agentctl.workspace image labels.
+type Layout struct {
+	Home      string `json:"home,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	User      string `json:"user,omitempty"`
+	// UserNS is the podman --userns mode, which maps the host user to the
+	// container user so bind mounts stay writable.
+	UserNS string `json:"userns,omitempty"`
+}
+
+// withDefaults fills unset paths: the workspace defaults to
+// <home>/workspace/repo.
+func (l Layout) withDefaults() Layout {
+	if l.Home == "" {
+		l.Home = DefaultHome
+	}
+	if l.Workspace == "" {
+		l.Workspace = path.Join(l.Home, "workspace", "repo")
+	}
+	return l
+}
+
+// Path returns a path under the agent user's home directory.
+func (l Layout) Path(rel string) string {
+	return path.Join(l.withDefaults().Home, rel)
+}
+
+// cd returns a shell prefix that enters the workspace.
+func (l Layout) cd() string {
+	return "cd " + shellQuote(l.withDefaults().Workspace) + " && "
+}
+
+// sessionGlob matches every Claude session transcript in the container.
+func (l Layout) sessionGlob() string {
+	return l.Path(".claude/projects") + "/*/*.jsonl"
+}
+
+// layoutOf returns a saved agent's layout, or the default one.
+func layoutOf(name string) Layout {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return Layout{}.withDefaults()
+	}
+	return agent.Layout.withDefaults()
+}
+
+// resolveImage returns the image an agent runs: the one asked for, else the
+// named profile's, else DefaultImage.
+func resolveImage(cfg *config.Config, profile, image string) (string, error) {
+	if profile != "" {
+		p, ok := cfg.Profiles[profile]
+		if !ok {
+			return "", fmt.Errorf("no profile named %q", profile)
+		}
+		if image == "" {
+			image = p.Image
+		}
+	}
+	if image == "" {
+		image = DefaultImage
+	}
+	return image, nil
+}
+
+// resolveLayout works out an image's layout: the named profile (or the
+// profile for the image) first, then what the image says about itself.
+func resolveLayout(cfg *config.Config, profile, image string) (Layout, string, error) {
+	image, err := resolveImage(cfg, profile, image)
+	if err != nil {
+		return Layout{}, "", err
+	}
+	p := cfg.Profiles[profile]
+	if profile == "" {
+		for _, candidate := range cfg.Profiles {
+			if candidate.Image == image {
+				p = candidate
+				break
+			}
+		}
+	}
+
+	l := Layout{Home: p.Home, Workspace: p.Workspace, User: p.User, UserNS: p.UserNS}
+	if l.Home == "" || l.Workspace == "" || l.User == "" || l.UserNS == "" {
+		found := discoverLayout(image, l.User)
+		if l.Home == "" {
+			l.Home = found.Home
+		}
+		if l.Workspace == "" {
+			l.Workspace = found.Workspace
+		}
+		if l.User == "" {
+			l.User = found.User
+		}
+		if l.UserNS == "" {
+			l.UserNS = found.UserNS
+		}
+	}
+	return l.withDefaults(), image, nil
+}
+
+// withWorkspace applies a workspace chosen for this agent (override) or its
+// repo (config workspaces) on top of the image's layout.
+func (l Layout) withWorkspace(cfg *config.Config, repo, override string) (Layout, error) {
+	ws := override
+	if ws == "" {
+		ws = cfg.WorkspaceFor(repo)
+	}
+	if ws == "" {
+		return l, nil
+	}
+	if !path.IsAbs(ws) {
+		return l, fmt.Errorf("workspace %q must be an absolute path", ws)
+	}
+	l.Workspace = path.Clean(ws)
+	return l, nil
+}
+
+// discoverLayout reads an image's labels, HOME and user. Any failure (e.g.
+// the image isn't pulled yet) leaves the defaults in place.
+func discoverLayout(image, user string) Layout {
+	out, err := podmanRetry("image", "inspect", "--format", "json", image)
+	if err != nil {
+		return Layout{}
+	}
+	var images []struct {
+		Config struct {
+			User   string            `json:"User"`
+			Env    []string          `json:"Env"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if json.Unmarshal(out, &images) != nil || len(images) == 0 {
+		return Layout{}
+	}
+	c := images[0].Config
+	l := Layout{Home: c.Labels[labelHome], Workspace: c.Labels[labelWorkspace], UserNS: c.Labels[labelUserNS], User: c.User}
+	if l.Home == "" {
+		for _, kv := range c.Env {
+			if home, ok := strings.CutPrefix(kv, "HOME="); ok {
+				l.Home = home
+			}
+		}
+	}
+	if user == "" {
+		user = c.User
+	}
+	if l.Home == "" && (user == "root" || user == "0" || strings.HasPrefix(user, "0:")) {
+		l.Home = "/root"
+	}
+	return l
+}
+
+// shellQuote quotes s for sh -c.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
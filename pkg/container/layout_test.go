@@ -0,0 +1,83 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestLayoutDefaults(t *testing.T) {
+	l := Layout{}.withDefaults()
+	if l.Workspace != DefaultWorkspace || l.Path(".claude.json") != "/home/agent/.claude.json" {
+		t.Errorf("default layout = %+v", l)
+	}
+	l = Layout{Home: "/home/dev"}.withDefaults()
+	if l.Workspace != "/home/dev/workspace/repo" {
+		t.Errorf("workspace = %q, want it under the home", l.Workspace)
+	}
+	if got := (Layout{Workspace: "/srv/it's"}).cd(); got != `cd '/srv/it'\''s' && ` {
+		t.Errorf("cd() = %q", got)
+	}
+}
+
+func TestResolveLayout(t *testing.T) {
+	fakePodman(t, `case "$1 $2" in
+"image inspect")
+  case "$5" in
+  labelled) echo '[{"Config":{"User":"dev","Env":["HOME=/home/dev"],"Labels":{"agentctl.workspace":"/srv/repo","agentctl.userns":"keep-id:uid=1000,gid=1000"}}}]' ;;
+  rooty) echo '[{"Config":{"User":"root","Env":["PATH=/usr/bin"]}}]' ;;
+  *) exit 125 ;;
+  esac ;;
+esac`)
+
+	cfg := &config.Config{Profiles: map[string]config.Profile{
+		"python": {Image: "py-agent", Home: "/home/py", User: "1000:1000"},
+	}}
+	tests := []struct {
+		name, profile, image string
+		wantImage            string
+		want                 Layout
+	}{
+		{"default image", "", "", DefaultImage, Layout{Home: "/home/agent", Workspace: DefaultWorkspace}},
+		{"profile by name", "python", "", "py-agent", Layout{Home: "/home/py", Workspace: "/home/py/workspace/repo", User: "1000:1000"}},
+		{"profile by image", "", "py-agent", "py-agent", Layout{Home: "/home/py", Workspace: "/home/py/workspace/repo", User: "1000:1000"}},
+		{"image labels and HOME", "", "labelled", "labelled", Layout{Home: "/home/dev", Workspace: "/srv/repo", User: "dev", UserNS: "keep-id:uid=1000,gid=1000"}},
+		{"root user", "", "rooty", "rooty", Layout{Home: "/root", Workspace: "/root/workspace/repo", User: "root"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, image, err := resolveLayout(cfg, tt.profile, tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if image != tt.wantImage || l != tt.want {
+				t.Errorf("resolveLayout() = %+v, %q; want %+v, %q", l, image, tt.want, tt.wantImage)
+			}
+		})
+	}
+
+	if _, _, err := resolveLayout(cfg, "missing", ""); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestLayoutWithWorkspace(t *testing.T) {
+	cfg := &config.Config{Workspaces: map[string]string{"org/mono": "/srv/mono/"}}
+	base := Layout{Home: "/home/agent", Workspace: DefaultWorkspace}
+	tests := []struct {
+		repo, override, want string
+	}{
+		{"https://github.com/org/api.git", "", DefaultWorkspace},
+		{"https://github.com/org/mono.git", "", "/srv/mono"},
+		{"org/mono", "/work/here", "/work/here"},
+	}
+	for _, tt := range tests {
+		l, err := base.withWorkspace(cfg, tt.repo, tt.override)
+		if err != nil || l.Workspace != tt.want || l.Home != base.Home {
+			t.Errorf("withWorkspace(%q, %q) = %+v, %v; want workspace %q", tt.repo, tt.override, l, err, tt.want)
+		}
+	}
+	if _, err := base.withWorkspace(cfg, "org/api", "relative/dir"); err == nil {
+		t.Error("expected an error for a relative workspace")
+	}
+}
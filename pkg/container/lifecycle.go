@@ -1,13 +1,17 @@
 package container
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
 )
 
 // DefaultGracePeriod is how long a completed agent container stays before auto-cleanup.
@@ -25,68 +29,21 @@ type AgentHistory struct {
 	Result      string            `json:"result"` // "success", "failed", "killed"
 	Attempts    int               `json:"attempts,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"` // PR URL, commit SHA, etc.
+	Notes       []Note            `json:"notes,omitempty"`
+	// Instructions are the `tell` messages the agent was given.
+	Instructions []Instruction `json:"instructions,omitempty"`
+
+	// What `agentctl stats` groups and costs runs by.
+	Task     string      `json:"task,omitempty"`
+	Template string      `json:"template,omitempty"`
+	Backend  string      `json:"backend,omitempty"`
+	Tokens   *TokenUsage `json:"tokens,omitempty"`
 }
 
-// historyDir returns the path to the agent history directory.
+// historyDir holds patches saved by --output-patch, and held history
+// records as JSON before the store.
 func historyDir() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".agentctl", "history")
-}
-
-func historyPath(name string) string {
-	return filepath.Join(historyDir(), name+".json")
-}
-
-// SaveHistory persists an agent history record.
-func SaveHistory(h *AgentHistory) error {
-	if err := os.MkdirAll(historyDir(), 0755); err != nil {
-		return fmt.Errorf("failed to create history dir: %w", err)
-	}
-	data, err := json.MarshalIndent(h, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal history: %w", err)
-	}
-	return os.WriteFile(historyPath(h.Name), data, 0644)
-}
-
-// LoadHistory loads a single agent history record.
-func LoadHistory(name string) (*AgentHistory, error) {
-	data, err := os.ReadFile(historyPath(name))
-	if err != nil {
-		return nil, fmt.Errorf("history not found: %s", name)
-	}
-	var h AgentHistory
-	if err := json.Unmarshal(data, &h); err != nil {
-		return nil, fmt.Errorf("failed to parse history: %w", err)
-	}
-	return &h, nil
-}
-
-// ListHistory returns all agent history records.
-func ListHistory() ([]*AgentHistory, error) {
-	entries, err := os.ReadDir(historyDir())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	var records []*AgentHistory
-	for _, e := range entries {
-		if !strings.HasSuffix(e.Name(), ".json") {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(historyDir(), e.Name()))
-		if err != nil {
-			continue
-		}
-		var h AgentHistory
-		if err := json.Unmarshal(data, &h); err != nil {
-			continue
-		}
-		records = append(records, &h)
-	}
-	return records, nil
+	return filepath.Join(namespace.Root(), "history")
 }
 
 // AgentLifecycleState categorizes an agent's current lifecycle phase.
@@ -95,6 +52,7 @@ type AgentLifecycleState string
 const (
 	StateActive    AgentLifecycleState = "active"    // Claude is running, work in progress
 	StateCompleted AgentLifecycleState = "completed" // Task done, awaiting cleanup
+	StatePaused    AgentLifecycleState = "paused"    // Frozen by agentctl pause; never cleaned up
 	StateExited    AgentLifecycleState = "exited"    // Container exited (may be stale)
 	StateStopped   AgentLifecycleState = "stopped"   // Container not found
 )
@@ -105,65 +63,79 @@ type AgentWithState struct {
 	Lifecycle   AgentLifecycleState `json:"lifecycle"`
 	ContainerUp bool                `json:"container_up"`
 	Age         time.Duration       `json:"-"`
+	// Idle is set for an agent the idle policy considers quiet, IdleFor
+	// since when.
+	Idle    bool          `json:"idle,omitempty"`
+	IdleFor time.Duration `json:"-"`
 }
 
-// ListWithState returns all agents enriched with lifecycle state.
+// ListWithState returns all agents enriched with lifecycle state, from one
+// `podman ps` call plus parallel Claude probes of running containers.
 func ListWithState() ([]*AgentWithState, error) {
-	entries, _ := os.ReadDir(agentDir())
-	var agents []*AgentWithState
-	for _, e := range entries {
-		if !strings.HasSuffix(e.Name(), ".json") {
-			continue
-		}
-		data, _ := os.ReadFile(filepath.Join(agentDir(), e.Name()))
-		var agent Agent
-		if err := json.Unmarshal(data, &agent); err != nil {
-			continue
-		}
-
-		aws := &AgentWithState{
-			Agent: &agent,
-			Age:   time.Since(agent.Created),
-		}
-
-		// Get container status from podman
-		out, _ := exec.Command("podman", "inspect", "-f", "{{.State.Status}}", agent.Name).Output()
-		containerStatus := strings.TrimSpace(string(out))
-
-		switch containerStatus {
-		case "running":
-			aws.ContainerUp = true
-			// Check if Claude is still working
-			psOut, _ := exec.Command("podman", "exec", agent.Name, "sh", "-c",
-				"ps aux 2>/dev/null | grep -v grep | grep claude || true").Output()
-			if len(strings.TrimSpace(string(psOut))) > 0 {
-				aws.Lifecycle = StateActive
-			} else {
-				aws.Lifecycle = StateCompleted
-			}
-		case "exited":
-			aws.ContainerUp = false
-			aws.Lifecycle = StateExited
-		default:
-			aws.ContainerUp = false
-			aws.Lifecycle = StateStopped
-		}
-
-		agent.Status = containerStatus
-		if agent.Status == "" {
-			agent.Status = "stopped"
-		}
+	agents := loadAgents()
+	states, err := containerStates(agents)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*AgentWithState, 0, len(agents))
+	for _, a := range agents {
+		out = append(out, withState(a, states[a.Name]))
+	}
+	return out, nil
+}
 
-		agents = append(agents, aws)
+// agentState inspects a single agent's container to determine its lifecycle state.
+func agentState(agent *Agent) (*AgentWithState, error) {
+	out, err := podmanRetry("inspect", "-f", "{{.State.Status}}", containerName(agent.Name))
+	if errors.Is(err, ErrRuntimeUnresponsive) {
+		return nil, err
+	}
+	st := containerState{Status: strings.TrimSpace(string(out))}
+	if st.Status == "running" {
+		st.Claude = claudeRunning(agent.Name)
 	}
-	return agents, nil
+	return withState(agent, st), nil
 }
 
 // Cleanup stops and removes a single agent container, preserving history.
 func Cleanup(name string, result string, attempts int, metadata map[string]string) error {
 	agent, err := loadAgent(name)
 	if err != nil {
-		return fmt.Errorf("agent not found: %s", name)
+		return fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+
+	// Keep the agent's summary with its history.
+	if agent.Summary != "" && metadata["summary"] == "" {
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata["summary"] = agent.Summary
+	}
+	if agent.Issue != 0 && metadata["issue"] == "" {
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata["issue"] = strconv.Itoa(agent.Issue)
+	}
+
+	// The run's own record knows how it went; keep that over what cleanup
+	// can tell from here.
+	prev, _ := LoadHistory(name)
+	if prev != nil && prev.Created.Before(agent.Created) {
+		prev = nil
+	}
+	if prev != nil {
+		if attempts == 0 {
+			attempts = prev.Attempts
+		}
+		for k, v := range prev.Metadata {
+			if _, set := metadata[k]; !set {
+				if metadata == nil {
+					metadata = map[string]string{}
+				}
+				metadata[k] = v
+			}
+		}
 	}
 
 	// Save history before removing
@@ -178,8 +150,21 @@ func Cleanup(name string, result string, attempts int, metadata map[string]strin
 		Result:      result,
 		Attempts:    attempts,
 		Metadata:    metadata,
+		Notes:       agent.Notes,
+
+		Instructions: agent.Instructions,
+
+		Task:     agent.Task,
+		Template: agent.Template,
+		Backend:  agent.Model,
 	}
-	if err := SaveHistory(h); err != nil {
+	if prev != nil {
+		if !prev.CompletedAt.IsZero() {
+			h.CompletedAt = prev.CompletedAt
+		}
+		h.Tokens = prev.Tokens
+	}
+	if err := archiveAgent(h); err != nil {
 		return fmt.Errorf("failed to save history: %w", err)
 	}
 
@@ -189,15 +174,33 @@ func Cleanup(name string, result string, attempts int, metadata map[string]strin
 	}
 
 	// Stop and remove container
-	exec.Command("podman", "stop", name).Run()
-	exec.Command("podman", "rm", name).Run()
+	podman("stop", containerName(name)).Run()
+	podman("rm", containerName(name)).Run()
+	if len(agent.Egress) > 0 {
+		removeEgress(name)
+	}
+	leaveBus(agent, result)
 
-	// Remove agent metadata file
-	os.Remove(agentMetaPath(name))
+	removeCheckpoint(name)
+	removeInstructions(name)
 
 	return nil
 }
 
+// leaveBus takes a removed agent off its repo's coordination bus, so its
+// claims and state entry don't block other agents after it is gone. It
+// returns the files it released.
+func leaveBus(agent *Agent, reason string) []string {
+	if agent.Repo == "" {
+		return nil
+	}
+	released, err := coordination.RemoveAgent(agent.Repo, agent.Name, reason)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not remove %s from the coordination bus: %v\n", agent.Name, err)
+	}
+	return released
+}
+
 // captureIntentKnowledge feeds agent intent and result into the know CLI for post-mortem tracking.
 func captureIntentKnowledge(h *AgentHistory) {
 	title := fmt.Sprintf("Agent %s: %s", h.Name, h.Result)
@@ -228,11 +231,13 @@ func captureIntentKnowledge(h *AgentHistory) {
 }
 
 // Prune removes all exited and stopped agent containers, preserving history.
-func Prune() ([]string, error) {
+// A repo limits it to that repo's agents.
+func Prune(repo string) ([]string, error) {
 	agents, err := ListWithState()
 	if err != nil {
 		return nil, err
 	}
+	agents = AgentsOnRepo(agents, repo)
 
 	var pruned []string
 	for _, a := range agents {
@@ -247,12 +252,14 @@ func Prune() ([]string, error) {
 	return pruned, nil
 }
 
-// CleanupCompleted removes completed agents that have exceeded the grace period.
-func CleanupCompleted(gracePeriod time.Duration) ([]string, error) {
+// CleanupCompleted removes completed agents that have exceeded the grace
+// period; a repo limits it to that repo's agents.
+func CleanupCompleted(gracePeriod time.Duration, repo string) ([]string, error) {
 	agents, err := ListWithState()
 	if err != nil {
 		return nil, err
 	}
+	agents = AgentsOnRepo(agents, repo)
 
 	var cleaned []string
 	for _, a := range agents {
@@ -267,12 +274,14 @@ func CleanupCompleted(gracePeriod time.Duration) ([]string, error) {
 	return cleaned, nil
 }
 
-// CleanupStale removes containers that have been exited for longer than the grace period.
-func CleanupStale(gracePeriod time.Duration) ([]string, error) {
+// CleanupStale removes containers that have been exited for longer than the
+// grace period; a repo limits it to that repo's agents.
+func CleanupStale(gracePeriod time.Duration, repo string) ([]string, error) {
 	agents, err := ListWithState()
 	if err != nil {
 		return nil, err
 	}
+	agents = AgentsOnRepo(agents, repo)
 
 	var cleaned []string
 	for _, a := range agents {
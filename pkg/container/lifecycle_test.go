@@ -3,6 +3,7 @@ package container
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -154,27 +155,27 @@ func TestSaveHistoryWithNilMetadata(t *testing.T) {
 	}
 }
 
-func TestHistoryDirCreation(t *testing.T) {
+func TestStoreCreation(t *testing.T) {
 	tmpHome := t.TempDir()
 	origHome := os.Getenv("HOME")
 	os.Setenv("HOME", tmpHome)
 	defer os.Setenv("HOME", origHome)
 
-	// History dir shouldn't exist yet
-	hDir := filepath.Join(tmpHome, ".agentctl", "history")
-	if _, err := os.Stat(hDir); !os.IsNotExist(err) {
-		t.Fatal("history dir should not exist before SaveHistory")
+	// The store shouldn't exist yet
+	store := filepath.Join(tmpHome, ".agentctl", "agentctl.db")
+	if _, err := os.Stat(store); !os.IsNotExist(err) {
+		t.Fatal("store should not exist before SaveHistory")
 	}
 
 	SaveHistory(&AgentHistory{Name: "test"})
 
-	// Now it should exist
-	info, err := os.Stat(hDir)
+	// Now it should exist, readable by the owner only
+	info, err := os.Stat(store)
 	if err != nil {
-		t.Fatalf("history dir should exist after SaveHistory: %v", err)
+		t.Fatalf("store should exist after SaveHistory: %v", err)
 	}
-	if !info.IsDir() {
-		t.Error("history path should be a directory")
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("store mode = %v, want 0600", info.Mode().Perm())
 	}
 }
 
@@ -260,3 +261,59 @@ func TestDefaultGracePeriod(t *testing.T) {
 		t.Errorf("DefaultGracePeriod = %v, want 1h", DefaultGracePeriod)
 	}
 }
+
+func TestListWithStateBatched(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$1" >> `+calls+`
+case "$1" in
+ps) echo '[{"Names":["busy"],"State":"running"},{"Names":["idle"],"State":"running"},{"Names":["dead"],"State":"exited"}]' ;;
+exec) [ "$2" = busy ] && echo "agent 1 claude" ;;
+esac
+true`)
+	for _, name := range []string{"busy", "idle", "dead", "gone"} {
+		saveAgent(&Agent{Name: name})
+	}
+
+	agents, err := ListWithState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]AgentLifecycleState{"busy": StateActive, "idle": StateCompleted, "dead": StateExited, "gone": StateStopped}
+	for _, a := range agents {
+		if a.Lifecycle != want[a.Name] {
+			t.Errorf("%s: lifecycle = %s, want %s", a.Name, a.Lifecycle, want[a.Name])
+		}
+	}
+	data, _ := os.ReadFile(calls)
+	if got := strings.Fields(string(data)); strings.Count(strings.Join(got, " "), "ps") != 1 || len(got) != 3 {
+		t.Errorf("podman calls = %v, want one ps and an exec per running container", got)
+	}
+
+	// The cached variant reuses a fresh snapshot without calling podman.
+	if _, err := ListWithStateCached(); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(calls)
+	cached, err := ListWithStateCached()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(calls); err == nil {
+		t.Error("ListWithStateCached() called podman with a fresh cache")
+	}
+	if len(cached) != 4 {
+		t.Errorf("cached list = %d agents, want 4", len(cached))
+	}
+
+	// A new agent isn't in the snapshot, so it forces a refresh.
+	saveAgent(&Agent{Name: "new"})
+	ListWithStateCached()
+	if _, err := os.Stat(calls); err != nil {
+		t.Error("a new agent should refresh the cache")
+	}
+}
@@ -0,0 +1,184 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// LogSources maps the sources `agentctl logs --source` accepts to the log
+// files in the agent's home. Claude's output is also read from task.log,
+// where dispatched agents write it; hooks.log is written by the image's
+// hooks, if it has any.
+var LogSources = map[string][]string{
+	"claude": {"claude.log", "task.log"},
+	"setup":  {"setup.log"},
+	"tests":  {"tests.log"},
+	"hooks":  {"hooks.log"},
+}
+
+// LogSourceNames lists LogSources' keys, sorted.
+func LogSourceNames() []string {
+	var names []string
+	for name := range LogSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// logMarker starts each section agentctl appends to a log, followed by the
+// time and what wrote it; --since selects sections by it.
+const logMarker = "### agentctl "
+
+// LogOptions selects what Logs prints.
+type LogOptions struct {
+	Source string        // a LogSources key (default claude)
+	Tail   int           // only the last N lines (0 for all)
+	Since  time.Duration // only sections started this recently (0 for all)
+	Follow bool          // keep printing as the log grows
+}
+
+// logRotation returns the configured rotation size and number of rotated
+// files kept (logs.max_size, default 10M; logs.keep, default 3).
+func logRotation() (int64, int) {
+	maxSize, keep := int64(10<<20), 3
+	if cfg, err := config.Load(); err == nil {
+		if n, err := ParseSize(cfg.Logs.MaxSize); err == nil && n > 0 {
+			maxSize = n
+		}
+		if cfg.Logs.Keep > 0 {
+			keep = cfg.Logs.Keep
+		}
+	}
+	return maxSize, keep
+}
+
+// logAppend returns shell that rotates the log at path once it is past the
+// size limit (path → path.1 → path.2 ..., dropping the oldest) and starts a
+// new section in it. Run it before appending output to the log.
+func logAppend(path, label string) string {
+	maxSize, keep := logRotation()
+	var b strings.Builder
+	fmt.Fprintf(&b, "if [ -f %s ] && [ \"$(wc -c < %s)\" -gt %d ]; then ", shellQuote(path), shellQuote(path), maxSize)
+	for i := keep - 1; i >= 1; i-- {
+		fmt.Fprintf(&b, "mv -f %s %s 2>/dev/null; ", shellQuote(rotated(path, i)), shellQuote(rotated(path, i+1)))
+	}
+	fmt.Fprintf(&b, "mv -f %s %s; fi; ", shellQuote(path), shellQuote(rotated(path, 1)))
+	fmt.Fprintf(&b, "echo %s >> %s; ", shellQuote(logMarker+time.Now().UTC().Format(time.RFC3339)+" "+label), shellQuote(path))
+	return b.String()
+}
+
+func rotated(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// logFiles returns a source's files, rotated ones first, oldest to newest.
+func logFiles(layout Layout, source string) ([]string, error) {
+	if source == "" {
+		source = "claude"
+	}
+	names, ok := LogSources[source]
+	if !ok {
+		return nil, fmt.Errorf("unknown log source %q (use %s)", source, strings.Join(LogSourceNames(), ", "))
+	}
+	_, keep := logRotation()
+	var files []string
+	for _, name := range names {
+		path := layout.Path(name)
+		for i := keep; i >= 1; i-- {
+			files = append(files, rotated(path, i))
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// Logs prints one of the agent's logs, rotated files included.
+func Logs(name string, opts LogOptions) error {
+	if _, err := loadAgent(name); err != nil {
+		return err
+	}
+	files, err := logFiles(layoutOf(name), opts.Source)
+	if err != nil {
+		return err
+	}
+	if opts.Follow {
+		return followLog(name, files, opts.Tail)
+	}
+	var quoted []string
+	for _, f := range files {
+		quoted = append(quoted, shellQuote(f))
+	}
+	out, err := podman("exec", containerName(name), "sh", "-c", "cat "+strings.Join(quoted, " ")+" 2>/dev/null; true").Output()
+	if err != nil {
+		return err
+	}
+	log := filterLog(string(out), opts.Since, opts.Tail, time.Now())
+	if log == "" {
+		source := opts.Source
+		if source == "" {
+			source = "claude"
+		}
+		fmt.Fprintf(os.Stderr, "No %s log output\n", source)
+		return nil
+	}
+	fmt.Println(log)
+	return nil
+}
+
+// followLog streams the newest existing file of a source with tail -F, which
+// keeps following across rotations.
+func followLog(name string, files []string, tail int) error {
+	if tail <= 0 {
+		tail = 10
+	}
+	target := files[len(files)-1]
+	for i := len(files) - 1; i >= 0; i-- {
+		if podman("exec", containerName(name), "test", "-e", files[i]).Run() == nil {
+			target = files[i]
+			break
+		}
+	}
+	cmd := exec.Command("podman", "exec", containerName(name), "tail", "-n", strconv.Itoa(tail), "-F", target)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// filterLog keeps the sections of log started within since of now (0 keeps
+// everything), then the last tail lines (0 keeps all).
+func filterLog(log string, since time.Duration, tail int, now time.Time) string {
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	if since > 0 {
+		start := len(lines)
+		for i, line := range lines {
+			if at, ok := markerTime(line); ok && !at.Before(now.Add(-since)) {
+				start = i
+				break
+			}
+		}
+		lines = lines[start:]
+	}
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markerTime returns when a section marker line was written.
+func markerTime(line string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(line, logMarker)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, _, _ := strings.Cut(rest, " ")
+	at, err := time.Parse(time.RFC3339, stamp)
+	return at, err == nil
+}
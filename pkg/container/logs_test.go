@@ -0,0 +1,87 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogAppendRotates(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"logs": {"max_size": "16", "keep": 2}}`), 0644)
+
+	log := filepath.Join(t.TempDir(), "claude.log")
+	for _, out := range []string{"first run output", "second run output", "third run output", "fourth"} {
+		if err := exec.Command("sh", "-c", logAppend(log, "run-task")+"echo '"+out+"' >> "+shellQuote(log)).Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read := func(path string) string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+	if got := read(log); !strings.HasPrefix(got, logMarker) || !strings.HasSuffix(got, " run-task\nfourth\n") {
+		t.Errorf("current log = %q", got)
+	}
+	if got := read(log + ".1"); !strings.Contains(got, "third run output") {
+		t.Errorf("claude.log.1 = %q", got)
+	}
+	if got := read(log + ".2"); !strings.Contains(got, "second run output") {
+		t.Errorf("claude.log.2 = %q", got)
+	}
+	if _, err := os.Stat(log + ".3"); err == nil {
+		t.Error("only 2 rotated files should be kept")
+	}
+}
+
+func TestFilterLog(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	log := strings.Join([]string{
+		"legacy line",
+		logMarker + "2026-03-01T09:00:00Z setup: npm ci",
+		"old output",
+		logMarker + "2026-03-01T11:30:00Z run-task",
+		"recent 1",
+		"recent 2",
+	}, "\n") + "\n"
+
+	if got := filterLog(log, 0, 0, now); got != strings.TrimSuffix(log, "\n") {
+		t.Errorf("no filter changed the log: %q", got)
+	}
+	if got := filterLog(log, time.Hour, 0, now); !strings.HasPrefix(got, logMarker+"2026-03-01T11:30:00Z") || strings.Contains(got, "old output") {
+		t.Errorf("--since 1h = %q", got)
+	}
+	if got := filterLog(log, 10*time.Minute, 0, now); got != "" {
+		t.Errorf("nothing was written in the last 10m, got %q", got)
+	}
+	if got := filterLog(log, 0, 2, now); got != "recent 1\nrecent 2" {
+		t.Errorf("--tail 2 = %q", got)
+	}
+}
+
+func TestLogFiles(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	files, err := logFiles(Layout{Home: "/home/agent"}, "setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/home/agent/setup.log.3 /home/agent/setup.log.2 /home/agent/setup.log.1 /home/agent/setup.log"
+	if strings.Join(files, " ") != want {
+		t.Errorf("files = %v", files)
+	}
+	if _, err := logFiles(Layout{}, "build"); err == nil || !strings.Contains(err.Error(), "claude, hooks, setup, tests") {
+		t.Errorf("unknown source error = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package container
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// IsPattern reports whether s is a glob ("issue-12*", "w?", "w[1-3]")
+// rather than an agent name.
+func IsPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// MatchAgents resolves agent names and glob patterns to the agents they
+// select, sorted and without duplicates. A name that isn't an agent, or a
+// pattern that matches none, is an error, so a typo can't silently shrink
+// a bulk operation.
+func MatchAgents(patterns []string) ([]string, error) {
+	var all []string
+	for _, a := range loadAgents() {
+		all = append(all, a.Name)
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", p, err)
+		}
+		matched := false
+		for _, name := range all {
+			if ok, _ := path.Match(p, name); ok {
+				matched = true
+				if !seen[name] {
+					seen[name] = true
+					out = append(out, name)
+				}
+			}
+		}
+		if !matched {
+			if IsPattern(p) {
+				return nil, fmt.Errorf("%w: nothing matches %q", ErrAgentNotFound, p)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, p)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// AgentNames lists every agent in the namespace, sorted.
+func AgentNames() []string {
+	var names []string
+	for _, a := range loadAgents() {
+		names = append(names, a.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BulkCleanup returns a bulk operation that removes agents as Cleanup does,
+// recording result in their history; with result empty, agents completed
+// now are recorded as "success" and the rest as "killed".
+func BulkCleanup(result string) BulkOp {
+	completed := map[string]bool{}
+	if result == "" {
+		if agents, err := ListWithState(); err == nil {
+			for _, a := range agents {
+				completed[a.Name] = a.Lifecycle == StateCompleted
+			}
+		}
+	}
+	return func(s BulkSpec) BulkResult {
+		r := result
+		if r == "" {
+			r = "killed"
+			if completed[s.Name] {
+				r = "success"
+			}
+		}
+		if err := Cleanup(s.Name, r, 0, nil); err != nil {
+			return BulkResult{Error: err.Error()}
+		}
+		return BulkResult{OK: true}
+	}
+}
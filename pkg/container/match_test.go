@@ -0,0 +1,75 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMatchAgents(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	for _, name := range []string{"issue-12a", "issue-12b", "issue-13", "docs"} {
+		saveAgent(&Agent{Name: name})
+	}
+	for _, tc := range []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"issue-12*"}, []string{"issue-12a", "issue-12b"}},
+		{[]string{"docs", "issue-1?"}, []string{"docs", "issue-13"}},
+		{[]string{"issue-*", "issue-13"}, []string{"issue-12a", "issue-12b", "issue-13"}},
+		{[]string{"issue-12[ab]"}, []string{"issue-12a", "issue-12b"}},
+	} {
+		if got, err := MatchAgents(tc.patterns); err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("MatchAgents(%v) = %v, %v", tc.patterns, got, err)
+		}
+	}
+	for _, bad := range [][]string{{"issue-99*"}, {"issue-12a", "nope"}, {"issue-[12"}} {
+		if _, err := MatchAgents(bad); err == nil {
+			t.Errorf("MatchAgents(%v) should fail", bad)
+		}
+	}
+	if _, err := MatchAgents([]string{"nope"}); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("unknown name: %v", err)
+	}
+	if !IsPattern("w[1-3]") || IsPattern("worker-1") {
+		t.Error("IsPattern")
+	}
+}
+
+func TestBulkCleanup(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	fakePodman(t, `case "$1" in ps) echo '[{"Names": ["done"], "State": "running"}]' ;; esac`)
+
+	saveAgent(&Agent{Name: "done"})
+	saveAgent(&Agent{Name: "gone"})
+	var results []BulkResult
+	Bulk([]BulkSpec{{Name: "done"}, {Name: "gone"}}, 2, BulkCleanup(""), func(r BulkResult) { results = append(results, r) })
+	for _, r := range results {
+		if !r.OK {
+			t.Errorf("%s: %s", r.Name, r.Error)
+		}
+	}
+	for name, want := range map[string]string{"done": "success", "gone": "killed"} {
+		if h, err := LoadHistory(name); err != nil || h.Result != want {
+			t.Errorf("%s history = %+v, %v", name, h, err)
+		}
+	}
+	if len(AgentNames()) != 0 {
+		t.Errorf("agents left: %v", AgentNames())
+	}
+
+	saveAgent(&Agent{Name: "forced"})
+	Bulk([]BulkSpec{{Name: "forced"}}, 1, BulkCleanup("killed"), func(BulkResult) {})
+	if h, _ := LoadHistory("forced"); h == nil || h.Result != "killed" {
+		t.Errorf("history = %+v", h)
+	}
+}
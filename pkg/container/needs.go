@@ -0,0 +1,240 @@
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// needsPoll is how often a run waiting on its needs checks their history.
+var needsPoll = 10 * time.Second
+
+// States of an agent in the dependency graph.
+const (
+	NeedCompleted = "completed" // its run succeeded
+	NeedFailed    = "failed"    // its run failed, or it was removed unfinished
+	NeedWaiting   = "waiting"   // some of its needs have not completed
+	NeedPending   = "pending"   // free to run, or not spawned yet
+)
+
+// needState is whether dep's run has completed, going by its history: the
+// record of its latest run, not one left by an earlier agent of the name.
+// detail is the recorded result when it failed.
+func needState(dep string) (state, detail string) {
+	h, err := LoadHistory(dep)
+	if err != nil {
+		return NeedPending, ""
+	}
+	if agent, err := loadAgent(dep); err == nil && h.Created.Before(agent.Created) {
+		return NeedPending, ""
+	}
+	switch runOutcome(h.Result) {
+	case "succeeded":
+		return NeedCompleted, ""
+	case "failed":
+		return NeedFailed, h.Result
+	}
+	if _, err := loadAgent(dep); err != nil {
+		// Killed or pruned before it finished.
+		return NeedFailed, h.Result
+	}
+	return NeedPending, ""
+}
+
+// awaitNeeds blocks until every agent in needs has completed, failing as
+// soon as one of them fails.
+func awaitNeeds(needs []string) error {
+	announced := false
+	for {
+		var pending []string
+		for _, dep := range needs {
+			state, detail := needState(dep)
+			switch state {
+			case NeedFailed:
+				return fmt.Errorf("%w: %s ended %s", ErrDependencyFailed, dep, detail)
+			case NeedPending:
+				pending = append(pending, dep)
+			}
+		}
+		if len(pending) == 0 {
+			if announced {
+				fmt.Printf("✅ Dependencies completed: %s\n", strings.Join(needs, ", "))
+			}
+			return nil
+		}
+		if !announced {
+			fmt.Printf("⏳ Waiting for %s to complete...\n", strings.Join(pending, ", "))
+			announced = true
+		}
+		time.Sleep(needsPoll)
+	}
+}
+
+// savedNeeds is what a saved agent needs; nil if there is no such agent.
+func savedNeeds(name string) []string {
+	if agent, err := loadAgent(name); err == nil {
+		return agent.Needs
+	}
+	return nil
+}
+
+// checkNeeds rejects needs that would make name depend on itself, through
+// the needs lookup returns for other agents.
+func checkNeeds(name string, needs []string, lookup func(string) []string) error {
+	var path []string
+	seen := map[string]bool{}
+	var visit func(string) bool
+	visit = func(n string) bool {
+		path = append(path, n)
+		if n == name {
+			return true
+		}
+		if seen[n] {
+			path = path[:len(path)-1]
+			return false
+		}
+		seen[n] = true
+		for _, dep := range lookup(n) {
+			if visit(dep) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	for _, dep := range needs {
+		path, seen = []string{name}, map[string]bool{}
+		if visit(dep) {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " → "))
+		}
+	}
+	return nil
+}
+
+// specNeeds looks agents' needs up in specs, then among saved agents.
+func specNeeds(specs []BulkSpec) func(string) []string {
+	byName := map[string][]string{}
+	for _, s := range specs {
+		byName[s.Name] = s.Needs
+	}
+	return func(name string) []string {
+		if needs, ok := byName[name]; ok {
+			return needs
+		}
+		return savedNeeds(name)
+	}
+}
+
+// startOrder is the order to start specs in: each after the specs it needs,
+// otherwise as given, so none waits in a slot on one not yet started.
+func startOrder(specs []BulkSpec) []int {
+	index := map[string]int{}
+	for i, s := range specs {
+		index[s.Name] = i
+	}
+	started := make([]bool, len(specs))
+	var order []int
+	var start func(i int)
+	start = func(i int) {
+		if started[i] {
+			return
+		}
+		started[i] = true
+		for _, dep := range specs[i].Needs {
+			if j, ok := index[dep]; ok {
+				start(j)
+			}
+		}
+		order = append(order, i)
+	}
+	for i := range specs {
+		start(i)
+	}
+	return order
+}
+
+// DAGNode is an agent in the dependency graph.
+type DAGNode struct {
+	Name  string   `json:"name"`
+	State string   `json:"state"`
+	Needs []string `json:"needs,omitempty"`
+}
+
+// AgentDAG returns the agents that need others or are needed, with their
+// states, in stages: every agent's needs lie in earlier stages.
+func AgentDAG() ([][]DAGNode, error) {
+	agents, err := List()
+	if err != nil {
+		return nil, err
+	}
+	needs := map[string][]string{}
+	var queue []string
+	for _, a := range agents {
+		if len(a.Needs) > 0 {
+			needs[a.Name] = a.Needs
+			queue = append(queue, a.Needs...)
+		}
+	}
+	// Needed agents join the graph, with what they need in turn.
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := needs[name]; ok {
+			continue
+		}
+		needs[name] = savedNeeds(name)
+		queue = append(queue, needs[name]...)
+	}
+	return dagStages(needs, needState), nil
+}
+
+// dagStages layers the graph, each stage sorted by name. Agents caught in a
+// cycle, which spawn prevents, end up in a last stage.
+func dagStages(needs map[string][]string, state func(string) (string, string)) [][]DAGNode {
+	states := map[string]string{}
+	for name := range needs {
+		states[name], _ = state(name)
+	}
+	placed := map[string]bool{}
+	var stages [][]DAGNode
+	for len(placed) < len(needs) {
+		var stage []DAGNode
+		for name, deps := range needs {
+			if !placed[name] && allIn(deps, placed) {
+				stage = append(stage, DAGNode{Name: name, Needs: deps})
+			}
+		}
+		if len(stage) == 0 {
+			for name, deps := range needs {
+				if !placed[name] {
+					stage = append(stage, DAGNode{Name: name, Needs: deps})
+				}
+			}
+		}
+		sort.Slice(stage, func(i, j int) bool { return stage[i].Name < stage[j].Name })
+		for i, n := range stage {
+			placed[n.Name] = true
+			stage[i].State = states[n.Name]
+			if stage[i].State == NeedPending {
+				for _, dep := range n.Needs {
+					if states[dep] != NeedCompleted {
+						stage[i].State = NeedWaiting
+						break
+					}
+				}
+			}
+		}
+		stages = append(stages, stage)
+	}
+	return stages
+}
+
+func allIn(names []string, set map[string]bool) bool {
+	for _, n := range names {
+		if !set[n] {
+			return false
+		}
+	}
+	return true
+}
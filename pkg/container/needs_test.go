@@ -0,0 +1,122 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNeedState(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	now := time.Now()
+	saveAgent(&Agent{Name: "done", Created: now.Add(-time.Hour)})
+	SaveHistory(&AgentHistory{Name: "done", Created: now.Add(-30 * time.Minute), Result: "success"})
+	saveAgent(&Agent{Name: "broken", Created: now.Add(-time.Hour)})
+	SaveHistory(&AgentHistory{Name: "broken", Created: now.Add(-30 * time.Minute), Result: "failed"})
+	SaveHistory(&AgentHistory{Name: "gone", Created: now.Add(-30 * time.Minute), Result: "killed"})
+	// Respawned since its last run succeeded.
+	saveAgent(&Agent{Name: "again", Created: now})
+	SaveHistory(&AgentHistory{Name: "again", Created: now.Add(-time.Hour), Result: "success"})
+	saveAgent(&Agent{Name: "busy", Created: now})
+
+	for name, want := range map[string]string{
+		"done":    NeedCompleted,
+		"broken":  NeedFailed,
+		"gone":    NeedFailed,
+		"again":   NeedPending,
+		"busy":    NeedPending,
+		"unknown": NeedPending,
+	} {
+		if got, _ := needState(name); got != want {
+			t.Errorf("needState(%s) = %s, want %s", name, got, want)
+		}
+	}
+
+	if err := awaitNeeds([]string{"done"}); err != nil {
+		t.Errorf("awaitNeeds(done) = %v", err)
+	}
+	origPoll := needsPoll
+	needsPoll = time.Millisecond
+	defer func() { needsPoll = origPoll }()
+	err := awaitNeeds([]string{"busy", "broken"})
+	if !errors.Is(err, ErrDependencyFailed) || !strings.Contains(err.Error(), "broken ended failed") {
+		t.Errorf("awaitNeeds(busy, broken) = %v", err)
+	}
+}
+
+func TestCheckNeeds(t *testing.T) {
+	graph := map[string][]string{"api": {"schema"}, "ui": {"api"}, "schema": nil}
+	lookup := func(name string) []string { return graph[name] }
+
+	if err := checkNeeds("e2e", []string{"ui", "api"}, lookup); err != nil {
+		t.Errorf("checkNeeds(e2e) = %v", err)
+	}
+	if err := checkNeeds("schema", []string{"ui"}, lookup); err == nil || !strings.Contains(err.Error(), "schema → ui → api → schema") {
+		t.Errorf("checkNeeds(schema) = %v, want the cycle", err)
+	}
+	if err := checkNeeds("solo", []string{"solo"}, lookup); err == nil {
+		t.Error("an agent needing itself should be a cycle")
+	}
+}
+
+func TestBulkNeeds(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	specs, err := ReadBulkSpecs(strings.NewReader(`[
+		{"name": "e2e", "task": "t", "needs": ["ui", "api"]},
+		{"name": "ui", "task": "t", "needs": ["api"]},
+		{"name": "docs", "task": "t"},
+		{"name": "api", "task": "t"}
+	]`), "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, i := range startOrder(specs) {
+		order = append(order, specs[i].Name)
+	}
+	if got := strings.Join(order, ","); got != "api,ui,e2e,docs" {
+		t.Errorf("startOrder = %s", got)
+	}
+
+	_, err = ReadBulkSpecs(strings.NewReader(`[
+		{"name": "a", "task": "t", "needs": ["b"]},
+		{"name": "b", "task": "t", "needs": ["a"]}
+	]`), "run")
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("a cyclic batch = %v, want a cycle error", err)
+	}
+}
+
+func TestDAGStages(t *testing.T) {
+	needs := map[string][]string{"e2e": {"api", "ui"}, "ui": {"api"}, "api": nil, "docs": {"api"}}
+	states := map[string]string{"api": NeedCompleted}
+	stages := dagStages(needs, func(name string) (string, string) {
+		if s, ok := states[name]; ok {
+			return s, ""
+		}
+		return NeedPending, ""
+	})
+
+	var got []string
+	for _, stage := range stages {
+		var cells []string
+		for _, n := range stage {
+			cells = append(cells, n.Name+":"+n.State)
+		}
+		got = append(got, strings.Join(cells, ","))
+	}
+	want := "api:completed | docs:pending,ui:pending | e2e:waiting"
+	if strings.Join(got, " | ") != want {
+		t.Errorf("dagStages = %s, want %s", strings.Join(got, " | "), want)
+	}
+}
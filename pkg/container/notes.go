@@ -0,0 +1,69 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Note is a timestamped annotation on an agent — why it is paused, what it
+// is waiting on — kept with its metadata and carried into history.
+type Note struct {
+	Time   time.Time `json:"time"`
+	Author string    `json:"author,omitempty"`
+	Text   string    `json:"text"`
+}
+
+func (n Note) String() string {
+	s := n.Time.Format("2006-01-02 15:04") + " "
+	if n.Author != "" {
+		s += n.Author + ": "
+	}
+	return s + n.Text
+}
+
+// noteAuthor is the local user, recorded with each note.
+func noteAuthor() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return os.Getenv("USERNAME")
+}
+
+// AddNote annotates a running agent, or the history record of one that has
+// already been cleaned up.
+func AddNote(name, text string) (*Note, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("note is empty")
+	}
+	n := Note{Time: time.Now(), Author: noteAuthor(), Text: text}
+	err := updateAgent(name, func(agent *Agent) error {
+		agent.Notes = append(agent.Notes, n)
+		return nil
+	})
+	if !errors.Is(err, ErrAgentNotFound) {
+		return &n, err
+	}
+	if err := updateHistory(name, func(h *AgentHistory) error {
+		h.Notes = append(h.Notes, n)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	return &n, nil
+}
+
+// Notes returns an agent's notes, from its metadata or its history.
+func Notes(name string) ([]Note, error) {
+	if agent, err := loadAgent(name); err == nil {
+		return agent.Notes, nil
+	}
+	h, err := LoadHistory(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	return h.Notes, nil
+}
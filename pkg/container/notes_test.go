@@ -0,0 +1,40 @@
+package container
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNotesSurviveCleanup(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	fakePodman(t, "exit 0")
+
+	saveAgent(&Agent{Name: "pinned", Repo: "https://github.com/test/repo"})
+	if _, err := AddNote("pinned", "waiting on upstream fix for flaky redis test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddNote("pinned", "   "); err == nil {
+		t.Error("expected an error for an empty note")
+	}
+	if err := Cleanup("pinned", "killed", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notes move to history and can still be added there.
+	if _, err := AddNote("pinned", "upstream fixed in v2.1"); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := Notes("pinned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 || notes[0].Text != "waiting on upstream fix for flaky redis test" || notes[0].Time.IsZero() {
+		t.Errorf("Notes() = %+v", notes)
+	}
+	if _, err := AddNote("nobody", "hi"); err == nil {
+		t.Error("expected an error for an unknown agent")
+	}
+}
@@ -0,0 +1,92 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// patchOnlyNote tells a patch-only agent to leave its work uncommitted.
+const patchOnlyNote = "\n\nDo not commit. Leave every change uncommitted in the working tree; it is collected as a patch for review."
+
+// RunForPatch runs the agent like RunUntilDone, but in patch-only mode: the
+// agent is told not to commit, the task is done once tests pass, and the
+// change — everything since the run started, committed or not — is saved as
+// a patch in the agent's history (TaskResult.Patch) instead of being left
+// on a branch.
+func RunForPatch(name string, task string, maxAttempts int) (*TaskResult, error) {
+	return RunWithOptions(name, task, maxAttempts, RunOptions{OutputPatch: true})
+}
+
+// patchDone checks a patch-only attempt: tests OK, coverage holding and a
+// change to collect. It returns the change when it is done.
+func patchDone(name string, cp *Checkpoint, status *AgentStatus) (string, bool) {
+	if !status.TestsOK() {
+		return "", false
+	}
+	if status.Coverage == nil {
+		// getStatus only measures committed work.
+		status.Coverage = checkCoverage(name)
+	}
+	if status.Coverage != nil && status.Coverage.Low {
+		return "", false
+	}
+	patch, err := workspacePatch(name, cp.PatchBase)
+	if err != nil {
+		fmt.Printf("⚠️  Could not collect the patch: %v\n", err)
+		return "", false
+	}
+	if strings.TrimSpace(patch) == "" {
+		fmt.Printf("⚠️  No changes to collect yet\n")
+		return "", false
+	}
+	return patch, true
+}
+
+// workspacePatch diffs the workspace, untracked files included, against
+// base. A scratch index keeps the agent's own index untouched.
+func workspacePatch(name, base string) (string, error) {
+	script := layoutOf(name).cd() + `idx=$(mktemp) && cp "$(git rev-parse --git-dir)/index" "$idx" && ` +
+		`GIT_INDEX_FILE="$idx" git add -A && GIT_INDEX_FILE="$idx" git diff --cached --binary ` + shellQuote(base) +
+		`; rc=$?; rm -f "$idx"; exit $rc`
+	out, err := podmanLong("exec", containerName(name), "sh", "-c", script).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// PatchPath is where a patch-only run's patch is kept, next to the agent's
+// history record.
+func PatchPath(name string) string {
+	return filepath.Join(historyDir(), name+".patch")
+}
+
+// savePatch writes the patch for its owner only. It stays plaintext even
+// with encryption on: it is there to be read and git-applied by hand.
+func savePatch(name, patch string) (string, error) {
+	if err := os.MkdirAll(historyDir(), 0755); err != nil {
+		return "", err
+	}
+	path := PatchPath(name)
+	return path, writeFileAtomic(path, []byte(patch), 0600)
+}
+
+// ApplyPatchToCheckout applies a patch to a local checkout's working tree,
+// uncommitted, for a human to review. Nothing is applied unless all of it
+// applies.
+func ApplyPatchToCheckout(dir, patchPath string) error {
+	abs, err := filepath.Abs(patchPath)
+	if err != nil {
+		return err
+	}
+	for _, args := range [][]string{{"apply", "--check", abs}, {"apply", abs}} {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("patch does not apply to %s: %s", dir, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
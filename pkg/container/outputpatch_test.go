@@ -0,0 +1,81 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const helloPatch = `diff --git a/hello.txt b/hello.txt
+--- a/hello.txt
++++ b/hello.txt
+@@ -1 +1 @@
+-hello
++hello, world
+`
+
+func TestPatchDone(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	diff := filepath.Join(t.TempDir(), "diff")
+	fakePodman(t, `case "$*" in
+*"git diff --cached --binary 'abc123'"*) cat `+diff+` ;;
+esac`)
+	cp := &Checkpoint{OutputPatch: true, PatchBase: "abc123"}
+
+	if _, done := patchDone("p", cp, &AgentStatus{TestStatus: "fail", HasUncommitted: true}); done {
+		t.Error("failing tests are not done")
+	}
+	os.WriteFile(diff, nil, 0644)
+	if _, done := patchDone("p", cp, &AgentStatus{TestStatus: "pass"}); done {
+		t.Error("no change is not done")
+	}
+	os.WriteFile(diff, []byte(helloPatch), 0644)
+	patch, done := patchDone("p", cp, &AgentStatus{TestStatus: "pass", HasUncommitted: true})
+	if !done || patch != helloPatch {
+		t.Errorf("patchDone = %q, %v", patch, done)
+	}
+
+	path, err := savePatch("p", patch)
+	if err != nil || path != filepath.Join(tmpHome, ".agentctl", "history", "p.patch") {
+		t.Errorf("savePatch = %s, %v", path, err)
+	}
+}
+
+func TestApplyPatchToCheckout(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-qm", "init"}} {
+		if args[0] == "add" {
+			os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello\n"), 0644)
+		}
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	patch := filepath.Join(t.TempDir(), "p.patch")
+	os.WriteFile(patch, []byte(helloPatch), 0644)
+
+	if err := ApplyPatchToCheckout(dir, patch); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "hello.txt")); string(data) != "hello, world\n" {
+		t.Errorf("hello.txt = %q", data)
+	}
+	// Applied already: it no longer applies, and nothing changes.
+	if err := ApplyPatchToCheckout(dir, patch); err == nil || !strings.Contains(err.Error(), "does not apply") {
+		t.Errorf("second apply: %v", err)
+	}
+}
+
+func TestPatchOnlyRetryPrompt(t *testing.T) {
+	prompt := retryPrompt("fix login"+patchOnlyNote, AgentStatus{TestStatus: "fail", HasUncommitted: true}, true)
+	if strings.Contains(prompt, "all changes are committed") || !strings.Contains(prompt, "Leave your changes uncommitted.") ||
+		!strings.Contains(prompt, "Do not commit.") {
+		t.Errorf("prompt:\n%s", prompt)
+	}
+}
@@ -0,0 +1,197 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+	"github.com/jordanpartridge/agentctl/pkg/process"
+)
+
+// Run loop pacing defaults; see config.RunLoop.
+const (
+	DefaultSettle     = 2 * time.Second
+	DefaultBackoff    = 3 * time.Second
+	DefaultMaxBackoff = 2 * time.Minute
+)
+
+// rateWindow is the period RunLoop.RateLimit counts invocations over.
+const rateWindow = time.Minute
+
+// Pacing is the run loop's pauses and rate limit, read from config.
+type Pacing struct {
+	Settle     time.Duration
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	RateLimit  int
+}
+
+// loadPacing reads the run loop pacing from config, with the defaults for
+// what is unset or unparseable.
+func loadPacing() Pacing {
+	p := Pacing{Settle: DefaultSettle, Backoff: DefaultBackoff, MaxBackoff: DefaultMaxBackoff}
+	cfg, err := config.Load()
+	if err != nil {
+		return p
+	}
+	for _, f := range []struct {
+		value string
+		into  *time.Duration
+	}{
+		{cfg.RunLoop.Settle, &p.Settle},
+		{cfg.RunLoop.Backoff, &p.Backoff},
+		{cfg.RunLoop.MaxBackoff, &p.MaxBackoff},
+	} {
+		if d, err := time.ParseDuration(f.value); err == nil && d >= 0 {
+			*f.into = d
+		}
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = p.Backoff
+	}
+	p.RateLimit = cfg.RunLoop.RateLimit
+	return p
+}
+
+// backoff is the pause after the n-th unfinished attempt in a row: Backoff
+// doubled n-1 times, capped at MaxBackoff, plus up to a quarter of jitter so
+// runs that failed together don't retry together.
+func (p Pacing) backoff(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/4+1))
+}
+
+// longest is the longest pause the backoff reaches, with its jitter, for
+// failures only time will fix.
+func (p Pacing) longest() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return 0
+	}
+	return p.MaxBackoff + time.Duration(rand.Int63n(int64(p.MaxBackoff)/4+1))
+}
+
+func rateLimitPath() string {
+	return filepath.Join(namespace.Root(), "ratelimit.json")
+}
+
+// Lock files older than this were left by a process that died holding them.
+// The rate limit and keyring locks are held for moments; a reference clone
+// or a rewrite of the spy store can hold its lock for minutes.
+const (
+	rateLockStale = 10 * time.Second
+	longLockStale = 30 * time.Minute
+)
+
+// takeInvocation records an agent invocation against the rate limit,
+// returning how long to wait first when the last minute already has limit
+// of them; 0 means it was recorded and the agent can start. The record is
+// shared by every agentctl process in the namespace.
+func takeInvocation(limit int, now time.Time) (time.Duration, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	path := rateLimitPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	unlock, err := lockFile(path+".lock", rateLockStale)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	var recent []time.Time
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &recent)
+	}
+	kept := recent[:0]
+	for _, t := range recent {
+		if now.Sub(t) < rateWindow {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= limit {
+		return kept[len(kept)-limit].Add(rateWindow).Sub(now), nil
+	}
+	data, _ := json.Marshal(append(kept, now))
+	return 0, os.WriteFile(path, data, 0644)
+}
+
+// lockFile takes an exclusive lock file, recording this process's pid in
+// it, and returns the function that releases it. A lock whose holder has
+// died is broken at once, one whose holder can't be told once it is older
+// than stale; waiting gives up after twice that.
+func lockFile(path string, stale time.Duration) (func(), error) {
+	deadline := time.Now().Add(2 * stale)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if lockAbandoned(path, stale) {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// lockAbandoned reports whether the lock file at path was left behind: its
+// holder is no longer running, or it is older than stale. A lock not yet
+// written is judged by its age alone.
+func lockAbandoned(path string, stale time.Duration) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && !process.Alive(pid) {
+			return true
+		}
+	}
+	return time.Since(info.ModTime()) > stale
+}
+
+// waitForRateLimit blocks until the rate limit lets another agent
+// invocation start, and records it. A limiter that can't be read is not
+// worth stalling the run over: the agent starts.
+func waitForRateLimit(p Pacing) {
+	for announced := false; ; announced = true {
+		wait, err := takeInvocation(p.RateLimit, time.Now())
+		if err != nil {
+			fmt.Printf("⚠️  Rate limiter unavailable: %v\n", err)
+			return
+		}
+		if wait <= 0 {
+			return
+		}
+		if !announced {
+			fmt.Printf("🚦 Rate limit of %d runs/min reached, waiting %s\n", p.RateLimit, wait.Round(time.Second))
+		}
+		time.Sleep(wait)
+	}
+}
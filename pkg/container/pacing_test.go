@@ -0,0 +1,100 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLoadPacing(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	if p := loadPacing(); p.Settle != DefaultSettle || p.Backoff != DefaultBackoff || p.MaxBackoff != DefaultMaxBackoff || p.RateLimit != 0 {
+		t.Errorf("defaults = %+v", p)
+	}
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"),
+		[]byte(`{"run_loop": {"settle": "0s", "backoff": "10s", "max_backoff": "5s", "rate_limit": 20}}`), 0644)
+	if p := loadPacing(); p.Settle != 0 || p.Backoff != 10*time.Second || p.MaxBackoff != 10*time.Second || p.RateLimit != 20 {
+		t.Errorf("configured = %+v", p)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Pacing{Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	for n, base := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 40: 10 * time.Second} {
+		if d := p.backoff(n); d < base || d > base+base/4 {
+			t.Errorf("backoff(%d) = %s, want %s plus jitter", n, d, base)
+		}
+	}
+	if d := (Pacing{}).backoff(3); d != 0 {
+		t.Errorf("zero backoff = %s", d)
+	}
+}
+
+func TestTakeInvocation(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if wait, err := takeInvocation(3, now.Add(time.Duration(i)*10*time.Second)); wait != 0 || err != nil {
+			t.Fatalf("invocation %d: wait %s, %v", i, wait, err)
+		}
+	}
+	// The fourth within the minute waits for the first to age out.
+	if wait, _ := takeInvocation(3, now.Add(30*time.Second)); wait != 30*time.Second {
+		t.Errorf("wait = %s, want 30s", wait)
+	}
+	if wait, _ := takeInvocation(3, now.Add(61*time.Second)); wait != 0 {
+		t.Errorf("wait after the window = %s", wait)
+	}
+	if wait, _ := takeInvocation(0, now); wait != 0 {
+		t.Error("no limit should never wait")
+	}
+
+	// A lock left by a dead process is broken.
+	lock := rateLimitPath() + ".lock"
+	os.WriteFile(lock, nil, 0644)
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(lock, old, old)
+	if _, err := takeInvocation(3, now.Add(5*time.Minute)); err != nil {
+		t.Errorf("stale lock: %v", err)
+	}
+}
+
+func TestLockAbandoned(t *testing.T) {
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skip("no true(1):", err)
+	}
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	for _, tc := range []struct {
+		name, holder string
+		aged         bool
+		want         bool
+	}{
+		{"live holder", strconv.Itoa(os.Getpid()), false, false},
+		{"live holder past stale", strconv.Itoa(os.Getpid()), true, true},
+		{"dead holder", strconv.Itoa(exited.Process.Pid), false, true},
+		{"not yet written", "", false, false},
+	} {
+		path := filepath.Join(dir, "test.lock")
+		os.WriteFile(path, []byte(tc.holder), 0644)
+		if tc.aged {
+			os.Chtimes(path, old, old)
+		}
+		if got := lockAbandoned(path, 30*time.Minute); got != tc.want {
+			t.Errorf("%s: lockAbandoned() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
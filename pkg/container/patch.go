@@ -0,0 +1,104 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// seedPatchPath is where ApplyPatch copies the patch inside the container.
+const seedPatchPath = "/tmp/agentctl-seed.patch"
+
+// ApplyPatch seeds the agent's workspace with a (possibly partial) patch from
+// the host, applied to the working tree and left uncommitted, and returns
+// the files it touches. When the agent is on a coordination bus the files are
+// claimed for it first; the patch is not applied if another agent holds one.
+func ApplyPatch(name, patchPath string) ([]string, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(patchPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read patch: %w", err)
+	}
+	files := patchFiles(string(data))
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s is not a patch (no files in it)", patchPath)
+	}
+
+	if agent.Repo != "" {
+		if _, err := coordination.Init(agent.Repo); err == nil {
+			for _, f := range files {
+				if err := coordination.ClaimFile(agent.Repo, name, f); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if out, err := podmanLong("cp", patchPath, containerName(name)+":"+seedPatchPath).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("copy patch to %s failed: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	defer podman("exec", containerName(name), "rm", "-f", seedPatchPath).Run()
+	out, err := podman("exec", containerName(name), "sh", "-c",
+		layoutOf(name).cd()+"git apply --check "+seedPatchPath+" && git apply "+seedPatchPath).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("patch does not apply to %s's workspace: %s", name, strings.TrimSpace(string(out)))
+	}
+	return files, nil
+}
+
+// patchFiles lists the files a unified diff touches, in order: the new path
+// of each file, or the old one for a deletion.
+func patchFiles(patch string) []string {
+	var files []string
+	seen := map[string]bool{}
+	var old string
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			old = diffPath(strings.TrimPrefix(line, "--- "))
+		case strings.HasPrefix(line, "+++ "):
+			f := diffPath(strings.TrimPrefix(line, "+++ "))
+			if f == "" {
+				f = old
+			}
+			if f != "" && !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// diffPath strips a diff header path of its a/ or b/ prefix and trailing
+// timestamp; /dev/null yields "".
+func diffPath(p string) string {
+	p, _, _ = strings.Cut(p, "\t")
+	p = strings.TrimSpace(p)
+	if p == "/dev/null" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(p, "a/"); ok {
+		return rest
+	}
+	if rest, ok := strings.CutPrefix(p, "b/"); ok {
+		return rest
+	}
+	return p
+}
+
+// PatchTask is the prompt for finishing a seeded patch.
+func PatchTask(task, patchPath string, files []string) string {
+	return fmt.Sprintf(`A partial change (%s) has already been applied to the working tree, uncommitted. It touches:
+- %s
+
+Review it, finish the work it starts, and commit the result together with your changes.
+
+Task: %s`, filepath.Base(patchPath), strings.Join(files, "\n- "), task)
+}
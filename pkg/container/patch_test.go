@@ -0,0 +1,88 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+const seedPatch = `diff --git a/src/auth.go b/src/auth.go
+--- a/src/auth.go
++++ b/src/auth.go
+@@ -1 +1 @@
+-old
++new
+diff --git a/src/token.go b/src/token.go
+new file mode 100644
+--- /dev/null
++++ b/src/token.go	2026-03-01 10:00:00
+@@ -0,0 +1 @@
++package src
+diff --git a/legacy.go b/legacy.go
+deleted file mode 100644
+--- a/legacy.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+`
+
+func TestPatchFiles(t *testing.T) {
+	want := []string{"src/auth.go", "src/token.go", "legacy.go"}
+	if got := patchFiles(seedPatch); !reflect.DeepEqual(got, want) {
+		t.Errorf("patchFiles = %v, want %v", got, want)
+	}
+	if got := patchFiles("just some notes\n"); len(got) != 0 {
+		t.Errorf("not a patch, got %v", got)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$*" >> `+calls+`
+case "$*" in
+*"git apply --check"*) exit 0 ;;
+esac`)
+
+	patch := filepath.Join(t.TempDir(), "changes.patch")
+	os.WriteFile(patch, []byte(seedPatch), 0644)
+	repo := "https://github.com/test/patch"
+	saveAgent(&Agent{Name: "finisher", Repo: repo})
+
+	files, err := ApplyPatch("finisher", patch)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("ApplyPatch = %v, %v", files, err)
+	}
+	log, _ := os.ReadFile(calls)
+	if !strings.Contains(string(log), "cp "+patch+" "+containerName("finisher")+":"+seedPatchPath) ||
+		!strings.Contains(string(log), "git apply "+seedPatchPath) {
+		t.Errorf("podman calls:\n%s", log)
+	}
+	if holder, claimed, _ := coordination.IsFileClaimed(repo, "src/auth.go"); !claimed || holder != "finisher" {
+		t.Error("patched files should be claimed for the agent")
+	}
+
+	// A file held by another agent stops the patch before it is applied.
+	os.Remove(calls)
+	saveAgent(&Agent{Name: "other", Repo: repo})
+	if _, err := ApplyPatch("other", patch); !errors.Is(err, coordination.ErrClaimConflict) {
+		t.Errorf("expected a claim conflict, got %v", err)
+	}
+	if _, err := os.Stat(calls); err == nil {
+		t.Error("the patch should not reach the container after a conflict")
+	}
+
+	task := PatchTask("finish and fix tests", patch, files)
+	if !strings.Contains(task, "(changes.patch)") || !strings.Contains(task, "- src/token.go") || !strings.HasSuffix(task, "Task: finish and fix tests") {
+		t.Errorf("task = %q", task)
+	}
+}
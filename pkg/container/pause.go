@@ -0,0 +1,76 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// pausePoll is how often a run loop held at an attempt boundary checks
+// whether its agent was resumed.
+var pausePoll = 2 * time.Second
+
+// Pause freezes an agent's container (podman pause), freeing its CPU while
+// keeping every process and file as it is. A run loop mid-attempt simply
+// stalls with it; one between attempts waits before starting the next.
+func Pause(name string) error {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return err
+	}
+	if agent.Paused {
+		return fmt.Errorf("agent %s is already paused", name)
+	}
+	if out, err := podman("pause", containerName(name)).CombinedOutput(); err != nil {
+		return fmt.Errorf("pausing %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	var resumeStatus string
+	if agent.Repo != "" {
+		if st, err := coordination.GetState(agent.Repo); err == nil && st.Agents[name] != nil {
+			resumeStatus = st.Agents[name].Status
+		}
+		coordination.UpdateAgentState(agent.Repo, name, "paused", "")
+	}
+	return updateAgent(name, func(agent *Agent) error {
+		agent.Paused, agent.ResumeStatus = true, resumeStatus
+		return nil
+	})
+}
+
+// Resume unfreezes a paused agent and restores its coordination state.
+func Resume(name string) error {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return err
+	}
+	if !agent.Paused {
+		return fmt.Errorf("agent %s is not paused", name)
+	}
+	if out, err := podman("unpause", containerName(name)).CombinedOutput(); err != nil {
+		return fmt.Errorf("resuming %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	if agent.Repo != "" && agent.ResumeStatus != "" {
+		coordination.UpdateAgentState(agent.Repo, name, agent.ResumeStatus, "")
+	}
+	return updateAgent(name, func(agent *Agent) error {
+		agent.Paused, agent.ResumeStatus = false, ""
+		return nil
+	})
+}
+
+// waitWhilePaused holds the run loop at an attempt boundary until the agent
+// is resumed, so no podman exec hits a frozen container.
+func waitWhilePaused(name string) {
+	for announced := false; ; announced = true {
+		agent, err := loadAgent(name)
+		if err != nil || !agent.Paused {
+			return
+		}
+		if !announced {
+			fmt.Printf("⏸️  Paused; waiting for: agentctl resume %s\n", name)
+		}
+		time.Sleep(pausePoll)
+	}
+}
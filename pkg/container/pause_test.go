@@ -0,0 +1,72 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestPauseResume(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$@" >> `+calls)
+	repo := "https://github.com/org/api"
+	saveAgent(&Agent{Name: "worker", Repo: repo})
+	coordination.Init(repo)
+	coordination.UpdateAgentState(repo, "worker", "working", "feat/x")
+
+	if err := Pause("worker"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Pause("worker"); err == nil {
+		t.Error("pausing a paused agent succeeded")
+	}
+	if st, _ := coordination.GetState(repo); st.Agents["worker"].Status != "paused" {
+		t.Errorf("coordination status = %q, want paused", st.Agents["worker"].Status)
+	}
+	if aws := withState(&Agent{Name: "worker"}, containerState{Status: "paused"}); aws.Lifecycle != StatePaused || !aws.ContainerUp {
+		t.Errorf("paused container lifecycle = %s", aws.Lifecycle)
+	}
+
+	// A run loop at an attempt boundary waits until the agent is resumed.
+	pausePoll = 10 * time.Millisecond
+	defer func() { pausePoll = 2 * time.Second }()
+	released := make(chan struct{})
+	go func() {
+		waitWhilePaused("worker")
+		close(released)
+	}()
+	select {
+	case <-released:
+		t.Fatal("run loop continued while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := Resume("worker"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("run loop still waiting after resume")
+	}
+	if st, _ := coordination.GetState(repo); st.Agents["worker"].Status != "working" {
+		t.Errorf("coordination status after resume = %q, want working", st.Agents["worker"].Status)
+	}
+	if err := Resume("worker"); err == nil {
+		t.Error("resuming a running agent succeeded")
+	}
+
+	data, _ := os.ReadFile(calls)
+	if got := strings.Fields(string(data)); strings.Join(got, " ") != "pause "+containerName("worker")+" unpause "+containerName("worker") {
+		t.Errorf("podman calls = %q", data)
+	}
+}
@@ -0,0 +1,96 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// PermissionsEnv tells the image's run-task how to start Claude:
+// PermissionsSkip means with --dangerously-skip-permissions, PermissionsSettings
+// without it, so the rules agentctl wrote to the managed settings apply.
+const (
+	PermissionsEnv      = "AGENTCTL_PERMISSIONS"
+	PermissionsSkip     = "skip"
+	PermissionsSettings = "settings"
+)
+
+// claudeManagedSettings is Claude Code's system-wide settings file on Linux.
+// It outranks user and project settings, so neither the repo's .claude nor
+// the agent itself can loosen what is written there.
+const claudeManagedSettings = "/etc/claude-code/managed-settings.json"
+
+// agentPermissions returns the rules an agent spawned with opts runs under,
+// or nil when it skips permission checks: its profile sets none, or it was
+// spawned with --yolo.
+func agentPermissions(cfg *config.Config, opts SpawnOptions) *config.Permissions {
+	if opts.Yolo {
+		return nil
+	}
+	return cfg.Profiles[opts.Profile].Permissions
+}
+
+// permissionsMode is the PermissionsEnv value for rules.
+func permissionsMode(rules *config.Permissions) string {
+	if rules == nil {
+		return PermissionsSkip
+	}
+	return PermissionsSettings
+}
+
+// mergeClaudeSettings sets the permissions of a Claude Code settings file,
+// keeping whatever else the image put there.
+func mergeClaudeSettings(existing []byte, rules *config.Permissions) ([]byte, error) {
+	settings := map[string]any{}
+	if len(strings.TrimSpace(string(existing))) > 0 {
+		if err := json.Unmarshal(existing, &settings); err != nil {
+			return nil, fmt.Errorf("the image's Claude settings are not JSON: %w", err)
+		}
+	}
+	permissions := map[string]any{
+		"allow":       nonNil(rules.Allow),
+		"deny":        nonNil(rules.Deny),
+		"defaultMode": "default",
+		// Even a run-task that passes --dangerously-skip-permissions
+		// regardless can't bypass the rules.
+		"disableBypassPermissionsMode": "disable",
+	}
+	// Rules the image set for other tools still apply.
+	if old, ok := settings["permissions"].(map[string]any); ok {
+		for k, v := range old {
+			if _, set := permissions[k]; !set {
+				permissions[k] = v
+			}
+		}
+	}
+	settings["permissions"] = permissions
+	return json.MarshalIndent(settings, "", "  ")
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// writeClaudeSettings writes rules into the container's managed settings,
+// as root and read-only to the agent user.
+func writeClaudeSettings(name string, rules *config.Permissions) error {
+	existing, _ := podman("exec", containerName(name), "cat", claudeManagedSettings).Output()
+	data, err := mergeClaudeSettings(existing, rules)
+	if err != nil {
+		return err
+	}
+	cmd := podman("exec", "-i", "--user", "root", containerName(name), "sh", "-c",
+		"mkdir -p "+shellQuote(path.Dir(claudeManagedSettings))+" && cat > "+shellQuote(claudeManagedSettings)+
+			" && chmod 644 "+shellQuote(claudeManagedSettings))
+	cmd.Stdin = strings.NewReader(string(data) + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package container
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestAgentPermissions(t *testing.T) {
+	rules := &config.Permissions{Allow: []string{"Edit", "Bash(go test:*)"}, Deny: []string{"WebFetch"}}
+	cfg := &config.Config{Profiles: map[string]config.Profile{"locked": {Permissions: rules}, "open": {}}}
+
+	if got := agentPermissions(cfg, SpawnOptions{Profile: "locked"}); got != rules {
+		t.Errorf("locked profile = %+v", got)
+	}
+	if got := agentPermissions(cfg, SpawnOptions{Profile: "locked", Yolo: true}); got != nil {
+		t.Errorf("--yolo should skip the rules, got %+v", got)
+	}
+	if got := agentPermissions(cfg, SpawnOptions{Profile: "open"}); got != nil || permissionsMode(got) != PermissionsSkip {
+		t.Errorf("open profile = %+v", got)
+	}
+	if permissionsMode(rules) != PermissionsSettings {
+		t.Error("rules should run under the settings")
+	}
+}
+
+func TestMergeClaudeSettings(t *testing.T) {
+	existing := []byte(`{"env": {"FOO": "1"}, "permissions": {"deny": ["Read(./.env)"], "additionalDirectories": ["/data"]}}`)
+	data, err := mergeClaudeSettings(existing, &config.Permissions{Allow: []string{"Edit"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	json.Unmarshal(data, &got)
+	perms := got["permissions"].(map[string]any)
+	if !reflect.DeepEqual(perms["allow"], []any{"Edit"}) || !reflect.DeepEqual(perms["deny"], []any{}) {
+		t.Errorf("permissions = %v", perms)
+	}
+	if perms["disableBypassPermissionsMode"] != "disable" || perms["additionalDirectories"] == nil {
+		t.Errorf("permissions = %v", perms)
+	}
+	if got["env"] == nil {
+		t.Errorf("other settings dropped: %s", data)
+	}
+	if _, err := mergeClaudeSettings([]byte("not json"), &config.Permissions{}); err == nil {
+		t.Error("mergeClaudeSettings should reject a corrupt file")
+	}
+}
+
+func TestWriteClaudeSettings(t *testing.T) {
+	written := filepath.Join(t.TempDir(), "settings.json")
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo "$@" >> `+calls+`
+case "$*" in
+exec\ -i*) cat > `+written+` ;;
+esac`)
+
+	if err := writeClaudeSettings("locked", &config.Permissions{Deny: []string{"Bash(curl:*)"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(written)
+	if !strings.Contains(string(data), `"Bash(curl:*)"`) {
+		t.Errorf("settings = %s", data)
+	}
+	log, _ := os.ReadFile(calls)
+	if !strings.Contains(string(log), "--user root") || !strings.Contains(string(log), claudeManagedSettings) {
+		t.Errorf("podman calls = %s", log)
+	}
+}
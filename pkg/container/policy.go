@@ -0,0 +1,273 @@
+package container
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Policy violation actions.
+const (
+	PolicyKill  = "kill"
+	PolicyPause = "pause"
+	PolicyWarn  = "warn"
+)
+
+// builtinForbiddenCommands are always enforced when a policy is enabled.
+var builtinForbiddenCommands = []string{
+	`\b(curl|wget)\b[^|;&]*\|\s*(sudo\s+)?(ba|z|da)?sh\b`, // download piped into a shell
+	`\brm\s+-[a-zA-Z]*(rf|fr)[a-zA-Z]*\s+(/|~/?)(\s|$)`,   // rm -rf / or ~
+}
+
+var defaultProtectedBranches = []string{"main", "master"}
+
+// writeTools are the tools that modify files.
+var writeTools = map[string]bool{"Write": true, "Edit": true, "MultiEdit": true, "NotebookEdit": true}
+
+var (
+	urlPattern      = regexp.MustCompile(`https?://[^\s'"|;&)]+`)
+	shellSeparators = regexp.MustCompile(`&&|\|\||;|\|`)
+)
+
+// PolicyViolation records a tool call that broke the policy.
+type PolicyViolation struct {
+	Agent  string    `json:"agent"`
+	Time   time.Time `json:"time"`
+	Rule   string    `json:"rule"` // command, path, write, host, push
+	Tool   string    `json:"tool"`
+	Detail string    `json:"detail"` // the offending command, path or URL
+	Reason string    `json:"reason"`
+	Action string    `json:"action"`
+}
+
+func (v *PolicyViolation) String() string {
+	return fmt.Sprintf("%s: %s (%s: %s)", v.Rule, v.Reason, v.Tool, truncate(v.Detail, 120))
+}
+
+// Policy is a compiled config.Policy.
+type Policy struct {
+	Action    string
+	workspace string // relative paths resolve here; always writable
+	commands  []*regexp.Regexp
+	paths     []string
+	writable  []string
+	hosts     []string
+	protected []string
+	// pushed names the branches a push without a refspec sends: the
+	// checked-out branch and its push upstream. Nil outside an agent.
+	pushed func() []string
+}
+
+// NewPolicy compiles the configured rules, adding the built-in ones.
+func NewPolicy(cfg config.Policy) (*Policy, error) {
+	p := &Policy{
+		Action:    cfg.Action,
+		workspace: DefaultWorkspace,
+		paths:     cfg.ForbiddenPaths,
+		writable:  append([]string{"/tmp"}, cfg.WritablePaths...),
+		protected: cfg.ProtectedBranches,
+	}
+	switch p.Action {
+	case "":
+		p.Action = PolicyKill
+	case PolicyKill, PolicyPause, PolicyWarn:
+	default:
+		return nil, fmt.Errorf("policy: unknown action %q (want kill, pause or warn)", cfg.Action)
+	}
+	if len(p.protected) == 0 {
+		p.protected = defaultProtectedBranches
+	}
+	for _, h := range cfg.ForbiddenHosts {
+		p.hosts = append(p.hosts, strings.ToLower(strings.TrimPrefix(h, ".")))
+	}
+	for _, expr := range append(builtinForbiddenCommands, cfg.ForbiddenCommands...) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("policy: invalid forbidden command %q: %w", expr, err)
+		}
+		p.commands = append(p.commands, re)
+	}
+	return p, nil
+}
+
+// forWorkspace returns a copy of the policy for an agent whose workspace is ws.
+func (p *Policy) forWorkspace(ws string) *Policy {
+	c := *p
+	c.workspace = ws
+	return &c
+}
+
+// forAgent returns a copy of the policy for the agent name, resolving paths
+// against its workspace and pushes without a refspec against its branch.
+func (p *Policy) forAgent(name string) *Policy {
+	c := p.forWorkspace(layoutOf(name).Workspace)
+	c.pushed = func() []string { return pushedBranches(name) }
+	return c
+}
+
+// pushedBranches returns the agent's checked-out branch and the branch its
+// push upstream names, as far as git can tell.
+func pushedBranches(name string) []string {
+	out, _ := podman("exec", containerName(name), "sh", "-c", layoutOf(name).cd()+
+		`git rev-parse --abbrev-ref HEAD; git rev-parse --abbrev-ref '@{push}' 2>/dev/null; true`).Output()
+	var branches []string
+	for i, line := range strings.Fields(string(out)) {
+		if i > 0 {
+			_, line, _ = strings.Cut(line, "/") // origin/main
+		}
+		if line != "" && line != "HEAD" {
+			branches = append(branches, line)
+		}
+	}
+	return branches
+}
+
+// Check returns the first rule the tool call breaks, or nil.
+func (p *Policy) Check(tool string, in toolInput) *PolicyViolation {
+	violation := func(rule, detail, reason string) *PolicyViolation {
+		return &PolicyViolation{Rule: rule, Tool: tool, Detail: detail, Reason: reason, Action: p.Action}
+	}
+
+	if in.Command != "" {
+		for _, re := range p.commands {
+			if re.MatchString(in.Command) {
+				return violation("command", in.Command, "matches forbidden pattern "+re.String())
+			}
+		}
+		if branch := p.pushTarget(in.Command); branch != "" {
+			return violation("push", in.Command, "push to protected branch "+branch)
+		}
+		for _, u := range urlPattern.FindAllString(in.Command, -1) {
+			if host := p.forbiddenHost(u); host != "" {
+				return violation("host", u, "forbidden host "+host)
+			}
+		}
+	}
+
+	if in.FilePath != "" {
+		file := in.FilePath
+		if !path.IsAbs(file) {
+			file = path.Join(p.workspace, file)
+		}
+		file = path.Clean(file)
+		for _, pattern := range p.paths {
+			if matchPath(pattern, file) {
+				return violation("path", file, "forbidden path "+pattern)
+			}
+		}
+		if writeTools[tool] && !p.isWritable(file) {
+			return violation("write", file, "write outside the workspace")
+		}
+	}
+
+	if in.URL != "" {
+		if host := p.forbiddenHost(in.URL); host != "" {
+			return violation("host", in.URL, "forbidden host "+host)
+		}
+	}
+	return nil
+}
+
+// pushTarget returns the protected branch a git push command targets, if any.
+func (p *Policy) pushTarget(command string) string {
+	for _, segment := range shellSeparators.Split(command, -1) {
+		fields := strings.Fields(segment)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] != "git" {
+				continue
+			}
+			j := i + 1
+			for j < len(fields) && (fields[j] == "-C" || fields[j] == "-c") {
+				j += 2
+			}
+			if j >= len(fields) || fields[j] != "push" {
+				continue
+			}
+			// After the remote come the refspecs; with none, or HEAD, git
+			// pushes the checked-out branch.
+			var refs []string
+			for _, arg := range fields[j+1:] {
+				if !strings.HasPrefix(arg, "-") {
+					refs = append(refs, arg)
+				}
+			}
+			if len(refs) > 0 {
+				refs = refs[1:]
+			}
+			current := len(refs) == 0
+			for _, arg := range refs {
+				ref := arg[strings.LastIndex(arg, ":")+1:]
+				ref = strings.TrimPrefix(strings.TrimPrefix(ref, "+"), "refs/heads/")
+				if ref == "HEAD" {
+					current = true
+				}
+				if b := p.protectedBranch(ref); b != "" {
+					return b
+				}
+			}
+			if current && p.pushed != nil {
+				for _, ref := range p.pushed() {
+					if b := p.protectedBranch(ref); b != "" {
+						return b
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// protectedBranch returns ref if it is a protected branch.
+func (p *Policy) protectedBranch(ref string) string {
+	for _, b := range p.protected {
+		if ref == b {
+			return b
+		}
+	}
+	return ""
+}
+
+func (p *Policy) forbiddenHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range p.hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return h
+		}
+	}
+	return ""
+}
+
+func (p *Policy) isWritable(file string) bool {
+	for _, dir := range append([]string{p.workspace}, p.writable...) {
+		dir = path.Clean(dir)
+		if file == dir || strings.HasPrefix(file, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches a forbidden-path pattern: a trailing slash means the
+// directory and everything in it, a pattern without a slash matches the base
+// name, anything else is a glob against the full path.
+func matchPath(pattern, file string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		dir := path.Clean(pattern)
+		return file == dir || strings.HasPrefix(file, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	ok, _ := path.Match(pattern, file)
+	return ok
+}
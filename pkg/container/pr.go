@@ -0,0 +1,62 @@
+package container
+
+import (
+	"fmt"
+
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+)
+
+// PROptions controls OpenPR.
+type PROptions struct {
+	Title string // default: the agent's intent, or its branch
+	Body  string // default: the agent's summary; added to Body when given
+	Base  string // default: the branch the agent started from
+	Draft bool
+}
+
+// OpenPR opens a pull request (a merge request on GitLab) for the agent's
+// branch on its repo's forge, with the agent's summary as the body when it
+// has one.
+func OpenPR(name string, opts PROptions) (*forge.PR, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	if agent.Repo == "" || agent.Branch == "" {
+		return nil, fmt.Errorf("%s has no repo or branch to open a PR for", name)
+	}
+	pr := forge.NewPR{Head: agent.Branch, Base: opts.Base, Title: opts.Title, Draft: opts.Draft}
+	if pr.Base == "" {
+		pr.Base = agent.BaseBranch
+	}
+	if pr.Title == "" {
+		pr.Title = agent.Intent
+	}
+	if pr.Title == "" {
+		pr.Title = agent.Branch
+	}
+	if agent.Summary != "" {
+		pr.Body = withSummary(opts.Body, agent.Summary)
+	} else if opts.Body != "" {
+		pr.Body = opts.Body
+	} else {
+		pr.Body = fmt.Sprintf("Opened by agentctl for agent %s.", name)
+	}
+	if agent.Issue != 0 {
+		pr.Body += fmt.Sprintf("\n\nCloses #%d", agent.Issue)
+	}
+	return forge.For(agent.Repo).CreatePR(agent.Repo, pr)
+}
+
+// CIStatus reports the CI result for the head of the agent's branch: one of
+// forge.CIPass, CIFail, CIPending or CINone.
+func CIStatus(name string) (string, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	if agent.Repo == "" || agent.Branch == "" {
+		return "", fmt.Errorf("%s has no repo or branch", name)
+	}
+	return forge.For(agent.Repo).CIStatus(agent.Repo, agent.Branch)
+}
@@ -0,0 +1,73 @@
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// pushHookTemplate reports an agent's pushes to the coordination bus. Git
+// has no post-push hook, so pre-push leaves a watcher behind for each ref:
+// once the push exits, it checks the remote-tracking branch moved to the
+// pushed commit — a rejected push leaves it alone — and only then appends
+// the event to the bus directory mounted from the host (@EVENTS@).
+const pushHookTemplate = `#!/bin/sh
+# Installed by agentctl: reports successful pushes to the coordination bus.
+events=@EVENTS@
+remote="$1"
+push=$PPID
+while read -r local_ref local_sha remote_ref remote_sha; do
+	case "$local_sha" in *[!0]*) ;; *) continue ;; esac
+	branch=${remote_ref#refs/heads/}
+	(
+		while kill -0 "$push" 2>/dev/null; do sleep 1; done
+		[ "$(git rev-parse -q --verify "refs/remotes/$remote/$branch")" = "$local_sha" ] || exit 0
+		printf '{"agent":"%s","branch":"%s","sha":"%s","at":"%s"}\n' \
+			"$AGENTCTL_AGENT" "$branch" "$local_sha" "$(date -u +%Y-%m-%dT%H:%M:%SZ)" >> "$events"
+	) </dev/null >/dev/null 2>&1 &
+done
+exit 0
+`
+
+// pushHookScript is the hook, writing to events inside the container.
+func pushHookScript(events string) string {
+	return strings.Replace(pushHookTemplate, "@EVENTS@", shellQuote(events), 1)
+}
+
+// installPushHook installs the pre-push hook in the agent's clone, where the
+// repo's own hooks path (core.hooksPath) says. A repo with a pre-push hook
+// of its own keeps it, and its pushes reach the bus only through
+// `agentctl notify`.
+func installPushHook(name, repo string, layout Layout) error {
+	dir, err := coordination.CoordDir(repo)
+	if err != nil {
+		return err
+	}
+	events := containerCoordRoot(layout) + "/" + filepath.Base(dir) + "/" + coordination.PushesFile
+	script := layout.cd() + `hook=$(git rev-parse --git-path hooks/pre-push) || exit 1
+if [ -e "$hook" ]; then echo "the repo has its own pre-push hook" >&2; exit 1; fi
+mkdir -p "$(dirname "$hook")" && cat > "$hook" && chmod +x "$hook"`
+	cmd := podman("exec", "-i", containerName(name), "sh", "-c", script)
+	cmd.Stdin = strings.NewReader(pushHookScript(events))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// relayPushes publishes the pushes agents' hooks recorded on repo's bus.
+func relayPushes(repoURL string) {
+	pushes, err := coordination.RelayPushes(repoURL)
+	if err != nil {
+		fmt.Printf("⚠️  Relaying pushes failed: %v\n", err)
+	}
+	for _, p := range pushes {
+		sha := p.SHA
+		if len(sha) > 12 {
+			sha = sha[:12]
+		}
+		fmt.Printf("📤 %s pushed %s (%s)\n", p.Agent, p.Branch, sha)
+	}
+}
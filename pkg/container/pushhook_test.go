@@ -0,0 +1,60 @@
+package container
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestPushHookScript(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote, work := t.TempDir(), t.TempDir()
+	events := filepath.Join(t.TempDir(), coordination.PushesFile)
+	hostGit(t, remote, "init", "-q", "--bare", "-b", "main")
+	hostGit(t, work, "init", "-q", "-b", "main")
+	hostGit(t, work, "remote", "add", "origin", remote)
+	os.WriteFile(filepath.Join(work, ".git", "hooks", "pre-push"), []byte(pushHookScript(events)), 0755)
+	t.Setenv("AGENTCTL_AGENT", "a1")
+
+	hostGit(t, work, "commit", "-q", "--allow-empty", "-m", "one")
+	hostGit(t, work, "push", "-q", "origin", "main:feature/x")
+	sha := hostGit(t, work, "rev-parse", "HEAD")
+
+	var data []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if data, _ = os.ReadFile(events); len(data) > 0 {
+			break
+		}
+	}
+	var e coordination.PushEvent
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &e); err != nil {
+		t.Fatalf("event %q: %v", data, err)
+	}
+	if e.Agent != "a1" || e.Branch != "feature/x" || e.SHA != sha || e.At.IsZero() {
+		t.Errorf("event = %+v", e)
+	}
+
+	// A rejected push is not reported.
+	os.Remove(events)
+	other := t.TempDir()
+	hostGit(t, other, "clone", "-q", remote, ".")
+	hostGit(t, other, "checkout", "-q", "feature/x")
+	hostGit(t, other, "commit", "-q", "--allow-empty", "-m", "theirs")
+	hostGit(t, other, "push", "-q", "origin", "feature/x")
+	hostGit(t, work, "commit", "-q", "--allow-empty", "-m", "two")
+	if err := exec.Command("git", "-C", work, "push", "-q", "origin", "main:feature/x").Run(); err == nil {
+		t.Fatal("push should have been rejected")
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if data, err := os.ReadFile(events); err == nil {
+		t.Errorf("rejected push reported: %s", data)
+	}
+}
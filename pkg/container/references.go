@@ -0,0 +1,185 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+)
+
+// containerReferenceRoot is where references are mounted, read-only, in
+// the agent's container.
+const containerReferenceRoot = "/references"
+
+// Reference is a reference mounted into an agent, as its prompt names it.
+type Reference struct {
+	Name string `json:"name"`
+	// Path is where it is in the container.
+	Path string `json:"path"`
+	// Source is the repo it was checked out from, with its ref; empty for
+	// a host directory.
+	Source      string `json:"source,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// referencesDir holds the checkouts of repo references on the host.
+func referencesDir() string {
+	return filepath.Join(cacheDir(), "references")
+}
+
+var referenceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// referenceNameOf is the reference's directory under /references.
+func referenceNameOf(r config.Reference) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	source := r.Repo
+	if source == "" {
+		source = filepath.ToSlash(r.Path)
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimSuffix(source, "/")), ".git")
+}
+
+// referencesFor keeps the references that apply to an agent on repo.
+func referencesFor(refs []config.Reference, repo string) []config.Reference {
+	var out []config.Reference
+	for _, r := range refs {
+		if len(r.Repos) == 0 {
+			out = append(out, r)
+			continue
+		}
+		for _, want := range r.Repos {
+			if repo != "" && repoKey(want) == repoKey(repo) {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// referenceMounts readies the references that apply to an agent on repo
+// and returns the podman run flags mounting them read-only, with what was
+// mounted. A reference that can't be had is skipped with a warning: the
+// agent can work without it.
+func referenceMounts(refs []config.Reference, repo string) ([]string, []Reference) {
+	var args []string
+	var mounted []Reference
+	seen := map[string]bool{}
+	for _, r := range referencesFor(refs, repo) {
+		name := referenceNameOf(r)
+		switch {
+		case !referenceName.MatchString(name):
+			fmt.Printf("⚠️  Reference %q skipped: name it with letters, digits, '.', '_' and '-'\n", name)
+			continue
+		case seen[name]:
+			fmt.Printf("⚠️  Reference %q skipped: another reference has that name\n", name)
+			continue
+		case (r.Repo == "") == (r.Path == ""):
+			fmt.Printf("⚠️  Reference %q skipped: give it a repo or a path\n", name)
+			continue
+		}
+		src, source := r.Path, ""
+		if r.Repo != "" {
+			var err error
+			if src, err = syncReference(name, r); err != nil {
+				fmt.Printf("⚠️  Reference %q skipped: %v\n", name, err)
+				continue
+			}
+			source = r.Repo
+			if r.Ref != "" {
+				source += "@" + r.Ref
+			}
+		} else if info, err := os.Stat(src); err != nil || !info.IsDir() {
+			fmt.Printf("⚠️  Reference %q skipped: %s is not a directory\n", name, src)
+			continue
+		}
+		seen[name] = true
+		dest := path.Join(containerReferenceRoot, name)
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro,z", src, dest))
+		mounted = append(mounted, Reference{Name: name, Path: dest, Source: source, Description: r.Description})
+	}
+	return args, mounted
+}
+
+// syncReference brings a repo reference's checkout on the host up to date
+// with its ref, cloning it the first time (or when its repo changed). A refresh that fails leaves the
+// last checkout in place.
+func syncReference(name string, r config.Reference) (string, error) {
+	dir := filepath.Join(referencesDir(), name)
+	if err := os.MkdirAll(referencesDir(), 0755); err != nil {
+		return "", err
+	}
+	unlock, err := lockFile(dir+".lock", longLockStale)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	host := forge.For(r.Repo)
+	auth := forge.AuthEnv(host, r.Repo, host.Token())
+	git := func(args ...string) error {
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), auth...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	// A reference pointed at another repo is checked out afresh.
+	origin, _ := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+	if strings.TrimSpace(string(origin)) == r.Repo {
+		ref := r.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		err := git("-C", dir, "fetch", "--quiet", "--depth", "1", "origin", ref)
+		if err == nil {
+			err = git("-C", dir, "reset", "--quiet", "--hard", "FETCH_HEAD")
+		}
+		if err != nil {
+			fmt.Printf("⚠️  Reference %q not refreshed, using the last checkout: %v\n", name, err)
+		}
+		return dir, nil
+	}
+
+	fmt.Printf("📚 Checking out reference %s\n", r.Repo)
+	os.RemoveAll(dir)
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if r.Ref != "" {
+		args = append(args, "--branch", r.Ref)
+	}
+	if err := git(append(args, r.Repo, dir)...); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// referenceNote lists an agent's references for its prompt.
+func referenceNote(refs []Reference) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Reference material is mounted read-only for you to read; it is not part of the repo you are working on, so don't edit or copy it in wholesale:\n")
+	for _, r := range refs {
+		b.WriteString("- " + r.Path)
+		if r.Source != "" {
+			b.WriteString(" (" + r.Source + ")")
+		}
+		if r.Description != "" {
+			b.WriteString(": " + r.Description)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestReferencesFor(t *testing.T) {
+	refs := []config.Reference{
+		{Name: "style", Path: "/docs/style"},
+		{Repo: "https://github.com/org/billing", Repos: []string{"org/api"}},
+	}
+	if got := referencesFor(refs, "https://github.com/org/api.git"); len(got) != 2 {
+		t.Errorf("an agent on org/api gets %d references, want 2", len(got))
+	}
+	if got := referencesFor(refs, "https://github.com/org/web"); len(got) != 1 || got[0].Name != "style" {
+		t.Errorf("an agent on org/web gets %+v, want only style", got)
+	}
+	if name := referenceNameOf(refs[1]); name != "billing" {
+		t.Errorf("referenceNameOf() = %q, want billing", name)
+	}
+}
+
+func TestReferenceMounts(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	// A local repo stands in for the remote.
+	remote := filepath.Join(t.TempDir(), "billing")
+	for _, args := range [][]string{
+		{"init", "--quiet", "-b", "main", remote},
+		{"-C", remote, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "--quiet", "--allow-empty", "-m", "one"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	docs := t.TempDir()
+
+	args, mounted := referenceMounts([]config.Reference{
+		{Repo: remote, Ref: "main", Description: "the billing service"},
+		{Name: "style", Path: docs},
+		{Name: "style", Path: docs},
+		{Name: "gone", Path: filepath.Join(docs, "missing")},
+		{Name: "../escape", Path: docs},
+	}, "https://github.com/org/api")
+
+	checkout := filepath.Join(referencesDir(), "billing")
+	want := []string{"-v", checkout + ":/references/billing:ro,z", "-v", docs + ":/references/style:ro,z"}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+	if _, err := os.Stat(filepath.Join(checkout, ".git")); err != nil {
+		t.Errorf("the repo reference wasn't checked out: %v", err)
+	}
+	if len(mounted) != 2 || mounted[0].Source != remote+"@main" || mounted[1].Source != "" {
+		t.Fatalf("mounted = %+v", mounted)
+	}
+
+	// The next spawn refreshes the checkout.
+	exec.Command("git", "-C", remote, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "--quiet", "--allow-empty", "-m", "two").Run()
+	referenceMounts([]config.Reference{{Repo: remote, Ref: "main"}}, "")
+	if out, _ := exec.Command("git", "-C", checkout, "log", "-1", "--format=%s").Output(); strings.TrimSpace(string(out)) != "two" {
+		t.Errorf("checkout at %q, want the new commit", strings.TrimSpace(string(out)))
+	}
+
+	note := referenceNote(mounted)
+	if !strings.Contains(note, "- /references/billing ("+remote+"@main): the billing service\n") || !strings.Contains(note, "- /references/style\n") {
+		t.Errorf("referenceNote() = %q", note)
+	}
+}
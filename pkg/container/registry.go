@@ -0,0 +1,93 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// imageRegistry returns the registry an image reference names, or "" for
+// an unqualified one (a local build, or whatever podman's search registries
+// resolve it to). As in podman, the first component is a registry when it
+// has a dot or a port, or is localhost.
+func imageRegistry(image string) string {
+	first, _, ok := strings.Cut(image, "/")
+	if !ok || !(strings.ContainsAny(first, ".:") || first == "localhost") {
+		return ""
+	}
+	return first
+}
+
+// registryFor returns the configured registry an image is pulled from.
+func registryFor(registries []config.Registry, image string) *config.Registry {
+	host := imageRegistry(image)
+	for i, r := range registries {
+		if host != "" && strings.EqualFold(strings.TrimSuffix(r.Host, "/"), host) {
+			return &registries[i]
+		}
+	}
+	return nil
+}
+
+// ensureImage makes sure image is present locally, pulling it when it isn't
+// with the credentials configured for its registry. A failed pull returns
+// ErrImageUnavailable with podman's reason and what to do about it.
+func ensureImage(cfg *config.Config, image string) error {
+	if podman("image", "exists", image).Run() == nil {
+		return nil
+	}
+	args := []string{"pull", "--quiet"}
+	reg := registryFor(cfg.Registries, image)
+	if reg != nil {
+		authArgs, err := registryLogin(reg)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrImageUnavailable, image, err)
+		}
+		args = append(args, authArgs...)
+	}
+	fmt.Printf("📥 Pulling %s...\n", image)
+	out, err := podmanLong(append(args, image)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s is not present locally and pulling it failed: %s%s",
+			ErrImageUnavailable, image, strings.TrimSpace(string(out)), imageHint(image, reg))
+	}
+	return nil
+}
+
+// registryLogin returns the pull flags for reg's auth file, or logs in to
+// reg with its username and password.
+func registryLogin(reg *config.Registry) ([]string, error) {
+	if reg.AuthFile != "" {
+		if _, err := os.Stat(reg.AuthFile); err != nil {
+			return nil, fmt.Errorf("auth file for %s: %w", reg.Host, err)
+		}
+		return []string{"--authfile", reg.AuthFile}, nil
+	}
+	if reg.Username == "" {
+		return nil, nil
+	}
+	if reg.Password == "" {
+		return nil, fmt.Errorf("registry %s has a username but no password", reg.Host)
+	}
+	cmd := podman("login", "--username", reg.Username, "--password-stdin", reg.Host)
+	cmd.Stdin = strings.NewReader(reg.Password)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("login to %s failed: %s", reg.Host, strings.TrimSpace(string(out)))
+	}
+	return nil, nil
+}
+
+// imageHint says how to make an image that failed to pull available.
+func imageHint(image string, reg *config.Registry) string {
+	switch {
+	case image == DefaultImage:
+		return "\n  hint: build it from agentctl's Dockerfile: podman build -t " + DefaultImage + " ."
+	case reg == nil && imageRegistry(image) != "":
+		return fmt.Sprintf("\n  hint: if %s is private, add it to \"registries\" in the config with credentials or an auth_file", imageRegistry(image))
+	case reg != nil:
+		return "\n  hint: check the credentials configured for " + reg.Host
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestImageRegistry(t *testing.T) {
+	for image, want := range map[string]string{
+		"agent-devbox:latest":                        "",
+		"acme/agent:1":                               "",
+		"localhost/agent-devbox:latest":              "localhost",
+		"registry.internal:5000/agents/hardened:2.1": "registry.internal:5000",
+		"ghcr.io/acme/agent@sha256:abc":              "ghcr.io",
+	} {
+		if got := imageRegistry(image); got != want {
+			t.Errorf("imageRegistry(%q) = %q, want %q", image, got, want)
+		}
+	}
+	regs := []config.Registry{{Host: "GHCR.io"}, {Host: "registry.internal:5000"}}
+	if r := registryFor(regs, "ghcr.io/acme/agent:1"); r == nil || r.Host != "GHCR.io" {
+		t.Errorf("registryFor(ghcr) = %+v", r)
+	}
+	if r := registryFor(regs, "acme/agent:1"); r != nil {
+		t.Errorf("registryFor(unqualified) = %+v", r)
+	}
+}
+
+func TestEnsureImage(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	fakePodman(t, `echo "$@" >> `+calls+`
+case "$1 $2" in
+"image exists") [ "$3" = present:1 ] ;;
+"login --username") cat > `+filepath.Join(dir, "password")+` ;;
+"pull --quiet") case "$*" in *missing*) echo "manifest unknown" >&2; exit 125 ;; esac ;;
+esac`)
+	authFile := filepath.Join(dir, "auth.json")
+	os.WriteFile(authFile, []byte("{}"), 0600)
+	cfg := &config.Config{Registries: []config.Registry{
+		{Host: "registry.internal", Username: "bot", Password: "s3cret"},
+		{Host: "ghcr.io", AuthFile: authFile},
+	}}
+
+	if err := ensureImage(cfg, "present:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureImage(cfg, "registry.internal/agents/hardened:2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureImage(cfg, "ghcr.io/acme/agent:1"); err != nil {
+		t.Fatal(err)
+	}
+	log, _ := os.ReadFile(calls)
+	for _, want := range []string{
+		"login --username bot --password-stdin registry.internal",
+		"pull --quiet registry.internal/agents/hardened:2",
+		"pull --quiet --authfile " + authFile + " ghcr.io/acme/agent:1",
+	} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("podman calls lack %q:\n%s", want, log)
+		}
+	}
+	if strings.Contains(string(log), "pull --quiet present:1") {
+		t.Error("a local image should not be pulled")
+	}
+	if pw, _ := os.ReadFile(filepath.Join(dir, "password")); string(pw) != "s3cret" {
+		t.Errorf("password on stdin = %q", pw)
+	}
+
+	err := ensureImage(cfg, "quay.io/acme/missing:1")
+	if !errors.Is(err, ErrImageUnavailable) || !strings.Contains(err.Error(), "manifest unknown") || !strings.Contains(err.Error(), `"registries"`) {
+		t.Errorf("ensureImage(missing) = %v", err)
+	}
+}
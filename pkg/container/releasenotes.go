@@ -0,0 +1,114 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+)
+
+// DefaultChangelog is the file release notes are written to.
+const DefaultChangelog = "CHANGELOG.md"
+
+// releaseNotesPrompt is the release-notes agent's task. Its arguments are
+// the repo, the range of commits, where it starts and the changelog file.
+const releaseNotesPrompt = `Write the release notes for %s: a changelog entry for everything merged %s.
+
+1. List the merged pull requests in the range: ` + "`git log --first-parent --format='%%h %%s' %s`" + ` shows
+   the merge and squash commits; where ` + "`gh`" + ` works, ` + "`gh pr view <number>`" + ` gives a PR's
+   description and labels. Read the diff of anything whose title doesn't say what changed.
+2. Add one entry to the top of %s, keeping the format it already has (Keep a Changelog
+   if it is new or empty), under an "Unreleased" heading — do not pick a version number.
+3. Group the changes under Added, Changed, Deprecated, Removed, Fixed and Security, leaving
+   empty groups out. One line per change, written for users of the project rather than its
+   developers, ending with the PR reference, e.g. "(#123)". Merge related PRs into one line;
+   leave out changes users can't see (CI, refactors, tests, dependency bumps without effect).
+4. Call out breaking changes first, under "Breaking", with what users have to do.
+
+Change nothing but %s. Commit it with the message "docs(changelog): %s".`
+
+// releaseNotesPRBody is the description of the release-notes PR.
+const releaseNotesPRBody = `Changelog entry for the changes merged %s, written by agentctl's release-notes agent.
+
+Before merging:
+- check each line against the PR it cites, and that nothing user-facing is missing
+- pick the version number and replace the "Unreleased" heading with it`
+
+// ReleaseNotesOptions controls ReleaseNotes. Zero values take the defaults.
+type ReleaseNotesOptions struct {
+	// Name names the agent (default "release-notes").
+	Name string
+	// Since is the tag (or any revision) to start from; default the latest
+	// tag reachable from the default branch, or the whole history.
+	Since string
+	// File is the changelog (default DefaultChangelog).
+	File        string
+	Image       string
+	MaxAttempts int
+	Draft       bool
+}
+
+// releaseNotesTask returns the agent's task for the changes merged after
+// the since revision (all of them when it is empty), and how it describes
+// that range.
+func releaseNotesTask(repo, since, file string) (string, string) {
+	revs, desc := since+"..HEAD", "since "+since
+	if since == "" {
+		revs, desc = "HEAD", "so far"
+	}
+	return fmt.Sprintf(releaseNotesPrompt, repo, desc, revs, file, file, "release notes "+desc), desc
+}
+
+// ReleaseNotes spawns an agent on repo's default branch to write a changelog
+// entry for the PRs merged since the last tag, runs it until the changelog
+// has changed, pushes its branch and opens a PR for the entry.
+func ReleaseNotes(repo string, opts ReleaseNotesOptions) (*forge.PR, error) {
+	if opts.Name == "" {
+		opts.Name = "release-notes"
+	}
+	if opts.File == "" {
+		opts.File = DefaultChangelog
+	}
+	name := opts.Name
+	fmt.Printf("🚀 Spawning %s on %s\n", name, repo)
+	if _, err := SpawnWithOptions(SpawnOptions{
+		Name: name, Repo: repo, Image: opts.Image, Intent: "Release notes", Template: "release-notes",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := startBranch(name, "release-notes/"+time.Now().Format("20060102")); err != nil {
+		return nil, err
+	}
+	git := agentGit(name)
+	head, err := git(nil, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s's HEAD: %w", name, err)
+	}
+	head = strings.TrimSpace(head)
+	if opts.Since == "" {
+		tag, err := git(nil, "describe", "--tags", "--abbrev=0")
+		if err == nil {
+			opts.Since = strings.TrimSpace(tag)
+		}
+	}
+	task, since := releaseNotesTask(repo, opts.Since, opts.File)
+	fmt.Printf("📝 Changelog entry for everything merged %s\n", since)
+
+	// Docs don't move the tests: done is a committed change to the changelog.
+	doneWhen := "test -n \"$(git diff --name-only " + shellQuote(head) + " HEAD -- " + shellQuote(opts.File) + ")\""
+	if _, err := RunWithOptions(name, task, opts.MaxAttempts, RunOptions{DoneWhen: doneWhen}); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("⬆️  Pushing %s\n", name)
+	if err := Push(name, true); err != nil {
+		return nil, err
+	}
+	return OpenPR(name, PROptions{
+		Title: "docs(changelog): release notes " + since,
+		Body:  fmt.Sprintf(releaseNotesPRBody, since),
+		Draft: opts.Draft,
+	})
+}
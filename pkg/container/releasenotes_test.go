@@ -0,0 +1,28 @@
+package container
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseNotesTask(t *testing.T) {
+	task, since := releaseNotesTask("acme/app", "v1.4.0", "CHANGELOG.md")
+	if since != "since v1.4.0" {
+		t.Errorf("since = %q", since)
+	}
+	for _, want := range []string{
+		"everything merged since v1.4.0",
+		"`git log --first-parent --format='%h %s' v1.4.0..HEAD`",
+		"top of CHANGELOG.md",
+		`"docs(changelog): release notes since v1.4.0"`,
+	} {
+		if !strings.Contains(task, want) {
+			t.Errorf("%q missing from task:\n%s", want, task)
+		}
+	}
+
+	task, since = releaseNotesTask("acme/app", "", "docs/CHANGES.md")
+	if since != "so far" || !strings.Contains(task, "%h %s' HEAD`") || !strings.Contains(task, "Change nothing but docs/CHANGES.md.") {
+		t.Errorf("untagged repo: %q\n%s", since, task)
+	}
+}
@@ -0,0 +1,139 @@
+package container
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// orphanAge is how long a bus entry of an agent unknown here must have been
+// quiet before SweepOrphans removes it, so agents on other machines sharing
+// the bus are left alone.
+var orphanAge = DefaultGracePeriod
+
+// OnRepo reports whether the agent works on repo, given as a URL or as
+// owner/name.
+func (a *Agent) OnRepo(repo string) bool {
+	return a.Repo != "" && repoKey(a.Repo) == repoKey(repo)
+}
+
+func repoKey(repo string) string {
+	return strings.ToLower(config.RepoKey(strings.TrimSuffix(strings.TrimSpace(repo), "/")))
+}
+
+// AgentsOnRepo keeps the agents working on repo; an empty repo keeps all.
+func AgentsOnRepo(agents []*AgentWithState, repo string) []*AgentWithState {
+	if repo == "" {
+		return agents
+	}
+	var out []*AgentWithState
+	for _, a := range agents {
+		if a.OnRepo(repo) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// KillRepo kills every agent on repo and returns their names.
+func KillRepo(repo string) []string {
+	var killed []string
+	for _, a := range agentsOnRepo(repo) {
+		Kill(a.Name)
+		killed = append(killed, a.Name)
+	}
+	return killed
+}
+
+// busURLs returns the coordination buses for repo: the URL each of its
+// agents (live or in history) was spawned with, and repo itself when it is
+// a URL.
+func busURLs(repo string) []string {
+	seen := map[string]bool{}
+	var urls []string
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	if strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@") {
+		add(repo)
+	}
+	for _, a := range agentsOnRepo(repo) {
+		add(a.Repo)
+	}
+	for _, h := range historyOnRepo(repo) {
+		add(h.Repo)
+	}
+	return urls
+}
+
+func historyOnRepo(repo string) []*AgentHistory {
+	records, _ := FindHistory(HistoryQuery{Repo: repo})
+	return records
+}
+
+// SweepOrphans removes repo's bus entries — state and claims — of agents
+// that no longer exist in this namespace and haven't been heard from (a
+// state update, heartbeat or claim) for orphanAge. It returns the agents removed.
+func SweepOrphans(repo string) ([]string, error) {
+	local := map[string]bool{}
+	for _, a := range loadAgents() {
+		local[a.Name] = true
+	}
+	var swept []string
+	for _, url := range busURLs(repo) {
+		dir, err := coordination.CoordDir(url)
+		if err != nil {
+			return swept, err
+		}
+		if _, err := os.Stat(dir); err != nil {
+			continue // no bus for this URL
+		}
+		state, err := coordination.GetState(url)
+		if err != nil {
+			return swept, err
+		}
+		claims, err := coordination.ListClaims(url)
+		if err != nil {
+			return swept, err
+		}
+		for _, name := range orphans(state, claims, local, time.Now()) {
+			if _, err := coordination.RemoveAgent(url, name, "orphaned"); err != nil {
+				return swept, err
+			}
+			swept = append(swept, name)
+		}
+	}
+	return swept, nil
+}
+
+// orphans picks the agents on a bus that are not local and quiet for
+// orphanAge.
+func orphans(state *coordination.State, claims coordination.Claims, local map[string]bool, now time.Time) []string {
+	last := map[string]time.Time{}
+	heard := func(name string, at time.Time) {
+		if at.After(last[name]) || last[name].IsZero() {
+			last[name] = at
+		}
+	}
+	for name, s := range state.Agents {
+		heard(name, s.LastSeen())
+	}
+	for _, c := range claims {
+		heard(c.Agent, c.ClaimedAt)
+	}
+	var out []string
+	for name, at := range last {
+		if !local[name] && now.Sub(at) >= orphanAge {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,87 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+func TestOnRepo(t *testing.T) {
+	a := &Agent{Repo: "https://github.com/Org/API.git"}
+	for _, repo := range []string{"https://github.com/org/api", "org/api", "git@github.com:org/api.git", "https://github.com/org/api/"} {
+		if !a.OnRepo(repo) {
+			t.Errorf("OnRepo(%q) = false", repo)
+		}
+	}
+	if a.OnRepo("org/api-v2") || (&Agent{}).OnRepo("") {
+		t.Error("other repos should not match")
+	}
+
+	agents := []*AgentWithState{{Agent: &Agent{Name: "a1", Repo: "https://github.com/org/api"}}, {Agent: &Agent{Name: "w1", Repo: "https://github.com/org/web"}}}
+	if got := AgentsOnRepo(agents, "org/web"); len(got) != 1 || got[0].Name != "w1" {
+		t.Errorf("AgentsOnRepo = %v", got)
+	}
+	if got := AgentsOnRepo(agents, ""); len(got) != 2 {
+		t.Error("no repo should keep every agent")
+	}
+}
+
+func TestSweepOrphans(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/test/sweep"
+	if _, err := coordination.Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	saveAgent(&Agent{Name: "live", Repo: repo})
+	coordination.UpdateAgentState(repo, "live", "working", "main")
+	coordination.UpdateAgentState(repo, "gone", "working", "main")
+	coordination.ClaimFile(repo, "gone", "src/a.go")
+	coordination.ClaimFile(repo, "remote", "src/b.go")
+
+	// Everything was just heard from: nothing is swept yet.
+	if swept, err := SweepOrphans("test/sweep"); err != nil || len(swept) != 0 {
+		t.Fatalf("fresh entries swept: %v, %v", swept, err)
+	}
+
+	orig := orphanAge
+	orphanAge = 0
+	defer func() { orphanAge = orig }()
+	swept, err := SweepOrphans("test/sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gone", "remote"}; !reflect.DeepEqual(swept, want) {
+		t.Errorf("swept = %v, want %v", swept, want)
+	}
+	state, _ := coordination.GetState(repo)
+	if _, ok := state.Agents["live"]; !ok || len(state.Agents) != 1 {
+		t.Errorf("only the live agent should stay on the bus: %v", state.Agents)
+	}
+	if claims, _ := coordination.ListClaims(repo); len(claims) != 0 {
+		t.Errorf("orphaned claims should be released: %v", claims)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	now := time.Now()
+	state := &coordination.State{Agents: map[string]*coordination.AgentState{
+		"old":   {Name: "old", LastUpdate: now.Add(-2 * time.Hour)},
+		"local": {Name: "local", LastUpdate: now.Add(-2 * time.Hour)},
+	}}
+	// A recent claim means the agent is still around somewhere.
+	claims := coordination.Claims{"x.go": {Agent: "old", ClaimedAt: now.Add(-time.Minute)}}
+	if got := orphans(state, claims, map[string]bool{"local": true}, now); len(got) != 0 {
+		t.Errorf("orphans = %v, want none", got)
+	}
+	delete(claims, "x.go")
+	if got := orphans(state, claims, map[string]bool{"local": true}, now); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("orphans = %v, want [old]", got)
+	}
+}
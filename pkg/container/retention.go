@@ -0,0 +1,206 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// RetentionPolicy is how long cleanup keeps finished agents, by how they
+// finished, and their history.
+type RetentionPolicy struct {
+	Completed time.Duration // task done
+	Failed    time.Duration // run gave up
+	Killed    time.Duration // container exited or gone
+	// MaxContainers caps the finished agents kept (0: no cap).
+	MaxContainers int
+	// History is how long history records are kept (0: forever).
+	History time.Duration
+}
+
+// LoadRetention reads the retention policy from config, defaulting every
+// grace period to DefaultGracePeriod.
+func LoadRetention() (RetentionPolicy, error) {
+	p := RetentionPolicy{Completed: DefaultGracePeriod, Failed: DefaultGracePeriod, Killed: DefaultGracePeriod}
+	cfg, err := config.Load()
+	if err != nil {
+		return p, nil
+	}
+	r := cfg.Retention
+	p.MaxContainers = r.MaxContainers
+	for _, f := range []struct {
+		key, value string
+		into       *time.Duration
+	}{
+		{"completed", r.Completed, &p.Completed},
+		{"failed", r.Failed, &p.Failed},
+		{"killed", r.Killed, &p.Killed},
+		{"history", r.History, &p.History},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := ParseAge(f.value)
+		if err != nil {
+			return p, fmt.Errorf("retention.%s: %w", f.key, err)
+		}
+		*f.into = d
+	}
+	return p, nil
+}
+
+// WithGrace returns p with every grace period set to d.
+func (p RetentionPolicy) WithGrace(d time.Duration) RetentionPolicy {
+	p.Completed, p.Failed, p.Killed = d, d, d
+	return p
+}
+
+// HistoryPruned is the Reason of a history record PruneHistory deleted.
+const HistoryPruned = "history past retention"
+
+// Retired is an agent or history record cleanup removed, and why.
+type Retired struct {
+	Name   string
+	Result string // as recorded in history
+	Reason string
+}
+
+// finishedAgent is a live agent cleanup may remove.
+type finishedAgent struct {
+	name     string
+	result   string // history result: success, failed or stale
+	finished time.Time
+	grace    time.Duration
+	history  *AgentHistory // the run loop's record, if any
+}
+
+// finishedAgents classifies the agents that aren't working or paused.
+// A run that ended leaves a history record saying how, and when. A running
+// container with no Claude process is only finished once such a record
+// says so: until then it may be freshly spawned, between attempts or
+// waiting on --after. An agent a live run holds, or with a checkpoint newer
+// than its history, is never finished; Cleanup drops the checkpoint of one
+// that is.
+func finishedAgents(agents []*AgentWithState, p RetentionPolicy) []finishedAgent {
+	var out []finishedAgent
+	for _, a := range agents {
+		if CheckRunLock(a.Name) != nil {
+			continue
+		}
+		f := finishedAgent{name: a.Name, finished: a.Created}
+		if h, err := LoadHistory(a.Name); err == nil && !h.CompletedAt.Before(a.Created) {
+			f.history, f.finished = h, h.CompletedAt
+		}
+		// A run that aborted keeps its checkpoint for --resume but records
+		// its failure after it; only a checkpoint newer than the history is
+		// a run still to come back to.
+		// One that can't be read (its key is missing) is kept too.
+		if cp, err := LoadCheckpoint(a.Name); err == nil && (f.history == nil || f.history.CompletedAt.Before(cp.Updated)) {
+			continue
+		} else if err != nil && hasCheckpoint(a.Name) {
+			continue
+		}
+		switch {
+		case a.Lifecycle == StateExited || a.Lifecycle == StateStopped:
+			f.result, f.grace = "stale", p.Killed
+		case a.Lifecycle != StateCompleted || f.history == nil:
+			continue
+		case f.history.Result == "failed":
+			f.result, f.grace = "failed", p.Failed
+		default:
+			f.result, f.grace = "success", p.Completed
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// ApplyRetention removes the finished agents past their grace period, then
+// the oldest others beyond MaxContainers, then history older than
+// p.History. A repo limits the agents to that repo's; history is pruned
+// across the namespace.
+func ApplyRetention(p RetentionPolicy, repo string) ([]Retired, error) {
+	agents, err := ListWithState()
+	if err != nil {
+		return nil, err
+	}
+	finished := finishedAgents(AgentsOnRepo(agents, repo), p)
+	sort.Slice(finished, func(i, j int) bool { return finished[i].finished.Before(finished[j].finished) })
+
+	var retired []Retired
+	var kept []finishedAgent
+	retire := func(f finishedAgent, reason string) {
+		attempts, metadata := 0, map[string]string(nil)
+		if f.history != nil {
+			attempts, metadata = f.history.Attempts, f.history.Metadata
+		}
+		if err := Cleanup(f.name, f.result, attempts, metadata); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to cleanup %s: %v\n", f.name, err)
+			return
+		}
+		retired = append(retired, Retired{Name: f.name, Result: f.result, Reason: reason})
+	}
+	for _, f := range finished {
+		if time.Since(f.finished) > f.grace {
+			retire(f, "past the "+retentionLabel(f.result)+" grace period")
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	if p.MaxContainers > 0 && len(kept) > p.MaxContainers {
+		for _, f := range kept[:len(kept)-p.MaxContainers] {
+			retire(f, fmt.Sprintf("over max_containers (%d)", p.MaxContainers))
+		}
+	}
+
+	if p.History > 0 {
+		pruned, err := PruneHistory(p.History)
+		if err != nil {
+			return retired, err
+		}
+		retired = append(retired, pruned...)
+	}
+	return retired, nil
+}
+
+func retentionLabel(result string) string {
+	switch result {
+	case "failed":
+		return "failed"
+	case "stale":
+		return "killed"
+	}
+	return "completed"
+}
+
+// PruneHistory deletes the history of agents removed more than olderThan
+// ago, with any patch saved alongside. Records of live agents are kept.
+func PruneHistory(olderThan time.Duration) ([]Retired, error) {
+	records, err := ListHistory()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var pruned []Retired
+	for _, h := range records {
+		at := h.RemovedAt
+		if at.IsZero() {
+			at = h.CompletedAt
+		}
+		if at.IsZero() || at.After(cutoff) {
+			continue
+		}
+		if _, err := loadAgent(h.Name); err == nil {
+			continue
+		}
+		if err := deleteHistory(h.Name); err != nil {
+			return pruned, err
+		}
+		os.Remove(PatchPath(h.Name))
+		pruned = append(pruned, Retired{Name: h.Name, Result: h.Result, Reason: HistoryPruned})
+	}
+	return pruned, nil
+}
@@ -0,0 +1,157 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadRetention(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	p, err := LoadRetention()
+	if err != nil || p.Completed != DefaultGracePeriod || p.Failed != DefaultGracePeriod || p.Killed != DefaultGracePeriod || p.History != 0 {
+		t.Errorf("default policy = %+v, %v", p, err)
+	}
+
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"),
+		[]byte(`{"retention": {"failed": "3d", "killed": "30m", "max_containers": 5, "history": "90d"}}`), 0644)
+	p, err = LoadRetention()
+	want := RetentionPolicy{Completed: time.Hour, Failed: 72 * time.Hour, Killed: 30 * time.Minute, MaxContainers: 5, History: 90 * 24 * time.Hour}
+	if err != nil || p != want {
+		t.Errorf("policy = %+v, %v; want %+v", p, err, want)
+	}
+	if p := p.WithGrace(time.Minute); p.Completed != time.Minute || p.Failed != time.Minute || p.Killed != time.Minute {
+		t.Errorf("WithGrace = %+v", p)
+	}
+
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"retention": {"failed": "a week"}}`), 0644)
+	if _, err := LoadRetention(); err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fakePodman(t, `case "$1" in
+ps) echo '[{"Names":["busy"],"State":"running"},{"Names":["done-old"],"State":"running"},{"Names":["done-new"],"State":"running"},{"Names":["failed"],"State":"running"},{"Names":["dead"],"State":"exited"}]' ;;
+exec) [ "$2" = busy ] && echo "agent 1 claude" ;;
+esac
+true`)
+	now := time.Now()
+	created := now.Add(-5 * time.Hour)
+	for _, name := range []string{"busy", "done-old", "done-new", "failed", "dead"} {
+		saveAgent(&Agent{Name: name, Created: created})
+	}
+	SaveHistory(&AgentHistory{Name: "done-old", Created: created, CompletedAt: now.Add(-2 * time.Hour), Result: "success",
+		Attempts: 3, Metadata: map[string]string{"summary": "Fixed it"}})
+	SaveHistory(&AgentHistory{Name: "done-new", Created: created, CompletedAt: now.Add(-10 * time.Minute), Result: "success"})
+	SaveHistory(&AgentHistory{Name: "failed", Created: created, CompletedAt: now.Add(-2 * time.Hour), Result: "failed"})
+	SaveHistory(&AgentHistory{Name: "ancient", RemovedAt: now.Add(-100 * 24 * time.Hour), Result: "killed"})
+	os.WriteFile(PatchPath("ancient"), []byte("diff"), 0644)
+
+	policy := RetentionPolicy{Completed: time.Hour, Failed: 72 * time.Hour, Killed: time.Hour}
+	retired, err := ApplyRetention(policy, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range retired {
+		got[r.Name] = r.Result
+	}
+	if len(got) != 2 || got["done-old"] != "success" || got["dead"] != "stale" {
+		t.Errorf("retired = %+v, want done-old and dead", retired)
+	}
+	if h, err := LoadHistory("done-old"); err != nil || h.Attempts != 3 || h.Metadata["summary"] != "Fixed it" || h.RemovedAt.IsZero() {
+		t.Errorf("done-old's history lost the run's record: %+v, %v", h, err)
+	}
+
+	// Over the cap, the oldest finished agent goes: failed, not done-new or busy.
+	policy.MaxContainers, policy.History = 1, 90*24*time.Hour
+	retired, err = ApplyRetention(policy, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 2 || retired[0].Name != "failed" || retired[1].Name != "ancient" || retired[1].Reason != HistoryPruned {
+		t.Errorf("retired = %+v, want failed, then ancient's history", retired)
+	}
+	if _, err := LoadHistory("ancient"); err == nil {
+		t.Error("ancient history should be pruned")
+	}
+	if _, err := os.Stat(PatchPath("ancient")); err == nil {
+		t.Error("ancient patch should be pruned")
+	}
+	for _, name := range []string{"busy", "done-new"} {
+		if _, err := loadAgent(name); err != nil {
+			t.Errorf("%s should be kept: %v", name, err)
+		}
+	}
+}
+
+func TestApplyRetentionKeepsLiveAgents(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// Running containers with no Claude process, all older than the grace
+	// period.
+	fakePodman(t, `case "$1" in
+ps) echo '[{"Names":["locked"],"State":"running"},{"Names":["spawned"],"State":"running"},{"Names":["resumable"],"State":"running"}]' ;;
+esac
+true`)
+	now := time.Now()
+	created := now.Add(-5 * time.Hour)
+	for _, name := range []string{"locked", "spawned", "resumable"} {
+		saveAgent(&Agent{Name: name, Created: created})
+	}
+	// An earlier run of each finished long ago; "spawned" was never run.
+	for _, name := range []string{"locked", "resumable"} {
+		SaveHistory(&AgentHistory{Name: name, Created: created, CompletedAt: now.Add(-2 * time.Hour), Result: "success"})
+	}
+	release, err := acquireRunLock("locked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	saveCheckpoint(&Checkpoint{Agent: "resumable", Task: "fix login", Attempt: 1, MaxAttempts: 3, LoopStart: now})
+
+	retired, err := ApplyRetention(RetentionPolicy{Completed: time.Hour, Failed: time.Hour, Killed: time.Hour, MaxContainers: 1}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 0 {
+		t.Errorf("retired = %+v, want every agent kept", retired)
+	}
+}
+
+func TestApplyRetentionRetiresAbortedRuns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	fakePodman(t, `case "$1" in
+ps) echo '[{"Names":["aborted"],"State":"running"}]' ;;
+esac
+true`)
+	created := time.Now().Add(-5 * time.Hour)
+	saveAgent(&Agent{Name: "aborted", Created: created})
+	// The run saved its checkpoint, then aborted and recorded the failure.
+	saveCheckpoint(&Checkpoint{Agent: "aborted", Task: "fix login", Attempt: 1, MaxAttempts: 3, LoopStart: created})
+	SaveHistory(&AgentHistory{Name: "aborted", Created: created, CompletedAt: time.Now(), Result: "failed"})
+
+	retired, err := ApplyRetention(RetentionPolicy{Completed: time.Hour, Failed: time.Nanosecond, Killed: time.Hour}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retired) != 1 || retired[0].Name != "aborted" || retired[0].Result != "failed" {
+		t.Errorf("retired = %+v, want the aborted run", retired)
+	}
+	if _, err := LoadCheckpoint("aborted"); err == nil {
+		t.Error("the aborted run's checkpoint should go with it")
+	}
+}
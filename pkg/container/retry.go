@@ -0,0 +1,63 @@
+package container
+
+import (
+	"errors"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Retry policy for idempotent runtime calls. Variables so tests can shrink them.
+var (
+	retryAttempts  = 3
+	retryBaseDelay = 250 * time.Millisecond
+)
+
+// transientMarkers are podman stderr fragments that indicate a short-lived
+// runtime problem (lock contention, conmon hiccups) rather than a real answer.
+var transientMarkers = []string{
+	"database is locked",
+	"error acquiring lock",
+	"resource temporarily unavailable",
+	"layer not known",
+	"conmon",
+	"storage driver",
+	"try again",
+}
+
+// isTransient reports whether err is a podman-level failure worth retrying.
+// Exit code 125 is podman itself failing; anything else is the answer from
+// the command we ran (e.g. `test -f` returning 1) and must not be retried.
+func isTransient(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 125 {
+		return false
+	}
+	stderr := strings.ToLower(string(exitErr.Stderr))
+	for _, m := range transientMarkers {
+		if strings.Contains(stderr, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// podmanRetry runs an idempotent podman call (inspect, read-only exec, cp)
+// and returns its stdout, retrying transient failures with jittered
+// exponential backoff. Only use it for calls that are safe to repeat.
+func podmanRetry(args ...string) ([]byte, error) {
+	var out []byte
+	var err error
+	delay := retryBaseDelay
+	for attempt := 1; attempt <= retryAttempts; attempt++ {
+		out, err = podman(args...).Output()
+		if err == nil || !isTransient(err) || attempt == retryAttempts {
+			return out, err
+		}
+		jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		time.Sleep(delay + jitter)
+		delay *= 2
+	}
+	return out, err
+}
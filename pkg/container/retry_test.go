@@ -0,0 +1,66 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shrinkRetryDelay makes retry backoff instant for the duration of a test.
+func shrinkRetryDelay(t *testing.T) {
+	t.Helper()
+	orig := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = orig })
+}
+
+func TestPodmanRetryRecoversFromTransientFailure(t *testing.T) {
+	shrinkRetryDelay(t)
+	counter := filepath.Join(t.TempDir(), "calls")
+	// Fail with a lock error on the first call, then succeed.
+	fakePodman(t, `echo x >> `+counter+`
+if [ "$(wc -l < `+counter+`)" -lt 2 ]; then echo "Error: database is locked" >&2; exit 125; fi
+echo running`)
+
+	out, err := podmanRetry("inspect", "agent-1")
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "running" {
+		t.Errorf("out = %q, want running", out)
+	}
+	data, _ := os.ReadFile(counter)
+	if n := strings.Count(string(data), "x"); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+}
+
+func TestPodmanRetryDoesNotRetryCommandFailures(t *testing.T) {
+	shrinkRetryDelay(t)
+	counter := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo x >> `+counter+`; exit 1`)
+
+	if _, err := podmanRetry("exec", "agent-1", "test", "-f", "/nope"); err == nil {
+		t.Fatal("expected error")
+	}
+	data, _ := os.ReadFile(counter)
+	if n := strings.Count(string(data), "x"); n != 1 {
+		t.Errorf("command failures must not be retried, got %d calls", n)
+	}
+}
+
+func TestPodmanRetryGivesUp(t *testing.T) {
+	shrinkRetryDelay(t)
+	counter := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `echo x >> `+counter+`; echo "error acquiring lock 3" >&2; exit 125`)
+
+	if _, err := podmanRetry("inspect", "agent-1"); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	data, _ := os.ReadFile(counter)
+	if n := strings.Count(string(data), "x"); n != retryAttempts {
+		t.Errorf("expected %d calls, got %d", retryAttempts, n)
+	}
+}
@@ -0,0 +1,152 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+	"github.com/jordanpartridge/agentctl/pkg/process"
+)
+
+// RunLock records the agentctl process running an agent's loop. Two loops
+// on one agent would interleave two Claude processes in one workspace.
+type RunLock struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+	Command string    `json:"command,omitempty"`
+}
+
+func (l *RunLock) String() string {
+	return fmt.Sprintf("pid %d on %s, since %s", l.PID, l.Host, l.Started.Format("2006-01-02 15:04"))
+}
+
+func runLockPath(name string) string {
+	return filepath.Join(namespace.Root(), "locks", name+".lock")
+}
+
+// LoadRunLock returns the lock held on the agent's run loop, or nil.
+func LoadRunLock(name string) *RunLock {
+	data, err := os.ReadFile(runLockPath(name))
+	if err != nil {
+		return nil
+	}
+	var l RunLock
+	if json.Unmarshal(data, &l) != nil || l.PID <= 0 {
+		// Unreadable: held by an unknown process, unless its holder died
+		// between creating and writing it.
+		l = RunLock{}
+		if info, err := os.Stat(runLockPath(name)); err == nil {
+			l.Started = info.ModTime()
+		}
+	}
+	return &l
+}
+
+// runLockWriteGrace is how long a lock may stay unreadable while its holder
+// writes it.
+const runLockWriteGrace = time.Minute
+
+// stale reports whether the lock's process is known to be gone: it ran on
+// this host and no longer exists, or the lock was never written. Locks
+// from other hosts are never stale.
+func (l *RunLock) stale() bool {
+	if l.PID <= 0 {
+		return time.Since(l.Started) > runLockWriteGrace
+	}
+	host, _ := os.Hostname()
+	return l.Host == host && !process.Alive(l.PID)
+}
+
+// acquireRunLock takes the agent's run lock for this process, replacing a
+// stale one, and returns the function that releases it. A lock held by a
+// live (or unknown) process fails with ErrRunInProgress.
+func acquireRunLock(name string) (func(), error) {
+	path := runLockPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	me := RunLock{PID: os.Getpid(), Host: host, Started: time.Now(), Command: strings.Join(os.Args, " ")}
+	data, _ := json.MarshalIndent(me, "", "  ")
+	for try := 0; try < 2; try++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { releaseRunLock(name, me.PID) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if err := CheckRunLock(name); err != nil {
+			return nil, err
+		}
+		if err := removeStaleRunLock(name); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: could not take the run lock for %s", ErrRunInProgress, name)
+}
+
+// removeStaleRunLock removes the agent's run lock if it is still stale.
+// Runs taking over the same stale lock do it one at a time, each looking
+// again under the guard, so none removes the lock another has just
+// created in its place.
+func removeStaleRunLock(name string) error {
+	unlock, err := lockFile(runLockPath(name)+".takeover", rateLockStale)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	held := LoadRunLock(name)
+	if held == nil {
+		return nil // released or taken over meanwhile
+	}
+	if !held.stale() {
+		return runInProgress(name, held)
+	}
+	fmt.Printf("🔓 Taking over a stale run lock (%s)\n", held)
+	if err := os.Remove(runLockPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CheckRunLock fails with ErrRunInProgress if a live run holds the agent,
+// for commands that change the workspace before starting a run.
+func CheckRunLock(name string) error {
+	if held := LoadRunLock(name); held != nil && !held.stale() {
+		return runInProgress(name, held)
+	}
+	return nil
+}
+
+func runInProgress(name string, held *RunLock) error {
+	return fmt.Errorf("%w: %s is being run by %s; use --force if that run is gone", ErrRunInProgress, name, held)
+}
+
+// releaseRunLock removes the lock if pid still holds it.
+func releaseRunLock(name string, pid int) {
+	if l := LoadRunLock(name); l != nil && l.PID == pid {
+		os.Remove(runLockPath(name))
+	}
+}
+
+// BreakRunLock removes the agent's run lock whoever holds it (run --force),
+// returning the lock removed, if any.
+func BreakRunLock(name string) *RunLock {
+	l := LoadRunLock(name)
+	if l != nil {
+		os.Remove(runLockPath(name))
+	}
+	return l
+}
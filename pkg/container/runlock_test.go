@@ -0,0 +1,131 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	release, err := acquireRunLock("busy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := LoadRunLock("busy"); l == nil || l.PID != os.Getpid() {
+		t.Fatalf("lock = %+v", l)
+	}
+	if _, err := acquireRunLock("busy"); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("a second run should be refused, got %v", err)
+	}
+	if _, err := RunUntilDone("busy", "task", 1); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("RunUntilDone on a locked agent = %v", err)
+	}
+	if err := CheckRunLock("busy"); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("CheckRunLock = %v", err)
+	}
+	release()
+	if LoadRunLock("busy") != nil {
+		t.Error("release should remove the lock")
+	}
+
+	// A lock whose process has exited on this host is taken over.
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Skip("cannot start a process")
+	}
+	host, _ := os.Hostname()
+	writeLock := func(l RunLock) {
+		data, _ := json.Marshal(l)
+		os.MkdirAll(filepath.Dir(runLockPath("busy")), 0755)
+		os.WriteFile(runLockPath("busy"), data, 0644)
+	}
+	writeLock(RunLock{PID: dead.Process.Pid, Host: host, Started: time.Now()})
+	release, err = acquireRunLock("busy")
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	release()
+
+	// One from another host can only be broken by hand.
+	writeLock(RunLock{PID: dead.Process.Pid, Host: "elsewhere", Started: time.Now()})
+	if _, err := acquireRunLock("busy"); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("a lock from another host should hold, got %v", err)
+	}
+	if l := BreakRunLock("busy"); l == nil || l.Host != "elsewhere" {
+		t.Errorf("BreakRunLock = %+v", l)
+	}
+	release, err = acquireRunLock("busy")
+	if err != nil {
+		t.Errorf("after --force: %v", err)
+	}
+	release()
+
+	// A lock left unwritten is held while its writer may still be at it,
+	// then taken over.
+	writeLock(RunLock{})
+	if _, err := acquireRunLock("busy"); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("a freshly created lock should hold, got %v", err)
+	}
+	old := time.Now().Add(-2 * runLockWriteGrace)
+	os.Chtimes(runLockPath("busy"), old, old)
+	if release, err = acquireRunLock("busy"); err != nil {
+		t.Errorf("an unwritten lock wasn't taken over: %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestRunLockTakeoverRace(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Skip("cannot start a process")
+	}
+	host, _ := os.Hostname()
+
+	// Runs racing to take over one stale lock: exactly one gets it, and
+	// none removes the lock the winner put in its place.
+	for round := 0; round < 50; round++ {
+		data, _ := json.Marshal(RunLock{PID: dead.Process.Pid, Host: host, Started: time.Now()})
+		os.MkdirAll(filepath.Dir(runLockPath("busy")), 0755)
+		os.WriteFile(runLockPath("busy"), data, 0644)
+
+		var mu sync.Mutex
+		var releases []func()
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				release, err := acquireRunLock("busy")
+				if err != nil {
+					if !errors.Is(err, ErrRunInProgress) {
+						t.Error(err)
+					}
+					return
+				}
+				mu.Lock()
+				releases = append(releases, release)
+				mu.Unlock()
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if len(releases) != 1 {
+			t.Fatalf("round %d: %d runs took the lock, want 1", round, len(releases))
+		}
+		if l := LoadRunLock("busy"); l == nil || l.PID != os.Getpid() {
+			t.Fatalf("round %d: lock = %+v", round, l)
+		}
+		releases[0]()
+	}
+}
@@ -0,0 +1,155 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/telemetry"
+)
+
+const (
+	// DefaultRuntimeTimeout bounds quick podman calls (inspect, exec probes, cp, stop).
+	DefaultRuntimeTimeout = 30 * time.Second
+	// DefaultLongRuntimeTimeout bounds slow but finite calls (run, clone, setup, test suites).
+	DefaultLongRuntimeTimeout = 30 * time.Minute
+)
+
+// ErrRuntimeUnresponsive is returned (wrapped) when a podman call exceeds its timeout.
+var ErrRuntimeUnresponsive = errors.New("container runtime unresponsive")
+
+var (
+	timeoutsOnce sync.Once
+	shortTimeout = DefaultRuntimeTimeout
+	longTimeout  = DefaultLongRuntimeTimeout
+)
+
+// runtimeTimeouts returns the configured short and long podman timeouts,
+// read once from the runtime section of the config.
+func runtimeTimeouts() (time.Duration, time.Duration) {
+	timeoutsOnce.Do(func() {
+		cfg, err := config.Load()
+		if err != nil {
+			return
+		}
+		if d, err := time.ParseDuration(cfg.Runtime.Timeout); err == nil && d > 0 {
+			shortTimeout = d
+		}
+		if d, err := time.ParseDuration(cfg.Runtime.LongTimeout); err == nil && d > 0 {
+			longTimeout = d
+		}
+	})
+	return shortTimeout, longTimeout
+}
+
+// runtimeCmd is a podman invocation bounded by a timeout. It embeds exec.Cmd
+// so call sites can set Stdout/Stderr as usual; Run, Output, CombinedOutput
+// and Start/Wait translate a deadline into ErrRuntimeUnresponsive.
+type runtimeCmd struct {
+	*exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	args    []string
+	timeout time.Duration
+	span    *telemetry.Span // of a command Started and not yet waited for
+}
+
+// podman builds a podman command bounded by the short runtime timeout.
+func podman(args ...string) *runtimeCmd {
+	short, _ := runtimeTimeouts()
+	return podmanTimeout(short, args...)
+}
+
+// podmanLong builds a podman command bounded by the long runtime timeout.
+func podmanLong(args ...string) *runtimeCmd {
+	_, long := runtimeTimeouts()
+	return podmanTimeout(long, args...)
+}
+
+func podmanTimeout(timeout time.Duration, args ...string) *runtimeCmd {
+	deadline, argv := timeout, append([]string{"podman"}, args...)
+	if f := faultFor(args); f != nil {
+		argv = f.command()
+		if f.kind == "hang" {
+			deadline = 10 * time.Millisecond
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	// Don't let a child that inherited our pipes keep us waiting after the kill.
+	cmd.WaitDelay = 2 * time.Second
+	return &runtimeCmd{
+		Cmd:     cmd,
+		ctx:     ctx,
+		cancel:  cancel,
+		args:    args,
+		timeout: timeout,
+	}
+}
+
+func (c *runtimeCmd) Run() error {
+	defer c.cancel()
+	span := podmanSpan(c.args)
+	err := c.wrap(c.Cmd.Run())
+	endPodmanSpan(span, err)
+	return err
+}
+
+func (c *runtimeCmd) Output() ([]byte, error) {
+	defer c.cancel()
+	span := podmanSpan(c.args)
+	out, err := c.Cmd.Output()
+	err = c.wrap(err)
+	endPodmanSpan(span, err)
+	return out, err
+}
+
+func (c *runtimeCmd) CombinedOutput() ([]byte, error) {
+	defer c.cancel()
+	span := podmanSpan(c.args)
+	out, err := c.Cmd.CombinedOutput()
+	err = c.wrap(err)
+	endPodmanSpan(span, err)
+	return out, err
+}
+
+// Start starts the command; Wait must follow, which ends its timeout.
+func (c *runtimeCmd) Start() error {
+	c.span = podmanSpan(c.args)
+	err := c.Cmd.Start()
+	if err != nil {
+		c.cancel()
+		err = c.wrap(err)
+		endPodmanSpan(c.span, err)
+	}
+	return err
+}
+
+func (c *runtimeCmd) Wait() error {
+	defer c.cancel()
+	err := c.wrap(c.Cmd.Wait())
+	endPodmanSpan(c.span, err)
+	return err
+}
+
+func (c *runtimeCmd) wrap(err error) error {
+	if err != nil && errors.Is(c.ctx.Err(), context.DeadlineExceeded) {
+		return runtimeTimeoutError(c.args, c.timeout)
+	}
+	return err
+}
+
+// runtimeTimeoutError builds the consistent "runtime unresponsive" error.
+func runtimeTimeoutError(args []string, timeout time.Duration) error {
+	verb := args
+	if len(verb) > 2 {
+		verb = verb[:2]
+	}
+	return fmt.Errorf("%w: `podman %s` did not finish within %s (hint: `podman --log-level=debug ps` shows where podman stalls, e.g. on a storage lock; a wedged container can be restarted with `podman restart <name>`)",
+		ErrRuntimeUnresponsive, strings.Join(verb, " "), timeout)
+}
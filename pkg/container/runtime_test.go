@@ -0,0 +1,73 @@
+package container
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePodman puts a podman stub running script first on PATH.
+func fakePodman(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "podman")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPodmanTimeout(t *testing.T) {
+	fakePodman(t, "exec sleep 5")
+
+	start := time.Now()
+	_, err := podmanTimeout(100*time.Millisecond, "inspect", "agent-1").Output()
+	if !errors.Is(err, ErrRuntimeUnresponsive) {
+		t.Fatalf("expected ErrRuntimeUnresponsive, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("timeout took too long: %s", time.Since(start))
+	}
+	if !strings.Contains(err.Error(), "podman inspect agent-1") || !strings.Contains(err.Error(), "hint:") {
+		t.Errorf("error should name the call and include a hint: %v", err)
+	}
+}
+
+func TestPodmanTimeoutStartWait(t *testing.T) {
+	fakePodman(t, "exec sleep 5")
+
+	cmd := podmanTimeout(100*time.Millisecond, "logs", "-f", "agent-1")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); !errors.Is(err, ErrRuntimeUnresponsive) {
+		t.Fatalf("expected ErrRuntimeUnresponsive from Wait, got %v", err)
+	}
+}
+
+func TestPodmanPassesThroughErrors(t *testing.T) {
+	fakePodman(t, "echo boom >&2; exit 3")
+
+	out, err := podmanTimeout(time.Second, "exec", "agent-1", "false").CombinedOutput()
+	if err == nil || errors.Is(err, ErrRuntimeUnresponsive) {
+		t.Fatalf("expected a plain exit error, got %v", err)
+	}
+	if !strings.Contains(string(out), "boom") {
+		t.Errorf("output should be preserved, got %q", out)
+	}
+}
+
+func TestPodmanSuccess(t *testing.T) {
+	fakePodman(t, `echo "$@"`)
+
+	out, err := podmanTimeout(time.Second, "inspect", "-f", "{{.State.Status}}", "agent-1").Output()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "inspect -f {{.State.Status}} agent-1" {
+		t.Errorf("args not passed through: %q", out)
+	}
+}
@@ -0,0 +1,99 @@
+package container
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// DefaultSetupScript is the repo-relative script Spawn runs after cloning when present.
+const DefaultSetupScript = ".agentctl/setup.sh"
+
+// SetupError reports a failed post-clone setup step with its captured output,
+// so dependency install failures aren't mistaken for task failures later.
+type SetupError struct {
+	Command string
+	Output  string
+	Err     error
+	// Log is where the full output was written inside the container.
+	Log string
+}
+
+func (e *SetupError) Error() string {
+	return fmt.Sprintf("setup step %q failed: %v\n%s", e.Command, e.Err, tailLines(e.Output, 20))
+}
+
+func (e *SetupError) Unwrap() error { return e.Err }
+
+// SetupCommands decides which bootstrap commands to run for a workspace.
+// exists reports whether a repo-relative path is present. Precedence: the
+// repo's own setup script, then configured commands, then auto-detection
+// from well-known manifests.
+func SetupCommands(cfg config.Setup, exists func(string) bool) []string {
+	if cfg.Disabled {
+		return nil
+	}
+	script := cfg.Script
+	if script == "" {
+		script = DefaultSetupScript
+	}
+	if exists(script) {
+		return []string{"sh " + script}
+	}
+	if len(cfg.Commands) > 0 {
+		return cfg.Commands
+	}
+
+	var cmds []string
+	if exists("composer.json") {
+		cmds = append(cmds, "composer install --no-interaction --no-progress")
+	}
+	if exists("package-lock.json") {
+		cmds = append(cmds, "npm ci --no-audit --no-fund")
+	} else if exists("package.json") {
+		cmds = append(cmds, "npm install --no-audit --no-fund")
+	}
+	if exists("go.mod") {
+		cmds = append(cmds, "go mod download")
+	}
+	return cmds
+}
+
+// runSetup runs the bootstrap commands inside the agent's workspace, appending
+// all output to setup.log. It stops at the first failing command.
+func runSetup(name string, cfg config.Setup, override []string) error {
+	layout := layoutOf(name)
+	logPath := layout.Path("setup.log")
+	exists := func(rel string) bool {
+		return podman("exec", containerName(name), "test", "-e", path.Join(layout.Workspace, rel)).Run() == nil
+	}
+	cmds := override
+	if len(cmds) == 0 {
+		cmds = SetupCommands(cfg, exists)
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	for _, c := range cmds {
+		fmt.Printf("🔧 Setup: %s\n", c)
+		// bash with pipefail so a failing step isn't masked by tee's exit code.
+		script := fmt.Sprintf("set -o pipefail; %s%s{ %s; } 2>&1 | tee -a %s", logAppend(logPath, "setup: "+c), layout.cd(), c, shellQuote(logPath))
+		out, err := podmanLong("exec", containerName(name), "bash", "-c", script).CombinedOutput()
+		if err != nil {
+			return &SetupError{Command: c, Output: string(out), Err: err, Log: logPath}
+		}
+	}
+	return nil
+}
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,84 @@
+package container
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func existsIn(files ...string) func(string) bool {
+	set := make(map[string]bool)
+	for _, f := range files {
+		set[f] = true
+	}
+	return func(p string) bool { return set[p] }
+}
+
+func TestSetupCommandsAutoDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{"none", nil, nil},
+		{"go", []string{"go.mod"}, []string{"go mod download"}},
+		{"npm lockfile", []string{"package.json", "package-lock.json"}, []string{"npm ci --no-audit --no-fund"}},
+		{"npm no lockfile", []string{"package.json"}, []string{"npm install --no-audit --no-fund"}},
+		{"composer and npm", []string{"composer.json", "package.json"}, []string{
+			"composer install --no-interaction --no-progress",
+			"npm install --no-audit --no-fund",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SetupCommands(config.Setup{}, existsIn(tt.files...))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SetupCommands() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetupCommandsScriptWins(t *testing.T) {
+	cfg := config.Setup{Commands: []string{"make deps"}}
+	got := SetupCommands(cfg, existsIn(DefaultSetupScript, "go.mod"))
+	want := []string{"sh " + DefaultSetupScript}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SetupCommands() = %v, want %v", got, want)
+	}
+}
+
+func TestSetupCommandsConfigured(t *testing.T) {
+	cfg := config.Setup{Commands: []string{"make deps"}}
+	got := SetupCommands(cfg, existsIn("go.mod"))
+	if !reflect.DeepEqual(got, []string{"make deps"}) {
+		t.Errorf("configured commands should override detection, got %v", got)
+	}
+}
+
+func TestSetupCommandsDisabled(t *testing.T) {
+	cfg := config.Setup{Disabled: true}
+	if got := SetupCommands(cfg, existsIn(DefaultSetupScript, "go.mod")); got != nil {
+		t.Errorf("disabled setup should return nil, got %v", got)
+	}
+}
+
+func TestSetupErrorIncludesOutputTail(t *testing.T) {
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, "line")
+	}
+	lines = append(lines, "Your lock file is out of date")
+	err := &SetupError{Command: "composer install", Output: strings.Join(lines, "\n"), Err: errors.New("exit status 2")}
+
+	msg := err.Error()
+	if !strings.Contains(msg, "composer install") || !strings.Contains(msg, "lock file is out of date") {
+		t.Errorf("error should name the command and include output tail, got: %q", msg)
+	}
+	if strings.Count(msg, "line") > 20 {
+		t.Errorf("output should be trimmed to the last 20 lines")
+	}
+}
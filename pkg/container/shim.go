@@ -0,0 +1,189 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShimPort is the container port the liveness shim listens on. Spawn
+// publishes it on the host as the agent's port.
+const ShimPort = 8080
+
+// agentProcess matches the command lines of the agent harness.
+var agentProcess = regexp.MustCompile(`run-task|claude|opencode`)
+
+// ShimStatus is what the liveness shim reports about its container.
+type ShimStatus struct {
+	PID     int    `json:"pid,omitempty"` // agent process, 0 when none runs
+	Command string `json:"command,omitempty"`
+	// LastActivity is the newest write to the agent's logs.
+	LastActivity time.Time `json:"last_activity,omitempty"`
+	// LastSession is the newest write to a session transcript.
+	LastSession time.Time `json:"last_session,omitempty"`
+	// Harness is set when the process is claude or opencode itself, not a
+	// wrapper that may outlive it.
+	Harness bool `json:"harness,omitempty"`
+	Attempt int  `json:"attempt,omitempty"` // set by the host's run loop
+}
+
+// Running reports whether an agent process runs in the container.
+func (s *ShimStatus) Running() bool {
+	return s.PID != 0
+}
+
+// Shim answers liveness queries from inside an agent container, so the host
+// asks over HTTP rather than running ps through podman exec:
+//
+//	GET  /health        ShimStatus as JSON
+//	POST /attempt?n=3   record the current attempt
+type Shim struct {
+	ProcRoot string   // normally /proc
+	Logs     []string // files whose modification marks activity
+	Sessions string   // glob of the session transcripts
+
+	mu      sync.Mutex
+	attempt int
+}
+
+// NewShim returns a shim for an agent whose home is home.
+func NewShim(home string) *Shim {
+	l := Layout{Home: home}
+	return &Shim{ProcRoot: "/proc", Logs: []string{l.Path("claude.log"), l.Path("task.log")}, Sessions: l.sessionGlob()}
+}
+
+// ServeShim runs the shim on addr (":8080" when empty) until it fails.
+func ServeShim(addr, home string) error {
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", ShimPort)
+	}
+	return http.ListenAndServe(addr, NewShim(home).Handler())
+}
+
+// Handler serves the shim's endpoints.
+func (s *Shim) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status())
+	})
+	mux.HandleFunc("/attempt", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil || n < 0 {
+			http.Error(w, "n must be an attempt number", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.attempt = n
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// Status looks for the agent process and the latest log write.
+func (s *Shim) Status() ShimStatus {
+	s.mu.Lock()
+	st := ShimStatus{Attempt: s.attempt}
+	s.mu.Unlock()
+
+	entries, _ := os.ReadDir(s.ProcRoot)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.ProcRoot, e.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		cmdline := strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+		if agentProcess.MatchString(cmdline) && (st.PID == 0 || pid < st.PID) {
+			st.PID, st.Command = pid, cmdline
+		}
+		st.Harness = st.Harness || isHarness(cmdline)
+	}
+	for _, log := range s.Logs {
+		if fi, err := os.Stat(log); err == nil && fi.ModTime().After(st.LastActivity) {
+			st.LastActivity = fi.ModTime()
+		}
+	}
+	if s.Sessions != "" {
+		sessions, _ := filepath.Glob(s.Sessions)
+		for _, f := range sessions {
+			if fi, err := os.Stat(f); err == nil && fi.ModTime().After(st.LastSession) {
+				st.LastSession = fi.ModTime()
+			}
+		}
+	}
+	return st
+}
+
+// shimClient is short-fused: a missing shim must not slow list or check down.
+var shimClient = &http.Client{Timeout: time.Second}
+
+// shimURL addresses an agent's shim through its published port.
+func shimURL(agent *Agent, path string) string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s", agent.Port, path)
+}
+
+// probeShim asks an agent's shim for its status. It fails for agents spawned
+// without one (or whose shim died), and callers fall back to podman exec.
+func probeShim(name string) (*ShimStatus, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := shimClient.Get(shimURL(agent, "/health"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("shim: %s", resp.Status)
+	}
+	var st ShimStatus
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, fmt.Errorf("shim: %w", err)
+	}
+	return &st, nil
+}
+
+// startShim launches the shim in an agent's container from the mounted
+// agentctl binary. Without the binary (no coordination mount, or a non-Linux
+// host) it fails and the agent is probed through podman exec as before.
+func startShim(name string) error {
+	return podman("exec", "-d", containerName(name),
+		containerAgentctlPath, "shim", "--home", layoutOf(name).Home).Run()
+}
+
+// ensureShim restarts a shim that is gone, e.g. after the container was
+// restarted.
+func ensureShim(name string) {
+	if _, err := probeShim(name); err != nil {
+		startShim(name)
+	}
+}
+
+// setShimAttempt tells the shim which attempt is running.
+func setShimAttempt(name string, attempt int) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return
+	}
+	resp, err := shimClient.Post(shimURL(agent, fmt.Sprintf("/attempt?n=%d", attempt)), "", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,66 @@
+package container
+
+import (
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShim(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	// A fake /proc with an idle shell and the agent harness.
+	proc := t.TempDir()
+	for pid, cmdline := range map[string]string{
+		"1":    "sleep\x00infinity\x00",
+		"42":   "node\x00/usr/bin/opencode\x00run\x00",
+		"self": "ignored",
+	} {
+		os.MkdirAll(filepath.Join(proc, pid), 0755)
+		os.WriteFile(filepath.Join(proc, pid, "cmdline"), []byte(cmdline), 0644)
+	}
+	log := filepath.Join(t.TempDir(), "claude.log")
+	os.WriteFile(log, []byte("working\n"), 0644)
+	shim := &Shim{ProcRoot: proc, Logs: []string{log, "/nonexistent/task.log"}}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(shim.Handler())
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+	saveAgent(&Agent{Name: "worker", Port: ln.Addr().(*net.TCPAddr).Port})
+
+	setShimAttempt("worker", 3)
+	st, err := probeShim("worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Running() || !st.Harness || st.PID != 42 || st.Command != "node /usr/bin/opencode run" || st.Attempt != 3 {
+		t.Errorf("status = %+v", st)
+	}
+	if time.Since(st.LastActivity) > time.Minute {
+		t.Errorf("last activity = %v, want the log's mtime", st.LastActivity)
+	}
+	if !claudeRunning("worker") {
+		t.Error("claudeRunning() = false with the harness running")
+	}
+
+	os.RemoveAll(filepath.Join(proc, "42"))
+	if st, _ := probeShim("worker"); st == nil || st.Running() {
+		t.Errorf("status after the harness exited = %+v", st)
+	}
+
+	saveAgent(&Agent{Name: "old", Port: 1})
+	if _, err := probeShim("old"); err == nil {
+		t.Error("probeShim() succeeded for an agent without a shim")
+	}
+}
@@ -2,11 +2,20 @@ package container
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
@@ -17,6 +26,27 @@ type SpyOptions struct {
 	Thinking  bool // include thinking blocks
 	Verbose   bool // include tool results
 	JSON      bool // structured JSON output for piping
+	Diffs     bool // show what Edit, MultiEdit and Write calls change
+
+	NoColor bool   // no ANSI escapes
+	Compact bool   // one emoji per line instead of the "> Tool:" layout
+	Wide    bool   // never truncate
+	Width   int    // truncate every field to this many characters (0: per-field defaults)
+	Format  string // text/template for each line, executed with a SpyEvent
+
+	// Filters. Tools and Paths keep only tool calls; Grep applies to every
+	// event, raw lines included.
+	Tools []string // tool names (Bash, Edit, ...)
+	Paths []string // globs over the files a call touches; ** spans directories
+	Grep  string   // regexp over an event's text and tool input
+	// Workspace resolves absolute paths for Paths; SpyStream fills it in.
+	Workspace string
+
+	// Record, when set, receives every event as a SpyRecord, unfiltered.
+	Record io.Writer
+	// Out, when set, receives every line shown, without colors; progress
+	// lines, which overwrite themselves, stay on the terminal.
+	Out io.Writer
 }
 
 // claudeConfig represents the top-level .claude.json file.
@@ -37,8 +67,17 @@ type jsonlMessage struct {
 }
 
 type messageBody struct {
+	ID      string         `json:"id,omitempty"`
 	Role    string         `json:"role"`
 	Content []contentBlock `json:"content"`
+	Usage   *tokenUsage    `json:"usage,omitempty"`
+}
+
+type tokenUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 }
 
 type contentBlock struct {
@@ -68,14 +107,32 @@ type progressData struct {
 
 // Spy streams real-time session activity from a running agent container.
 func Spy(name string, opts SpyOptions) error {
+	return SpyStream(context.Background(), name, os.Stdout, os.Stderr, opts)
+}
+
+// SpyStream renders a running agent's session activity to w until ctx is
+// cancelled or the container stops. Progress notes go to info.
+func SpyStream(ctx context.Context, name string, w, info io.Writer, opts SpyOptions) error {
+	renderer, err := NewRenderer(w, opts)
+	if err != nil {
+		return err
+	}
+
 	// Verify the container is running.
-	out, err := exec.Command("podman", "inspect", "-f", "{{.State.Status}}", name).Output()
+	out, err := podmanRetry("inspect", "-f", "{{.State.Status}}", containerName(name))
+	if errors.Is(err, ErrRuntimeUnresponsive) {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("container %q not found — is the agent spawned?", name)
+		return fmt.Errorf("%w: no container %q — is the agent spawned?", ErrAgentNotFound, name)
 	}
 	status := strings.TrimSpace(string(out))
 	if status != "running" {
-		return fmt.Errorf("container %q is %s, not running", name, status)
+		return fmt.Errorf("%w: %q is %s", ErrContainerNotRunning, name, status)
+	}
+
+	if opts.Workspace == "" {
+		renderer.opts.Workspace = layoutOf(name).Workspace
 	}
 
 	// Discover the session JSONL file path inside the container.
@@ -84,172 +141,558 @@ func Spy(name string, opts SpyOptions) error {
 		return fmt.Errorf("session discovery failed: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Spying on agent %s (Ctrl+C to stop)...\n", name)
-	fmt.Fprintf(os.Stderr, "Session: %s\n", sessionPath)
-	fmt.Fprintln(os.Stderr, "---")
+	fmt.Fprintf(info, "Spying on agent %s (Ctrl+C to stop)...\n", name)
+	fmt.Fprintf(info, "Session: %s\n", sessionPath)
+	fmt.Fprintln(info, "---")
+
+	for {
+		next, err := tailSession(ctx, name, sessionPath, w, info, renderer, opts.Record)
+		if err != nil || next == "" {
+			return err
+		}
+		fmt.Fprintf(info, "--- newer session: %s\n", next)
+		sessionPath = next
+	}
+}
+
+// tailSession renders one session until ctx is cancelled or the container
+// stops. If the session goes quiet while a newer one appears — Claude
+// restarted for the next attempt — it stops and returns the newer one.
+func tailSession(ctx context.Context, name, sessionPath string, w, info io.Writer, renderer *Renderer, record io.Writer) (string, error) {
+	var recorder *SpyRecorder
+	if record != nil {
+		recorder = NewSpyRecorder(record, name, sessionPath)
+	}
+	if renderer.out != nil {
+		renderer.out.println(logMarker + time.Now().UTC().Format(time.RFC3339) + " spy " + name + " " + sessionID(sessionPath))
+	}
+
+	tailCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Tail the session JSONL via podman exec.
-	cmd := exec.Command("podman", "exec", name, "tail", "-f", "-n", "+1", sessionPath)
+	cmd := exec.CommandContext(tailCtx, "podman", "exec", containerName(name), "tail", "-f", "-n", "+1", sessionPath)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("pipe failed: %w", err)
+		return "", fmt.Errorf("pipe failed: %w", err)
 	}
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = info
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("tail failed: %w", err)
+		return "", fmt.Errorf("tail failed: %w", err)
 	}
 
+	var lastLine atomic.Int64
+	lastLine.Store(time.Now().UnixNano())
+	var newer string
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		tick := time.NewTicker(sessionPoll)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+			}
+			if time.Since(time.Unix(0, lastLine.Load())) < sessionQuietAfter {
+				continue
+			}
+			if p, err := discoverSessionFile(name); err == nil && p != sessionPath {
+				newer = p
+				cancel()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	stopWatching := func() { once.Do(func() { close(stop); <-stopped }) }
+	defer stopWatching()
+
 	scanner := bufio.NewScanner(stdout)
 	// Allow up to 1 MB lines — JSONL messages can be large.
 	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
 
 	for scanner.Scan() {
+		lastLine.Store(time.Now().UnixNano())
 		line := scanner.Text()
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		if opts.Raw {
-			fmt.Println(line)
-			continue
+		if recorder != nil {
+			if err := recorder.Record(line); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return "", fmt.Errorf("recording failed: %w", err)
+			}
+		}
+		renderer.Render(line)
+		if err := renderer.Err(); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return "", fmt.Errorf("writing --out failed: %w", err)
+		}
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
 		}
+	}
 
-		renderLine(line, opts)
+	err = cmd.Wait()
+	stopWatching()
+	if newer != "" {
+		return newer, nil
+	}
+	if err != nil && ctx.Err() == nil {
+		return "", err
 	}
+	return "", nil
+}
+
+// sessionFile is a session transcript in the container and when it was last
+// written.
+type sessionFile struct {
+	Path  string
+	MTime int64 // unix seconds
+}
+
+// Re-discovery while spying: when the tailed session has been quiet for
+// sessionQuietAfter, spy looks for a newer one every sessionPoll.
+var (
+	sessionQuietAfter = 30 * time.Second
+	sessionPoll       = 5 * time.Second
+)
 
-	return cmd.Wait()
+var nonAlnum = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// claudeProjectDir is the directory under .claude/projects Claude keeps a
+// working directory's sessions in: the path with everything but letters and
+// digits replaced by dashes.
+func claudeProjectDir(workspace string) string {
+	return nonAlnum.ReplaceAllString(workspace, "-")
 }
 
-// discoverSessionFile reads .claude.json inside the container, extracts the
-// lastSessionId, then locates the matching JSONL file under .claude/projects/.
+// discoverSessionFile finds the transcript of the agent's live Claude
+// session. Containers collect sessions — one per attempt, plus any Claude
+// started elsewhere — so it looks only at sessions of the agent's workspace
+// and takes the most recently written one, falling back to the workspace's
+// lastSessionId in .claude.json to break ties.
 func discoverSessionFile(name string) (string, error) {
-	// Read .claude.json from the container.
-	out, err := exec.Command("podman", "exec", name, "cat", "/home/agent/.claude.json").Output()
+	layout := layoutOf(name)
+
+	var lastID string
+	if out, err := podmanRetry("exec", containerName(name), "cat", layout.Path(".claude.json")); err == nil {
+		var cfg claudeConfig
+		if json.Unmarshal(out, &cfg) == nil {
+			lastID = cfg.Projects[layout.Workspace].LastSessionID
+		}
+	}
+
+	out, err := podmanRetry("exec", containerName(name), "sh", "-c",
+		"stat -c '%Y %n' "+layout.sessionGlob()+" 2>/dev/null; true")
 	if err != nil {
-		return "", fmt.Errorf("could not read .claude.json: %w", err)
+		return "", fmt.Errorf("could not list sessions: %w", err)
 	}
+	path := pickSession(parseSessionFiles(string(out)), layout.Workspace, lastID)
+	if path == "" {
+		return "", fmt.Errorf("no session under .claude/projects — has Claude started a session?")
+	}
+	return path, nil
+}
 
-	var cfg claudeConfig
-	if err := json.Unmarshal(out, &cfg); err != nil {
-		return "", fmt.Errorf("could not parse .claude.json: %w", err)
+// parseSessionFiles reads `stat -c '%Y %n'` output.
+func parseSessionFiles(out string) []sessionFile {
+	var files []sessionFile
+	for _, line := range strings.Split(out, "\n") {
+		mtime, p, ok := strings.Cut(strings.TrimSpace(line), " ")
+		n, err := strconv.ParseInt(mtime, 10, 64)
+		if !ok || err != nil || !strings.HasSuffix(p, ".jsonl") {
+			continue
+		}
+		files = append(files, sessionFile{Path: p, MTime: n})
 	}
+	return files
+}
 
-	// Find the first project entry with a lastSessionId.
-	var sessionID string
-	for _, proj := range cfg.Projects {
-		if proj.LastSessionID != "" {
-			sessionID = proj.LastSessionID
-			break
+// pickSession chooses the newest session of workspace, or of any project
+// when the workspace has none. Among equally recent ones, lastID wins.
+func pickSession(files []sessionFile, workspace, lastID string) string {
+	dir := claudeProjectDir(workspace)
+	var own []sessionFile
+	for _, f := range files {
+		if path.Base(path.Dir(f.Path)) == dir {
+			own = append(own, f)
 		}
 	}
-	if sessionID == "" {
-		return "", fmt.Errorf("no lastSessionId found in .claude.json — has Claude started a session?")
+	if len(own) > 0 {
+		files = own
 	}
+	best := -1
+	for i, f := range files {
+		if best < 0 || f.MTime > files[best].MTime ||
+			f.MTime == files[best].MTime && path.Base(f.Path) == lastID+".jsonl" {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return files[best].Path
+}
 
-	// List project directories under .claude/projects/ to find the encoded path.
-	out, err = exec.Command("podman", "exec", name, "ls", "/home/agent/.claude/projects/").Output()
-	if err != nil {
-		return "", fmt.Errorf("could not list .claude/projects/: %w", err)
+// Default truncation lengths per kind of rendered field.
+const (
+	defaultTextWidth     = 120
+	defaultThinkingWidth = 100
+	defaultCommandWidth  = 100
+	defaultDetailWidth   = 80
+)
+
+// SpyEvent is one rendered spy line, exposed to --format templates.
+type SpyEvent struct {
+	Time string // HH:MM:SS
+	Kind string // tool, text, thinking, result, progress, hook, event, raw
+	Icon string // single emoji for the kind (per tool for tool events)
+	Tool string // tool name for tool events
+	Text string // the truncated summary, message or output
+	Diff string // with Diffs, the change an edit makes, as diff lines
+}
+
+var spyIcons = map[string]string{
+	"text":     "💬",
+	"thinking": "💭",
+	"result":   "📤",
+	"progress": "⏳",
+	"hook":     "🪝",
+	"event":    "📎",
+	"raw":      "📄",
+}
+
+var toolIcons = map[string]string{
+	"Bash":      "💻",
+	"Read":      "📖",
+	"Write":     "📝",
+	"Edit":      "✏️",
+	"Glob":      "🔍",
+	"Grep":      "🔍",
+	"WebFetch":  "🌐",
+	"WebSearch": "🌐",
+	"Task":      "🤖",
+}
+
+// Renderer formats session JSONL lines for display.
+type Renderer struct {
+	w     io.Writer
+	opts  SpyOptions
+	tmpl  *template.Template
+	now   func() time.Time
+	paths []*regexp.Regexp
+	grep  *regexp.Regexp
+	out   *plainText
+}
+
+// NewRenderer returns a renderer writing to w. It fails if opts.Format is not
+// a valid template.
+func NewRenderer(w io.Writer, opts SpyOptions) (*Renderer, error) {
+	r := &Renderer{w: w, opts: opts, now: time.Now}
+	if opts.Out != nil {
+		r.out = &plainText{w: opts.Out}
 	}
+	if opts.Format != "" {
+		tmpl, err := template.New("spy").Parse(opts.Format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --format template: %w", err)
+		}
+		r.tmpl = tmpl
+	}
+	for _, p := range opts.Paths {
+		r.paths = append(r.paths, pathGlob(p))
+	}
+	if opts.Grep != "" {
+		re, err := regexp.Compile(opts.Grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep: %w", err)
+		}
+		r.grep = re
+	}
+	return r, nil
+}
+
+// toolsOnly reports whether only tool calls can pass the filters.
+func (r *Renderer) toolsOnly() bool {
+	return r.opts.ToolsOnly || len(r.opts.Tools) > 0 || len(r.paths) > 0
+}
 
-	dirs := strings.Fields(strings.TrimSpace(string(out)))
-	if len(dirs) == 0 {
-		return "", fmt.Errorf("no project directories found in .claude/projects/")
+// passes applies the filters to an event: its tool ("" for other events),
+// the tool's raw input and its text.
+func (r *Renderer) passes(tool string, input json.RawMessage, text string) bool {
+	if tool == "" && r.toolsOnly() {
+		return false
+	}
+	if len(r.opts.Tools) > 0 && !containsFold(r.opts.Tools, tool) {
+		return false
+	}
+	if len(r.paths) > 0 && !r.matchesPath(input) {
+		return false
 	}
+	return r.grep == nil || r.grep.MatchString(text) || r.grep.Match(input)
+}
 
-	// Try each directory — look for a matching JSONL file.
-	for _, dir := range dirs {
-		candidate := fmt.Sprintf("/home/agent/.claude/projects/%s/%s.jsonl", dir, sessionID)
-		err := exec.Command("podman", "exec", name, "test", "-f", candidate).Run()
-		if err == nil {
-			return candidate, nil
+// matchesPath reports whether a tool call touches a file matching a --path
+// glob, tried against the path both relative to the workspace and as given.
+func (r *Renderer) matchesPath(input json.RawMessage) bool {
+	var in fileToolInput
+	json.Unmarshal(input, &in)
+	for _, file := range []string{in.FilePath, in.NotebookPath, in.Path} {
+		if file == "" {
+			continue
+		}
+		candidates := []string{path.Clean(file)}
+		if rel, ok := repoRelative(file, r.opts.Workspace); ok && r.opts.Workspace != "" {
+			candidates = append(candidates, rel)
+		}
+		for _, c := range candidates {
+			for _, re := range r.paths {
+				for p := c; p != "." && p != "/"; p = path.Dir(p) {
+					if re.MatchString(p) {
+						return true
+					}
+				}
+			}
 		}
 	}
+	return false
+}
 
-	// If the exact session file doesn't exist yet, fall back to the most recently
-	// modified JSONL in the first project directory.
-	fallbackCmd := fmt.Sprintf("ls -t /home/agent/.claude/projects/%s/*.jsonl 2>/dev/null | head -1", dirs[0])
-	out, err = exec.Command("podman", "exec", name, "sh", "-c", fallbackCmd).Output()
-	if err == nil && len(strings.TrimSpace(string(out))) > 0 {
-		return strings.TrimSpace(string(out)), nil
+// pathGlob compiles a --path glob: ** matches across directories, * and ?
+// within one. A file matches if it or one of its directories does, so
+// "src" and "src/*" both cover src/auth/token.go.
+func pathGlob(pattern string) *regexp.Regexp {
+	pattern = strings.TrimSuffix(path.Clean(pattern), "/")
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i:] == "/**":
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
 	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
 
-	return "", fmt.Errorf("session file %s.jsonl not found in any project directory", sessionID)
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
 }
 
-// renderLine parses a single JSONL line and emits formatted output.
-func renderLine(line string, opts SpyOptions) {
+// Render parses a single JSONL line and emits formatted output.
+func (r *Renderer) Render(line string) {
+	if r.opts.Raw {
+		if r.grep == nil || r.grep.MatchString(line) {
+			r.println(line)
+		}
+		return
+	}
+
 	var msg jsonlMessage
 	if err := json.Unmarshal([]byte(line), &msg); err != nil {
 		// Not valid JSON — print as-is with timestamp.
-		fmt.Printf("%s  %s\n", ts(), line)
+		if r.passes("", nil, line) {
+			r.emit(SpyEvent{Kind: "raw", Text: line})
+		}
 		return
 	}
 
-	if opts.JSON {
-		renderJSON(msg, opts)
+	if r.opts.JSON {
+		r.renderJSON(msg)
 		return
 	}
 
 	switch {
 	case msg.Message != nil:
-		renderMessage(msg, opts)
+		r.renderMessage(msg)
 	case msg.Type == "progress":
-		renderProgress(msg, opts)
+		r.renderProgress(msg)
 	default:
-		if opts.Verbose {
-			fmt.Printf("%s  [%s]\n", ts(), msg.Type)
+		if r.opts.Verbose && r.passes("", nil, msg.Type) {
+			r.emit(SpyEvent{Kind: "event", Text: "[" + msg.Type + "]"})
 		}
 	}
 }
 
-func renderMessage(msg jsonlMessage, opts SpyOptions) {
-	if msg.Message == nil {
-		return
-	}
-
+func (r *Renderer) renderMessage(msg jsonlMessage) {
 	role := msg.Message.Role
 	for _, block := range msg.Message.Content {
 		switch block.Type {
 		case "tool_use":
-			renderToolUse(block, opts)
-		case "text":
-			if opts.ToolsOnly {
+			if !r.passes(block.Name, block.Input, "") {
 				continue
 			}
-			if role == "assistant" {
-				text := truncate(block.Text, 120)
-				fmt.Printf("%s  %s\n", ts(), text)
+			var ti toolInput
+			json.Unmarshal(block.Input, &ti)
+			e := SpyEvent{Kind: "tool", Tool: block.Name, Text: toolSummary(block.Name, ti, r.opts)}
+			var diff []diffLine
+			if r.opts.Diffs {
+				diff = toolDiff(block.Name, block.Input)
+				e.Diff = plainDiff(diff)
+			}
+			r.emit(e)
+			// A --format template places .Diff itself.
+			if len(diff) > 0 && r.tmpl == nil {
+				r.renderDiff(ti.FilePath, diff)
+			}
+		case "text":
+			if role != "assistant" || !r.passes("", nil, block.Text) {
+				continue
 			}
+			r.emit(SpyEvent{Kind: "text", Text: truncate(block.Text, r.opts.width(defaultTextWidth))})
 		case "thinking":
-			if !opts.Thinking {
+			if !r.opts.Thinking || !r.passes("", nil, block.Thinking) {
 				continue
 			}
-			text := truncate(block.Thinking, 100)
-			fmt.Printf("%s  \033[2m[thinking] %s\033[0m\n", ts(), text)
+			r.emit(SpyEvent{Kind: "thinking", Text: truncate(block.Thinking, r.opts.width(defaultThinkingWidth))})
 		case "tool_result":
-			if !opts.Verbose {
+			if !r.opts.Verbose || !r.passes("", nil, block.Text) {
 				continue
 			}
-			text := truncate(block.Text, 80)
-			fmt.Printf("%s  \033[2m  -> %s\033[0m\n", ts(), text)
+			r.emit(SpyEvent{Kind: "result", Text: truncate(block.Text, r.opts.width(defaultDetailWidth))})
+		}
+	}
+}
+
+func (r *Renderer) renderProgress(msg jsonlMessage) {
+	if r.toolsOnly() || r.grep != nil {
+		return
+	}
+	var pd progressData
+	if err := json.Unmarshal(msg.Data, &pd); err != nil {
+		return
+	}
+
+	switch pd.Type {
+	case "bash_progress":
+		r.emit(SpyEvent{Kind: "progress", Text: fmt.Sprintf("running (%ds, %d lines)", pd.ElapsedTimeSeconds, pd.TotalLines)})
+	case "hook_progress":
+		r.emit(SpyEvent{Kind: "hook", Text: pd.Name})
+	default:
+		if r.opts.Verbose {
+			r.emit(SpyEvent{Kind: "event", Text: "[progress:" + pd.Type + "]"})
+		}
+	}
+}
+
+// emit writes one event using the template, compact or default layout.
+// Progress lines overwrite themselves with a carriage return.
+func (r *Renderer) emit(e SpyEvent) {
+	e.Time = r.now().Format("15:04:05")
+	e.Icon = spyIcons[e.Kind]
+	if e.Kind == "tool" {
+		e.Icon = "🔧"
+		if icon, ok := toolIcons[e.Tool]; ok {
+			e.Icon = icon
+		}
+	}
+
+	var line string
+	switch {
+	case r.tmpl != nil:
+		var b strings.Builder
+		if err := r.tmpl.Execute(&b, e); err != nil {
+			line = fmt.Sprintf("%s  [format error: %v]", e.Time, err)
+		} else {
+			line = strings.TrimRight(b.String(), "\n")
 		}
+	case r.opts.Compact:
+		line = fmt.Sprintf("%s %s %s", e.Time, e.Icon, e.Text)
+	default:
+		line = r.defaultLine(e)
+	}
+
+	if e.Kind == "progress" {
+		fmt.Fprint(r.w, "\r"+line)
+		return
+	}
+	r.println(line)
+}
+
+// println writes a line to the terminal and to Out.
+func (r *Renderer) println(line string) {
+	fmt.Fprintln(r.w, line)
+	if r.out != nil {
+		r.out.println(line)
+	}
+}
+
+// Err is the first error writing to Out.
+func (r *Renderer) Err() error {
+	if r.out == nil {
+		return nil
+	}
+	return r.out.err
+}
+
+// defaultLine is the standard spy layout.
+func (r *Renderer) defaultLine(e SpyEvent) string {
+	switch e.Kind {
+	case "tool":
+		return fmt.Sprintf("%s  > %s: %s", e.Time, e.Tool, e.Text)
+	case "thinking":
+		return fmt.Sprintf("%s  %s", e.Time, r.dim("[thinking] "+e.Text))
+	case "result":
+		return fmt.Sprintf("%s  %s", e.Time, r.dim("  -> "+e.Text))
+	case "progress":
+		return fmt.Sprintf("%s  ... %s", e.Time, e.Text)
+	case "hook":
+		return fmt.Sprintf("%s  [hook] %s", e.Time, e.Text)
+	default:
+		return fmt.Sprintf("%s  %s", e.Time, e.Text)
 	}
 }
 
-func renderToolUse(block contentBlock, opts SpyOptions) {
-	var ti toolInput
-	json.Unmarshal(block.Input, &ti)
+func (r *Renderer) dim(s string) string {
+	if r.opts.NoColor {
+		return s
+	}
+	return "\033[2m" + s + "\033[0m"
+}
 
-	summary := toolSummary(block.Name, ti)
-	fmt.Printf("%s  > %s: %s\n", ts(), block.Name, summary)
+// width returns the truncation length for a field whose default is def;
+// 0 means no truncation.
+func (o SpyOptions) width(def int) int {
+	switch {
+	case o.Wide:
+		return 0
+	case o.Width > 0:
+		return o.Width
+	default:
+		return def
+	}
 }
 
-func toolSummary(name string, ti toolInput) string {
+func toolSummary(name string, ti toolInput, opts SpyOptions) string {
+	detail := opts.width(defaultDetailWidth)
 	switch name {
 	case "Bash":
-		return truncate(ti.Command, 100)
+		return truncate(ti.Command, opts.width(defaultCommandWidth))
 	case "Read":
 		return ti.FilePath
 	case "Write":
@@ -263,60 +706,43 @@ func toolSummary(name string, ti toolInput) string {
 	case "WebFetch":
 		return ti.URL
 	case "WebSearch":
-		return truncate(ti.Query, 80)
+		return truncate(ti.Query, detail)
 	case "Task":
-		return truncate(ti.Content, 80)
+		return truncate(ti.Content, detail)
 	default:
 		if ti.FilePath != "" {
 			return ti.FilePath
 		}
 		if ti.Command != "" {
-			return truncate(ti.Command, 80)
+			return truncate(ti.Command, detail)
 		}
 		raw, _ := json.Marshal(ti)
-		return truncate(string(raw), 80)
+		return truncate(string(raw), detail)
 	}
 }
 
-func renderProgress(msg jsonlMessage, opts SpyOptions) {
-	if opts.ToolsOnly {
-		return
-	}
-	var pd progressData
-	if err := json.Unmarshal(msg.Data, &pd); err != nil {
-		return
-	}
-
-	switch pd.Type {
-	case "bash_progress":
-		fmt.Printf("\r%s  ... running (%ds, %d lines)", ts(), pd.ElapsedTimeSeconds, pd.TotalLines)
-	case "hook_progress":
-		fmt.Printf("%s  [hook] %s\n", ts(), pd.Name)
-	default:
-		if opts.Verbose {
-			fmt.Printf("%s  [progress:%s]\n", ts(), pd.Type)
-		}
-	}
-}
-
-func renderJSON(msg jsonlMessage, opts SpyOptions) {
+func (r *Renderer) renderJSON(msg jsonlMessage) {
 	if msg.Message == nil {
 		return
 	}
 
 	for _, block := range msg.Message.Content {
-		if opts.ToolsOnly && block.Type != "tool_use" {
+		tool, text := block.Name, block.Text+block.Thinking
+		if block.Type != "tool_use" {
+			tool = ""
+		}
+		if !r.passes(tool, block.Input, text) {
 			continue
 		}
-		if !opts.Thinking && block.Type == "thinking" {
+		if !r.opts.Thinking && block.Type == "thinking" {
 			continue
 		}
-		if !opts.Verbose && block.Type == "tool_result" {
+		if !r.opts.Verbose && block.Type == "tool_result" {
 			continue
 		}
 
 		event := map[string]interface{}{
-			"time": time.Now().Format(time.RFC3339),
+			"time": r.now().Format(time.RFC3339),
 			"type": block.Type,
 		}
 		switch block.Type {
@@ -324,7 +750,12 @@ func renderJSON(msg jsonlMessage, opts SpyOptions) {
 			event["tool"] = block.Name
 			var ti toolInput
 			json.Unmarshal(block.Input, &ti)
-			event["summary"] = toolSummary(block.Name, ti)
+			event["summary"] = toolSummary(block.Name, ti, r.opts)
+			if r.opts.Diffs {
+				if diff := toolDiff(block.Name, block.Input); len(diff) > 0 {
+					event["diff"] = plainDiff(diff)
+				}
+			}
 		case "text":
 			event["text"] = block.Text
 		case "thinking":
@@ -333,19 +764,17 @@ func renderJSON(msg jsonlMessage, opts SpyOptions) {
 			event["result"] = block.Text
 		}
 		out, _ := json.Marshal(event)
-		fmt.Println(string(out))
+		r.println(string(out))
 	}
 }
 
-func ts() string {
-	return time.Now().Format("15:04:05")
-}
-
+// truncate collapses s to a single line of at most max bytes; max <= 0
+// disables truncation.
 func truncate(s string, max int) string {
 	// Collapse to single line.
 	s = strings.ReplaceAll(s, "\n", " ")
 	s = strings.TrimSpace(s)
-	if len(s) > max {
+	if max > 0 && len(s) > max {
 		return s[:max] + "..."
 	}
 	return s
@@ -2,13 +2,29 @@ package container
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"io"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
+// render renders one line into a buffer with a fixed clock.
+func render(t *testing.T, line string, opts SpyOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	r, err := NewRenderer(&buf, opts)
+	if err != nil {
+		t.Fatalf("NewRenderer() error: %v", err)
+	}
+	r.now = func() time.Time { return time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC) }
+	r.Render(line)
+	return buf.String()
+}
+
 func TestTruncate(t *testing.T) {
 	tests := []struct {
 		name string
@@ -57,7 +73,7 @@ func TestToolSummary(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := toolSummary(tt.toolName, tt.input)
+			got := toolSummary(tt.toolName, tt.input, SpyOptions{})
 			if got != tt.want {
 				t.Errorf("toolSummary(%q, ...) = %q, want %q", tt.toolName, got, tt.want)
 			}
@@ -67,18 +83,7 @@ func TestToolSummary(t *testing.T) {
 
 func TestRenderLine_InvalidJSON(t *testing.T) {
 	// Capture stdout
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine("not valid json", SpyOptions{})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, "not valid json", SpyOptions{})
 
 	if !strings.Contains(output, "not valid json") {
 		t.Errorf("expected invalid JSON to be printed as-is, got: %q", output)
@@ -101,18 +106,7 @@ func TestRenderLine_ToolUse(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{})
 
 	if !strings.Contains(output, "Bash") {
 		t.Errorf("expected tool name 'Bash' in output, got: %q", output)
@@ -136,18 +130,7 @@ func TestRenderLine_TextBlock(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{})
 
 	if !strings.Contains(output, "Hello from Claude") {
 		t.Errorf("expected text in output, got: %q", output)
@@ -168,18 +151,7 @@ func TestRenderLine_ToolsOnlyFilter(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{ToolsOnly: true})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{ToolsOnly: true})
 
 	if strings.Contains(output, "This should be filtered") {
 		t.Errorf("text should be filtered in tools-only mode, got: %q", output)
@@ -200,18 +172,7 @@ func TestRenderLine_ThinkingHiddenByDefault(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{Thinking: false})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{Thinking: false})
 
 	if strings.Contains(output, "Internal reasoning") {
 		t.Errorf("thinking should be hidden by default, got: %q", output)
@@ -232,18 +193,7 @@ func TestRenderLine_ThinkingShownWhenEnabled(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{Thinking: true})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{Thinking: true})
 
 	if !strings.Contains(output, "Internal reasoning") {
 		t.Errorf("thinking should be shown when enabled, got: %q", output)
@@ -266,18 +216,7 @@ func TestRenderLine_JSONMode(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{JSON: true})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{JSON: true})
 
 	// Should be valid JSON
 	var result map[string]interface{}
@@ -304,18 +243,7 @@ func TestRenderLine_ProgressToolsOnlyFilter(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{ToolsOnly: true})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{ToolsOnly: true})
 
 	if strings.Contains(output, "running") {
 		t.Errorf("progress should be filtered in tools-only mode, got: %q", output)
@@ -336,18 +264,7 @@ func TestRenderLine_ToolResultHiddenByDefault(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{Verbose: false})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{Verbose: false})
 
 	if strings.Contains(output, "command output here") {
 		t.Errorf("tool_result should be hidden by default, got: %q", output)
@@ -368,18 +285,7 @@ func TestRenderLine_ToolResultShownWhenVerbose(t *testing.T) {
 	}
 	line, _ := json.Marshal(msg)
 
-	old := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	renderLine(string(line), SpyOptions{Verbose: true})
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	output := buf.String()
+	output := render(t, string(line), SpyOptions{Verbose: true})
 
 	if !strings.Contains(output, "command output here") {
 		t.Errorf("tool_result should be shown when verbose, got: %q", output)
@@ -416,6 +322,67 @@ func TestClaudeConfigParsing(t *testing.T) {
 	}
 }
 
+func TestPickSession(t *testing.T) {
+	files := parseSessionFiles(`1700000300 /home/dev/.claude/projects/-tmp/other.jsonl
+1700000100 /home/dev/.claude/projects/-home-dev-workspace-repo/attempt1.jsonl
+1700000200 /home/dev/.claude/projects/-home-dev-workspace-repo/attempt2.jsonl
+1700000200 /home/dev/.claude/projects/-home-dev-workspace-repo/last.jsonl
+stat: cannot statx '/home/dev/.claude/projects/*/*.jsonl': No such file or directory
+`)
+	if len(files) != 4 {
+		t.Fatalf("parseSessionFiles = %+v", files)
+	}
+	if claudeProjectDir("/home/dev/workspace/repo") != "-home-dev-workspace-repo" {
+		t.Errorf("claudeProjectDir = %s", claudeProjectDir("/home/dev/workspace/repo"))
+	}
+	for _, tc := range []struct{ workspace, lastID, want string }{
+		{"/home/dev/workspace/repo", "", "attempt2.jsonl"},
+		{"/home/dev/workspace/repo", "last", "last.jsonl"},
+		{"/srv/app", "", "other.jsonl"}, // no session of its own: newest anywhere
+	} {
+		if got := path.Base(pickSession(files, tc.workspace, tc.lastID)); got != tc.want {
+			t.Errorf("pickSession(%s, %q) = %s, want %s", tc.workspace, tc.lastID, got, tc.want)
+		}
+	}
+	if pickSession(nil, "/srv/app", "") != "" {
+		t.Error("no sessions, no pick")
+	}
+}
+
+func TestSpyFollowsNewerSession(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	quiet, poll := sessionQuietAfter, sessionPoll
+	sessionQuietAfter, sessionPoll = 100*time.Millisecond, 20*time.Millisecond
+	defer func() { sessionQuietAfter, sessionPoll = quiet, poll }()
+
+	dir := t.TempDir()
+	projects := layoutOf("spied").Path(".claude/projects") + "/" + claudeProjectDir(layoutOf("spied").Workspace)
+	os.WriteFile(filepath.Join(dir, "sessions"), []byte("1700000100 "+projects+"/old.jsonl\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "sessions2"), []byte("1700000100 "+projects+"/old.jsonl\n1700000200 "+projects+"/new.jsonl\n"), 0644)
+	fakePodman(t, `case "$*" in
+inspect*) echo running ;;
+*"stat -c"*) cat `+dir+`/sessions ;;
+*"tail -f"*old.jsonl) echo '{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"first attempt"}]}}'
+  cp `+dir+`/sessions2 `+dir+`/sessions; exec sleep 5 ;;
+*"tail -f"*new.jsonl) echo '{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"second attempt"}]}}' ;;
+esac`)
+
+	var out, info bytes.Buffer
+	if err := SpyStream(context.Background(), "spied", &out, &info, SpyOptions{NoColor: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "first attempt") || !strings.Contains(out.String(), "second attempt") {
+		t.Errorf("output:\n%s", out.String())
+	}
+	if !strings.Contains(info.String(), "--- newer session: "+projects+"/new.jsonl") {
+		t.Errorf("info:\n%s", info.String())
+	}
+}
+
 func TestJsonlMessageParsing(t *testing.T) {
 	inputJSON, _ := json.Marshal(toolInput{Command: "ls -la"})
 	raw := `{
@@ -453,3 +420,127 @@ func TestJsonlMessageParsing(t *testing.T) {
 		t.Errorf("expected second block type=text, got: %s", msg.Message.Content[1].Type)
 	}
 }
+
+func thinkingLine(text string) string {
+	line, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+		Role:    "assistant",
+		Content: []contentBlock{{Type: "thinking", Thinking: text}},
+	}})
+	return string(line)
+}
+
+func TestRenderer_NoColor(t *testing.T) {
+	if out := render(t, thinkingLine("hmm"), SpyOptions{Thinking: true}); !strings.Contains(out, "\033[2m") {
+		t.Errorf("expected dim escape by default, got: %q", out)
+	}
+	out := render(t, thinkingLine("hmm"), SpyOptions{Thinking: true, NoColor: true})
+	if out != "15:04:05  [thinking] hmm\n" {
+		t.Errorf("no-color output = %q", out)
+	}
+}
+
+func TestRenderer_Width(t *testing.T) {
+	long := strings.Repeat("x", 150)
+	line, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+		Role:    "assistant",
+		Content: []contentBlock{{Type: "text", Text: long}},
+	}})
+
+	tests := []struct {
+		name string
+		opts SpyOptions
+		want int
+	}{
+		{"default", SpyOptions{}, defaultTextWidth},
+		{"width", SpyOptions{Width: 40}, 40},
+		{"wide", SpyOptions{Wide: true, Width: 40}, 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := render(t, string(line), tt.opts)
+			if got := strings.Count(out, "x"); got != tt.want {
+				t.Errorf("rendered %d chars, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderer_Compact(t *testing.T) {
+	input, _ := json.Marshal(toolInput{FilePath: "/src/main.go"})
+	line, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+		Role:    "assistant",
+		Content: []contentBlock{{Type: "tool_use", Name: "Read", Input: input}},
+	}})
+	if out := render(t, string(line), SpyOptions{Compact: true}); out != "15:04:05 📖 /src/main.go\n" {
+		t.Errorf("compact output = %q", out)
+	}
+}
+
+func TestRenderer_Format(t *testing.T) {
+	input, _ := json.Marshal(toolInput{Command: "go test ./..."})
+	line, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+		Role:    "assistant",
+		Content: []contentBlock{{Type: "tool_use", Name: "Bash", Input: input}},
+	}})
+	out := render(t, string(line), SpyOptions{Format: "{{.Kind}}|{{.Tool}}|{{.Text}}"})
+	if out != "tool|Bash|go test ./...\n" {
+		t.Errorf("formatted output = %q", out)
+	}
+
+	if _, err := NewRenderer(&bytes.Buffer{}, SpyOptions{Format: "{{.Kind"}); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+}
+
+func TestRenderer_Raw(t *testing.T) {
+	if out := render(t, `{"type":"x"}`, SpyOptions{Raw: true}); out != "{\"type\":\"x\"}\n" {
+		t.Errorf("raw output = %q", out)
+	}
+}
+
+func TestRenderer_Filters(t *testing.T) {
+	lines := []string{
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./pkg/auth/..."}}]}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Edit","input":{"file_path":"/home/agent/workspace/repo/pkg/auth/token.go"}}]}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Edit","input":{"file_path":"/home/agent/workspace/repo/cmd/main.go"}}]}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Grep","input":{"pattern":"Token","path":"pkg/auth"}}]}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Now fixing the token expiry"}]}}`,
+		`{"type":"progress","data":{"type":"bash_progress","elapsedTimeSeconds":3,"totalLines":10}}`,
+	}
+	run := func(opts SpyOptions) string {
+		t.Helper()
+		opts.Workspace = "/home/agent/workspace/repo"
+		opts.Compact = true
+		var out strings.Builder
+		for _, l := range lines {
+			out.WriteString(render(t, l, opts))
+		}
+		return out.String()
+	}
+	count := func(out string) int { return strings.Count(out, "\n") }
+
+	if out := run(SpyOptions{Tools: []string{"bash"}}); count(out) != 1 || !strings.Contains(out, "go test") {
+		t.Errorf("--tool bash:\n%s", out)
+	}
+	if out := run(SpyOptions{Paths: []string{"pkg/auth/**"}}); count(out) != 2 || !strings.Contains(out, "token.go") || strings.Contains(out, "main.go") {
+		t.Errorf("--path pkg/auth/**:\n%s", out)
+	}
+	if out := run(SpyOptions{Paths: []string{"cmd"}, Tools: []string{"Edit"}}); count(out) != 1 || !strings.Contains(out, "main.go") {
+		t.Errorf("--path cmd --tool Edit:\n%s", out)
+	}
+	if out := run(SpyOptions{Paths: []string{"**/*.go"}, Tools: []string{"Edit"}}); count(out) != 2 {
+		t.Errorf("--path **/*.go:\n%s", out)
+	}
+	if out := run(SpyOptions{Grep: "(?i)token"}); count(out) != 3 || strings.Contains(out, "main.go") || strings.Contains(out, "running") {
+		t.Errorf("--grep token:\n%s", out)
+	}
+	if out := run(SpyOptions{Grep: "expiry", Raw: true}); count(out) != 1 {
+		t.Errorf("--grep with --raw:\n%s", out)
+	}
+	if out := run(SpyOptions{Tools: []string{"Edit"}, JSON: true}); count(out) != 2 || !strings.Contains(out, `"tool":"Edit"`) {
+		t.Errorf("--tool Edit --json:\n%s", out)
+	}
+	if _, err := NewRenderer(&bytes.Buffer{}, SpyOptions{Grep: "("}); err == nil {
+		t.Error("invalid --grep accepted")
+	}
+}
@@ -0,0 +1,284 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// defaultDiffLines caps the lines of a diff spy shows per tool call.
+	defaultDiffLines = 20
+	// diffContext is how many unchanged lines are kept around a change.
+	diffContext = 2
+	// maxDiffCells bounds the line-by-line comparison; beyond it an edit is
+	// shown as its old lines removed and its new lines added.
+	maxDiffCells = 250000
+	// diffIndent lines diffs up under the text after the time.
+	diffIndent = "          "
+)
+
+// editInput holds the input fields of the tools that change files.
+type editInput struct {
+	FilePath  string `json:"file_path"`
+	OldString string `json:"old_string"`
+	NewString string `json:"new_string"`
+	Content   string `json:"content"`
+	Edits     []struct {
+		OldString string `json:"old_string"`
+		NewString string `json:"new_string"`
+	} `json:"edits"`
+}
+
+// diffLine is one line of a diff: Op is ' ' for context, '-', '+', or '~'
+// for unchanged lines left out.
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// toolDiff is the change an Edit, MultiEdit or Write call makes, as far as
+// its input tells: a Write shows as all added lines, since the file it
+// replaces isn't in the transcript. Other tools have none.
+func toolDiff(tool string, input json.RawMessage) []diffLine {
+	var in editInput
+	if json.Unmarshal(input, &in) != nil {
+		return nil
+	}
+	switch tool {
+	case "Edit":
+		return lineDiff(splitLines(in.OldString), splitLines(in.NewString))
+	case "MultiEdit":
+		var diff []diffLine
+		for i, e := range in.Edits {
+			if i > 0 {
+				diff = append(diff, diffLine{Op: '~'})
+			}
+			diff = append(diff, lineDiff(splitLines(e.OldString), splitLines(e.NewString))...)
+		}
+		return diff
+	case "Write":
+		var diff []diffLine
+		for _, l := range splitLines(in.Content) {
+			diff = append(diff, diffLine{'+', l})
+		}
+		return diff
+	}
+	return nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineDiff diffs a against b by longest common subsequence, keeping
+// diffContext unchanged lines around each change.
+func lineDiff(a, b []string) []diffLine {
+	var diff []diffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			diff = append(diff, diffLine{'-', l})
+		}
+		for _, l := range b {
+			diff = append(diff, diffLine{'+', l})
+		}
+		return diff
+	}
+	// lcs[i][j] is the common subsequence length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			diff = append(diff, diffLine{'+', b[j]})
+			j++
+		default:
+			diff = append(diff, diffLine{'-', a[i]})
+			i++
+		}
+	}
+	return trimContext(diff)
+}
+
+// trimContext drops unchanged lines further than diffContext from a change,
+// marking each run left out between changes with a '~' line.
+func trimContext(diff []diffLine) []diffLine {
+	near := make([]bool, len(diff))
+	for i, l := range diff {
+		if l.Op == ' ' {
+			continue
+		}
+		for k := max(0, i-diffContext); k <= i+diffContext && k < len(diff); k++ {
+			near[k] = true
+		}
+	}
+	var out []diffLine
+	for i, l := range diff {
+		if near[i] {
+			out = append(out, l)
+		} else if len(out) > 0 && out[len(out)-1].Op != '~' {
+			out = append(out, diffLine{Op: '~'})
+		}
+	}
+	if len(out) > 0 && out[len(out)-1].Op == '~' {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// plainDiff is the diff as unified-diff text, for --json and --format.
+func plainDiff(diff []diffLine) string {
+	var b strings.Builder
+	for _, l := range diff {
+		if l.Op == '~' {
+			b.WriteString("...\n")
+			continue
+		}
+		b.WriteByte(l.Op)
+		b.WriteString(l.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// renderDiff writes a tool call's diff under its line: removed lines red,
+// added lines green, each highlighted for the file's language.
+func (r *Renderer) renderDiff(file string, diff []diffLine) {
+	limit := defaultDiffLines
+	if r.opts.Wide {
+		limit = 0
+	}
+	width := r.opts.width(defaultTextWidth)
+	lang := languageOf(file)
+	for n, l := range diff {
+		if limit > 0 && n == limit {
+			r.println(diffIndent + r.dim(fmt.Sprintf("... %d more line(s)", len(diff)-n)))
+			return
+		}
+		if l.Op == '~' {
+			r.println(diffIndent + r.dim("⋯"))
+			continue
+		}
+		text := l.Text
+		if width > 0 && len(text) > width {
+			text = text[:width] + "..."
+		}
+		if r.opts.NoColor {
+			r.println(diffIndent + string(l.Op) + " " + text)
+			continue
+		}
+		color := map[byte]string{'-': "\033[31m", '+': "\033[32m"}[l.Op]
+		r.println(diffIndent + color + string(l.Op) + " " + lang.highlight(text) + "\033[0m")
+	}
+}
+
+// language is what highlighting needs to know of a file's language.
+type language struct {
+	comment  string // line comment marker; "" for none
+	keywords map[string]bool
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cLike = "if else for while do switch case default break continue return new try catch finally throw class interface extends implements static public private protected const var let function import export from"
+
+	languages = map[string]language{
+		".go":   {"//", words("package import func type struct interface map chan go defer select if else for range switch case default break continue return var const fallthrough goto nil true false")},
+		".js":   {"//", words(cLike + " async await typeof instanceof of null undefined true false this yield")},
+		".ts":   {"//", words(cLike + " async await typeof instanceof of null undefined true false this yield type enum readonly as")},
+		".php":  {"//", words(cLike + " namespace use fn echo foreach as match null true false readonly enum trait abstract final public")},
+		".py":   {"#", words("def class if elif else for while return import from as with try except finally raise pass break continue lambda yield None True False and or not in is async await")},
+		".rs":   {"//", words("fn let mut struct enum impl trait pub use mod match if else for while loop return self Self crate const static async await move ref where type true false")},
+		".sh":   {"#", words("if then else elif fi for while do done case esac function return local export in")},
+		".java": {"//", words(cLike + " package void null true false this final abstract")},
+		".rb":   {"#", words("def class module if elsif else unless end do while until return require yield nil true false self")},
+		".yaml": {"#", nil},
+	}
+	languageAliases = map[string]string{
+		".tsx": ".ts", ".jsx": ".js", ".mjs": ".js", ".cjs": ".js",
+		".bash": ".sh", ".yml": ".yaml", ".toml": ".yaml", ".kt": ".java",
+	}
+)
+
+// languageOf picks the highlighting for a file by its extension; files of
+// other kinds are not highlighted.
+func languageOf(file string) language {
+	ext := strings.ToLower(path.Ext(file))
+	if alias, ok := languageAliases[ext]; ok {
+		ext = alias
+	}
+	return languages[ext]
+}
+
+// highlight marks keywords bold, strings italic and comments faint. These
+// leave the foreground alone, so the diff's red and green show through.
+func (lang language) highlight(line string) string {
+	if lang.comment == "" && lang.keywords == nil {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case lang.comment != "" && strings.HasPrefix(line[i:], lang.comment):
+			b.WriteString("\033[2m" + line[i:] + "\033[22m")
+			return b.String()
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			b.WriteString("\033[3m" + line[i:end] + "\033[23m")
+			i = end
+		case isWordByte(c):
+			end := i
+			for end < len(line) && isWordByte(line[end]) {
+				end++
+			}
+			if w := line[i:end]; lang.keywords[w] {
+				b.WriteString("\033[1m" + w + "\033[22m")
+			} else {
+				b.WriteString(w)
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,114 @@
+package container
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func toolUseLine(tool string, input map[string]interface{}) string {
+	raw, _ := json.Marshal(input)
+	line, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+		Role:    "assistant",
+		Content: []contentBlock{{Type: "tool_use", Name: tool, Input: raw}},
+	}})
+	return string(line)
+}
+
+func TestLineDiff(t *testing.T) {
+	old := strings.Split("a\nb\nc\nd\ne\nf\ng\nh\ni\nj", "\n")
+	new := strings.Split("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk", "\n")
+	got := plainDiff(lineDiff(old, new))
+	want := " a\n-b\n+B\n c\n d\n...\n i\n j\n+k\n"
+	if got != want {
+		t.Errorf("lineDiff =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToolDiff(t *testing.T) {
+	edits := map[string]interface{}{"file_path": "x.go", "edits": []map[string]string{
+		{"old_string": "one", "new_string": "uno"},
+		{"old_string": "two\n", "new_string": ""},
+	}}
+	raw, _ := json.Marshal(edits)
+	if got := plainDiff(toolDiff("MultiEdit", raw)); got != "-one\n+uno\n...\n-two\n" {
+		t.Errorf("MultiEdit diff = %q", got)
+	}
+	raw, _ = json.Marshal(map[string]string{"file_path": "new.txt", "content": "hello\nworld\n"})
+	if got := plainDiff(toolDiff("Write", raw)); got != "+hello\n+world\n" {
+		t.Errorf("Write diff = %q", got)
+	}
+	if diff := toolDiff("Bash", json.RawMessage(`{"command": "ls"}`)); diff != nil {
+		t.Errorf("Bash diff = %v", diff)
+	}
+}
+
+func TestRenderer_Diffs(t *testing.T) {
+	line := toolUseLine("Edit", map[string]interface{}{
+		"file_path":  "/workspace/auth.go",
+		"old_string": "\tif exp.Before(now) {\n\t\treturn ErrExpired",
+		"new_string": "\tif !exp.After(now) {\n\t\treturn ErrExpired",
+	})
+
+	if got := render(t, line, SpyOptions{NoColor: true}); strings.Contains(got, "exp.") {
+		t.Errorf("without --diffs the diff should not show: %q", got)
+	}
+
+	got := render(t, line, SpyOptions{NoColor: true, Diffs: true})
+	want := "15:04:05  > Edit: /workspace/auth.go\n" +
+		diffIndent + "- \tif exp.Before(now) {\n" +
+		diffIndent + "+ \tif !exp.After(now) {\n" +
+		diffIndent + "  \t\treturn ErrExpired\n"
+	if got != want {
+		t.Errorf("--diffs got\n%q\nwant\n%q", got, want)
+	}
+
+	got = render(t, line, SpyOptions{Diffs: true})
+	if !strings.Contains(got, "\033[31m- \t\033[1mif\033[22m exp.Before(now) {\033[0m") {
+		t.Errorf("removed line should be red with its keyword bold: %q", got)
+	}
+
+	got = render(t, line, SpyOptions{Diffs: true, JSON: true})
+	var event map[string]string
+	if err := json.Unmarshal([]byte(got), &event); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(event["diff"], "-\tif exp.Before(now) {\n+") {
+		t.Errorf("--json diff = %q", event["diff"])
+	}
+
+	got = render(t, line, SpyOptions{Diffs: true, Format: "{{.Tool}}\n{{.Diff}}"})
+	if strings.Count(got, "exp.Before") != 1 {
+		t.Errorf("--format should show the diff once, where the template puts it: %q", got)
+	}
+}
+
+func TestRenderer_DiffLimit(t *testing.T) {
+	var content []string
+	for i := 0; i < 30; i++ {
+		content = append(content, "line")
+	}
+	line := toolUseLine("Write", map[string]interface{}{"file_path": "big.txt", "content": strings.Join(content, "\n")})
+
+	got := render(t, line, SpyOptions{NoColor: true, Diffs: true})
+	if n := strings.Count(got, "+ line"); n != defaultDiffLines {
+		t.Errorf("showed %d lines, want %d", n, defaultDiffLines)
+	}
+	if !strings.Contains(got, "... 10 more line(s)") {
+		t.Errorf("the cut should say how much is left: %q", got)
+	}
+	if got := render(t, line, SpyOptions{NoColor: true, Diffs: true, Wide: true}); strings.Count(got, "+ line") != 30 {
+		t.Errorf("--wide should show the whole diff")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := languageOf("main.go").highlight(`return "if" // if`)
+	want := "\033[1mreturn\033[22m \033[3m\"if\"\033[23m \033[2m// if\033[22m"
+	if got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+	if got := languageOf("notes.txt").highlight("if x"); got != "if x" {
+		t.Errorf("unknown languages should be left alone: %q", got)
+	}
+}
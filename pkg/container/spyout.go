@@ -0,0 +1,120 @@
+package container
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// ansiEscape matches the color and style sequences the renderer writes.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// RotatingFile appends to a file, rotating it the way agent logs rotate
+// (path → path.1 → path.2 ..., dropping the oldest) before a write would take
+// it past its size limit. Writes are never split across files, so a file
+// holds whole lines when each write is one.
+type RotatingFile struct {
+	path    string
+	maxSize int64 // 0: never rotate
+	keep    int
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+}
+
+// OpenRotating opens path for appending, creating it if need be.
+func OpenRotating(path string, maxSize int64, keep int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OpenSpyFile opens a file for spy to append to, rotated at spy.max_size
+// keeping spy.keep files; unset, they default to the logs settings.
+func OpenSpyFile(path string) (*RotatingFile, error) {
+	maxSize, keep := spyRotation()
+	return OpenRotating(path, maxSize, keep)
+}
+
+func spyRotation() (int64, int) {
+	maxSize, keep := logRotation()
+	if cfg, err := config.Load(); err == nil {
+		if n, err := ParseSize(cfg.Spy.MaxSize); err == nil && n > 0 {
+			maxSize = n
+		}
+		if cfg.Spy.Keep > 0 {
+			keep = cfg.Spy.Keep
+		}
+	}
+	return maxSize, keep
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if the file would outgrow its limit.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.keep > 0 {
+		for i := r.keep - 1; i >= 1; i-- {
+			os.Rename(rotated(r.path, i), rotated(r.path, i+1))
+		}
+		if err := os.Rename(r.path, rotated(r.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the current file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// plainText writes lines to w with the ANSI escapes removed, remembering
+// the first error.
+type plainText struct {
+	w   io.Writer
+	err error
+}
+
+func (p *plainText) println(line string) {
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, ansiEscape.ReplaceAllString(line, "")+"\n")
+	}
+}
@@ -0,0 +1,88 @@
+package container
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spy.log")
+	os.WriteFile(path, []byte("old line\n"), 0600)
+
+	f, err := OpenRotating(path, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	want := map[string]string{
+		path:             "fourth line\n",
+		rotated(path, 1): "third line\n",
+		rotated(path, 2): "second line\n",
+		path + ".3":      "",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if content == "" {
+			if err == nil {
+				t.Errorf("%s should not exist, has %q", filepath.Base(file), data)
+			}
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, content)
+		}
+	}
+}
+
+func TestRotatingFile_NoLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spy.log")
+	f, err := OpenRotating(path, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(strings.Repeat("x", 100) + "\n"))
+	f.Write([]byte("y\n"))
+	f.Close()
+	if data, _ := os.ReadFile(path); len(data) != 103 {
+		t.Errorf("file has %d bytes, want 103", len(data))
+	}
+	if _, err := os.Stat(rotated(path, 1)); err == nil {
+		t.Error("a file without a limit should not rotate")
+	}
+}
+
+func TestRenderer_Out(t *testing.T) {
+	var out bytes.Buffer
+	term := render(t, thinkingLine("hmm"), SpyOptions{Thinking: true, Out: &out})
+	if !strings.Contains(term, "\033[2m") {
+		t.Errorf("terminal should keep its colors, got %q", term)
+	}
+	if out.String() != "15:04:05  [thinking] hmm\n" {
+		t.Errorf("--out got %q", out.String())
+	}
+
+	out.Reset()
+	progress := `{"type":"progress","data":{"type":"bash_progress","elapsedTimeSeconds":3,"totalLines":10}}`
+	if term := render(t, progress, SpyOptions{Out: &out}); !strings.Contains(term, "running (3s") {
+		t.Errorf("terminal progress = %q", term)
+	}
+	if out.Len() != 0 {
+		t.Errorf("progress lines should stay off --out, got %q", out.String())
+	}
+
+	out.Reset()
+	raw := `{"type":"user"}`
+	render(t, raw, SpyOptions{Raw: true, Out: &out})
+	if out.String() != raw+"\n" {
+		t.Errorf("--raw --out got %q", out.String())
+	}
+}
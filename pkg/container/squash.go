@@ -0,0 +1,269 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Commit policies (config commits.policy).
+const (
+	CommitsKeep         = "keep"
+	CommitsSquashFixups = "squash-fixups"
+	CommitsSquashAll    = "squash-all"
+)
+
+// preSquashRef keeps the branch head from before the last squash, so a
+// rewrite can be undone with `git reset --hard refs/agentctl/pre-squash`.
+const preSquashRef = "refs/agentctl/pre-squash"
+
+// fixupSubject matches throwaway commit subjects that belong to the commit
+// before them.
+var fixupSubject = regexp.MustCompile(`(?i)^((fixup|squash|amend)!|wip\b|(fix(es|ed)?|oops|typo|lint|format(ting)?|more|again|tmp|temp|cleanup)( (again|it|tests?|lint|build|typo|ci|more|\d+))*[.!]*$)`)
+
+// wipPrefix is stripped from the subjects of commits that are kept.
+var wipPrefix = regexp.MustCompile(`(?i)^(wip|tmp)[:\s-]+`)
+
+// SquashOptions controls Squash.
+type SquashOptions struct {
+	Policy string // keep, squash-fixups or squash-all
+	// Task is what the agent was asked to do; squash-all writes the commit
+	// message from it.
+	Task   string
+	DryRun bool
+}
+
+// SquashResult describes a rewrite: the commits on the branch before and
+// the subjects after.
+type SquashResult struct {
+	Base   string
+	Before []string
+	After  []string
+	Head   string // new head commit; empty when nothing changed or DryRun
+}
+
+// Changed reports whether the rewrite alters the branch.
+func (r *SquashResult) Changed() bool {
+	if len(r.Before) != len(r.After) {
+		return true
+	}
+	for i := range r.Before {
+		if r.Before[i] != r.After[i] {
+			return true
+		}
+	}
+	return false
+}
+
+type commitInfo struct {
+	SHA, Tree, AuthorName, AuthorEmail, AuthorDate, Message string
+}
+
+func (c commitInfo) subject() string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return subject
+}
+
+// gitRunner runs git in a workspace with extra environment.
+type gitRunner func(env []string, args ...string) (string, error)
+
+// agentGit runs git in an agent's workspace.
+func agentGit(name string) gitRunner {
+	ws := layoutOf(name).Workspace
+	return func(env []string, args ...string) (string, error) {
+		a := []string{"exec"}
+		for _, e := range env {
+			a = append(a, "-e", e)
+		}
+		a = append(append(a, containerName(name), "git", "-C", ws), args...)
+		out, err := podmanLong(a...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return string(out), nil
+	}
+}
+
+// dirGit runs git in a host checkout.
+func dirGit(dir string) gitRunner {
+	return func(env []string, args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return string(out), nil
+	}
+}
+
+// Squash tidies an agent's branch per the commit policy before a PR.
+func Squash(name string, opts SquashOptions) (*SquashResult, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Task == "" {
+		opts.Task = agent.Task
+		if opts.Task == "" {
+			opts.Task = agent.Intent
+		}
+	}
+	return squash(agentGit(name), opts)
+}
+
+// SquashDir is Squash for a host checkout, as used by pipelines.
+func SquashDir(dir string, opts SquashOptions) (*SquashResult, error) {
+	return squash(dirGit(dir), opts)
+}
+
+func squash(git gitRunner, opts SquashOptions) (*SquashResult, error) {
+	switch opts.Policy {
+	case "", CommitsKeep:
+		return &SquashResult{}, nil
+	case CommitsSquashFixups, CommitsSquashAll:
+	default:
+		return nil, fmt.Errorf("unknown commit policy %q (want keep, squash-fixups or squash-all)", opts.Policy)
+	}
+
+	base, err := branchBase(git)
+	if err != nil {
+		return nil, err
+	}
+	if merges, _ := git(nil, "rev-list", "--min-parents=2", base+"..HEAD"); strings.TrimSpace(merges) != "" {
+		return nil, fmt.Errorf("branch has merge commits; squash it by hand")
+	}
+	out, err := git(nil, "log", "--reverse", "--format=%H%x1f%T%x1f%an%x1f%ae%x1f%aI%x1f%B%x1e", base+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+	commits := parseCommitLog(out)
+	res := &SquashResult{Base: base}
+	for _, c := range commits {
+		res.Before = append(res.Before, c.subject())
+	}
+	if len(commits) == 0 {
+		return res, nil
+	}
+
+	type rewrite struct {
+		author  commitInfo
+		tree    string
+		message string
+	}
+	var plan []rewrite
+	if opts.Policy == CommitsSquashAll {
+		stat, _ := git(nil, "diff", "--shortstat", base, "HEAD")
+		plan = append(plan, rewrite{commits[0], commits[len(commits)-1].Tree, squashAllMessage(opts.Task, commits, stat)})
+	} else {
+		for _, group := range groupFixups(commits) {
+			plan = append(plan, rewrite{group[0], group[len(group)-1].Tree, fixupGroupMessage(group)})
+		}
+	}
+	for _, r := range plan {
+		subject, _, _ := strings.Cut(r.message, "\n")
+		res.After = append(res.After, subject)
+	}
+	if opts.DryRun || !res.Changed() {
+		return res, nil
+	}
+
+	head := commits[len(commits)-1].SHA
+	parent := base
+	for _, r := range plan {
+		env := []string{
+			"GIT_AUTHOR_NAME=" + r.author.AuthorName,
+			"GIT_AUTHOR_EMAIL=" + r.author.AuthorEmail,
+			"GIT_AUTHOR_DATE=" + r.author.AuthorDate,
+		}
+		sha, err := git(env, "commit-tree", r.tree, "-p", parent, "-m", r.message)
+		if err != nil {
+			return nil, err
+		}
+		parent = strings.TrimSpace(sha)
+	}
+	if _, err := git(nil, "update-ref", preSquashRef, head); err != nil {
+		return nil, err
+	}
+	// The final tree is unchanged, so a soft reset leaves the index and any
+	// uncommitted work exactly as they were.
+	if _, err := git(nil, "reset", "--soft", parent); err != nil {
+		return nil, err
+	}
+	res.Head = parent
+	return res, nil
+}
+
+// branchBase finds where the branch left the default branch.
+func branchBase(git gitRunner) (string, error) {
+	for _, ref := range []string{"origin/HEAD", "origin/main", "origin/master"} {
+		if out, err := git(nil, "merge-base", "HEAD", ref); err == nil {
+			return strings.TrimSpace(out), nil
+		}
+	}
+	return "", fmt.Errorf("cannot find the branch's base (no origin/HEAD, origin/main or origin/master)")
+}
+
+func parseCommitLog(out string) []commitInfo {
+	var commits []commitInfo
+	for _, rec := range strings.Split(out, "\x1e") {
+		f := strings.SplitN(strings.TrimLeft(rec, "\n"), "\x1f", 6)
+		if len(f) < 6 {
+			continue
+		}
+		commits = append(commits, commitInfo{f[0], f[1], f[2], f[3], f[4], strings.TrimSpace(f[5])})
+	}
+	return commits
+}
+
+// groupFixups folds each throwaway commit into the group of the commit
+// before it. Leading throwaway commits form the first group themselves.
+func groupFixups(commits []commitInfo) [][]commitInfo {
+	var groups [][]commitInfo
+	for _, c := range commits {
+		if len(groups) > 0 && fixupSubject.MatchString(c.subject()) {
+			groups[len(groups)-1] = append(groups[len(groups)-1], c)
+			continue
+		}
+		groups = append(groups, []commitInfo{c})
+	}
+	return groups
+}
+
+// fixupGroupMessage keeps the leading commit's message, without a WIP
+// prefix.
+func fixupGroupMessage(group []commitInfo) string {
+	return wipPrefix.ReplaceAllString(group[0].Message, "")
+}
+
+// squashAllMessage writes one commit message from the task and a summary of
+// the change and the commits it replaces.
+func squashAllMessage(task string, commits []commitInfo, shortstat string) string {
+	task = strings.TrimSpace(task)
+	subject := wipPrefix.ReplaceAllString(commits[0].subject(), "")
+	if task != "" {
+		subject, _, _ = strings.Cut(task, "\n")
+		subject = strings.TrimSpace(subject)
+	}
+	if len(subject) > 72 {
+		subject = strings.TrimSpace(subject[:69]) + "..."
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n")
+	if task != "" && task != subject {
+		b.WriteString("\n" + task + "\n")
+	}
+	if stat := strings.TrimSpace(shortstat); stat != "" {
+		b.WriteString("\n" + stat + "\n")
+	}
+	if len(commits) > 1 {
+		b.WriteString("\nSquashed commits:\n")
+		for _, c := range commits {
+			b.WriteString("- " + c.subject() + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo makes a checkout whose origin/main is one commit behind the
+// branch commits given as subject -> file content.
+func gitRepo(t *testing.T, commits ...string) (string, gitRunner) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := dirGit(dir)
+	env := []string{"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com"}
+	run := func(args ...string) {
+		if _, err := git(env, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q", "-b", "work")
+	os.WriteFile(filepath.Join(dir, "README"), []byte("base\n"), 0644)
+	run("add", "-A")
+	run("commit", "-qm", "Initial commit")
+	run("update-ref", "refs/remotes/origin/main", "HEAD")
+	for i, subject := range commits {
+		os.WriteFile(filepath.Join(dir, "file.txt"), []byte(strings.Repeat("x", i+1)+"\n"), 0644)
+		run("add", "-A")
+		run("commit", "-qm", subject)
+	}
+	return dir, func(extra []string, args ...string) (string, error) {
+		return git(append(env, extra...), args...)
+	}
+}
+
+func TestSquashFixups(t *testing.T) {
+	dir, git := gitRepo(t, "WIP: Add login", "fix", "fix tests", "Add logout", "typo")
+	tree, _ := git(nil, "rev-parse", "HEAD^{tree}")
+
+	res, err := SquashDir(dir, SquashOptions{Policy: CommitsSquashFixups, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Before) != 5 || strings.Join(res.After, "|") != "Add login|Add logout" || res.Head != "" {
+		t.Fatalf("dry run = %+v", res)
+	}
+
+	if _, err := squash(git, SquashOptions{Policy: CommitsSquashFixups}); err != nil {
+		t.Fatal(err)
+	}
+	log, _ := git(nil, "log", "--format=%s", "origin/main..HEAD")
+	if strings.TrimSpace(log) != "Add logout\nAdd login" {
+		t.Errorf("log after squash:\n%s", log)
+	}
+	if after, _ := git(nil, "rev-parse", "HEAD^{tree}"); after != tree {
+		t.Errorf("tree changed: %s -> %s", tree, after)
+	}
+	if n, _ := git(nil, "rev-list", "--count", "origin/main.."+preSquashRef); strings.TrimSpace(n) != "5" {
+		t.Errorf("pre-squash ref has %s commits, want 5", n)
+	}
+}
+
+func TestSquashAll(t *testing.T) {
+	_, git := gitRepo(t, "Add login", "fix", "Add logout")
+
+	res, err := squash(git, SquashOptions{Policy: CommitsSquashAll, Task: "Add session handling\n\nLogin and logout endpoints."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.After) != 1 || res.After[0] != "Add session handling" {
+		t.Fatalf("after = %v", res.After)
+	}
+	msg, _ := git(nil, "log", "-1", "--format=%B")
+	for _, want := range []string{"Login and logout endpoints.", "1 file changed", "- Add login\n- fix\n- Add logout"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+
+	// Already one commit with the same subject: nothing to rewrite.
+	head, _ := git(nil, "rev-parse", "HEAD")
+	res, err = squash(git, SquashOptions{Policy: CommitsSquashAll, Task: "Add session handling"})
+	if err != nil || res.Changed() || res.Head != "" {
+		t.Fatalf("second squash = %+v, %v", res, err)
+	}
+	if again, _ := git(nil, "rev-parse", "HEAD"); again != head {
+		t.Error("tidy branch was rewritten")
+	}
+}
+
+func TestSquashPolicy(t *testing.T) {
+	_, git := gitRepo(t, "Add login", "fix")
+	if res, err := squash(git, SquashOptions{Policy: CommitsKeep}); err != nil || res.Changed() {
+		t.Errorf("keep = %+v, %v", res, err)
+	}
+	if _, err := squash(git, SquashOptions{Policy: "rebase"}); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func TestFixupSubject(t *testing.T) {
+	for subject, want := range map[string]bool{
+		"fix":                     true,
+		"Fix tests":               true,
+		"fixup! Add login":        true,
+		"wip":                     true,
+		"WIP: Add login":          true,
+		"oops.":                   true,
+		"lint":                    true,
+		"Fix login redirect loop": false,
+		"Add login":               false,
+		"Wipe cache on logout":    false,
+		"Format dates in UTC":     false,
+	} {
+		if got := fixupSubject.MatchString(subject); got != want {
+			t.Errorf("fixupSubject(%q) = %v, want %v", subject, got, want)
+		}
+	}
+}
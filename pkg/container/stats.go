@@ -0,0 +1,221 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// TokenUsage counts the tokens an agent's sessions used, by kind.
+type TokenUsage struct {
+	Input      int `json:"input"`
+	Output     int `json:"output"`
+	CacheRead  int `json:"cache_read,omitempty"`
+	CacheWrite int `json:"cache_write,omitempty"`
+}
+
+// Total is every token, cached or not.
+func (u TokenUsage) Total() int {
+	return u.Input + u.Output + u.CacheRead + u.CacheWrite
+}
+
+// cost is what u costs at p, in dollars.
+func (u TokenUsage) cost(p config.Price) float64 {
+	read, write := p.Input, p.Input
+	if p.CacheRead != nil {
+		read = *p.CacheRead
+	}
+	if p.CacheWrite != nil {
+		write = *p.CacheWrite
+	}
+	return (float64(u.Input)*p.Input + float64(u.Output)*p.Output +
+		float64(u.CacheRead)*read + float64(u.CacheWrite)*write) / 1e6
+}
+
+// Stats groupings.
+const (
+	GroupByRepo     = "repo"
+	GroupByTemplate = "template"
+	GroupByBackend  = "backend"
+)
+
+// Group keys for runs without a template or a chosen backend.
+const (
+	adhocTemplate  = "adhoc"
+	defaultBackend = "default"
+)
+
+// StatsFilter narrows the history records Stats looks at.
+type StatsFilter struct {
+	Repo    string
+	Since   time.Time
+	GroupBy string // repo (default), template or backend
+}
+
+// RunGroup is how the runs of one repo, template or backend went.
+type RunGroup struct {
+	Key       string `json:"key"`
+	Runs      int    `json:"runs"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	// SuccessRate is of the runs that succeeded or failed, 0-1; killed and
+	// pruned agents never finished either way.
+	SuccessRate  float64 `json:"success_rate"`
+	MeanAttempts float64 `json:"mean_attempts"`
+	MeanDuration float64 `json:"mean_duration_seconds"`
+	Tokens       int     `json:"tokens"`
+	// Cost is in dollars, over the PricedRuns whose backend has a price.
+	Cost       float64 `json:"cost"`
+	PricedRuns int     `json:"priced_runs"`
+}
+
+// Stats aggregates history into per-group success rates, attempts,
+// durations and costs, most runs first.
+func Stats(records []*AgentHistory, filter StatsFilter, prices map[string]config.Price) ([]RunGroup, error) {
+	if filter.GroupBy == "" {
+		filter.GroupBy = GroupByRepo
+	}
+	key, err := statsKey(filter.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	type totals struct {
+		RunGroup
+		attempted, timed int
+		attempts         int
+		duration         time.Duration
+	}
+	groups := map[string]*totals{}
+	for _, h := range records {
+		if filter.Repo != "" && repoKey(h.Repo) != repoKey(filter.Repo) {
+			continue
+		}
+		if h.Created.Before(filter.Since) {
+			continue
+		}
+		k := key(h)
+		g := groups[k]
+		if g == nil {
+			g = &totals{RunGroup: RunGroup{Key: k}}
+			groups[k] = g
+		}
+		g.Runs++
+		switch runOutcome(h.Result) {
+		case "succeeded":
+			g.Succeeded++
+		case "failed":
+			g.Failed++
+		}
+		if h.Attempts > 0 {
+			g.attempted++
+			g.attempts += h.Attempts
+		}
+		if !h.Created.IsZero() && h.CompletedAt.After(h.Created) {
+			g.timed++
+			g.duration += h.CompletedAt.Sub(h.Created)
+		}
+		if h.Tokens != nil {
+			g.Tokens += h.Tokens.Total()
+			if p, ok := priceFor(prices, h.Backend); ok {
+				g.Cost += h.Tokens.cost(p)
+				g.PricedRuns++
+			}
+		}
+	}
+
+	out := make([]RunGroup, 0, len(groups))
+	for _, g := range groups {
+		if n := g.Succeeded + g.Failed; n > 0 {
+			g.SuccessRate = float64(g.Succeeded) / float64(n)
+		}
+		if g.attempted > 0 {
+			g.MeanAttempts = float64(g.attempts) / float64(g.attempted)
+		}
+		if g.timed > 0 {
+			g.MeanDuration = (g.duration / time.Duration(g.timed)).Seconds()
+		}
+		out = append(out, g.RunGroup)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Runs != out[j].Runs {
+			return out[i].Runs > out[j].Runs
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
+
+func statsKey(groupBy string) (func(*AgentHistory) string, error) {
+	switch groupBy {
+	case GroupByRepo:
+		return func(h *AgentHistory) string {
+			if h.Repo == "" {
+				return "(none)"
+			}
+			return repoKey(h.Repo)
+		}, nil
+	case GroupByTemplate:
+		return func(h *AgentHistory) string {
+			if h.Template == "" {
+				return adhocTemplate
+			}
+			return h.Template
+		}, nil
+	case GroupByBackend:
+		return func(h *AgentHistory) string {
+			if h.Backend == "" {
+				return defaultBackend
+			}
+			return h.Backend
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown grouping %q (want repo, template or backend)", groupBy)
+}
+
+// runOutcome sorts history results into succeeded, failed or neither.
+func runOutcome(result string) string {
+	switch result {
+	case "success", "completed":
+		return "succeeded"
+	case "failed", "policy_violation", "setup_failed", "stale":
+		return "failed"
+	}
+	return ""
+}
+
+// priceFor is the price of a backend, or the default price.
+func priceFor(prices map[string]config.Price, backend string) (config.Price, bool) {
+	if backend == "" {
+		backend = defaultBackend
+	}
+	if p, ok := prices[backend]; ok {
+		return p, true
+	}
+	p, ok := prices[defaultBackend]
+	return p, ok
+}
+
+// withRunDetails adds what stats groups and costs a run by to its history
+// record: the agent's task, template and backend, and the tokens its
+// sessions used.
+func withRunDetails(h *AgentHistory) *AgentHistory {
+	if agent, err := loadAgent(h.Name); err == nil {
+		h.Task, h.Template, h.Backend = agent.Task, agent.Template, agent.Model
+	}
+	if u := sessionUsage(h.Name); u.Total() > 0 {
+		h.Tokens = &u
+	}
+	return h
+}
+
+// agentBackend is the model an agent spawned with opts runs, as its image's
+// run-task is told through AGENT_LLM_MODEL; "" is the image's default.
+func agentBackend(opts SpawnOptions) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return os.Getenv("AGENT_LLM_MODEL")
+}
@@ -0,0 +1,90 @@
+package container
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestStats(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	run := func(repo, template, backend, result string, attempts int, took time.Duration, tokens *TokenUsage) *AgentHistory {
+		return &AgentHistory{Repo: repo, Template: template, Backend: backend, Result: result, Attempts: attempts,
+			Created: start, CompletedAt: start.Add(took), Tokens: tokens}
+	}
+	api, web := "https://github.com/acme/api", "https://github.com/acme/web"
+	records := []*AgentHistory{
+		run(api, "deps", "", "success", 2, 10*time.Minute, &TokenUsage{Input: 1_000_000, Output: 100_000}),
+		run(api, "deps", "", "failed", 10, 30*time.Minute, &TokenUsage{Input: 500_000, CacheRead: 1_000_000}),
+		run(api+".git", "", "local-agent", "killed", 0, 0, &TokenUsage{Input: 10}),
+		run(web, "triage", "local-agent", "completed", 1, 5*time.Minute, nil),
+	}
+	read := 0.3
+	prices := map[string]config.Price{
+		"default":     {Input: 3, Output: 15, CacheRead: &read},
+		"local-agent": {},
+	}
+
+	groups, err := Stats(records, StatsFilter{}, prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Key != "acme/api" || groups[0].Runs != 3 {
+		t.Fatalf("by repo = %+v", groups)
+	}
+	g := groups[0]
+	if g.Succeeded != 1 || g.Failed != 1 || g.SuccessRate != 0.5 || g.MeanAttempts != 6 || g.MeanDuration != 1200 {
+		t.Errorf("api = %+v", g)
+	}
+	// 1M in + 100k out at default, 500k in + 1M cache read at default, 10 free.
+	if want := 3 + 1.5 + 1.5 + 0.3; math.Abs(g.Cost-want) > 1e-9 || g.PricedRuns != 3 || g.Tokens != 2_600_010 {
+		t.Errorf("api cost = %v over %d runs, %d tokens; want %v", g.Cost, g.PricedRuns, g.Tokens, want)
+	}
+
+	groups, _ = Stats(records, StatsFilter{GroupBy: GroupByTemplate, Repo: api}, prices)
+	if len(groups) != 2 || groups[0].Key != "deps" || groups[1].Key != adhocTemplate {
+		t.Errorf("by template = %+v", groups)
+	}
+	groups, _ = Stats(records, StatsFilter{GroupBy: GroupByBackend, Since: start.Add(-time.Hour)}, nil)
+	if len(groups) != 2 || groups[0].Key != defaultBackend || groups[1].Key != "local-agent" || groups[1].SuccessRate != 1 {
+		t.Errorf("by backend = %+v", groups)
+	}
+	if groups, _ := Stats(records, StatsFilter{Since: start.Add(time.Hour)}, nil); len(groups) != 0 {
+		t.Errorf("since = %+v", groups)
+	}
+	if _, err := Stats(records, StatsFilter{GroupBy: "model"}, nil); err == nil {
+		t.Error("Stats should reject an unknown grouping")
+	}
+}
+
+func TestCleanupKeepsRunHistory(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	fakePodman(t, "exit 0")
+
+	spawned := time.Now().Add(-2 * time.Hour)
+	saveAgent(&Agent{Name: "w", Repo: "https://github.com/acme/api", Created: spawned, Task: "Fix it", Template: "triage", Model: "fast"})
+	finished := time.Now().Add(-time.Hour).Truncate(time.Second)
+	SaveHistory(&AgentHistory{Name: "w", Repo: "https://github.com/acme/api", Created: spawned.Add(time.Minute),
+		CompletedAt: finished, Result: "success", Attempts: 3, Metadata: map[string]string{"summary": "done"},
+		Tokens: &TokenUsage{Input: 7}})
+
+	if err := Cleanup("w", "success", 0, map[string]string{"pr": "https://github.com/acme/api/pull/9"}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := LoadHistory("w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Attempts != 3 || !h.CompletedAt.Equal(finished) || h.Metadata["summary"] != "done" || h.Metadata["pr"] == "" {
+		t.Errorf("run details lost: %+v", h)
+	}
+	if h.Template != "triage" || h.Backend != "fast" || h.Task != "Fix it" || h.Tokens == nil || h.Tokens.Input != 7 {
+		t.Errorf("stats fields = %+v", h)
+	}
+}
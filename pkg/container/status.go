@@ -0,0 +1,228 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// statusWorkers bounds the concurrent `podman exec` probes for Claude.
+const statusWorkers = 8
+
+// DefaultStatusCacheTTL is how long ListWithStateCached reuses container
+// states (runtime.status_cache in config; "0" disables the cache).
+const DefaultStatusCacheTTL = 3 * time.Second
+
+// containerState is what podman tells us about one agent's container.
+type containerState struct {
+	Status string `json:"status"`           // running, exited, ... or "" when missing
+	Claude bool   `json:"claude,omitempty"` // an agent process (harness or wrapper) is running inside
+	// Harness is set when claude or opencode itself runs, not just its
+	// wrapper.
+	Harness bool `json:"harness,omitempty"`
+	// Activity is the newest write to a session transcript or log.
+	Activity time.Time `json:"activity,omitempty"`
+}
+
+// containerStatuses returns the state of every container, keyed by
+// container name, from a single `podman ps` call.
+func containerStatuses() (map[string]string, error) {
+	out, err := podmanRetry("ps", "-a", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Names []string `json:"Names"`
+		State string   `json:"State"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse podman ps: %w", err)
+	}
+	statuses := map[string]string{}
+	for _, e := range entries {
+		for _, n := range e.Names {
+			statuses[n] = strings.ToLower(e.State)
+		}
+	}
+	return statuses, nil
+}
+
+// claudeRunning reports whether a Claude process runs in the agent's
+// container, asking its liveness shim when it has one.
+func claudeRunning(name string) bool {
+	return probeAgent(name).Claude
+}
+
+// probeAgent finds the agent processes in a running container and its
+// latest activity, from its liveness shim or else with podman exec.
+func probeAgent(name string) containerState {
+	st := containerState{Status: "running"}
+	if shim, err := probeShim(name); err == nil {
+		st.Claude, st.Harness = shim.Running(), shim.Harness
+		st.Activity = shim.LastActivity
+		if shim.LastSession.After(st.Activity) {
+			st.Activity = shim.LastSession
+		}
+		return st
+	}
+	out, _ := podmanRetry("exec", containerName(name), "sh", "-c", activityScript(layoutOf(name)))
+	probed := parseActivity(string(out))
+	probed.Status = "running"
+	return probed
+}
+
+// containerStates looks up every agent's container with one `podman ps` and
+// probes running ones for Claude in parallel, statusWorkers at a time.
+func containerStates(agents []*Agent) (map[string]containerState, error) {
+	statuses, err := containerStatuses()
+	if errors.Is(err, ErrRuntimeUnresponsive) {
+		return nil, err
+	}
+	// Any other failure reads as "container not found", as inspect did.
+
+	states := make(map[string]containerState, len(agents))
+	var running []string
+	for _, a := range agents {
+		st := containerState{Status: statuses[containerName(a.Name)]}
+		states[a.Name] = st
+		if st.Status == "running" {
+			running = append(running, a.Name)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statusWorkers)
+	for _, name := range running {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			st := probeAgent(name)
+			mu.Lock()
+			states[name] = st
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return states, nil
+}
+
+// withState derives an agent's lifecycle state from its container state.
+func withState(agent *Agent, st containerState) *AgentWithState {
+	aws := &AgentWithState{
+		Agent: agent,
+		Age:   time.Since(agent.Created),
+	}
+	switch st.Status {
+	case "running":
+		aws.ContainerUp = true
+		if st.Claude {
+			aws.Lifecycle = StateActive
+		} else {
+			aws.Lifecycle = StateCompleted
+		}
+		// A wrapper left behind by a harness that finished mid-attempt
+		// doesn't keep the agent active.
+		if after, action := idlePolicy(); st.idle(after, time.Now()) {
+			aws.Idle, aws.IdleFor = true, time.Since(st.Activity)
+			if action == IdleComplete {
+				aws.Lifecycle = StateCompleted
+			}
+		}
+	case "paused":
+		aws.ContainerUp = true
+		aws.Lifecycle = StatePaused
+	case "exited":
+		aws.Lifecycle = StateExited
+	default:
+		aws.Lifecycle = StateStopped
+	}
+	agent.Status = st.Status
+	if agent.Status == "" {
+		agent.Status = "stopped"
+	}
+	return aws
+}
+
+// Agents returns every agent's saved metadata without asking the runtime.
+func Agents() []*Agent {
+	return loadAgents()
+}
+
+// statusCache is the on-disk snapshot behind ListWithStateCached, shared by
+// back-to-back invocations (e.g. `watch agentctl list`).
+type statusCache struct {
+	Time   time.Time                 `json:"time"`
+	States map[string]containerState `json:"states"`
+}
+
+func statusCachePath() string {
+	return filepath.Join(namespace.Root(), "status-cache.json")
+}
+
+func statusCacheTTL() time.Duration {
+	cfg, err := config.Load()
+	if err != nil || cfg.Runtime.StatusCache == "" {
+		return DefaultStatusCacheTTL
+	}
+	if cfg.Runtime.StatusCache == "0" {
+		return 0
+	}
+	if d, err := time.ParseDuration(cfg.Runtime.StatusCache); err == nil && d >= 0 {
+		return d
+	}
+	return DefaultStatusCacheTTL
+}
+
+// cachedStates returns cached states if they are fresh and cover every agent.
+func cachedStates(agents []*Agent, ttl time.Duration) (map[string]containerState, bool) {
+	data, err := os.ReadFile(statusCachePath())
+	if err != nil {
+		return nil, false
+	}
+	var c statusCache
+	if json.Unmarshal(data, &c) != nil || time.Since(c.Time) > ttl || c.Time.After(time.Now()) {
+		return nil, false
+	}
+	for _, a := range agents {
+		if _, ok := c.States[a.Name]; !ok {
+			return nil, false
+		}
+	}
+	return c.States, true
+}
+
+// ListWithStateCached is ListWithState for display: container states up to
+// runtime.status_cache old (default 3s) are reused instead of asking podman.
+func ListWithStateCached() ([]*AgentWithState, error) {
+	ttl := statusCacheTTL()
+	if ttl <= 0 {
+		return ListWithState()
+	}
+	agents := loadAgents()
+	states, ok := cachedStates(agents, ttl)
+	if !ok {
+		var err error
+		if states, err = containerStates(agents); err != nil {
+			return nil, err
+		}
+		data, _ := json.Marshal(statusCache{Time: time.Now(), States: states})
+		os.MkdirAll(filepath.Dir(statusCachePath()), 0755)
+		os.WriteFile(statusCachePath(), data, 0644)
+	}
+	out := make([]*AgentWithState, 0, len(agents))
+	for _, a := range agents {
+		out = append(out, withState(a, states[a.Name]))
+	}
+	return out, nil
+}
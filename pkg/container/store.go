@@ -0,0 +1,455 @@
+package container
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+
+	_ "modernc.org/sqlite"
+)
+
+// Agent metadata and history live in one SQLite database per namespace.
+// Records are stored as the JSON they were always written as, with the
+// columns list, history and stats filter on pulled out beside them.
+// History is sealed like any other record when encryption is on, and then
+// keeps no repo column.
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS agents (
+	name     TEXT PRIMARY KEY,
+	repo_key TEXT NOT NULL DEFAULT '',
+	created  INTEGER NOT NULL DEFAULT 0,
+	data     BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS agents_repo ON agents (repo_key);
+CREATE TABLE IF NOT EXISTS history (
+	name     TEXT PRIMARY KEY,
+	repo_key TEXT NOT NULL DEFAULT '',
+	created  INTEGER NOT NULL DEFAULT 0,
+	data     BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS history_repo ON history (repo_key);
+CREATE INDEX IF NOT EXISTS history_created ON history (created);
+`
+
+// storeBusyTimeout is how long a write waits on another process's.
+const storeBusyTimeout = 10 * time.Second
+
+// storePath is the namespace's database.
+func storePath() string {
+	return filepath.Join(namespace.Root(), "agentctl.db")
+}
+
+var (
+	storeMu sync.Mutex
+	stores  = map[string]*sql.DB{}
+)
+
+// openStore returns the namespace's database, creating it and importing
+// the JSON files of earlier versions on first use in a process.
+func openStore() (*sql.DB, error) {
+	path := storePath()
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if db := stores[path]; db != nil {
+		if _, err := os.Stat(path); err == nil {
+			return db, nil
+		}
+		// Deleted under us (a reset home directory): start afresh.
+		db.Close()
+		delete(stores, path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// History may hold sealed records and plaintext notes; owner only.
+	if f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600); err == nil {
+		f.Close()
+	}
+	db, err := sql.Open("sqlite", fmt.Sprintf("%s?_txlock=immediate&_pragma=busy_timeout(%d)&_pragma=journal_mode(wal)",
+		path, storeBusyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	if err := importJSON(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("importing JSON metadata into %s: %w", path, err)
+	}
+	stores[path] = db
+	return db, nil
+}
+
+// inTx runs fn in a write transaction, committing it when fn succeeds.
+func inTx(fn func(tx *sql.Tx) error) error {
+	db, err := openStore()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is what writes need of a *sql.DB or *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func putAgent(db execer, agent *Agent) error {
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO agents (name, repo_key, created, data) VALUES (?, ?, ?, ?)`,
+		agent.Name, storeRepoKey(agent.Repo), agent.Created.Unix(), data)
+	return err
+}
+
+func putHistory(db execer, h *AgentHistory) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	sealed, err := seal(data)
+	if err != nil {
+		return err
+	}
+	key := storeRepoKey(h.Repo)
+	if encryptionEnabled() {
+		key = ""
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO history (name, repo_key, created, data) VALUES (?, ?, ?, ?)`,
+		h.Name, key, h.Created.Unix(), sealed)
+	return err
+}
+
+func storeRepoKey(repo string) string {
+	if repo == "" {
+		return ""
+	}
+	return repoKey(repo)
+}
+
+func saveAgent(agent *Agent) error {
+	db, err := openStore()
+	if err != nil {
+		return err
+	}
+	return putAgent(db, agent)
+}
+
+// LoadAgent reads the saved metadata for the named agent.
+func LoadAgent(name string) (*Agent, error) {
+	db, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = db.QueryRow(`SELECT data FROM agents WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var agent Agent
+	json.Unmarshal(data, &agent)
+	return &agent, nil
+}
+
+func loadAgent(name string) (*Agent, error) {
+	return LoadAgent(name)
+}
+
+// updateAgent applies change to the named agent's metadata in one
+// transaction, so updates from commands racing on the agent all land.
+// Returning an error from change leaves the agent as it was.
+func updateAgent(name string, change func(*Agent) error) error {
+	return inTx(func(tx *sql.Tx) error {
+		var data []byte
+		err := tx.QueryRow(`SELECT data FROM agents WHERE name = ?`, name).Scan(&data)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+		}
+		if err != nil {
+			return err
+		}
+		var agent Agent
+		json.Unmarshal(data, &agent)
+		if err := change(&agent); err != nil {
+			return err
+		}
+		return putAgent(tx, &agent)
+	})
+}
+
+// deleteAgent removes the named agent's metadata.
+func deleteAgent(name string) error {
+	db, err := openStore()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`DELETE FROM agents WHERE name = ?`, name)
+	return err
+}
+
+// loadAgents reads every agent's metadata in the current namespace.
+func loadAgents() []*Agent {
+	return queryAgents(`SELECT data FROM agents ORDER BY name`)
+}
+
+// agentsOnRepo reads the metadata of the agents working on repo.
+func agentsOnRepo(repo string) []*Agent {
+	return queryAgents(`SELECT data FROM agents WHERE repo_key = ? ORDER BY name`, storeRepoKey(repo))
+}
+
+func queryAgents(query string, args ...any) []*Agent {
+	db, err := openStore()
+	if err != nil {
+		return nil
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var agents []*Agent
+	for rows.Next() {
+		var data []byte
+		var agent Agent
+		if rows.Scan(&data) != nil || json.Unmarshal(data, &agent) != nil {
+			continue
+		}
+		agents = append(agents, &agent)
+	}
+	return agents
+}
+
+// SaveHistory persists an agent history record, encrypted when
+// config.Encryption is on.
+func SaveHistory(h *AgentHistory) error {
+	db, err := openStore()
+	if err != nil {
+		return err
+	}
+	return putHistory(db, h)
+}
+
+// LoadHistory loads a single agent history record.
+func LoadHistory(name string) (*AgentHistory, error) {
+	db, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = db.QueryRow(`SELECT data FROM history WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("history not found: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseHistory(name, data)
+}
+
+func parseHistory(name string, data []byte) (*AgentHistory, error) {
+	plain, err := unseal(data)
+	if err != nil {
+		return nil, fmt.Errorf("history of %s: %w", name, err)
+	}
+	var h AgentHistory
+	if err := json.Unmarshal(plain, &h); err != nil {
+		return nil, fmt.Errorf("failed to parse history: %w", err)
+	}
+	return &h, nil
+}
+
+// updateHistory applies change to the named agent's history record in one
+// transaction.
+func updateHistory(name string, change func(*AgentHistory) error) error {
+	return inTx(func(tx *sql.Tx) error {
+		var data []byte
+		err := tx.QueryRow(`SELECT data FROM history WHERE name = ?`, name).Scan(&data)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("history not found: %s", name)
+		}
+		if err != nil {
+			return err
+		}
+		h, err := parseHistory(name, data)
+		if err != nil {
+			return err
+		}
+		if err := change(h); err != nil {
+			return err
+		}
+		return putHistory(tx, h)
+	})
+}
+
+// deleteHistory removes the named agent's history record.
+func deleteHistory(name string) error {
+	db, err := openStore()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`DELETE FROM history WHERE name = ?`, name)
+	return err
+}
+
+// archiveAgent saves h and removes the agent's live metadata in one
+// transaction, so a cleaned-up agent is never both or neither.
+func archiveAgent(h *AgentHistory) error {
+	return inTx(func(tx *sql.Tx) error {
+		if err := putHistory(tx, h); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM agents WHERE name = ?`, h.Name)
+		return err
+	})
+}
+
+// HistoryQuery narrows FindHistory; the zero value matches every record.
+type HistoryQuery struct {
+	Repo string
+	// Since keeps the records of agents created at or after it.
+	Since time.Time
+}
+
+// ListHistory returns all agent history records.
+func ListHistory() ([]*AgentHistory, error) {
+	return FindHistory(HistoryQuery{})
+}
+
+// FindHistory returns the history records matching q, by name.
+func FindHistory(q HistoryQuery) ([]*AgentHistory, error) {
+	db, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	query, args := `SELECT name, data FROM history WHERE 1 = 1`, []any{}
+	if q.Repo != "" {
+		// Sealed records keep no repo, so they are checked once opened.
+		query += ` AND repo_key IN (?, '')`
+		args = append(args, storeRepoKey(q.Repo))
+	}
+	if !q.Since.IsZero() {
+		query += ` AND created >= ?`
+		args = append(args, q.Since.Unix())
+	}
+	rows, err := db.Query(query+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []*AgentHistory
+	for rows.Next() {
+		var name string
+		var data []byte
+		if err := rows.Scan(&name, &data); err != nil {
+			return nil, err
+		}
+		h, err := parseHistory(name, data)
+		if err != nil {
+			continue
+		}
+		if q.Repo != "" && storeRepoKey(h.Repo) != storeRepoKey(q.Repo) {
+			continue
+		}
+		records = append(records, h)
+	}
+	return records, rows.Err()
+}
+
+// importJSON moves the per-file JSON metadata of earlier versions, under
+// agents/ and history/, into the database. Each file is renamed with an
+// .imported suffix once its record is committed, so it is read only once
+// and is still there to recover by hand. Files that don't parse, as a
+// write torn by racing commands could leave, are left where they are.
+func importJSON(db *sql.DB) error {
+	var imported []string
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, path := range jsonFiles(agentDir()) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var agent Agent
+		if err := json.Unmarshal(data, &agent); err != nil || agent.Name == "" {
+			fmt.Printf("⚠️  Not importing %s: it doesn't parse as agent metadata\n", path)
+			continue
+		}
+		if err := putAgent(tx, &agent); err != nil {
+			return err
+		}
+		imported = append(imported, path)
+	}
+	for _, path := range jsonFiles(historyDir()) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		h, err := parseHistory(filepath.Base(path), data)
+		if err != nil || h.Name == "" {
+			fmt.Printf("⚠️  Not importing %s: %v\n", path, err)
+			continue
+		}
+		// Keep the record as it was sealed, or not.
+		key := storeRepoKey(h.Repo)
+		if bytes.HasPrefix(data, []byte(sealedPrefix)) {
+			key = ""
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO history (name, repo_key, created, data) VALUES (?, ?, ?, ?)`,
+			h.Name, key, h.Created.Unix(), data); err != nil {
+			return err
+		}
+		imported = append(imported, path)
+	}
+	if len(imported) == 0 {
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, path := range imported {
+		os.Rename(path, path+".imported")
+	}
+	return nil
+}
+
+// jsonFiles lists dir's .json files.
+func jsonFiles(dir string) []string {
+	entries, _ := os.ReadDir(dir)
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths
+}
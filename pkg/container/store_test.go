@@ -0,0 +1,116 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// storedHistory returns a history record as the store holds it.
+func storedHistory(t *testing.T, name string) []byte {
+	t.Helper()
+	db, err := openStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	if err := db.QueryRow(`SELECT data FROM history WHERE name = ?`, name).Scan(&data); err != nil {
+		t.Fatalf("history of %s: %v", name, err)
+	}
+	return data
+}
+
+func TestImportJSON(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// What an earlier version left behind, with one write torn by a race.
+	os.MkdirAll(agentDir(), 0755)
+	os.MkdirAll(historyDir(), 0700)
+	os.WriteFile(filepath.Join(agentDir(), "api.json"), []byte(`{"name": "api", "repo": "https://github.com/org/api", "task": "fix login"}`), 0644)
+	os.WriteFile(filepath.Join(agentDir(), "torn.json"), []byte(`{"name": "torn", "re`), 0644)
+	os.WriteFile(filepath.Join(historyDir(), "old.json"), []byte(`{"name": "old", "repo": "https://github.com/org/api", "result": "success"}`), 0600)
+	os.WriteFile(filepath.Join(historyDir(), "old.patch"), []byte("diff"), 0644)
+
+	if a, err := LoadAgent("api"); err != nil || a.Task != "fix login" {
+		t.Fatalf("LoadAgent() = %+v, %v", a, err)
+	}
+	if h, err := LoadHistory("old"); err != nil || h.Result != "success" {
+		t.Fatalf("LoadHistory() = %+v, %v", h, err)
+	}
+	for _, path := range []string{
+		filepath.Join(agentDir(), "api.json.imported"),
+		filepath.Join(agentDir(), "torn.json"),
+		filepath.Join(historyDir(), "old.json.imported"),
+		filepath.Join(historyDir(), "old.patch"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	if agents := loadAgents(); len(agents) != 1 {
+		t.Errorf("loadAgents() = %d agents, want only the one that parsed", len(agents))
+	}
+}
+
+func TestUpdateAgentKeepsEveryUpdate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	saveAgent(&Agent{Name: "worker"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := AddNote("worker", fmt.Sprintf("note %d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if notes, _ := Notes("worker"); len(notes) != 20 {
+		t.Errorf("%d notes kept, want 20", len(notes))
+	}
+	if err := updateAgent("gone", func(*Agent) error { return nil }); err == nil {
+		t.Error("updateAgent() of a missing agent succeeded")
+	}
+}
+
+func TestFindHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useEncryption(t, false, bytes.Repeat([]byte{7}, 32))
+
+	now := time.Now()
+	SaveHistory(&AgentHistory{Name: "a", Repo: "https://github.com/org/api", Created: now.Add(-48 * time.Hour)})
+	SaveHistory(&AgentHistory{Name: "b", Repo: "https://github.com/org/api.git", Created: now})
+	SaveHistory(&AgentHistory{Name: "c", Repo: "https://github.com/org/web", Created: now})
+	// Sealed records keep no repo column, but still match.
+	useEncryption(t, true, bytes.Repeat([]byte{7}, 32))
+	SaveHistory(&AgentHistory{Name: "d", Repo: "https://github.com/org/api", Created: now})
+
+	names := func(records []*AgentHistory) string {
+		var s string
+		for _, h := range records {
+			s += h.Name
+		}
+		return s
+	}
+	if got, err := FindHistory(HistoryQuery{Repo: "https://github.com/org/api"}); err != nil || names(got) != "abd" {
+		t.Errorf("FindHistory(repo) = %s, %v; want abd", names(got), err)
+	}
+	if got, _ := FindHistory(HistoryQuery{Repo: "https://github.com/org/api", Since: now.Add(-time.Hour)}); names(got) != "bd" {
+		t.Errorf("FindHistory(repo, since) = %s, want bd", names(got))
+	}
+	if got, _ := ListHistory(); names(got) != "abcd" {
+		t.Errorf("ListHistory() = %s, want abcd", names(got))
+	}
+
+	saveAgent(&Agent{Name: "x", Repo: "https://github.com/org/api"})
+	saveAgent(&Agent{Name: "y", Repo: "https://github.com/org/web"})
+	if got := agentsOnRepo("https://github.com/org/api.git"); len(got) != 1 || got[0].Name != "x" {
+		t.Errorf("agentsOnRepo() = %+v", got)
+	}
+}
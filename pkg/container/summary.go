@@ -0,0 +1,172 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+)
+
+// DefaultSummaryCommand asks Claude, non-interactively, for the summary.
+const DefaultSummaryCommand = "claude -p"
+
+// Limits on what the summary prompt quotes, so a large change doesn't blow
+// the model's context.
+const (
+	summaryDiffBytes   = 40000
+	summarySessionTail = 80
+)
+
+// summaryMarkers delimit the summary in a PR body, so updating it replaces
+// the previous one and leaves the rest of the body alone.
+const (
+	summaryStart = "<!-- agentctl:summary -->"
+	summaryEnd   = "<!-- /agentctl:summary -->"
+)
+
+var summaryBlock = regexp.MustCompile(`(?s)` + regexp.QuoteMeta(summaryStart) + `.*?` + regexp.QuoteMeta(summaryEnd))
+
+// summaryInput is what the model is shown.
+type summaryInput struct {
+	Task, Commits, Stat, Diff, Session string
+}
+
+// summaryPrompt asks for a reviewer-oriented summary of the change.
+func summaryPrompt(in summaryInput) string {
+	var b strings.Builder
+	b.WriteString("Summarize this change for a code reviewer in under 200 words of Markdown, with these sections:\n" +
+		"**What changed** (files and behaviour), **Commits**, **Tests** (what was added or fixed, and whether they pass), " +
+		"**Open questions** (anything unfinished, uncertain or worth a second look; \"None\" if nothing).\n" +
+		"Reply with the summary only.\n")
+	if task := strings.TrimSpace(in.Task); task != "" {
+		b.WriteString("\n## Task\n" + task + "\n")
+	}
+	b.WriteString("\n## Commits\n" + strings.TrimSpace(in.Commits) + "\n")
+	b.WriteString("\n## Files\n" + strings.TrimSpace(in.Stat) + "\n")
+	diff := in.Diff
+	if len(diff) > summaryDiffBytes {
+		diff = diff[:summaryDiffBytes] + "\n[diff truncated]"
+	}
+	b.WriteString("\n## Diff\n```diff\n" + strings.TrimSpace(diff) + "\n```\n")
+	if session := strings.TrimSpace(in.Session); session != "" {
+		b.WriteString("\n## End of the agent's session\n```\n" + session + "\n```\n")
+	}
+	return b.String()
+}
+
+func summaryCommand() string {
+	if cfg, err := config.Load(); err == nil && cfg.Summary.Command != "" {
+		return cfg.Summary.Command
+	}
+	return DefaultSummaryCommand
+}
+
+// Summarize has the model write a short summary of what the agent changed
+// on its branch — files, commits, tests and open questions — from the diff
+// against the base and the tail of its session, and records it on the agent.
+func Summarize(name string) (string, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	git := agentGit(name)
+	base, err := branchBase(git)
+	if err != nil {
+		return "", err
+	}
+	commits, err := git(nil, "log", "--reverse", "--format=- %s", base+"..HEAD")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(commits) == "" {
+		return "", fmt.Errorf("%s has no commits on its branch to summarize", name)
+	}
+	stat, _ := git(nil, "diff", "--stat", base, "HEAD")
+	diff, _ := git(nil, "diff", base, "HEAD")
+
+	layout := layoutOf(name)
+	session, _ := podman("exec", containerName(name), "sh", "-c",
+		"tail -n "+fmt.Sprint(summarySessionTail)+" "+shellQuote(layout.Path("claude.log"))+" 2>/dev/null").Output()
+
+	task := agent.Task
+	if task == "" {
+		task = agent.Intent
+	}
+	prompt := summaryPrompt(summaryInput{Task: task, Commits: commits, Stat: stat, Diff: diff, Session: string(session)})
+
+	cmd := podmanLong("exec", "-i", containerName(name), "sh", "-c", layout.cd()+summaryCommand())
+	cmd.Stdin = strings.NewReader(prompt)
+	out, err := cmd.Output()
+	summary := strings.TrimSpace(string(out))
+	if err != nil {
+		return "", fmt.Errorf("summary command failed: %w", err)
+	}
+	if summary == "" {
+		return "", fmt.Errorf("summary command printed nothing")
+	}
+
+	updateAgent(name, func(agent *Agent) error {
+		agent.Summary = summary
+		return nil
+	})
+	return summary, nil
+}
+
+// withSummary renders a PR body with summary in place of any earlier one,
+// or appended.
+func withSummary(body, summary string) string {
+	block := summaryStart + "\n## Summary\n\n" + summary + "\n" + summaryEnd
+	if summaryBlock.MatchString(body) {
+		return summaryBlock.ReplaceAllLiteralString(body, block)
+	}
+	if body = strings.TrimSpace(body); body == "" {
+		return block + "\n"
+	}
+	return body + "\n\n" + block + "\n"
+}
+
+// UpdatePRSummary writes the agent's summary into the body of the open PR
+// for its branch, returning the PR's URL.
+func UpdatePRSummary(name string) (string, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+	}
+	if agent.Summary == "" {
+		return "", fmt.Errorf("%s has no summary yet (run `agentctl summarize %s`)", name, name)
+	}
+	host := forge.For(agent.Repo)
+	pr, err := host.FindPR(agent.Repo, agent.Branch)
+	if err != nil {
+		return "", err
+	}
+	if err := host.SetPRBody(agent.Repo, pr, withSummary(pr.Body, agent.Summary)); err != nil {
+		return "", err
+	}
+	return pr.URL, nil
+}
+
+// summarizeCompleted writes the summary of a completed run when configured,
+// returning it for the history record ("" when off or it failed).
+func summarizeCompleted(name string) string {
+	cfg, err := config.Load()
+	if err != nil || !cfg.Summary.Enabled {
+		return ""
+	}
+	summary, err := Summarize(name)
+	if err != nil {
+		fmt.Printf("⚠️  Summary failed: %v\n", err)
+		return ""
+	}
+	fmt.Printf("📄 Summary:\n%s\n", summary)
+	if cfg.Summary.UpdatePR {
+		if url, err := UpdatePRSummary(name); err != nil {
+			fmt.Printf("⚠️  PR not updated: %v\n", err)
+		} else {
+			fmt.Printf("📝 Summary added to %s\n", url)
+		}
+	}
+	return summary
+}
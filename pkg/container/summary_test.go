@@ -0,0 +1,82 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	prompt := filepath.Join(t.TempDir(), "prompt")
+	fakePodman(t, `case "$*" in
+*merge-base*) echo abc123 ;;
+*" log "*) echo "- Add token expiry" ;;
+*"diff --stat"*) echo " auth.go | 4 ++--" ;;
+*" diff "*) echo "+expiry := time.Hour" ;;
+*"tail -n"*) echo "All tests pass." ;;
+*"exec -i"*) cat > `+prompt+`; echo "**What changed** token expiry" ;;
+esac`)
+	saveAgent(&Agent{Name: "sum", Repo: "https://github.com/test/sum", Task: "Add token expiry"})
+
+	summary, err := Summarize("sum")
+	if err != nil || summary != "**What changed** token expiry" {
+		t.Fatalf("Summarize = %q, %v", summary, err)
+	}
+	sent, _ := os.ReadFile(prompt)
+	for _, want := range []string{"## Task\nAdd token expiry", "- Add token expiry", "auth.go | 4", "+expiry := time.Hour", "All tests pass."} {
+		if !strings.Contains(string(sent), want) {
+			t.Errorf("prompt is missing %q:\n%s", want, sent)
+		}
+	}
+	if agent, _ := loadAgent("sum"); agent.Summary != summary {
+		t.Errorf("summary not recorded: %q", agent.Summary)
+	}
+
+	// Cleanup keeps it in history.
+	Cleanup("sum", "success", 1, nil)
+	if h, err := LoadHistory("sum"); err != nil || h.Metadata["summary"] != summary {
+		t.Errorf("history = %+v, %v", h, err)
+	}
+}
+
+func TestSummarizeNoCommits(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	fakePodman(t, `case "$*" in *merge-base*) echo abc123 ;; esac`)
+	saveAgent(&Agent{Name: "idle"})
+	if _, err := Summarize("idle"); err == nil || !strings.Contains(err.Error(), "no commits") {
+		t.Errorf("Summarize = %v", err)
+	}
+}
+
+func TestWithSummary(t *testing.T) {
+	body := withSummary("Closes #12", "first")
+	if !strings.HasPrefix(body, "Closes #12\n\n"+summaryStart) || !strings.Contains(body, "first\n"+summaryEnd) {
+		t.Errorf("body = %q", body)
+	}
+	// A new summary replaces the old one and keeps the rest.
+	body = withSummary(body+"\nFooter", "second")
+	if strings.Contains(body, "first") || !strings.Contains(body, "second") ||
+		!strings.HasPrefix(body, "Closes #12") || !strings.HasSuffix(body, "Footer") {
+		t.Errorf("body = %q", body)
+	}
+	if got := withSummary("", "only"); !strings.HasPrefix(got, summaryStart) {
+		t.Errorf("empty body = %q", got)
+	}
+}
+
+func TestSummaryPromptTruncatesDiff(t *testing.T) {
+	p := summaryPrompt(summaryInput{Commits: "- x", Diff: strings.Repeat("+", summaryDiffBytes+100)})
+	if !strings.Contains(p, "[diff truncated]") || len(p) > summaryDiffBytes+2000 {
+		t.Errorf("prompt of %d bytes", len(p))
+	}
+}
@@ -3,10 +3,14 @@ package container
 import (
 	"fmt"
 	"os/exec"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/jordanpartridge/agentctl/pkg/config"
 	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/telemetry"
 )
 
 type TaskResult struct {
@@ -14,43 +18,240 @@ type TaskResult struct {
 	TestsPassed bool
 	LintPassed  bool
 	HasChanges  bool
+	TestStatus  string // of the last attempt
 	Error       string
 	Attempts    int
+	// Patch is where a patch-only run saved its change.
+	Patch string
+	// Instructions are the `tell` messages delivered during this run.
+	Instructions []Instruction
+	// Failure is why the last unfinished attempt failed; see FailureClass.
+	Failure string
 }
 
 type AgentStatus struct {
-	TestStatus     string // "pass", "fail", "unknown"
-	HasUncommitted bool
-	ClaudeRunning  bool
+	TestStatus     string   `json:"test_status"` // "pass", "fail", "flaky", "unknown"
+	HasUncommitted bool     `json:"has_uncommitted"`
+	Unmerged       []string `json:"unmerged,omitempty"` // files a merge or rebase left conflicted
+	ClaudeRunning  bool     `json:"claude_running"`
+	FailingTests   []string `json:"failing_tests,omitempty"` // tests that failed on every run
+	FlakyTests     []string `json:"flaky_tests,omitempty"`   // tests that failed on some runs only
+	TestOutput     string   `json:"test_output,omitempty"`   // tail of the last failing run's output
+	// TestReport is the last test run's counts, time and failures, when
+	// the runner's output could be read.
+	TestReport *TestReport `json:"test_report,omitempty"`
+	// Coverage is the coverage gate's verdict, when it is configured and
+	// there was a finished change to measure.
+	Coverage *CoverageResult `json:"coverage,omitempty"`
+	// DoneWhen is the command that set TestStatus in place of the test
+	// suite, for a run given one.
+	DoneWhen string `json:"done_when,omitempty"`
+}
+
+// TestsOK reports whether the suite is good enough to finish: passing, or
+// failing only intermittently (flakes the agent didn't cause and can't fix).
+func (s AgentStatus) TestsOK() bool {
+	return s.TestStatus == "pass" || s.TestStatus == "flaky"
+}
+
+// Done reports whether the agent may finish: tests OK, everything committed
+// and coverage not held back by the gate.
+func (s AgentStatus) Done() bool {
+	return s.TestsOK() && !s.HasUncommitted && (s.Coverage == nil || !s.Coverage.Low)
+}
+
+// testRuns returns how many times a failing suite is run (tests.runs, default 1).
+func testRuns() int {
+	if cfg, err := config.Load(); err == nil && cfg.Tests.Runs > 1 {
+		return cfg.Tests.Runs
+	}
+	return 1
+}
+
+// testOutputLines returns how many lines of failing test output are kept for
+// the retry prompt (tests.output_lines, default 40, -1 for none).
+func testOutputLines() int {
+	if cfg, err := config.Load(); err == nil && cfg.Tests.OutputLines != 0 {
+		return cfg.Tests.OutputLines
+	}
+	return 40
 }
 
 // RunUntilDone keeps the agent working until the task is complete
 // This implements the "Ralph Wiggum" pattern - persistent retry until success.
 // When a repoURL is available (via agent metadata), it integrates with the
 // coordination bus to update state and check for rebase_needed signals.
+// Loop state is checkpointed after each attempt; see ResumeRun. Only one
+// loop runs an agent at a time: while another holds its run lock this fails
+// with ErrRunInProgress.
 func RunUntilDone(name string, task string, maxAttempts int) (*TaskResult, error) {
-	result := &TaskResult{}
+	return startRun(name, task, &Checkpoint{Task: task, MaxAttempts: maxAttempts})
+}
 
-	if maxAttempts == 0 {
-		maxAttempts = 10 // default
+// RunOptions change how a run decides it is done and what it leaves behind.
+type RunOptions struct {
+	// OutputPatch runs in patch-only mode; see RunForPatch.
+	OutputPatch bool
+	// DoneWhen is a shell command run in the workspace in place of the
+	// test suite: the task is done once it exits 0.
+	DoneWhen string
+	// ContinueSession resumes the previous attempt's Claude session in each
+	// retry instead of starting a new conversation; run_loop.continue_session
+	// turns it on for every run.
+	ContinueSession bool
+}
+
+// RunWithOptions is RunUntilDone with opts applied for this run only.
+func RunWithOptions(name, task string, maxAttempts int, opts RunOptions) (*TaskResult, error) {
+	cp := &Checkpoint{Task: task, MaxAttempts: maxAttempts, DoneWhen: opts.DoneWhen, ContinueSession: opts.ContinueSession}
+	if cfg, err := config.Load(); err == nil && cfg.RunLoop.ContinueSession {
+		cp.ContinueSession = true
+	}
+	if opts.DoneWhen != "" {
+		cp.Task += doneWhenNote(opts.DoneWhen)
+	}
+	if opts.OutputPatch {
+		base, err := agentGit(name)(nil, "rev-parse", "HEAD")
+		if err != nil {
+			return &TaskResult{}, fmt.Errorf("cannot read %s's HEAD: %w", name, err)
+		}
+		cp.Task += patchOnlyNote
+		cp.OutputPatch, cp.PatchBase = true, strings.TrimSpace(base)
 	}
+	return startRun(name, task, cp)
+}
+
+// startRun starts a fresh loop from cp, which carries the prompt and mode;
+// task is what the agent records as its task.
+func startRun(name, task string, cp *Checkpoint) (*TaskResult, error) {
+	if cp.MaxAttempts == 0 {
+		cp.MaxAttempts = 10 // default
+	}
+	release, err := acquireRunLock(name)
+	if err != nil {
+		return &TaskResult{}, err
+	}
+	defer release()
+	updateAgent(name, func(agent *Agent) error {
+		// Remember the task so the agent can be exported and re-run elsewhere,
+		// and what it is for, so it can be recognised later. A new task
+		// needs its scope predicted again.
+		if agent.Task != task {
+			agent.Scope = nil
+		}
+		agent.Task = task
+		agent.TestReports = nil
+		if agent.Intent == "" {
+			agent.Intent = IntentFrom(task)
+		}
+		return nil
+	})
+	clearAttemptTags(name)
+	cp.Agent, cp.LoopStart = name, time.Now()
+	return runLoop(name, cp)
+}
+
+// runLoop runs attempts cp.Attempt+1 through cp.MaxAttempts, traced as one
+// agentctl.run span.
+func runLoop(name string, cp *Checkpoint) (*TaskResult, error) {
+	run := telemetry.Start(nil, "agentctl.run", "agent.name", name,
+		"agent.max_attempts", cp.MaxAttempts, "agent.resumed_after", cp.Attempt)
+	result, err := runAttempts(name, cp, run)
+	run.Set("agent.attempts", result.Attempts, "agent.completed", result.Completed,
+		"agent.instructions", len(result.Instructions))
+	run.End(err)
+	return result, err
+}
+
+func runAttempts(name string, cp *Checkpoint, run *telemetry.Span) (*TaskResult, error) {
+	result := &TaskResult{Attempts: cp.Attempt}
+	task, maxAttempts, loopStart := cp.Task, cp.MaxAttempts, cp.LoopStart
+	ensureShim(name)
 
 	// Look up agent metadata for coordination integration
 	var repoURL string
-	if agent, err := loadAgent(name); err == nil && agent.Repo != "" {
-		repoURL = agent.Repo
+	var after, needs []string
+	var references []Reference
+	if agent, err := loadAgent(name); err == nil {
+		repoURL, after, needs, references = agent.Repo, agent.After, agent.Needs, agent.References
+		run.Set("agent.repo", repoURL)
+	}
+	if repoURL != "" {
 		// Initialize coordination directory
 		if _, err := coordination.Init(repoURL); err != nil {
 			fmt.Printf("⚠️  Coordination init failed (continuing without): %v\n", err)
 			repoURL = "" // disable coordination
 		}
 	}
+	// Beat for as long as the loop runs, so the bus can tell a long attempt
+	// from a crashed run.
+	if repoURL != "" {
+		stop := startHeartbeat(repoURL, name, coordination.HeartbeatInterval())
+		defer stop()
+	}
+
+	// Watch the agent's tool calls when a security policy is configured.
+	var violations <-chan guardStop
+	if cfg, err := config.Load(); err == nil && cfg.Policy.Enabled {
+		policy, err := NewPolicy(cfg.Policy)
+		if err != nil {
+			return result, err
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		violations = startGuard(name, policy, stop)
+		fmt.Printf("🛡️  Policy enforced (%s on violation)\n", policy.Action)
+	}
+
+	// Hold the agent to the claims of others on the bus.
+	claimMode := ClaimsOff
+	if repoURL != "" {
+		mode, err := claimEnforcement()
+		if err != nil {
+			return result, err
+		}
+		claimMode = mode
+	}
+
+	// Unfinished attempts in a row back off exponentially.
+	pacing := loadPacing()
+	misses := 0
+	// What to do about each kind of failed attempt.
+	strategies := retryStrategies()
+
+	// Start only once the agents this one needs have completed.
+	if cp.Attempt == 0 && len(needs) > 0 {
+		if err := awaitNeeds(needs); err != nil {
+			return result, err
+		}
+	}
+
+	if cp.Attempt > 0 {
+		fmt.Printf("⏯️  Resuming after attempt %d/%d (started %s)\n", cp.Attempt, maxAttempts, loopStart.Format(time.RFC3339))
+	}
 
-	loopStart := time.Now()
+	// Expired credentials would only show as attempts that do nothing.
+	if err := checkAuth(name, true); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	if cp.ContinueSession && !runTaskResumes(name) {
+		fmt.Printf("⚠️  %s's run-task ignores %s, so each attempt starts a new session\n", name, ResumeSessionEnv)
+	}
 
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
+	for attempt := cp.Attempt + 1; attempt <= maxAttempts; attempt++ {
+		waitWhilePaused(name)
 		result.Attempts = attempt
 		fmt.Printf("\n🔄 Attempt %d/%d\n", attempt, maxAttempts)
+		setShimAttempt(name, attempt)
+		span := telemetry.Start(run, "agentctl.attempt", "agent.name", name, "agent.attempt", attempt)
+		untrace := traceAgent(name, span)
+		endAttempt := func(outcome string, err error) {
+			untrace()
+			span.Set("agent.result", outcome)
+			span.End(err)
+			run.Flush()
+		}
 
 		// Update coordination state
 		if repoURL != "" {
@@ -59,47 +260,197 @@ func RunUntilDone(name string, task string, maxAttempts int) (*TaskResult, error
 
 		// Check for rebase_needed signals from other agents
 		if repoURL != "" {
+			relayPushes(repoURL)
 			if needsRebase, _ := coordination.HasRebaseNeeded(repoURL, name, loopStart); needsRebase {
 				fmt.Printf("⚠️  Rebase needed signal detected, adding to prompt\n")
 				task = task + "\n\nIMPORTANT: Another agent has pushed changes. Run 'git pull --rebase' before continuing."
 			}
 		}
 
+		// Have the agent rebase onto dependencies merged since the last attempt.
+		if repoURL != "" && len(after) > 0 {
+			if settled, err := settledDependencies(repoURL, after, cp.Settled); err == nil {
+				task += takeSettled(cp, settled)
+			}
+		}
+
+		// Deliver instructions queued with `agentctl tell` since the last
+		// attempt; checkpoint straight away, as they are gone from the queue.
+		if told := takeInstructions(name, attempt); len(told) > 0 {
+			fmt.Printf("📨 %d instruction(s) from the operator\n", len(told))
+			task += instructionNote(told)
+			result.Instructions = append(result.Instructions, told...)
+			cp.Task = task
+			saveCheckpoint(cp)
+		}
+
 		// Build the prompt - include context from previous attempts
 		prompt := task
 		if attempt > 1 {
-			status := getStatus(name)
-			prompt = fmt.Sprintf(`Continue working. Previous status:
-- Tests: %s
-- Uncommitted changes: %v
+			last := statusOf(name, cp.DoneWhen)
+			prompt = retryPrompt(task, last, cp.OutputPatch)
+			if class := FailureClass(cp.Failure); strategies[class] == RetryPrompt || strategies[class] == RetrySetup {
+				prompt = failurePrompt(class, last, cp.SetupRerun) + prompt
+			}
+		}
+		resume := ""
+		if cp.ContinueSession && attempt > 1 {
+			if resume = cp.Session; resume != "" {
+				fmt.Printf("🧵 Continuing session %s\n", resume)
+			} else {
+				fmt.Printf("⚠️  No session from the last attempt to continue; starting a new one\n")
+			}
+		}
 
-Original task: %s
+		// Point the agent at the references it can read.
+		if note := referenceNote(references); note != "" {
+			prompt = note + "\n" + prompt
+		}
 
-Keep going until tests pass and all changes are committed.`,
-				status.TestStatus, status.HasUncommitted, task)
+		// Tell the agent about the bus: who holds what, and what just happened.
+		if repoURL != "" {
+			if briefing, err := coordination.PromptContext(repoURL, name, coordination.DefaultPromptMessages); err == nil {
+				prompt = briefing + "\n" + prompt
+			}
+		}
+
+		// Watch the transcript so the attempt's file touches can be reported.
+		var touches *sessionScanner
+		if repoURL != "" {
+			touches = newSessionScanner(name)
+		}
+
+		var conflicts <-chan []ClaimConflict
+		stopClaims := make(chan struct{})
+		if claimMode != ClaimsOff {
+			conflicts = watchClaims(repoURL, name, claimMode, stopClaims)
 		}
 
 		// Run agent via the image's run-task entrypoint
+		waitForRateLimit(pacing)
 		fmt.Printf("🤖 Running agent...\n")
-		err := runTask(name, prompt)
+		output, stopped, conflict, err := awaitTask(name, prompt, resume, violations, conflicts)
+		close(stopClaims)
+		if cp.ContinueSession {
+			cp.Session = latestSession(name)
+		}
+		if touches != nil {
+			publishFilesTouched(repoURL, name, attempt, touches)
+		}
+		if conflict != nil {
+			fmt.Printf("✋ Interrupted: edits to claimed files\n")
+			task = task + claimConflictNote(conflict)
+		}
+		if stopped != nil && stopped.err != nil {
+			// Fail closed: the run doesn't go on without the guard. The
+			// checkpoint stays, so it can be resumed.
+			err := fmt.Errorf("%v: %w", stopped.err, ErrUnrecoverable)
+			endAttempt("guard_failed", err)
+			failRun(repoURL, name, loopStart, attempt, result, err)
+			return result, fmt.Errorf("%w; resume with `agentctl run --resume %s` once the runtime answers", err, name)
+		}
+		if stopped != nil {
+			violation := stopped.violation
+			removeCheckpoint(name)
+			result.Error = "policy violation: " + violation.String()
+			err := fmt.Errorf("policy violation: %s", violation)
+			endAttempt("policy_violation", err)
+			return result, err
+		}
 		if err != nil {
 			fmt.Printf("⚠️  Agent error: %v\n", err)
 		}
 
 		// Wait a moment for things to settle
-		time.Sleep(2 * time.Second)
+		time.Sleep(pacing.Settle)
+		waitWhilePaused(name)
 
 		// Check if done
-		status := getStatus(name)
-		fmt.Printf("📊 Status: tests=%s uncommitted=%v\n", status.TestStatus, status.HasUncommitted)
+		check := telemetry.Start(span, "agentctl.check", "agent.name", name, "agent.attempt", attempt)
+		untraceCheck := traceAgent(name, check)
+		status := statusOf(name, cp.DoneWhen)
+		untraceCheck()
+		check.Set("tests.status", status.TestStatus, "tests.failing", len(status.FailingTests),
+			"tests.flaky", len(status.FlakyTests), "git.uncommitted", status.HasUncommitted)
+		check.End(nil)
+		if status.DoneWhen != "" {
+			fmt.Printf("📊 Status: done-when=%s uncommitted=%v\n", status.TestStatus, status.HasUncommitted)
+		} else {
+			fmt.Printf("📊 Status: tests=%s uncommitted=%v\n", status.TestStatus, status.HasUncommitted)
+		}
+		if status.TestReport != nil {
+			fmt.Printf("   %s\n", status.TestReport)
+			recordTestReport(name, attempt, status.TestReport)
+		}
+		if status.Coverage != nil {
+			fmt.Printf("🧪 Coverage: %s\n", status.Coverage)
+			if status.Coverage.Low {
+				fmt.Printf("⚠️  %s\n", status.Coverage.Reason)
+			}
+		}
+
+		if len(status.FlakyTests) > 0 {
+			fmt.Printf("🎲 Flaky tests: %s\n", strings.Join(status.FlakyTests, ", "))
+		}
+		if err := tagAttempt(name, attempt); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
 
-		result.TestsPassed = status.TestStatus == "pass"
+		result.TestsPassed = status.TestsOK()
+		result.TestStatus = status.TestStatus
 		result.HasChanges = status.HasUncommitted
+		done := status.Done()
+		var patch string
+		if cp.OutputPatch {
+			patch, done = patchDone(name, cp, &status)
+		}
 
-		// Done if tests pass and no uncommitted changes
-		if result.TestsPassed && !result.HasChanges {
+		// Done, but built on work that isn't merged yet: wait for it, then
+		// rebase in an extra attempt.
+		if done && repoURL != "" {
+			if pending := pendingDependencies(after, cp.Settled); len(pending) > 0 {
+				fmt.Printf("⏳ Task done; waiting for %s to merge\n", strings.Join(pending, ", "))
+				coordination.UpdateAgentState(repoURL, name, "waiting", "")
+				endAttempt("waiting", nil)
+				// Dependencies dropped without merging leave nothing to rebase onto.
+				note := ""
+				for note == "" && len(pendingDependencies(after, cp.Settled)) > 0 {
+					note = takeSettled(cp, awaitDependencies(repoURL, after, cp.Settled))
+				}
+				if note != "" {
+					task += note
+					maxAttempts++
+					cp.Task, cp.Attempt, cp.MaxAttempts = task, attempt, maxAttempts
+					saveCheckpoint(cp)
+					continue
+				}
+			}
+		}
+
+		// Done if tests pass, no uncommitted changes and coverage holds
+		if done {
 			result.Completed = true
 			fmt.Printf("✅ Task completed!\n")
+			endAttempt("completed", nil)
+			removeCheckpoint(name)
+			artifacts := exportConfiguredArtifacts(name)
+			if patch != "" {
+				if artifacts == nil {
+					artifacts = map[string]string{}
+				}
+				if path, err := savePatch(name, patch); err != nil {
+					fmt.Printf("⚠️  Could not save the patch: %v\n", err)
+				} else {
+					fmt.Printf("🩹 Patch: %s\n", path)
+					result.Patch, artifacts["patch"] = path, path
+				}
+			}
+			if summary := summarizeCompleted(name); summary != "" {
+				if artifacts == nil {
+					artifacts = map[string]string{}
+				}
+				artifacts["summary"] = summary
+			}
 
 			// Update coordination state to done and release all claims
 			if repoURL != "" {
@@ -108,30 +459,272 @@ Keep going until tests pass and all changes are committed.`,
 			}
 
 			// Save completion history for eventual cleanup
-			SaveHistory(&AgentHistory{
+			SaveHistory(withRunDetails(&AgentHistory{
 				Name:        name,
 				Repo:        repoURL,
 				Created:     loopStart,
 				CompletedAt: time.Now(),
 				Result:      "success",
 				Attempts:    attempt,
-			})
+				Metadata:    artifacts,
+
+				Instructions: result.Instructions,
+			}))
 
 			return result, nil
 		}
 
+		// Not done: work out why, and what to do about it.
+		class := classifyFailure(status, output)
+		strategy := strategies[class]
+		result.Failure = string(class)
+		span.Set("agent.failure", string(class), "agent.retry_strategy", string(strategy))
+		fmt.Printf("🩺 Failure: %s (%s)\n", class, strategy)
+
+		cp.Task, cp.Attempt, cp.Failure, cp.SetupRerun = task, attempt, string(class), false
+		cp.LastStatus = fmt.Sprintf("tests=%s uncommitted=%v", status.TestStatus, status.HasUncommitted)
+		if err := saveCheckpoint(cp); err != nil {
+			fmt.Printf("⚠️  Could not save checkpoint: %v\n", err)
+		}
+
+		// Credentials that expired mid-run may be refreshed from the host.
+		if strategy == RetryAbort && class == FailureAgentError && authMode() != "off" {
+			if check := verifyAuth(name, true, authTimeout()); check.OK {
+				fmt.Printf("🔑 Auth works again (%s); retrying\n", strings.Join(append(check.Refreshed, "probe passed"), ", "))
+				strategy = RetryPlain
+			}
+		}
+
+		// Some failures only waste the attempts left on them. The
+		// checkpoint stays, so the run can be resumed once they're fixed.
+		if strategy == RetryAbort {
+			endAttempt("aborted", err)
+			failRun(repoURL, name, loopStart, attempt, result, ErrUnrecoverable)
+			return result, fmt.Errorf("attempt %d failed on %s; fix it and resume with `agentctl run --resume %s`: %w", attempt, class, name, ErrUnrecoverable)
+		}
+
 		// Not done, loop continues
-		fmt.Printf("⏳ Not done yet, continuing...\n")
-		time.Sleep(3 * time.Second)
+		outcome := "not_done"
+		if conflict != nil {
+			outcome = "claim_conflict"
+		}
+		endAttempt(outcome, err)
+		misses++
+		if attempt < maxAttempts {
+			if strategy == RetrySetup && rerunSetup(name) {
+				cp.SetupRerun = true
+				saveCheckpoint(cp)
+			}
+			wait := pacing.backoff(misses)
+			if strategy == RetryBackoff {
+				wait = pacing.longest()
+			}
+			fmt.Printf("⏳ Not done yet, retrying in %s...\n", wait.Round(100*time.Millisecond))
+			time.Sleep(wait)
+		}
 	}
 
-	// Update coordination state on failure
+	removeCheckpoint(name)
+	failRun(repoURL, name, loopStart, maxAttempts, result, ErrMaxAttempts)
+	return result, fmt.Errorf("task not completed after %d attempts: %w", maxAttempts, ErrMaxAttempts)
+}
+
+// failRun records a run that ended without the task done: blocked on the
+// bus, and in history, which cleanup holds on to for longer.
+func failRun(repoURL, name string, loopStart time.Time, attempts int, result *TaskResult, reason error) {
 	if repoURL != "" {
 		coordination.UpdateAgentState(repoURL, name, "blocked", "")
 	}
+	result.Error = reason.Error()
+	var metadata map[string]string
+	if result.Failure != "" {
+		metadata = map[string]string{"failure": result.Failure}
+	}
+	SaveHistory(withRunDetails(&AgentHistory{
+		Name:        name,
+		Repo:        repoURL,
+		Created:     loopStart,
+		CompletedAt: time.Now(),
+		Result:      "failed",
+		Attempts:    attempts,
+		Metadata:    metadata,
+
+		Instructions: result.Instructions,
+	}))
+}
+
+// exportConfiguredArtifacts copies the config's artifact paths out of a
+// finished agent, returning history metadata naming where they went.
+func exportConfiguredArtifacts(name string) map[string]string {
+	cfg, err := config.Load()
+	if err != nil || len(cfg.Artifacts.Paths) == 0 {
+		return nil
+	}
+	files, err := ExportArtifacts(name, cfg.Artifacts.Paths)
+	if err != nil {
+		fmt.Printf("⚠️  Artifact export failed: %v\n", err)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	fmt.Printf("📦 %d artifact(s) in %s\n", len(files), ArtifactsDir(name))
+	return map[string]string{"artifacts": ArtifactsDir(name)}
+}
+
+// startHeartbeat beats on the bus now and every interval until the returned
+// function is called; a zero interval means heartbeats are off.
+func startHeartbeat(repoURL, name string, interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := coordination.Heartbeat(repoURL, name); err != nil {
+				fmt.Printf("⚠️  Heartbeat failed: %v\n", err)
+			}
+			// Pushes the agents' hooks recorded go out on the same tick.
+			relayPushes(repoURL)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// guardStop is why the guard stopped the run: a kill/pause violation, or
+// the guard itself failing.
+type guardStop struct {
+	violation *PolicyViolation
+	err       error
+}
+
+// startGuard runs Guard in the background and delivers what stopped it,
+// unless the run stopped it first.
+func startGuard(name string, policy *Policy, stop <-chan struct{}) <-chan guardStop {
+	stopped := make(chan guardStop, 1)
+	go func() {
+		v, err := Guard(name, policy, stop)
+		if err != nil {
+			fmt.Printf("🛡️  Policy guard failed: %v\n", err)
+		}
+		if v != nil || err != nil {
+			stopped <- guardStop{v, err}
+		}
+	}()
+	return stopped
+}
+
+// awaitTask runs the agent's task, returning early if the guard stops the
+// agent (a paused container would otherwise block the exec forever) or
+// fails, which interrupts the task. Claim conflicts interrupt the task,
+// which then ends on its own. The task's output comes back with the rest.
+func awaitTask(name, prompt, resume string, guard <-chan guardStop, conflicts <-chan []ClaimConflict) (string, *guardStop, []ClaimConflict, error) {
+	type taskDone struct {
+		output string
+		err    error
+	}
+	// A guard that stopped between attempts stops the next before it starts.
+	select {
+	case g := <-guard:
+		return "", &g, nil, nil
+	default:
+	}
+	done := make(chan taskDone, 1)
+	go func() {
+		output, err := runTask(name, prompt, resume)
+		done <- taskDone{output, err}
+	}()
+	var conflict []ClaimConflict
+	for {
+		select {
+		case d := <-done:
+			select {
+			case g := <-guard:
+				return d.output, &g, conflict, d.err
+			default:
+				return d.output, nil, conflict, d.err
+			}
+		case g := <-guard:
+			if g.err != nil {
+				if err := interruptTask(name); err != nil {
+					fmt.Printf("⚠️  Could not interrupt %s: %v\n", name, err)
+				}
+			}
+			return "", &g, conflict, nil
+		case conflict = <-conflicts:
+			conflicts = nil
+			if err := interruptTask(name); err != nil {
+				fmt.Printf("⚠️  Could not interrupt %s: %v\n", name, err)
+			}
+		}
+	}
+}
+
+// flakyNote tells the agent which failures are intermittent so it doesn't
+// spend attempts chasing them.
+func flakyNote(flaky []string) string {
+	if len(flaky) == 0 {
+		return ""
+	}
+	return "- Flaky tests (fail intermittently, not caused by you — do not try to fix): " +
+		strings.Join(flaky, ", ") + "\n"
+}
+
+// exitCodeLine is the marker the test commands append to their output.
+var exitCodeLine = regexp.MustCompile(`(?m)^EXIT_CODE:\d+\s*$`)
+
+// testOutputTail returns the last n lines of a test run's output, without
+// the EXIT_CODE marker.
+func testOutputTail(output string, n int) string {
+	output = exitCodeLine.ReplaceAllString(output, "")
+	return tailLines(strings.TrimSpace(output), n)
+}
+
+// retryPrompt tells the agent where the previous attempt left things,
+// including which tests fail and how, so it doesn't have to re-run the suite
+// just to find out.
+func retryPrompt(task string, status AgentStatus, patchOnly bool) string {
+	var failing string
+	if len(status.FailingTests) > 0 {
+		failing = "- Failing tests: " + strings.Join(status.FailingTests, ", ") + "\n"
+	}
+	// A --done-when command stands in for the suite.
+	tests, passing, source, rerun := "Tests", "tests pass", "the test output", "the whole suite"
+	if cmd := status.DoneWhen; cmd != "" {
+		tests, passing, source, rerun = "`"+cmd+"`", "`"+cmd+"` succeeds", "its output", "it"
+	}
+	var output string
+	if status.TestOutput != "" {
+		output = fmt.Sprintf("\nLast %d lines of %s:\n```\n%s\n```\nStart from these failures; there is no need to re-run %s to find them.\n",
+			strings.Count(status.TestOutput, "\n")+1, source, status.TestOutput, rerun)
+	}
+	goal := "Keep going until " + passing + " and all changes are committed."
+	if patchOnly {
+		goal = "Keep going until " + passing + ". Leave your changes uncommitted."
+	}
+	result := status.TestStatus
+	if status.TestReport != nil {
+		result += " (" + status.TestReport.String() + ")"
+	}
+	return fmt.Sprintf(`Continue working. Previous status:
+- %s: %s
+- Uncommitted changes: %v
+%s%s%s%s
+Original task: %s
 
-	result.Error = "max attempts reached"
-	return result, fmt.Errorf("task not completed after %d attempts", maxAttempts)
+%s`,
+		tests, result, status.HasUncommitted, failing, flakyNote(status.FlakyTests), coverageNote(status.Coverage), output, task, goal)
 }
 
 // CheckCompletion checks if an agent's task appears complete
@@ -140,79 +733,194 @@ func CheckCompletion(name string) AgentStatus {
 }
 
 func getStatus(name string) AgentStatus {
-	status := AgentStatus{TestStatus: "unknown"}
+	return statusOf(name, "")
+}
+
+// statusOf checks the agent, judging the work by doneWhen's exit code
+// instead of the test suite when it is set.
+func statusOf(name, doneWhen string) AgentStatus {
+	status := AgentStatus{TestStatus: "unknown", DoneWhen: doneWhen}
 
 	// Check for uncommitted changes
-	out, _ := exec.Command("podman", "exec", name, "sh", "-c",
-		"cd /home/agent/workspace/repo && git status --porcelain 2>/dev/null").Output()
+	layout := layoutOf(name)
+	cd := layout.cd()
+	testLog := layout.Path("tests.log")
+	out, _ := podmanRetry("exec", containerName(name), "sh", "-c",
+		cd+"git status --porcelain 2>/dev/null")
 	status.HasUncommitted = len(strings.TrimSpace(string(out))) > 0
+	status.Unmerged = unmergedFiles(string(out))
 
 	// Check if tests pass (try common test runners)
 	// Use exit code for reliable pass/fail detection
 	testCmds := []struct {
-		check string // command to check if test runner exists
-		run   string // command to run tests
+		runner string // how ParseTestReport reads its output
+		check  string // command to check if test runner exists
+		run    string // command to run tests
 	}{
 		{
-			check: "cd /home/agent/workspace/repo && test -f vendor/bin/pest",
-			run:   "cd /home/agent/workspace/repo && vendor/bin/pest --no-coverage 2>&1; echo EXIT_CODE:$?",
+			runner: "pest",
+			check:  cd + "test -f vendor/bin/pest",
+			run:    cd + "vendor/bin/pest --no-coverage 2>&1; echo EXIT_CODE:$?",
 		},
 		{
-			check: "cd /home/agent/workspace/repo && test -f package.json",
-			run:   "cd /home/agent/workspace/repo && npm test 2>&1; echo EXIT_CODE:$?",
+			runner: "jest",
+			check:  cd + "test -f package.json",
+			run:    cd + "npm test 2>&1; echo EXIT_CODE:$?",
 		},
 		{
-			check: "cd /home/agent/workspace/repo && test -f go.mod",
-			run:   "cd /home/agent/workspace/repo && go test ./... 2>&1; echo EXIT_CODE:$?",
+			runner: "go",
+			check:  cd + "test -f go.mod",
+			run:    cd + "go test ./... 2>&1; echo EXIT_CODE:$?",
 		},
 		{
-			check: "cd /home/agent/workspace/repo && test -f pytest.ini -o -f pyproject.toml",
-			run:   "cd /home/agent/workspace/repo && pytest 2>&1; echo EXIT_CODE:$?",
+			runner: "pytest",
+			check:  cd + "test -f pytest.ini -o -f pyproject.toml",
+			run:    cd + "pytest 2>&1; echo EXIT_CODE:$?",
 		},
 		{
-			check: "cd /home/agent/workspace/repo && test -f Cargo.toml",
-			run:   "cd /home/agent/workspace/repo && cargo test 2>&1; echo EXIT_CODE:$?",
+			runner: "cargo",
+			check:  cd + "test -f Cargo.toml",
+			run:    cd + "cargo test 2>&1; echo EXIT_CODE:$?",
 		},
 	}
 
+	if doneWhen != "" {
+		testCmds = nil
+		status.TestStatus, status.TestOutput = checkDoneWhen(name, doneWhen)
+	}
 	for _, tc := range testCmds {
 		// Check if test runner exists
-		if err := exec.Command("podman", "exec", name, "sh", "-c", tc.check).Run(); err != nil {
+		if _, err := podmanRetry("exec", containerName(name), "sh", "-c", tc.check); err != nil {
 			continue
 		}
-		// Run tests and check exit code
-		out, _ := exec.Command("podman", "exec", name, "sh", "-c", tc.run).Output()
-		output := string(out)
-		if strings.Contains(output, "EXIT_CODE:0") {
-			status.TestStatus = "pass"
-		} else {
-			status.TestStatus = "fail"
+		// Run tests and check exit code. A failing suite is re-run up to
+		// tests.runs times so intermittent failures can be told apart
+		// from real ones.
+		var runs []testRun
+		var failedOutput string
+		for i := 0; i < testRuns(); i++ {
+			// The run is also appended to tests.log for `agentctl logs --source tests`.
+			script := logAppend(testLog, "tests") + "{ " + tc.run + "; } 2>&1 | tee -a " + shellQuote(testLog)
+			out, _ := podmanLong("exec", containerName(name), "sh", "-c", script).Output()
+			output := string(out)
+			status.TestReport = ParseTestReport(tc.runner, output)
+			run := testRun{Passed: strings.Contains(output, "EXIT_CODE:0")}
+			if !run.Passed {
+				run.Failing = ParseFailingTests(output)
+				failedOutput = output
+			}
+			runs = append(runs, run)
+			if i == 0 && run.Passed {
+				break
+			}
+		}
+		status.TestStatus, status.FailingTests, status.FlakyTests = classifyRuns(runs)
+		if n := testOutputLines(); status.TestStatus == "fail" && n > 0 {
+			status.TestOutput = testOutputTail(failedOutput, n)
 		}
 		break
 	}
+	// Coverage is only worth measuring once the change is otherwise done.
+	if status.TestsOK() && !status.HasUncommitted && doneWhen == "" {
+		status.Coverage = checkCoverage(name)
+	}
 
 	// Check if the agent task runner is active
-	out, _ = exec.Command("podman", "exec", name, "sh", "-c",
-		"ps aux 2>/dev/null | grep -v grep | grep -E 'run-task|claude|opencode' || true").Output()
+	if st, err := probeShim(name); err == nil {
+		status.ClaudeRunning = st.Running()
+		return status
+	}
+	out, _ = podmanRetry("exec", containerName(name), "sh", "-c",
+		"ps aux 2>/dev/null | grep -v grep | grep -E 'run-task|claude|opencode' || true")
 	status.ClaudeRunning = len(strings.TrimSpace(string(out))) > 0
 
 	return status
 }
 
+// unmergedFiles picks the conflicted paths out of `git status --porcelain`.
+func unmergedFiles(porcelain string) []string {
+	var files []string
+	for _, line := range strings.Split(porcelain, "\n") {
+		if len(line) > 3 && unmergedCodes[line[:2]] {
+			files = append(files, line[3:])
+		}
+	}
+	return files
+}
+
+// unmergedCodes are the porcelain status codes of conflicted paths.
+var unmergedCodes = map[string]bool{"DD": true, "AU": true, "UD": true, "UA": true, "DU": true, "AA": true, "UU": true}
+
+// ResumeSessionEnv carries the session an attempt should resume (run
+// --continue-session): an image's run-task starts Claude with --resume
+// "$AGENTCTL_RESUME_SESSION", or opencode with --session, when it is set.
+const ResumeSessionEnv = "AGENTCTL_RESUME_SESSION"
+
+// opencodeSessionGlob matches opencode's session records, relative to the
+// home directory.
+const opencodeSessionGlob = ".local/share/opencode/storage/session/*/ses_*.json"
+
+// latestSession is the ID of the agent's live Claude session, the one spy
+// follows, or failing that its latest opencode session; "" when there is
+// none.
+func latestSession(name string) string {
+	if file, err := discoverSessionFile(name); err == nil {
+		return strings.TrimSuffix(path.Base(file), ".jsonl")
+	}
+	out, _ := podmanRetry("exec", containerName(name), "sh", "-c",
+		"ls -t "+layoutOf(name).Path(opencodeSessionGlob)+" 2>/dev/null | head -n 1")
+	if file := strings.TrimSpace(string(out)); file != "" {
+		return strings.TrimSuffix(path.Base(file), ".json")
+	}
+	return ""
+}
+
+// runTaskResumes reports whether the image's run-task reads
+// ResumeSessionEnv. One that can't be inspected is given the benefit of
+// the doubt.
+func runTaskResumes(name string) bool {
+	out, _ := podmanRetry("exec", containerName(name), "sh", "-c",
+		`f=$(command -v run-task) || exit 0; grep -q `+ResumeSessionEnv+` "$f" || echo ignored`)
+	return strings.TrimSpace(string(out)) != "ignored"
+}
+
 // runTask calls the image's standard run-task entrypoint with the given prompt.
 // Each image ships its own /usr/local/bin/run-task so agentctl stays image-agnostic.
-func runTask(name string, prompt string) error {
-	escaped := strings.ReplaceAll(prompt, "'", "'\\''")
-
-	cmd := exec.Command("podman", "exec", name, "sh", "-c",
-		fmt.Sprintf("cd /home/agent/workspace/repo && run-task '%s' 2>&1 | tee -a /home/agent/claude.log", escaped))
+// The prompt goes over stdin rather than into the shell command, so quotes,
+// backticks, $(...), newlines and emoji reach the agent exactly as written.
+// A resume session ID is passed on in ResumeSessionEnv. The output is
+// returned for classifyFailure.
+func runTask(name, prompt, resume string) (string, error) {
+	keyArgs, keyEnv := llmKeyEnv()
+	argv := append([]string{"podman", "exec", "-i"}, keyArgs...)
+	if resume != "" {
+		argv = append(argv, "-e", ResumeSessionEnv+"="+resume)
+	}
+	argv = append(argv, containerName(name), "sh", "-c", runTaskScript(layoutOf(name)))
+	span := podmanSpan(argv[1:])
+	if f := faultFor(argv[1:]); f != nil {
+		argv = f.command()
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = keyEnv
+	cmd.Stdin = strings.NewReader(prompt)
 
 	output, err := cmd.CombinedOutput()
+	endPodmanSpan(span, err)
 	if len(output) > 500 {
 		fmt.Printf("📝 Output (truncated): %s...\n", string(output[:500]))
 	} else if len(output) > 0 {
 		fmt.Printf("📝 Output: %s\n", string(output))
 	}
 
-	return err
+	return string(output), err
+}
+
+// runTaskScript saves the prompt arriving on stdin to prompt.txt in the
+// agent's home, then hands it to run-task as a single argument read back
+// from the file, so the prompt never goes through the shell's parser.
+func runTaskScript(layout Layout) string {
+	prompt := shellQuote(layout.Path("prompt.txt"))
+	return fmt.Sprintf(`%s%scat > %s && run-task "$(cat %s)" < /dev/null 2>&1 | tee -a %s`,
+		logAppend(layout.Path("claude.log"), "run-task"), layout.cd(), prompt, prompt, shellQuote(layout.Path("claude.log")))
 }
@@ -0,0 +1,133 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRetryPrompt(t *testing.T) {
+	output := "ok  \tpkg/a\n--- FAIL: TestLogin (0.01s)\n    login_test.go:12: got 500\nFAIL\npkg/auth\nEXIT_CODE:1\n"
+	status := AgentStatus{
+		TestStatus:   "fail",
+		FailingTests: ParseFailingTests(output),
+		FlakyTests:   []string{"TestClock"},
+		TestOutput:   testOutputTail(output, 3),
+	}
+	if status.TestOutput != "    login_test.go:12: got 500\nFAIL\npkg/auth" {
+		t.Fatalf("testOutputTail() = %q", status.TestOutput)
+	}
+
+	prompt := retryPrompt("fix login", status, false)
+	for _, want := range []string{
+		"- Tests: fail",
+		"- Failing tests: TestLogin\n",
+		"Flaky tests (fail intermittently",
+		"Last 3 lines of the test output:\n```\n    login_test.go:12: got 500\nFAIL\npkg/auth\n```",
+		"Original task: fix login",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+
+	prompt = retryPrompt("fix login", AgentStatus{TestStatus: "pass", HasUncommitted: true}, false)
+	if strings.Contains(prompt, "Failing tests") || strings.Contains(prompt, "test output") {
+		t.Errorf("passing status prompt mentions failures:\n%s", prompt)
+	}
+}
+
+func TestRunTaskPassesPromptVerbatim(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	// podman runs the exec'd shell on the host, and run-task records the
+	// arguments it was given.
+	fakePodman(t, `while [ "$1" != sh ]; do shift; done; exec "$@"`)
+	bin, home := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(bin, "run-task"), []byte("#!/bin/sh\necho \"$#\" > \"$HOME/argc\"\nprintf '%s' \"$1\" > \"$HOME/argv\"\n"), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	os.MkdirAll(filepath.Join(home, "ws"), 0755)
+	saveAgent(&Agent{Name: "worker", Layout: Layout{Home: home, Workspace: filepath.Join(home, "ws")}})
+
+	prompt := "Fix `make test` — don't run $(rm -rf /) or '$HOME'\n\n\"quoted\" \\ 🚀 ; exit 1"
+	if _, err := runTask("worker", prompt, ""); err != nil {
+		t.Fatalf("runTask: %v", err)
+	}
+	if argc, _ := os.ReadFile(filepath.Join(tmpHome, "argc")); strings.TrimSpace(string(argc)) != "1" {
+		t.Errorf("run-task got %s arguments, want 1", argc)
+	}
+	if argv, _ := os.ReadFile(filepath.Join(tmpHome, "argv")); string(argv) != prompt {
+		t.Errorf("run-task got %q, want %q", argv, prompt)
+	}
+	if saved, _ := os.ReadFile(filepath.Join(home, "prompt.txt")); string(saved) != prompt {
+		t.Errorf("prompt.txt = %q", saved)
+	}
+}
+
+func TestContinueSession(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"), []byte(`{"run_loop": {"settle": "0s", "backoff": "1ms"}}`), 0644)
+
+	// Each attempt leaves a session named after it; the tests pass on the third.
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) echo OK ;;
+*prompt.txt*) echo "$*" >> `+calls+`; cat > /dev/null ;;
+*"stat -c"*) echo "1700000000 /home/agent/.claude/projects/-workspace/sess-$(wc -l < `+calls+` | tr -d ' ').jsonl" ;;
+*"test -f go.mod"*) exit 0 ;;
+*"go test"*) if [ "$(wc -l < `+calls+`)" -ge 3 ]; then echo EXIT_CODE:0; else echo FAIL; echo EXIT_CODE:1; fi ;;
+*"test -f"*) exit 1 ;;
+esac`)
+	saveAgent(&Agent{Name: "worker"})
+
+	result, err := RunWithOptions("worker", "fix login", 3, RunOptions{ContinueSession: true})
+	if err != nil || !result.Completed {
+		t.Fatalf("RunWithOptions() = %+v, %v", result, err)
+	}
+	data, _ := os.ReadFile(calls)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d attempts, want 3: %s", len(lines), data)
+	}
+	if strings.Contains(lines[0], ResumeSessionEnv) {
+		t.Errorf("the first attempt has nothing to resume: %s", lines[0])
+	}
+	for i, session := range []string{"sess-1", "sess-2"} {
+		if want := "-e " + ResumeSessionEnv + "=" + session + " "; !strings.Contains(lines[i+1], want) {
+			t.Errorf("attempt %d = %s, want it to resume %s", i+2, lines[i+1], session)
+		}
+	}
+}
+
+func TestContinueOpencodeSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	saveAgent(&Agent{Name: "worker"})
+
+	// No Claude session: opencode's latest is resumed instead.
+	fakePodman(t, `case "$*" in
+*"ls -t"*) echo /home/agent/.local/share/opencode/storage/session/global/ses_abc123.json ;;
+*"grep -q"*) echo ignored ;;
+esac`)
+	if got := latestSession("worker"); got != "ses_abc123" {
+		t.Errorf("latestSession() = %q, want ses_abc123", got)
+	}
+	if runTaskResumes("worker") {
+		t.Error("runTaskResumes() = true for a run-task that ignores the session")
+	}
+
+	fakePodman(t, `exit 0`)
+	if got := latestSession("worker"); got != "" {
+		t.Errorf("latestSession() = %q with no sessions", got)
+	}
+	if !runTaskResumes("worker") {
+		t.Error("runTaskResumes() = false for a run-task that reads the session")
+	}
+}
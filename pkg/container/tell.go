@@ -0,0 +1,125 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// Instruction is an operator's message to a running agent, queued by Tell
+// and appended to the task at the loop's next attempt.
+type Instruction struct {
+	Time    time.Time `json:"time"`
+	Author  string    `json:"author,omitempty"`
+	Text    string    `json:"text"`
+	Attempt int       `json:"attempt,omitempty"` // the attempt it was given to; 0 while queued
+}
+
+func (i Instruction) String() string {
+	s := Note{Time: i.Time, Author: i.Author, Text: i.Text}.String()
+	if i.Attempt > 0 {
+		s += fmt.Sprintf(" (attempt %d)", i.Attempt)
+	}
+	return s
+}
+
+func instructionsDir() string {
+	return filepath.Join(namespace.Root(), "tell")
+}
+
+func instructionsPath(name string) string {
+	return filepath.Join(instructionsDir(), name+".jsonl")
+}
+
+// Tell queues an instruction for the agent's next attempt, so a run can be
+// course-corrected without killing the loop.
+func Tell(name, text string) (*Instruction, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("message is empty")
+	}
+	if _, err := loadAgent(name); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(instructionsDir(), 0755); err != nil {
+		return nil, err
+	}
+	ins := Instruction{Time: time.Now(), Author: noteAuthor(), Text: text}
+	line, _ := json.Marshal(ins)
+	f, err := os.OpenFile(instructionsPath(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return &ins, nil
+}
+
+// PendingInstructions returns the instructions queued for the agent's next
+// attempt.
+func PendingInstructions(name string) []Instruction {
+	return readInstructions(instructionsPath(name))
+}
+
+func readInstructions(path string) []Instruction {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []Instruction
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ins Instruction
+		if json.Unmarshal(scanner.Bytes(), &ins) == nil && ins.Text != "" {
+			out = append(out, ins)
+		}
+	}
+	return out
+}
+
+// takeInstructions empties the agent's queue for attempt and records what
+// it took in the agent's metadata, which carries it into history.
+func takeInstructions(name string, attempt int) []Instruction {
+	// Move the queue aside first so a Tell racing with the take lands in a
+	// fresh queue for the next attempt instead of being lost.
+	taking := instructionsPath(name) + ".taking"
+	if err := os.Rename(instructionsPath(name), taking); err != nil {
+		return nil
+	}
+	told := readInstructions(taking)
+	os.Remove(taking)
+	for i := range told {
+		told[i].Attempt = attempt
+	}
+	if len(told) > 0 {
+		updateAgent(name, func(agent *Agent) error {
+			agent.Instructions = append(agent.Instructions, told...)
+			return nil
+		})
+	}
+	return told
+}
+
+func removeInstructions(name string) {
+	os.Remove(instructionsPath(name))
+}
+
+// instructionNote is the text added to the task for instructions; it stays
+// in the task, so later attempts keep following them.
+func instructionNote(told []Instruction) string {
+	var b strings.Builder
+	for _, ins := range told {
+		fmt.Fprintf(&b, "\n\nIMPORTANT: Instruction from the operator (given before attempt %d): %s", ins.Attempt, ins.Text)
+	}
+	return b.String()
+}
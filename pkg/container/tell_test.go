@@ -0,0 +1,86 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTellQueue(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	fakePodman(t, "exit 0")
+
+	if _, err := Tell("nobody", "use the v2 client"); err == nil {
+		t.Error("expected an error for an unknown agent")
+	}
+	saveAgent(&Agent{Name: "steered", Repo: "https://github.com/test/repo"})
+	if _, err := Tell("steered", "  "); err == nil {
+		t.Error("expected an error for an empty message")
+	}
+	Tell("steered", "use the v2 client")
+	Tell("steered", "don't touch the migrations")
+	if got := PendingInstructions("steered"); len(got) != 2 || got[0].Text != "use the v2 client" {
+		t.Fatalf("PendingInstructions = %+v", got)
+	}
+
+	told := takeInstructions("steered", 3)
+	if len(told) != 2 || told[1].Attempt != 3 {
+		t.Fatalf("takeInstructions = %+v", told)
+	}
+	if len(PendingInstructions("steered")) != 0 || len(takeInstructions("steered", 4)) != 0 {
+		t.Error("the queue should be empty once taken")
+	}
+	note := instructionNote(told)
+	if !strings.Contains(note, "(given before attempt 3): use the v2 client") || !strings.Contains(note, "don't touch the migrations") {
+		t.Errorf("instructionNote = %q", note)
+	}
+
+	// Delivered instructions stay with the agent and move to its history.
+	if err := Cleanup("steered", "success", 3, nil); err != nil {
+		t.Fatal(err)
+	}
+	h, err := LoadHistory("steered")
+	if err != nil || len(h.Instructions) != 2 || h.Instructions[0].Attempt != 3 {
+		t.Errorf("history = %+v, %v", h, err)
+	}
+}
+
+func TestTellReachesNextAttempt(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	fakePodman(t, `case "$*" in
+*AUTH_PROBE*) echo OK ;;
+*run-task*) { echo "$*"; cat; } >> `+calls+` ;;
+*"test -f go.mod"*) exit 0 ;;
+*"go test"*) echo EXIT_CODE:0 ;;
+*"test -f"*) exit 1 ;;
+esac`)
+
+	saveAgent(&Agent{Name: "worker"})
+	saveCheckpoint(&Checkpoint{Agent: "worker", Task: "fix login", Attempt: 1, MaxAttempts: 2, LoopStart: time.Now()})
+	Tell("worker", "keep the old endpoint working")
+
+	result, err := ResumeRun("worker", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Instructions) != 1 || result.Instructions[0].Attempt != 2 {
+		t.Errorf("result.Instructions = %+v", result.Instructions)
+	}
+	out, _ := os.ReadFile(calls)
+	if !strings.Contains(string(out), "keep the old endpoint working") {
+		t.Errorf("the instruction did not reach the prompt: %s", out)
+	}
+	if h, err := LoadHistory("worker"); err != nil || len(h.Instructions) != 1 {
+		t.Errorf("history = %+v, %v", h, err)
+	}
+}
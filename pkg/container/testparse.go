@@ -0,0 +1,86 @@
+package container
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// failurePatterns extract failing test names from common runner output.
+// Each pattern's first submatch is the test name.
+var failurePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^\s*--- FAIL: (\S+)`),                  // go test
+	regexp.MustCompile(`(?m)^FAILED (\S+::\S+)`),                   // pytest
+	regexp.MustCompile(`(?m)^test (\S+) \.\.\. FAILED`),            // cargo test
+	regexp.MustCompile(`(?m)^\s*(?:✕|×) (.+?)(?: \(\d+ ?m?s\))?$`), // jest / vitest
+	regexp.MustCompile(`(?m)^(?:\s+FAILED|\s*⨯)\s+(\S.*?)\s*$`),    // pest (indented, unlike pytest)
+}
+
+// ParseFailingTests returns the sorted, de-duplicated names of failing tests
+// found in a test runner's output.
+func ParseFailingTests(output string) []string {
+	seen := make(map[string]bool)
+	for _, re := range failurePatterns {
+		for _, m := range re.FindAllStringSubmatch(output, -1) {
+			name := strings.TrimSpace(m[1])
+			if name != "" {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// testRun is the outcome of one execution of the suite.
+type testRun struct {
+	Passed  bool
+	Failing []string
+}
+
+// classifyRuns folds repeated runs into a status. Tests that fail in every
+// run are real failures; tests that fail only sometimes are flaky. A suite
+// whose only failures are intermittent is "flaky" rather than "fail", but
+// only if it passed at least once.
+func classifyRuns(runs []testRun) (status string, failing, flaky []string) {
+	if len(runs) == 0 {
+		return "unknown", nil, nil
+	}
+	passes := 0
+	counts := make(map[string]int)
+	for _, r := range runs {
+		if r.Passed {
+			passes++
+		}
+		for _, name := range r.Failing {
+			counts[name]++
+		}
+	}
+	for name, n := range counts {
+		if n == len(runs) {
+			failing = append(failing, name)
+		} else {
+			flaky = append(flaky, name)
+		}
+	}
+	sort.Strings(failing)
+	sort.Strings(flaky)
+
+	switch {
+	case passes == len(runs):
+		return "pass", nil, flaky
+	case len(failing) > 0:
+		return "fail", failing, flaky
+	case passes == 0:
+		// Every run failed, just not on the same tests (or without naming
+		// one: compile error, crash). The suite never passed, so whatever
+		// failed is a real failure.
+		return "fail", flaky, nil
+	default:
+		return "flaky", nil, flaky
+	}
+}
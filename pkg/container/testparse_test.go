@@ -0,0 +1,77 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFailingTests(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{"go", "=== RUN   TestA\n--- FAIL: TestA (0.00s)\n    --- FAIL: TestB/sub (0.00s)\n--- PASS: TestC\nFAIL\n", []string{"TestA", "TestB/sub"}},
+		{"pytest", "FAILED tests/test_api.py::test_login - AssertionError\n", []string{"tests/test_api.py::test_login"}},
+		{"cargo", "test parser::tests::empty ... FAILED\ntest ok_one ... ok\n", []string{"parser::tests::empty"}},
+		{"jest", "  ✕ renders header (12 ms)\n  ✓ renders footer\n", []string{"renders header"}},
+		{"pest", "  FAILED  Tests\\Feature\\LoginTest > it logs in\n", []string{"Tests\\Feature\\LoginTest > it logs in"}},
+		{"none", "ok  \tpkg/foo\t0.01s\n", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseFailingTests(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFailingTests() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyRuns(t *testing.T) {
+	tests := []struct {
+		name        string
+		runs        []testRun
+		wantStatus  string
+		wantFailing []string
+		wantFlaky   []string
+	}{
+		{"no runs", nil, "unknown", nil, nil},
+		{"single pass", []testRun{{Passed: true}}, "pass", nil, nil},
+		{"single fail", []testRun{{Failing: []string{"TestA"}}}, "fail", []string{"TestA"}, nil},
+		{"consistent failure", []testRun{{Failing: []string{"TestA"}}, {Failing: []string{"TestA"}}}, "fail", []string{"TestA"}, nil},
+		{"intermittent only", []testRun{{Failing: []string{"TestA"}}, {Passed: true}}, "flaky", nil, []string{"TestA"}},
+		{"flaky alongside real failure", []testRun{
+			{Failing: []string{"TestA", "TestB"}},
+			{Failing: []string{"TestA"}},
+		}, "fail", []string{"TestA"}, []string{"TestB"}},
+		{"unnamed failures every run", []testRun{{}, {}}, "fail", nil, nil},
+		{"different failures every run", []testRun{
+			{Failing: []string{"TestB"}},
+			{Failing: []string{"TestA"}},
+		}, "fail", []string{"TestA", "TestB"}, nil},
+		{"named failure then compile error", []testRun{{Failing: []string{"TestA"}}, {}}, "fail", []string{"TestA"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, failing, flaky := classifyRuns(tt.runs)
+			if status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(failing, tt.wantFailing) {
+				t.Errorf("failing = %v, want %v", failing, tt.wantFailing)
+			}
+			if !reflect.DeepEqual(flaky, tt.wantFlaky) {
+				t.Errorf("flaky = %v, want %v", flaky, tt.wantFlaky)
+			}
+		})
+	}
+}
+
+func TestAgentStatusTestsOK(t *testing.T) {
+	for status, want := range map[string]bool{"pass": true, "flaky": true, "fail": false, "unknown": false} {
+		if got := (AgentStatus{TestStatus: status}).TestsOK(); got != want {
+			t.Errorf("TestsOK(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TestReport is what a test run's output says about the suite: how many
+// tests passed, failed and were skipped, how long it took and which tests
+// failed.
+type TestReport struct {
+	Runner string `json:"runner"` // go, pest, jest, pytest or cargo
+	// Passed is -1 when the runner doesn't report it (go test without -v).
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+	Skipped  int           `json:"skipped,omitempty"`
+	Seconds  float64       `json:"seconds,omitempty"`
+	Failures []TestFailure `json:"failures,omitempty"`
+}
+
+// TestFailure is one failing test, with its time when the runner gives it.
+type TestFailure struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds,omitempty"`
+}
+
+// String summarises the report: "2 failed, 40 passed, 1 skipped in 3.2s".
+func (r *TestReport) String() string {
+	var parts []string
+	if r.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", r.Failed))
+	}
+	if r.Passed >= 0 {
+		parts = append(parts, fmt.Sprintf("%d passed", r.Passed))
+	}
+	if r.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", r.Skipped))
+	}
+	s := strings.Join(parts, ", ")
+	if s == "" {
+		s = "no tests counted"
+	}
+	if r.Seconds > 0 {
+		s += fmt.Sprintf(" in %.1fs", r.Seconds)
+	}
+	return s
+}
+
+var (
+	// summaryCounts reads "2 failed, 40 passed" style summaries.
+	summaryCounts = regexp.MustCompile(`(\d+) (passed|failed|skipped|ignored|pending|todo|errors?|incomplete|risky)\b`)
+
+	goResult    = regexp.MustCompile(`(?m)^--- (PASS|FAIL|SKIP): (\S+) \(([\d.]+)s\)`)
+	goPackage   = regexp.MustCompile(`(?m)^(?:ok|FAIL)\s+\S+\s+([\d.]+)s`)
+	jestSummary = regexp.MustCompile(`(?m)^\s*Tests:?\s+(.*\d+ (?:passed|failed|skipped|todo).*)$`)
+	jestTime    = regexp.MustCompile(`(?m)^\s*(?:Time:|Duration)\s+([\d.]+) ?(ms|s)\b`)
+	jestFailure = regexp.MustCompile(`(?m)^\s*(?:✕|×) (.+?) \((\d+) ?ms\)$`)
+	pestTime    = regexp.MustCompile(`(?m)^\s*Duration:\s+([\d.]+)s`)
+	phpunitOK   = regexp.MustCompile(`(?m)^OK \((\d+) tests?`)
+	phpunitSum  = regexp.MustCompile(`(?m)^Tests: (\d+), Assertions: \d+(.*)$`)
+	phpunitPart = regexp.MustCompile(`(\w+): (\d+)`)
+	phpunitTime = regexp.MustCompile(`(?m)^Time: (\d+):([\d.]+)`)
+	pytestSum   = regexp.MustCompile(`(?m)^=+ (.*\d+ (?:passed|failed|skipped|errors?).*) in ([\d.]+)s`)
+	cargoSum    = regexp.MustCompile(`(?m)^test result: \w+\. (.*?); finished in ([\d.]+)s`)
+)
+
+// ParseTestReport reads a runner's output into a report, or returns nil
+// when the output has neither a summary nor failures it recognises.
+func ParseTestReport(runner, output string) *TestReport {
+	r := &TestReport{Runner: runner}
+	found := false
+	seconds := map[string]float64{}
+	switch runner {
+	case "go":
+		r.Passed = -1
+		for _, m := range goResult.FindAllStringSubmatch(output, -1) {
+			found = true
+			switch m[1] {
+			case "PASS":
+				r.Passed = max(r.Passed, 0) + 1
+			case "FAIL":
+				r.Failed++
+				seconds[m[2]] = parseSeconds(m[3])
+			case "SKIP":
+				r.Skipped++
+			}
+		}
+		for _, m := range goPackage.FindAllStringSubmatch(output, -1) {
+			found = true
+			r.Seconds += parseSeconds(m[1])
+		}
+	case "pest":
+		if m := jestSummary.FindAllStringSubmatch(output, -1); len(m) > 0 {
+			found = r.addCounts(m[len(m)-1][1])
+		}
+		if m := pestTime.FindStringSubmatch(output); m != nil {
+			r.Seconds = parseSeconds(m[1])
+		}
+		// Plain PHPUnit, under vendor/bin/pest's name or not.
+		if m := phpunitOK.FindStringSubmatch(output); m != nil && !found {
+			found = true
+			r.Passed, _ = strconv.Atoi(m[1])
+		}
+		if m := phpunitSum.FindStringSubmatch(output); m != nil && !found {
+			found = true
+			total, _ := strconv.Atoi(m[1])
+			for _, kv := range phpunitPart.FindAllStringSubmatch(m[2], -1) {
+				n, _ := strconv.Atoi(kv[2])
+				switch kv[1] {
+				case "Errors", "Failures":
+					r.Failed += n
+				case "Skipped", "Incomplete":
+					r.Skipped += n
+				}
+			}
+			r.Passed = total - r.Failed - r.Skipped
+		}
+		if m := phpunitTime.FindStringSubmatch(output); m != nil && r.Seconds == 0 {
+			mins, _ := strconv.Atoi(m[1])
+			r.Seconds = float64(mins)*60 + parseSeconds(m[2])
+		}
+	case "jest":
+		if m := jestSummary.FindAllStringSubmatch(output, -1); len(m) > 0 {
+			found = r.addCounts(m[len(m)-1][1])
+		}
+		if m := jestTime.FindStringSubmatch(output); m != nil {
+			r.Seconds = parseSeconds(m[1])
+			if m[2] == "ms" {
+				r.Seconds /= 1000
+			}
+		}
+		for _, m := range jestFailure.FindAllStringSubmatch(output, -1) {
+			ms, _ := strconv.Atoi(m[2])
+			seconds[m[1]] = float64(ms) / 1000
+		}
+	case "pytest":
+		if m := pytestSum.FindAllStringSubmatch(output, -1); len(m) > 0 {
+			last := m[len(m)-1]
+			found = r.addCounts(last[1])
+			r.Seconds = parseSeconds(last[2])
+		}
+	case "cargo":
+		for _, m := range cargoSum.FindAllStringSubmatch(output, -1) {
+			found = r.addCounts(m[1]) || found
+			r.Seconds += parseSeconds(m[2])
+		}
+	}
+
+	for _, name := range ParseFailingTests(output) {
+		r.Failures = append(r.Failures, TestFailure{Name: name, Seconds: seconds[name]})
+	}
+	if r.Failed == 0 {
+		r.Failed = len(r.Failures)
+	}
+	if !found && len(r.Failures) == 0 {
+		return nil
+	}
+	return r
+}
+
+// addCounts adds a summary's counts to r, reporting whether it had any.
+func (r *TestReport) addCounts(summary string) bool {
+	matches := summaryCounts.FindAllStringSubmatch(summary, -1)
+	for _, m := range matches {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "passed":
+			r.Passed += n
+		case "failed", "error", "errors":
+			r.Failed += n
+		default:
+			r.Skipped += n
+		}
+	}
+	return len(matches) > 0
+}
+
+func parseSeconds(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// AttemptTests is the test report of one run attempt.
+type AttemptTests struct {
+	Attempt int         `json:"attempt"`
+	At      time.Time   `json:"at"`
+	Report  *TestReport `json:"report"`
+}
+
+// recordTestReport keeps the attempt's report with the agent, for
+// `agentctl attempts`.
+func recordTestReport(name string, attempt int, report *TestReport) {
+	updateAgent(name, func(agent *Agent) error {
+		agent.TestReports = append(agent.TestReports, AttemptTests{Attempt: attempt, At: time.Now(), Report: report})
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTestReport(t *testing.T) {
+	tests := []struct {
+		runner, output string
+		want           *TestReport
+	}{
+		{"go", `--- FAIL: TestLogin (0.25s)
+    auth_test.go:12: got 401
+FAIL
+FAIL	example.com/app/auth	0.300s
+ok  	example.com/app/db	1.200s
+EXIT_CODE:1`, &TestReport{Runner: "go", Passed: -1, Failed: 1, Seconds: 1.5,
+			Failures: []TestFailure{{Name: "TestLogin", Seconds: 0.25}}}},
+		{"go", `=== RUN   TestA
+--- PASS: TestA (0.00s)
+--- SKIP: TestB (0.00s)
+PASS
+ok  	example.com/app	0.010s`, &TestReport{Runner: "go", Passed: 1, Skipped: 1, Seconds: 0.01}},
+		{"pest", `   FAILED  Tests\Feature\LoginTest > it logs in
+  Tests:    1 failed, 1 skipped, 40 passed (85 assertions)
+  Duration: 2.31s`, &TestReport{Runner: "pest", Passed: 40, Failed: 1, Skipped: 1, Seconds: 2.31,
+			Failures: []TestFailure{{Name: `Tests\Feature\LoginTest > it logs in`}}}},
+		{"pest", `Time: 00:01.500, Memory: 10.00 MB
+
+Tests: 10, Assertions: 20, Errors: 1, Failures: 2, Skipped: 1.`,
+			&TestReport{Runner: "pest", Passed: 6, Failed: 3, Skipped: 1, Seconds: 1.5}},
+		{"jest", `  ✕ adds numbers (5 ms)
+Test Suites: 1 failed, 3 passed, 4 total
+Tests:       1 failed, 12 passed, 13 total
+Time:        3.2 s`, &TestReport{Runner: "jest", Passed: 12, Failed: 1, Seconds: 3.2,
+			Failures: []TestFailure{{Name: "adds numbers", Seconds: 0.005}}}},
+		{"pytest", `FAILED tests/test_api.py::test_get - assert 1 == 2
+==== 1 failed, 10 passed, 2 skipped in 1.23s ====`, &TestReport{Runner: "pytest", Passed: 10, Failed: 1, Skipped: 2, Seconds: 1.23,
+			Failures: []TestFailure{{Name: "tests/test_api.py::test_get"}}}},
+		{"cargo", `test parse ... FAILED
+test result: FAILED. 4 passed; 1 failed; 1 ignored; 0 measured; 0 filtered out; finished in 0.50s
+test result: ok. 2 passed; 0 failed; 0 ignored; 0 measured; 0 filtered out; finished in 0.25s`,
+			&TestReport{Runner: "cargo", Passed: 6, Failed: 1, Skipped: 1, Seconds: 0.75,
+				Failures: []TestFailure{{Name: "parse"}}}},
+		{"pytest", "ImportError: no module named app\nEXIT_CODE:2", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseTestReport(tt.runner, tt.output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTestReport(%s, %q)\n = %+v\nwant %+v", tt.runner, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestTestReportString(t *testing.T) {
+	r := &TestReport{Passed: 40, Failed: 2, Skipped: 1, Seconds: 3.14}
+	if s := r.String(); s != "2 failed, 40 passed, 1 skipped in 3.1s" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := (&TestReport{Passed: -1, Failed: 1}).String(); s != "1 failed" {
+		t.Errorf("String() = %q", s)
+	}
+}
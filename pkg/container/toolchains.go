@@ -0,0 +1,271 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Toolchain is a runtime a repo's manifests ask for.
+type Toolchain struct {
+	Name   string // go, node, php or composer
+	Min    string // the lowest version the manifest allows; "" for any
+	Source string // where the need comes from, e.g. "composer.json needs ^8.3"
+}
+
+// String names the toolchain with its minimum version: "php 8.3".
+func (t Toolchain) String() string {
+	if t.Min == "" {
+		return t.Name
+	}
+	return t.Name + " " + t.Min
+}
+
+// toolchainManifests are the files in the workspace toolchains are read from.
+var toolchainManifests = []string{"go.mod", "package.json", "composer.json"}
+
+// toolchainProbes print each toolchain's version inside the container. They
+// run in the workspace, so a Go that switches toolchains for go.mod reports
+// the one it will build with.
+var toolchainProbes = map[string]string{
+	"go":       "go env GOVERSION",
+	"node":     "node --version",
+	"php":      "php -r 'echo PHP_VERSION;'",
+	"composer": "composer --version",
+}
+
+var (
+	goDirective = regexp.MustCompile(`(?m)^go\s+(\S+)\s*$`)
+	versionNum  = regexp.MustCompile(`\d+(\.\d+)*`)
+)
+
+// requiredToolchains reads what the manifests (file name → contents) need.
+// A manifest that can't be parsed asks for nothing; the build will say why.
+func requiredToolchains(files map[string]string) []Toolchain {
+	var need []Toolchain
+	if mod, ok := files["go.mod"]; ok {
+		if m := goDirective.FindStringSubmatch(mod); m != nil {
+			need = append(need, Toolchain{"go", versionNum.FindString(m[1]), "go.mod needs go " + m[1]})
+		} else {
+			need = append(need, Toolchain{"go", "", "go.mod"})
+		}
+	}
+	if data, ok := files["package.json"]; ok {
+		var pkg struct {
+			Engines map[string]string `json:"engines"`
+		}
+		if json.Unmarshal([]byte(data), &pkg) == nil {
+			if c := pkg.Engines["node"]; c != "" {
+				need = append(need, Toolchain{"node", minVersion(c), "package.json needs node " + c})
+			} else {
+				need = append(need, Toolchain{"node", "", "package.json"})
+			}
+		}
+	}
+	if data, ok := files["composer.json"]; ok {
+		var composer struct {
+			Require map[string]string `json:"require"`
+		}
+		if json.Unmarshal([]byte(data), &composer) == nil {
+			if c := composer.Require["php"]; c != "" {
+				need = append(need, Toolchain{"php", minVersion(c), "composer.json needs php " + c})
+			} else {
+				need = append(need, Toolchain{"php", "", "composer.json"})
+			}
+			need = append(need, Toolchain{"composer", "", "composer.json"})
+		}
+	}
+	return need
+}
+
+// minVersion is the lowest version a constraint such as "^8.1 || ^8.3",
+// ">=18" or "~20.11" allows; "" when it sets no lower bound.
+func minVersion(constraint string) string {
+	lowest := ""
+	for _, part := range strings.Split(constraint, "||") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "<") || strings.HasPrefix(part, "!") {
+			continue
+		}
+		v := versionNum.FindString(part)
+		if v != "" && (lowest == "" || compareVersions(v, lowest) < 0) {
+			lowest = v
+		}
+	}
+	return lowest
+}
+
+// compareVersions compares dotted versions numerically, a missing
+// component counting as 0: "8.3" == "8.3.0" < "8.10".
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ToolchainCheck is a needed toolchain and what the container has of it.
+type ToolchainCheck struct {
+	Toolchain
+	Have string // the installed version; "" when it isn't installed
+	OK   bool
+}
+
+// checkToolchains reads the manifests in the agent's workspace and probes
+// the container for each toolchain they need.
+func checkToolchains(name string, layout Layout) ([]ToolchainCheck, error) {
+	script := layout.cd() + "for f in " + strings.Join(toolchainManifests, " ") +
+		`; do if [ -f "$f" ]; then echo "=== $f"; cat "$f"; echo; fi; done`
+	out, err := podman("exec", containerName(name), "sh", "-c", script).Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the repo's manifests: %w", err)
+	}
+	var checks []ToolchainCheck
+	for _, t := range requiredToolchains(splitManifests(string(out))) {
+		c := ToolchainCheck{Toolchain: t}
+		if out, err := podman("exec", containerName(name), "sh", "-c",
+			layout.cd()+toolchainProbes[t.Name]).Output(); err == nil {
+			c.Have = versionNum.FindString(string(out))
+		}
+		c.OK = c.Have != "" && (t.Min == "" || compareVersions(c.Have, t.Min) >= 0)
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// splitManifests splits the "=== name" sections checkToolchains prints.
+func splitManifests(out string) map[string]string {
+	files := map[string]string{}
+	current := ""
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if f, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "=== "); ok {
+			current = f
+			files[current] = ""
+			continue
+		}
+		if current != "" {
+			files[current] += line
+		}
+	}
+	return files
+}
+
+// verifyToolchains checks the spawning agent's image against its repo as
+// toolchains.check says: failing the spawn, warning, or not at all.
+func verifyToolchains(cfg *config.Config, opts SpawnOptions, image string, layout Layout) error {
+	mode := cfg.Toolchains.Check
+	if opts.SkipToolchainCheck || mode == "off" {
+		return nil
+	}
+	checks, err := checkToolchains(opts.Name, layout)
+	if err != nil {
+		fmt.Printf("⚠️  Toolchains not checked: %v\n", err)
+		return nil
+	}
+	if err := missingToolchains(cfg, image, checks); err != nil {
+		if mode == "warn" {
+			fmt.Printf("⚠️  %v\n", err)
+			return nil
+		}
+		return err
+	}
+	if len(checks) > 0 {
+		var have []string
+		for _, c := range checks {
+			have = append(have, c.Name+" "+c.Have)
+		}
+		fmt.Printf("🧰 Toolchains: %s\n", strings.Join(have, ", "))
+	}
+	return nil
+}
+
+// missingToolchains is the error for checks that failed: what the image
+// lacks and why it's needed, then the profiles that provide it.
+func missingToolchains(cfg *config.Config, image string, checks []ToolchainCheck) error {
+	var lacks []string
+	var missing []Toolchain
+	for _, c := range checks {
+		if c.OK {
+			continue
+		}
+		has := "it isn't installed"
+		if c.Have != "" {
+			has = "it has " + c.Have
+		}
+		lacks = append(lacks, fmt.Sprintf("%s (%s; %s)", c.Toolchain, c.Source, has))
+		missing = append(missing, c.Toolchain)
+	}
+	if len(lacks) == 0 {
+		return nil
+	}
+	hint := "use an image that has it, or spawn with --no-toolchain-check"
+	if profiles := profilesProviding(cfg.Profiles, missing); len(profiles) > 0 {
+		hint = "try --profile " + strings.Join(profiles, " or --profile ")
+	}
+	return fmt.Errorf("%w: image %s lacks %s — %s", ErrToolchainMissing, image, strings.Join(lacks, ", "), hint)
+}
+
+// profilesProviding lists, sorted, the profiles whose declared toolchains
+// cover every one of need.
+func profilesProviding(profiles map[string]config.Profile, need []Toolchain) []string {
+	var names []string
+	for name, p := range profiles {
+		ok := true
+		for _, t := range need {
+			v, has := p.Toolchains[t.Name]
+			if !has || (t.Min != "" && compareVersions(versionNum.FindString(v), t.Min) < 0) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// warnStaleImage warns when image was built longer ago than
+// toolchains.max_image_age, its runtimes likely behind what repos expect.
+func warnStaleImage(tc config.Toolchains, image string) {
+	if tc.MaxImageAge == "" {
+		return
+	}
+	maxAge, err := ParseAge(tc.MaxImageAge)
+	if err != nil {
+		fmt.Printf("⚠️  toolchains.max_image_age: %v\n", err)
+		return
+	}
+	out, err := podman("image", "inspect", "--format", "{{.Created.Unix}}", image).Output()
+	if err != nil {
+		return
+	}
+	created, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return
+	}
+	if age := time.Since(time.Unix(created, 0)); age > maxAge {
+		fmt.Printf("⚠️  Image %s was built %d days ago (toolchains.max_image_age is %s); rebuild it to pick up current toolchains\n",
+			image, int(age.Hours()/24), tc.MaxImageAge)
+	}
+}
@@ -0,0 +1,110 @@
+package container
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestMinVersion(t *testing.T) {
+	for constraint, want := range map[string]string{
+		"^8.3":             "8.3",
+		"^8.1 || ^8.3":     "8.1",
+		">=18.0.0 <21":     "18.0.0",
+		"~20.11":           "20.11",
+		"<7 || >=8.2":      "8.2",
+		"*":                "",
+		">= 7.4 || 8.0.*":  "7.4",
+		"20.x":             "20",
+		"^1.22rc1 || ^1.3": "1.3",
+	} {
+		if got := minVersion(constraint); got != want {
+			t.Errorf("minVersion(%q) = %q, want %q", constraint, got, want)
+		}
+	}
+
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"8.3", "8.3.0", 0},
+		{"8.10", "8.3", 1},
+		{"1.21.9", "1.22", -1},
+		{"20", "18.17.1", 1},
+	} {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestRequiredToolchains(t *testing.T) {
+	need := requiredToolchains(map[string]string{
+		"go.mod":        "module example.com/x\n\ngo 1.22\n\ntoolchain go1.22.3\n",
+		"package.json":  `{"name": "x", "engines": {"node": ">=20"}}`,
+		"composer.json": `{"require": {"php": "^8.3", "laravel/framework": "^11.0"}}`,
+	})
+	var got []string
+	for _, t := range need {
+		got = append(got, t.String())
+	}
+	if strings.Join(got, ", ") != "go 1.22, node 20, php 8.3, composer" {
+		t.Errorf("requiredToolchains = %s", strings.Join(got, ", "))
+	}
+
+	need = requiredToolchains(map[string]string{"package.json": `{"name": "x"}`})
+	if len(need) != 1 || need[0].Name != "node" || need[0].Min != "" {
+		t.Errorf("package.json without engines = %+v, want any node", need)
+	}
+	if need := requiredToolchains(map[string]string{"package.json": "not json"}); len(need) != 0 {
+		t.Errorf("an unparsable manifest = %+v, want nothing", need)
+	}
+}
+
+func TestCheckToolchains(t *testing.T) {
+	fakePodman(t, `case "$5" in
+*"for f in"*) printf '=== go.mod\nmodule x\n\ngo 1.22\n\n=== composer.json\n{"require": {"php": "^8.3"}}\n\n' ;;
+*"go env"*) echo go1.22.3 ;;
+*PHP_VERSION*) printf 8.1.2 ;;
+*) echo "sh: composer: not found" >&2; exit 127 ;;
+esac`)
+
+	checks, err := checkToolchains("api", Layout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range checks {
+		got = append(got, c.Name+"="+c.Have)
+		if c.OK != (c.Name == "go") {
+			t.Errorf("%s OK = %v", c.Name, c.OK)
+		}
+	}
+	if strings.Join(got, " ") != "go=1.22.3 php=8.1.2 composer=" {
+		t.Errorf("checks = %s", strings.Join(got, " "))
+	}
+
+	cfg := &config.Config{Profiles: map[string]config.Profile{
+		"php83":  {Toolchains: map[string]string{"php": "8.3", "composer": "2"}},
+		"php82":  {Toolchains: map[string]string{"php": "8.2", "composer": "2"}},
+		"nocomp": {Toolchains: map[string]string{"php": "8.4"}},
+	}}
+	err = missingToolchains(cfg, "agent-devbox:latest", checks)
+	if !errors.Is(err, ErrToolchainMissing) {
+		t.Fatalf("missingToolchains = %v, want ErrToolchainMissing", err)
+	}
+	for _, want := range []string{
+		"lacks php 8.3 (composer.json needs php ^8.3; it has 8.1.2)",
+		"composer (composer.json; it isn't installed)",
+		"try --profile php83",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "php82") || strings.Contains(err.Error(), "nocomp") {
+		t.Errorf("error suggests a profile that won't do: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package container
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// fileToolInput covers the path argument of the file tools (and the search
+// root of Glob and Grep).
+type fileToolInput struct {
+	FilePath     string `json:"file_path"`
+	NotebookPath string `json:"notebook_path"`
+	Path         string `json:"path"`
+}
+
+// touchedFiles extracts the repo files read and written by the tool calls in
+// transcript lines. Paths outside the workspace are left out; a file that
+// was written is not also listed as read.
+func touchedFiles(lines []string, workspace string) (read, written []string) {
+	readSet, writeSet := map[string]bool{}, map[string]bool{}
+	for _, line := range lines {
+		var msg jsonlMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Message == nil {
+			continue
+		}
+		for _, block := range msg.Message.Content {
+			if block.Type != "tool_use" {
+				continue
+			}
+			var in fileToolInput
+			json.Unmarshal(block.Input, &in)
+			file := in.FilePath
+			if file == "" {
+				file = in.NotebookPath
+			}
+			rel, ok := repoRelative(file, workspace)
+			if !ok {
+				continue
+			}
+			switch {
+			case writeTools[block.Name]:
+				writeSet[rel] = true
+			case block.Name == "Read":
+				readSet[rel] = true
+			}
+		}
+	}
+	for f := range writeSet {
+		written = append(written, f)
+		delete(readSet, f)
+	}
+	for f := range readSet {
+		read = append(read, f)
+	}
+	sort.Strings(read)
+	sort.Strings(written)
+	return read, written
+}
+
+// repoRelative maps a path the agent used to one relative to the repo root.
+func repoRelative(file, workspace string) (string, bool) {
+	if file == "" {
+		return "", false
+	}
+	if !path.IsAbs(file) {
+		file = path.Join(workspace, file)
+	}
+	file = path.Clean(file)
+	rel, ok := strings.CutPrefix(file, path.Clean(workspace)+"/")
+	return rel, ok
+}
+
+// publishFilesTouched reports what an attempt read and wrote, from the
+// transcript lines the scanner has seen since the attempt started.
+func publishFilesTouched(repoURL, name string, attempt int, scanner *sessionScanner) {
+	lines, err := scanner.poll()
+	if err != nil {
+		return
+	}
+	read, written := touchedFiles(lines, layoutOf(name).Workspace)
+	if len(read) == 0 && len(written) == 0 {
+		return
+	}
+	coordination.PublishFilesTouched(repoURL, coordination.FilesTouched{
+		Agent: name, Attempt: attempt, Read: read, Written: written,
+	})
+}
@@ -0,0 +1,34 @@
+package container
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTouchedFiles(t *testing.T) {
+	line := func(tool string, in map[string]string) string {
+		input, _ := json.Marshal(in)
+		data, _ := json.Marshal(jsonlMessage{Message: &messageBody{
+			Role:    "assistant",
+			Content: []contentBlock{{Type: "tool_use", Name: tool, Input: input}},
+		}})
+		return string(data)
+	}
+	lines := []string{
+		line("Read", map[string]string{"file_path": DefaultWorkspace + "/go.mod"}),
+		line("Read", map[string]string{"file_path": "pkg/auth/token.go"}),
+		line("Edit", map[string]string{"file_path": DefaultWorkspace + "/pkg/auth/token.go"}),
+		line("NotebookEdit", map[string]string{"notebook_path": DefaultWorkspace + "/notes/eda.ipynb"}),
+		line("Read", map[string]string{"file_path": "/home/agent/.bashrc"}),
+		line("Bash", map[string]string{"command": "go test ./..."}),
+		`{"type":"partial`,
+	}
+	read, written := touchedFiles(lines, DefaultWorkspace)
+	if !reflect.DeepEqual(read, []string{"go.mod"}) {
+		t.Errorf("read = %v", read)
+	}
+	if !reflect.DeepEqual(written, []string{"notes/eda.ipynb", "pkg/auth/token.go"}) {
+		t.Errorf("written = %v", written)
+	}
+}
@@ -0,0 +1,77 @@
+package container
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/jordanpartridge/agentctl/pkg/telemetry"
+)
+
+// agentSpans holds, per container name, the span podman calls on that
+// container are traced under: the spawn, or the run attempt or check in
+// progress. Calls on containers without one aren't traced.
+var agentSpans sync.Map
+
+// traceAgent makes span the parent of podman calls on the agent's container
+// until the returned func restores the previous parent.
+func traceAgent(name string, span *telemetry.Span) func() {
+	if span == nil {
+		return func() {}
+	}
+	ctr := containerName(name)
+	prev, had := agentSpans.Load(ctr)
+	agentSpans.Store(ctr, span)
+	return func() {
+		if had {
+			agentSpans.Store(ctr, prev)
+		} else {
+			agentSpans.Delete(ctr)
+		}
+	}
+}
+
+// podmanSpan starts a span for a podman call on a traced container.
+func podmanSpan(args []string) *telemetry.Span {
+	for i, arg := range args {
+		if parent, ok := agentSpans.Load(arg); ok {
+			verb := args[0]
+			if verb == "exec" {
+				verb += " " + execCommand(args[i+1:])
+			}
+			return telemetry.Start(parent.(*telemetry.Span), "podman "+args[0],
+				"podman.command", verb, "container.name", arg)
+		}
+	}
+	return nil
+}
+
+// endPodmanSpan records how a traced podman call ended.
+func endPodmanSpan(span *telemetry.Span, err error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		span.Set("process.exit_code", exitErr.ExitCode())
+	} else if err == nil {
+		span.Set("process.exit_code", 0)
+	}
+	span.End(err)
+}
+
+// execCommand names what a podman exec runs, without its (possibly long)
+// arguments: the first word of a shell script, or the program.
+func execCommand(argv []string) string {
+	if len(argv) == 0 {
+		return ""
+	}
+	if argv[0] == "sh" && len(argv) > 2 && argv[1] == "-c" {
+		script := argv[2]
+		if _, rest, ok := strings.Cut(script, "&& "); ok && strings.HasPrefix(script, "cd ") {
+			script = rest
+		}
+		if fields := strings.Fields(script); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return argv[0]
+}
@@ -0,0 +1,20 @@
+package container
+
+import "testing"
+
+func TestExecCommand(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want string
+	}{
+		{[]string{"sh", "-c", "cd '/workspace' && go test ./... 2>&1; echo EXIT_CODE:$?"}, "go"},
+		{[]string{"sh", "-c", "git status --porcelain"}, "git"},
+		{[]string{"pkill", "-f", "run-task"}, "pkill"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := execCommand(tt.argv); got != tt.want {
+			t.Errorf("execCommand(%q) = %q, want %q", tt.argv, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// transcriptTailLines is how much of the session transcript LatestActivity
+// reads looking for the last message and tool call.
+const transcriptTailLines = 400
+
+// SessionActivity is where an agent's session transcript has got to: what
+// Claude last said and the last tool it used. Times are zero when the
+// transcript doesn't have them.
+type SessionActivity struct {
+	Session  string
+	Message  string // the last assistant text, on one line
+	Said     time.Time
+	Action   string // the last tool call, as spy shows it: "Bash: go test ./..."
+	ActionAt time.Time
+}
+
+// LatestActivity reads the end of the agent's current session transcript.
+func LatestActivity(name string) (*SessionActivity, error) {
+	path, err := discoverSessionFile(name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := podmanRetry("exec", containerName(name), "tail", "-n", fmt.Sprint(transcriptTailLines), path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read session: %w", err)
+	}
+	a := latestActivity(strings.Split(string(out), "\n"))
+	a.Session = path
+	return a, nil
+}
+
+// latestActivity scans transcript lines from the end for the last assistant
+// text and tool call, summarised the way spy renders them.
+func latestActivity(lines []string) *SessionActivity {
+	a := &SessionActivity{}
+	for i := len(lines) - 1; i >= 0 && (a.Message == "" || a.Action == ""); i-- {
+		var msg jsonlMessage
+		if json.Unmarshal([]byte(lines[i]), &msg) != nil || msg.Message == nil || msg.Message.Role != "assistant" {
+			continue
+		}
+		at, _ := time.Parse(time.RFC3339, msg.Timestamp)
+		content := msg.Message.Content
+		for j := len(content) - 1; j >= 0; j-- {
+			block := content[j]
+			switch {
+			case block.Type == "text" && a.Message == "" && strings.TrimSpace(block.Text) != "":
+				a.Message, a.Said = truncate(block.Text, defaultTextWidth), at
+			case block.Type == "tool_use" && a.Action == "":
+				var ti toolInput
+				json.Unmarshal(block.Input, &ti)
+				a.Action, a.ActionAt = block.Name+": "+toolSummary(block.Name, ti, SpyOptions{}), at
+			}
+		}
+	}
+	return a
+}
+
+// agoSuffix renders " (5m ago)" for a known time, or nothing.
+func agoSuffix(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(" (%s ago)", time.Since(t).Round(time.Second))
+}
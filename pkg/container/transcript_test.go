@@ -0,0 +1,27 @@
+package container
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatestActivity(t *testing.T) {
+	lines := strings.Split(`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"Fix the parser"}]},"timestamp":"2026-01-02T10:00:00Z"}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Looking at the parser.\nIt drops empty input."},{"type":"tool_use","name":"Read","input":{"file_path":"parse.go"}}]},"timestamp":"2026-01-02T10:00:05Z"}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]},"timestamp":"2026-01-02T10:01:00Z"}
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","text":"ok"}]}}
+not json`, "\n")
+
+	a := latestActivity(lines)
+	if a.Message != "Looking at the parser. It drops empty input." || !a.Said.Equal(time.Date(2026, 1, 2, 10, 0, 5, 0, time.UTC)) {
+		t.Errorf("message = %q at %v", a.Message, a.Said)
+	}
+	if a.Action != "Bash: go test ./..." || !a.ActionAt.Equal(time.Date(2026, 1, 2, 10, 1, 0, 0, time.UTC)) {
+		t.Errorf("action = %q at %v", a.Action, a.ActionAt)
+	}
+
+	if a := latestActivity(nil); a.Message != "" || a.Action != "" {
+		t.Errorf("empty transcript: %+v", a)
+	}
+}
@@ -0,0 +1,201 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/forge"
+	"github.com/jordanpartridge/agentctl/pkg/process"
+)
+
+// Triage defaults; config.Triage overrides them.
+const (
+	DefaultTriageLabel   = "agent-ok"
+	DefaultMaxDifficulty = 2
+	// triageBodyBytes bounds how much of an issue goes into the estimate.
+	triageBodyBytes = 8000
+)
+
+// TriageOptions controls Triage. Zero values take the config's defaults.
+type TriageOptions struct {
+	Label         string
+	MaxDifficulty int
+	// Limit caps how many issues are picked (0: no cap).
+	Limit int
+	// Prefix names the agents: prefix plus the issue number (default "issue-").
+	Prefix string
+}
+
+// Estimate is the model's guess at what an issue takes.
+type Estimate struct {
+	Difficulty int      `json:"difficulty"` // 1 (trivial) to 5
+	Files      []string `json:"files"`
+	Reason     string   `json:"reason"`
+}
+
+// TriageCandidate is one open issue and what triage made of it.
+type TriageCandidate struct {
+	forge.Issue
+	Estimate *Estimate `json:"estimate,omitempty"`
+	// Agent is the agent to spawn for it, set when the issue was picked.
+	Agent string `json:"agent,omitempty"`
+	// Skip says why it wasn't picked.
+	Skip string `json:"skip,omitempty"`
+}
+
+// estimateIssue is swapped out in tests.
+var estimateIssue = runEstimate
+
+// Triage lists repo's open issues carrying the label, has the model estimate
+// each one's difficulty and scope, and picks those at or under the maximum
+// difficulty that no agent has taken yet, oldest first.
+func Triage(repo string, opts TriageOptions) ([]TriageCandidate, error) {
+	cfg, _ := config.Load()
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if opts.Label == "" {
+		opts.Label = cfg.Triage.Label
+	}
+	if opts.Label == "" {
+		opts.Label = DefaultTriageLabel
+	}
+	if opts.MaxDifficulty == 0 {
+		opts.MaxDifficulty = cfg.Triage.MaxDifficulty
+	}
+	if opts.MaxDifficulty == 0 {
+		opts.MaxDifficulty = DefaultMaxDifficulty
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "issue-"
+	}
+	command := cfg.Triage.Command
+	if command == "" {
+		command = DefaultSummaryCommand
+	}
+
+	issues, err := forge.For(repo).Issues(repo, opts.Label)
+	if err != nil {
+		return nil, err
+	}
+	taken := takenIssues(repo)
+	picked := 0
+	var out []TriageCandidate
+	for _, is := range issues {
+		c := TriageCandidate{Issue: is}
+		out = append(out, c)
+		cand := &out[len(out)-1]
+		if by, ok := taken[is.Number]; ok {
+			cand.Skip = "taken by " + by
+			continue
+		}
+		if opts.Limit > 0 && picked >= opts.Limit {
+			cand.Skip = "over the limit"
+			continue
+		}
+		name := opts.Prefix + strconv.Itoa(is.Number)
+		if _, err := loadAgent(name); err == nil {
+			cand.Skip = "an agent named " + name + " exists"
+			continue
+		}
+		est, err := estimateIssue(command, triagePrompt(repo, is))
+		if err != nil {
+			cand.Skip = "no estimate: " + err.Error()
+			continue
+		}
+		cand.Estimate = est
+		if est.Difficulty > opts.MaxDifficulty {
+			cand.Skip = fmt.Sprintf("difficulty %d > %d", est.Difficulty, opts.MaxDifficulty)
+			continue
+		}
+		cand.Agent = name
+		picked++
+	}
+	return out, nil
+}
+
+// takenIssues maps the issues of repo that agents have been given, live or
+// in history, to the agent.
+func takenIssues(repo string) map[int]string {
+	taken := map[int]string{}
+	for _, h := range historyOnRepo(repo) {
+		if n, err := strconv.Atoi(h.Metadata["issue"]); err == nil {
+			taken[n] = fmt.Sprintf("%s (%s)", h.Name, h.Result)
+		}
+	}
+	for _, a := range loadAgents() {
+		if a.Issue != 0 && a.OnRepo(repo) {
+			taken[a.Issue] = a.Name
+		}
+	}
+	return taken
+}
+
+func triagePrompt(repo string, is forge.Issue) string {
+	body := strings.TrimSpace(is.Body)
+	if len(body) > triageBodyBytes {
+		body = body[:triageBodyBytes] + "\n[truncated]"
+	}
+	return "You are triaging an issue of " + repo + " for an autonomous coding agent.\n" +
+		"Estimate how hard it is for the agent to resolve alone, from 1 (trivial, like a typo) to 5 " +
+		"(large, cross-cutting or underspecified), and which files it will likely change.\n" +
+		`Reply with JSON only: {"difficulty": N, "files": ["path", ...], "reason": "one sentence"}` + "\n" +
+		fmt.Sprintf("\n## Issue #%d: %s\n%s\n", is.Number, is.Title, body)
+}
+
+// runEstimate runs command on the host with prompt on stdin.
+func runEstimate(command, prompt string) (*Estimate, error) {
+	cmd := process.Shell(command)
+	cmd.Stdin = strings.NewReader(prompt)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", command, err)
+	}
+	return parseEstimate(string(out))
+}
+
+// parseEstimate reads the JSON object in the model's reply, which may come
+// wrapped in prose or a code fence.
+func parseEstimate(reply string) (*Estimate, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON in reply")
+	}
+	var est Estimate
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &est); err != nil {
+		return nil, fmt.Errorf("invalid estimate: %w", err)
+	}
+	if est.Difficulty < 1 || est.Difficulty > 5 {
+		return nil, fmt.Errorf("difficulty %d out of range", est.Difficulty)
+	}
+	return &est, nil
+}
+
+// TriageSpecs turns the picked candidates into agents to spawn and run.
+func TriageSpecs(repo string, candidates []TriageCandidate, attempts int) []BulkSpec {
+	var specs []BulkSpec
+	for _, c := range candidates {
+		if c.Agent == "" {
+			continue
+		}
+		specs = append(specs, BulkSpec{
+			Name:        c.Agent,
+			Repo:        repo,
+			Intent:      fmt.Sprintf("issue #%d %s", c.Number, c.Title),
+			Issue:       c.Number,
+			Template:    "triage",
+			Task:        IssueTask(repo, c.Issue),
+			MaxAttempts: attempts,
+		})
+	}
+	return specs
+}
+
+// IssueTask is the task an agent picked by triage runs.
+func IssueTask(repo string, is forge.Issue) string {
+	task := fmt.Sprintf("Resolve issue #%d of %s: %s\n\n%s\n", is.Number, repo, is.Title, strings.TrimSpace(is.Body))
+	return task + fmt.Sprintf("\nKeep the change focused on the issue, with tests. End your last commit message with \"Closes #%d\".", is.Number)
+}
@@ -0,0 +1,79 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEstimate(t *testing.T) {
+	est, err := parseEstimate("Sure:\n```json\n{\"difficulty\": 2, \"files\": [\"README.md\"], \"reason\": \"a typo\"}\n```")
+	if err != nil || est.Difficulty != 2 || est.Files[0] != "README.md" {
+		t.Errorf("parseEstimate = %+v, %v", est, err)
+	}
+	for _, bad := range []string{"no idea", `{"difficulty": 9}`, `{"difficulty": "easy"}`} {
+		if _, err := parseEstimate(bad); err == nil {
+			t.Errorf("parseEstimate(%q) should fail", bad)
+		}
+	}
+}
+
+func TestTriage(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	bin := t.TempDir()
+	gh := `#!/bin/sh
+echo '[{"number": 40, "title": "Rewrite the scheduler"}, {"number": 31, "title": "Fix typo", "body": "teh"}, {"number": 30, "title": "Old one"}, {"number": 12, "title": "Rename flag"}]'
+`
+	os.WriteFile(filepath.Join(bin, "gh"), []byte(gh), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := "https://github.com/acme/api"
+	SaveHistory(&AgentHistory{Name: "issue-30", Repo: repo, Result: "failed", Metadata: map[string]string{"issue": "30"}})
+	saveAgent(&Agent{Name: "rename", Repo: repo, Issue: 12})
+
+	orig := estimateIssue
+	defer func() { estimateIssue = orig }()
+	var prompts []string
+	estimateIssue = func(command, prompt string) (*Estimate, error) {
+		prompts = append(prompts, prompt)
+		if strings.Contains(prompt, "scheduler") {
+			return &Estimate{Difficulty: 4, Reason: "large"}, nil
+		}
+		return &Estimate{Difficulty: 1, Files: []string{"README.md"}, Reason: "typo"}, nil
+	}
+
+	candidates, err := Triage(repo, TriageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, fmt.Sprintf("%d:%s%s", c.Number, c.Agent, c.Skip))
+	}
+	want := "12:taken by rename,30:taken by issue-30 (failed),31:issue-31,40:difficulty 4 > 2"
+	if strings.Join(got, ",") != want {
+		t.Errorf("triage = %s\nwant %s", strings.Join(got, ","), want)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[0], "## Issue #31: Fix typo\nteh") {
+		t.Errorf("prompts = %q", prompts)
+	}
+
+	specs := TriageSpecs(repo, candidates, 3)
+	if len(specs) != 1 || specs[0].Name != "issue-31" || specs[0].Issue != 31 || specs[0].MaxAttempts != 3 ||
+		!strings.Contains(specs[0].Task, `"Closes #31"`) || specs[0].SpawnOptions().Issue != 31 {
+		t.Errorf("specs = %+v", specs)
+	}
+
+	// A limit stops picking before more estimates are asked for.
+	prompts = nil
+	candidates, _ = Triage(repo, TriageOptions{MaxDifficulty: 5, Limit: 1})
+	if candidates[3].Skip != "over the limit" || len(prompts) != 1 {
+		t.Errorf("limited triage = %+v after %d estimates", candidates, len(prompts))
+	}
+}
@@ -0,0 +1,144 @@
+package container
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// Wait outcomes.
+const (
+	WaitSucceeded = "success"
+	WaitFailed    = "failed"
+	WaitTimedOut  = "timeout"
+)
+
+// DefaultWaitInterval is how often Wait polls the agent.
+const DefaultWaitInterval = 10 * time.Second
+
+// waitIdlePolls is how many consecutive polls an agent may sit idle with
+// pending work before Wait gives up on it. This rides out the pause between
+// RunUntilDone attempts, when no task runner is active.
+const waitIdlePolls = 3
+
+// WaitOptions controls Wait.
+type WaitOptions struct {
+	// Timeout bounds the wait; zero waits indefinitely.
+	Timeout time.Duration
+	// Interval is the poll interval (default DefaultWaitInterval).
+	Interval time.Duration
+	// Heartbeat, if set, is called after every poll that didn't finish.
+	Heartbeat func(elapsed time.Duration, state AgentLifecycleState)
+}
+
+// WaitResult is the outcome of Wait.
+type WaitResult struct {
+	Result    string // WaitSucceeded, WaitFailed or WaitTimedOut
+	Lifecycle AgentLifecycleState
+	Reason    string
+	Elapsed   time.Duration
+}
+
+// Wait blocks until the agent's task has completed or failed, or the timeout
+// passes. Success means the run loop reported done, or the agent went idle
+// with passing tests and nothing uncommitted. Failure means the run loop gave
+// up, the container exited, or the agent stayed idle with pending work.
+func Wait(name string, opts WaitOptions) (*WaitResult, error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+	start := time.Now()
+	idle := 0
+
+	for {
+		res, err := waitCheck(name, &idle)
+		if err != nil {
+			return nil, err
+		}
+		res.Elapsed = time.Since(start)
+		if res.Result != "" {
+			return res, nil
+		}
+		if opts.Timeout > 0 && res.Elapsed >= opts.Timeout {
+			res.Result = WaitTimedOut
+			res.Reason = fmt.Sprintf("still %s after %s", res.Lifecycle, opts.Timeout)
+			return res, nil
+		}
+		if opts.Heartbeat != nil {
+			opts.Heartbeat(res.Elapsed, res.Lifecycle)
+		}
+		sleep := interval
+		if opts.Timeout > 0 && opts.Timeout-res.Elapsed < sleep {
+			sleep = opts.Timeout - res.Elapsed
+		}
+		time.Sleep(sleep)
+	}
+}
+
+// waitCheck polls the agent once. An empty Result means keep waiting.
+func waitCheck(name string, idle *int) (*WaitResult, error) {
+	agent, err := loadAgent(name)
+	if err != nil {
+		// The agent may already have been cleaned up; its history has the answer.
+		h, herr := LoadHistory(name)
+		if herr != nil {
+			return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, name)
+		}
+		res := &WaitResult{Lifecycle: StateStopped, Result: WaitFailed, Reason: "agent removed (result: " + h.Result + ")"}
+		if h.Result == "success" {
+			res.Result, res.Reason = WaitSucceeded, "agent completed and was removed"
+		}
+		return res, nil
+	}
+
+	aws, err := agentState(agent)
+	if err != nil {
+		return nil, err
+	}
+	res := &WaitResult{Lifecycle: aws.Lifecycle}
+
+	// The run loop's coordination state is authoritative when present.
+	if agent.Repo != "" {
+		if st, err := coordination.GetState(agent.Repo); err == nil {
+			if s := st.Agents[name]; s != nil {
+				switch s.Status {
+				case "done":
+					res.Result, res.Reason = WaitSucceeded, "run completed"
+					return res, nil
+				case "blocked":
+					res.Result, res.Reason = WaitFailed, "run gave up after max attempts"
+					return res, nil
+				}
+				if s.Stale(time.Now(), coordination.HeartbeatTimeout()) {
+					res.Result = WaitFailed
+					res.Reason = "run loop stopped heartbeating (last seen " + s.LastSeen().Format(time.RFC3339) + ")"
+					return res, nil
+				}
+			}
+		}
+	}
+
+	switch aws.Lifecycle {
+	case StateExited, StateStopped:
+		res.Result, res.Reason = WaitFailed, "container "+string(aws.Lifecycle)
+	case StateCompleted:
+		status := getStatus(name)
+		if status.Done() {
+			res.Result, res.Reason = WaitSucceeded, "tests pass and all changes committed"
+			return res, nil
+		}
+		*idle++
+		if *idle >= waitIdlePolls {
+			res.Result = WaitFailed
+			res.Reason = fmt.Sprintf("agent idle with pending work (tests=%s uncommitted=%v)", status.TestStatus, status.HasUncommitted)
+			if status.Coverage != nil && status.Coverage.Low {
+				res.Reason = "agent idle with " + status.Coverage.Reason
+			}
+		}
+	default:
+		*idle = 0
+	}
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWaitRemovedAgentUsesHistory(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	SaveHistory(&AgentHistory{Name: "done-agent", Result: "success"})
+	SaveHistory(&AgentHistory{Name: "dead-agent", Result: "stale"})
+
+	res, err := Wait("done-agent", WaitOptions{})
+	if err != nil {
+		t.Fatalf("Wait() error: %v", err)
+	}
+	if res.Result != WaitSucceeded {
+		t.Errorf("Result = %q, want %q", res.Result, WaitSucceeded)
+	}
+
+	res, err = Wait("dead-agent", WaitOptions{})
+	if err != nil {
+		t.Fatalf("Wait() error: %v", err)
+	}
+	if res.Result != WaitFailed {
+		t.Errorf("Result = %q, want %q", res.Result, WaitFailed)
+	}
+
+	if _, err := Wait("never-existed", WaitOptions{}); err == nil {
+		t.Error("Wait() on unknown agent should fail")
+	}
+}
+
+func TestWaitExitedContainerFails(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	fakePodman(t, `echo exited`)
+
+	saveAgent(&Agent{Name: "crashed"})
+	res, err := Wait("crashed", WaitOptions{})
+	if err != nil {
+		t.Fatalf("Wait() error: %v", err)
+	}
+	if res.Result != WaitFailed || res.Lifecycle != StateExited {
+		t.Errorf("got %+v, want failed/exited", res)
+	}
+}
+
+func TestWaitTimeoutWithHeartbeats(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	// Container running with the task runner active.
+	fakePodman(t, `case "$1" in inspect) echo running ;; *) echo "agent 1 claude" ;; esac`)
+
+	saveAgent(&Agent{Name: "busy"})
+	beats := 0
+	res, err := Wait("busy", WaitOptions{
+		Timeout:   150 * time.Millisecond,
+		Interval:  20 * time.Millisecond,
+		Heartbeat: func(time.Duration, AgentLifecycleState) { beats++ },
+	})
+	if err != nil {
+		t.Fatalf("Wait() error: %v", err)
+	}
+	if res.Result != WaitTimedOut || res.Lifecycle != StateActive {
+		t.Errorf("got %+v, want timeout/active", res)
+	}
+	if beats == 0 {
+		t.Error("expected heartbeats while waiting")
+	}
+}
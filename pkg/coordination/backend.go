@@ -0,0 +1,247 @@
+package coordination
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Backend stores one repo's coordination state: claims, messages and agent
+// states. The package-level functions (ClaimFile, Publish, ...) go through
+// the backend selected by coordination.backend in the config.
+type Backend interface {
+	// Claim gives c.File to c.Agent unless another agent holds it, and
+	// returns the holder afterwards. A new claim is announced on the bus.
+	Claim(c Claim) (*Claim, error)
+	// Release drops agent's claim on file, announcing it on the bus. If
+	// another agent holds the file nothing changes and its name is returned.
+	Release(agent, file string) (holder string, err error)
+	// ReleaseAll drops every claim agent holds.
+	ReleaseAll(agent string) error
+	Claims() (Claims, error)
+
+	Publish(msg Message) error
+	Messages() ([]Message, error)
+
+	UpdateAgent(s *AgentState) error
+	RemoveAgent(name string) error
+	// Beat records a heartbeat for name without touching its state entry.
+	Beat(name string, at time.Time) error
+	// State returns the agent states with their heartbeats filled in.
+	State() (*State, error)
+}
+
+// subscriber is a Backend that pushes new messages as they are published,
+// rather than having to be polled.
+type subscriber interface {
+	Subscribe(stop <-chan struct{}, fn func(Message)) error
+}
+
+// RedisURLEnv overrides the config's Redis URL; spawn sets it for agents so
+// agentctl inside the container reaches the same bus.
+const RedisURLEnv = "AGENTCTL_REDIS_URL"
+
+// backendFor returns the configured backend for a repo.
+func backendFor(repoURL string) (Backend, error) {
+	if url := os.Getenv(RedisURLEnv); url != "" {
+		return newRedisBackend(url, repoURL), nil
+	}
+	if url := os.Getenv(BusURLEnv); url != "" {
+		return newHTTPBackend(url, os.Getenv(BusTokenEnv), repoURL), nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	switch c := cfg.Coordination; c.Backend {
+	case "", "file", "git":
+		return fileBackend{repoURL}, nil
+	case "redis":
+		if c.URL == "" {
+			return nil, fmt.Errorf("coordination backend redis needs coordination.url (redis://host:6379/0)")
+		}
+		return newRedisBackend(c.URL, repoURL), nil
+	case "http":
+		if c.URL == "" {
+			return nil, fmt.Errorf("coordination backend http needs coordination.url (http://host:8088, where `agentctl bus serve` runs)")
+		}
+		return newHTTPBackend(c.URL, c.Token, repoURL), nil
+	default:
+		return nil, fmt.Errorf("unknown coordination backend %q (want file, git, redis or http)", c.Backend)
+	}
+}
+
+// ErrNoSubscribe is returned by Subscribe for backends that can only be
+// polled (file and git).
+var ErrNoSubscribe = errors.New("coordination backend does not push messages; poll it")
+
+// Subscribe calls fn for each message published to a repo's bus from now
+// on, as it is published, until stop is closed.
+func Subscribe(repoURL string, stop <-chan struct{}, fn func(Message)) error {
+	b, err := backendFor(repoURL)
+	if err != nil {
+		return err
+	}
+	sub, ok := b.(subscriber)
+	if !ok {
+		return ErrNoSubscribe
+	}
+	return sub.Subscribe(stop, fn)
+}
+
+// fileBackend keeps the state in the coordination directory, replicated
+// through git when the git backend is configured.
+type fileBackend struct {
+	repoURL string
+}
+
+func (f fileBackend) Claim(c Claim) (*Claim, error) {
+	var holder *Claim
+	err := transact(f.repoURL, "claim "+c.File+" for "+c.Agent, func(dir string) error {
+		claims, err := loadClaims(dir)
+		if err != nil {
+			return err
+		}
+		if existing, ok := claims[c.File]; ok {
+			holder = existing
+			return nil
+		}
+		claims[c.File] = &c
+		holder = &c
+		if err := saveClaims(dir, claims); err != nil {
+			return err
+		}
+		return appendMessage(dir, claimMessage(MsgClaim, c.Agent, c.File))
+	})
+	return holder, err
+}
+
+func (f fileBackend) Release(agent, file string) (string, error) {
+	var holder string
+	err := transact(f.repoURL, "release "+file+" from "+agent, func(dir string) error {
+		claims, err := loadClaims(dir)
+		if err != nil {
+			return err
+		}
+		existing, ok := claims[file]
+		if !ok {
+			return nil
+		}
+		if existing.Agent != agent {
+			holder = existing.Agent
+			return nil
+		}
+		delete(claims, file)
+		if err := saveClaims(dir, claims); err != nil {
+			return err
+		}
+		return appendMessage(dir, claimMessage(MsgRelease, agent, file))
+	})
+	return holder, err
+}
+
+func (f fileBackend) ReleaseAll(agent string) error {
+	return transact(f.repoURL, "release all claims of "+agent, func(dir string) error {
+		claims, err := loadClaims(dir)
+		if err != nil {
+			return err
+		}
+		for file, claim := range claims {
+			if claim.Agent == agent {
+				delete(claims, file)
+			}
+		}
+		return saveClaims(dir, claims)
+	})
+}
+
+func (f fileBackend) Claims() (Claims, error) {
+	dir, err := syncedDir(f.repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return loadClaims(dir)
+}
+
+func (f fileBackend) Publish(msg Message) error {
+	return transact(f.repoURL, string(msg.Type)+" from "+msg.Agent, func(dir string) error {
+		return appendMessage(dir, msg)
+	})
+}
+
+func (f fileBackend) Messages() ([]Message, error) {
+	dir, err := syncedDir(f.repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return readMessagesFromDir(dir)
+}
+
+func (f fileBackend) UpdateAgent(s *AgentState) error {
+	return transact(f.repoURL, s.Name+" is "+s.Status, func(dir string) error {
+		state, err := loadState(dir)
+		if err != nil {
+			return err
+		}
+		state.Agents[s.Name] = s
+		state.LastUpdated = s.LastUpdate.Format(time.RFC3339)
+		return saveState(dir, state)
+	})
+}
+
+func (f fileBackend) RemoveAgent(name string) error {
+	return transact(f.repoURL, "remove "+name, func(dir string) error {
+		state, err := loadState(dir)
+		if err != nil {
+			return err
+		}
+		delete(state.Agents, name)
+		state.LastUpdated = time.Now().Format(time.RFC3339)
+		if err := saveState(dir, state); err != nil {
+			return err
+		}
+		beats, err := loadHeartbeats(dir)
+		if err != nil || beats[name].IsZero() {
+			return err
+		}
+		delete(beats, name)
+		return saveHeartbeats(dir, beats)
+	})
+}
+
+func (f fileBackend) Beat(name string, at time.Time) error {
+	return transact(f.repoURL, "heartbeat from "+name, func(dir string) error {
+		beats, err := loadHeartbeats(dir)
+		if err != nil {
+			return err
+		}
+		beats[name] = at
+		return saveHeartbeats(dir, beats)
+	})
+}
+
+func (f fileBackend) State() (*State, error) {
+	dir, err := syncedDir(f.repoURL)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadState(dir)
+	if err != nil {
+		return nil, err
+	}
+	beats, err := loadHeartbeats(dir)
+	if err != nil {
+		return nil, err
+	}
+	for name, s := range state.Agents {
+		s.Heartbeat = beats[name]
+	}
+	return state, nil
+}
+
+func claimMessage(typ MessageType, agent, file string) Message {
+	return Message{Version: MessageVersion, Type: typ, Agent: agent, Timestamp: time.Now(), Data: map[string]string{"file": file}}
+}
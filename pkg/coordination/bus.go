@@ -20,25 +20,54 @@ const (
 	MsgPRCreated    MessageType = "pr_created"
 	MsgMerged       MessageType = "merged"
 	MsgRebaseNeeded MessageType = "rebase_needed"
+	MsgEscalation   MessageType = "escalation"
+	MsgViolation    MessageType = "policy_violation"
+	// MsgFilesTouched carries the files an agent read and wrote during one
+	// attempt, taken from its session transcript rather than its claims.
+	MsgFilesTouched MessageType = "files_touched"
+	// MsgAgentRemoved announces that an agent was killed or cleaned up; its
+	// claims (listed in "released") and state entry are gone.
+	MsgAgentRemoved MessageType = "agent_removed"
+	// MsgClaimConflict reports an agent editing a file another agent has
+	// claimed ("file", "holder", and the "action" taken).
+	MsgClaimConflict MessageType = "claim_conflict"
+
+	// Forge events forwarded by the daemon's webhook receiver.
+	MsgIssueLabeled    MessageType = "issue_labeled"
+	MsgReviewSubmitted MessageType = "review_submitted"
+	MsgChecksCompleted MessageType = "checks_completed"
 )
 
 // Message represents a single coordination message on the bus.
 type Message struct {
+	// Version is the envelope's version (MessageVersion when published).
+	Version   int               `json:"v,omitempty"`
 	Type      MessageType       `json:"type"`
 	Agent     string            `json:"agent"`
 	Timestamp time.Time         `json:"timestamp"`
 	Data      map[string]string `json:"data,omitempty"`
 }
 
-// Publish appends a message to the bus (messages.jsonl).
+// Publish appends a message to the bus (messages.jsonl). The message must
+// be of a known type and match its schema; see ValidateMessage.
 func Publish(repoURL string, msg Message) error {
-	dir, err := CoordDir(repoURL)
-	if err != nil {
+	if err := ValidateMessage(msg); err != nil {
 		return err
 	}
+	return PublishUnchecked(repoURL, msg)
+}
 
-	msg.Timestamp = time.Now()
+// PublishUnchecked publishes msg without validating it.
+func PublishUnchecked(repoURL string, msg Message) error {
+	b, err := backendFor(repoURL)
+	if err != nil {
+		return err
+	}
+	msg.Version, msg.Timestamp = MessageVersion, time.Now()
+	return b.Publish(msg)
+}
 
+func appendMessage(dir string, msg Message) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("cannot marshal message: %w", err)
@@ -58,12 +87,11 @@ func Publish(repoURL string, msg Message) error {
 
 // ReadMessages reads all messages from the bus.
 func ReadMessages(repoURL string) ([]Message, error) {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return nil, err
 	}
-
-	return readMessagesFromDir(dir)
+	return b.Messages()
 }
 
 // ReadMessagesSince reads messages from the bus that occurred after the given time.
@@ -153,9 +181,13 @@ func readMessagesFromDir(dir string) ([]Message, error) {
 // isRelevantToAgent checks if a message is relevant to a specific agent.
 // Broadcast messages (like rebase_needed without a target) are relevant to all.
 func isRelevantToAgent(msg Message, agentName string) bool {
-	if msg.Type == MsgRebaseNeeded {
+	switch msg.Type {
+	case MsgRebaseNeeded:
 		target, ok := msg.Data["target"]
 		return !ok || target == agentName
+	case MsgReviewSubmitted, MsgChecksCompleted:
+		// Forge events on an agent's PR or branch.
+		return msg.Data["target"] == agentName
 	}
 	// pushed/committed/merged events are relevant to all agents on the same repo
 	switch msg.Type {
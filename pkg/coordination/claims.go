@@ -2,6 +2,7 @@ package coordination
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,102 +19,68 @@ type Claim struct {
 // Claims is a map from file path to the Claim holding it.
 type Claims map[string]*Claim
 
+// ErrClaimConflict is returned (wrapped) when a file is claimed by another agent.
+var ErrClaimConflict = errors.New("already claimed")
+
 // ClaimFile attempts to claim a file for the given agent.
-// Returns an error if the file is already claimed by another agent.
+// Returns an error if the file is already claimed by another agent. A claim
+// held by an agent whose heartbeat is stale has expired and is taken over.
 func ClaimFile(repoURL, agentName, filePath string) error {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return err
 	}
-
-	claims, err := loadClaims(dir)
+	holder, err := b.Claim(Claim{Agent: agentName, File: filePath, ClaimedAt: time.Now()})
 	if err != nil {
 		return err
 	}
-
-	if existing, ok := claims[filePath]; ok {
-		if existing.Agent != agentName {
-			return fmt.Errorf("file %s already claimed by agent %s (since %s)",
-				filePath, existing.Agent, existing.ClaimedAt.Format(time.RFC3339))
+	if holder.Agent != agentName && IsAgentStale(repoURL, holder.Agent) {
+		if _, err := b.Release(holder.Agent, filePath); err != nil {
+			return err
+		}
+		if holder, err = b.Claim(Claim{Agent: agentName, File: filePath, ClaimedAt: time.Now()}); err != nil {
+			return err
 		}
-		// Already claimed by same agent, idempotent
-		return nil
 	}
-
-	claims[filePath] = &Claim{
-		Agent:     agentName,
-		File:      filePath,
-		ClaimedAt: time.Now(),
+	// Already claimed by the same agent is fine (idempotent)
+	if holder.Agent != agentName {
+		return fmt.Errorf("file %s %w by agent %s (since %s)",
+			filePath, ErrClaimConflict, holder.Agent, holder.ClaimedAt.Format(time.RFC3339))
 	}
-
-	if err := saveClaims(dir, claims); err != nil {
-		return err
-	}
-
-	// Publish a claim message on the bus
-	return Publish(repoURL, Message{
-		Type:  MsgClaim,
-		Agent: agentName,
-		Data:  map[string]string{"file": filePath},
-	})
+	return nil
 }
 
 // ReleaseFile releases a file claim for the given agent.
 // Returns an error if the file is claimed by a different agent.
 func ReleaseFile(repoURL, agentName, filePath string) error {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return err
 	}
-
-	claims, err := loadClaims(dir)
+	holder, err := b.Release(agentName, filePath)
 	if err != nil {
 		return err
 	}
-
-	existing, ok := claims[filePath]
-	if !ok {
-		// Not claimed, nothing to do
-		return nil
-	}
-
-	if existing.Agent != agentName {
+	if holder != "" {
 		return fmt.Errorf("file %s is claimed by agent %s, not %s",
-			filePath, existing.Agent, agentName)
+			filePath, holder, agentName)
 	}
-
-	delete(claims, filePath)
-
-	if err := saveClaims(dir, claims); err != nil {
-		return err
-	}
-
-	// Publish a release message on the bus
-	return Publish(repoURL, Message{
-		Type:  MsgRelease,
-		Agent: agentName,
-		Data:  map[string]string{"file": filePath},
-	})
+	return nil
 }
 
 // ListClaims returns all current file claims.
 func ListClaims(repoURL string) (Claims, error) {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return nil, err
 	}
-	return loadClaims(dir)
+	return b.Claims()
 }
 
 // IsFileClaimed checks if a file is claimed by any agent.
 // Returns the claiming agent name (empty if unclaimed) and whether it's claimed.
 func IsFileClaimed(repoURL, filePath string) (string, bool, error) {
-	dir, err := CoordDir(repoURL)
-	if err != nil {
-		return "", false, err
-	}
-
-	claims, err := loadClaims(dir)
+	claims, err := ListClaims(repoURL)
 	if err != nil {
 		return "", false, err
 	}
@@ -126,23 +93,11 @@ func IsFileClaimed(repoURL, filePath string) (string, bool, error) {
 
 // ReleaseAllForAgent releases all claims held by a given agent.
 func ReleaseAllForAgent(repoURL, agentName string) error {
-	dir, err := CoordDir(repoURL)
-	if err != nil {
-		return err
-	}
-
-	claims, err := loadClaims(dir)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return err
 	}
-
-	for file, claim := range claims {
-		if claim.Agent == agentName {
-			delete(claims, file)
-		}
-	}
-
-	return saveClaims(dir, claims)
+	return b.ReleaseAll(agentName)
 }
 
 func loadClaims(dir string) (Claims, error) {
@@ -173,5 +128,69 @@ func saveClaims(dir string, claims Claims) error {
 		return fmt.Errorf("cannot marshal claims: %w", err)
 	}
 	data = append(data, '\n')
-	return os.WriteFile(claimsPath, data, 0644)
+	return writeFileAtomic(claimsPath, data)
+}
+
+// ClaimStatus reports whether a file is claimed, and by whom, for tools
+// outside agentctl.
+type ClaimStatus struct {
+	File      string     `json:"file"`
+	Claimed   bool       `json:"claimed"`
+	Agent     string     `json:"agent,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
+}
+
+// CheckClaims reports the claim on each file.
+func CheckClaims(repoURL string, files []string) ([]ClaimStatus, error) {
+	claims, err := ListClaims(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ClaimStatus, 0, len(files))
+	for _, f := range files {
+		st := ClaimStatus{File: f}
+		if c, ok := claims[f]; ok {
+			at := c.ClaimedAt
+			st.Claimed, st.Agent, st.ClaimedAt = true, c.Agent, &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// acquirePoll is how often AcquireClaims retries while waiting.
+var acquirePoll = 2 * time.Second
+
+// AcquireClaims claims all of files for agent, or none of them: on a
+// conflict the files already claimed are released again. With a positive
+// wait it retries until the claims are free or wait runs out; the error then
+// wraps ErrClaimConflict.
+func AcquireClaims(repoURL, agent string, files []string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		err := claimAll(repoURL, agent, files)
+		if err == nil || !errors.Is(err, ErrClaimConflict) || !time.Now().Before(deadline) {
+			return err
+		}
+		time.Sleep(min(acquirePoll, time.Until(deadline)))
+	}
+}
+
+func claimAll(repoURL, agent string, files []string) error {
+	claims, err := ListClaims(repoURL)
+	if err != nil {
+		return err
+	}
+	for i, f := range files {
+		if err := ClaimFile(repoURL, agent, f); err != nil {
+			for _, held := range files[:i] {
+				// Only give back what this call took.
+				if c, ok := claims[held]; !ok || c.Agent != agent {
+					ReleaseFile(repoURL, agent, held)
+				}
+			}
+			return err
+		}
+	}
+	return nil
 }
@@ -1,8 +1,10 @@
 package coordination
 
 import (
+	"errors"
 	"os"
 	"testing"
+	"time"
 )
 
 func setupTestRepo(t *testing.T) (string, func()) {
@@ -63,6 +65,9 @@ func TestClaimFileConflict(t *testing.T) {
 	if err == nil {
 		t.Error("expected error when different agent claims same file")
 	}
+	if !errors.Is(err, ErrClaimConflict) {
+		t.Errorf("error should be ErrClaimConflict: %v", err)
+	}
 }
 
 func TestReleaseFile(t *testing.T) {
@@ -164,3 +169,60 @@ func TestReleaseAllForAgent(t *testing.T) {
 		t.Error("agent-2's claim should still exist")
 	}
 }
+
+func TestCheckClaims(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ClaimFile(repoURL, "agent-1", "src/main.go")
+	got, err := CheckClaims(repoURL, []string{"src/main.go", "README.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got[0].Claimed || got[0].Agent != "agent-1" || got[0].ClaimedAt == nil {
+		t.Errorf("src/main.go = %+v", got[0])
+	}
+	if got[1].Claimed || got[1].Agent != "" {
+		t.Errorf("README.md = %+v", got[1])
+	}
+}
+
+func TestAcquireClaims(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+	orig := acquirePoll
+	acquirePoll = 10 * time.Millisecond
+	defer func() { acquirePoll = orig }()
+
+	ClaimFile(repoURL, "agent-1", "b.go")
+	ClaimFile(repoURL, "ci", "c.go")
+
+	// All or nothing: a.go is given back, c.go (held before) is kept.
+	err := AcquireClaims(repoURL, "ci", []string{"c.go", "a.go", "b.go"}, 0)
+	if !errors.Is(err, ErrClaimConflict) {
+		t.Fatalf("AcquireClaims = %v", err)
+	}
+	claims, _ := ListClaims(repoURL)
+	if claims["a.go"] != nil || claims["c.go"] == nil {
+		t.Errorf("claims after conflict = %v", claims)
+	}
+
+	start := time.Now()
+	if err := AcquireClaims(repoURL, "ci", []string{"b.go"}, 50*time.Millisecond); !errors.Is(err, ErrClaimConflict) {
+		t.Errorf("timed out AcquireClaims = %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("AcquireClaims gave up before the timeout")
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		ReleaseFile(repoURL, "agent-1", "b.go")
+	}()
+	if err := AcquireClaims(repoURL, "ci", []string{"a.go", "b.go"}, 5*time.Second); err != nil {
+		t.Fatalf("waiting AcquireClaims = %v", err)
+	}
+	if holder, _, _ := IsFileClaimed(repoURL, "b.go"); holder != "ci" {
+		t.Errorf("b.go held by %q", holder)
+	}
+}
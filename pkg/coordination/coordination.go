@@ -1,6 +1,7 @@
 // Package coordination provides a shared file-based message bus for agent coordination.
 // It manages file claims, inter-agent messaging, and shared state via a coordination
-// directory at ~/.agentctl/coordination/<repo-hash>/.
+// directory at ~/.agentctl/coordination/<repo-hash>/. With the git backend that
+// directory is a checkout of a branch of the repo, so hosts can share it.
 package coordination
 
 import (
@@ -9,22 +10,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
 )
 
 // CoordDir returns the coordination directory for a given repo path.
-// The directory is at ~/.agentctl/coordination/<repo-hash>/.
+// The directory is at ~/.agentctl/coordination/<repo-hash>/, under the
+// namespace's root when one is selected.
 func CoordDir(repoURL string) (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
+	if _, err := os.UserHomeDir(); err != nil {
 		return "", fmt.Errorf("cannot determine home directory: %w", err)
 	}
 	hash := repoHash(repoURL)
-	dir := filepath.Join(home, ".agentctl", "coordination", hash)
+	dir := filepath.Join(namespace.Root(), "coordination", hash)
 	return dir, nil
 }
 
 // Init creates the coordination directory structure and initializes
-// claims.json, messages.jsonl, and state.json if they don't exist.
+// claims.json, messages.jsonl, and state.json if they don't exist. With the
+// git backend it also brings the directory up to date and publishes any
+// changes made to it directly (e.g. by agents through the container mount).
 func Init(repoURL string) (string, error) {
 	dir, err := CoordDir(repoURL)
 	if err != nil {
@@ -35,31 +40,44 @@ func Init(repoURL string) (string, error) {
 		return "", fmt.Errorf("cannot create coordination directory: %w", err)
 	}
 
-	// Initialize claims.json if it doesn't exist
-	claimsPath := filepath.Join(dir, "claims.json")
-	if _, err := os.Stat(claimsPath); os.IsNotExist(err) {
-		if err := os.WriteFile(claimsPath, []byte("{}\n"), 0644); err != nil {
-			return "", fmt.Errorf("cannot create claims.json: %w", err)
+	err = transact(repoURL, "sync", func(dir string) error {
+		// Initialize claims.json if it doesn't exist
+		claimsPath := filepath.Join(dir, "claims.json")
+		if _, err := os.Stat(claimsPath); os.IsNotExist(err) {
+			if err := os.WriteFile(claimsPath, []byte("{}\n"), 0644); err != nil {
+				return fmt.Errorf("cannot create claims.json: %w", err)
+			}
 		}
-	}
 
-	// Initialize messages.jsonl if it doesn't exist
-	messagesPath := filepath.Join(dir, "messages.jsonl")
-	if _, err := os.Stat(messagesPath); os.IsNotExist(err) {
-		if err := os.WriteFile(messagesPath, []byte(""), 0644); err != nil {
-			return "", fmt.Errorf("cannot create messages.jsonl: %w", err)
+		// Initialize messages.jsonl if it doesn't exist
+		messagesPath := filepath.Join(dir, "messages.jsonl")
+		if _, err := os.Stat(messagesPath); os.IsNotExist(err) {
+			if err := os.WriteFile(messagesPath, []byte(""), 0644); err != nil {
+				return fmt.Errorf("cannot create messages.jsonl: %w", err)
+			}
 		}
-	}
 
-	// Initialize state.json if it doesn't exist
-	statePath := filepath.Join(dir, "state.json")
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		initial := `{"agents":{},"last_updated":""}` + "\n"
-		if err := os.WriteFile(statePath, []byte(initial), 0644); err != nil {
-			return "", fmt.Errorf("cannot create state.json: %w", err)
+		// Initialize state.json if it doesn't exist
+		statePath := filepath.Join(dir, "state.json")
+		if _, err := os.Stat(statePath); os.IsNotExist(err) {
+			initial := `{"agents":{},"last_updated":""}` + "\n"
+			if err := os.WriteFile(statePath, []byte(initial), 0644); err != nil {
+				return fmt.Errorf("cannot create state.json: %w", err)
+			}
 		}
-	}
 
+		// Initialize heartbeats.json if it doesn't exist
+		heartbeatsPath := filepath.Join(dir, "heartbeats.json")
+		if _, err := os.Stat(heartbeatsPath); os.IsNotExist(err) {
+			if err := os.WriteFile(heartbeatsPath, []byte("{}\n"), 0644); err != nil {
+				return fmt.Errorf("cannot create heartbeats.json: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
 	return dir, nil
 }
 
@@ -68,3 +86,27 @@ func repoHash(repoURL string) string {
 	h := sha256.Sum256([]byte(repoURL))
 	return hex.EncodeToString(h[:])[:12]
 }
+
+// writeFileAtomic replaces path with data through a temp file and a
+// rename. Readers don't take the bus lock, so a file written in place could
+// be read half-written.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
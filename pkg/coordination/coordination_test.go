@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
 )
 
 func TestRepoHash(t *testing.T) {
@@ -40,6 +42,21 @@ func TestCoordDir(t *testing.T) {
 	}
 }
 
+func TestCoordDirNamespaced(t *testing.T) {
+	repo := "https://github.com/user/repo"
+	shared, _ := CoordDir(repo)
+
+	namespace.Set("exp")
+	defer namespace.Set(namespace.Default)
+	dir, err := CoordDir(repo)
+	if err != nil {
+		t.Fatalf("CoordDir failed: %v", err)
+	}
+	if dir == shared || !contains(dir, filepath.Join("namespaces", "exp", "coordination")) {
+		t.Errorf("namespaced bus should live apart from the default one, got %s", dir)
+	}
+}
+
 func TestInit(t *testing.T) {
 	repoURL := "https://github.com/test/init-test-" + t.Name()
 	dir, err := Init(repoURL)
@@ -0,0 +1,364 @@
+package coordination
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGatewayAddr is where `agentctl bus serve` listens by default.
+const DefaultGatewayAddr = ":8088"
+
+// Gateway serves this host's coordination buses over HTTP, so agents in
+// containers, on other hosts and dashboards can use them without its
+// filesystem (or its Redis). Every request names its repo with ?repo=<url>:
+//
+//	GET    /v1/claims                    claims, by file
+//	POST   /v1/claims                    claim {"agent", "file"}; returns the holder
+//	DELETE /v1/claims?agent=A[&file=F]   release F, or all of A's claims
+//	GET    /v1/messages[?since=T]        messages, oldest first
+//	POST   /v1/messages[?force]          publish a message
+//	GET    /v1/events[?since=T]          messages as they are published (SSE)
+//	GET    /v1/state                     agent states with their heartbeats
+//	PUT    /v1/agents/<name>             set an agent's state
+//	DELETE /v1/agents/<name>             take an agent off the bus
+//	POST   /v1/agents/<name>/heartbeat   record a heartbeat
+//
+// Times are RFC 3339. The http backend is a client of it.
+type Gateway struct {
+	// Token must come as "Authorization: Bearer <token>". Without one the
+	// gateway only listens on a loopback address.
+	Token string
+	// Poll is how often event streams read backends that can't push new
+	// messages (default 1s).
+	Poll time.Duration
+
+	ready sync.Map // repos whose coordination directory is initialized
+}
+
+// gatewayKeepalive is how often an idle event stream sends a comment, so
+// proxies don't close it.
+const gatewayKeepalive = 30 * time.Second
+
+// ErrGatewayNoToken is returned by ListenAndServe for a gateway without a
+// token on an address other hosts can reach: anyone there could forge
+// claims, take agents off the bus and publish messages.
+var ErrGatewayNoToken = errors.New("the bus gateway needs a token to listen beyond loopback")
+
+// ListenAndServe serves the gateway on addr until ctx is done.
+func (g *Gateway) ListenAndServe(ctx context.Context, addr string) error {
+	if g.Token == "" && !loopbackAddr(addr) {
+		return fmt.Errorf("%w: set coordination.token or %s, or listen on 127.0.0.1", ErrGatewayNoToken, BusTokenEnv)
+	}
+	srv := &http.Server{Addr: addr, Handler: g}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// loopbackAddr reports whether addr only listens on this host: "localhost"
+// or a loopback IP. ":8088" listens on every interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.Token != "" {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(g.Token), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			gatewayError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+	}
+	q := r.URL.Query()
+	repo := q.Get("repo")
+	if repo == "" {
+		gatewayError(w, http.StatusBadRequest, errors.New("?repo=<url> is required"))
+		return
+	}
+	b, err := g.store(repo)
+	if err != nil {
+		gatewayError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/v1/agents/"); ok {
+		name, action, _ := strings.Cut(rest, "/")
+		g.agent(w, r, b, name, action)
+		return
+	}
+	route := r.Method + " " + r.URL.Path
+	switch route {
+	case "GET /v1/claims":
+		claims, err := b.Claims()
+		respond(w, claims, err)
+	case "POST /v1/claims":
+		var c Claim
+		if !decode(w, r, &c) {
+			return
+		}
+		if c.Agent == "" || c.File == "" {
+			gatewayError(w, http.StatusBadRequest, errors.New("a claim needs an agent and a file"))
+			return
+		}
+		if c.ClaimedAt.IsZero() {
+			c.ClaimedAt = time.Now()
+		}
+		holder, err := b.Claim(c)
+		respond(w, holder, err)
+	case "DELETE /v1/claims":
+		agent, file := q.Get("agent"), q.Get("file")
+		switch {
+		case agent == "":
+			gatewayError(w, http.StatusBadRequest, errors.New("?agent= is required"))
+		case file == "":
+			respond(w, map[string]string{}, b.ReleaseAll(agent))
+		default:
+			holder, err := b.Release(agent, file)
+			respond(w, map[string]string{"holder": holder}, err)
+		}
+	case "GET /v1/messages":
+		since, ok := sinceParam(w, r)
+		if !ok {
+			return
+		}
+		msgs, err := messagesAfter(b, since)
+		respond(w, msgs, err)
+	case "POST /v1/messages":
+		var msg Message
+		if !decode(w, r, &msg) {
+			return
+		}
+		if !q.Has("force") {
+			if err := ValidateMessage(msg); err != nil {
+				gatewayError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		msg.Version, msg.Timestamp = MessageVersion, time.Now()
+		respond(w, msg, b.Publish(msg))
+	case "GET /v1/events":
+		g.events(w, r, b)
+	case "GET /v1/state":
+		state, err := b.State()
+		respond(w, state, err)
+	default:
+		gatewayError(w, http.StatusNotFound, fmt.Errorf("no route for %s", route))
+	}
+}
+
+// agent handles /v1/agents/<name>[/heartbeat].
+func (g *Gateway) agent(w http.ResponseWriter, r *http.Request, b Backend, name, action string) {
+	switch {
+	case name == "":
+		gatewayError(w, http.StatusNotFound, errors.New("no agent named"))
+	case action == "" && r.Method == http.MethodPut:
+		var s AgentState
+		if !decode(w, r, &s) {
+			return
+		}
+		s.Name = name
+		if s.LastUpdate.IsZero() {
+			s.LastUpdate = time.Now()
+		}
+		respond(w, s, b.UpdateAgent(&s))
+	case action == "" && r.Method == http.MethodDelete:
+		respond(w, map[string]string{}, b.RemoveAgent(name))
+	case action == "heartbeat" && r.Method == http.MethodPost:
+		respond(w, map[string]string{}, b.Beat(name, time.Now()))
+	default:
+		gatewayError(w, http.StatusNotFound, fmt.Errorf("no route for %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// store is the backend the gateway serves repo from: the host's own, never
+// another gateway.
+func (g *Gateway) store(repo string) (Backend, error) {
+	b, err := backendFor(repo)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := b.(httpBackend); ok {
+		return nil, errors.New("the gateway serves a file, git or redis backend, not another gateway (check coordination.backend and " + BusURLEnv + ")")
+	}
+	if _, done := g.ready.Load(repo); !done {
+		if _, err := Init(repo); err != nil {
+			return nil, err
+		}
+		g.ready.Store(repo, true)
+	}
+	return b, nil
+}
+
+// events streams messages published after ?since (or the Last-Event-ID a
+// reconnecting client sends, or now) as server-sent events, each with the
+// message's type as the event and its timestamp as the ID.
+func (g *Gateway) events(w http.ResponseWriter, r *http.Request, b Backend) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		gatewayError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	since := time.Now()
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		t, err := time.Parse(time.RFC3339Nano, id)
+		if err != nil {
+			gatewayError(w, http.StatusBadRequest, fmt.Errorf("invalid Last-Event-ID %q", id))
+			return
+		}
+		since = t
+	} else if r.URL.Query().Has("since") {
+		if since, ok = sinceParam(w, r); !ok {
+			return
+		}
+	}
+
+	// Subscribe before reading the backlog, so nothing published in
+	// between is lost; what both deliver is sent once.
+	ctx := r.Context()
+	pushed := make(chan Message, 64)
+	subErr := make(chan error, 1)
+	var poll <-chan time.Time
+	if sub, ok := b.(subscriber); ok {
+		go func() {
+			subErr <- sub.Subscribe(ctx.Done(), func(m Message) {
+				select {
+				case pushed <- m:
+				case <-ctx.Done():
+				}
+			})
+		}()
+	} else {
+		interval := g.Poll
+		if interval <= 0 {
+			interval = time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	keepalive := time.NewTicker(gatewayKeepalive)
+	defer keepalive.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(msgs ...Message) bool {
+		for _, m := range msgs {
+			if !m.Timestamp.After(since) {
+				continue
+			}
+			data, _ := json.Marshal(m)
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", m.Timestamp.Format(time.RFC3339Nano), m.Type, data); err != nil {
+				return false
+			}
+			since = m.Timestamp
+		}
+		flusher.Flush()
+		return true
+	}
+	backlog, err := messagesAfter(b, since)
+	if err != nil || !send(backlog...) {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-pushed:
+			if !send(m) {
+				return
+			}
+		case <-subErr:
+			// The client reconnects with Last-Event-ID.
+			return
+		case <-poll:
+			msgs, err := messagesAfter(b, since)
+			if err != nil || !send(msgs...) {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func messagesAfter(b Backend, since time.Time) ([]Message, error) {
+	all, err := b.Messages()
+	if err != nil {
+		return nil, err
+	}
+	var after []Message
+	for _, m := range all {
+		if m.Timestamp.After(since) {
+			after = append(after, m)
+		}
+	}
+	return after, nil
+}
+
+// sinceParam parses ?since; it is the zero time when absent.
+func sinceParam(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		gatewayError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q (use RFC 3339)", v))
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		gatewayError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	return true
+}
+
+// respond writes v as JSON, or err if there was one.
+func respond(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		gatewayError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func gatewayError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,153 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startGateway(t *testing.T, token string) string {
+	t.Helper()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { os.Setenv("HOME", origHome) })
+
+	srv := httptest.NewServer(&Gateway{Token: token, Poll: 20 * time.Millisecond})
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestGateway(t *testing.T) {
+	url := startGateway(t, "s3cret")
+	repo := "https://github.com/org/api"
+	h := newHTTPBackend(url+"/", "s3cret", repo)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	pushed := make(chan Message, 10)
+	go h.Subscribe(stop, func(m Message) { pushed <- m })
+	time.Sleep(100 * time.Millisecond)
+
+	holder, err := h.Claim(Claim{Agent: "a1", File: "src/auth.go"})
+	if err != nil || holder.Agent != "a1" {
+		t.Fatalf("claim = %+v, %v", holder, err)
+	}
+	if holder, err := h.Claim(Claim{Agent: "b1", File: "src/auth.go"}); err != nil || holder.Agent != "a1" {
+		t.Errorf("claim of a held file = %+v, %v; want holder a1", holder, err)
+	}
+	if holder, err := h.Release("b1", "src/auth.go"); err != nil || holder != "a1" {
+		t.Errorf("release of another agent's claim = %q, %v", holder, err)
+	}
+	h.Claim(Claim{Agent: "b1", File: "src/db.go"})
+	if err := h.ReleaseAll("a1"); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := h.Claims()
+	if err != nil || len(claims) != 1 || claims["src/db.go"].Agent != "b1" {
+		t.Fatalf("claims = %+v, %v", claims, err)
+	}
+
+	if err := h.UpdateAgent(&AgentState{Name: "a1", Status: "working", Branch: "feat/auth"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Beat("a1", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := h.State()
+	if err != nil || state.Agents["a1"].Branch != "feat/auth" || state.Agents["a1"].Heartbeat.IsZero() {
+		t.Fatalf("state = %+v, %v", state, err)
+	}
+	if err := h.RemoveAgent("a1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Publish(Message{Type: MsgPushed, Agent: "a1", Data: map[string]string{"branch": "feat/auth"}}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := h.Messages()
+	if err != nil || len(msgs) == 0 || msgs[len(msgs)-1].Type != MsgPushed || msgs[len(msgs)-1].Timestamp.IsZero() {
+		t.Fatalf("messages = %+v, %v", msgs, err)
+	}
+
+	for {
+		select {
+		case m := <-pushed:
+			if m.Type == MsgPushed {
+				return
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("the published message never reached the event stream")
+		}
+	}
+}
+
+func TestGateway_Errors(t *testing.T) {
+	url := startGateway(t, "s3cret")
+	repo := "https://github.com/org/api"
+
+	if _, err := newHTTPBackend(url, "wrong", repo).Claims(); err == nil || !strings.Contains(err.Error(), "bearer token") {
+		t.Errorf("wrong token = %v", err)
+	}
+	if _, err := newHTTPBackend(url, "s3cret", repo).Claim(Claim{Agent: "a1"}); err == nil || !strings.Contains(err.Error(), "needs an agent and a file") {
+		t.Errorf("claim without a file = %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/v1/claims", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("request without ?repo = %s", resp.Status)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, url+"/v1/messages?repo="+repo, strings.NewReader(`{"type":"no-such-type","agent":"a1"}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("publish of a message failing its schema = %s", resp.Status)
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := ": keepalive\n\n" +
+		"id: 2026-01-01T00:00:00Z\nevent: pushed\ndata: {\"type\":\"pushed\",\"agent\":\"a1\"}\n\n" +
+		"event: broken\ndata: {not json\n\n" +
+		"data: {\"type\":\"merged\",\ndata: \"agent\":\"b1\"}\n\n"
+	var got []string
+	if err := readEvents(strings.NewReader(stream), func(m Message) { got = append(got, string(m.Type)+":"+m.Agent) }); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "pushed:a1,merged:b1" {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestGatewayNeedsTokenBeyondLoopback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, addr := range []string{":8088", "0.0.0.0:8088", "192.168.1.5:8088"} {
+		if err := (&Gateway{}).ListenAndServe(ctx, addr); !errors.Is(err, ErrGatewayNoToken) {
+			t.Errorf("ListenAndServe(%s) without a token = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0", "[::1]:0"} {
+		if !loopbackAddr(addr) {
+			t.Errorf("%s is loopback", addr)
+		}
+	}
+	if err := (&Gateway{}).ListenAndServe(ctx, "127.0.0.1:0"); err != nil {
+		t.Errorf("ListenAndServe(loopback) without a token = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package coordination
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBranch is the orphan branch the git backend keeps the bus on.
+const DefaultBranch = "agentctl/coordination"
+
+// gitReplica shares the bus through a branch of the repo being worked on, so
+// anyone who can push to the repo can coordinate. The coordination directory
+// is a checkout of that branch: HEAD is the last commit pulled, and local
+// changes stay uncommitted until pushed.
+type gitReplica struct {
+	remote string
+	branch string
+}
+
+// remoteURL expands "owner/repo" to a GitHub URL; anything else (URLs, scp
+// style, local paths) is used as given.
+func remoteURL(repo string) string {
+	if strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@") || filepath.IsAbs(repo) {
+		return repo
+	}
+	return "https://github.com/" + strings.TrimSuffix(repo, ".git") + ".git"
+}
+
+func (g gitReplica) git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir,
+		"-c", "user.name=agentctl", "-c", "user.email=agentctl@localhost"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// ensure turns dir into a checkout of the coordination branch. Files
+// already in dir (from the file backend) become local changes.
+func (g gitReplica) ensure(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return nil
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"symbolic-ref", "HEAD", "refs/heads/" + g.branch},
+		{"remote", "add", "origin", g.remote},
+	} {
+		if _, err := g.git(dir, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g gitReplica) pull(dir string) error {
+	if err := g.ensure(dir); err != nil {
+		return err
+	}
+	if out, err := g.git(dir, "fetch", "-q", "origin", g.branch); err != nil {
+		if strings.Contains(out, "couldn't find remote ref") {
+			return nil // first push creates the branch
+		}
+		return err
+	}
+	remote, err := g.git(dir, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	base := make(map[string][]byte)
+	if head, err := g.git(dir, "rev-parse", "-q", "--verify", "HEAD"); err == nil {
+		if strings.TrimSpace(head) == strings.TrimSpace(remote) {
+			return nil
+		}
+		base = g.show(dir, "HEAD")
+	}
+	if err := mergeInto(dir, base, g.show(dir, "FETCH_HEAD")); err != nil {
+		return err
+	}
+	// Move HEAD (and the index) to the store's commit; the merged files in
+	// the working tree are now exactly the local changes on top of it.
+	_, err = g.git(dir, "reset", "-q", "FETCH_HEAD")
+	return err
+}
+
+// show reads the docs as committed at rev.
+func (g gitReplica) show(dir, rev string) map[string][]byte {
+	m := make(map[string][]byte)
+	for _, name := range docs {
+		if out, err := g.git(dir, "show", rev+":"+name); err == nil {
+			m[name] = []byte(out)
+		}
+	}
+	return m
+}
+
+func (g gitReplica) push(dir, summary string) error {
+	// Only docs that exist or are tracked: git add fails on a pathspec
+	// matching neither (a doc added in a later agentctl, say).
+	tracked, _ := g.git(dir, append([]string{"ls-files", "--"}, docs...)...)
+	isTracked := map[string]bool{}
+	for _, f := range strings.Fields(tracked) {
+		isTracked[f] = true
+	}
+	var paths []string
+	for _, name := range docs {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil || isTracked[name] {
+			paths = append(paths, name)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	if _, err := g.git(dir, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return err
+	}
+	if _, err := g.git(dir, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	if _, err := g.git(dir, "commit", "-q", "-m", summary); err != nil {
+		return err
+	}
+	out, err := g.git(dir, "push", "-q", "origin", "HEAD:refs/heads/"+g.branch)
+	if err == nil {
+		return nil
+	}
+	// Uncommit, keeping the changes in the working tree.
+	if _, perr := g.git(dir, "rev-parse", "-q", "--verify", "HEAD~1"); perr == nil {
+		g.git(dir, "reset", "-q", "HEAD~1")
+	} else {
+		g.git(dir, "update-ref", "-d", "HEAD")
+	}
+	if strings.Contains(out, "rejected") || strings.Contains(out, "fetch first") || strings.Contains(out, "non-fast-forward") {
+		return errStale
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package coordination
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitHosts returns a bare repo to coordinate through and a function that
+// switches HOME between two hosts configured with the git backend.
+func gitHosts(t *testing.T) (string, func(host string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "repo.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	homes := map[string]string{}
+	for _, host := range []string{"a", "b"} {
+		homes[host] = t.TempDir()
+		os.MkdirAll(filepath.Join(homes[host], ".agentctl"), 0755)
+		os.WriteFile(filepath.Join(homes[host], ".agentctl", "config.json"), []byte(`{"coordination":{"backend":"git"}}`), 0644)
+	}
+	origHome := os.Getenv("HOME")
+	t.Cleanup(func() { os.Setenv("HOME", origHome) })
+	return remote, func(host string) { os.Setenv("HOME", homes[host]) }
+}
+
+func TestGitBackendSharesBus(t *testing.T) {
+	remote, as := gitHosts(t)
+
+	as("a")
+	if _, err := Init(remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimFile(remote, "a1", "src/auth.go"); err != nil {
+		t.Fatal(err)
+	}
+	UpdateAgentState(remote, "a1", "working", "feat/auth")
+
+	as("b")
+	if agent, claimed, err := IsFileClaimed(remote, "src/auth.go"); err != nil || !claimed || agent != "a1" {
+		t.Fatalf("host b sees claim = %q, %v, %v", agent, claimed, err)
+	}
+	if err := ClaimFile(remote, "b1", "src/auth.go"); err == nil {
+		t.Error("host b claimed a file host a holds")
+	}
+	if err := ClaimFile(remote, "b1", "src/api.go"); err != nil {
+		t.Fatal(err)
+	}
+	UpdateAgentState(remote, "b1", "working", "feat/api")
+
+	// A write made straight into host a's directory (as an agent does through
+	// the container mount) is merged with b's work and published on the next
+	// operation.
+	as("a")
+	dir, _ := CoordDir(remote)
+	appendMessage(dir, Message{Type: MsgCommitted, Agent: "a1"})
+	if _, err := Init(remote); err != nil {
+		t.Fatal(err)
+	}
+
+	as("b")
+	state, err := GetState(remote)
+	if err != nil || len(state.Agents) != 2 {
+		t.Fatalf("state = %+v, %v", state, err)
+	}
+	msgs, _ := ReadMessages(remote)
+	var types []string
+	for _, m := range msgs {
+		types = append(types, string(m.Type))
+	}
+	if strings.Join(types, ",") != "claim,claim,committed" {
+		t.Errorf("messages = %v", types)
+	}
+}
+
+func TestGitBackendRetriesStalePush(t *testing.T) {
+	remote, as := gitHosts(t)
+	as("b")
+	Init(remote)
+	as("a")
+	Init(remote)
+
+	// Host b publishes between host a's pull and push.
+	calls := 0
+	err := transact(remote, "test", func(dir string) error {
+		calls++
+		if calls == 1 {
+			as("b")
+			if err := Publish(remote, Message{Type: MsgPushed, Agent: "b1"}); err != nil {
+				t.Fatal(err)
+			}
+			as("a")
+		}
+		return appendMessage(dir, Message{Type: MsgMerged, Agent: "a1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("change applied %d times, want 2 (once more after the stale push)", calls)
+	}
+
+	as("b")
+	msgs, _ := ReadMessages(remote)
+	if len(msgs) != 2 || msgs[0].Type != MsgPushed || msgs[1].Type != MsgMerged {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestMergeDoc(t *testing.T) {
+	base := []byte(`{"a.go":{"agent":"x"},"b.go":{"agent":"x"}}`)
+	local := []byte(`{"a.go":{"agent":"x"},"c.go":{"agent":"y"}}`)                       // released b, claimed c
+	remote := []byte(`{"a.go":{"agent":"x"},"c.go":{"agent":"z"},"d.go":{"agent":"z"}}`) // z took c first
+	out, err := mergeDoc("claims.json", base, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]struct{ Agent string }
+	json.Unmarshal(out, &claims)
+	if len(claims) != 3 || claims["c.go"].Agent != "z" || claims["d.go"].Agent != "z" {
+		t.Errorf("merged claims = %s", out)
+	}
+
+	lines := string(mergeLines([]byte("1\n2\n"), []byte("1\n2\n3\n"), []byte("1\n2\n4\n")))
+	if lines != "1\n2\n4\n3\n" {
+		t.Errorf("merged log = %q", lines)
+	}
+}
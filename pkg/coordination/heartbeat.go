@@ -0,0 +1,157 @@
+package coordination
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Heartbeat defaults; see config.Coordination.
+const (
+	DefaultHeartbeatInterval = time.Minute
+	DefaultHeartbeatTimeout  = 5 * time.Minute
+)
+
+// StatusSpawned is an agent's status from spawn until its run loop starts.
+const StatusSpawned = "spawned"
+
+// liveStatuses are the states an agent only holds while its run loop is
+// going, and so heartbeating.
+var liveStatuses = map[string]bool{"working": true, "waiting": true}
+
+// Heartbeat records that agent's run loop is alive. Heartbeats are kept
+// apart from the agent's state entry, so a beat never overwrites a status
+// change made at the same time.
+func Heartbeat(repoURL, agent string) error {
+	b, err := backendFor(repoURL)
+	if err != nil {
+		return err
+	}
+	return b.Beat(agent, time.Now())
+}
+
+// HeartbeatInterval is how often run loops beat (coordination.heartbeat,
+// default 1m); 0 means heartbeats are off.
+func HeartbeatInterval() time.Duration {
+	cfg, err := config.Load()
+	if err != nil || cfg.Coordination.Heartbeat == "" {
+		return DefaultHeartbeatInterval
+	}
+	if cfg.Coordination.Heartbeat == "off" {
+		return 0
+	}
+	if d, err := time.ParseDuration(cfg.Coordination.Heartbeat); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHeartbeatInterval
+}
+
+// HeartbeatTimeout is how long an agent may go without a sign of life before
+// it is considered dead (coordination.heartbeat_timeout, default 5m).
+func HeartbeatTimeout() time.Duration {
+	if cfg, err := config.Load(); err == nil && cfg.Coordination.HeartbeatTimeout != "" {
+		if d, err := time.ParseDuration(cfg.Coordination.HeartbeatTimeout); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultHeartbeatTimeout
+}
+
+// LastSeen is the agent's latest sign of life: a heartbeat or a state update.
+func (s *AgentState) LastSeen() time.Time {
+	if s.Heartbeat.After(s.LastUpdate) {
+		return s.Heartbeat
+	}
+	return s.LastUpdate
+}
+
+// Stale reports whether the agent claims a running loop ("working",
+// "waiting") but hasn't been seen for timeout: its loop crashed or its host
+// went away. Finished and blocked agents are never stale.
+func (s *AgentState) Stale(now time.Time, timeout time.Duration) bool {
+	return liveStatuses[s.Status] && now.Sub(s.LastSeen()) > timeout
+}
+
+// StaleAgents lists the agents in state that are stale, sorted.
+func (st *State) StaleAgents(now time.Time, timeout time.Duration) []string {
+	var out []string
+	for name, s := range st.Agents {
+		if s.Stale(now, timeout) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ActiveAgents lists, sorted, the agents in state at work on the repo: those
+// with a live run loop that isn't stale, and those spawned within timeout that
+// haven't started one yet. Finished, blocked and paused agents don't count.
+func (st *State) ActiveAgents(now time.Time, timeout time.Duration) []string {
+	var out []string
+	for name, s := range st.Agents {
+		live := liveStatuses[s.Status] && !s.Stale(now, timeout)
+		starting := s.Status == StatusSpawned && now.Sub(s.LastSeen()) <= timeout
+		if live || starting {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsAgentStale reports whether repo's bus considers agent dead.
+func IsAgentStale(repoURL, agent string) bool {
+	st, err := GetState(repoURL)
+	if err != nil || st.Agents[agent] == nil {
+		return false
+	}
+	return st.Agents[agent].Stale(time.Now(), HeartbeatTimeout())
+}
+
+// ReapStale takes agents whose heartbeat is stale off repo's bus, releasing
+// their claims, and returns their names.
+func ReapStale(repoURL string) ([]string, error) {
+	st, err := GetState(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	var reaped []string
+	for _, name := range st.StaleAgents(time.Now(), HeartbeatTimeout()) {
+		if _, err := RemoveAgent(repoURL, name, "heartbeat lost"); err != nil {
+			return reaped, err
+		}
+		reaped = append(reaped, name)
+	}
+	return reaped, nil
+}
+
+// loadHeartbeats reads heartbeats.json, which maps agent names to their
+// last heartbeat.
+func loadHeartbeats(dir string) (map[string]time.Time, error) {
+	beats := make(map[string]time.Time)
+	data, err := os.ReadFile(filepath.Join(dir, "heartbeats.json"))
+	if os.IsNotExist(err) {
+		return beats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read heartbeats.json: %w", err)
+	}
+	if err := json.Unmarshal(data, &beats); err != nil {
+		return nil, fmt.Errorf("cannot parse heartbeats.json: %w", err)
+	}
+	return beats, nil
+}
+
+func saveHeartbeats(dir string, beats map[string]time.Time) error {
+	data, err := json.MarshalIndent(beats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "heartbeats.json"), append(data, '\n'))
+}
@@ -0,0 +1,127 @@
+package coordination
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	repoURL := "https://github.com/test/" + t.Name()
+	dir, err := Init(repoURL)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	UpdateAgentState(repoURL, "agent-1", "working", "b1")
+	if err := Heartbeat(repoURL, "agent-1"); err != nil {
+		t.Fatal(err)
+	}
+	UpdateAgentState(repoURL, "agent-1", "done", "b1")
+
+	// A beat never overwrites the status, and survives status updates.
+	state, _ := GetState(repoURL)
+	s := state.Agents["agent-1"]
+	if s.Status != "done" || s.Heartbeat.IsZero() {
+		t.Errorf("state = %+v", s)
+	}
+
+	RemoveAgent(repoURL, "agent-1", "killed")
+	beats, _ := loadHeartbeats(dir)
+	if _, ok := beats["agent-1"]; ok {
+		t.Error("removing an agent should drop its heartbeat")
+	}
+}
+
+func TestStale(t *testing.T) {
+	now := time.Now()
+	timeout := 5 * time.Minute
+	for _, tc := range []struct {
+		s    AgentState
+		want bool
+	}{
+		{AgentState{Status: "working", LastUpdate: now.Add(-time.Hour), Heartbeat: now.Add(-time.Minute)}, false},
+		{AgentState{Status: "working", LastUpdate: now.Add(-time.Hour), Heartbeat: now.Add(-10 * time.Minute)}, true},
+		{AgentState{Status: "waiting", LastUpdate: now.Add(-time.Hour)}, true},
+		{AgentState{Status: "working", LastUpdate: now.Add(-time.Minute)}, false},
+		{AgentState{Status: "done", LastUpdate: now.Add(-time.Hour)}, false},
+		{AgentState{Status: "blocked", LastUpdate: now.Add(-time.Hour)}, false},
+	} {
+		if got := tc.s.Stale(now, timeout); got != tc.want {
+			t.Errorf("%+v: Stale = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestActiveAgents(t *testing.T) {
+	now := time.Now()
+	st := &State{Agents: map[string]*AgentState{
+		"working":  {Status: "working", LastUpdate: now.Add(-time.Hour), Heartbeat: now.Add(-time.Minute)},
+		"dead":     {Status: "working", LastUpdate: now.Add(-time.Hour)},
+		"waiting":  {Status: "waiting", LastUpdate: now.Add(-time.Minute)},
+		"starting": {Status: StatusSpawned, LastUpdate: now.Add(-time.Minute)},
+		"never":    {Status: StatusSpawned, LastUpdate: now.Add(-time.Hour)},
+		"done":     {Status: "done", LastUpdate: now},
+		"paused":   {Status: "paused", LastUpdate: now},
+	}}
+	got := st.ActiveAgents(now, 5*time.Minute)
+	want := []string{"starting", "waiting", "working"}
+	if len(got) != len(want) {
+		t.Fatalf("ActiveAgents = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ActiveAgents = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestStaleAgentLosesClaimsAndBus(t *testing.T) {
+	repoURL := "https://github.com/test/" + t.Name()
+	dir, err := Init(repoURL)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, _ := backendFor(repoURL)
+	long := time.Now().Add(-time.Hour)
+	b.UpdateAgent(&AgentState{Name: "crashed", Status: "working", LastUpdate: long})
+	b.Beat("crashed", long)
+	ClaimFile(repoURL, "crashed", "src/a.go")
+	UpdateAgentState(repoURL, "alive", "working", "")
+	ClaimFile(repoURL, "alive", "src/b.go")
+
+	// The crashed agent's claim has expired; the live one's holds.
+	if err := ClaimFile(repoURL, "newcomer", "src/a.go"); err != nil {
+		t.Errorf("claim held by a stale agent should be taken over: %v", err)
+	}
+	if err := ClaimFile(repoURL, "newcomer", "src/b.go"); !errors.Is(err, ErrClaimConflict) {
+		t.Errorf("claim held by a live agent = %v", err)
+	}
+
+	reaped, err := ReapStale(repoURL)
+	if err != nil || len(reaped) != 1 || reaped[0] != "crashed" {
+		t.Fatalf("ReapStale = %v, %v", reaped, err)
+	}
+	state, _ := GetState(repoURL)
+	if state.Agents["crashed"] != nil || state.Agents["alive"] == nil {
+		t.Errorf("agents after reaping: %v", state.Agents)
+	}
+}
+
+func TestMergeHeartbeats(t *testing.T) {
+	base := []byte(`{"a": "2026-01-01T00:00:00Z"}`)
+	local := []byte(`{"a": "2026-01-01T00:00:00Z", "b": "2026-01-01T00:01:00Z"}`)
+	remote := []byte(`{"a": "2026-01-01T00:02:00Z"}`)
+	merged, err := mergeDoc("heartbeats.json", base, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"a\": \"2026-01-01T00:02:00Z\",\n  \"b\": \"2026-01-01T00:01:00Z\"\n}\n"
+	if string(merged) != want {
+		t.Errorf("merged = %s", merged)
+	}
+}
@@ -0,0 +1,228 @@
+package coordination
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BusURLEnv and BusTokenEnv override the config's gateway URL and token;
+// spawn sets them for agents so agentctl inside the container reaches the
+// same bus.
+const (
+	BusURLEnv   = "AGENTCTL_BUS_URL"
+	BusTokenEnv = "AGENTCTL_BUS_TOKEN"
+)
+
+// httpBackend is a client of a Gateway: the bus lives on the host serving
+// it, and this host reaches it over HTTP.
+type httpBackend struct {
+	base  string // http://host:8088
+	token string
+	repo  string
+}
+
+// httpTimeout bounds every gateway call but the event stream.
+const httpTimeout = 30 * time.Second
+
+func newHTTPBackend(base, token, repoURL string) httpBackend {
+	return httpBackend{base: strings.TrimSuffix(base, "/"), token: token, repo: repoURL}
+}
+
+// request builds a call to the gateway for h's repo.
+func (h httpBackend) request(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("repo", h.repo)
+	var data io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.base+path+"?"+query.Encode(), data)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	return req, nil
+}
+
+// call makes a request and decodes the JSON reply into out, if given.
+func (h httpBackend) call(method, path string, query url.Values, body, out interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	req, err := h.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bus gateway %s: %w", h.base, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return gatewayFailure(h.base, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("bus gateway %s: bad reply: %w", h.base, err)
+	}
+	return nil
+}
+
+// gatewayFailure turns an error reply into an error, with the gateway's
+// reason when it gave one.
+func gatewayFailure(base string, resp *http.Response) error {
+	var reply struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&reply)
+	if reply.Error == "" {
+		reply.Error = resp.Status
+	}
+	return fmt.Errorf("bus gateway %s: %s", base, reply.Error)
+}
+
+func (h httpBackend) Claim(c Claim) (*Claim, error) {
+	var holder Claim
+	if err := h.call(http.MethodPost, "/v1/claims", nil, c, &holder); err != nil {
+		return nil, err
+	}
+	return &holder, nil
+}
+
+func (h httpBackend) Release(agent, file string) (string, error) {
+	var reply struct {
+		Holder string `json:"holder"`
+	}
+	err := h.call(http.MethodDelete, "/v1/claims", url.Values{"agent": {agent}, "file": {file}}, nil, &reply)
+	return reply.Holder, err
+}
+
+func (h httpBackend) ReleaseAll(agent string) error {
+	return h.call(http.MethodDelete, "/v1/claims", url.Values{"agent": {agent}}, nil, nil)
+}
+
+func (h httpBackend) Claims() (Claims, error) {
+	claims := Claims{}
+	err := h.call(http.MethodGet, "/v1/claims", nil, nil, &claims)
+	return claims, err
+}
+
+// Publish sends msg as is: callers validated it already, against this
+// host's schemas.
+func (h httpBackend) Publish(msg Message) error {
+	return h.call(http.MethodPost, "/v1/messages", url.Values{"force": {"1"}}, msg, nil)
+}
+
+func (h httpBackend) Messages() ([]Message, error) {
+	var msgs []Message
+	err := h.call(http.MethodGet, "/v1/messages", nil, nil, &msgs)
+	return msgs, err
+}
+
+func (h httpBackend) UpdateAgent(s *AgentState) error {
+	return h.call(http.MethodPut, "/v1/agents/"+url.PathEscape(s.Name), nil, s, nil)
+}
+
+func (h httpBackend) RemoveAgent(name string) error {
+	return h.call(http.MethodDelete, "/v1/agents/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// Beat records a heartbeat; the gateway stamps it with its own clock.
+func (h httpBackend) Beat(name string, at time.Time) error {
+	return h.call(http.MethodPost, "/v1/agents/"+url.PathEscape(name)+"/heartbeat", nil, nil, nil)
+}
+
+func (h httpBackend) State() (*State, error) {
+	state := &State{}
+	if err := h.call(http.MethodGet, "/v1/state", nil, nil, state); err != nil {
+		return nil, err
+	}
+	if state.Agents == nil {
+		state.Agents = map[string]*AgentState{}
+	}
+	return state, nil
+}
+
+// Subscribe follows the gateway's event stream, calling fn for each
+// message, until stop is closed or the stream breaks.
+func (h httpBackend) Subscribe(stop <-chan struct{}, fn func(Message)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	req, err := h.request(ctx, http.MethodGet, "/v1/events", nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("bus gateway %s: %w", h.base, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return gatewayFailure(h.base, resp)
+	}
+	err = readEvents(resp.Body, fn)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("bus gateway %s: event stream ended: %w", h.base, err)
+}
+
+// readEvents reads server-sent events, passing each one's data on as a
+// message. Comments and fields other than data are skipped.
+func readEvents(r io.Reader, fn func(Message)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			var msg Message
+			if data.Len() > 0 && json.Unmarshal([]byte(data.String()), &msg) == nil {
+				fn(msg)
+			}
+			data.Reset()
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(v, " "))
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,148 @@
+package coordination
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SubTask is one unit of work and the files or modules it will touch. A
+// target is a file ("pkg/auth/token.go"), a directory standing for a module
+// ("pkg/auth" or "pkg/auth/"), or a glob ("pkg/*/routes.go").
+type SubTask struct {
+	Name    string   `json:"name"`
+	Targets []string `json:"targets"`
+}
+
+// Plan is a partition of sub-tasks. Batches run one after another; the
+// sub-tasks within a batch touch disjoint targets and can run in parallel.
+type Plan struct {
+	Batches [][]SubTask `json:"batches"`
+	// Blocked holds sub-tasks that overlap a file another agent has claimed,
+	// keyed by name, with the conflicting claims ("file (claimed by agent)").
+	Blocked map[string][]string `json:"blocked,omitempty"`
+}
+
+// PlanPartition partitions sub-tasks against the repo's current claims.
+// Claims held by owner (the orchestrating agent, if any) don't block.
+func PlanPartition(repoURL, owner string, tasks []SubTask) (*Plan, error) {
+	claims, err := ListClaims(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return Partition(tasks, claims, owner)
+}
+
+// Partition is PlanPartition over a given set of claims. A sub-task goes in
+// the batch after the last earlier sub-task it conflicts with, so
+// conflicting work keeps its input order and everything else runs as early
+// as possible.
+func Partition(tasks []SubTask, claims Claims, owner string) (*Plan, error) {
+	seen := map[string]bool{}
+	for i, t := range tasks {
+		if t.Name == "" {
+			return nil, fmt.Errorf("sub-task %d has no name", i+1)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate sub-task %q", t.Name)
+		}
+		seen[t.Name] = true
+		for _, target := range t.Targets {
+			if _, err := path.Match(cleanTarget(target), ""); err != nil {
+				return nil, fmt.Errorf("sub-task %q: bad target %q: %w", t.Name, target, err)
+			}
+		}
+	}
+
+	files := make([]string, 0, len(claims))
+	for f := range claims {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	plan := &Plan{}
+	var placed []SubTask
+	var batchOf []int
+	for _, t := range tasks {
+		var held []string
+		for _, f := range files {
+			if c := claims[f]; c.Agent != owner && touches(t, f) {
+				held = append(held, fmt.Sprintf("%s (claimed by %s)", f, c.Agent))
+			}
+		}
+		if len(held) > 0 {
+			if plan.Blocked == nil {
+				plan.Blocked = map[string][]string{}
+			}
+			plan.Blocked[t.Name] = held
+			continue
+		}
+
+		batch := 0
+		for i, p := range placed {
+			if batchOf[i] >= batch && Conflicts(t, p) {
+				batch = batchOf[i] + 1
+			}
+		}
+		if batch == len(plan.Batches) {
+			plan.Batches = append(plan.Batches, nil)
+		}
+		plan.Batches[batch] = append(plan.Batches[batch], t)
+		placed = append(placed, t)
+		batchOf = append(batchOf, batch)
+	}
+	return plan, nil
+}
+
+// Conflicts reports whether two sub-tasks touch an overlapping target.
+func Conflicts(a, b SubTask) bool {
+	for _, x := range a.Targets {
+		for _, y := range b.Targets {
+			if Overlaps(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func touches(t SubTask, file string) bool {
+	for _, target := range t.Targets {
+		if Overlaps(target, file) {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanTarget(t string) string {
+	return path.Clean(strings.TrimPrefix(strings.TrimSpace(t), "./"))
+}
+
+// Overlaps reports whether two targets can refer to the same file: equal,
+// one inside the other, or matched by a glob.
+func Overlaps(a, b string) bool {
+	a, b = cleanTarget(a), cleanTarget(b)
+	if a == b || a == "." || b == "." {
+		return true
+	}
+	if strings.HasPrefix(b, a+"/") || strings.HasPrefix(a, b+"/") {
+		return true
+	}
+	return globOverlaps(a, b) || globOverlaps(b, a)
+}
+
+// globOverlaps matches pattern against target and each of target's parent
+// directories, so "pkg/*" covers "pkg/auth/token.go".
+func globOverlaps(pattern, target string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return false
+	}
+	for p := target; p != "." && p != "/"; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package coordination
+
+import (
+	"reflect"
+	"testing"
+)
+
+func batchNames(p *Plan) [][]string {
+	var out [][]string
+	for _, b := range p.Batches {
+		var names []string
+		for _, t := range b {
+			names = append(names, t.Name)
+		}
+		out = append(out, names)
+	}
+	return out
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"pkg/auth/token.go", "pkg/auth/token.go", true},
+		{"./pkg/auth/token.go", "pkg/auth/token.go", true},
+		{"pkg/auth", "pkg/auth/token.go", true},
+		{"pkg/auth/", "pkg/auth/token.go", true},
+		{"pkg/auth", "pkg/authz/policy.go", false},
+		{"pkg/*/routes.go", "pkg/api/routes.go", true},
+		{"pkg/*", "pkg/api/routes.go", true},
+		{"*.md", "docs/guide.md", false},
+		{"pkg/api/routes.go", "pkg/api/handlers.go", false},
+	}
+	for _, tt := range tests {
+		if got := Overlaps(tt.a, tt.b); got != tt.want {
+			t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	tasks := []SubTask{
+		{Name: "auth", Targets: []string{"pkg/auth"}},
+		{Name: "routes", Targets: []string{"pkg/api/routes.go"}},
+		{Name: "token-expiry", Targets: []string{"pkg/auth/token.go"}},
+		{Name: "docs", Targets: []string{"README.md"}},
+		{Name: "api-all", Targets: []string{"pkg/api/*.go"}},
+		{Name: "billing", Targets: []string{"pkg/billing/invoice.go"}},
+		{Name: "mine", Targets: []string{"go.mod"}},
+	}
+	claims := Claims{
+		"pkg/billing/invoice.go": {Agent: "other", File: "pkg/billing/invoice.go"},
+		"go.mod":                 {Agent: "orchestrator", File: "go.mod"},
+	}
+	plan, err := Partition(tasks, claims, "orchestrator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"auth", "routes", "docs", "mine"},
+		{"token-expiry", "api-all"},
+	}
+	if got := batchNames(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+	if got := plan.Blocked["billing"]; len(got) != 1 || got[0] != "pkg/billing/invoice.go (claimed by other)" {
+		t.Errorf("blocked = %v", plan.Blocked)
+	}
+
+	if _, err := Partition([]SubTask{{Name: "a"}, {Name: "a"}}, nil, ""); err == nil {
+		t.Error("expected an error for duplicate names")
+	}
+	if _, err := Partition([]SubTask{{Name: "a", Targets: []string{"pkg/[x"}}}, nil, ""); err == nil {
+		t.Error("expected an error for a malformed glob")
+	}
+}
+
+func TestPlanPartition(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+	ClaimFile(repoURL, "agent-1", "src/main.go")
+
+	plan, err := PlanPartition(repoURL, "", []SubTask{
+		{Name: "main", Targets: []string{"src/main.go"}},
+		{Name: "util", Targets: []string{"src/util.go"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Batches) != 1 || len(plan.Batches[0]) != 1 || plan.Blocked["main"] == nil {
+		t.Errorf("plan = %+v", plan)
+	}
+}
@@ -0,0 +1,100 @@
+package coordination
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPromptMessages is how many recent bus messages PromptContext includes.
+const DefaultPromptMessages = 10
+
+// PromptContext renders a coordination briefing for agentName to prepend to
+// its prompt: files claimed by other agents, claims it holds, and recent
+// messages relevant to it, plus instructions for using claim/release.
+// Agents never consult the bus on their own, so it has to be put in front
+// of them.
+func PromptContext(repoURL, agentName string, maxMessages int) (string, error) {
+	claims, err := ListClaims(repoURL)
+	if err != nil {
+		return "", err
+	}
+	msgs, err := ReadMessagesForAgent(repoURL, agentName)
+	if err != nil {
+		return "", err
+	}
+	return formatPromptContext(repoURL, agentName, claims, msgs, maxMessages), nil
+}
+
+func formatPromptContext(repoURL, agentName string, claims Claims, msgs []Message, maxMessages int) string {
+	var mine, others []string
+	for file, c := range claims {
+		if c.Agent == agentName {
+			mine = append(mine, file)
+		} else {
+			others = append(others, fmt.Sprintf("%s (claimed by %s)", file, c.Agent))
+		}
+	}
+	sort.Strings(mine)
+	sort.Strings(others)
+
+	var b strings.Builder
+	b.WriteString("## Coordination\n")
+	fmt.Fprintf(&b, "You are agent %q. Other agents are working on this repository at the same time.\n", agentName)
+	b.WriteString("Before editing a file, claim it; release it when you are done:\n")
+	fmt.Fprintf(&b, "  agentctl claim %s %s <file>\n", agentName, repoURL)
+	fmt.Fprintf(&b, "  agentctl release %s %s <file>\n", agentName, repoURL)
+	b.WriteString("Do NOT edit files claimed by other agents. If a claim fails, work on something else or wait.\n")
+
+	b.WriteString("\nFiles claimed by other agents:\n")
+	if len(others) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, o := range others {
+		b.WriteString("  - " + o + "\n")
+	}
+
+	b.WriteString("\nFiles you hold:\n")
+	if len(mine) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, m := range mine {
+		b.WriteString("  - " + m + "\n")
+	}
+
+	// Only messages from other agents are news to this one.
+	var recent []Message
+	for _, m := range msgs {
+		if m.Agent != agentName {
+			recent = append(recent, m)
+		}
+	}
+	if maxMessages > 0 && len(recent) > maxMessages {
+		recent = recent[len(recent)-maxMessages:]
+	}
+	if len(recent) > 0 {
+		b.WriteString("\nRecent activity from other agents:\n")
+		for _, m := range recent {
+			fmt.Fprintf(&b, "  - [%s] %s %s%s\n", m.Timestamp.Format(time.RFC3339), m.Agent, m.Type, formatData(m.Data))
+		}
+	}
+	return b.String()
+}
+
+// formatData renders message data as sorted " k=v" pairs.
+func formatData(data map[string]string) string {
+	if len(data) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(" " + k + "=" + data[k])
+	}
+	return b.String()
+}
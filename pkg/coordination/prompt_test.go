@@ -0,0 +1,68 @@
+package coordination
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptContext(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ClaimFile(repoURL, "agent-1", "src/mine.go")
+	ClaimFile(repoURL, "agent-2", "src/theirs.go")
+	Publish(repoURL, Message{Type: MsgPushed, Agent: "agent-2", Data: map[string]string{"branch": "feat"}})
+
+	got, err := PromptContext(repoURL, "agent-1", DefaultPromptMessages)
+	if err != nil {
+		t.Fatalf("PromptContext failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"agentctl claim agent-1 " + repoURL,
+		"agentctl release agent-1 " + repoURL,
+		"src/theirs.go (claimed by agent-2)",
+		"  - src/mine.go",
+		"agent-2 pushed branch=feat",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("context missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "src/mine.go (claimed by") {
+		t.Error("own claims should not be listed as claimed by others")
+	}
+}
+
+func TestPromptContextLimitsMessages(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		Publish(repoURL, Message{Type: MsgMerged, Agent: "agent-2"})
+	}
+
+	got, err := PromptContext(repoURL, "agent-1", 2)
+	if err != nil {
+		t.Fatalf("PromptContext failed: %v", err)
+	}
+	if n := strings.Count(got, "agent-2 merged"); n != 2 {
+		t.Errorf("expected 2 messages, got %d", n)
+	}
+}
+
+func TestPromptContextEmpty(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	got, err := PromptContext(repoURL, "agent-1", DefaultPromptMessages)
+	if err != nil {
+		t.Fatalf("PromptContext failed: %v", err)
+	}
+	if strings.Contains(got, "Recent activity") {
+		t.Error("no activity section expected on an empty bus")
+	}
+	if strings.Count(got, "(none)") != 2 {
+		t.Errorf("expected both claim sections to be empty:\n%s", got)
+	}
+}
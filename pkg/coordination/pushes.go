@@ -0,0 +1,59 @@
+package coordination
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PushesFile, in a repo's coordination directory, is where agents' git
+// hooks record their pushes through the container mount. It stays local:
+// RelayPushes turns its entries into bus messages.
+const PushesFile = "pushes.jsonl"
+
+// PushEvent is one push an agent's hook recorded.
+type PushEvent struct {
+	Agent  string    `json:"agent"`
+	Branch string    `json:"branch"`
+	SHA    string    `json:"sha"`
+	At     time.Time `json:"at"`
+}
+
+// RelayPushes publishes the pushes recorded in repo's coordination
+// directory since the last call as MsgPushed messages, and returns them.
+func RelayPushes(repoURL string) ([]PushEvent, error) {
+	dir, err := CoordDir(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	// Take the file away first, so hooks appending meanwhile start a new one.
+	path := filepath.Join(dir, PushesFile)
+	taken := path + ".relay"
+	if err := os.Rename(path, taken); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(taken)
+	os.Remove(taken)
+	if err != nil {
+		return nil, err
+	}
+
+	var relayed []PushEvent
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var e PushEvent
+		if json.Unmarshal(line, &e) != nil || e.Agent == "" || e.Branch == "" {
+			continue
+		}
+		msg := Message{Type: MsgPushed, Agent: e.Agent, Data: map[string]string{"branch": e.Branch, "sha": e.SHA}}
+		if err := Publish(repoURL, msg); err != nil {
+			return relayed, err
+		}
+		relayed = append(relayed, e)
+	}
+	return relayed, nil
+}
@@ -0,0 +1,42 @@
+package coordination
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelayPushes(t *testing.T) {
+	repoURL := "https://github.com/test/" + t.Name()
+	dir, err := Init(repoURL)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if got, err := RelayPushes(repoURL); err != nil || got != nil {
+		t.Fatalf("RelayPushes with nothing recorded = %v, %v", got, err)
+	}
+
+	hooked := `{"agent":"a1","branch":"feature/x","sha":"abc123","at":"2026-01-02T15:04:05Z"}
+not json
+{"agent":"a2","branch":"fix","sha":"def456","at":"2026-01-02T15:05:00Z"}
+`
+	os.WriteFile(filepath.Join(dir, PushesFile), []byte(hooked), 0644)
+	got, err := RelayPushes(repoURL)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("RelayPushes = %v, %v", got, err)
+	}
+
+	msgs, _ := ReadMessages(repoURL)
+	if len(msgs) != 2 || msgs[0].Type != MsgPushed || msgs[0].Agent != "a1" ||
+		msgs[0].Data["branch"] != "feature/x" || msgs[0].Data["sha"] != "abc123" {
+		t.Errorf("messages = %+v", msgs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, PushesFile)); !os.IsNotExist(err) {
+		t.Error("relayed pushes should be taken off the file")
+	}
+	if got, _ := RelayPushes(repoURL); got != nil {
+		t.Errorf("pushes relayed twice: %v", got)
+	}
+}
@@ -0,0 +1,445 @@
+package coordination
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// redisBackend keeps a repo's coordination state in Redis, under
+// agentctl:<namespace>:<repo-hash>:
+//
+//	…:claims   hash  file → Claim JSON (HSETNX makes claiming atomic)
+//	…:agents   hash  agent → AgentState JSON
+//	…:updated  string  last state change
+//	…:messages list  Message JSON, oldest first
+//	…:bus      channel  each message as it is published
+type redisBackend struct {
+	url    string
+	prefix string
+}
+
+func newRedisBackend(redisURL, repoURL string) redisBackend {
+	return redisBackend{url: redisURL, prefix: "agentctl:" + namespace.Current() + ":" + repoHash(repoURL)}
+}
+
+// redisRetries bounds optimistic (WATCH/MULTI) transactions.
+const redisRetries = 5
+
+func (r redisBackend) key(name string) string {
+	return r.prefix + ":" + name
+}
+
+// with runs fn on a fresh connection.
+func (r redisBackend) with(fn func(c *redisConn) error) error {
+	c, err := dialRedis(r.url)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return fn(c)
+}
+
+func (r redisBackend) Claim(claim Claim) (*Claim, error) {
+	data, _ := json.Marshal(claim)
+	var holder *Claim
+	err := r.with(func(c *redisConn) error {
+		set, err := c.do("HSETNX", r.key("claims"), claim.File, string(data))
+		if err != nil {
+			return err
+		}
+		if set == int64(1) {
+			holder = &claim
+			return r.publish(c, claimMessage(MsgClaim, claim.Agent, claim.File))
+		}
+		existing, err := c.do("HGET", r.key("claims"), claim.File)
+		if err != nil {
+			return err
+		}
+		s, _ := existing.(string)
+		holder = &Claim{}
+		if err := json.Unmarshal([]byte(s), holder); err != nil {
+			return fmt.Errorf("cannot parse claim on %s: %w", claim.File, err)
+		}
+		return nil
+	})
+	return holder, err
+}
+
+func (r redisBackend) Release(agent, file string) (string, error) {
+	var holder string
+	err := r.with(func(c *redisConn) error {
+		for try := 0; try < redisRetries; try++ {
+			if _, err := c.do("WATCH", r.key("claims")); err != nil {
+				return err
+			}
+			existing, err := c.do("HGET", r.key("claims"), file)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				_, err := c.do("UNWATCH")
+				return err
+			}
+			var claim Claim
+			s, _ := existing.(string)
+			json.Unmarshal([]byte(s), &claim)
+			if claim.Agent != agent {
+				holder = claim.Agent
+				_, err := c.do("UNWATCH")
+				return err
+			}
+			msg, _ := json.Marshal(claimMessage(MsgRelease, agent, file))
+			done, err := c.transaction(
+				[]string{"HDEL", r.key("claims"), file},
+				[]string{"RPUSH", r.key("messages"), string(msg)},
+				[]string{"PUBLISH", r.key("bus"), string(msg)},
+			)
+			if err != nil || done {
+				return err
+			}
+		}
+		return errStale
+	})
+	return holder, err
+}
+
+func (r redisBackend) ReleaseAll(agent string) error {
+	return r.with(func(c *redisConn) error {
+		for try := 0; try < redisRetries; try++ {
+			if _, err := c.do("WATCH", r.key("claims")); err != nil {
+				return err
+			}
+			claims, err := r.claims(c)
+			if err != nil {
+				return err
+			}
+			hdel := []string{"HDEL", r.key("claims")}
+			for file, claim := range claims {
+				if claim.Agent == agent {
+					hdel = append(hdel, file)
+				}
+			}
+			if len(hdel) == 2 {
+				_, err := c.do("UNWATCH")
+				return err
+			}
+			done, err := c.transaction(hdel)
+			if err != nil || done {
+				return err
+			}
+		}
+		return errStale
+	})
+}
+
+func (r redisBackend) Claims() (Claims, error) {
+	var claims Claims
+	err := r.with(func(c *redisConn) error {
+		var err error
+		claims, err = r.claims(c)
+		return err
+	})
+	return claims, err
+}
+
+func (r redisBackend) claims(c *redisConn) (Claims, error) {
+	fields, err := c.hgetall(r.key("claims"))
+	if err != nil {
+		return nil, err
+	}
+	claims := make(Claims)
+	for file, data := range fields {
+		var claim Claim
+		if json.Unmarshal([]byte(data), &claim) == nil {
+			claims[file] = &claim
+		}
+	}
+	return claims, nil
+}
+
+func (r redisBackend) Publish(msg Message) error {
+	return r.with(func(c *redisConn) error {
+		return r.publish(c, msg)
+	})
+}
+
+func (r redisBackend) publish(c *redisConn, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cannot marshal message: %w", err)
+	}
+	if _, err := c.do("RPUSH", r.key("messages"), string(data)); err != nil {
+		return err
+	}
+	_, err = c.do("PUBLISH", r.key("bus"), string(data))
+	return err
+}
+
+func (r redisBackend) Messages() ([]Message, error) {
+	var msgs []Message
+	err := r.with(func(c *redisConn) error {
+		reply, err := c.do("LRANGE", r.key("messages"), "0", "-1")
+		if err != nil {
+			return err
+		}
+		items, _ := reply.([]interface{})
+		for _, item := range items {
+			var msg Message
+			if s, ok := item.(string); ok && json.Unmarshal([]byte(s), &msg) == nil {
+				msgs = append(msgs, msg)
+			}
+		}
+		return nil
+	})
+	return msgs, err
+}
+
+func (r redisBackend) UpdateAgent(s *AgentState) error {
+	data, _ := json.Marshal(s)
+	return r.with(func(c *redisConn) error {
+		if _, err := c.do("HSET", r.key("agents"), s.Name, string(data)); err != nil {
+			return err
+		}
+		_, err := c.do("SET", r.key("updated"), s.LastUpdate.Format(time.RFC3339))
+		return err
+	})
+}
+
+func (r redisBackend) RemoveAgent(name string) error {
+	return r.with(func(c *redisConn) error {
+		if _, err := c.do("HDEL", r.key("agents"), name); err != nil {
+			return err
+		}
+		if _, err := c.do("HDEL", r.key("heartbeats"), name); err != nil {
+			return err
+		}
+		_, err := c.do("SET", r.key("updated"), time.Now().Format(time.RFC3339))
+		return err
+	})
+}
+
+func (r redisBackend) Beat(name string, at time.Time) error {
+	return r.with(func(c *redisConn) error {
+		_, err := c.do("HSET", r.key("heartbeats"), name, at.Format(time.RFC3339Nano))
+		return err
+	})
+}
+
+func (r redisBackend) State() (*State, error) {
+	state := &State{Agents: make(map[string]*AgentState)}
+	err := r.with(func(c *redisConn) error {
+		fields, err := c.hgetall(r.key("agents"))
+		if err != nil {
+			return err
+		}
+		beats, err := c.hgetall(r.key("heartbeats"))
+		if err != nil {
+			return err
+		}
+		for name, data := range fields {
+			var s AgentState
+			if json.Unmarshal([]byte(data), &s) == nil {
+				s.Heartbeat, _ = time.Parse(time.RFC3339Nano, beats[name])
+				state.Agents[name] = &s
+			}
+		}
+		updated, err := c.do("GET", r.key("updated"))
+		state.LastUpdated, _ = updated.(string)
+		return err
+	})
+	return state, err
+}
+
+// Subscribe delivers messages as they are published, until stop is closed.
+func (r redisBackend) Subscribe(stop <-chan struct{}, fn func(Message)) error {
+	c, err := dialRedis(r.url)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	go func() {
+		<-stop
+		c.Close()
+	}()
+	if err := c.send("SUBSCRIBE", r.key("bus")); err != nil {
+		return err
+	}
+	c.SetDeadline(time.Time{}) // wait for messages indefinitely
+	for {
+		reply, err := c.read()
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return err
+			}
+		}
+		parts, _ := reply.([]interface{})
+		if len(parts) != 3 || parts[0] != "message" {
+			continue
+		}
+		var msg Message
+		if s, ok := parts[2].(string); ok && json.Unmarshal([]byte(s), &msg) == nil {
+			fn(msg)
+		}
+	}
+}
+
+// redisConn is a minimal RESP client connection: enough commands for the
+// backend without a client library.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// dialRedis connects to redis://[user:password@]host[:port][/db] (rediss://
+// for TLS), authenticating and selecting the database.
+func dialRedis(rawURL string) (*redisConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+		return nil, fmt.Errorf("invalid redis URL %q (want redis://host:6379/0)", rawURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if u.Scheme == "rediss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach redis at %s: %w", addr, err)
+	}
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn)}
+	if pass, ok := u.User.Password(); ok {
+		args := []string{"AUTH", pass}
+		if name := u.User.Username(); name != "" {
+			args = []string{"AUTH", name, pass}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" && db != "0" {
+		if _, err := c.do("SELECT", db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and reads its reply.
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// transaction runs commands in MULTI/EXEC after a WATCH. It reports false
+// if a watched key changed and nothing ran.
+func (c *redisConn) transaction(cmds ...[]string) (bool, error) {
+	if _, err := c.do("MULTI"); err != nil {
+		return false, err
+	}
+	for _, cmd := range cmds {
+		if _, err := c.do(cmd...); err != nil {
+			c.do("DISCARD")
+			return false, err
+		}
+	}
+	reply, err := c.do("EXEC")
+	return err == nil && reply != nil, err
+}
+
+func (c *redisConn) hgetall(key string) (map[string]string, error) {
+	reply, err := c.do("HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		k, _ := items[i].(string)
+		v, _ := items[i+1].(string)
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+func (c *redisConn) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	c.SetDeadline(time.Now().Add(10 * time.Second)) // for the reply too
+	_, err := io.WriteString(c.Conn, b.String())
+	return err
+}
+
+// read parses one reply: strings and bulk strings as string, integers as
+// int64, arrays as []interface{}, nulls as nil and errors as redisError.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
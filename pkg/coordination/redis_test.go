@@ -0,0 +1,287 @@
+package coordination
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-memory server speaking just enough RESP for the
+// backend: hashes, lists, strings, WATCH/MULTI/EXEC and pub/sub.
+type fakeRedis struct {
+	mu       sync.Mutex
+	hashes   map[string]map[string]string
+	lists    map[string][]string
+	strings  map[string]string
+	versions map[string]int
+	subs     map[string][]net.Conn
+}
+
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{
+		hashes: map[string]map[string]string{}, lists: map[string][]string{},
+		strings: map[string]string{}, versions: map[string]int{}, subs: map[string][]net.Conn{},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return "redis://" + ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	watched := map[string]int{}
+	var queued [][]string
+	inMulti := false
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(cmd[0])
+		switch {
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			io.WriteString(conn, "+OK\r\n")
+		case name == "EXEC":
+			f.mu.Lock()
+			stale := false
+			for k, v := range watched {
+				stale = stale || f.versions[k] != v
+			}
+			var replies []string
+			if !stale {
+				for _, q := range queued {
+					replies = append(replies, f.apply(q))
+				}
+			}
+			f.mu.Unlock()
+			inMulti, watched = false, map[string]int{}
+			if stale {
+				io.WriteString(conn, "*-1\r\n")
+			} else {
+				io.WriteString(conn, fmt.Sprintf("*%d\r\n%s", len(replies), strings.Join(replies, "")))
+			}
+		case inMulti:
+			queued = append(queued, cmd)
+			io.WriteString(conn, "+QUEUED\r\n")
+		case name == "WATCH":
+			f.mu.Lock()
+			for _, k := range cmd[1:] {
+				watched[k] = f.versions[k]
+			}
+			f.mu.Unlock()
+			io.WriteString(conn, "+OK\r\n")
+		case name == "UNWATCH":
+			watched = map[string]int{}
+			io.WriteString(conn, "+OK\r\n")
+		case name == "SUBSCRIBE":
+			f.mu.Lock()
+			f.subs[cmd[1]] = append(f.subs[cmd[1]], conn)
+			f.mu.Unlock()
+			io.WriteString(conn, "*3\r\n"+bulk("subscribe")+bulk(cmd[1])+":1\r\n")
+		default:
+			f.mu.Lock()
+			reply := f.apply(cmd)
+			f.mu.Unlock()
+			io.WriteString(conn, reply)
+		}
+	}
+}
+
+// apply runs one data command; the caller holds f.mu.
+func (f *fakeRedis) apply(cmd []string) string {
+	key := ""
+	if len(cmd) > 1 {
+		key = cmd[1]
+	}
+	h := f.hashes[key]
+	if h == nil {
+		h = map[string]string{}
+		f.hashes[key] = h
+	}
+	switch strings.ToUpper(cmd[0]) {
+	case "HSETNX":
+		if _, ok := h[cmd[2]]; ok {
+			return ":0\r\n"
+		}
+		h[cmd[2]] = cmd[3]
+		f.versions[key]++
+		return ":1\r\n"
+	case "HSET":
+		h[cmd[2]] = cmd[3]
+		f.versions[key]++
+		return ":1\r\n"
+	case "HGET":
+		if v, ok := h[cmd[2]]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "HDEL":
+		n := 0
+		for _, field := range cmd[2:] {
+			if _, ok := h[field]; ok {
+				delete(h, field)
+				n++
+			}
+		}
+		f.versions[key]++
+		return ":" + strconv.Itoa(n) + "\r\n"
+	case "HGETALL":
+		fields := make([]string, 0, len(h))
+		for k := range h {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		out := fmt.Sprintf("*%d\r\n", 2*len(fields))
+		for _, k := range fields {
+			out += bulk(k) + bulk(h[k])
+		}
+		return out
+	case "RPUSH":
+		f.lists[key] = append(f.lists[key], cmd[2:]...)
+		return ":" + strconv.Itoa(len(f.lists[key])) + "\r\n"
+	case "LRANGE":
+		out := fmt.Sprintf("*%d\r\n", len(f.lists[key]))
+		for _, v := range f.lists[key] {
+			out += bulk(v)
+		}
+		return out
+	case "SET":
+		f.strings[key] = cmd[2]
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := f.strings[key]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "PUBLISH":
+		for _, sub := range f.subs[key] {
+			io.WriteString(sub, "*3\r\n"+bulk("message")+bulk(key)+bulk(cmd[2]))
+		}
+		return ":" + strconv.Itoa(len(f.subs[key])) + "\r\n"
+	}
+	return "-ERR unknown command '" + cmd[0] + "'\r\n"
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisBackend(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	url := startFakeRedis(t)
+	os.MkdirAll(filepath.Join(tmpHome, ".agentctl"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, ".agentctl", "config.json"),
+		[]byte(`{"coordination":{"backend":"redis","url":"`+url+`"}}`), 0644)
+	repo := "https://github.com/org/api"
+
+	stop := make(chan struct{})
+	defer close(stop)
+	pushed := make(chan Message, 10)
+	go Subscribe(repo, stop, func(m Message) { pushed <- m })
+	time.Sleep(100 * time.Millisecond)
+
+	if err := ClaimFile(repo, "a1", "src/auth.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClaimFile(repo, "a1", "src/auth.go"); err != nil {
+		t.Errorf("re-claim by the holder: %v", err)
+	}
+	if err := ClaimFile(repo, "b1", "src/auth.go"); err == nil || !strings.Contains(err.Error(), "already claimed by agent a1") {
+		t.Errorf("claim of a held file = %v", err)
+	}
+	if err := ReleaseFile(repo, "b1", "src/auth.go"); err == nil {
+		t.Error("released another agent's claim")
+	}
+	ClaimFile(repo, "a1", "src/api.go")
+	ClaimFile(repo, "b1", "src/db.go")
+	if err := ReleaseFile(repo, "a1", "src/auth.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseAllForAgent(repo, "a1"); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ListClaims(repo)
+	if err != nil || len(claims) != 1 || claims["src/db.go"].Agent != "b1" {
+		t.Fatalf("claims = %+v, %v", claims, err)
+	}
+
+	UpdateAgentState(repo, "a1", "working", "feat/auth")
+	UpdateAgentState(repo, "b1", "done", "")
+	RemoveAgentState(repo, "b1")
+	state, err := GetState(repo)
+	if err != nil || len(state.Agents) != 1 || state.Agents["a1"].Branch != "feat/auth" || state.LastUpdated == "" {
+		t.Fatalf("state = %+v, %v", state, err)
+	}
+
+	Publish(repo, Message{Type: MsgPushed, Agent: "a1", Data: map[string]string{"branch": "feat/auth"}})
+	msgs, err := ReadMessages(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, m := range msgs {
+		types = append(types, string(m.Type))
+	}
+	if strings.Join(types, ",") != "claim,claim,claim,release,pushed" {
+		t.Errorf("messages = %v", types)
+	}
+
+	for i := 0; i < len(msgs); i++ {
+		select {
+		case m := <-pushed:
+			if m.Type != msgs[i].Type {
+				t.Errorf("pushed message %d = %s, want %s", i, m.Type, msgs[i].Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d messages pushed to the subscriber", i, len(msgs))
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package coordination
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// docs are the files that make up a repo's coordination state.
+var docs = []string{"claims.json", "state.json", "messages.jsonl", "heartbeats.json"}
+
+// errStale means the shared store moved on between pull and push.
+var errStale = errors.New("coordination store changed concurrently")
+
+// staleRetries bounds how often a write is re-applied after errStale.
+const staleRetries = 5
+
+// replica keeps a coordination directory in step with a store shared across
+// hosts. The directory stays the working copy everything reads and writes;
+// a replica only moves changes in and out of it.
+type replica interface {
+	// pull merges the store's changes into dir, keeping local ones.
+	pull(dir string) error
+	// push publishes dir's changes, or returns errStale if the store has
+	// changes dir hasn't seen.
+	push(dir, summary string) error
+}
+
+// replicaFor returns the configured replica for a repo's coordination
+// directory, or nil when the directory is the only copy (or unused).
+func replicaFor(repoURL string) (replica, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	switch c := cfg.Coordination; c.Backend {
+	case "", "file", "redis", "http":
+		return nil, nil
+	case "git":
+		branch := c.Branch
+		if branch == "" {
+			branch = DefaultBranch
+		}
+		return gitReplica{remote: remoteURL(repoURL), branch: branch}, nil
+	default:
+		return nil, fmt.Errorf("unknown coordination backend %q (want file, git, redis or http)", c.Backend)
+	}
+}
+
+// transact applies a change to a repo's coordination directory. With a
+// shared backend the directory is brought up to date first and the change
+// published after; if someone else published in between, the change is
+// undone and re-applied on top of theirs.
+func transact(repoURL, summary string, change func(dir string) error) error {
+	dir, err := CoordDir(repoURL)
+	if err != nil {
+		return err
+	}
+	r, err := replicaFor(repoURL)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return change(dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create coordination directory: %w", err)
+	}
+	for try := 0; ; try++ {
+		if err := r.pull(dir); err != nil {
+			return err
+		}
+		before := readDocs(dir)
+		if err := change(dir); err != nil {
+			return err
+		}
+		err := r.push(dir, summary)
+		if !errors.Is(err, errStale) || try == staleRetries {
+			return err
+		}
+		if err := writeDocs(dir, before); err != nil {
+			return err
+		}
+	}
+}
+
+// syncedDir returns a repo's coordination directory for reading, updated
+// from the shared backend when there is one. If the update fails the local
+// copy is still returned, with a warning: stale coordination data beats none.
+func syncedDir(repoURL string) (string, error) {
+	dir, err := CoordDir(repoURL)
+	if err != nil {
+		return "", err
+	}
+	r, err := replicaFor(repoURL)
+	if err != nil {
+		return "", err
+	}
+	if r != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("cannot create coordination directory: %w", err)
+		}
+		if err := r.pull(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: coordination sync failed, using local copy: %v\n", err)
+		}
+	}
+	return dir, nil
+}
+
+func readDocs(dir string) map[string][]byte {
+	m := make(map[string][]byte)
+	for _, name := range docs {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			m[name] = data
+		}
+	}
+	return m
+}
+
+func writeDocs(dir string, m map[string][]byte) error {
+	for _, name := range docs {
+		data, ok := m[name]
+		if !ok {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeInto three-way merges the store's docs (remote) into dir's, given the
+// docs both last agreed on (base).
+func mergeInto(dir string, base, remote map[string][]byte) error {
+	local := readDocs(dir)
+	merged := make(map[string][]byte)
+	for _, name := range docs {
+		data, err := mergeDoc(name, base[name], local[name], remote[name])
+		if err != nil {
+			return fmt.Errorf("merging %s: %w", name, err)
+		}
+		if data != nil {
+			merged[name] = data
+		}
+	}
+	return writeDocs(dir, merged)
+}
+
+// mergeDoc merges one doc. Messages are an append-only log: both sides'
+// new lines are kept. Claims, heartbeats and agent states merge per key; when both sides
+// changed the same key the store wins, so a claim taken elsewhere first
+// stays taken.
+func mergeDoc(name string, base, local, remote []byte) ([]byte, error) {
+	if name == "messages.jsonl" {
+		return mergeLines(base, local, remote), nil
+	}
+	if bytes.Equal(local, base) {
+		return remote, nil
+	}
+	if bytes.Equal(remote, base) {
+		return local, nil
+	}
+	if name == "claims.json" || name == "heartbeats.json" {
+		var b, l, r map[string]json.RawMessage
+		if err := unmarshalDocs([3][]byte{base, local, remote}, &b, &l, &r); err != nil {
+			return nil, err
+		}
+		return marshalDoc(mergeKeyed(b, l, r))
+	}
+	var b, l, r struct {
+		Agents      map[string]json.RawMessage `json:"agents"`
+		LastUpdated string                     `json:"last_updated"`
+	}
+	if err := unmarshalDocs([3][]byte{base, local, remote}, &b, &l, &r); err != nil {
+		return nil, err
+	}
+	r.Agents = mergeKeyed(b.Agents, l.Agents, r.Agents)
+	if l.LastUpdated > r.LastUpdated {
+		r.LastUpdated = l.LastUpdated
+	}
+	return marshalDoc(r)
+}
+
+// unmarshalDocs decodes the base, local and remote versions of a doc; a
+// missing or empty version decodes to nothing.
+func unmarshalDocs(data [3][]byte, base, local, remote interface{}) error {
+	for i, v := range []interface{}{base, local, remote} {
+		if len(bytes.TrimSpace(data[i])) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(data[i], v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalDoc(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// mergeKeyed applies the keys changed locally (set or deleted since base) on
+// top of remote, except where remote changed them too.
+func mergeKeyed(base, local, remote map[string]json.RawMessage) map[string]json.RawMessage {
+	merged := make(map[string]json.RawMessage, len(remote))
+	for k, v := range remote {
+		merged[k] = v
+	}
+	keys := make(map[string]bool)
+	for k := range base {
+		keys[k] = true
+	}
+	for k := range local {
+		keys[k] = true
+	}
+	for k := range keys {
+		b, inBase := base[k]
+		l, inLocal := local[k]
+		r, inRemote := remote[k]
+		if sameValue(b, inBase, l, inLocal) || !sameValue(b, inBase, r, inRemote) {
+			continue
+		}
+		if inLocal {
+			merged[k] = l
+		} else {
+			delete(merged, k)
+		}
+	}
+	return merged
+}
+
+func sameValue(a json.RawMessage, aOK bool, b json.RawMessage, bOK bool) bool {
+	if aOK != bOK {
+		return false
+	}
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// mergeLines keeps the remote log, drops lines removed locally, and appends
+// the lines added locally.
+func mergeLines(base, local, remote []byte) []byte {
+	inBase, inLocal, inRemote := lineSet(base), lineSet(local), lineSet(remote)
+	var b bytes.Buffer
+	for _, line := range splitLines(remote) {
+		if inBase[line] && !inLocal[line] {
+			continue
+		}
+		b.WriteString(line + "\n")
+	}
+	for _, line := range splitLines(local) {
+		if !inBase[line] && !inRemote[line] {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func splitLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func lineSet(data []byte) map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range splitLines(data) {
+		set[line] = true
+	}
+	return set
+}
@@ -0,0 +1,99 @@
+package coordination
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// MessageVersion is the version of the Message envelope Publish writes.
+// Messages from before versioning read as version 0.
+const MessageVersion = 1
+
+// ErrInvalidMessage is returned (wrapped) by Publish for a message of an
+// unknown type or whose data doesn't match its type's schema.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// builtinSchemas describes the types agentctl itself publishes. They are
+// open: agentctl and older scripts add fields freely.
+var builtinSchemas = map[MessageType]config.MessageSchema{
+	MsgClaim:           {Description: "a file was claimed", Optional: []string{"file"}, Open: true},
+	MsgRelease:         {Description: "a claim was released", Optional: []string{"file"}, Open: true},
+	MsgCommitted:       {Description: "an agent committed", Open: true},
+	MsgPushed:          {Description: "an agent pushed its branch", Optional: []string{"branch", "sha"}, Open: true},
+	MsgPRCreated:       {Description: "a PR was opened", Optional: []string{"url", "pr"}, Open: true},
+	MsgMerged:          {Description: "an agent's work was merged", Optional: []string{"base"}, Open: true},
+	MsgRebaseNeeded:    {Description: "agents should rebase", Optional: []string{"target"}, Open: true},
+	MsgEscalation:      {Description: "a pipeline needs a human", Optional: []string{"step", "error", "reason"}, Open: true},
+	MsgViolation:       {Description: "an agent broke the security policy", Open: true},
+	MsgFilesTouched:    {Description: "files an agent read and wrote", Optional: []string{"attempt", "read", "written"}, Open: true},
+	MsgAgentRemoved:    {Description: "an agent left the bus", Optional: []string{"reason", "released"}, Open: true},
+	MsgClaimConflict:   {Description: "an edit to another agent's file", Optional: []string{"file", "holder", "action"}, Open: true},
+	MsgIssueLabeled:    {Description: "forge: an issue was labelled", Open: true},
+	MsgReviewSubmitted: {Description: "forge: a review was submitted", Optional: []string{"target"}, Open: true},
+	MsgChecksCompleted: {Description: "forge: checks finished", Optional: []string{"target"}, Open: true},
+}
+
+// MessageSchemas returns the schema of every known message type: the
+// built-in ones and those registered in the config, which win.
+func MessageSchemas() map[MessageType]config.MessageSchema {
+	schemas := map[MessageType]config.MessageSchema{}
+	for t, s := range builtinSchemas {
+		schemas[t] = s
+	}
+	if cfg, err := config.Load(); err == nil {
+		for t, s := range cfg.Coordination.MessageTypes {
+			schemas[MessageType(t)] = s
+		}
+	}
+	return schemas
+}
+
+// ValidateMessage checks msg against its type's schema.
+func ValidateMessage(msg Message) error {
+	return validateMessage(MessageSchemas(), msg)
+}
+
+func validateMessage(schemas map[MessageType]config.MessageSchema, msg Message) error {
+	schema, ok := schemas[msg.Type]
+	if !ok {
+		var known []string
+		for t := range schemas {
+			known = append(known, string(t))
+		}
+		sort.Strings(known)
+		return fmt.Errorf("%w: unknown type %q (known: %s; register it under coordination.message_types)",
+			ErrInvalidMessage, msg.Type, strings.Join(known, ", "))
+	}
+	var missing []string
+	for _, f := range schema.Required {
+		if msg.Data[f] == "" {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s needs %s", ErrInvalidMessage, msg.Type, strings.Join(missing, ", "))
+	}
+	if schema.Open {
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, f := range append(append([]string(nil), schema.Required...), schema.Optional...) {
+		allowed[f] = true
+	}
+	var unknown []string
+	for f := range msg.Data {
+		if !allowed[f] {
+			unknown = append(unknown, f)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: %s has no field %s (fields: %s)", ErrInvalidMessage, msg.Type,
+			strings.Join(unknown, ", "), strings.Join(append(append([]string(nil), schema.Required...), schema.Optional...), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package coordination
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+func TestValidateMessage(t *testing.T) {
+	schemas := map[MessageType]config.MessageSchema{
+		MsgPushed:        builtinSchemas[MsgPushed],
+		"deploy_started": {Required: []string{"env"}, Optional: []string{"sha"}},
+	}
+	for _, tc := range []struct {
+		msg  Message
+		want string // in the error; "" for valid
+	}{
+		{Message{Type: MsgPushed, Data: map[string]string{"branch": "fix", "anything": "goes"}}, ""},
+		{Message{Type: "deploy_started", Data: map[string]string{"env": "prod", "sha": "abc"}}, ""},
+		{Message{Type: "pushd"}, `unknown type "pushd" (known: deploy_started, pushed;`},
+		{Message{Type: "deploy_started", Data: map[string]string{"sha": "abc"}}, "deploy_started needs env"},
+		{Message{Type: "deploy_started", Data: map[string]string{"env": "prod", "shaa": "abc"}}, "has no field shaa"},
+	} {
+		err := validateMessage(schemas, tc.msg)
+		if tc.want == "" && err != nil {
+			t.Errorf("%+v: %v", tc.msg, err)
+		}
+		if tc.want != "" && (!errors.Is(err, ErrInvalidMessage) || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("%+v: error %v, want %q", tc.msg, err, tc.want)
+		}
+	}
+}
+
+func TestPublishValidates(t *testing.T) {
+	repoURL, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	if err := Publish(repoURL, Message{Type: "pushd", Agent: "a"}); !errors.Is(err, ErrInvalidMessage) {
+		t.Fatalf("Publish of an unknown type = %v", err)
+	}
+	if err := PublishUnchecked(repoURL, Message{Type: "pushd", Agent: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Publish(repoURL, Message{Type: MsgPushed, Agent: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ := ReadMessages(repoURL)
+	if len(msgs) != 2 || msgs[0].Type != "pushd" || msgs[1].Version != MessageVersion {
+		t.Errorf("bus = %+v", msgs)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,8 @@ type AgentState struct {
 	Branch     string    `json:"branch,omitempty"`
 	Status     string    `json:"status"` // "working", "idle", "done", "blocked"
 	LastUpdate time.Time `json:"last_update"`
+	// Heartbeat is the run loop's last beat; see Heartbeat.
+	Heartbeat time.Time `json:"heartbeat,omitempty"`
 }
 
 // State represents the shared coordination state for a repo.
@@ -24,52 +28,74 @@ type State struct {
 
 // UpdateAgentState updates an agent's state in the shared state file.
 func UpdateAgentState(repoURL, agentName, status, branch string) error {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return err
 	}
-
-	state, err := loadState(dir)
-	if err != nil {
-		return err
-	}
-
-	state.Agents[agentName] = &AgentState{
+	return b.UpdateAgent(&AgentState{
 		Name:       agentName,
 		Branch:     branch,
 		Status:     status,
 		LastUpdate: time.Now(),
-	}
-	state.LastUpdated = time.Now().Format(time.RFC3339)
-
-	return saveState(dir, state)
+	})
 }
 
 // RemoveAgentState removes an agent from the shared state.
 func RemoveAgentState(repoURL, agentName string) error {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return err
 	}
+	return b.RemoveAgent(agentName)
+}
 
-	state, err := loadState(dir)
+// RemoveAgent takes a departing agent off the bus: it releases every claim
+// the agent holds, drops its state entry and publishes agent_removed with
+// the reason and the released files. It returns the released files; an
+// agent that was never on the bus is left alone.
+func RemoveAgent(repoURL, agentName, reason string) ([]string, error) {
+	b, err := backendFor(repoURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	delete(state.Agents, agentName)
-	state.LastUpdated = time.Now().Format(time.RFC3339)
-
-	return saveState(dir, state)
+	claims, err := b.Claims()
+	if err != nil {
+		return nil, err
+	}
+	var released []string
+	for file, c := range claims {
+		if c.Agent == agentName {
+			released = append(released, file)
+		}
+	}
+	sort.Strings(released)
+	state, err := b.State()
+	if err != nil {
+		return nil, err
+	}
+	if _, listed := state.Agents[agentName]; !listed && len(released) == 0 {
+		return nil, nil // never on the bus
+	}
+	if err := b.ReleaseAll(agentName); err != nil {
+		return nil, err
+	}
+	if err := b.RemoveAgent(agentName); err != nil {
+		return released, err
+	}
+	data := map[string]string{"reason": reason}
+	if len(released) > 0 {
+		data["released"] = strings.Join(released, ",")
+	}
+	return released, b.Publish(Message{Version: MessageVersion, Type: MsgAgentRemoved, Agent: agentName, Timestamp: time.Now(), Data: data})
 }
 
 // GetState returns the current coordination state.
 func GetState(repoURL string) (*State, error) {
-	dir, err := CoordDir(repoURL)
+	b, err := backendFor(repoURL)
 	if err != nil {
 		return nil, err
 	}
-	return loadState(dir)
+	return b.State()
 }
 
 func loadState(dir string) (*State, error) {
@@ -100,5 +126,5 @@ func saveState(dir string, state *State) error {
 		return fmt.Errorf("cannot marshal state: %w", err)
 	}
 	data = append(data, '\n')
-	return os.WriteFile(statePath, data, 0644)
+	return writeFileAtomic(statePath, data)
 }
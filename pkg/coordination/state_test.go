@@ -2,6 +2,7 @@ package coordination
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -134,3 +135,56 @@ func TestStateLastUpdated(t *testing.T) {
 		t.Error("LastUpdated should be set after update")
 	}
 }
+
+func TestRemoveAgent(t *testing.T) {
+	repoURL := "https://github.com/test/" + t.Name()
+	dir, err := Init(repoURL)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	UpdateAgentState(repoURL, "agent-1", "working", "branch-1")
+	UpdateAgentState(repoURL, "agent-2", "working", "branch-2")
+	ClaimFile(repoURL, "agent-1", "b.go")
+	ClaimFile(repoURL, "agent-1", "a.go")
+	ClaimFile(repoURL, "agent-2", "c.go")
+
+	released, err := RemoveAgent(repoURL, "agent-1", "killed")
+	if err != nil {
+		t.Fatalf("RemoveAgent failed: %v", err)
+	}
+	if strings.Join(released, ",") != "a.go,b.go" {
+		t.Errorf("released = %v, want [a.go b.go]", released)
+	}
+
+	claims, _ := ListClaims(repoURL)
+	if len(claims) != 1 || claims["c.go"].Agent != "agent-2" {
+		t.Errorf("claims = %v, want only agent-2's c.go", claims)
+	}
+	state, _ := GetState(repoURL)
+	if _, ok := state.Agents["agent-1"]; ok {
+		t.Error("agent-1 should have been removed from state")
+	}
+	if _, ok := state.Agents["agent-2"]; !ok {
+		t.Error("agent-2 should still be in state")
+	}
+
+	msgs, _ := ReadMessages(repoURL)
+	if len(msgs) == 0 {
+		t.Fatal("expected an agent_removed message")
+	}
+	m := msgs[len(msgs)-1]
+	if m.Type != MsgAgentRemoved || m.Agent != "agent-1" || m.Data["reason"] != "killed" || m.Data["released"] != "a.go,b.go" {
+		t.Errorf("unexpected message %+v", m)
+	}
+
+	// An agent that was never on the bus is left alone.
+	released, err = RemoveAgent(repoURL, "agent-3", "killed")
+	if err != nil || released != nil {
+		t.Errorf("RemoveAgent(agent-3) = %v, %v; want nil, nil", released, err)
+	}
+	if after, _ := ReadMessages(repoURL); len(after) != len(msgs) {
+		t.Errorf("expected no new message for agent-3, got %d", len(after)-len(msgs))
+	}
+}
@@ -0,0 +1,127 @@
+package coordination
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FilesTouched is the read and write set of an agent.
+type FilesTouched struct {
+	Agent   string
+	Attempt int
+	Read    []string
+	Written []string
+}
+
+// PublishFilesTouched puts an attempt's file-touch manifest on the bus.
+// Paths are repo-relative and comma-separated in the message data.
+func PublishFilesTouched(repoURL string, ft FilesTouched) error {
+	return Publish(repoURL, Message{
+		Type:  MsgFilesTouched,
+		Agent: ft.Agent,
+		Data: map[string]string{
+			"attempt": strconv.Itoa(ft.Attempt),
+			"read":    strings.Join(ft.Read, ","),
+			"written": strings.Join(ft.Written, ","),
+		},
+	})
+}
+
+// TouchedFiles merges every files_touched message on the bus into one
+// manifest per agent. Attempt is the latest attempt reported.
+func TouchedFiles(repoURL string) (map[string]*FilesTouched, error) {
+	msgs, err := ReadMessages(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return mergeTouched(msgs), nil
+}
+
+func mergeTouched(msgs []Message) map[string]*FilesTouched {
+	read := map[string]map[string]bool{}
+	written := map[string]map[string]bool{}
+	touched := map[string]*FilesTouched{}
+	for _, m := range msgs {
+		if m.Type != MsgFilesTouched {
+			continue
+		}
+		ft, ok := touched[m.Agent]
+		if !ok {
+			ft = &FilesTouched{Agent: m.Agent}
+			touched[m.Agent] = ft
+			read[m.Agent], written[m.Agent] = map[string]bool{}, map[string]bool{}
+		}
+		if n, err := strconv.Atoi(m.Data["attempt"]); err == nil && n > ft.Attempt {
+			ft.Attempt = n
+		}
+		addList(read[m.Agent], m.Data["read"])
+		addList(written[m.Agent], m.Data["written"])
+	}
+	for name, ft := range touched {
+		ft.Read, ft.Written = sortedKeys(read[name]), sortedKeys(written[name])
+	}
+	return touched
+}
+
+// Overlap is a file written by one agent and touched by another.
+type Overlap struct {
+	File    string
+	Writers []string
+	Readers []string // agents that only read it
+}
+
+// TouchOverlaps returns the files that more than one agent touched where at
+// least one of them wrote it — the real conflicts, whatever was claimed.
+func TouchOverlaps(touched map[string]*FilesTouched) []Overlap {
+	writers, readers := map[string][]string{}, map[string][]string{}
+	for name, ft := range touched {
+		for _, f := range ft.Written {
+			writers[f] = append(writers[f], name)
+		}
+	}
+	for name, ft := range touched {
+		for _, f := range ft.Read {
+			if len(writers[f]) > 0 && !hasString(writers[f], name) {
+				readers[f] = append(readers[f], name)
+			}
+		}
+	}
+	var overlaps []Overlap
+	for f, w := range writers {
+		if len(w)+len(readers[f]) < 2 {
+			continue
+		}
+		sort.Strings(w)
+		sort.Strings(readers[f])
+		overlaps = append(overlaps, Overlap{File: f, Writers: w, Readers: readers[f]})
+	}
+	sort.Slice(overlaps, func(i, j int) bool { return overlaps[i].File < overlaps[j].File })
+	return overlaps
+}
+
+func addList(set map[string]bool, list string) {
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			set[f] = true
+		}
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
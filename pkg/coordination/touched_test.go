@@ -0,0 +1,42 @@
+package coordination
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTouchedFiles(t *testing.T) {
+	repoURL := "https://github.com/test/" + t.Name()
+	dir, err := Init(repoURL)
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, ft := range []FilesTouched{
+		{Agent: "agent-1", Attempt: 1, Read: []string{"go.mod"}, Written: []string{"pkg/auth/token.go"}},
+		{Agent: "agent-1", Attempt: 2, Written: []string{"pkg/auth/token_test.go"}},
+		{Agent: "agent-2", Attempt: 1, Read: []string{"pkg/auth/token.go", "go.mod"}, Written: []string{"pkg/api/routes.go"}},
+		{Agent: "agent-3", Attempt: 1, Written: []string{"pkg/auth/token.go"}},
+	} {
+		if err := PublishFilesTouched(repoURL, ft); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Publish(repoURL, Message{Type: MsgPushed, Agent: "agent-1"})
+
+	touched, err := TouchedFiles(repoURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a1 := touched["agent-1"]
+	if a1 == nil || a1.Attempt != 2 || !reflect.DeepEqual(a1.Written, []string{"pkg/auth/token.go", "pkg/auth/token_test.go"}) || !reflect.DeepEqual(a1.Read, []string{"go.mod"}) {
+		t.Errorf("agent-1 = %+v", a1)
+	}
+
+	want := []Overlap{{File: "pkg/auth/token.go", Writers: []string{"agent-1", "agent-3"}, Readers: []string{"agent-2"}}}
+	if got := TouchOverlaps(touched); !reflect.DeepEqual(got, want) {
+		t.Errorf("TouchOverlaps() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,319 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/board"
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/container"
+)
+
+// API roles. Spectators can list agents, spy on them and read history;
+// spawning, killing and merging need an admin.
+const (
+	RoleAdmin     = "admin"
+	RoleSpectator = "spectator"
+)
+
+// caller is the identity behind an API request.
+type caller struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// authenticate resolves the request's bearer token. With no users configured
+// every caller on this host is an anonymous spectator, and callers from
+// elsewhere are refused: spy streams whole transcripts, tokens and all.
+func (s *Server) authenticate(r *http.Request) (caller, bool) {
+	users := s.cfg.Daemon.Users
+	if len(users) == 0 {
+		if !fromLoopback(r) {
+			return caller{}, false
+		}
+		return caller{Name: "anonymous", Role: RoleSpectator}, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return caller{}, false
+	}
+	for _, u := range users {
+		if u.Token != "" && subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+			return caller{Name: u.Name, Role: u.Role}, true
+		}
+	}
+	return caller{}, false
+}
+
+// fromLoopback reports whether the request came from this host.
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func validRole(role string) bool {
+	return role == RoleAdmin || role == RoleSpectator
+}
+
+// handleAPI routes /api/ requests:
+//
+//	GET    /api/whoami
+//	GET    /api/agents               list with lifecycle state
+//	POST   /api/agents               spawn (admin)
+//	GET    /api/agents/<name>        metadata and state
+//	DELETE /api/agents/<name>        kill (admin)
+//	GET    /api/agents/<name>/spy    stream session activity
+//	POST   /api/agents/<name>/merge  merge the agent's PR (admin)
+//	GET    /api/history              finished agents
+//	GET    /api/board[?format=html]  fleet progress board
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	who, ok := s.authenticate(r)
+	if !ok && len(s.cfg.Daemon.Users) == 0 {
+		http.Error(w, "the API only answers this host until daemon.users is configured", http.StatusForbidden)
+		return
+	}
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"), "/")
+	route := parts[0]
+	name, action := "", ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		action = parts[2]
+	}
+	if len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Everything that changes state is admin-only.
+	if r.Method != http.MethodGet && who.Role != RoleAdmin {
+		http.Error(w, fmt.Sprintf("forbidden: %s is a %s", who.Name, who.Role), http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case route == "whoami" && name == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, who)
+	case route == "history" && name == "" && r.Method == http.MethodGet:
+		s.apiHistory(w)
+	case route == "board" && name == "" && r.Method == http.MethodGet:
+		s.apiBoard(w, r)
+	case route == "agents" && name == "" && r.Method == http.MethodGet:
+		s.apiList(w)
+	case route == "agents" && name == "" && r.Method == http.MethodPost:
+		s.apiSpawn(w, r, who)
+	case route == "agents" && action == "" && r.Method == http.MethodGet:
+		s.apiAgent(w, name)
+	case route == "agents" && action == "" && r.Method == http.MethodDelete:
+		s.apiKill(w, name, who)
+	case route == "agents" && action == "spy" && r.Method == http.MethodGet:
+		s.apiSpy(w, r, name)
+	case route == "agents" && action == "merge" && r.Method == http.MethodPost:
+		s.apiMerge(w, name, who)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) apiList(w http.ResponseWriter) {
+	agents, err := container.ListWithStateCached()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if agents == nil {
+		agents = []*container.AgentWithState{}
+	}
+	writeJSON(w, http.StatusOK, agents)
+}
+
+func (s *Server) apiHistory(w http.ResponseWriter) {
+	records, err := container.ListHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if records == nil {
+		records = []*container.AgentHistory{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (s *Server) apiBoard(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	b, err := board.Collect(r.URL.Query().Get("repo"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out, err := board.Render(b, format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch format {
+	case board.FormatHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	fmt.Fprint(w, out)
+}
+
+func (s *Server) apiAgent(w http.ResponseWriter, name string) {
+	agents, err := container.ListWithStateCached()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, a := range agents {
+		if a.Name == name {
+			writeJSON(w, http.StatusOK, a)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("agent not found: %s", name))
+}
+
+// apiSpawn starts the spawn in the background (cloning and setup take
+// minutes) and answers 202.
+func (s *Server) apiSpawn(w http.ResponseWriter, r *http.Request, who caller) {
+	var req struct {
+		Name   string `json:"name"`
+		Repo   string `json:"repo"`
+		Branch string `json:"branch"`
+		Image  string `json:"image"`
+		Intent string `json:"intent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if req.Name == "" || req.Repo == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("name and repo are required"))
+		return
+	}
+	if _, err := container.LoadAgent(req.Name); err == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("agent %s already exists", req.Name))
+		return
+	}
+	fmt.Printf("🔐 %s: spawn %s (%s)\n", who.Name, req.Name, req.Repo)
+	go func() {
+		opts := container.SpawnOptions{Name: req.Name, Repo: req.Repo, Branch: req.Branch, Image: req.Image, Intent: req.Intent}
+		if _, err := container.SpawnWithOptions(opts); err != nil {
+			fmt.Printf("❌ spawn %s failed: %v\n", req.Name, err)
+			return
+		}
+		fmt.Printf("✅ spawned %s\n", req.Name)
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "spawning", "name": req.Name})
+}
+
+func (s *Server) apiKill(w http.ResponseWriter, name string, who caller) {
+	if _, err := container.LoadAgent(name); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	fmt.Printf("🔐 %s: kill %s\n", who.Name, name)
+	if err := container.Cleanup(name, "killed", 0, map[string]string{"killed_by": who.Name}); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "killed", "name": name})
+}
+
+func (s *Server) apiMerge(w http.ResponseWriter, name string, who caller) {
+	if _, err := container.LoadAgent(name); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	fmt.Printf("🔐 %s: merge %s\n", who.Name, name)
+	out, err := container.MergePR(name)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "merged", "name": name, "output": out})
+}
+
+// apiSpy streams rendered session activity as plain text until the client
+// disconnects. ?tools, ?thinking, ?verbose and ?diffs mirror the CLI flags.
+func (s *Server) apiSpy(w http.ResponseWriter, r *http.Request, name string) {
+	if _, err := container.LoadAgent(name); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	q := r.URL.Query()
+	opts := container.SpyOptions{
+		NoColor:   true,
+		ToolsOnly: q.Has("tools"),
+		Thinking:  q.Has("thinking"),
+		Verbose:   q.Has("verbose"),
+		Diffs:     q.Has("diffs"),
+		JSON:      q.Has("json"),
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	out := &flushWriter{w: w}
+	if f, ok := w.(http.Flusher); ok {
+		out.f = f
+	}
+	if err := container.SpyStream(r.Context(), name, out, out, opts); err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+	}
+}
+
+// flushWriter pushes each rendered line to the client immediately.
+type flushWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	return fw.w.Write(p)
+}
+
+func (fw *flushWriter) Flush() {
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+}
+
+// userProblems reports misconfigured API users.
+func userProblems(users []config.DaemonUser) []string {
+	var problems []string
+	for i, u := range users {
+		if u.Token == "" {
+			problems = append(problems, fmt.Sprintf("user %d (%s): no token", i+1, u.Name))
+		}
+		if !validRole(u.Role) {
+			problems = append(problems, fmt.Sprintf("user %d (%s): unknown role %q (want admin or spectator)", i+1, u.Name, u.Role))
+		}
+	}
+	return problems
+}
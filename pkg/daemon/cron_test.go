@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday 2024-01-31 10:17.
+	from := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"@hourly", "2024-01-31 11:00"},
+		{"@nightly", "2024-02-01 02:00"},
+		{"@weekly", "2024-02-04 00:00"},
+		{"@monthly", "2024-02-01 00:00"},
+		{"*/15 * * * *", "2024-01-31 10:30"},
+		{"0,45 9-17 * * 1-5", "2024-01-31 10:45"},
+		{"30 3 * * 7", "2024-02-04 03:30"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		{"0 0 31 * *", "2024-03-31 00:00"},
+		// Both day fields restricted: the 15th or any Monday.
+		{"0 8 15 * 1", "2024-02-05 08:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCron() error: %v", err)
+			}
+			if got := c.Next(from).Format("2006-01-02 15:04"); got != tt.want {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly", "0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) should fail", spec)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package daemon implements `agentctl serve`, a long-running process that
+// routes events — coordination bus messages and forge webhooks — to the
+// named pipelines configured as triggers, and serves an HTTP API for the
+// fleet with admin and read-only spectator roles.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/container"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// Defaults for config.Daemon.
+const (
+	DefaultAddr         = ":8090"
+	DefaultPollInterval = 5 * time.Second
+)
+
+// Server is the daemon: an HTTP listener plus bus watchers feeding Dispatch.
+type Server struct {
+	cfg        *config.Config
+	deliveries deliveries
+	sched      scheduler
+	notify     *notifier
+
+	// Launch starts the pipeline for a fired trigger. It defaults to running
+	// `agentctl pipeline run` in the background with output in LogDir.
+	Launch func(t config.Trigger, params map[string]string) error
+	// LaunchSchedule starts a due schedule. It defaults to running
+	// `agentctl schedule run` in the background with output in LogDir.
+	LaunchSchedule func(sc config.Schedule) error
+	// LogDir receives one log file per launched pipeline.
+	LogDir string
+}
+
+// New creates a daemon for the given config.
+func New(cfg *config.Config) *Server {
+	s := &Server{cfg: cfg, LogDir: filepath.Join(config.Dir(), "daemon", "logs"), notify: newNotifier(cfg.Notifications)}
+	s.Launch = s.launchPipeline
+	s.LaunchSchedule = s.launchSchedule
+	return s
+}
+
+// Check reports trigger and schedule definitions that can never fire and
+// misconfigured API users.
+func (s *Server) Check() []string {
+	problems := append(userProblems(s.cfg.Daemon.Users), scheduleProblems(s.cfg)...)
+	problems = append(problems, notificationProblems(s.cfg.Notifications)...)
+	for i, t := range s.cfg.Triggers {
+		switch {
+		case strings.HasPrefix(t.On, "bus:"):
+			if t.Repo == "" {
+				problems = append(problems, fmt.Sprintf("trigger %d (%s): bus triggers need a repo", i+1, t.On))
+			}
+		case strings.HasPrefix(t.On, "github:"):
+		default:
+			problems = append(problems, fmt.Sprintf("trigger %d: unknown event %q (want bus:<type> or github:<event>)", i+1, t.On))
+		}
+		if _, ok := s.cfg.Pipelines[t.Pipeline]; !ok {
+			problems = append(problems, fmt.Sprintf("trigger %d (%s): no pipeline named %q", i+1, t.On, t.Pipeline))
+		}
+	}
+	return problems
+}
+
+// Handler returns the daemon's HTTP routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/webhooks/github", s.handleGitHub)
+	mux.HandleFunc("/api/", s.handleAPI)
+	return mux
+}
+
+// Run serves HTTP on addr, watches the bus of every repo with a bus trigger
+// and fires scheduled runs until ctx is cancelled.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = s.cfg.Daemon.Addr
+	}
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	interval := DefaultPollInterval
+	if d, err := time.ParseDuration(s.cfg.Daemon.PollInterval); err == nil && d > 0 {
+		interval = d
+	}
+
+	for _, repo := range s.BusRepos() {
+		if _, err := coordination.Init(repo); err != nil {
+			return err
+		}
+		fmt.Printf("👂 Watching bus for %s\n", repo)
+		go s.watchBus(ctx, repo, interval)
+		go s.relayPushes(ctx, repo, interval)
+		if beat := coordination.HeartbeatInterval(); beat > 0 {
+			go s.reapStale(ctx, repo, beat)
+		}
+	}
+
+	if s.cfg.Idle.Action == container.IdlePause {
+		go s.sweepIdle(ctx, time.Minute)
+	}
+
+	if s.cfg.Spy.Record {
+		fmt.Printf("🎙️  Recording agent sessions to %s\n", container.SpyStorePath())
+		go s.recordSpy(ctx, 30*time.Second)
+	}
+
+	if len(s.cfg.Schedules) > 0 {
+		fmt.Printf("⏰ %d schedule(s) active\n", len(s.cfg.Schedules))
+		go s.runScheduler(ctx)
+	}
+	if len(s.cfg.Notifications) > 0 {
+		fmt.Printf("📬 %d notification channel(s)\n", len(s.cfg.Notifications))
+	}
+
+	srv := &http.Server{Addr: addr, Handler: s.Handler()}
+	go func() {
+		<-ctx.Done()
+		s.notify.Flush()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	fmt.Printf("🛰️  agentctl daemon listening on %s (%d triggers)\n", addr, len(s.cfg.Triggers))
+	if len(s.cfg.Daemon.Users) == 0 {
+		fmt.Println("🔓 API is read-only and answers this host only (configure daemon.users for tokens, remote and admin access)")
+	}
+	if s.cfg.Daemon.WebhookSecret == "" {
+		fmt.Println("⚠️  No daemon.webhook_secret: /webhooks/github refuses every delivery")
+	}
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// BusRepos returns the repos whose coordination bus has triggers or
+// notification channels, sorted.
+func (s *Server) BusRepos() []string {
+	seen := map[string]bool{}
+	var repos []string
+	for _, t := range s.cfg.Triggers {
+		if strings.HasPrefix(t.On, "bus:") && t.Repo != "" && !seen[t.Repo] {
+			seen[t.Repo] = true
+			repos = append(repos, t.Repo)
+		}
+	}
+	for _, n := range s.cfg.Notifications {
+		for _, repo := range n.Repos {
+			if !seen[repo] {
+				seen[repo] = true
+				repos = append(repos, repo)
+			}
+		}
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// Dispatch fires every trigger matching e and returns how many fired. Bus
+// events also go to the notification channels.
+func (s *Server) Dispatch(e Event) int {
+	s.notify.Notify(e)
+	fired := 0
+	for _, t := range Match(s.cfg.Triggers, e) {
+		params := Render(t, e)
+		fmt.Printf("⚡ %s → pipeline %s\n", e.Key(), t.Pipeline)
+		if err := s.Launch(t, params); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  trigger %s → %s failed to start: %v\n", t.On, t.Pipeline, err)
+			continue
+		}
+		fired++
+	}
+	return fired
+}
+
+// watchBus dispatches messages published to a repo's bus after the daemon
+// started, as the backend pushes them or else by polling.
+func (s *Server) watchBus(ctx context.Context, repo string, interval time.Duration) {
+	since := time.Now()
+	err := coordination.Subscribe(repo, ctx.Done(), func(msg coordination.Message) {
+		s.Dispatch(busEvent(repo, msg))
+	})
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	if !errors.Is(err, coordination.ErrNoSubscribe) {
+		fmt.Fprintf(os.Stderr, "⚠️  bus subscription for %s failed, polling instead: %v\n", repo, err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			since = s.pollBus(repo, since)
+		}
+	}
+}
+
+// reapStale takes agents whose run loop stopped heartbeating off repo's bus
+// every interval, freeing their claims; the agent_removed message reaches
+// triggers and channels like any other.
+func (s *Server) reapStale(ctx context.Context, repo string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reaped, err := coordination.ReapStale(repo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  heartbeat check for %s failed: %v\n", repo, err)
+		}
+		for _, name := range reaped {
+			fmt.Printf("💀 %s stopped heartbeating; removed it from the %s bus\n", name, repo)
+		}
+	}
+}
+
+// relayPushes publishes the pushes agents' git hooks record on repo's bus,
+// every interval, so bus triggers see them while no run loop is going.
+func (s *Server) relayPushes(ctx context.Context, repo string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := coordination.RelayPushes(repo); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  relaying pushes for %s failed: %v\n", repo, err)
+		}
+	}
+}
+
+// sweepIdle pauses agents that went quiet mid-attempt, every interval.
+func (s *Server) sweepIdle(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		paused, err := container.SweepIdle()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  idle check failed: %v\n", err)
+		}
+		for _, name := range paused {
+			fmt.Printf("💤 %s went idle; paused it\n", name)
+		}
+	}
+}
+
+// recordSpy appends new session events of the running agents to the spy
+// store every interval.
+func (s *Server) recordSpy(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := container.RecordSessions(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  recording sessions failed: %v\n", err)
+		}
+	}
+}
+
+// pollBus dispatches messages newer than since and returns the new high-water mark.
+func (s *Server) pollBus(repo string, since time.Time) time.Time {
+	msgs, err := coordination.ReadMessagesSince(repo, since)
+	if err != nil {
+		return since
+	}
+	for _, msg := range msgs {
+		if msg.Timestamp.After(since) {
+			since = msg.Timestamp
+		}
+		s.Dispatch(busEvent(repo, msg))
+	}
+	return since
+}
+
+func busEvent(repo string, msg coordination.Message) Event {
+	fields := map[string]string{"repo": repo, "agent": msg.Agent, "type": string(msg.Type)}
+	for k, v := range msg.Data {
+		fields[k] = v
+	}
+	return Event{Source: "bus", Type: string(msg.Type), Repo: repo, Fields: fields}
+}
+
+// launchPipeline runs `agentctl pipeline run` for the trigger in the
+// background, logging to a file in LogDir.
+func (s *Server) launchPipeline(t config.Trigger, params map[string]string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.LogDir, 0755); err != nil {
+		return err
+	}
+	logPath := filepath.Join(s.LogDir, fmt.Sprintf("%s-%s.log", t.Pipeline, time.Now().Format("20060102-150405.000")))
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"pipeline", "run", t.Pipeline}
+	if ns := namespace.Current(); ns != namespace.Default {
+		args = append([]string{"--namespace", ns}, args...)
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--"+k+"="+params[k])
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+	fmt.Printf("   📝 %s\n", logPath)
+	go func() {
+		err := cmd.Wait()
+		logFile.Close()
+		if err != nil {
+			fmt.Printf("❌ pipeline %s failed: %v (see %s)\n", t.Pipeline, err, logPath)
+			return
+		}
+		fmt.Printf("✅ pipeline %s complete\n", t.Pipeline)
+	}()
+	return nil
+}
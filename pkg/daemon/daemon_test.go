@@ -0,0 +1,451 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+const labeledPayload = `{
+	"action": "labeled",
+	"label": {"name": "agent-ok"},
+	"issue": {"number": 42, "title": "Add rate limiting"},
+	"repository": {"full_name": "org/api", "html_url": "https://github.com/org/api"},
+	"sender": {"login": "octocat"}
+}`
+
+func TestMatches(t *testing.T) {
+	e := Event{Source: "github", Type: "issues.labeled", Repo: "https://github.com/org/api", Fields: map[string]string{"label": "agent-ok"}}
+	tests := []struct {
+		name    string
+		trigger config.Trigger
+		want    bool
+	}{
+		{"exact", config.Trigger{On: "github:issues.labeled"}, true},
+		{"any action", config.Trigger{On: "github:issues"}, true},
+		{"other event", config.Trigger{On: "github:pull_request"}, false},
+		{"label match", config.Trigger{On: "github:issues.labeled", Label: "agent-ok"}, true},
+		{"label mismatch", config.Trigger{On: "github:issues.labeled", Label: "wontfix"}, false},
+		{"repo slug", config.Trigger{On: "github:issues.labeled", Repo: "org/api"}, true},
+		{"repo mismatch", config.Trigger{On: "github:issues.labeled", Repo: "org/web"}, false},
+		{"bus", config.Trigger{On: "bus:issues.labeled"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(tt.trigger, e); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	e := Event{Repo: "https://github.com/org/api", Fields: map[string]string{"issue": "42", "title": "Add rate limiting"}}
+	tr := config.Trigger{Params: map[string]string{"issue": "{{issue}}", "task": "Fix #{{ issue }}: {{title}}{{missing}}"}}
+	got := Render(tr, e)
+	if got["issue"] != "42" || got["task"] != "Fix #42: Add rate limiting" {
+		t.Errorf("Render() = %v", got)
+	}
+	if got["repo"] != "https://github.com/org/api" {
+		t.Errorf("repo should default to the event's repo, got %q", got["repo"])
+	}
+}
+
+func TestParseGitHubEvent(t *testing.T) {
+	e, err := ParseGitHubEvent("issues", []byte(labeledPayload))
+	if err != nil {
+		t.Fatalf("ParseGitHubEvent() error: %v", err)
+	}
+	if e.Key() != "github:issues.labeled" || e.Fields["label"] != "agent-ok" || e.Fields["issue"] != "42" {
+		t.Errorf("event = %+v", e)
+	}
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	if !VerifySignature("s3cret", []byte("body"), sign("s3cret", "body")) {
+		t.Error("valid signature rejected")
+	}
+	for _, header := range []string{"", "sha1=abc", "sha256=zz", sign("other", "body")} {
+		if VerifySignature("s3cret", []byte("body"), header) {
+			t.Errorf("signature %q should be rejected", header)
+		}
+	}
+}
+
+type launched struct {
+	trigger config.Trigger
+	params  map[string]string
+}
+
+func newTestServer(cfg *config.Config) (*Server, *[]launched) {
+	var calls []launched
+	s := New(cfg)
+	s.Launch = func(t config.Trigger, params map[string]string) error {
+		calls = append(calls, launched{t, params})
+		return nil
+	}
+	return s, &calls
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := &config.Config{
+		Daemon: config.Daemon{WebhookSecret: "s3cret"},
+		Triggers: []config.Trigger{
+			{On: "github:issues.labeled", Label: "agent-ok", Pipeline: "implement", Params: map[string]string{"issue": "{{issue}}"}},
+		},
+	}
+	s, calls := newTestServer(cfg)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	post := func(sig, delivery string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/webhooks/github", strings.NewReader(labeledPayload))
+		req.Header.Set("X-GitHub-Event", "issues")
+		req.Header.Set("X-GitHub-Delivery", delivery)
+		req.Header.Set("X-Hub-Signature-256", sig)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("sha256=00", "d1"); code != http.StatusUnauthorized {
+		t.Errorf("bad signature status = %d", code)
+	}
+	if code := post(sign("s3cret", labeledPayload), "d1"); code != http.StatusOK {
+		t.Errorf("status = %d", code)
+	}
+	post(sign("s3cret", labeledPayload), "d1") // redelivery
+	if len(*calls) != 1 {
+		t.Fatalf("launched %d pipelines, want 1", len(*calls))
+	}
+	c := (*calls)[0]
+	if c.trigger.Pipeline != "implement" || c.params["issue"] != "42" || c.params["repo"] != "https://github.com/org/api" {
+		t.Errorf("launch = %+v", c)
+	}
+
+	// Without a secret, nothing is trusted, signed or not.
+	cfg.Daemon.WebhookSecret = ""
+	if code := post("", "d2"); code != http.StatusUnauthorized {
+		t.Errorf("unsigned delivery with no secret configured: status = %d", code)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("launched %d pipelines, want still 1", len(*calls))
+	}
+}
+
+const reviewPayload = `{
+	"action": "submitted",
+	"review": {"state": "CHANGES_REQUESTED", "body": "Please add a test", "user": {"login": "ana"}},
+	"pull_request": {"number": 7, "title": "Rate limiting", "html_url": "https://github.com/org/api/pull/7", "head": {"ref": "feat/limit"}},
+	"repository": {"full_name": "org/api", "html_url": "https://github.com/org/api"},
+	"sender": {"login": "ana"}
+}`
+
+const checkSuitePayload = `{
+	"action": "completed",
+	"check_suite": {"conclusion": "failure", "head_branch": "main", "head_sha": "abc123", "pull_requests": []},
+	"repository": {"full_name": "org/web", "html_url": "https://github.com/org/web"}
+}`
+
+func TestForwardToBus(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	// The agent working on the PR branch, registered under its clone URL.
+	agentsDir := filepath.Join(namespace.Root(), "agents")
+	os.MkdirAll(agentsDir, 0755)
+	os.WriteFile(filepath.Join(agentsDir, "limiter.json"),
+		[]byte(`{"name": "limiter", "repo": "https://github.com/org/api.git", "branch": "feat/limit"}`), 0644)
+
+	e, err := ParseGitHubEvent("pull_request_review", []byte(reviewPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, ok := forwardToBus(e); !ok || typ != coordination.MsgReviewSubmitted {
+		t.Fatalf("forwardToBus() = %q, %v", typ, ok)
+	}
+	msgs, _ := coordination.ReadMessagesForAgent("https://github.com/org/api.git", "limiter")
+	if len(msgs) != 1 {
+		t.Fatalf("agent's bus has %d messages relevant to it, want 1", len(msgs))
+	}
+	m := msgs[0]
+	if m.Agent != "github" || m.Data["target"] != "limiter" || m.Data["review_state"] != "changes_requested" || m.Data["pr"] != "7" {
+		t.Errorf("message = %+v", m)
+	}
+
+	// No agent on the branch and no bus for the repo: nothing is published.
+	e, _ = ParseGitHubEvent("check_suite", []byte(checkSuitePayload))
+	if _, ok := forwardToBus(e); ok {
+		t.Error("published to a repo without a bus")
+	}
+	coordination.Init("https://github.com/org/web")
+	if typ, ok := forwardToBus(e); !ok || typ != coordination.MsgChecksCompleted {
+		t.Errorf("forwardToBus() = %q, %v after the bus exists", typ, ok)
+	}
+	if msgs, _ := coordination.ReadMessages("https://github.com/org/web"); len(msgs) != 1 || msgs[0].Data["conclusion"] != "failure" {
+		t.Errorf("web bus = %+v", msgs)
+	}
+}
+
+func TestPollBus(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	repo := "https://github.com/org/api"
+	cfg := &config.Config{Triggers: []config.Trigger{
+		{On: "bus:pr_created", Repo: repo, Pipeline: "review", Params: map[string]string{"pr": "{{pr}}"}},
+	}}
+	s, calls := newTestServer(cfg)
+	if got := s.BusRepos(); len(got) != 1 || got[0] != repo {
+		t.Fatalf("BusRepos() = %v", got)
+	}
+	coordination.Init(repo)
+
+	since := time.Now().Add(-time.Second)
+	coordination.Publish(repo, coordination.Message{Type: coordination.MsgCommitted, Agent: "a1"})
+	coordination.Publish(repo, coordination.Message{Type: coordination.MsgPRCreated, Agent: "a1", Data: map[string]string{"pr": "7"}})
+
+	since = s.pollBus(repo, since)
+	if len(*calls) != 1 || (*calls)[0].params["pr"] != "7" {
+		t.Fatalf("launches = %+v", *calls)
+	}
+	s.pollBus(repo, since)
+	if len(*calls) != 1 {
+		t.Error("already-seen messages must not fire again")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: map[string]config.NamedPipeline{"review": {Steps: []string{"review"}}},
+		Triggers: []config.Trigger{
+			{On: "bus:pr_created", Pipeline: "review"},
+			{On: "cron:nightly", Pipeline: "review"},
+			{On: "github:issues", Pipeline: "missing"},
+		},
+	}
+	if problems := New(cfg).Check(); len(problems) != 3 {
+		t.Errorf("Check() = %v, want 3 problems", problems)
+	}
+}
+
+func TestCheckSchedules(t *testing.T) {
+	cfg := &config.Config{
+		Pipelines: map[string]config.NamedPipeline{"deps": {Steps: []string{"implement"}}},
+		Schedules: []config.Schedule{
+			{ID: "nightly-deps", Cron: "@nightly", Repos: []string{"https://github.com/org/api"}, Task: "Update dependencies and open a PR"},
+			{ID: "weekly", Cron: "0 6 * * 1", Repos: []string{"https://github.com/org/api"}, Pipeline: "deps"},
+			{ID: "weekly", Cron: "0 6 * * 8", Pipeline: "missing"},
+			{Cron: "@daily", Repos: []string{"https://github.com/org/api"}},
+		},
+	}
+	// duplicate id, bad weekday, no repos, unknown pipeline, missing id, no task.
+	if problems := New(cfg).Check(); len(problems) != 6 {
+		t.Errorf("Check() = %v, want 6 problems", problems)
+	}
+
+	feb30 := &config.Config{Schedules: []config.Schedule{
+		{ID: "feb-30", Cron: "0 0 30 2 *", Repos: []string{"https://github.com/org/api"}, Task: "Never"},
+	}}
+	if problems := New(feb30).Check(); len(problems) != 1 || !strings.Contains(problems[0], "matches no date") {
+		t.Errorf("Check() = %v, want Feb 30 rejected", problems)
+	}
+	s := New(feb30)
+	start := time.Date(2024, 5, 1, 10, 59, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		if fired := s.due(start.Add(time.Duration(i) * time.Minute)); len(fired) != 0 {
+			t.Errorf("Feb 30 fired: %v", fired)
+		}
+	}
+}
+
+func TestSchedulerDue(t *testing.T) {
+	cfg := &config.Config{Schedules: []config.Schedule{
+		{ID: "hourly", Cron: "@hourly"},
+		{ID: "nightly", Cron: "@nightly"},
+	}}
+	s := New(cfg)
+	start := time.Date(2024, 5, 1, 10, 59, 0, 0, time.Local)
+	if fired := s.due(start); len(fired) != 0 {
+		t.Fatalf("first call only primes, fired %v", fired)
+	}
+	if fired := s.due(start.Add(30 * time.Second)); len(fired) != 0 {
+		t.Errorf("fired early: %v", fired)
+	}
+	fired := s.due(start.Add(90 * time.Second))
+	if len(fired) != 1 || fired[0].ID != "hourly" {
+		t.Fatalf("due() = %v, want hourly", fired)
+	}
+	if fired := s.due(start.Add(2 * time.Minute)); len(fired) != 0 {
+		t.Errorf("fired twice in one slot: %v", fired)
+	}
+
+	// A run still going at the next slot is skipped, not stacked.
+	s.setRunning("hourly", true)
+	if fired := s.due(start.Add(61 * time.Minute)); len(fired) != 0 {
+		t.Errorf("fired while running: %v", fired)
+	}
+	s.setRunning("hourly", false)
+	if fired := s.due(start.Add(121 * time.Minute)); len(fired) != 1 {
+		t.Errorf("due() = %v, want hourly again", fired)
+	}
+}
+
+func TestRecordScheduleRun(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	start := time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)
+	for i := 0; i < maxScheduleRuns+5; i++ {
+		recordScheduleRun(&ScheduleRun{Schedule: "nightly", Repo: "r", Started: start.AddDate(0, 0, i), Result: "completed"})
+	}
+	run := &ScheduleRun{Schedule: "other", Repo: "r", Started: start, Result: "running"}
+	recordScheduleRun(run)
+	run.Result = "failed"
+	recordScheduleRun(run)
+
+	runs, err := LoadScheduleRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != maxScheduleRuns+1 {
+		t.Errorf("kept %d runs, want %d", len(runs), maxScheduleRuns+1)
+	}
+	if last, ok := LastScheduleRun("other"); !ok || last.Result != "failed" {
+		t.Errorf("LastScheduleRun() = %+v, want the updated run", last)
+	}
+	if last, _ := LastScheduleRun("nightly"); !last.Started.Equal(start.AddDate(0, 0, maxScheduleRuns+4)) {
+		t.Errorf("LastScheduleRun() = %+v, want the newest", last)
+	}
+	if got := scheduleAgentName("nightly", "https://github.com/org/api.git", start); got != "nightly-api-0501-0200" {
+		t.Errorf("scheduleAgentName() = %q", got)
+	}
+}
+
+func TestAPIRoles(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	cfg := &config.Config{Daemon: config.Daemon{Users: []config.DaemonUser{
+		{Name: "ops", Token: "admin-token", Role: RoleAdmin},
+		{Name: "pm", Token: "viewer-token", Role: RoleSpectator},
+	}}}
+	srv := httptest.NewServer(New(cfg).Handler())
+	defer srv.Close()
+
+	call := func(method, path, token string) int {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/api/agents", "", http.StatusUnauthorized},
+		{"GET", "/api/agents", "wrong", http.StatusUnauthorized},
+		{"GET", "/api/agents", "viewer-token", http.StatusOK},
+		{"GET", "/api/history", "viewer-token", http.StatusOK},
+		{"GET", "/api/agents/ghost", "viewer-token", http.StatusNotFound},
+		{"DELETE", "/api/agents/ghost", "viewer-token", http.StatusForbidden},
+		{"POST", "/api/agents/ghost/merge", "viewer-token", http.StatusForbidden},
+		{"POST", "/api/agents", "viewer-token", http.StatusForbidden},
+		{"DELETE", "/api/agents/ghost", "admin-token", http.StatusNotFound},
+		{"POST", "/api/agents", "admin-token", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := call(tt.method, tt.path, tt.token); got != tt.want {
+			t.Errorf("%s %s (token %q) = %d, want %d", tt.method, tt.path, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestAPIOpenIsReadOnly(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	srv := httptest.NewServer(New(&config.Config{}).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("whoami = %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/agents/a1", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("anonymous kill = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestAPIOpenOnlyToThisHost(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	handler := New(&config.Config{}).Handler()
+
+	tests := []struct {
+		path, remote string
+		want         int
+	}{
+		{"/api/whoami", "127.0.0.1:50000", http.StatusOK},
+		{"/api/whoami", "[::1]:50000", http.StatusOK},
+		{"/api/whoami", "192.168.1.5:50000", http.StatusForbidden},
+		{"/api/agents/a1/spy", "192.168.1.5:50000", http.StatusForbidden},
+		{"/api/history", "192.168.1.5:50000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = tt.remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s from %s = %d, want %d", tt.path, tt.remote, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Event is something the daemon can react to: a coordination bus message or
+// a forge webhook.
+type Event struct {
+	Source string            // "bus" or "github"
+	Type   string            // message type, or "<event>.<action>" for webhooks
+	Repo   string            // repo URL or owner/name slug
+	Fields map[string]string // values available to {{field}} placeholders
+}
+
+// Key is the trigger "on" value this event satisfies most specifically.
+func (e Event) Key() string {
+	return e.Source + ":" + e.Type
+}
+
+// Matches reports whether a trigger fires for the event.
+func Matches(t config.Trigger, e Event) bool {
+	on := t.On
+	if on != e.Key() {
+		// "github:issues" matches any action of the issues event.
+		if e.Source != "github" || !strings.HasPrefix(e.Key(), on+".") {
+			return false
+		}
+	}
+	if t.Repo != "" && !SameRepo(t.Repo, e.Repo) {
+		return false
+	}
+	if t.Label != "" && t.Label != e.Fields["label"] {
+		return false
+	}
+	return true
+}
+
+// Match returns the triggers that fire for the event.
+func Match(triggers []config.Trigger, e Event) []config.Trigger {
+	var matched []config.Trigger
+	for _, t := range triggers {
+		if Matches(t, e) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Render fills {{field}} placeholders in the trigger's params from the event.
+// The event's repo is passed as the "repo" param unless the trigger sets one.
+func Render(t config.Trigger, e Event) map[string]string {
+	params := make(map[string]string, len(t.Params)+1)
+	for k, v := range t.Params {
+		params[k] = placeholder.ReplaceAllStringFunc(v, func(m string) string {
+			return e.Fields[placeholder.FindStringSubmatch(m)[1]]
+		})
+	}
+	if params["repo"] == "" {
+		params["repo"] = e.Repo
+		if t.Repo != "" {
+			params["repo"] = t.Repo
+		}
+	}
+	return params
+}
+
+// SameRepo compares repos given as URLs or owner/name slugs.
+func SameRepo(a, b string) bool {
+	return repoSlug(a) == repoSlug(b)
+}
+
+func repoSlug(repo string) string {
+	r := strings.ToLower(strings.TrimSpace(repo))
+	for _, prefix := range []string{"https://github.com/", "http://github.com/", "git@github.com:"} {
+		r = strings.TrimPrefix(r, prefix)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(r, "/"), ".git")
+}
@@ -0,0 +1,284 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/board"
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Default notification filters (config.Notification.Events and Immediate).
+var (
+	DefaultNotifyEvents = []string{
+		"pr_created", "merged", "checks_completed", "review_submitted",
+		"escalation", "policy_violation", "claim_conflict", "agent_removed",
+	}
+	DefaultImmediateEvents = []string{"escalation", "policy_violation"}
+)
+
+// notifier posts bus events to the configured channels.
+type notifier struct {
+	channels []*channel
+	// post sends a message to a webhook (board.PublishSlack by default).
+	post func(webhook, text string) error
+}
+
+// channel is one configured notification target and its pending digest.
+type channel struct {
+	cfg       config.Notification
+	window    time.Duration
+	events    map[string]bool
+	immediate map[string]bool
+
+	mu      sync.Mutex
+	pending []Event
+	seen    map[string]time.Time // dedup key → when last taken
+	timer   *time.Timer
+}
+
+func newNotifier(cfgs []config.Notification) *notifier {
+	n := &notifier{post: board.PublishSlack}
+	for _, c := range cfgs {
+		ch := &channel{cfg: c, events: set(c.Events, DefaultNotifyEvents), immediate: set(c.Immediate, DefaultImmediateEvents), seen: map[string]time.Time{}}
+		if d, err := time.ParseDuration(c.Digest); err == nil && d > 0 {
+			ch.window = d
+		}
+		n.channels = append(n.channels, ch)
+	}
+	return n
+}
+
+func set(list, defaults []string) map[string]bool {
+	if len(list) == 0 {
+		list = defaults
+	}
+	m := map[string]bool{}
+	for _, s := range list {
+		m[s] = true
+	}
+	return m
+}
+
+// notificationProblems reports channels that can never post.
+func notificationProblems(cfgs []config.Notification) []string {
+	var problems []string
+	for i, c := range cfgs {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("notification %d", i+1)
+		}
+		if c.Webhook == "" {
+			problems = append(problems, name+": no webhook")
+		}
+		if c.Digest != "" {
+			if d, err := time.ParseDuration(c.Digest); err != nil || d <= 0 {
+				problems = append(problems, fmt.Sprintf("%s: invalid digest window %q", name, c.Digest))
+			}
+		}
+	}
+	return problems
+}
+
+// Notify hands a bus event to every channel that wants it: posted at once
+// when the channel has no digest window or the event is high severity,
+// otherwise held for the channel's next digest. An event identical to one
+// already taken within the window is dropped.
+func (n *notifier) Notify(e Event) {
+	if e.Source != "bus" {
+		return
+	}
+	for _, ch := range n.channels {
+		if !ch.events[e.Type] || !ch.wantsRepo(e.Repo) {
+			continue
+		}
+		if ch.window == 0 {
+			n.send(ch, formatEvent(e))
+			continue
+		}
+		ch.mu.Lock()
+		key, now := dedupKey(e), time.Now()
+		if last, ok := ch.seen[key]; ok && now.Sub(last) < ch.window {
+			ch.mu.Unlock()
+			continue
+		}
+		ch.seen[key] = now
+		if ch.immediate[e.Type] {
+			ch.mu.Unlock()
+			n.send(ch, formatEvent(e))
+			continue
+		}
+		ch.pending = append(ch.pending, e)
+		if ch.timer == nil {
+			ch.timer = time.AfterFunc(ch.window, func() { n.flush(ch) })
+		}
+		ch.mu.Unlock()
+	}
+}
+
+func (ch *channel) wantsRepo(repo string) bool {
+	if len(ch.cfg.Repos) == 0 {
+		return true
+	}
+	for _, r := range ch.cfg.Repos {
+		if SameRepo(r, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// flush posts a channel's pending digest.
+func (n *notifier) flush(ch *channel) {
+	ch.mu.Lock()
+	events := ch.pending
+	ch.pending, ch.timer = nil, nil
+	for key, at := range ch.seen {
+		if time.Since(at) >= ch.window {
+			delete(ch.seen, key)
+		}
+	}
+	ch.mu.Unlock()
+	if len(events) > 0 {
+		n.send(ch, formatDigest(events, ch.cfg.Digest))
+	}
+}
+
+// Flush posts every pending digest now, e.g. when the daemon stops.
+func (n *notifier) Flush() {
+	for _, ch := range n.channels {
+		ch.mu.Lock()
+		if ch.timer != nil {
+			ch.timer.Stop()
+		}
+		ch.mu.Unlock()
+		n.flush(ch)
+	}
+}
+
+func (n *notifier) send(ch *channel, text string) {
+	if err := n.post(ch.cfg.Webhook, text); err != nil {
+		name := ch.cfg.Name
+		if name == "" {
+			name = "notification"
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  %s: %v\n", name, err)
+	}
+}
+
+// dedupKey identifies an event by everything but its timing.
+func dedupKey(e Event) string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(e.Repo + "|" + e.Type)
+	for _, k := range keys {
+		b.WriteString("|" + k + "=" + e.Fields[k])
+	}
+	return b.String()
+}
+
+// outcome sorts an event into the digest's summary: what happened to the
+// agent, in the words of the summary line.
+func outcome(e Event) string {
+	switch e.Type {
+	case "agent_removed":
+		switch e.Fields["reason"] {
+		case "success":
+			return "completed"
+		case "killed":
+			return "killed"
+		}
+		return "failed"
+	case "merged":
+		return "merged"
+	case "pr_created":
+		return "needs review"
+	case "review_submitted":
+		if e.Fields["review_state"] == "changes_requested" {
+			return "changes requested"
+		}
+		return "reviewed"
+	case "checks_completed":
+		if e.Fields["conclusion"] == "success" {
+			return "checks passed"
+		}
+		return "checks failed"
+	case "escalation", "policy_violation", "claim_conflict":
+		return "needs attention"
+	}
+	return e.Type
+}
+
+// outcomeOrder puts the summary's most telling outcomes first.
+var outcomeOrder = []string{"completed", "merged", "failed", "needs attention", "needs review", "changes requested", "checks failed"}
+
+// formatDigest summarises a window's events — "7 completed, 2 failed,
+// 1 needs review" — followed by one line per event.
+func formatDigest(events []Event, window string) string {
+	counts := map[string]int{}
+	for _, e := range events {
+		counts[outcome(e)]++
+	}
+	var order []string
+	for _, o := range outcomeOrder {
+		if counts[o] > 0 {
+			order = append(order, o)
+		}
+	}
+	var rest []string
+	for o := range counts {
+		if !slices.Contains(outcomeOrder, o) {
+			rest = append(rest, o)
+		}
+	}
+	sort.Strings(rest)
+	var parts []string
+	for _, o := range append(order, rest...) {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[o], o))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📬 agentctl, last %s: %s — details:\n", window, strings.Join(parts, ", "))
+	for _, e := range events {
+		b.WriteString("• " + eventLine(e) + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// formatEvent is the message for an event posted on its own.
+func formatEvent(e Event) string {
+	icon := "📣"
+	switch outcome(e) {
+	case "failed", "needs attention", "checks failed":
+		icon = "🚨"
+	case "completed", "merged", "checks passed":
+		icon = "✅"
+	}
+	return icon + " " + eventLine(e)
+}
+
+// eventLine describes an event in one line: agent, type, repo and data.
+func eventLine(e Event) string {
+	var data []string
+	for k, v := range e.Fields {
+		if k == "repo" || k == "agent" || k == "type" || v == "" {
+			continue
+		}
+		data = append(data, k+"="+v)
+	}
+	sort.Strings(data)
+	line := fmt.Sprintf("%s %s (%s)", e.Fields["agent"], e.Type, repoSlug(e.Repo))
+	if len(data) > 0 {
+		line += ": " + strings.Join(data, " ")
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// capture replaces the notifier's webhook post, recording what was sent.
+func capture(n *notifier) func() []string {
+	var mu sync.Mutex
+	var posts []string
+	n.post = func(webhook, text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		posts = append(posts, webhook+" "+text)
+		return nil
+	}
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), posts...)
+	}
+}
+
+func busMsg(typ, agent string, data ...string) Event {
+	fields := map[string]string{"repo": "https://github.com/org/api", "agent": agent, "type": typ}
+	for i := 0; i+1 < len(data); i += 2 {
+		fields[data[i]] = data[i+1]
+	}
+	return Event{Source: "bus", Type: typ, Repo: "https://github.com/org/api", Fields: fields}
+}
+
+func TestNotifyDigest(t *testing.T) {
+	n := newNotifier([]config.Notification{{Name: "fleet", Webhook: "https://hooks/fleet", Digest: "1h"}})
+	posts := capture(n)
+
+	n.Notify(busMsg("agent_removed", "a1", "reason", "success"))
+	n.Notify(busMsg("agent_removed", "a2", "reason", "success"))
+	n.Notify(busMsg("agent_removed", "a3", "reason", "max_attempts"))
+	n.Notify(busMsg("pr_created", "a1", "pr", "7"))
+	n.Notify(busMsg("pr_created", "a1", "pr", "7")) // duplicate
+	n.Notify(busMsg("claim", "a1", "file", "x.go")) // not a notified event
+	n.Notify(busMsg("escalation", "a4", "reason", "stuck"))
+
+	got := posts()
+	if len(got) != 1 || !strings.HasPrefix(got[0], "https://hooks/fleet 🚨 a4 escalation (org/api): reason=stuck") {
+		t.Fatalf("only the escalation should be posted at once, got %q", got)
+	}
+
+	n.Flush()
+	got = posts()
+	if len(got) != 2 {
+		t.Fatalf("expected a digest after flush, got %d posts", len(got))
+	}
+	digest := got[1]
+	if !strings.Contains(digest, "last 1h: 2 completed, 1 failed, 1 needs review — details:") {
+		t.Errorf("digest summary wrong:\n%s", digest)
+	}
+	if strings.Count(digest, "\n• ") != 4 {
+		t.Errorf("digest should list 4 deduplicated events:\n%s", digest)
+	}
+	if !strings.Contains(digest, "• a1 pr_created (org/api): pr=7") {
+		t.Errorf("digest should detail the PR:\n%s", digest)
+	}
+
+	// Nothing pending: no empty digest.
+	n.Flush()
+	if len(posts()) != 2 {
+		t.Error("an empty digest should not be posted")
+	}
+}
+
+func TestNotifyFilters(t *testing.T) {
+	n := newNotifier([]config.Notification{
+		{Webhook: "https://hooks/web", Repos: []string{"org/web"}},
+		{Webhook: "https://hooks/merges", Events: []string{"merged"}},
+	})
+	posts := capture(n)
+
+	n.Notify(busMsg("merged", "a1", "base", "main"))
+	n.Notify(busMsg("pr_created", "a2"))
+	n.Notify(Event{Source: "github", Type: "issues.labeled", Repo: "org/api"})
+
+	got := posts()
+	if len(got) != 1 || got[0] != "https://hooks/merges ✅ a1 merged (org/api): base=main" {
+		t.Errorf("posts = %q", got)
+	}
+}
+
+func TestNotificationProblems(t *testing.T) {
+	problems := notificationProblems([]config.Notification{
+		{Name: "ok", Webhook: "https://hooks/x", Digest: "30m"},
+		{Name: "nohook"},
+		{Webhook: "https://hooks/y", Digest: "soon"},
+	})
+	want := []string{"nohook: no webhook", `notification 3: invalid digest window "soon"`}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+}
@@ -0,0 +1,353 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/container"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+	"github.com/jordanpartridge/agentctl/pkg/pipeline"
+)
+
+// ScheduleTick is how often the daemon checks for due schedules.
+const ScheduleTick = 30 * time.Second
+
+// maxScheduleRuns bounds the runs kept per schedule in schedules.json.
+const maxScheduleRuns = 20
+
+// ScheduleRun records one scheduled run against one repo.
+type ScheduleRun struct {
+	Schedule string    `json:"schedule"`
+	Repo     string    `json:"repo"`
+	Agent    string    `json:"agent,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Result   string    `json:"result"` // running, completed, failed
+	Attempts int       `json:"attempts,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// FindSchedule returns the schedule with the given ID.
+func FindSchedule(cfg *config.Config, id string) (config.Schedule, bool) {
+	for _, sc := range cfg.Schedules {
+		if sc.ID == id {
+			return sc, true
+		}
+	}
+	return config.Schedule{}, false
+}
+
+// scheduleProblems reports schedules that can never run.
+func scheduleProblems(cfg *config.Config) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for i, sc := range cfg.Schedules {
+		label := fmt.Sprintf("schedule %d", i+1)
+		if sc.ID == "" {
+			problems = append(problems, label+": missing id")
+		} else {
+			label += " (" + sc.ID + ")"
+			if seen[sc.ID] {
+				problems = append(problems, label+": duplicate id")
+			}
+			seen[sc.ID] = true
+		}
+		if _, err := ParseCron(sc.Cron); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+		}
+		if len(sc.Repos) == 0 {
+			problems = append(problems, label+": no repos")
+		}
+		switch {
+		case sc.Pipeline != "":
+			if _, ok := cfg.Pipelines[sc.Pipeline]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: no pipeline named %q", label, sc.Pipeline))
+			}
+		case sc.Task == "":
+			problems = append(problems, label+": needs a task or a pipeline")
+		}
+	}
+	return problems
+}
+
+// scheduler tracks when each schedule fires next.
+type scheduler struct {
+	mu      sync.Mutex
+	next    map[string]time.Time
+	running map[string]bool
+}
+
+// due returns the schedules whose fire time has passed and advances them to
+// their next time. A schedule whose previous run is still going is skipped
+// for this slot rather than stacked.
+func (s *Server) due(now time.Time) []config.Schedule {
+	sch := &s.sched
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	if sch.next == nil {
+		sch.next = map[string]time.Time{}
+	}
+	var fire []config.Schedule
+	for _, sc := range s.cfg.Schedules {
+		c, err := ParseCron(sc.Cron)
+		if err != nil {
+			continue
+		}
+		// A zero next time matches no date: it is never due.
+		next, ok := sch.next[sc.ID]
+		if !ok || next.IsZero() {
+			sch.next[sc.ID] = c.Next(now)
+			continue
+		}
+		if now.Before(next) {
+			continue
+		}
+		sch.next[sc.ID] = c.Next(now)
+		if sch.running[sc.ID] {
+			fmt.Printf("⏭️  schedule %s: previous run still going, skipping\n", sc.ID)
+			continue
+		}
+		fire = append(fire, sc)
+	}
+	return fire
+}
+
+// setRunning marks a schedule's run as started or finished.
+func (s *Server) setRunning(id string, running bool) {
+	s.sched.mu.Lock()
+	defer s.sched.mu.Unlock()
+	if s.sched.running == nil {
+		s.sched.running = map[string]bool{}
+	}
+	s.sched.running[id] = running
+}
+
+// runScheduler fires due schedules until ctx is cancelled.
+func (s *Server) runScheduler(ctx context.Context) {
+	s.due(time.Now()) // prime next fire times
+	ticker := time.NewTicker(ScheduleTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, sc := range s.due(now) {
+				fmt.Printf("⏰ schedule %s → %d repo(s)\n", sc.ID, len(sc.Repos))
+				if err := s.LaunchSchedule(sc); err != nil {
+					fmt.Fprintf(os.Stderr, "⚠️  schedule %s failed to start: %v\n", sc.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// launchSchedule runs `agentctl schedule run <id>` in the background,
+// logging to a file in LogDir.
+func (s *Server) launchSchedule(sc config.Schedule) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.LogDir, 0755); err != nil {
+		return err
+	}
+	logPath := filepath.Join(s.LogDir, fmt.Sprintf("schedule-%s-%s.log", sc.ID, time.Now().Format("20060102-150405")))
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"schedule", "run", sc.ID}
+	if ns := namespace.Current(); ns != namespace.Default {
+		args = append([]string{"--namespace", ns}, args...)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return err
+	}
+	s.setRunning(sc.ID, true)
+	fmt.Printf("   📝 %s\n", logPath)
+	go func() {
+		err := cmd.Wait()
+		logFile.Close()
+		s.setRunning(sc.ID, false)
+		if err != nil {
+			fmt.Printf("❌ schedule %s failed: %v (see %s)\n", sc.ID, err, logPath)
+			return
+		}
+		fmt.Printf("✅ schedule %s complete\n", sc.ID)
+	}()
+	return nil
+}
+
+// RunSchedule runs a schedule once, one repo after another, recording each
+// outcome in schedules.json and agent history. It returns an error if any
+// repo's run failed.
+func RunSchedule(sc config.Schedule) error {
+	failed := 0
+	for _, repo := range sc.Repos {
+		run := &ScheduleRun{Schedule: sc.ID, Repo: repo, Started: time.Now(), Result: "running"}
+		if sc.Pipeline == "" {
+			run.Agent = scheduleAgentName(sc.ID, repo, run.Started)
+		}
+		recordScheduleRun(run)
+
+		var err error
+		if sc.Pipeline != "" {
+			err = runSchedulePipeline(sc, repo)
+		} else {
+			err = runScheduleAgent(sc, run)
+		}
+		run.Finished = time.Now()
+		run.Result = "completed"
+		if err != nil {
+			run.Result, run.Error = "failed", err.Error()
+			failed++
+			fmt.Fprintf(os.Stderr, "❌ %s on %s: %v\n", sc.ID, repo, err)
+		} else {
+			fmt.Printf("✅ %s on %s complete\n", sc.ID, repo)
+		}
+		recordScheduleRun(run)
+	}
+	if failed > 0 {
+		return fmt.Errorf("schedule %s: %d of %d repo(s) failed", sc.ID, failed, len(sc.Repos))
+	}
+	return nil
+}
+
+func runSchedulePipeline(sc config.Schedule, repo string) error {
+	params := map[string]string{}
+	for k, v := range sc.Params {
+		params[k] = v
+	}
+	params["repo"] = repo
+	params["schedule"] = sc.ID
+	if sc.Task != "" {
+		params["task"] = sc.Task
+	}
+	return pipeline.RunNamed(sc.Pipeline, params, pipeline.Options{})
+}
+
+// runScheduleAgent spawns a fresh agent for the task, runs it until done and
+// removes it, keeping a history record tagged with the schedule ID.
+func runScheduleAgent(sc config.Schedule, run *ScheduleRun) error {
+	fmt.Printf("🚀 %s: spawning %s for %s\n", sc.ID, run.Agent, run.Repo)
+	agent, err := container.SpawnWithOptions(container.SpawnOptions{
+		Name:   run.Agent,
+		Repo:   run.Repo,
+		Branch: sc.Branch,
+		Image:  sc.Image,
+		Intent: sc.Task,
+		// Stats group the schedule's runs together.
+		Template: "schedule:" + sc.ID,
+		// Scheduled runs queue for capacity rather than fail.
+		WaitForCapacity: true,
+	})
+	if err != nil {
+		if agent != nil {
+			container.Cleanup(run.Agent, "setup_failed", 0, map[string]string{"schedule": sc.ID, "error": err.Error()})
+		}
+		return err
+	}
+
+	result, err := container.RunUntilDone(run.Agent, sc.Task, sc.Attempts)
+	if result != nil {
+		run.Attempts = result.Attempts
+	}
+	outcome := "completed"
+	meta := map[string]string{"schedule": sc.ID}
+	if err != nil {
+		outcome = "failed"
+		meta["error"] = err.Error()
+		if sc.KeepFailed {
+			fmt.Printf("🔍 keeping %s for inspection (agentctl shell %s)\n", run.Agent, run.Agent)
+			return err
+		}
+	}
+	if cerr := container.Cleanup(run.Agent, outcome, run.Attempts, meta); cerr != nil && err == nil {
+		return cerr
+	}
+	return err
+}
+
+// scheduleAgentName derives a unique, readable agent name for a run.
+func scheduleAgentName(id, repo string, t time.Time) string {
+	base := path.Base(strings.TrimSuffix(strings.TrimRight(repo, "/"), ".git"))
+	return fmt.Sprintf("%s-%s-%s", id, base, t.Format("0102-1504"))
+}
+
+func scheduleRunsPath() string {
+	return filepath.Join(namespace.Root(), "daemon", "schedules.json")
+}
+
+// LoadScheduleRuns returns recorded runs, oldest first.
+func LoadScheduleRuns() ([]ScheduleRun, error) {
+	data, err := os.ReadFile(scheduleRunsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []ScheduleRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// LastScheduleRun returns the most recent run of a schedule, if any.
+func LastScheduleRun(id string) (ScheduleRun, bool) {
+	runs, _ := LoadScheduleRuns()
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Schedule == id {
+			return runs[i], true
+		}
+	}
+	return ScheduleRun{}, false
+}
+
+// recordScheduleRun inserts or updates a run (keyed by schedule, repo and
+// start time), keeping the latest maxScheduleRuns per schedule.
+func recordScheduleRun(run *ScheduleRun) error {
+	runs, _ := LoadScheduleRuns()
+	replaced := false
+	for i := range runs {
+		if runs[i].Schedule == run.Schedule && runs[i].Repo == run.Repo && runs[i].Started.Equal(run.Started) {
+			runs[i], replaced = *run, true
+		}
+	}
+	if !replaced {
+		runs = append(runs, *run)
+	}
+
+	counts := map[string]int{}
+	var kept []ScheduleRun
+	for i := len(runs) - 1; i >= 0; i-- {
+		if counts[runs[i].Schedule]++; counts[runs[i].Schedule] <= maxScheduleRuns {
+			kept = append(kept, runs[i])
+		}
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(scheduleRunsPath()), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(kept, "", "  ")
+	return os.WriteFile(scheduleRunsPath(), data, 0644)
+}
@@ -0,0 +1,263 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jordanpartridge/agentctl/pkg/container"
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// maxWebhookBody bounds the payload read from a webhook request.
+const maxWebhookBody = 5 << 20
+
+// githubPayload is the subset of GitHub webhook payloads the router uses.
+type githubPayload struct {
+	Action string `json:"action"`
+	Label  *struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Issue *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	} `json:"issue"`
+	PullRequest *struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Head    struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Review *struct {
+		State string `json:"state"`
+		Body  string `json:"body"`
+		User  struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"review"`
+	CheckSuite *struct {
+		Conclusion   string `json:"conclusion"`
+		HeadBranch   string `json:"head_branch"`
+		HeadSHA      string `json:"head_sha"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"check_suite"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// ParseGitHubEvent turns a webhook delivery into an Event.
+func ParseGitHubEvent(eventName string, body []byte) (Event, error) {
+	var p githubPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Event{}, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	typ := eventName
+	if p.Action != "" {
+		typ += "." + p.Action
+	}
+	e := Event{
+		Source: "github",
+		Type:   typ,
+		Repo:   p.Repository.HTMLURL,
+		Fields: map[string]string{
+			"event":     eventName,
+			"action":    p.Action,
+			"repo":      p.Repository.HTMLURL,
+			"repo_slug": p.Repository.FullName,
+			"sender":    p.Sender.Login,
+		},
+	}
+	if e.Repo == "" {
+		e.Repo = p.Repository.FullName
+		e.Fields["repo"] = p.Repository.FullName
+	}
+	if p.Label != nil {
+		e.Fields["label"] = p.Label.Name
+	}
+	if p.Issue != nil {
+		e.Fields["issue"] = strconv.Itoa(p.Issue.Number)
+		e.Fields["title"] = p.Issue.Title
+	}
+	if p.PullRequest != nil {
+		e.Fields["pr"] = strconv.Itoa(p.PullRequest.Number)
+		e.Fields["title"] = p.PullRequest.Title
+		e.Fields["branch"] = p.PullRequest.Head.Ref
+		e.Fields["url"] = p.PullRequest.HTMLURL
+	}
+	if p.Review != nil {
+		e.Fields["review_state"] = strings.ToLower(p.Review.State)
+		e.Fields["reviewer"] = p.Review.User.Login
+		e.Fields["review_body"] = p.Review.Body
+	}
+	if p.CheckSuite != nil {
+		e.Fields["conclusion"] = p.CheckSuite.Conclusion
+		e.Fields["branch"] = p.CheckSuite.HeadBranch
+		e.Fields["sha"] = p.CheckSuite.HeadSHA
+		if len(p.CheckSuite.PullRequests) > 0 {
+			e.Fields["pr"] = strconv.Itoa(p.CheckSuite.PullRequests[0].Number)
+		}
+	}
+	return e, nil
+}
+
+// busTypes maps the webhook events forwarded to the coordination bus.
+var busTypes = map[string]coordination.MessageType{
+	"issues.labeled":                coordination.MsgIssueLabeled,
+	"pull_request_review.submitted": coordination.MsgReviewSubmitted,
+	"check_suite.completed":         coordination.MsgChecksCompleted,
+}
+
+// busFields are the event fields carried into a forwarded bus message.
+var busFields = []string{"issue", "label", "title", "pr", "url", "branch", "sha", "review_state", "reviewer", "conclusion", "sender"}
+
+// forwardToBus publishes a forge event on the repo's coordination bus, so
+// agents see it in their briefing and bus triggers can react to it. Events
+// about an agent's branch are targeted at that agent and go to the bus it
+// uses; others are published only to repos that already have a bus.
+func forwardToBus(e Event) (coordination.MessageType, bool) {
+	typ, ok := busTypes[e.Type]
+	if !ok {
+		return "", false
+	}
+	msg := coordination.Message{Type: typ, Agent: "github", Data: map[string]string{}}
+	for _, k := range busFields {
+		if v := e.Fields[k]; v != "" {
+			msg.Data[k] = v
+		}
+	}
+
+	repo := ""
+	if branch := e.Fields["branch"]; branch != "" {
+		for _, a := range container.Agents() {
+			if a.Branch == branch && SameRepo(a.Repo, e.Repo) {
+				repo, msg.Data["target"] = a.Repo, a.Name
+				break
+			}
+		}
+	}
+	if repo != "" {
+		if _, err := coordination.Init(repo); err != nil {
+			return "", false
+		}
+	} else {
+		repo = e.Repo
+		dir, err := coordination.CoordDir(repo)
+		if err != nil {
+			return "", false
+		}
+		if _, err := os.Stat(dir); err != nil {
+			return "", false
+		}
+	}
+	if err := coordination.Publish(repo, msg); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  could not publish %s to the bus: %v\n", typ, err)
+		return "", false
+	}
+	return typ, true
+}
+
+// VerifySignature checks a GitHub X-Hub-Signature-256 header against the
+// shared secret.
+func VerifySignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// deliveries remembers recent webhook delivery IDs so GitHub redeliveries
+// don't fire a trigger twice.
+type deliveries struct {
+	mu    sync.Mutex
+	seen  map[string]bool
+	order []string
+}
+
+const maxDeliveries = 1000
+
+func (d *deliveries) add(id string) (fresh bool) {
+	if id == "" {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]bool)
+	}
+	if d.seen[id] {
+		return false
+	}
+	d.seen[id] = true
+	d.order = append(d.order, id)
+	if len(d.order) > maxDeliveries {
+		delete(d.seen, d.order[0])
+		d.order = d.order[1:]
+	}
+	return true
+}
+
+// handleGitHub receives GitHub webhooks and routes them to triggers.
+func (s *Server) handleGitHub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+	// Deliveries can spawn agents, so an unsigned one is never trusted.
+	if s.cfg.Daemon.WebhookSecret == "" {
+		http.Error(w, "webhooks are disabled: set daemon.webhook_secret", http.StatusUnauthorized)
+		return
+	}
+	if !VerifySignature(s.cfg.Daemon.WebhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	name := r.Header.Get("X-GitHub-Event")
+	if name == "ping" {
+		fmt.Fprintln(w, "pong")
+		return
+	}
+	if !s.deliveries.add(r.Header.Get("X-GitHub-Delivery")) {
+		fmt.Fprintln(w, "duplicate delivery ignored")
+		return
+	}
+	e, err := ParseGitHubEvent(name, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n := s.Dispatch(e)
+	fmt.Fprintf(w, "%d trigger(s) fired\n", n)
+	if typ, ok := forwardToBus(e); ok {
+		fmt.Printf("📨 %s → bus %s\n", e.Key(), typ)
+		fmt.Fprintf(w, "published %s\n", typ)
+	}
+}
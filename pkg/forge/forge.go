@@ -0,0 +1,200 @@
+// Package forge hides the differences between the hosts agentctl's repos
+// live on — GitHub, GitLab and Gitea — behind one interface: where the
+// token comes from, how git authenticates with it, and how pull requests
+// are found, opened and checked. The forge is picked from the repo's URL.
+package forge
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Forge types.
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+	Gitea  = "gitea"
+)
+
+// CI states reported by CIStatus.
+const (
+	CIPass    = "pass"
+	CIFail    = "fail"
+	CIPending = "pending"
+	CINone    = "none" // no CI ran for the ref
+)
+
+// PR is an open pull (or merge) request.
+type PR struct {
+	Number int
+	URL    string
+	Body   string
+}
+
+// Issue is an open issue.
+type Issue struct {
+	Number int      `json:"number"`
+	Title  string   `json:"title"`
+	Body   string   `json:"body,omitempty"`
+	URL    string   `json:"url"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// NewPR describes a pull request to open.
+type NewPR struct {
+	Head  string // the branch with the changes
+	Base  string // the branch to merge into
+	Title string
+	Body  string
+	Draft bool
+}
+
+// Forge is a code host.
+type Forge interface {
+	// Type is GitHub, GitLab or Gitea.
+	Type() string
+	// Token finds the API and git token for the host, or "".
+	Token() string
+	// TokenEnv is the variable agents get the token in, as the host's CLI
+	// expects it.
+	TokenEnv() string
+	// Credentials are the user and password git sends with token.
+	Credentials(token string) (user, password string)
+
+	// FindPR returns the open PR from branch, or an error if there is none.
+	FindPR(repo, branch string) (*PR, error)
+	CreatePR(repo string, pr NewPR) (*PR, error)
+	SetPRBody(repo string, pr *PR, body string) error
+	// CIStatus sums up the CI runs for branch's head.
+	CIStatus(repo, branch string) (string, error)
+	// Issues lists repo's open issues carrying label (any, if empty),
+	// oldest first. Pull requests are not issues here.
+	Issues(repo, label string) ([]Issue, error)
+}
+
+// For returns the forge hosting repo: configured hosts first, then
+// github.com and hosts named after GitLab or Gitea. Anything else is taken
+// for GitHub (Enterprise).
+func For(repo string) Forge {
+	host := Host(repo)
+	var cfg config.Forge
+	if c, err := config.Load(); err == nil {
+		for _, f := range c.Forges {
+			if strings.EqualFold(f.Host, host) {
+				cfg = f
+				break
+			}
+		}
+	}
+	kind := cfg.Type
+	if kind == "" {
+		switch {
+		case strings.Contains(host, "gitlab"):
+			kind = GitLab
+		case strings.Contains(host, "gitea"), strings.Contains(host, "codeberg"):
+			kind = Gitea
+		default:
+			kind = GitHub
+		}
+	}
+	api := strings.TrimSuffix(cfg.APIURL, "/")
+	switch kind {
+	case GitLab:
+		if api == "" {
+			api = "https://" + host + "/api/v4"
+		}
+		return &gitlab{host: host, api: api, tokenEnv: cfg.TokenEnv}
+	case Gitea:
+		if api == "" {
+			api = "https://" + host + "/api/v1"
+		}
+		return &gitea{host: host, api: api, tokenEnv: cfg.TokenEnv}
+	}
+	return &github{host: host, tokenEnv: cfg.TokenEnv}
+}
+
+// Host returns the host of a repo URL (https or scp-style ssh); an
+// "owner/repo" shorthand is on github.com.
+func Host(repo string) string {
+	repo = strings.TrimSpace(repo)
+	if i := strings.Index(repo, "://"); i >= 0 {
+		if u, err := url.Parse(repo); err == nil && u.Host != "" {
+			return strings.ToLower(u.Hostname())
+		}
+		return ""
+	}
+	if at := strings.Index(repo, "@"); at >= 0 {
+		if colon := strings.Index(repo[at:], ":"); colon >= 0 {
+			return strings.ToLower(repo[at+1 : at+colon])
+		}
+	}
+	return "github.com"
+}
+
+// Path returns a repo's path on its host: "owner/repo", or
+// "group/subgroup/repo" on GitLab.
+func Path(repo string) string {
+	repo = strings.TrimSuffix(strings.TrimSpace(repo), "/")
+	repo = strings.TrimSuffix(repo, ".git")
+	if i := strings.Index(repo, "://"); i >= 0 {
+		if u, err := url.Parse(repo); err == nil {
+			return strings.Trim(u.Path, "/")
+		}
+	}
+	if at := strings.Index(repo, "@"); at >= 0 {
+		if colon := strings.Index(repo[at:], ":"); colon >= 0 {
+			return repo[at+colon+1:]
+		}
+	}
+	return repo
+}
+
+// CloneURL returns repo's https URL with token in it, for clones where a
+// credential helper isn't set up. SSH URLs and an empty token are returned
+// unchanged.
+func CloneURL(f Forge, repo, token string) string {
+	if token == "" || !strings.HasPrefix(repo, "https://") {
+		return repo
+	}
+	user, pass := f.Credentials(token)
+	userinfo := url.PathEscape(user)
+	if pass != "" {
+		userinfo += ":" + url.PathEscape(pass)
+	}
+	return strings.Replace(repo, "https://", "https://"+userinfo+"@", 1)
+}
+
+// AuthEnv returns the environment that has git send token to the host of
+// an https repo as a header, or nil. It goes in git's environment (git
+// 2.31+ reads GIT_CONFIG_COUNT) rather than its arguments, which any user
+// can read with ps, and is never written to disk.
+func AuthEnv(f Forge, repo, token string) []string {
+	if token == "" || !strings.HasPrefix(repo, "https://") {
+		return nil
+	}
+	user, pass := f.Credentials(token)
+	basic := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		fmt.Sprintf("GIT_CONFIG_KEY_0=http.https://%s/.extraheader", Host(repo)),
+		"GIT_CONFIG_VALUE_0=AUTHORIZATION: basic " + basic,
+	}
+}
+
+// envToken returns the first of the variables that is set.
+func envToken(vars ...string) string {
+	for _, v := range vars {
+		if v == "" {
+			continue
+		}
+		if t := os.Getenv(v); t != "" {
+			return t
+		}
+	}
+	return ""
+}
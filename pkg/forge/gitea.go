@@ -0,0 +1,127 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// gitea talks to the REST API of Gitea (and Forgejo, e.g. Codeberg).
+type gitea struct {
+	host     string
+	api      string // e.g. https://gitea.example.com/api/v1
+	tokenEnv string
+}
+
+func (g *gitea) Type() string     { return Gitea }
+func (g *gitea) TokenEnv() string { return "GITEA_TOKEN" }
+
+func (g *gitea) Token() string {
+	return envToken(g.tokenEnv, "GITEA_TOKEN")
+}
+
+// Credentials: Gitea accepts a token as the user name, with no password.
+func (g *gitea) Credentials(token string) (string, string) {
+	return token, ""
+}
+
+func (g *gitea) call(method, path string, in, out any) error {
+	token := g.Token()
+	return apiCall(method, g.api+path, func(r *http.Request) {
+		if token != "" {
+			r.Header.Set("Authorization", "token "+token)
+		}
+	}, in, out)
+}
+
+type giteaPR struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+func (p giteaPR) pr() *PR {
+	return &PR{Number: p.Number, URL: p.HTMLURL, Body: p.Body}
+}
+
+func (g *gitea) FindPR(repo, branch string) (*PR, error) {
+	var prs []giteaPR
+	if err := g.call("GET", "/repos/"+Path(repo)+"/pulls?state=open&limit=50", nil, &prs); err != nil {
+		return nil, err
+	}
+	for _, p := range prs {
+		if p.Head.Ref == branch {
+			return p.pr(), nil
+		}
+	}
+	return nil, fmt.Errorf("no open pull request for %s", branch)
+}
+
+func (g *gitea) CreatePR(repo string, pr NewPR) (*PR, error) {
+	title := pr.Title
+	if pr.Draft {
+		title = "WIP: " + title
+	}
+	in := map[string]any{"head": pr.Head, "base": pr.Base, "title": title, "body": pr.Body}
+	var created giteaPR
+	if err := g.call("POST", "/repos/"+Path(repo)+"/pulls", in, &created); err != nil {
+		return nil, err
+	}
+	return created.pr(), nil
+}
+
+func (g *gitea) SetPRBody(repo string, pr *PR, body string) error {
+	return g.call("PATCH", fmt.Sprintf("/repos/%s/pulls/%d", Path(repo), pr.Number), map[string]any{"body": body}, nil)
+}
+
+// CIStatus reads the combined commit status of branch's head.
+func (g *gitea) CIStatus(repo, branch string) (string, error) {
+	var status struct {
+		State      string `json:"state"`
+		TotalCount int    `json:"total_count"`
+	}
+	if err := g.call("GET", "/repos/"+Path(repo)+"/commits/"+branch+"/status", nil, &status); err != nil {
+		return "", err
+	}
+	switch status.State {
+	case "success", "warning":
+		return CIPass, nil
+	case "failure", "error":
+		return CIFail, nil
+	case "pending":
+		return CIPending, nil
+	}
+	return CINone, nil
+}
+
+func (g *gitea) Issues(repo, label string) ([]Issue, error) {
+	var list []struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		Labels  []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	q := url.Values{"state": {"open"}, "type": {"issues"}, "limit": {"50"}}
+	if label != "" {
+		q.Set("labels", label)
+	}
+	if err := g.call("GET", "/repos/"+Path(repo)+"/issues?"+q.Encode(), nil, &list); err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, len(list))
+	for i, it := range list {
+		issues[i] = Issue{Number: it.Number, Title: it.Title, Body: it.Body, URL: it.HTMLURL}
+		for _, l := range it.Labels {
+			issues[i].Labels = append(issues[i].Labels, l.Name)
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	return issues, nil
+}
@@ -0,0 +1,68 @@
+package forge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitea(t *testing.T) {
+	var edited map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/acme/api/pulls":
+			w.Write([]byte(`[{"number": 3, "html_url": "https://gitea.example/acme/api/pulls/3", "body": "old", "head": {"ref": "other"}},
+				{"number": 4, "html_url": "https://gitea.example/acme/api/pulls/4", "body": "mine", "head": {"ref": "fix"}}]`))
+		case "POST /repos/acme/api/pulls":
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			if in["head"] != "fix" || in["base"] != "main" || in["title"] != "Fix it" || in["body"] != "summary" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"number": 5, "html_url": "https://gitea.example/acme/api/pulls/5"}`))
+		case "PATCH /repos/acme/api/pulls/4":
+			json.NewDecoder(r.Body).Decode(&edited)
+			w.Write([]byte(`{}`))
+		case "GET /repos/acme/api/issues":
+			if r.URL.Query().Get("labels") != "agent-ok" || r.URL.Query().Get("type") != "issues" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"number": 9, "title": "Newer", "html_url": "https://gitea.example/acme/api/issues/9"},
+				{"number": 2, "title": "Typo", "body": "in README", "labels": [{"name": "agent-ok"}]}]`))
+		case "GET /repos/acme/api/commits/fix/status":
+			w.Write([]byte(`{"state": "failure", "total_count": 2}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITEA_TOKEN", "tok")
+	g := &gitea{host: "gitea.example", api: srv.URL}
+	repo := "https://gitea.example/acme/api"
+
+	pr, err := g.FindPR(repo, "fix")
+	if err != nil || pr.Number != 4 || pr.Body != "mine" {
+		t.Fatalf("FindPR = %+v, %v", pr, err)
+	}
+	if err := g.SetPRBody(repo, pr, "new"); err != nil || edited["body"] != "new" {
+		t.Errorf("SetPRBody: %v, sent %v", err, edited)
+	}
+	created, err := g.CreatePR(repo, NewPR{Head: "fix", Base: "main", Title: "Fix it", Body: "summary"})
+	if err != nil || created.URL != "https://gitea.example/acme/api/pulls/5" {
+		t.Errorf("CreatePR = %+v, %v", created, err)
+	}
+	if status, err := g.CIStatus(repo, "fix"); err != nil || status != CIFail {
+		t.Errorf("CIStatus = %s, %v", status, err)
+	}
+	issues, err := g.Issues(repo, "agent-ok")
+	if err != nil || len(issues) != 2 || issues[0].Number != 2 || issues[0].Labels[0] != "agent-ok" {
+		t.Errorf("Issues = %+v, %v", issues, err)
+	}
+}
@@ -0,0 +1,190 @@
+package forge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// github talks to GitHub and GitHub Enterprise through the gh CLI, which
+// brings its own authentication.
+type github struct {
+	host     string
+	tokenEnv string // configured variable, tried first
+}
+
+func (g *github) Type() string     { return GitHub }
+func (g *github) TokenEnv() string { return "GH_TOKEN" }
+
+// Token checks both common variables, then falls back to gh.
+func (g *github) Token() string {
+	if t := envToken(g.tokenEnv, "GH_TOKEN", "GITHUB_TOKEN"); t != "" {
+		return t
+	}
+	if out, err := exec.Command("gh", "auth", "token", "--hostname", g.host).Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
+
+func (g *github) Credentials(token string) (string, string) {
+	return "x-access-token", token
+}
+
+// repoArg is how gh names repo: with the host when it isn't github.com.
+func (g *github) repoArg(repo string) string {
+	if g.host == "github.com" {
+		return Path(repo)
+	}
+	return g.host + "/" + Path(repo)
+}
+
+// gh runs the gh CLI. On failure the output is still returned, alongside
+// an error that includes gh's message.
+func (g *github) gh(stdin string, args ...string) ([]byte, error) {
+	cmd := exec.Command("gh", args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return out, fmt.Errorf("gh %s: %w: %s", args[0], err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return out, fmt.Errorf("gh %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+func (g *github) FindPR(repo, branch string) (*PR, error) {
+	out, err := g.gh("", "pr", "view", branch, "--repo", g.repoArg(repo), "--json", "number,url,body,state")
+	if err != nil {
+		return nil, fmt.Errorf("no open PR for %s: %w", branch, err)
+	}
+	var pr struct {
+		Number int    `json:"number"`
+		URL    string `json:"url"`
+		Body   string `json:"body"`
+		State  string `json:"state"`
+	}
+	if err := json.Unmarshal(out, &pr); err != nil {
+		return nil, fmt.Errorf("cannot parse gh output: %w", err)
+	}
+	if pr.State != "" && pr.State != "OPEN" {
+		return nil, fmt.Errorf("no open PR for %s (#%d is %s)", branch, pr.Number, strings.ToLower(pr.State))
+	}
+	return &PR{Number: pr.Number, URL: pr.URL, Body: pr.Body}, nil
+}
+
+func (g *github) CreatePR(repo string, pr NewPR) (*PR, error) {
+	args := []string{"pr", "create", "--repo", g.repoArg(repo), "--head", pr.Head, "--title", pr.Title, "--body-file", "-"}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+	out, err := g.gh(pr.Body, args...)
+	if err != nil {
+		return nil, err
+	}
+	// gh prints the new PR's URL, ending in its number.
+	url := strings.TrimSpace(string(out))
+	if lines := strings.Split(url, "\n"); len(lines) > 1 {
+		url = lines[len(lines)-1]
+	}
+	n, _ := strconv.Atoi(url[strings.LastIndex(url, "/")+1:])
+	return &PR{Number: n, URL: url, Body: pr.Body}, nil
+}
+
+func (g *github) SetPRBody(repo string, pr *PR, body string) error {
+	_, err := g.gh(body, "pr", "edit", strconv.Itoa(pr.Number), "--repo", g.repoArg(repo), "--body-file", "-")
+	return err
+}
+
+// CIStatus reads the checks of branch's PR.
+func (g *github) CIStatus(repo, branch string) (string, error) {
+	out, err := g.gh("", "pr", "checks", branch, "--repo", g.repoArg(repo), "--json", "bucket")
+	if err != nil {
+		if strings.Contains(err.Error(), "no checks reported") {
+			return CINone, nil
+		}
+		// gh also fails while checks are pending or failing, with the JSON
+		// still printed.
+		if len(out) == 0 {
+			return "", err
+		}
+	}
+	var checks []struct {
+		Bucket string `json:"bucket"`
+	}
+	if err := json.Unmarshal(out, &checks); err != nil {
+		return "", fmt.Errorf("cannot parse gh output: %w", err)
+	}
+	states := make([]string, len(checks))
+	for i, c := range checks {
+		switch c.Bucket {
+		case "fail", "cancel":
+			states[i] = CIFail
+		case "pending":
+			states[i] = CIPending
+		default: // pass, skipping
+			states[i] = CIPass
+		}
+	}
+	return combineCI(states), nil
+}
+
+// combineCI sums up individual CI results: any failure fails, then any
+// pending run is pending.
+func combineCI(states []string) string {
+	if len(states) == 0 {
+		return CINone
+	}
+	result := CIPass
+	for _, s := range states {
+		switch s {
+		case CIFail:
+			return CIFail
+		case CIPending:
+			result = CIPending
+		}
+	}
+	return result
+}
+
+func (g *github) Issues(repo, label string) ([]Issue, error) {
+	args := []string{"issue", "list", "--repo", g.repoArg(repo), "--state", "open", "--limit", "200",
+		"--json", "number,title,body,url,labels,createdAt"}
+	if label != "" {
+		args = append(args, "--label", label)
+	}
+	out, err := g.gh("", args...)
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+		URL    string `json:"url"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("cannot parse gh output: %w", err)
+	}
+	// gh lists newest first.
+	issues := make([]Issue, len(list))
+	for i, it := range list {
+		is := Issue{Number: it.Number, Title: it.Title, Body: it.Body, URL: it.URL}
+		for _, l := range it.Labels {
+			is.Labels = append(is.Labels, l.Name)
+		}
+		issues[len(list)-1-i] = is
+	}
+	return issues, nil
+}
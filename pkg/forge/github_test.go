@@ -0,0 +1,64 @@
+package forge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGH puts a gh script on PATH that logs its arguments and stdin.
+func fakeGH(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	body := "#!/bin/sh\necho \"$*\" >> " + log + "\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "gh"), []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestGitHub(t *testing.T) {
+	log := fakeGH(t, `case "$*" in
+"pr view"*) echo '{"number": 12, "url": "https://ghe.corp/acme/api/pull/12", "body": "old", "state": "OPEN"}' ;;
+"pr create"*) cat > /dev/null; echo "https://ghe.corp/acme/api/pull/13" ;;
+"pr edit"*) cat > /dev/null ;;
+"pr checks"*) echo '[{"bucket": "pass"}, {"bucket": "pending"}]'; exit 8 ;;
+"issue list"*) echo '[{"number": 30, "title": "Newer"}, {"number": 21, "title": "Typo", "labels": [{"name": "agent-ok"}]}]' ;;
+esac`)
+	g := &github{host: "ghe.corp"}
+	repo := "https://ghe.corp/acme/api"
+
+	pr, err := g.FindPR(repo, "fix")
+	if err != nil || pr.Number != 12 {
+		t.Fatalf("FindPR = %+v, %v", pr, err)
+	}
+	created, err := g.CreatePR(repo, NewPR{Head: "fix", Base: "main", Title: "Fix it", Draft: true})
+	if err != nil || created.Number != 13 {
+		t.Errorf("CreatePR = %+v, %v", created, err)
+	}
+	if err := g.SetPRBody(repo, pr, "new"); err != nil {
+		t.Errorf("SetPRBody: %v", err)
+	}
+	if status, err := g.CIStatus(repo, "fix"); err != nil || status != CIPending {
+		t.Errorf("CIStatus = %s, %v", status, err)
+	}
+	issues, err := g.Issues(repo, "agent-ok")
+	if err != nil || len(issues) != 2 || issues[0].Number != 21 || issues[0].Labels[0] != "agent-ok" {
+		t.Errorf("Issues = %+v, %v", issues, err)
+	}
+	calls, _ := os.ReadFile(log)
+	for _, want := range []string{
+		"pr view fix --repo ghe.corp/acme/api",
+		"pr create --repo ghe.corp/acme/api --head fix --title Fix it --body-file - --base main --draft",
+		"pr edit 12 --repo ghe.corp/acme/api --body-file -",
+		"issue list --repo ghe.corp/acme/api --state open",
+		"--label agent-ok",
+	} {
+		if !strings.Contains(string(calls), want) {
+			t.Errorf("gh calls lack %q:\n%s", want, calls)
+		}
+	}
+}
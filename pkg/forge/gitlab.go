@@ -0,0 +1,128 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// gitlab talks to GitLab's REST API (v4), where pull requests are merge
+// requests.
+type gitlab struct {
+	host     string
+	api      string // e.g. https://gitlab.example.com/api/v4
+	tokenEnv string
+}
+
+func (g *gitlab) Type() string     { return GitLab }
+func (g *gitlab) TokenEnv() string { return "GITLAB_TOKEN" }
+
+func (g *gitlab) Token() string {
+	return envToken(g.tokenEnv, "GITLAB_TOKEN", "GL_TOKEN")
+}
+
+// Credentials: GitLab takes any token as the password of "oauth2".
+func (g *gitlab) Credentials(token string) (string, string) {
+	return "oauth2", token
+}
+
+func (g *gitlab) call(method, path string, in, out any) error {
+	token := g.Token()
+	return apiCall(method, g.api+path, func(r *http.Request) {
+		if token != "" {
+			r.Header.Set("PRIVATE-TOKEN", token)
+		}
+	}, in, out)
+}
+
+// project is the API's name for repo: its path, escaped.
+func (g *gitlab) project(repo string) string {
+	return "/projects/" + url.PathEscape(Path(repo))
+}
+
+type gitlabMR struct {
+	IID         int    `json:"iid"`
+	WebURL      string `json:"web_url"`
+	Description string `json:"description"`
+}
+
+func (m gitlabMR) pr() *PR {
+	return &PR{Number: m.IID, URL: m.WebURL, Body: m.Description}
+}
+
+func (g *gitlab) FindPR(repo, branch string) (*PR, error) {
+	var mrs []gitlabMR
+	q := url.Values{"source_branch": {branch}, "state": {"opened"}}
+	if err := g.call("GET", g.project(repo)+"/merge_requests?"+q.Encode(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, fmt.Errorf("no open merge request for %s", branch)
+	}
+	return mrs[0].pr(), nil
+}
+
+func (g *gitlab) CreatePR(repo string, pr NewPR) (*PR, error) {
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	in := map[string]any{
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+		"title":         title,
+		"description":   pr.Body,
+	}
+	var mr gitlabMR
+	if err := g.call("POST", g.project(repo)+"/merge_requests", in, &mr); err != nil {
+		return nil, err
+	}
+	return mr.pr(), nil
+}
+
+func (g *gitlab) SetPRBody(repo string, pr *PR, body string) error {
+	return g.call("PUT", fmt.Sprintf("%s/merge_requests/%d", g.project(repo), pr.Number), map[string]any{"description": body}, nil)
+}
+
+// CIStatus reads the latest pipeline for branch.
+func (g *gitlab) CIStatus(repo, branch string) (string, error) {
+	var pipelines []struct {
+		Status string `json:"status"`
+	}
+	q := url.Values{"ref": {branch}, "per_page": {"1"}}
+	if err := g.call("GET", g.project(repo)+"/pipelines?"+q.Encode(), nil, &pipelines); err != nil {
+		return "", err
+	}
+	if len(pipelines) == 0 {
+		return CINone, nil
+	}
+	switch pipelines[0].Status {
+	case "success", "skipped", "manual":
+		return CIPass, nil
+	case "failed", "canceled":
+		return CIFail, nil
+	}
+	return CIPending, nil
+}
+
+func (g *gitlab) Issues(repo, label string) ([]Issue, error) {
+	var list []struct {
+		IID         int      `json:"iid"`
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		WebURL      string   `json:"web_url"`
+		Labels      []string `json:"labels"`
+	}
+	q := url.Values{"state": {"opened"}, "order_by": {"created_at"}, "sort": {"asc"}, "per_page": {"100"}}
+	if label != "" {
+		q.Set("labels", label)
+	}
+	if err := g.call("GET", g.project(repo)+"/issues?"+q.Encode(), nil, &list); err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, len(list))
+	for i, it := range list {
+		issues[i] = Issue{Number: it.IID, Title: it.Title, Body: it.Description, URL: it.WebURL, Labels: it.Labels}
+	}
+	return issues, nil
+}
@@ -0,0 +1,81 @@
+package forge
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitLab(t *testing.T) {
+	var edited map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.EscapedPath() {
+		case "GET /projects/acme%2Fplatform%2Fapi/merge_requests":
+			if r.URL.Query().Get("source_branch") != "fix" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"iid": 7, "web_url": "https://gitlab.example/mr/7", "description": "old"}]`))
+		case "POST /projects/acme%2Fplatform%2Fapi/merge_requests":
+			var in map[string]any
+			json.NewDecoder(r.Body).Decode(&in)
+			if in["source_branch"] != "fix" || in["target_branch"] != "main" || in["title"] != "Draft: Fix it" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"iid": 8, "web_url": "https://gitlab.example/mr/8"}`))
+		case "PUT /projects/acme%2Fplatform%2Fapi/merge_requests/7":
+			data, _ := io.ReadAll(r.Body)
+			json.Unmarshal(data, &edited)
+			w.Write([]byte(`{}`))
+		case "GET /projects/acme%2Fplatform%2Fapi/issues":
+			if r.URL.Query().Get("labels") != "agent-ok" || r.URL.Query().Get("state") != "opened" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"iid": 4, "title": "Typo", "description": "in README", "web_url": "https://gitlab.example/issues/4", "labels": ["agent-ok"]}]`))
+		case "GET /projects/acme%2Fplatform%2Fapi/pipelines":
+			w.Write([]byte(`[{"status": "running"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITLAB_TOKEN", "tok")
+	g := &gitlab{host: "gitlab.example", api: srv.URL}
+	repo := "https://gitlab.example/acme/platform/api.git"
+
+	pr, err := g.FindPR(repo, "fix")
+	if err != nil || pr.Number != 7 || pr.Body != "old" {
+		t.Fatalf("FindPR = %+v, %v", pr, err)
+	}
+	if _, err := g.FindPR(repo, "other"); err == nil {
+		t.Error("expected no merge request for another branch")
+	}
+	if err := g.SetPRBody(repo, pr, "new"); err != nil || edited["description"] != "new" {
+		t.Errorf("SetPRBody: %v, sent %v", err, edited)
+	}
+	created, err := g.CreatePR(repo, NewPR{Head: "fix", Base: "main", Title: "Fix it", Draft: true})
+	if err != nil || created.Number != 8 {
+		t.Errorf("CreatePR = %+v, %v", created, err)
+	}
+	if status, err := g.CIStatus(repo, "fix"); err != nil || status != CIPending {
+		t.Errorf("CIStatus = %s, %v", status, err)
+	}
+
+	issues, err := g.Issues(repo, "agent-ok")
+	if err != nil || len(issues) != 1 || issues[0].Number != 4 || issues[0].Body != "in README" {
+		t.Errorf("Issues = %+v, %v", issues, err)
+	}
+
+	t.Setenv("GITLAB_TOKEN", "wrong")
+	if _, err := g.FindPR(repo, "fix"); err == nil {
+		t.Error("expected an error with a bad token")
+	}
+}
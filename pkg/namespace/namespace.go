@@ -0,0 +1,104 @@
+// Package namespace isolates unrelated agent fleets on one host. Every
+// namespace gets its own container-name prefix, metadata and coordination
+// directories and host port block, so list/prune/kill in one namespace never
+// see agents from another. The default namespace keeps the original layout.
+package namespace
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Default is the unnamed namespace: no prefix, data directly under ~/.agentctl.
+const Default = ""
+
+// EnvVar selects the namespace when --namespace isn't given, so a shell or
+// CI job can stay in one namespace without repeating the flag.
+const EnvVar = "AGENTCTL_NAMESPACE"
+
+// Port blocks: the default namespace maps agents to 8000-8999; named
+// namespaces get one of portBlocks 1000-port blocks starting at 9000.
+const (
+	defaultPortBase = 8000
+	namedPortBase   = 9000
+	portBlocks      = 50
+	PortRange       = 1000
+)
+
+var valid = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,29}$`)
+
+var current = Default
+
+// Set selects the namespace for the rest of the process.
+func Set(ns string) error {
+	if err := Validate(ns); err != nil {
+		return err
+	}
+	current = ns
+	return nil
+}
+
+// Validate reports whether ns is usable as a namespace name.
+func Validate(ns string) error {
+	if ns != Default && !valid.MatchString(ns) {
+		return fmt.Errorf("invalid namespace %q: use up to 30 lowercase letters, digits and dashes", ns)
+	}
+	return nil
+}
+
+// Current returns the selected namespace.
+func Current() string {
+	return current
+}
+
+// Root returns the directory holding the current namespace's agent metadata,
+// history and coordination buses.
+func Root() string {
+	if current == Default {
+		return config.Dir()
+	}
+	return filepath.Join(config.Dir(), "namespaces", current)
+}
+
+// Container returns the podman container name for an agent.
+func Container(name string) string {
+	if current == Default {
+		return name
+	}
+	return current + "_" + name
+}
+
+// List returns the named namespaces that have data on this host.
+func List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(config.Dir(), "namespaces"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && Validate(e.Name()) == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// PortBase returns the first host port of the current namespace's block.
+func PortBase() int {
+	if current == Default {
+		return defaultPortBase
+	}
+	h := fnv.New32a()
+	h.Write([]byte(current))
+	return namedPortBase + int(h.Sum32()%portBlocks)*PortRange
+}
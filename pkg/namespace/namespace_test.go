@@ -0,0 +1,63 @@
+package namespace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, ns := range []string{"", "team", "exp-2"} {
+		if err := Validate(ns); err != nil {
+			t.Errorf("Validate(%q) = %v", ns, err)
+		}
+	}
+	for _, ns := range []string{"Team", "-x", "a_b", "a/b", "this-namespace-name-is-far-too-long"} {
+		if err := Validate(ns); err == nil {
+			t.Errorf("Validate(%q) should fail", ns)
+		}
+	}
+}
+
+func TestNamespacedPaths(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+	defer Set(Default)
+
+	if Root() != filepath.Join(tmpHome, ".agentctl") || Container("a") != "a" || PortBase() != 8000 {
+		t.Errorf("default namespace must keep the original layout: %s %s %d", Root(), Container("a"), PortBase())
+	}
+
+	if err := Set("exp"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Root(); got != filepath.Join(tmpHome, ".agentctl", "namespaces", "exp") {
+		t.Errorf("Root() = %s", got)
+	}
+	if got := Container("a"); got != "exp_a" {
+		t.Errorf("Container() = %s", got)
+	}
+	if base := PortBase(); base < 9000 || base+PortRange > 65536 || (base-9000)%PortRange != 0 {
+		t.Errorf("PortBase() = %d", base)
+	}
+}
+
+func TestList(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	if names, err := List(); err != nil || len(names) != 0 {
+		t.Fatalf("List() with no namespaces = %v, %v", names, err)
+	}
+	for _, d := range []string{"team-b", "exp", "Not_Valid"} {
+		os.MkdirAll(filepath.Join(tmpHome, ".agentctl", "namespaces", d, "agents"), 0755)
+	}
+	names, err := List()
+	if err != nil || len(names) != 2 || names[0] != "exp" || names[1] != "team-b" {
+		t.Errorf("List() = %v, %v", names, err)
+	}
+}
@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// IsDAG reports whether any step declares needs.
+func (p *Pipeline) IsDAG() bool {
+	for _, s := range p.Steps {
+		if len(s.Needs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Layers groups steps into stages where every step's needs lie in earlier
+// stages, preserving file order within a stage. It rejects duplicate step
+// names, unknown needs and cycles.
+func Layers(steps []Step) ([][]Step, error) {
+	index := make(map[string]int, len(steps))
+	for i, s := range steps {
+		if _, dup := index[s.Name]; dup {
+			return nil, fmt.Errorf("duplicate step %q", s.Name)
+		}
+		index[s.Name] = i
+	}
+	for _, s := range steps {
+		for _, n := range s.Needs {
+			if _, ok := index[n]; !ok {
+				return nil, fmt.Errorf("step %q needs unknown step %q", s.Name, n)
+			}
+		}
+	}
+
+	placed := make(map[string]bool, len(steps))
+	var layers [][]Step
+	for len(placed) < len(steps) {
+		var layer []Step
+		for _, s := range steps {
+			if placed[s.Name] || !allIn(s.Needs, placed) {
+				continue
+			}
+			layer = append(layer, s)
+		}
+		if len(layer) == 0 {
+			var stuck []string
+			for _, s := range steps {
+				if !placed[s.Name] {
+					stuck = append(stuck, s.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle among steps: %s", strings.Join(stuck, ", "))
+		}
+		for _, s := range layer {
+			placed[s.Name] = true
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// descendants returns from and every step that transitively needs it.
+func descendants(steps []Step, from string) map[string]bool {
+	set := map[string]bool{from: true}
+	for changed := true; changed; {
+		changed = false
+		for _, s := range steps {
+			if !set[s.Name] && anyIn(s.Needs, set) {
+				set[s.Name] = true
+				changed = true
+			}
+		}
+	}
+	return set
+}
+
+// stepResult is sent back by a finished DAG step.
+type stepResult struct {
+	index int
+	err   error
+}
+
+// executeDAG runs steps as their needs complete, in parallel where the graph
+// allows. With --from, only that step and its descendants run; everything
+// else is treated as already done.
+func executeDAG(p *Pipeline, name string, ws workspace, params map[string]string, opts Options) error {
+	layers, err := Layers(p.Steps)
+	if err != nil {
+		return err
+	}
+	run := map[string]bool{}
+	if opts.FromStep != "" {
+		found := false
+		for _, s := range p.Steps {
+			found = found || s.Name == opts.FromStep
+		}
+		if !found {
+			return fmt.Errorf("step %q not found in pipeline", opts.FromStep)
+		}
+		run = descendants(p.Steps, opts.FromStep)
+	}
+	selected := func(s Step) bool { return opts.FromStep == "" || run[s.Name] }
+
+	if opts.DryRun {
+		for i, layer := range layers {
+			fmt.Printf("[dry-run] stage %d:\n", i+1)
+			for _, s := range layer {
+				if !selected(s) {
+					fmt.Printf("  %s (skipped)\n", s.Name)
+					continue
+				}
+				fmt.Printf("  %s", s.Name)
+				if len(s.Needs) > 0 {
+					fmt.Printf(" (needs %s)", strings.Join(s.Needs, ", "))
+				}
+				fmt.Printf("\n    run: %s\n", s.Run)
+			}
+		}
+		return nil
+	}
+
+	record, err := newRunRecord(name, p, ws, params)
+	if err != nil {
+		return err
+	}
+	for i, s := range p.Steps {
+		if !selected(s) {
+			record.skip(i)
+		}
+	}
+	return runDAG(p, ws, params, record)
+}
+
+// runDAG schedules every step the record doesn't already show as done.
+// After a failure no new steps start; running ones finish and the failure is
+// reported once they have.
+func runDAG(p *Pipeline, ws workspace, params map[string]string, record *RunRecord) error {
+	done := make(map[string]bool)
+	started := make(map[int]bool)
+	for i, s := range p.Steps {
+		if record.done(i) {
+			done[s.Name] = true
+			started[i] = true
+		}
+	}
+
+	var outMu sync.Mutex
+	results := make(chan stepResult)
+	running := 0
+	var failed []string
+
+	for {
+		if len(failed) == 0 {
+			for i, s := range p.Steps {
+				if started[i] || !allIn(s.Needs, done) {
+					continue
+				}
+				started[i] = true
+				running++
+				env := buildEnv(ws.repo, ws.issue, ws.issueTitle, ws.cloneDir, ws.branch, ws.repoName, record.prNumber())
+				env = append(env, paramEnv(params)...)
+				fmt.Printf("▶ %s\n", s.Name)
+				go func(i int, s Step) {
+					stdout := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: "[" + s.Name + "] "}
+					stderr := &prefixWriter{mu: &outMu, w: os.Stderr, prefix: "[" + s.Name + "] "}
+					err := runWithPolicy(s, i, ws, env, record, stdout, stderr)
+					stdout.Flush()
+					stderr.Flush()
+					results <- stepResult{index: i, err: err}
+				}(i, s)
+			}
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		step := p.Steps[res.index]
+		if res.err != nil {
+			fmt.Printf("✗ %s failed: %v\n", step.Name, res.err)
+			failed = append(failed, step.Name)
+			continue
+		}
+		fmt.Printf("✓ %s\n", step.Name)
+		done[step.Name] = true
+
+		// After any step whose run contains "gh pr create", detect PR number.
+		if strings.Contains(step.Run, "gh pr create") {
+			if pr := detectPRNumber(ws.cloneDir, ws.branch); pr != "" {
+				record.setPRNumber(pr)
+				fmt.Printf("  detected PR #%s\n", pr)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("   resume with: agentctl pipeline resume %s\n", record.ID)
+		return fmt.Errorf("steps failed: %s", strings.Join(failed, ", "))
+	}
+	fmt.Println("✅ Pipeline complete")
+	return nil
+}
+
+// prefixWriter tags each output line of a parallel step with its name so
+// interleaved output stays readable.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.mu.Lock()
+		fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf[:i])
+		p.mu.Unlock()
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any trailing partial line.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) == 0 {
+		return
+	}
+	p.mu.Lock()
+	fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf)
+	p.mu.Unlock()
+	p.buf = nil
+}
+
+func allIn(names []string, set map[string]bool) bool {
+	for _, n := range names {
+		if !set[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func anyIn(names []string, set map[string]bool) bool {
+	for _, n := range names {
+		if set[n] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func layerNames(layers [][]Step) string {
+	var parts []string
+	for _, l := range layers {
+		var names []string
+		for _, s := range l {
+			names = append(names, s.Name)
+		}
+		parts = append(parts, strings.Join(names, ","))
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TestLayers(t *testing.T) {
+	steps := []Step{
+		{Name: "setup"},
+		{Name: "api", Needs: []string{"setup"}},
+		{Name: "ui", Needs: []string{"setup"}},
+		{Name: "integration", Needs: []string{"api", "ui"}},
+		{Name: "lint"},
+	}
+	layers, err := Layers(steps)
+	if err != nil {
+		t.Fatalf("Layers() error: %v", err)
+	}
+	if got, want := layerNames(layers), "setup,lint | api,ui | integration"; got != want {
+		t.Errorf("Layers() = %q, want %q", got, want)
+	}
+}
+
+func TestLayersErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []Step
+		wantErr string
+	}{
+		{"unknown need", []Step{{Name: "a", Needs: []string{"b"}}}, "unknown step"},
+		{"duplicate", []Step{{Name: "a"}, {Name: "a"}}, "duplicate"},
+		{"cycle", []Step{{Name: "a", Needs: []string{"b"}}, {Name: "b", Needs: []string{"a"}}}, "cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Layers(tt.steps)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	steps := []Step{
+		{Name: "setup"},
+		{Name: "api", Needs: []string{"setup"}},
+		{Name: "ui", Needs: []string{"setup"}},
+		{Name: "integration", Needs: []string{"api", "ui"}},
+	}
+	got := descendants(steps, "api")
+	if !got["api"] || !got["integration"] || got["ui"] || got["setup"] {
+		t.Errorf("descendants(api) = %v", got)
+	}
+}
+
+func TestRunRecordStages(t *testing.T) {
+	seq := &RunRecord{Steps: []StepRecord{{Name: "a"}, {Name: "b"}}}
+	if n := len(seq.Stages()); n != 2 {
+		t.Errorf("sequential run should have one stage per step, got %d", n)
+	}
+	dag := &RunRecord{Steps: []StepRecord{
+		{Name: "a"}, {Name: "b"}, {Name: "c", Needs: []string{"a", "b"}},
+	}}
+	stages := dag.Stages()
+	if len(stages) != 2 || len(stages[0]) != 2 {
+		t.Errorf("DAG stages = %v", stages)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &prefixWriter{mu: &sync.Mutex{}, w: &buf, prefix: "[api] "}
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	w.Flush()
+	if got, want := buf.String(), "[api] one\n[api] two\n[api] three\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,337 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Step statuses recorded in a RunRecord.
+const (
+	StepPending = "pending"
+	StepRunning = "running"
+	StepOK      = "ok"
+	StepFailed  = "failed"
+	StepSkipped = "skipped"
+)
+
+// StepRecord is the outcome of one step in a pipeline execution.
+type StepRecord struct {
+	Name       string    `json:"name"`
+	Needs      []string  `json:"needs,omitempty"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RunRecord is the persisted state of one pipeline execution, saved to
+// ~/.agentctl/pipelines/runs/<id>.json and rewritten after every step. It
+// keeps the resolved steps and workspace so the run can be resumed.
+type RunRecord struct {
+	ID         string            `json:"id"`
+	Pipeline   string            `json:"pipeline"`
+	Repo       string            `json:"repo"`
+	Issue      string            `json:"issue,omitempty"`
+	IssueTitle string            `json:"issue_title,omitempty"`
+	Branch     string            `json:"branch"`
+	CloneDir   string            `json:"clone_dir"`
+	PRNumber   string            `json:"pr_number,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	Status     string            `json:"status"` // "running", "success", "failed"
+	PID        int               `json:"pid,omitempty"`
+	Resumes    int               `json:"resumes,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at,omitempty"`
+	Steps      []StepRecord      `json:"steps"`
+	Spec       *Pipeline         `json:"spec,omitempty"`
+
+	mu sync.Mutex // DAG steps update the record concurrently
+}
+
+func runsDir() string {
+	return filepath.Join(config.Dir(), "pipelines", "runs")
+}
+
+// newRunRecord records the start of a run. Runs of one pipeline started in
+// the same second (a webhook and a schedule firing together) get distinct
+// IDs: the record file is reserved with O_EXCL before anything is written.
+func newRunRecord(name string, p *Pipeline, ws workspace, params map[string]string) (*RunRecord, error) {
+	now := time.Now()
+	id, err := reserveRunID(fmt.Sprintf("%s-%s", name, now.Format("20060102-150405")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record pipeline run: %w", err)
+	}
+	r := &RunRecord{
+		ID:         id,
+		Pipeline:   name,
+		Repo:       ws.repo,
+		Issue:      ws.issue,
+		IssueTitle: ws.issueTitle,
+		Branch:     ws.branch,
+		CloneDir:   ws.cloneDir,
+		Params:     params,
+		Status:     "running",
+		PID:        os.Getpid(),
+		StartedAt:  now,
+		Spec:       p,
+	}
+	for _, s := range p.Steps {
+		r.Steps = append(r.Steps, StepRecord{Name: s.Name, Needs: s.Needs, Status: StepPending})
+	}
+	if err := r.save(); err != nil {
+		return nil, fmt.Errorf("failed to record pipeline run: %w", err)
+	}
+	return r, nil
+}
+
+// reserveRunID claims base, or base-2, base-3... if another run already
+// holds it, by creating its record file exclusively.
+func reserveRunID(base string) (string, error) {
+	if err := os.MkdirAll(runsDir(), 0755); err != nil {
+		return "", err
+	}
+	for n := 1; n <= 100; n++ {
+		id := base
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		f, err := os.OpenFile(filepath.Join(runsDir(), id+".json"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return id, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free run ID for %s", base)
+}
+
+// workspace rebuilds the clone description the run was started with.
+func (r *RunRecord) workspace() workspace {
+	return workspace{
+		repo:       r.Repo,
+		repoName:   repoBaseName(r.Repo),
+		issue:      r.Issue,
+		issueTitle: r.IssueTitle,
+		cloneDir:   r.CloneDir,
+		branch:     r.Branch,
+	}
+}
+
+// done reports whether step i needs no further work.
+func (r *RunRecord) done(i int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Steps[i].Status == StepOK || r.Steps[i].Status == StepSkipped
+}
+
+func (r *RunRecord) prNumber() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.PRNumber
+}
+
+func (r *RunRecord) setPRNumber(pr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PRNumber = pr
+	r.update()
+}
+
+func (r *RunRecord) skip(i int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps[i].Status = StepSkipped
+	r.update()
+}
+
+func (r *RunRecord) start(i int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps[i].Status = StepRunning
+	r.Steps[i].Attempts++
+	r.Steps[i].StartedAt = time.Now()
+	r.Steps[i].Error = ""
+	r.update()
+}
+
+// finish marks step i done. The run itself is finished when a step fails or
+// every step has succeeded or been skipped.
+func (r *RunRecord) finish(i int, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps[i].FinishedAt = time.Now()
+	if err != nil {
+		r.Steps[i].Status = StepFailed
+		r.Steps[i].Error = err.Error()
+		r.Status = "failed"
+		r.FinishedAt = r.Steps[i].FinishedAt
+	} else {
+		r.Steps[i].Status = StepOK
+		if r.Status != "failed" && r.allDone() {
+			r.Status = "success"
+			r.FinishedAt = r.Steps[i].FinishedAt
+		}
+	}
+	r.update()
+}
+
+// failSkip records a failed step whose on_fail handler let the run continue.
+func (r *RunRecord) failSkip(i int, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Steps[i].FinishedAt = time.Now()
+	r.Steps[i].Status = StepSkipped
+	r.Steps[i].Error = err.Error()
+	if r.Status != "failed" && r.allDone() {
+		r.Status = "success"
+		r.FinishedAt = r.Steps[i].FinishedAt
+	}
+	r.update()
+}
+
+// allDone reports whether every step has succeeded or been skipped.
+func (r *RunRecord) allDone() bool {
+	for _, s := range r.Steps {
+		if s.Status != StepOK && s.Status != StepSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// Stages groups the recorded steps for display: DAG runs by dependency
+// depth, sequential runs one step per stage.
+func (r *RunRecord) Stages() [][]StepRecord {
+	steps := make([]Step, len(r.Steps))
+	byName := make(map[string]StepRecord, len(r.Steps))
+	for i, s := range r.Steps {
+		steps[i] = Step{Name: s.Name, Needs: s.Needs}
+		byName[s.Name] = s
+	}
+	p := &Pipeline{Steps: steps}
+	var stages [][]StepRecord
+	if layers, err := Layers(steps); err == nil && p.IsDAG() {
+		for _, layer := range layers {
+			var stage []StepRecord
+			for _, s := range layer {
+				stage = append(stage, byName[s.Name])
+			}
+			stages = append(stages, stage)
+		}
+		return stages
+	}
+	for _, s := range r.Steps {
+		stages = append(stages, []StepRecord{s})
+	}
+	return stages
+}
+
+// LoadRun loads a recorded pipeline execution by ID.
+func LoadRun(id string) (*RunRecord, error) {
+	data, err := os.ReadFile(filepath.Join(runsDir(), id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("pipeline run not found: %s", id)
+	}
+	var r RunRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline run: %w", err)
+	}
+	return &r, nil
+}
+
+// save writes the record atomically, so a crash mid-write can't leave
+// pipeline resume a torn file.
+func (r *RunRecord) save() error {
+	if err := os.MkdirAll(runsDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(runsDir(), r.ID+".json"), data)
+}
+
+// update saves the record after a step changes. A failed save is reported
+// but doesn't fail the pipeline: the steps themselves still ran.
+func (r *RunRecord) update() {
+	if err := r.save(); err != nil {
+		fmt.Printf("⚠️  Could not record pipeline run %s: %v\n", r.ID, err)
+	}
+}
+
+// writeFileAtomic replaces path with data through a temp file and a rename.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// ListRuns returns recorded pipeline executions, oldest first.
+func ListRuns() ([]*RunRecord, error) {
+	entries, err := os.ReadDir(runsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []*RunRecord
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(runsDir(), e.Name()))
+		if err != nil {
+			continue
+		}
+		var r RunRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			continue
+		}
+		runs = append(runs, &r)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	return runs, nil
+}
@@ -0,0 +1,24 @@
+package pipeline
+
+import "testing"
+
+func TestNewRunRecordIDsAreUnique(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	p := &Pipeline{Steps: []Step{{Name: "build", Run: "true"}}}
+
+	// Started in the same second, the runs must not share a record.
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		r, err := newRunRecord("nightly", p, workspace{repo: "org/repo"}, nil)
+		if err != nil {
+			t.Fatalf("newRunRecord() error: %v", err)
+		}
+		if seen[r.ID] {
+			t.Fatalf("run ID %s reused", r.ID)
+		}
+		seen[r.ID] = true
+		if _, err := LoadRun(r.ID); err != nil {
+			t.Errorf("LoadRun(%s) error: %v", r.ID, err)
+		}
+	}
+}
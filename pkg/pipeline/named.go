@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// Names returns the named pipelines defined in config, sorted.
+func Names(cfg *config.Config) []string {
+	var names []string
+	for name := range cfg.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve turns a named pipeline from config into runnable steps and its
+// final parameter set. Step names are looked up in pipeline_steps first,
+// then in the built-in default pipeline. Supplied params override the
+// pipeline's defaults; a default left empty makes the param required.
+func Resolve(cfg *config.Config, name string, params map[string]string) (*Pipeline, map[string]string, error) {
+	def, ok := cfg.Pipelines[name]
+	if !ok {
+		if names := Names(cfg); len(names) > 0 {
+			return nil, nil, fmt.Errorf("no pipeline named %q (available: %s)", name, strings.Join(names, ", "))
+		}
+		return nil, nil, fmt.Errorf("no pipeline named %q (none defined under \"pipelines\" in %s)", name, config.Path())
+	}
+	if len(def.Steps) == 0 {
+		return nil, nil, fmt.Errorf("pipeline %q has no steps", name)
+	}
+
+	p := &Pipeline{}
+	for _, stepName := range def.Steps {
+		run, ok := cfg.PipelineSteps[stepName]
+		if !ok {
+			run, ok = builtinStep(stepName)
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("pipeline %q: unknown step %q", name, stepName)
+		}
+		p.Steps = append(p.Steps, Step{Name: stepName, Run: run, Needs: def.Needs[stepName]})
+	}
+	for stepName := range def.Needs {
+		if !hasStep(p, stepName) {
+			return nil, nil, fmt.Errorf("pipeline %q: needs given for unknown step %q", name, stepName)
+		}
+	}
+	if _, err := Layers(p.Steps); err != nil {
+		return nil, nil, fmt.Errorf("pipeline %q: %w", name, err)
+	}
+
+	merged := map[string]string{"repo": ""} // every pipeline needs a repo
+	for k, v := range def.Params {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	var missing []string
+	for k, v := range merged {
+		if v == "" {
+			missing = append(missing, "--"+k)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, nil, fmt.Errorf("pipeline %q requires %s", name, strings.Join(missing, ", "))
+	}
+	return p, merged, nil
+}
+
+// RunNamed executes a pipeline defined under "pipelines" in config. The repo
+// comes from the "repo" param; "issue", when given, names the branch and
+// fills ISSUE/ISSUE_TITLE as for file pipelines.
+func RunNamed(name string, params map[string]string, opts Options) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	p, params, err := Resolve(cfg, name, params)
+	if err != nil {
+		return err
+	}
+
+	label := name
+	if params["issue"] != "" {
+		label = params["issue"]
+	}
+	ws, err := prepare(params["repo"], params["issue"], label, opts)
+	if err != nil {
+		return err
+	}
+	return execute(p, name, ws, params, opts)
+}
+
+func hasStep(p *Pipeline, name string) bool {
+	for _, s := range p.Steps {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func builtinStep(name string) (string, bool) {
+	for _, s := range defaultPipeline.Steps {
+		if s.Name == name {
+			return s.Run, true
+		}
+	}
+	return "", false
+}
+
+// paramEnv exports params as KEY=value, upper-casing names and mapping - to _.
+func paramEnv(params map[string]string) []string {
+	var env []string
+	for k, v := range params {
+		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		env = append(env, key+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
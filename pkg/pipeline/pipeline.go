@@ -2,23 +2,35 @@ package pipeline
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/jordanpartridge/agentctl/pkg/process"
+
 	"gopkg.in/yaml.v3"
 )
 
-// Step is a single pipeline step.
+// Step is a single pipeline step. When any step declares Needs the pipeline
+// runs as a DAG: steps start as soon as everything they need has succeeded,
+// so independent steps run in parallel. Otherwise steps run in file order.
+//
+// A failing step is retried Retries times, waiting Backoff (doubling each
+// time) between attempts, and then handled according to OnFail.
 type Step struct {
-	Name string `yaml:"name"`
-	Run  string `yaml:"run"`
+	Name    string   `json:"name" yaml:"name"`
+	Run     string   `json:"run" yaml:"run"`
+	Needs   []string `json:"needs,omitempty" yaml:"needs,omitempty"`
+	Retries int      `json:"retries,omitempty" yaml:"retries,omitempty"`
+	Backoff string   `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	OnFail  string   `json:"on_fail,omitempty" yaml:"on_fail,omitempty"`
 }
 
 // Pipeline is the parsed pipeline.yml structure.
 type Pipeline struct {
-	Steps []Step `yaml:"steps"`
+	Steps []Step `json:"steps" yaml:"steps"`
 }
 
 // Options controls pipeline execution.
@@ -32,7 +44,8 @@ var defaultPipeline = Pipeline{
 	Steps: []Step{
 		{Name: "setup", Run: "composer install --no-interaction --quiet"},
 		{Name: "investigate", Run: `run-task "Read issue #$ISSUE. Map files. Write DESIGN.md."`},
-		{Name: "implement", Run: `run-task "Implement per DESIGN.md. TDD. Commit when green."`},
+		{Name: "implement", Run: `run-task "${TASK:-Implement per DESIGN.md. TDD. Commit when green.}"`},
+		{Name: "squash", Run: `agentctl squash --dir "$CLONE_DIR" --repo "$REPO" --task "${TASK:-$ISSUE_TITLE}"`},
 		{Name: "pr", Run: `gh pr create --title "$ISSUE_TITLE" --body "Closes #$ISSUE" --base master`},
 		{Name: "review", Run: "agentctl review $AGENTCTL_NAME"},
 		{Name: "merge", Run: "gh pr merge --squash --auto $PR_NUMBER"},
@@ -76,36 +89,69 @@ func loadFile(path string) (*Pipeline, error) {
 
 // Run executes the pipeline for the given repo and issue.
 func Run(repo, issue string, opts Options) error {
-	repoName := repoBaseName(repo)
-	cloneDir := filepath.Join("/tmp/agents", fmt.Sprintf("%s-%s", repoName, issue))
+	ws, err := prepare(repo, issue, issue, opts)
+	if err != nil {
+		return err
+	}
+
+	// Load pipeline.
+	p, err := Load(ws.cloneDir)
+	if err != nil {
+		return fmt.Errorf("loading pipeline: %w", err)
+	}
+
+	return execute(p, "pipeline.yml", ws, nil, opts)
+}
+
+// workspace is the clone a pipeline runs in.
+type workspace struct {
+	repo       string
+	repoName   string
+	issue      string
+	issueTitle string
+	cloneDir   string
+	branch     string
+}
+
+// prepare clones the repo into agents/<repo>-<label> in the temp directory
+// (/tmp on Linux) and checks out feature/<repo>-<label>.
+func prepare(repo, issue, label string, opts Options) (workspace, error) {
+	ws := workspace{repo: repo, repoName: repoBaseName(repo), issue: issue}
+	ws.cloneDir = filepath.Join(os.TempDir(), "agents", fmt.Sprintf("%s-%s", ws.repoName, label))
 
 	// Clone if needed.
 	if !opts.DryRun {
-		if err := ensureClone(repo, cloneDir); err != nil {
-			return fmt.Errorf("clone failed: %w", err)
+		if err := ensureClone(repo, ws.cloneDir); err != nil {
+			return ws, fmt.Errorf("clone failed: %w", err)
 		}
 	}
 
 	// Fetch issue title from gh.
-	issueTitle := ""
-	if !opts.DryRun {
-		issueTitle = fetchIssueTitle(repo, issue)
+	if !opts.DryRun && issue != "" {
+		ws.issueTitle = fetchIssueTitle(repo, issue)
 	}
 
 	// Build branch name.
-	branch := fmt.Sprintf("feature/%s-%s", repoName, issue)
+	ws.branch = fmt.Sprintf("feature/%s-%s", ws.repoName, label)
 
 	// Checkout/create branch.
 	if !opts.DryRun {
-		if err := ensureBranch(cloneDir, branch); err != nil {
-			return fmt.Errorf("branch setup failed: %w", err)
+		if err := ensureBranch(ws.cloneDir, ws.branch); err != nil {
+			return ws, fmt.Errorf("branch setup failed: %w", err)
 		}
 	}
+	return ws, nil
+}
 
-	// Load pipeline.
-	p, err := Load(cloneDir)
-	if err != nil {
-		return fmt.Errorf("loading pipeline: %w", err)
+// execute runs the steps of p in ws, recording per-step status in the
+// pipeline run history. params are exported to every step as upper-cased
+// environment variables.
+func execute(p *Pipeline, name string, ws workspace, params map[string]string, opts Options) error {
+	if err := validate(p); err != nil {
+		return err
+	}
+	if p.IsDAG() {
+		return executeDAG(p, name, ws, params, opts)
 	}
 
 	// Find starting step index.
@@ -124,48 +170,67 @@ func Run(repo, issue string, opts Options) error {
 		}
 	}
 
-	prNumber := ""
+	if opts.DryRun {
+		for i, step := range p.Steps {
+			if i >= startIdx {
+				fmt.Printf("[dry-run] step %d: %s\n  run: %s\n", i+1, step.Name, step.Run)
+			}
+		}
+		return nil
+	}
+
+	record, err := newRunRecord(name, p, ws, params)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < startIdx; i++ {
+		record.skip(i)
+	}
+	return runSequential(p, ws, params, record)
+}
 
+// runSequential runs, in order, every step the record doesn't already show
+// as done — all of them on a fresh run, the remainder on resume.
+func runSequential(p *Pipeline, ws workspace, params map[string]string, record *RunRecord) error {
 	for i, step := range p.Steps {
-		if i < startIdx {
+		if record.done(i) {
 			continue
 		}
 
 		// Inject current PR_NUMBER into env.
-		env := buildEnv(repo, issue, issueTitle, cloneDir, branch, repoName, prNumber)
-
-		if opts.DryRun {
-			fmt.Printf("[dry-run] step %d: %s\n  run: %s\n", i+1, step.Name, step.Run)
-			continue
-		}
+		env := buildEnv(ws.repo, ws.issue, ws.issueTitle, ws.cloneDir, ws.branch, ws.repoName, record.prNumber())
+		env = append(env, paramEnv(params)...)
 
 		fmt.Printf("▶ [%d/%d] %s\n", i+1, len(p.Steps), step.Name)
 
-		if err := runStep(step, cloneDir, env); err != nil {
+		if err := runWithPolicy(step, i, ws, env, record, os.Stdout, os.Stderr); err != nil {
+			fmt.Printf("   resume with: agentctl pipeline resume %s\n", record.ID)
 			return fmt.Errorf("step %q failed: %w", step.Name, err)
 		}
 
 		// After any step whose run contains "gh pr create", detect PR number.
 		if strings.Contains(step.Run, "gh pr create") {
-			prNumber = detectPRNumber(cloneDir, branch)
-			if prNumber != "" {
-				fmt.Printf("  detected PR #%s\n", prNumber)
+			if pr := detectPRNumber(ws.cloneDir, ws.branch); pr != "" {
+				record.setPRNumber(pr)
+				fmt.Printf("  detected PR #%s\n", pr)
 			}
 		}
 	}
 
-	if !opts.DryRun {
-		fmt.Println("✅ Pipeline complete")
-	}
+	fmt.Println("✅ Pipeline complete")
 	return nil
 }
 
 func runStep(step Step, cloneDir string, env []string) error {
-	cmd := exec.Command("sh", "-c", step.Run)
+	return runStepTo(step, cloneDir, env, os.Stdout, os.Stderr)
+}
+
+func runStepTo(step Step, cloneDir string, env []string, stdout, stderr io.Writer) error {
+	cmd := process.Shell(step.Run)
 	cmd.Dir = cloneDir
 	cmd.Env = env
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	return cmd.Run()
 }
 
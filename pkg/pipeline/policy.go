@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/coordination"
+)
+
+// on_fail handlers, applied once a step's retries are exhausted.
+const (
+	OnFailAbort    = "abort"    // stop the pipeline (default)
+	OnFailRetry    = "retry"    // retry (DefaultRetries times unless retries is set), then abort
+	OnFailSkip     = "skip"     // record the failure and carry on as if the step passed
+	OnFailEscalate = "escalate" // stop and post an escalation on the repo's coordination bus
+)
+
+// DefaultRetries is used for on_fail: retry when retries isn't set.
+const DefaultRetries = 3
+
+// DefaultBackoff is the delay before the first retry; it doubles each time.
+const DefaultBackoff = 10 * time.Second
+
+// maxBackoff caps the exponential retry delay.
+const maxBackoff = 10 * time.Minute
+
+// validate checks the retry policies of every step.
+func validate(p *Pipeline) error {
+	for _, s := range p.Steps {
+		switch s.OnFail {
+		case "", OnFailAbort, OnFailRetry, OnFailSkip, OnFailEscalate:
+		default:
+			return fmt.Errorf("step %q: unknown on_fail %q (want abort, retry, skip or escalate)", s.Name, s.OnFail)
+		}
+		if s.Retries < 0 {
+			return fmt.Errorf("step %q: retries must not be negative", s.Name)
+		}
+		if s.Backoff != "" {
+			if _, err := time.ParseDuration(s.Backoff); err != nil {
+				return fmt.Errorf("step %q: invalid backoff %q: %w", s.Name, s.Backoff, err)
+			}
+		}
+	}
+	return nil
+}
+
+// attempts returns how many times the step may run in total.
+func (s Step) attempts() int {
+	retries := s.Retries
+	if retries == 0 && s.OnFail == OnFailRetry {
+		retries = DefaultRetries
+	}
+	return 1 + retries
+}
+
+// backoff returns the delay after the given failed attempt (1-based).
+func (s Step) backoff(attempt int) time.Duration {
+	base := DefaultBackoff
+	if d, err := time.ParseDuration(s.Backoff); err == nil {
+		base = d
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// retrySleep is swapped out in tests.
+var retrySleep = time.Sleep
+
+// runWithPolicy runs step i, retrying with backoff and applying its on_fail
+// handler. A nil error means the pipeline may continue: the step passed, or
+// failed with on_fail: skip.
+func runWithPolicy(step Step, i int, ws workspace, env []string, record *RunRecord, stdout, stderr io.Writer) error {
+	attempts := step.attempts()
+	var err error
+	for a := 1; a <= attempts; a++ {
+		record.start(i)
+		if err = runStepTo(step, ws.cloneDir, env, stdout, stderr); err == nil {
+			record.finish(i, nil)
+			return nil
+		}
+		if a < attempts {
+			delay := step.backoff(a)
+			fmt.Fprintf(stdout, "↻ %s failed (attempt %d/%d): %v — retrying in %s\n", step.Name, a, attempts, err, delay)
+			retrySleep(delay)
+		}
+	}
+
+	switch step.OnFail {
+	case OnFailSkip:
+		record.failSkip(i, err)
+		fmt.Fprintf(stdout, "⏭️  %s failed, continuing (on_fail: skip): %v\n", step.Name, err)
+		return nil
+	case OnFailEscalate:
+		record.finish(i, err)
+		escalate(step, ws, record, err)
+		return err
+	default:
+		record.finish(i, err)
+		return err
+	}
+}
+
+// escalate posts a human-attention message on the repo's coordination bus.
+func escalate(step Step, ws workspace, record *RunRecord, err error) {
+	data := map[string]string{"step": step.Name, "error": err.Error()}
+	if record != nil {
+		data["pipeline"] = record.Pipeline
+		data["run"] = record.ID
+	}
+	fmt.Printf("🚨 Escalating: step %q failed: %v\n", step.Name, err)
+	if ws.repo == "" {
+		return
+	}
+	if _, initErr := coordination.Init(ws.repo); initErr != nil {
+		return
+	}
+	coordination.Publish(ws.repo, coordination.Message{
+		Type:  coordination.MsgEscalation,
+		Agent: "pipeline",
+		Data:  data,
+	})
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStepAttemptsAndBackoff(t *testing.T) {
+	if n := (Step{}).attempts(); n != 1 {
+		t.Errorf("default attempts = %d, want 1", n)
+	}
+	if n := (Step{OnFail: OnFailRetry}).attempts(); n != 1+DefaultRetries {
+		t.Errorf("on_fail retry attempts = %d, want %d", n, 1+DefaultRetries)
+	}
+	if n := (Step{Retries: 2, OnFail: OnFailSkip}).attempts(); n != 3 {
+		t.Errorf("retries 2 attempts = %d, want 3", n)
+	}
+
+	s := Step{Backoff: "1s"}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 20: maxBackoff} {
+		if got := s.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	if got := (Step{}).backoff(1); got != DefaultBackoff {
+		t.Errorf("default backoff = %s, want %s", got, DefaultBackoff)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []Step{
+		{Name: "a", OnFail: "panic"},
+		{Name: "a", Retries: -1},
+		{Name: "a", Backoff: "soon"},
+	}
+	for _, s := range bad {
+		if err := validate(&Pipeline{Steps: []Step{s}}); err == nil {
+			t.Errorf("validate(%+v) should fail", s)
+		}
+	}
+	ok := &Pipeline{Steps: []Step{{Name: "a", Retries: 2, Backoff: "5s", OnFail: OnFailEscalate}}}
+	if err := validate(ok); err != nil {
+		t.Errorf("validate() error: %v", err)
+	}
+}
+
+func TestRunWithPolicy(t *testing.T) {
+	origSleep := retrySleep
+	retrySleep = func(time.Duration) {}
+	defer func() { retrySleep = origSleep }()
+
+	dir := t.TempDir()
+	ws := workspace{cloneDir: dir}
+	flag := filepath.Join(dir, "flag")
+	// Fails the first time, passes once the flag file exists.
+	flaky := Step{Name: "flaky", Run: "test -f " + flag + " || { touch " + flag + "; exit 1; }", Retries: 1}
+	failing := Step{Name: "broken", Run: "exit 2"}
+
+	record := &RunRecord{ID: "test", Steps: []StepRecord{{Name: "flaky"}, {Name: "broken"}}}
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", origHome)
+
+	if err := runWithPolicy(flaky, 0, ws, nil, record, io.Discard, io.Discard); err != nil {
+		t.Fatalf("flaky step should pass on retry: %v", err)
+	}
+	if record.Steps[0].Status != StepOK || record.Steps[0].Attempts != 2 {
+		t.Errorf("flaky record = %+v", record.Steps[0])
+	}
+
+	if err := runWithPolicy(failing, 1, ws, nil, record, io.Discard, io.Discard); err == nil {
+		t.Error("failing step should abort by default")
+	}
+	if record.Steps[1].Status != StepFailed || record.Status != "failed" {
+		t.Errorf("abort record = %+v, run status %q", record.Steps[1], record.Status)
+	}
+
+	failing.OnFail = OnFailSkip
+	record.Status = "running"
+	if err := runWithPolicy(failing, 1, ws, nil, record, io.Discard, io.Discard); err != nil {
+		t.Errorf("on_fail skip should continue: %v", err)
+	}
+	if record.Steps[1].Status != StepSkipped || record.Steps[1].Error == "" {
+		t.Errorf("skip record = %+v", record.Steps[1])
+	}
+	if record.Status != "success" {
+		t.Errorf("run status = %q, want success once every step is done", record.Status)
+	}
+}
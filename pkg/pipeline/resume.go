@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/process"
+)
+
+// Resume continues a failed or interrupted pipeline run from where it
+// stopped: steps recorded as ok or skipped are kept, everything else runs
+// again in the original clone and branch. A run whose process still appears
+// to be alive is refused unless force is set.
+func Resume(id string, force bool) error {
+	r, err := LoadRun(id)
+	if err != nil {
+		return err
+	}
+	if r.Spec == nil {
+		return fmt.Errorf("run %s predates resumable runs and cannot be resumed", id)
+	}
+	switch r.Status {
+	case "success":
+		return fmt.Errorf("run %s already succeeded", id)
+	case "running":
+		if !force && process.Alive(r.PID) {
+			return fmt.Errorf("run %s still appears to be running (pid %d); use --force if it is not", id, r.PID)
+		}
+	}
+	if err := validate(r.Spec); err != nil {
+		return err
+	}
+
+	ws := r.workspace()
+	if err := ensureClone(ws.repo, ws.cloneDir); err != nil {
+		return fmt.Errorf("clone failed: %w", err)
+	}
+	if err := ensureBranch(ws.cloneDir, ws.branch); err != nil {
+		return fmt.Errorf("branch setup failed: %w", err)
+	}
+
+	r.mu.Lock()
+	r.Status = "running"
+	r.PID = os.Getpid()
+	r.Resumes++
+	r.FinishedAt = time.Time{}
+	for i := range r.Steps {
+		if r.Steps[i].Status == StepRunning || r.Steps[i].Status == StepFailed {
+			r.Steps[i].Status = StepPending
+		}
+	}
+	err = r.save()
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to record pipeline run: %w", err)
+	}
+
+	fmt.Printf("⏯️  Resuming %s (%s)\n", r.ID, r.Pipeline)
+	if r.Spec.IsDAG() {
+		return runDAG(r.Spec, ws, r.Params, r)
+	}
+	return runSequential(r.Spec, ws, r.Params, r)
+}
@@ -0,0 +1,4 @@
+// Package process asks whether a process is still running, for the locks
+// and records that name the pid holding them, and starts host shell
+// commands the same way on every platform.
+package process
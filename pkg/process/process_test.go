@@ -0,0 +1,36 @@
+package process
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestAlive(t *testing.T) {
+	if !Alive(os.Getpid()) {
+		t.Error("this process should be alive")
+	}
+	for _, pid := range []int{0, -1} {
+		if Alive(pid) {
+			t.Errorf("Alive(%d) = true", pid)
+		}
+	}
+	exited := exec.Command("go", "version")
+	if err := exited.Run(); err != nil {
+		t.Skip("cannot start a process")
+	}
+	if Alive(exited.Process.Pid) {
+		t.Error("an exited process should not be alive")
+	}
+}
+
+func TestShell(t *testing.T) {
+	out, err := Shell("echo one && echo two").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(out)); len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("Shell output = %q", out)
+	}
+}
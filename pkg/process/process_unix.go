@@ -0,0 +1,21 @@
+//go:build !windows
+
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+// Alive reports whether a process with the given pid exists.
+func Alive(pid int) bool {
+	// Not a process: on unix, signalling 0 or -1 reaches a whole group.
+	if pid <= 0 {
+		return false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return proc.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package process
+
+import "syscall"
+
+// stillActive is the exit code Windows reports for a running process.
+const stillActive = 259
+
+// Alive reports whether a process with the given pid exists. Windows
+// has no signal 0, so it asks for the process's exit code instead; a
+// process it may not query belongs to another user and counts as alive.
+func Alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,17 @@
+package process
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// Shell runs command in a shell on the host: sh wherever there is one,
+// including the sh that Git for Windows installs, else cmd on Windows.
+func Shell(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		if _, err := exec.LookPath("sh"); err != nil {
+			return exec.Command("cmd", "/C", command)
+		}
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -1,9 +1,9 @@
 package review
 
 import (
-	"encoding/json"
 	"os"
-	"path/filepath"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
 )
 
 // Config holds connection settings for calling Lexi.
@@ -12,21 +12,17 @@ type Config struct {
 	LexiToken string `json:"lexi_token"`
 }
 
-// configPath returns the path to ~/.agentctl/config.json.
-func configPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".agentctl", "config.json")
-}
-
 // LoadConfig reads ~/.agentctl/config.json, falling back to APP_KEY env for the token.
 func LoadConfig() Config {
 	cfg := Config{
 		LexiURL: "http://localhost:8002",
 	}
 
-	data, err := os.ReadFile(configPath())
-	if err == nil {
-		json.Unmarshal(data, &cfg)
+	if c, err := config.Load(); err == nil {
+		if c.LexiURL != "" {
+			cfg.LexiURL = c.LexiURL
+		}
+		cfg.LexiToken = c.LexiToken
 	}
 
 	if cfg.LexiToken == "" {
@@ -0,0 +1,237 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/jordanpartridge/agentctl/pkg/container"
+)
+
+// ErrNoFeedback is returned when a PR has no unresolved review comments.
+var ErrNoFeedback = errors.New("no unresolved review comments")
+
+// Comment is one unresolved piece of review feedback: an inline thread
+// (Path set) or the body of a review that requested changes.
+type Comment struct {
+	Author   string
+	Path     string
+	Line     int
+	Body     string
+	Replies  []string
+	Outdated bool
+}
+
+// PRFeedback is the unresolved feedback on a pull request.
+type PRFeedback struct {
+	Number   int
+	URL      string
+	Branch   string
+	Comments []Comment
+}
+
+// FeedbackOptions controls a feedback run.
+type FeedbackOptions struct {
+	// PR overrides looking up the open PR for the agent's branch.
+	PR          int
+	MaxAttempts int
+	// Image is used when the agent has to be respawned from history.
+	Image string
+}
+
+const feedbackQuery = `query($owner: String!, $repo: String!, $pr: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $pr) {
+      number url headRefName
+      reviewThreads(first: 100) {
+        nodes {
+          isResolved isOutdated path line
+          comments(first: 50) { nodes { author { login } body } }
+        }
+      }
+      reviews(last: 20, states: [CHANGES_REQUESTED]) {
+        nodes { author { login } body }
+      }
+    }
+  }
+}`
+
+type ghAuthor struct {
+	Login string `json:"login"`
+}
+
+type feedbackResponse struct {
+	Data struct {
+		Repository struct {
+			PullRequest *struct {
+				Number        int    `json:"number"`
+				URL           string `json:"url"`
+				HeadRefName   string `json:"headRefName"`
+				ReviewThreads struct {
+					Nodes []struct {
+						IsResolved bool   `json:"isResolved"`
+						IsOutdated bool   `json:"isOutdated"`
+						Path       string `json:"path"`
+						Line       int    `json:"line"`
+						Comments   struct {
+							Nodes []struct {
+								Author ghAuthor `json:"author"`
+								Body   string   `json:"body"`
+							} `json:"nodes"`
+						} `json:"comments"`
+					} `json:"nodes"`
+				} `json:"reviewThreads"`
+				Reviews struct {
+					Nodes []struct {
+						Author ghAuthor `json:"author"`
+						Body   string   `json:"body"`
+					} `json:"nodes"`
+				} `json:"reviews"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	} `json:"data"`
+}
+
+// FetchFeedback pulls the unresolved review threads and change-request
+// review bodies of a PR via `gh api graphql`.
+func FetchFeedback(repo string, number int) (*PRFeedback, error) {
+	owner, name, ok := strings.Cut(repoSlug(repo), "/")
+	if !ok {
+		return nil, fmt.Errorf("cannot derive owner/repo from %q", repo)
+	}
+	out, err := exec.Command("gh", "api", "graphql",
+		"-f", "query="+feedbackQuery,
+		"-f", "owner="+owner,
+		"-f", "repo="+name,
+		"-F", "pr="+strconv.Itoa(number),
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("gh api graphql failed: %w", err)
+	}
+	return parseFeedback(out)
+}
+
+func parseFeedback(data []byte) (*PRFeedback, error) {
+	var resp feedbackResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse review threads: %w", err)
+	}
+	pr := resp.Data.Repository.PullRequest
+	if pr == nil {
+		return nil, fmt.Errorf("pull request not found")
+	}
+	fb := &PRFeedback{Number: pr.Number, URL: pr.URL, Branch: pr.HeadRefName}
+	for _, r := range pr.Reviews.Nodes {
+		if body := strings.TrimSpace(r.Body); body != "" {
+			fb.Comments = append(fb.Comments, Comment{Author: r.Author.Login, Body: body})
+		}
+	}
+	for _, t := range pr.ReviewThreads.Nodes {
+		if t.IsResolved || len(t.Comments.Nodes) == 0 {
+			continue
+		}
+		first := t.Comments.Nodes[0]
+		c := Comment{Author: first.Author.Login, Path: t.Path, Line: t.Line, Body: strings.TrimSpace(first.Body), Outdated: t.IsOutdated}
+		for _, reply := range t.Comments.Nodes[1:] {
+			c.Replies = append(c.Replies, fmt.Sprintf("%s: %s", reply.Author.Login, strings.TrimSpace(reply.Body)))
+		}
+		fb.Comments = append(fb.Comments, c)
+	}
+	return fb, nil
+}
+
+// FeedbackPrompt composes the follow-up task for an agent: every comment,
+// numbered, with where it applies.
+func FeedbackPrompt(fb *PRFeedback) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Reviewers left %d unresolved comment(s) on PR #%d (branch %s). ", len(fb.Comments), fb.Number, fb.Branch)
+	b.WriteString("Address every one of them: change the code where a change is asked for, ")
+	b.WriteString("keep the tests passing, commit, and push to the same branch.\n\n")
+	for i, c := range fb.Comments {
+		where := "General review comment"
+		if c.Path != "" {
+			where = c.Path
+			if c.Line > 0 {
+				where += ":" + strconv.Itoa(c.Line)
+			}
+			if c.Outdated {
+				where += " (on an older revision — find the current code)"
+			}
+		}
+		fmt.Fprintf(&b, "%d. %s — %s:\n%s\n", i+1, where, c.Author, indent(c.Body))
+		for _, r := range c.Replies {
+			fmt.Fprintf(&b, "   ↳ %s\n", r)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func indent(s string) string {
+	return "   " + strings.ReplaceAll(s, "\n", "\n   ")
+}
+
+// Feedback feeds a PR's unresolved review comments back to the agent that
+// opened it and runs it until they are addressed, then pushes. An agent that
+// was already cleaned up is respawned on the PR branch from its history.
+func Feedback(name string, opts FeedbackOptions) (*container.TaskResult, error) {
+	var repo, branch string
+	agent, err := container.LoadAgent(name)
+	running := err == nil
+	if running {
+		repo, branch = agent.Repo, agent.Branch
+	} else {
+		h, herr := container.LoadHistory(name)
+		if herr != nil {
+			return nil, fmt.Errorf("%w: %s (running or in history)", container.ErrAgentNotFound, name)
+		}
+		repo, branch = h.Repo, h.Branch
+	}
+	if repo == "" {
+		return nil, fmt.Errorf("agent %s has no repo", name)
+	}
+
+	number := opts.PR
+	if number == 0 {
+		pr, err := findOpenPR(repoSlug(repo), branch)
+		if err != nil {
+			return nil, fmt.Errorf("could not find open PR (pass --pr): %w", err)
+		}
+		number = pr.Number
+	}
+	fmt.Printf("🔍 Collecting unresolved review comments on PR #%d...\n", number)
+	fb, err := FetchFeedback(repo, number)
+	if err != nil {
+		return nil, err
+	}
+	if len(fb.Comments) == 0 {
+		return nil, ErrNoFeedback
+	}
+	fmt.Printf("💬 %d unresolved comment(s)\n", len(fb.Comments))
+
+	if !running {
+		fmt.Printf("🚀 Respawning %s on %s\n", name, fb.Branch)
+		if _, err := container.SpawnWithOptions(container.SpawnOptions{
+			Name:   name,
+			Repo:   repo,
+			Branch: fb.Branch,
+			Image:  opts.Image,
+			Intent: fmt.Sprintf("Address review feedback on PR #%d", fb.Number),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := container.RunUntilDone(name, FeedbackPrompt(fb), opts.MaxAttempts)
+	if err != nil {
+		return result, err
+	}
+	fmt.Printf("⬆️  Pushing %s\n", fb.Branch)
+	if err := container.Push(name, true); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package review
+
+import (
+	"strings"
+	"testing"
+)
+
+const threadsPayload = `{"data":{"repository":{"pullRequest":{
+	"number": 12, "url": "https://github.com/org/api/pull/12", "headRefName": "fix-rate-limit",
+	"reviewThreads": {"nodes": [
+		{"isResolved": true, "path": "main.go", "line": 3, "comments": {"nodes": [{"author": {"login": "ana"}, "body": "done already"}]}},
+		{"isResolved": false, "isOutdated": false, "path": "limit.go", "line": 42,
+		 "comments": {"nodes": [
+			{"author": {"login": "ana"}, "body": "Use a token bucket here.\nThe fixed window lets bursts through."},
+			{"author": {"login": "ben"}, "body": "+1"}
+		 ]}},
+		{"isResolved": false, "isOutdated": true, "path": "old.go", "line": 0,
+		 "comments": {"nodes": [{"author": {"login": "ben"}, "body": "Rename this."}]}}
+	]},
+	"reviews": {"nodes": [
+		{"author": {"login": "ana"}, "body": "Needs a test for the 429 path."},
+		{"author": {"login": "ben"}, "body": ""}
+	]}
+}}}}`
+
+func TestParseFeedback(t *testing.T) {
+	fb, err := parseFeedback([]byte(threadsPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fb.Number != 12 || fb.Branch != "fix-rate-limit" {
+		t.Errorf("feedback = %+v", fb)
+	}
+	if len(fb.Comments) != 3 {
+		t.Fatalf("got %d comments, want 3 (resolved threads and empty reviews skipped): %+v", len(fb.Comments), fb.Comments)
+	}
+	if c := fb.Comments[1]; c.Path != "limit.go" || c.Line != 42 || len(c.Replies) != 1 || c.Replies[0] != "ben: +1" {
+		t.Errorf("thread comment = %+v", c)
+	}
+
+	prompt := FeedbackPrompt(fb)
+	for _, want := range []string{
+		"3 unresolved comment(s) on PR #12 (branch fix-rate-limit)",
+		"push to the same branch",
+		"1. General review comment — ana:\n   Needs a test for the 429 path.",
+		"2. limit.go:42 — ana:\n   Use a token bucket here.\n   The fixed window lets bursts through.\n   ↳ ben: +1",
+		"3. old.go (on an older revision",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestParseFeedbackMissingPR(t *testing.T) {
+	if _, err := parseFeedback([]byte(`{"data":{"repository":{"pullRequest":null}}}`)); err == nil {
+		t.Error("expected an error for a missing pull request")
+	}
+}
@@ -43,7 +43,7 @@ func Review(name string) (*Result, error) {
 	// 1. Load agent metadata.
 	agent, err := container.LoadAgent(name)
 	if err != nil {
-		return nil, fmt.Errorf("agent not found: %w", err)
+		return nil, err
 	}
 
 	// 2. Resolve the GitHub repo slug from the full URL.
@@ -0,0 +1,317 @@
+// Package telemetry traces agentctl's work — spawns, run attempts, test
+// checks and podman calls — and exports the spans to an OpenTelemetry
+// collector over OTLP/HTTP with the JSON encoding, so agent runs show up in
+// the same tracing system as the rest of the automation.
+//
+// Tracing is off unless an endpoint is configured; Start then returns nil,
+// and every method of a nil *Span does nothing.
+package telemetry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// batchSize is how many ended spans are held before they are exported
+// without waiting for their root span to end.
+const batchSize = 128
+
+// exporter sends spans to the collector.
+type exporter struct {
+	url     string
+	headers map[string]string
+	service string
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	warned  bool
+}
+
+var (
+	exporterOnce sync.Once
+	active       *exporter
+)
+
+// current returns the configured exporter, or nil when tracing is off.
+func current() *exporter {
+	exporterOnce.Do(func() {
+		var tc config.Telemetry
+		if cfg, err := config.Load(); err == nil {
+			tc = cfg.Telemetry
+		}
+		active = newExporter(tc)
+	})
+	return active
+}
+
+// newExporter applies the OTEL_* environment to the config.
+func newExporter(tc config.Telemetry) *exporter {
+	url := ""
+	if tc.Endpoint != "" {
+		url = strings.TrimSuffix(tc.Endpoint, "/") + "/v1/traces"
+	}
+	if ep := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); ep != "" {
+		url = strings.TrimSuffix(ep, "/") + "/v1/traces"
+	}
+	if ep := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); ep != "" {
+		url = ep
+	}
+	if url == "" {
+		return nil
+	}
+	headers := map[string]string{}
+	for k, v := range tc.Headers {
+		headers[k] = v
+	}
+	for _, h := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(h, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	service := tc.ServiceName
+	if s := os.Getenv("OTEL_SERVICE_NAME"); s != "" {
+		service = s
+	}
+	if service == "" {
+		service = "agentctl"
+	}
+	return &exporter{url: url, headers: headers, service: service, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Span is one timed operation in a trace.
+type Span struct {
+	exp     *exporter
+	traceID [16]byte
+	id      [8]byte
+	parent  *Span
+	name    string
+	start   time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	end   time.Time
+	err   error
+	ended bool
+}
+
+// Start begins a span under parent, or a new trace when parent is nil.
+// Attributes are given as key, value pairs; values are strings, bools or
+// integers.
+func Start(parent *Span, name string, kv ...any) *Span {
+	exp := current()
+	if parent != nil {
+		exp = parent.exp
+	}
+	if exp == nil {
+		return nil
+	}
+	s := &Span{exp: exp, parent: parent, name: name, start: time.Now(), attrs: map[string]any{}}
+	if parent != nil {
+		s.traceID = parent.traceID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.id[:])
+	s.Set(kv...)
+	return s
+}
+
+// Set adds key, value attribute pairs to the span.
+func (s *Span) Set(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); ok {
+			s.attrs[k] = kv[i+1]
+		}
+	}
+}
+
+// End finishes the span, marking it failed when err is non-nil. Ending a
+// root span exports the spans of its trace that are still held.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end, s.err = true, time.Now(), err
+	s.mu.Unlock()
+
+	s.exp.mu.Lock()
+	s.exp.pending = append(s.exp.pending, s)
+	full := len(s.exp.pending) >= batchSize
+	s.exp.mu.Unlock()
+	if s.parent == nil || full {
+		s.exp.flush()
+	}
+}
+
+// Flush exports the spans ended so far, e.g. after each run attempt so a
+// long run shows up before it finishes.
+func (s *Span) Flush() {
+	if s != nil {
+		s.exp.flush()
+	}
+}
+
+func (e *exporter) flush() {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := e.send(spans); err != nil {
+		e.mu.Lock()
+		warn := !e.warned
+		e.warned = true
+		e.mu.Unlock()
+		if warn {
+			fmt.Fprintf(os.Stderr, "warning: could not export traces to %s: %v\n", e.url, err)
+		}
+	}
+}
+
+func (e *exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/JSON message shapes (opentelemetry-proto, trace/v1).
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 1 ok, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// spanKindInternal is OTLP's SPAN_KIND_INTERNAL.
+const spanKindInternal = 1
+
+func (e *exporter) encode(spans []*Span) otlpRequest {
+	var out []otlpSpan
+	for _, s := range spans {
+		s.mu.Lock()
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.id[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        attributes(s.attrs),
+			Status:            otlpStatus{Code: 1},
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: 2, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		if s.parent != nil {
+			o.ParentSpanID = hex.EncodeToString(s.parent.id[:])
+		}
+		out = append(out, o)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(map[string]any{"service.name": e.service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "agentctl"}, Spans: out}},
+	}}}
+}
+
+// attributes encodes attribute values as OTLP AnyValues, sorted by key.
+func attributes(attrs map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []otlpKeyValue
+	for _, k := range keys {
+		var v map[string]any
+		switch x := attrs[k].(type) {
+		case bool:
+			v = map[string]any{"boolValue": x}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case string:
+			v = map[string]any{"stringValue": x}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: v})
+	}
+	return out
+}
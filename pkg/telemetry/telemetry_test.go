@@ -0,0 +1,142 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+)
+
+// collect points tracing at a fake collector and returns the export
+// requests it receives.
+func collect(t *testing.T) func() []otlpRequest {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid OTLP JSON: %v", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	exporterOnce.Do(func() {})
+	prev := active
+	active = newExporter(config.Telemetry{Endpoint: srv.URL, Headers: map[string]string{"X-Api-Key": "secret"}})
+	t.Cleanup(func() { active = prev })
+
+	return func() []otlpRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return reqs
+	}
+}
+
+func TestSpansExportWithRoot(t *testing.T) {
+	requests := collect(t)
+
+	run := Start(nil, "agentctl.run", "agent.name", "worker")
+	attempt := Start(run, "agentctl.attempt", "agent.attempt", 2, "git.uncommitted", true)
+	attempt.End(errors.New("tests failing"))
+	if len(requests()) != 0 {
+		t.Fatal("a child span should be held until its root ends")
+	}
+	run.End(nil)
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 export, got %d", len(reqs))
+	}
+	rs := reqs[0].ResourceSpans[0]
+	if rs.Resource.Attributes[0].Key != "service.name" || rs.Resource.Attributes[0].Value["stringValue"] != "agentctl" {
+		t.Errorf("resource = %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	child, root := spans[0], spans[1]
+	if child.TraceID != root.TraceID || child.ParentSpanID != root.SpanID || root.ParentSpanID != "" {
+		t.Errorf("child %s/%s should be under root %s/%s", child.TraceID, child.ParentSpanID, root.TraceID, root.SpanID)
+	}
+	if len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Errorf("ids should be hex: trace %q span %q", root.TraceID, root.SpanID)
+	}
+	if child.Status.Code != 2 || child.Status.Message != "tests failing" || root.Status.Code != 1 {
+		t.Errorf("statuses = %+v, %+v", child.Status, root.Status)
+	}
+	want := map[string]map[string]any{
+		"agent.attempt":   {"intValue": "2"},
+		"git.uncommitted": {"boolValue": true},
+	}
+	for _, kv := range child.Attributes {
+		if w, ok := want[kv.Key]; ok {
+			for k, v := range w {
+				if kv.Value[k] != v {
+					t.Errorf("%s = %v, want %s %v", kv.Key, kv.Value, k, v)
+				}
+			}
+			delete(want, kv.Key)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing attributes %v", want)
+	}
+}
+
+func TestTracingOff(t *testing.T) {
+	exporterOnce.Do(func() {})
+	prev := active
+	active = nil
+	defer func() { active = prev }()
+
+	span := Start(nil, "agentctl.spawn")
+	if span != nil {
+		t.Fatal("Start should return nil without an endpoint")
+	}
+	// A nil span is safe to use.
+	span.Set("agent.name", "x")
+	Start(span, "child").End(nil)
+	span.Flush()
+	span.End(nil)
+}
+
+func TestEnvOverridesConfig(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer abc, x-team=infra")
+	t.Setenv("OTEL_SERVICE_NAME", "ci-agents")
+
+	e := newExporter(config.Telemetry{Endpoint: "http://localhost:4318", ServiceName: "mine"})
+	if e.url != "http://collector:4318/v1/traces" {
+		t.Errorf("url = %q", e.url)
+	}
+	if e.headers["authorization"] != "Bearer abc" || e.headers["x-team"] != "infra" {
+		t.Errorf("headers = %v", e.headers)
+	}
+	if e.service != "ci-agents" {
+		t.Errorf("service = %q", e.service)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if newExporter(config.Telemetry{}) != nil {
+		t.Error("no endpoint should mean no exporter")
+	}
+}
@@ -0,0 +1,277 @@
+// Package tutorial walks a new user through agentctl end to end against a
+// sandbox repository: spawn an agent, watch it, run a tiny task, check it,
+// open a PR and clean up. Each step is explained, confirmed and then run for
+// real, and progress is checkpointed so the tutorial can be left and resumed.
+package tutorial
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jordanpartridge/agentctl/pkg/config"
+	"github.com/jordanpartridge/agentctl/pkg/namespace"
+)
+
+// Branch is the branch the tutorial's agent commits to and opens its PR from.
+const Branch = "agentctl-tutorial"
+
+// Step is one stage of the tutorial.
+type Step struct {
+	Title   string
+	Explain string
+	// Command is what the step runs; a first word of "agentctl" runs this
+	// agentctl binary.
+	Command []string
+	// Background starts the command and moves on without waiting; its
+	// output goes to BackgroundLog().
+	Background bool
+	// Limit stops the command after this long (for commands that follow
+	// output until interrupted).
+	Limit time.Duration
+}
+
+// Steps returns the tutorial for an agent named name on the sandbox repo.
+func Steps(repo, name string) []Step {
+	task := fmt.Sprintf("Create a branch named %s, add a file HELLO.md containing a one-line greeting from an agentctl agent, and commit it.", Branch)
+	return []Step{
+		{
+			Title: "Spawn an agent",
+			Explain: "An agent is a podman container with the repo cloned into it. Spawn starts the container,\n" +
+				"clones the sandbox on its default branch and runs any setup the repo needs.",
+			Command: []string{"agentctl", "spawn", name, repo, "--intent", "agentctl tutorial"},
+		},
+		{
+			Title:   "Look at it",
+			Explain: "status shows what agentctl knows about an agent: its container, repo, branch and intent.",
+			Command: []string{"agentctl", "status", name},
+		},
+		{
+			Title: "Give it a task",
+			Explain: "run keeps the agent working until the task is done — tests pass and everything is committed —\n" +
+				"retrying with what is still missing. It runs in the background here so we can watch.",
+			Command:    []string{"agentctl", "run", name, task, "3"},
+			Background: true,
+		},
+		{
+			Title: "Watch it work",
+			Explain: "spy streams the agent's session: each tool call, file edit and command as it happens.\n" +
+				"We watch for 45 seconds; normally you would leave it open in another terminal.",
+			Command: []string{"agentctl", "spy", name, "--compact"},
+			Limit:   45 * time.Second,
+		},
+		{
+			Title:   "Wait for it",
+			Explain: "wait blocks until the agent finishes, with exit codes scripts and CI can use.",
+			Command: []string{"agentctl", "wait", name, "--timeout", "20m"},
+		},
+		{
+			Title:   "Check the result",
+			Explain: "check runs the completion check itself: the test suite and a look for uncommitted changes.",
+			Command: []string{"agentctl", "check", name},
+		},
+		{
+			Title:   "Push the branch",
+			Explain: "push runs the integrity checks (conflict markers, debug prints, a build) and pushes the agent's branch.",
+			Command: []string{"agentctl", "push", name},
+		},
+		{
+			Title:   "Open a pull request",
+			Explain: "The work is on " + Branch + "; open a PR for it as you would for a teammate's branch.",
+			Command: []string{"gh", "pr", "create", "--repo", config.RepoKey(repo), "--head", Branch,
+				"--title", "agentctl tutorial: add HELLO.md", "--body", "Opened by `agentctl tutorial`."},
+		},
+		{
+			Title: "Clean up",
+			Explain: "kill stops and removes the container and takes the agent off the coordination bus.\n" +
+				"For finished agents, cleanup and prune do the same in bulk and keep a history entry.",
+			Command: []string{"agentctl", "kill", name},
+		},
+	}
+}
+
+// Progress is the tutorial's checkpoint.
+type Progress struct {
+	Repo string `json:"repo"`
+	Name string `json:"name"`
+	Done int    `json:"done"` // steps finished or skipped
+}
+
+func progressPath() string {
+	return filepath.Join(namespace.Root(), "tutorial.json")
+}
+
+// BackgroundLog is where background steps write their output.
+func BackgroundLog() string {
+	return filepath.Join(namespace.Root(), "tutorial.log")
+}
+
+// LoadProgress returns the saved checkpoint, or nil if there is none.
+func LoadProgress() *Progress {
+	data, err := os.ReadFile(progressPath())
+	if err != nil {
+		return nil
+	}
+	var p Progress
+	if json.Unmarshal(data, &p) != nil {
+		return nil
+	}
+	return &p
+}
+
+// Save writes the checkpoint.
+func (p *Progress) Save() error {
+	if err := os.MkdirAll(namespace.Root(), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(p, "", "  ")
+	return os.WriteFile(progressPath(), data, 0644)
+}
+
+// Reset removes the checkpoint.
+func Reset() {
+	os.Remove(progressPath())
+}
+
+// Runner runs the tutorial, reading answers from In and writing to Out.
+type Runner struct {
+	In  io.Reader
+	Out io.Writer
+	// Exec runs a step's command (RunStep by default).
+	Exec func(Step) error
+}
+
+// Run takes the user through the steps from p.Done on, checkpointing after
+// each. At each step Enter runs it, s skips it and q stops (to resume later);
+// a failing step can be retried, skipped or left. It returns true when the
+// tutorial reached the end.
+func (r *Runner) Run(steps []Step, p *Progress) (bool, error) {
+	exec := r.Exec
+	if exec == nil {
+		exec = RunStep
+	}
+	in := bufio.NewReader(r.In)
+	ask := func(prompt string) string {
+		fmt.Fprint(r.Out, prompt)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return "q"
+		}
+		return strings.ToLower(strings.TrimSpace(line))
+	}
+
+	for p.Done < len(steps) {
+		step := steps[p.Done]
+		fmt.Fprintf(r.Out, "\n📘 Step %d/%d: %s\n", p.Done+1, len(steps), step.Title)
+		fmt.Fprintln(r.Out, indent(step.Explain))
+		fmt.Fprintf(r.Out, "\n   $ %s\n\n", shellLine(step.Command))
+
+		switch ask("   [Enter] run  [s] skip  [q] quit: ") {
+		case "q":
+			fmt.Fprintln(r.Out, "👋 Progress saved; run `agentctl tutorial` to pick up here.")
+			return false, p.Save()
+		case "s":
+			fmt.Fprintln(r.Out, "⏭️  Skipped")
+		default:
+			if !r.attempt(step, exec, ask) {
+				fmt.Fprintln(r.Out, "👋 Progress saved; run `agentctl tutorial` to pick up here.")
+				return false, p.Save()
+			}
+		}
+		p.Done++
+		if err := p.Save(); err != nil {
+			return false, err
+		}
+	}
+	fmt.Fprintln(r.Out, "\n🎓 Tutorial complete. `agentctl help` lists everything else.")
+	Reset()
+	return true, nil
+}
+
+// attempt runs a step until it succeeds or the user skips it; it returns
+// false if the user quits.
+func (r *Runner) attempt(step Step, exec func(Step) error, ask func(string) string) bool {
+	for {
+		err := exec(step)
+		if err == nil {
+			fmt.Fprintln(r.Out, "✅ Done")
+			return true
+		}
+		fmt.Fprintf(r.Out, "❌ %v\n", err)
+		switch ask("   [r] retry  [s] skip  [q] quit: ") {
+		case "r", "":
+			continue
+		case "s":
+			fmt.Fprintln(r.Out, "⏭️  Skipped")
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// RunStep runs a step's command with its output on the terminal.
+func RunStep(step Step) error {
+	argv := append([]string(nil), step.Command...)
+	if argv[0] == "agentctl" {
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		argv[0] = self
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	if step.Background {
+		log, err := os.Create(BackgroundLog())
+		if err != nil {
+			return err
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, log, log
+		if err := cmd.Start(); err != nil {
+			log.Close()
+			return err
+		}
+		go func() {
+			cmd.Wait()
+			log.Close()
+		}()
+		fmt.Printf("   (running in the background; output in %s)\n", BackgroundLog())
+		return nil
+	}
+	if step.Limit > 0 {
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		timer := time.AfterFunc(step.Limit, func() { cmd.Process.Kill() })
+		err := cmd.Wait()
+		if !timer.Stop() {
+			return nil // stopped at the limit, as intended
+		}
+		return err
+	}
+	return cmd.Run()
+}
+
+func indent(s string) string {
+	return "   " + strings.ReplaceAll(s, "\n", "\n   ")
+}
+
+// shellLine renders a command for display, quoting arguments with spaces.
+func shellLine(argv []string) string {
+	parts := make([]string, len(argv))
+	for i, a := range argv {
+		if strings.ContainsAny(a, " `'\"") {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		parts[i] = a
+	}
+	return strings.Join(parts, " ")
+}
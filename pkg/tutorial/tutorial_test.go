@@ -0,0 +1,117 @@
+package tutorial
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func setHome(t *testing.T) {
+	tmpHome := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	t.Cleanup(func() { os.Setenv("HOME", origHome) })
+}
+
+func TestSteps(t *testing.T) {
+	steps := Steps("https://github.com/me/agentctl-sandbox", "tut")
+	var cmds []string
+	for _, s := range steps {
+		if s.Title == "" || s.Explain == "" || len(s.Command) == 0 {
+			t.Errorf("incomplete step %+v", s)
+		}
+		cmds = append(cmds, s.Command[0]+" "+s.Command[1])
+	}
+	want := "agentctl spawn,agentctl status,agentctl run,agentctl spy,agentctl wait,agentctl check,agentctl push,gh pr,agentctl kill"
+	if got := strings.Join(cmds, ","); got != want {
+		t.Errorf("steps = %s\nwant %s", got, want)
+	}
+	if pr := strings.Join(steps[7].Command, " "); !strings.Contains(pr, "--repo me/agentctl-sandbox --head "+Branch) {
+		t.Errorf("pr step = %s", pr)
+	}
+	if !steps[2].Background || steps[3].Limit == 0 {
+		t.Error("run should be backgrounded and spy time-limited")
+	}
+}
+
+func TestRunCheckpoints(t *testing.T) {
+	setHome(t)
+	steps := []Step{
+		{Title: "one", Explain: "first", Command: []string{"agentctl", "a"}},
+		{Title: "two", Explain: "second", Command: []string{"agentctl", "b"}},
+		{Title: "three", Explain: "third", Command: []string{"agentctl", "c"}},
+	}
+	var ran []string
+	exec := func(s Step) error {
+		ran = append(ran, s.Title)
+		return nil
+	}
+
+	// Run the first step, skip the second, quit at the third.
+	var out bytes.Buffer
+	r := &Runner{In: strings.NewReader("\ns\nq\n"), Out: &out, Exec: exec}
+	p := &Progress{Repo: "r", Name: "tut"}
+	done, err := r.Run(steps, p)
+	if err != nil || done {
+		t.Fatalf("Run = %v, %v", done, err)
+	}
+	if strings.Join(ran, ",") != "one" || p.Done != 2 {
+		t.Errorf("ran %v, done %d", ran, p.Done)
+	}
+	if !strings.Contains(out.String(), "Step 3/3: three") || !strings.Contains(out.String(), "$ agentctl c") {
+		t.Errorf("output:\n%s", out.String())
+	}
+
+	// Resuming picks up at the checkpoint and clears it at the end.
+	saved := LoadProgress()
+	if saved == nil || saved.Done != 2 || saved.Name != "tut" {
+		t.Fatalf("checkpoint = %+v", saved)
+	}
+	r = &Runner{In: strings.NewReader("\n"), Out: &out, Exec: exec}
+	if done, err := r.Run(steps, saved); err != nil || !done {
+		t.Fatalf("resumed Run = %v, %v", done, err)
+	}
+	if strings.Join(ran, ",") != "one,three" {
+		t.Errorf("ran %v", ran)
+	}
+	if LoadProgress() != nil {
+		t.Error("checkpoint should be removed when the tutorial completes")
+	}
+}
+
+func TestRunRetry(t *testing.T) {
+	setHome(t)
+	steps := []Step{{Title: "flaky", Explain: "x", Command: []string{"agentctl", "x"}}}
+	calls := 0
+	exec := func(Step) error {
+		if calls++; calls == 1 {
+			return errors.New("not yet")
+		}
+		return nil
+	}
+	var out bytes.Buffer
+	r := &Runner{In: strings.NewReader("\nr\n"), Out: &out, Exec: exec}
+	if done, err := r.Run(steps, &Progress{}); err != nil || !done || calls != 2 {
+		t.Errorf("Run = %v, %v after %d calls", done, err, calls)
+	}
+	if !strings.Contains(out.String(), "❌ not yet") {
+		t.Errorf("output:\n%s", out.String())
+	}
+
+	// Quitting after a failure keeps the step to do next time.
+	calls = 0
+	p := &Progress{}
+	r = &Runner{In: strings.NewReader("\nq\n"), Out: &out, Exec: exec}
+	if done, _ := r.Run(steps, p); done || p.Done != 0 {
+		t.Errorf("done %v at %d", done, p.Done)
+	}
+}
+
+func TestShellLine(t *testing.T) {
+	got := shellLine([]string{"agentctl", "run", "tut", "it's a task", "3"})
+	if got != `agentctl run tut 'it'\''s a task' 3` {
+		t.Errorf("shellLine = %s", got)
+	}
+}